@@ -0,0 +1,258 @@
+package aggregator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/pingcap/metering_sdk/config"
+	"github.com/pingcap/metering_sdk/internal/compress"
+	"github.com/pingcap/metering_sdk/logging"
+	meteringreader "github.com/pingcap/metering_sdk/reader/metering"
+	"github.com/pingcap/metering_sdk/storage"
+	"github.com/pingcap/metering_sdk/writer"
+	"go.uber.org/zap"
+)
+
+const (
+	// LogicalClusterIDField is the Data entry field used to group rows by logical cluster
+	LogicalClusterIDField = common.LogicalClusterIDField
+	// WindowHour hourly roll-up window
+	WindowHour = "hour"
+	// hourSeconds length of an hourly window in seconds
+	hourSeconds = 3600
+)
+
+// AggregatedData roll-up data structure for one category in one window
+type AggregatedData struct {
+	FormatVersion int                      `json:"format_version"` // common.CurrentFormatVersion
+	Timestamp     int64                    `json:"timestamp"`      // window start timestamp
+	Window        string                   `json:"window"`         // window size, e.g. "hour"
+	Category      string                   `json:"category"`       // service category identifier
+	SourceFiles   int                      `json:"source_files"`   // number of files aggregated
+	Data          []map[string]interface{} `json:"data"`           // summed metering values per logical cluster
+}
+
+// Aggregator rolls minute-level metering files up into window summaries
+type Aggregator struct {
+	provider storage.ObjectStorageProvider
+	reader   *meteringreader.MeteringReader
+	config   *config.Config
+	logger   *zap.Logger
+	calls    *storage.CallCounter // storage requests made by the aggregator, except its reader
+}
+
+// NewAggregator creates a new aggregator reading from and writing to the given provider
+func NewAggregator(provider storage.ObjectStorageProvider, cfg *config.Config) *Aggregator {
+	if cfg == nil {
+		cfg = config.DefaultConfig()
+	}
+
+	calls := &storage.CallCounter{}
+	return &Aggregator{
+		provider: cfg.WrapProvider(provider, calls),
+		reader:   meteringreader.NewMeteringReader(provider, cfg),
+		config:   cfg,
+		logger:   cfg.ProviderLogger(provider),
+		calls:    calls,
+	}
+}
+
+// CallStats returns the storage requests the aggregator made, reading source files included
+func (a *Aggregator) CallStats() storage.CallStats {
+	return a.calls.Stats().Add(a.reader.CallStats())
+}
+
+// Aggregate reads all parts in the time range and sums metering values per category and logical cluster.
+// Fields that are not metering values (other than the logical cluster ID) are dropped.
+// Summing two values of the same metric with different units is an error, unless a unit registry is
+// configured: values are then converted to the unit seen first if both units have the same dimension.
+func (a *Aggregator) Aggregate(ctx context.Context, tr meteringreader.TimeRange) (map[string]*AggregatedData, error) {
+	// category -> logical cluster -> metric -> value
+	sums := make(map[string]map[string]map[string]*common.MeteringValue)
+	sourceFiles := make(map[string]map[string]struct{})
+
+	for rec, err := range a.reader.Records(ctx, meteringreader.RecordQuery{TimeRange: tr}) {
+		if err != nil {
+			return nil, err
+		}
+
+		category := rec.File.Category
+		if sums[category] == nil {
+			sums[category] = make(map[string]map[string]*common.MeteringValue)
+			sourceFiles[category] = make(map[string]struct{})
+		}
+		sourceFiles[category][rec.File.Path] = struct{}{}
+
+		logicalClusterID, _ := rec.Data[LogicalClusterIDField].(string)
+		if logicalClusterID == "" {
+			a.logger.Warn("Record without logical cluster ID, aggregating under empty ID",
+				logging.Path(rec.File.Path),
+			)
+		}
+		metrics := sums[category][logicalClusterID]
+		if metrics == nil {
+			metrics = make(map[string]*common.MeteringValue)
+			sums[category][logicalClusterID] = metrics
+		}
+
+		for field, raw := range rec.Data {
+			if field == LogicalClusterIDField {
+				continue
+			}
+			value, ok := common.ParseMeteringValue(raw)
+			if !ok {
+				continue
+			}
+			current, exists := metrics[field]
+			if !exists {
+				metrics[field] = value.Clone()
+				continue
+			}
+			if current.Unit != value.Unit {
+				if a.config.Units == nil {
+					return nil, fmt.Errorf("unit mismatch for %s of logical cluster %s in category %s: %s vs %s",
+						field, logicalClusterID, category, current.Unit, value.Unit)
+				}
+				converted, err := a.config.Units.ConvertValue(value, current.Unit)
+				if err != nil {
+					return nil, fmt.Errorf("unit mismatch for %s of logical cluster %s in category %s: %w",
+						field, logicalClusterID, category, err)
+				}
+				value = converted
+			}
+			sum, err := current.Add(value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to sum %s of logical cluster %s in category %s: %w",
+					field, logicalClusterID, category, err)
+			}
+			metrics[field] = sum
+		}
+	}
+
+	result := make(map[string]*AggregatedData, len(sums))
+	for category, clusters := range sums {
+		clusterIDs := make([]string, 0, len(clusters))
+		for id := range clusters {
+			clusterIDs = append(clusterIDs, id)
+		}
+		sort.Strings(clusterIDs)
+
+		data := make([]map[string]interface{}, 0, len(clusterIDs))
+		for _, id := range clusterIDs {
+			entry := make(map[string]interface{}, len(clusters[id])+1)
+			entry[LogicalClusterIDField] = id
+			for field, value := range clusters[id] {
+				entry[field] = value
+			}
+			data = append(data, entry)
+		}
+
+		result[category] = &AggregatedData{
+			FormatVersion: common.CurrentFormatVersion,
+			Timestamp:     tr.Start,
+			Category:      category,
+			SourceFiles:   len(sourceFiles[category]),
+			Data:          data,
+		}
+	}
+
+	a.logger.Debug("Aggregated metering data",
+		zap.Int64("start", tr.Start),
+		zap.Int64("end", tr.End),
+		zap.Int("categories_count", len(result)),
+	)
+
+	return result, nil
+}
+
+// RollupHour aggregates the hour starting at hourTimestamp and writes one roll-up file per category
+// under metering/agg/hour/{timestamp}/{category}.json.gz
+func (a *Aggregator) RollupHour(ctx context.Context, hourTimestamp int64) ([]*AggregatedData, error) {
+	if hourTimestamp <= 0 || hourTimestamp%hourSeconds != 0 {
+		return nil, fmt.Errorf("hour timestamp must be positive and divisible by %d", hourSeconds)
+	}
+
+	aggregated, err := a.Aggregate(ctx, meteringreader.TimeRange{
+		Start: hourTimestamp,
+		End:   hourTimestamp + hourSeconds - 60,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	categories := make([]string, 0, len(aggregated))
+	for category := range aggregated {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	results := make([]*AggregatedData, 0, len(categories))
+	for _, category := range categories {
+		data := aggregated[category]
+		data.Window = WindowHour
+		if err := a.writeAggregated(ctx, data); err != nil {
+			return nil, err
+		}
+		results = append(results, data)
+	}
+
+	a.logger.Info("Successfully rolled up metering data",
+		zap.Int64("hour_timestamp", hourTimestamp),
+		zap.Int("categories_count", len(results)),
+	)
+
+	return results, nil
+}
+
+// AggregatedPath returns the storage path of a roll-up file
+func AggregatedPath(window string, timestamp int64, category string) string {
+	return fmt.Sprintf("metering/agg/%s/%d/%s.json.gz", window, timestamp, category)
+}
+
+// writeAggregated serializes, compresses and uploads a roll-up file
+func (a *Aggregator) writeAggregated(ctx context.Context, data *AggregatedData) error {
+	path := AggregatedPath(data.Window, data.Timestamp, data.Category)
+
+	// With conditional put the provider rejects the upload itself, saving the Exists round-trip
+	conditional, useConditionalPut := a.provider.(storage.ConditionalUploader)
+	useConditionalPut = useConditionalPut && !a.config.DisableConditionalPut && !a.config.OverwriteExisting
+
+	if !a.config.OverwriteExisting && !useConditionalPut {
+		exists, err := a.provider.Exists(ctx, path)
+		if err != nil {
+			return fmt.Errorf("failed to check if file exists: %w", err)
+		}
+		if exists {
+			return fmt.Errorf("%w: %s", writer.ErrFileExists, path)
+		}
+	}
+
+	compressedData, err := compress.GzipJSON(data)
+	if err != nil {
+		return fmt.Errorf("failed to serialize aggregated data: %w", err)
+	}
+
+	ctx = a.config.UploadContext(ctx)
+	if useConditionalPut {
+		err = conditional.UploadIfNotExists(ctx, path, bytes.NewReader(compressedData))
+		if errors.Is(err, storage.ErrObjectExists) {
+			return fmt.Errorf("%w: %s", writer.ErrFileExists, path)
+		}
+	} else {
+		err = a.provider.Upload(ctx, path, bytes.NewReader(compressedData))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to upload aggregated data: %w", err)
+	}
+
+	a.logger.Debug("Successfully wrote aggregated data",
+		logging.Path(path),
+		zap.Int("logical_clusters", len(data.Data)),
+	)
+
+	return nil
+}
@@ -0,0 +1,239 @@
+package aggregator
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/pingcap/metering_sdk/config"
+	meteringreader "github.com/pingcap/metering_sdk/reader/metering"
+	"github.com/pingcap/metering_sdk/storage"
+	"github.com/pingcap/metering_sdk/units"
+	"github.com/pingcap/metering_sdk/writer"
+	meteringwriter "github.com/pingcap/metering_sdk/writer/metering"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestData(t *testing.T, provider storage.ObjectStorageProvider, data ...*common.MeteringData) {
+	w := meteringwriter.NewMeteringWriterWithSharedPool(provider, config.DefaultConfig(), "pool1")
+	defer w.Close()
+	for _, d := range data {
+		require.NoError(t, w.Write(context.Background(), d))
+	}
+}
+
+func TestAggregator_Aggregate(t *testing.T) {
+	provider := storage.NewMemoryProvider()
+	const hour = int64(1755849600)
+
+	writeTestData(t, provider,
+		&common.MeteringData{
+			Timestamp: hour,
+			Category:  "tidb",
+			SelfID:    "server1",
+			Data: []map[string]interface{}{
+				{"logical_cluster_id": "lc1", "ru": &common.MeteringValue{Value: 10, Unit: "RU"}, "note": "ignored"},
+				{"logical_cluster_id": "lc2", "ru": &common.MeteringValue{Value: 5, Unit: "RU"}},
+			},
+		},
+		&common.MeteringData{
+			Timestamp: hour + 60,
+			Category:  "tidb",
+			SelfID:    "server2",
+			Data: []map[string]interface{}{
+				{"logical_cluster_id": "lc1", "ru": &common.MeteringValue{Value: 7, Unit: "RU"}},
+			},
+		},
+		&common.MeteringData{
+			Timestamp: hour + 120,
+			Category:  "tikv",
+			SelfID:    "store1",
+			Data: []map[string]interface{}{
+				{"logical_cluster_id": "lc1", "storage": &common.MeteringValue{Value: 100, Unit: "bytes"}},
+			},
+		},
+	)
+
+	agg := NewAggregator(provider, config.DefaultConfig())
+	result, err := agg.Aggregate(context.Background(), meteringreader.TimeRange{Start: hour, End: hour + 3540})
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+
+	tidb := result["tidb"]
+	require.NotNil(t, tidb)
+	assert.Equal(t, 2, tidb.SourceFiles)
+	require.Len(t, tidb.Data, 2)
+	assert.Equal(t, "lc1", tidb.Data[0]["logical_cluster_id"])
+	assert.Equal(t, &common.MeteringValue{Value: 17, Unit: "RU"}, tidb.Data[0]["ru"])
+	assert.NotContains(t, tidb.Data[0], "note")
+	assert.Equal(t, &common.MeteringValue{Value: 5, Unit: "RU"}, tidb.Data[1]["ru"])
+
+	tikv := result["tikv"]
+	require.NotNil(t, tikv)
+	assert.Equal(t, &common.MeteringValue{Value: 100, Unit: "bytes"}, tikv.Data[0]["storage"])
+}
+
+func TestAggregator_UnitMismatch(t *testing.T) {
+	provider := storage.NewMemoryProvider()
+	const hour = int64(1755849600)
+
+	writeTestData(t, provider,
+		&common.MeteringData{
+			Timestamp: hour,
+			Category:  "tidb",
+			SelfID:    "server1",
+			Data: []map[string]interface{}{
+				{"logical_cluster_id": "lc1", "memory": &common.MeteringValue{Value: 1, Unit: "MB"}},
+				{"logical_cluster_id": "lc1", "memory": &common.MeteringValue{Value: 1, Unit: "MiB"}},
+			},
+		},
+	)
+
+	agg := NewAggregator(provider, config.DefaultConfig())
+	_, err := agg.Aggregate(context.Background(), meteringreader.TimeRange{Start: hour, End: hour})
+	assert.ErrorContains(t, err, "unit mismatch")
+
+	// Units of the same dimension are converted with a unit registry
+	agg = NewAggregator(provider, config.DefaultConfig().WithUnitRegistry(units.NewRegistry()))
+	result, err := agg.Aggregate(context.Background(), meteringreader.TimeRange{Start: hour, End: hour})
+	require.NoError(t, err)
+	memory, ok := common.ParseMeteringValue(result["tidb"].Data[0]["memory"])
+	require.True(t, ok)
+	assert.Equal(t, "MB", memory.Unit)
+	assert.InDelta(t, 2.048576, memory.Float64(), 1e-9)
+}
+
+func TestAggregator_RollupHour(t *testing.T) {
+	provider := storage.NewMemoryProvider()
+	const hour = int64(1755849600)
+
+	writeTestData(t, provider,
+		&common.MeteringData{
+			Timestamp: hour + 3540,
+			Category:  "tidb",
+			SelfID:    "server1",
+			Data: []map[string]interface{}{
+				{"logical_cluster_id": "lc1", "ru": &common.MeteringValue{Value: 3, Unit: "RU"}},
+			},
+		},
+		&common.MeteringData{
+			Timestamp: hour + 3600, // next hour
+			Category:  "tidb",
+			SelfID:    "server1",
+			Data: []map[string]interface{}{
+				{"logical_cluster_id": "lc1", "ru": &common.MeteringValue{Value: 100, Unit: "RU"}},
+			},
+		},
+	)
+
+	agg := NewAggregator(provider, config.DefaultConfig())
+	ctx := context.Background()
+
+	_, err := agg.RollupHour(ctx, hour+60)
+	assert.Error(t, err, "non hour-aligned timestamp should be rejected")
+
+	results, err := agg.RollupHour(ctx, hour)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	rc, err := provider.Download(ctx, AggregatedPath(WindowHour, hour, "tidb"))
+	require.NoError(t, err)
+	defer rc.Close()
+	gz, err := gzip.NewReader(rc)
+	require.NoError(t, err)
+	var stored AggregatedData
+	require.NoError(t, json.NewDecoder(gz).Decode(&stored))
+	assert.Equal(t, common.CurrentFormatVersion, stored.FormatVersion)
+	assert.Equal(t, WindowHour, stored.Window)
+	assert.Equal(t, hour, stored.Timestamp)
+	assert.Equal(t, 1, stored.SourceFiles)
+	value, ok := common.ParseMeteringValue(stored.Data[0]["ru"])
+	require.True(t, ok)
+	assert.Equal(t, uint64(3), value.Value)
+
+	// Rolling up the same hour again is rejected unless overwrite is enabled, by the conditional upload
+	// rather than an existence check
+	_, err = agg.RollupHour(ctx, hour)
+	assert.ErrorIs(t, err, writer.ErrFileExists)
+	assert.Zero(t, agg.calls.Stats().Heads)
+	assert.Equal(t, int64(2), agg.calls.Stats().Puts)
+	_, err = NewAggregator(provider, config.DefaultConfig().WithOverwriteExisting(true)).RollupHour(ctx, hour)
+	assert.NoError(t, err)
+}
+
+func TestAggregator_FloatValues(t *testing.T) {
+	provider := storage.NewMemoryProvider()
+	const hour = int64(1755849600)
+
+	writeTestData(t, provider,
+		&common.MeteringData{
+			Timestamp: hour,
+			Category:  "tidb",
+			SelfID:    "server1",
+			Data: []map[string]interface{}{
+				{"logical_cluster_id": "lc1", "cpu": common.NewFloatMeteringValue(0.25, 2, "vcpu_hours")},
+				{"logical_cluster_id": "lc1", "cpu": &common.MeteringValue{Value: 1, Unit: "vcpu_hours"}},
+			},
+		},
+	)
+
+	agg := NewAggregator(provider, config.DefaultConfig())
+	result, err := agg.Aggregate(context.Background(), meteringreader.TimeRange{Start: hour, End: hour})
+	require.NoError(t, err)
+	value, ok := common.ParseMeteringValue(result["tidb"].Data[0]["cpu"])
+	require.True(t, ok)
+	assert.True(t, value.IsFloat())
+	assert.Equal(t, 1.25, value.Float64())
+}
+
+func TestSummarizeByLogicalCluster(t *testing.T) {
+	provider := storage.NewMemoryProvider()
+	const minute = int64(1755849600)
+
+	writeTestData(t, provider,
+		&common.MeteringData{
+			Timestamp: minute,
+			Category:  "tidb",
+			SelfID:    "server1",
+			Data: []map[string]interface{}{
+				{"logical_cluster_id": "lc2", "ru": &common.MeteringValue{Value: 5, Unit: "RU"}},
+				{"logical_cluster_id": "lc1", "ru": &common.MeteringValue{Value: 10, Unit: "RU"}, "memory": &common.MeteringValue{Value: 1, Unit: "MiB"}},
+			},
+		},
+		&common.MeteringData{
+			Timestamp: minute + 60,
+			Category:  "tikv",
+			SelfID:    "store1",
+			Data: []map[string]interface{}{
+				{"logical_cluster_id": "lc1", "ru": &common.MeteringValue{Value: 7, Unit: "RU"}, "memory": &common.MeteringValue{Value: 1, Unit: "KB"}},
+				{"logical_cluster_id": "lc1", "cpu": &common.MeteringValue{Value: 2, Unit: "vcpu_hours"}},
+			},
+		},
+	)
+
+	reader := meteringreader.NewMeteringReader(provider, config.DefaultConfig())
+	tr := meteringreader.TimeRange{Start: minute, End: minute + 60}
+	summary, err := SummarizeByLogicalCluster(context.Background(), reader, tr)
+	require.NoError(t, err)
+	assert.Equal(t, 4, summary.Records)
+	require.Len(t, summary.Clusters, 2)
+	lc1 := summary.Clusters[0]
+	assert.Equal(t, "lc1", lc1.LogicalClusterID)
+	assert.Equal(t, &common.MeteringValue{Value: 17, Unit: "RU"}, lc1.Metrics["ru"], "summed across categories")
+	assert.Equal(t, &common.MeteringValue{Value: 1<<20 + 1000, Unit: "bytes"}, lc1.Metrics["memory"], "normalized to bytes")
+	assert.Equal(t, &common.MeteringValue{Value: 2, Unit: "vcpu_hours"}, lc1.Metrics["cpu"], "unknown units are kept")
+	assert.Equal(t, "lc2", summary.Clusters[1].LogicalClusterID)
+
+	registry := units.NewRegistry()
+	require.NoError(t, registry.Register(units.Unit{Name: "vcpu_hours", Dimension: "cpu_time", Factor: 3600}))
+	require.NoError(t, registry.Register(units.Unit{Name: "vcpu_seconds", Dimension: "cpu_time", Factor: 1}))
+	summary, err = SummarizeByLogicalCluster(context.Background(), reader, tr, WithSummaryCategory("tikv"), WithSummaryUnits(registry))
+	require.NoError(t, err)
+	assert.Equal(t, 2, summary.Records)
+	require.Len(t, summary.Clusters, 1)
+	assert.Equal(t, &common.MeteringValue{Value: 7200, Unit: "vcpu_seconds"}, summary.Clusters[0].Metrics["cpu"])
+	assert.Equal(t, &common.MeteringValue{Value: 7, Unit: "RU"}, summary.Clusters[0].Metrics["ru"])
+}
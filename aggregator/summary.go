@@ -0,0 +1,108 @@
+package aggregator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/pingcap/metering_sdk/common"
+	meteringreader "github.com/pingcap/metering_sdk/reader/metering"
+	"github.com/pingcap/metering_sdk/units"
+)
+
+// ClusterUsage total usage of one logical cluster
+type ClusterUsage struct {
+	LogicalClusterID string                           `json:"logical_cluster_id"` // logical cluster ID
+	Metrics          map[string]*common.MeteringValue `json:"metrics"`            // total per metric, in canonical units
+}
+
+// Summary total usage per logical cluster over a time range
+type Summary struct {
+	TimeRange meteringreader.TimeRange `json:"time_range"`         // summarized time range
+	Category  string                   `json:"category,omitempty"` // only this category was summarized when set
+	Records   int                      `json:"records"`            // number of records summarized
+	Clusters  []*ClusterUsage          `json:"clusters"`           // usage per logical cluster, sorted by ID
+}
+
+// summaryOptions options of SummarizeByLogicalCluster
+type summaryOptions struct {
+	category string
+	units    *units.Registry
+}
+
+// SummaryOption configures SummarizeByLogicalCluster
+type SummaryOption func(*summaryOptions)
+
+// WithSummaryCategory only summarizes the records of category
+func WithSummaryCategory(category string) SummaryOption {
+	return func(o *summaryOptions) { o.category = category }
+}
+
+// WithSummaryUnits normalizes units with registry instead of units.Default()
+func WithSummaryUnits(registry *units.Registry) SummaryOption {
+	return func(o *summaryOptions) { o.units = registry }
+}
+
+// SummarizeByLogicalCluster sums the metering values of every record in the time range per logical cluster
+// and metric, across categories. Values are normalized to the canonical unit of their dimension, e.g. "MiB"
+// and "GB" are summed in bytes; values in unknown units are summed as is, and summing them with values of
+// another unit is an error. Fields that are not metering values are dropped.
+func SummarizeByLogicalCluster(ctx context.Context, reader *meteringreader.MeteringReader, tr meteringreader.TimeRange, opts ...SummaryOption) (*Summary, error) {
+	options := &summaryOptions{units: units.Default()}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	summary := &Summary{TimeRange: tr, Category: options.category}
+	// logical cluster -> metric -> total
+	totals := make(map[string]map[string]*common.MeteringValue)
+	for rec, err := range reader.Records(ctx, meteringreader.RecordQuery{TimeRange: tr, Category: options.category}) {
+		if err != nil {
+			return nil, err
+		}
+		summary.Records++
+
+		logicalClusterID, _ := rec.Data[LogicalClusterIDField].(string)
+		metrics := totals[logicalClusterID]
+		if metrics == nil {
+			metrics = make(map[string]*common.MeteringValue)
+			totals[logicalClusterID] = metrics
+		}
+		for field, raw := range rec.Data {
+			if field == LogicalClusterIDField {
+				continue
+			}
+			value, ok := common.ParseMeteringValue(raw)
+			if !ok {
+				continue
+			}
+			if _, known := options.units.Lookup(value.Unit); known {
+				normalized, err := options.units.Normalize(value)
+				if err != nil {
+					return nil, fmt.Errorf("failed to normalize %s of logical cluster %s in %s: %w", field, logicalClusterID, rec.File.Path, err)
+				}
+				value = normalized
+			}
+
+			current, exists := metrics[field]
+			if !exists {
+				metrics[field] = value.Clone()
+				continue
+			}
+			sum, err := current.Add(value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to sum %s of logical cluster %s: %w", field, logicalClusterID, err)
+			}
+			metrics[field] = sum
+		}
+	}
+
+	summary.Clusters = make([]*ClusterUsage, 0, len(totals))
+	for id, metrics := range totals {
+		summary.Clusters = append(summary.Clusters, &ClusterUsage{LogicalClusterID: id, Metrics: metrics})
+	}
+	sort.Slice(summary.Clusters, func(i, j int) bool {
+		return summary.Clusters[i].LogicalClusterID < summary.Clusters[j].LogicalClusterID
+	})
+	return summary, nil
+}
@@ -0,0 +1,128 @@
+// Package codec serializes metering files. JSON is the default; other codecs, e.g. protobuf or msgpack,
+// are registered by suffix, so writers name files after their codec and readers detect it from the path.
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+)
+
+// compressedSuffix suffix of compressed metering files, following the codec suffix
+const compressedSuffix = ".gz"
+
+// Codec serializes metering files. Codecs other than JSON are given *common.MeteringData pages to
+// Marshal, and a *common.MeteringData to Unmarshal into; the part number is only encoded in the path.
+type Codec interface {
+	// Name identifies the codec, e.g. "json"
+	Name() string
+	// ContentType MIME type of the serialized data, e.g. "application/json"
+	ContentType() string
+	// Suffix file name suffix of the serialized data before ".gz", e.g. ".json"
+	Suffix() string
+	// Marshal serializes v
+	Marshal(v any) ([]byte, error)
+	// Unmarshal deserializes data into v
+	Unmarshal(data []byte, v any) error
+}
+
+// Versioned is implemented by codecs that record the format version, see common.CurrentFormatVersion, in
+// the files they write. Readers check it like the format_version of JSON files.
+type Versioned interface {
+	// UnmarshalVersion deserializes data into v like Unmarshal, returning the format version recorded in
+	// data, 0 if it predates format versions
+	UnmarshalVersion(data []byte, v any) (int, error)
+}
+
+// jsonCodec the default codec
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string                       { return "json" }
+func (jsonCodec) ContentType() string                { return "application/json" }
+func (jsonCodec) Suffix() string                     { return ".json" }
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// JSON the default codec, metering files named *.json.gz
+var JSON Codec = jsonCodec{}
+
+var (
+	mu       sync.RWMutex
+	byName   = map[string]Codec{JSON.Name(): JSON}
+	bySuffix = map[string]Codec{JSON.Suffix(): JSON}
+)
+
+// Register registers a codec, so readers detect files with its suffix. Names and suffixes must be unique
+func Register(c Codec) error {
+	name, suffix := c.Name(), c.Suffix()
+	if name == "" {
+		return fmt.Errorf("codec name is required")
+	}
+	if !strings.HasPrefix(suffix, ".") || strings.Contains(suffix, "/") || suffix == compressedSuffix {
+		return fmt.Errorf("invalid suffix %q of codec %s, expected e.g. \".pb\"", suffix, name)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := byName[name]; ok {
+		return fmt.Errorf("codec %s is already registered", name)
+	}
+	if existing, ok := bySuffix[suffix]; ok {
+		return fmt.Errorf("suffix %s is already used by codec %s", suffix, existing.Name())
+	}
+	byName[name] = c
+	bySuffix[suffix] = c
+	return nil
+}
+
+// Lookup returns the registered codec called name
+func Lookup(name string) (Codec, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	c, ok := byName[name]
+	return c, ok
+}
+
+// ForPath returns the codec of the metering file at p from its suffix, JSON if it has no registered suffix
+func ForPath(p string) Codec {
+	base := strings.TrimSuffix(path.Base(p), compressedSuffix)
+	mu.RLock()
+	defer mu.RUnlock()
+	// Suffixes may contain dots themselves, try the longest first
+	for i := 0; i < len(base); i++ {
+		if base[i] != '.' {
+			continue
+		}
+		if c, ok := bySuffix[base[i:]]; ok {
+			return c
+		}
+	}
+	return JSON
+}
+
+// IsJSON reports whether c is nil or the JSON codec
+func IsJSON(c Codec) bool {
+	return c == nil || c.Name() == JSON.Name()
+}
+
+// Path returns p, a path ending with ".json.gz" as rendered by layouts, with the suffix of c instead
+func Path(p string, c Codec) string {
+	jsonSuffix := JSON.Suffix() + compressedSuffix
+	if IsJSON(c) || !strings.HasSuffix(p, jsonSuffix) {
+		return p
+	}
+	return strings.TrimSuffix(p, jsonSuffix) + c.Suffix() + compressedSuffix
+}
+
+// JSONPath returns p with the suffix of its codec replaced by ".json.gz", the inverse of Path, so it can
+// be parsed by layouts
+func JSONPath(p string) string {
+	c := ForPath(p)
+	suffix := c.Suffix() + compressedSuffix
+	if IsJSON(c) || !strings.HasSuffix(p, suffix) {
+		return p
+	}
+	return strings.TrimSuffix(p, suffix) + JSON.Suffix() + compressedSuffix
+}
@@ -0,0 +1,68 @@
+package codec
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reversedJSON JSON with its bytes reversed, a codec JSON decoders can't read
+type reversedJSON struct{}
+
+func (reversedJSON) Name() string        { return "reversed-json" }
+func (reversedJSON) ContentType() string { return "application/x-reversed-json" }
+func (reversedJSON) Suffix() string      { return ".rjson" }
+func (reversedJSON) Marshal(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	slices.Reverse(data)
+	return data, err
+}
+func (reversedJSON) Unmarshal(data []byte, v any) error {
+	data = slices.Clone(data)
+	slices.Reverse(data)
+	return json.Unmarshal(data, v)
+}
+
+func TestRegister(t *testing.T) {
+	require.NoError(t, Register(reversedJSON{}))
+	assert.Error(t, Register(reversedJSON{}), "names are unique")
+
+	c, ok := Lookup("reversed-json")
+	assert.True(t, ok)
+	assert.Equal(t, reversedJSON{}, c)
+	_, ok = Lookup("msgpack")
+	assert.False(t, ok)
+
+	assert.Equal(t, reversedJSON{}, ForPath("metering/ru/1755850380/tidb/pool1/tidb001-0.rjson.gz"))
+	assert.Equal(t, JSON, ForPath("metering/ru/1755850380/tidb/pool1/tidb001-0.json.gz"))
+	assert.Equal(t, JSON, ForPath("metering/ru/1755850380/tidb/pool1/tidb001-0.csv.gz"))
+
+	jsonPath := "metering/ru/1755850380/tidb/pool1/tidb001-0.json.gz"
+	assert.Equal(t, jsonPath, Path(jsonPath, JSON))
+	assert.Equal(t, "metering/ru/1755850380/tidb/pool1/tidb001-0.rjson.gz", Path(jsonPath, reversedJSON{}))
+	assert.Equal(t, jsonPath, JSONPath(Path(jsonPath, reversedJSON{})))
+	assert.Equal(t, jsonPath, JSONPath(jsonPath))
+}
+
+func TestRegister_Invalid(t *testing.T) {
+	for name, c := range map[string]Codec{
+		"duplicate suffix": &testCodec{name: "other-json", suffix: ".json"},
+		"empty name":       &testCodec{suffix: ".pb"},
+		"no dot":           &testCodec{name: "pb", suffix: "pb"},
+		"gzip suffix":      &testCodec{name: "pb", suffix: ".gz"},
+	} {
+		assert.Error(t, Register(c), name)
+	}
+}
+
+// testCodec a codec with configurable name and suffix
+type testCodec struct {
+	reversedJSON
+	name, suffix string
+}
+
+func (c *testCodec) Name() string   { return c.name }
+func (c *testCodec) Suffix() string { return c.suffix }
@@ -0,0 +1,199 @@
+// Package pbcodec provides the protobuf codec of metering files, see proto/meteringpb/metering.proto.
+// Importing it registers the codec, metering files are named *.pb.gz.
+package pbcodec
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pingcap/metering_sdk/codec"
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/pingcap/metering_sdk/proto/meteringpb"
+	"google.golang.org/protobuf/proto"
+)
+
+// protobufCodec encodes metering files as meteringpb.PageMeteringData
+type protobufCodec struct{}
+
+// Codec the protobuf codec
+var Codec codec.Codec = protobufCodec{}
+
+func init() {
+	if err := codec.Register(Codec); err != nil {
+		panic(err)
+	}
+}
+
+func (protobufCodec) Name() string        { return "protobuf" }
+func (protobufCodec) ContentType() string { return "application/x-protobuf" }
+func (protobufCodec) Suffix() string      { return ".pb" }
+
+// Marshal serializes a *common.MeteringData or a proto.Message
+func (protobufCodec) Marshal(v any) ([]byte, error) {
+	switch val := v.(type) {
+	case *common.MeteringData:
+		msg, err := FromMeteringData(val)
+		if err != nil {
+			return nil, err
+		}
+		return proto.Marshal(msg)
+	case proto.Message:
+		return proto.Marshal(val)
+	default:
+		return nil, fmt.Errorf("protobuf codec cannot marshal %T", v)
+	}
+}
+
+// Unmarshal deserializes data into a *common.MeteringData or a proto.Message
+func (c protobufCodec) Unmarshal(data []byte, v any) error {
+	_, err := c.UnmarshalVersion(data, v)
+	return err
+}
+
+// UnmarshalVersion implements codec.Versioned, the format version is only returned for
+// *common.MeteringData
+func (protobufCodec) UnmarshalVersion(data []byte, v any) (int, error) {
+	switch val := v.(type) {
+	case *common.MeteringData:
+		msg := &meteringpb.PageMeteringData{}
+		if err := proto.Unmarshal(data, msg); err != nil {
+			return 0, err
+		}
+		decoded, err := ToMeteringData(msg)
+		if err != nil {
+			return 0, err
+		}
+		*val = *decoded
+		return int(msg.GetFormatVersion()), nil
+	case proto.Message:
+		return 0, proto.Unmarshal(data, val)
+	default:
+		return 0, fmt.Errorf("protobuf codec cannot unmarshal into %T", v)
+	}
+}
+
+// FromMeteringData converts metering data into its protobuf message, in the current format version
+func FromMeteringData(data *common.MeteringData) (*meteringpb.MeteringData, error) {
+	msg := &meteringpb.MeteringData{
+		Timestamp:     data.Timestamp,
+		Category:      data.Category,
+		SelfId:        data.SelfID,
+		SharedPoolId:  data.SharedPoolID,
+		Data:          make([]*meteringpb.Entry, 0, len(data.Data)),
+		FormatVersion: common.CurrentFormatVersion,
+	}
+	for i, entry := range data.Data {
+		fields := make(map[string]*meteringpb.Value, len(entry))
+		for key, raw := range entry {
+			value, err := fromValue(raw)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode field %s of entry %d: %w", key, i, err)
+			}
+			fields[key] = value
+		}
+		msg.Data = append(msg.Data, &meteringpb.Entry{Fields: fields})
+	}
+	return msg, nil
+}
+
+// ToMeteringData converts a protobuf page into metering data. Fields are decoded like JSON fields:
+// numbers as float64 and other values as JSON decodes them, except metering values, which are
+// decoded as *common.MeteringValue
+func ToMeteringData(msg *meteringpb.PageMeteringData) (*common.MeteringData, error) {
+	data := &common.MeteringData{
+		Timestamp:    msg.GetTimestamp(),
+		Category:     msg.GetCategory(),
+		SelfID:       msg.GetSelfId(),
+		SharedPoolID: msg.GetSharedPoolId(),
+		Data:         make([]map[string]interface{}, 0, len(msg.GetData())),
+	}
+	for i, entry := range msg.GetData() {
+		fields := make(map[string]interface{}, len(entry.GetFields()))
+		for key, value := range entry.GetFields() {
+			raw, err := toValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode field %s of entry %d: %w", key, i, err)
+			}
+			fields[key] = raw
+		}
+		data.Data = append(data.Data, fields)
+	}
+	return data, nil
+}
+
+// fromValue converts a field of a MeteringData.Data entry
+func fromValue(raw interface{}) (*meteringpb.Value, error) {
+	switch val := raw.(type) {
+	case *common.MeteringValue:
+		if val == nil {
+			return &meteringpb.Value{Kind: &meteringpb.Value_JsonValue{JsonValue: []byte("null")}}, nil
+		}
+		return meteringValue(val), nil
+	case common.MeteringValue:
+		return meteringValue(&val), nil
+	case string:
+		return &meteringpb.Value{Kind: &meteringpb.Value_StringValue{StringValue: val}}, nil
+	case bool:
+		return &meteringpb.Value{Kind: &meteringpb.Value_BoolValue{BoolValue: val}}, nil
+	case float64:
+		return number(val), nil
+	case float32:
+		return number(float64(val)), nil
+	case int:
+		return number(float64(val)), nil
+	case int32:
+		return number(float64(val)), nil
+	case int64:
+		return number(float64(val)), nil
+	case uint:
+		return number(float64(val)), nil
+	case uint32:
+		return number(float64(val)), nil
+	case uint64:
+		return number(float64(val)), nil
+	default:
+		encoded, err := json.Marshal(val)
+		if err != nil {
+			return nil, err
+		}
+		return &meteringpb.Value{Kind: &meteringpb.Value_JsonValue{JsonValue: encoded}}, nil
+	}
+}
+
+func meteringValue(val *common.MeteringValue) *meteringpb.Value {
+	return &meteringpb.Value{Kind: &meteringpb.Value_MeteringValue{MeteringValue: &meteringpb.MeteringValue{
+		Value:      val.Value,
+		ValueFloat: val.ValueFloat,
+		Unit:       val.Unit,
+	}}}
+}
+
+func number(val float64) *meteringpb.Value {
+	return &meteringpb.Value{Kind: &meteringpb.Value_NumberValue{NumberValue: val}}
+}
+
+// toValue converts a protobuf value into a field of a MeteringData.Data entry
+func toValue(value *meteringpb.Value) (interface{}, error) {
+	switch kind := value.GetKind().(type) {
+	case *meteringpb.Value_MeteringValue:
+		return &common.MeteringValue{
+			Value:      kind.MeteringValue.GetValue(),
+			ValueFloat: kind.MeteringValue.ValueFloat,
+			Unit:       kind.MeteringValue.GetUnit(),
+		}, nil
+	case *meteringpb.Value_StringValue:
+		return kind.StringValue, nil
+	case *meteringpb.Value_NumberValue:
+		return kind.NumberValue, nil
+	case *meteringpb.Value_BoolValue:
+		return kind.BoolValue, nil
+	case *meteringpb.Value_JsonValue:
+		var raw interface{}
+		if err := json.Unmarshal(kind.JsonValue, &raw); err != nil {
+			return nil, err
+		}
+		return raw, nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,152 @@
+package pbcodec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/pingcap/metering_sdk/codec"
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/pingcap/metering_sdk/config"
+	"github.com/pingcap/metering_sdk/internal/compress"
+	"github.com/pingcap/metering_sdk/proto/meteringpb"
+	sdkreader "github.com/pingcap/metering_sdk/reader"
+	meteringreader "github.com/pingcap/metering_sdk/reader/metering"
+	"github.com/pingcap/metering_sdk/storage"
+	meteringwriter "github.com/pingcap/metering_sdk/writer/metering"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMeteringData(entries int) *common.MeteringData {
+	data := &common.MeteringData{
+		Timestamp:    1755850380,
+		Category:     "tidbserver",
+		SelfID:       "tidb001",
+		SharedPoolID: "pool1",
+	}
+	for i := 0; i < entries; i++ {
+		data.Data = append(data.Data, map[string]interface{}{
+			common.LogicalClusterIDField: fmt.Sprintf("lc-%06d", i),
+			"cpu":                        &common.MeteringValue{Value: uint64(i), Unit: "vCPU"},
+			"ru":                         common.NewFloatMeteringValue(float64(i)+0.25, 2, "RU"),
+		})
+	}
+	return data
+}
+
+func TestCodec(t *testing.T) {
+	c, ok := codec.Lookup("protobuf")
+	require.True(t, ok, "importing the package registers the codec")
+	assert.Equal(t, Codec, c)
+	assert.Equal(t, Codec, codec.ForPath("metering/ru/1755850380/tidbserver/pool1/tidb001-0.pb.gz"))
+
+	data := testMeteringData(1)
+	data.Data[0]["region"] = "us-west-2"
+	data.Data[0]["count"] = 3
+	data.Data[0]["enabled"] = true
+	data.Data[0]["labels"] = map[string]string{"env": "prod"}
+	encoded, err := Codec.Marshal(data)
+	require.NoError(t, err)
+
+	decoded := &common.MeteringData{}
+	version, err := Codec.(codec.Versioned).UnmarshalVersion(encoded, decoded)
+	require.NoError(t, err)
+	assert.Equal(t, common.CurrentFormatVersion, version, "the format version is recorded")
+	assert.Equal(t, data.Timestamp, decoded.Timestamp)
+	assert.Equal(t, data.SharedPoolID, decoded.SharedPoolID)
+	require.Len(t, decoded.Data, 1)
+	entry := decoded.Data[0]
+	assert.Equal(t, "lc-000000", entry[common.LogicalClusterIDField])
+	assert.Equal(t, &common.MeteringValue{Value: 0, Unit: "vCPU"}, entry["cpu"])
+	ru, ok := common.ParseMeteringValue(entry["ru"])
+	require.True(t, ok)
+	assert.Equal(t, 0.25, ru.Float64())
+	// Other values decode like JSON
+	assert.Equal(t, "us-west-2", entry["region"])
+	assert.Equal(t, float64(3), entry["count"])
+	assert.Equal(t, true, entry["enabled"])
+	assert.Equal(t, map[string]interface{}{"env": "prod"}, entry["labels"])
+
+	// Pages carry the part number, and are read as metering data. Pages predating format versions have none
+	page, err := Codec.Marshal(&meteringpb.PageMeteringData{Timestamp: data.Timestamp, Part: 2})
+	require.NoError(t, err)
+	version, err = Codec.(codec.Versioned).UnmarshalVersion(page, decoded)
+	require.NoError(t, err)
+	assert.Zero(t, version)
+	assert.Equal(t, data.Timestamp, decoded.Timestamp)
+	assert.Empty(t, decoded.Data)
+
+	_, err = Codec.Marshal("metering")
+	assert.Error(t, err)
+	assert.Error(t, Codec.Unmarshal([]byte{0xff}, &common.MeteringData{}))
+}
+
+func TestCodec_Size(t *testing.T) {
+	data := testMeteringData(1000)
+	jsonData, err := json.Marshal(data)
+	require.NoError(t, err)
+	pbData, err := Codec.Marshal(data)
+	require.NoError(t, err)
+	assert.Less(t, len(pbData), len(jsonData)*3/4)
+}
+
+func TestCodec_WriteRead(t *testing.T) {
+	provider := storage.NewMemoryProvider()
+	writer := meteringwriter.NewMeteringWriter(provider, config.DefaultConfig().WithCodec(Codec))
+	defer writer.Close()
+	ctx := context.Background()
+	data := testMeteringData(10)
+	require.NoError(t, writer.Write(ctx, data))
+
+	reader := meteringreader.NewMeteringReader(provider, config.DefaultConfig())
+	files, err := reader.ListFilesByTimestamp(ctx, data.Timestamp)
+	require.NoError(t, err)
+	path := "metering/ru/1755850380/tidbserver/pool1/tidb001-0.pb.gz"
+	assert.Equal(t, map[string][]string{"tidbserver": {path}}, files.Files)
+
+	page, version, err := reader.ReadFileWithFormat(ctx, path)
+	require.NoError(t, err)
+	assert.Equal(t, meteringreader.FormatVersionCurrent, version)
+	assert.Equal(t, data.SelfID, page.SelfID)
+	require.Len(t, page.Data, len(data.Data))
+	for i, entry := range page.Data {
+		assert.Equal(t, data.Data[i][common.LogicalClusterIDField], entry[common.LogicalClusterIDField])
+		assert.Equal(t, data.Data[i]["cpu"], entry["cpu"])
+	}
+
+	// Pages of a newer format version are checked like JSON files
+	newer, err := Codec.Marshal(&meteringpb.PageMeteringData{Timestamp: data.Timestamp, FormatVersion: common.CurrentFormatVersion + 1})
+	require.NoError(t, err)
+	compressed, err := compress.Gzip(newer)
+	require.NoError(t, err)
+	newerPath := "metering/ru/1755850380/tidbserver/pool1/tidb002-0.pb.gz"
+	require.NoError(t, provider.Upload(ctx, newerPath, bytes.NewReader(compressed)))
+	_, err = meteringreader.NewMeteringReader(provider, config.DefaultConfig().WithStrictFormatVersion(true)).ReadFile(ctx, newerPath)
+	assert.ErrorIs(t, err, sdkreader.ErrUnsupportedFormat)
+	_, err = reader.ReadFile(ctx, newerPath)
+	assert.NoError(t, err, "read on a best-effort basis unless strict")
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	data := testMeteringData(1000)
+	jsonData, err := json.Marshal(data)
+	require.NoError(b, err)
+	pbData, err := Codec.Marshal(data)
+	require.NoError(b, err)
+
+	b.Run("json", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var decoded common.MeteringData
+			_ = codec.JSON.Unmarshal(jsonData, &decoded)
+		}
+	})
+	b.Run("protobuf", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var decoded common.MeteringData
+			_ = Codec.Unmarshal(pbData, &decoded)
+		}
+	})
+}
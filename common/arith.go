@@ -0,0 +1,80 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	// ErrUnitMismatch is returned by MeteringValue arithmetic on values of different units
+	ErrUnitMismatch = errors.New("unit mismatch")
+	// ErrNegativeValue is returned by MeteringValue.Sub when the result would be negative
+	ErrNegativeValue = errors.New("negative metering value")
+	// ErrValueOverflow is returned by MeteringValue.Add when an integral sum overflows uint64
+	ErrValueOverflow = errors.New("metering value overflows uint64")
+)
+
+// Clone returns a copy of v that can be modified without affecting v
+func (v *MeteringValue) Clone() *MeteringValue {
+	c := &MeteringValue{Value: v.Value, Unit: v.Unit}
+	if v.ValueFloat != nil {
+		f := *v.ValueFloat
+		c.ValueFloat = &f
+	}
+	return c
+}
+
+// Add returns the sum of v and other, failing with ErrUnitMismatch if their units differ. The sum is
+// fractional if either value is. Neither value is modified.
+func (v *MeteringValue) Add(other *MeteringValue) (*MeteringValue, error) {
+	if v.Unit != other.Unit {
+		return nil, fmt.Errorf("%w: %s vs %s", ErrUnitMismatch, v.Unit, other.Unit)
+	}
+	if v.IsFloat() || other.IsFloat() {
+		return NewFloatMeteringValue(v.Float64()+other.Float64(), -1, v.Unit), nil
+	}
+	if v.Value > math.MaxUint64-other.Value {
+		return nil, fmt.Errorf("%w: %d + %d", ErrValueOverflow, v.Value, other.Value)
+	}
+	return &MeteringValue{Value: v.Value + other.Value, Unit: v.Unit}, nil
+}
+
+// Sub returns v minus other, failing with ErrUnitMismatch if their units differ and with
+// ErrNegativeValue if other is larger, metering values are non-negative. The difference is fractional
+// if either value is. Neither value is modified.
+func (v *MeteringValue) Sub(other *MeteringValue) (*MeteringValue, error) {
+	if v.Unit != other.Unit {
+		return nil, fmt.Errorf("%w: %s vs %s", ErrUnitMismatch, v.Unit, other.Unit)
+	}
+	if v.IsFloat() || other.IsFloat() {
+		diff := v.Float64() - other.Float64()
+		if diff < 0 {
+			return nil, fmt.Errorf("%w: %v - %v", ErrNegativeValue, v.Float64(), other.Float64())
+		}
+		return NewFloatMeteringValue(diff, -1, v.Unit), nil
+	}
+	if other.Value > v.Value {
+		return nil, fmt.Errorf("%w: %d - %d", ErrNegativeValue, v.Value, other.Value)
+	}
+	return &MeteringValue{Value: v.Value - other.Value, Unit: v.Unit}, nil
+}
+
+// MergeValues adds the values of src to the values of the same field in dst, copying the values of
+// fields dst doesn't have. The values of src are never shared with dst. It stops at the first field
+// that can't be added, e.g. because of a unit mismatch, leaving the fields merged so far in dst.
+func MergeValues(dst, src map[string]*MeteringValue) error {
+	for field, value := range src {
+		current, exists := dst[field]
+		if !exists {
+			dst[field] = value.Clone()
+			continue
+		}
+		sum, err := current.Add(value)
+		if err != nil {
+			return fmt.Errorf("failed to merge %s: %w", field, err)
+		}
+		dst[field] = sum
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package common
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeteringValueArithmetic(t *testing.T) {
+	a := &MeteringValue{Value: 5, Unit: "RU"}
+	b := &MeteringValue{Value: 3, Unit: "RU"}
+
+	sum, err := a.Add(b)
+	require.NoError(t, err)
+	assert.Equal(t, &MeteringValue{Value: 8, Unit: "RU"}, sum)
+	assert.Equal(t, uint64(5), a.Value, "operands are not modified")
+
+	diff, err := a.Sub(b)
+	require.NoError(t, err)
+	assert.Equal(t, &MeteringValue{Value: 2, Unit: "RU"}, diff)
+
+	_, err = b.Sub(a)
+	assert.ErrorIs(t, err, ErrNegativeValue)
+	_, err = a.Add(&MeteringValue{Value: 1, Unit: "KB"})
+	assert.ErrorIs(t, err, ErrUnitMismatch)
+	_, err = a.Sub(&MeteringValue{Value: 1, Unit: "KB"})
+	assert.ErrorIs(t, err, ErrUnitMismatch)
+	_, err = a.Add(&MeteringValue{Value: math.MaxUint64, Unit: "RU"})
+	assert.ErrorIs(t, err, ErrValueOverflow)
+
+	fractional, err := a.Add(NewFloatMeteringValue(0.25, 2, "RU"))
+	require.NoError(t, err)
+	assert.True(t, fractional.IsFloat())
+	assert.Equal(t, 5.25, fractional.Float64())
+	fractional, err = fractional.Sub(NewFloatMeteringValue(0.5, 1, "RU"))
+	require.NoError(t, err)
+	assert.Equal(t, 4.75, fractional.Float64())
+	_, err = fractional.Sub(a)
+	assert.ErrorIs(t, err, ErrNegativeValue)
+}
+
+func TestMergeValues(t *testing.T) {
+	src := map[string]*MeteringValue{
+		"ru":      {Value: 2, Unit: "RU"},
+		"storage": {Value: 10, Unit: "KB"},
+	}
+	dst := map[string]*MeteringValue{
+		"ru": {Value: 1, Unit: "RU"},
+	}
+	require.NoError(t, MergeValues(dst, src))
+	assert.Equal(t, uint64(3), dst["ru"].Value)
+	assert.Equal(t, uint64(10), dst["storage"].Value)
+	assert.NotSame(t, src["storage"], dst["storage"])
+
+	err := MergeValues(dst, map[string]*MeteringValue{"ru": {Value: 1, Unit: "KB"}})
+	assert.ErrorIs(t, err, ErrUnitMismatch)
+	assert.ErrorContains(t, err, "ru")
+}
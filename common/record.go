@@ -0,0 +1,110 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/pingcap/metering_sdk/internal/utils"
+)
+
+// RecordBuilder builds a MeteringData.Data entry, validating it as fields are added:
+//
+//	record, err := common.NewRecord().
+//		LogicalCluster("lc-1").
+//		Value("compute_seconds", 3600, "seconds").
+//		Float("cpu_usage", 75.5, 1, "percent").
+//		Build()
+//
+// The first invalid call is reported by Build, later calls are ignored.
+type RecordBuilder struct {
+	entry             map[string]interface{}
+	validateClusterID func(id string) error
+	err               error
+}
+
+// NewRecord starts building a MeteringData.Data entry, checking logical cluster IDs with the default rules
+func NewRecord() *RecordBuilder {
+	return NewRecordWithValidator(utils.ValidateClusterID)
+}
+
+// NewRecordWithValidator starts building a MeteringData.Data entry, checking logical cluster IDs with
+// validateClusterID, e.g. config.Config.ValidateClusterID
+func NewRecordWithValidator(validateClusterID func(id string) error) *RecordBuilder {
+	return &RecordBuilder{entry: make(map[string]interface{}), validateClusterID: validateClusterID}
+}
+
+// LogicalCluster sets the logical cluster the entry is attributed to, required
+func (b *RecordBuilder) LogicalCluster(id string) *RecordBuilder {
+	if b.err != nil {
+		return b
+	}
+	if err := b.validateClusterID(id); err != nil {
+		b.err = fmt.Errorf("invalid logical cluster ID %q: %w", id, err)
+		return b
+	}
+	b.entry[LogicalClusterIDField] = id
+	return b
+}
+
+// Value adds the integral metering value of field
+func (b *RecordBuilder) Value(field string, value uint64, unit string) *RecordBuilder {
+	return b.Set(field, &MeteringValue{Value: value, Unit: unit})
+}
+
+// Float adds the fractional metering value of field, rounded to precision decimal places, see
+// NewFloatMeteringValue
+func (b *RecordBuilder) Float(field string, value float64, precision int, unit string) *RecordBuilder {
+	return b.Set(field, NewFloatMeteringValue(value, precision, unit))
+}
+
+// Set adds the metering value of field, e.g. a sum computed with MeteringValue.Add
+func (b *RecordBuilder) Set(field string, value *MeteringValue) *RecordBuilder {
+	if b.err != nil {
+		return b
+	}
+	b.err = b.checkField(field, value)
+	if b.err == nil {
+		b.entry[field] = value
+	}
+	return b
+}
+
+// checkField validates a metering value added to the entry under field
+func (b *RecordBuilder) checkField(field string, value *MeteringValue) error {
+	switch {
+	case field == "":
+		return fmt.Errorf("field name cannot be empty")
+	case field == LogicalClusterIDField:
+		return fmt.Errorf("field %s is reserved, use LogicalCluster", field)
+	case value == nil:
+		return fmt.Errorf("value of field %s cannot be nil", field)
+	case value.Unit == "":
+		return fmt.Errorf("unit of field %s cannot be empty", field)
+	}
+	if _, exists := b.entry[field]; exists {
+		return fmt.Errorf("field %s set twice", field)
+	}
+	if err := value.Validate(); err != nil {
+		return fmt.Errorf("invalid value of field %s: %w", field, err)
+	}
+	return nil
+}
+
+// Build returns the entry, failing if a call was invalid or no logical cluster was set
+func (b *RecordBuilder) Build() (map[string]interface{}, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if _, ok := b.entry[LogicalClusterIDField]; !ok {
+		return nil, fmt.Errorf("logical cluster ID is required")
+	}
+	return b.entry, nil
+}
+
+// MustBuild is like Build but panics if the entry is invalid, for entries built from constants
+func (b *RecordBuilder) MustBuild() map[string]interface{} {
+	entry, err := b.Build()
+	if err != nil {
+		panic(fmt.Sprintf("common: invalid metering record: %v", err))
+	}
+	return entry
+}
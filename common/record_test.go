@@ -0,0 +1,43 @@
+package common
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordBuilder(t *testing.T) {
+	record, err := NewRecord().
+		LogicalCluster("lc-1").
+		Value("compute_seconds", 3600, "seconds").
+		Float("cpu_usage", 75.46, 1, "percent").
+		Set("ru", &MeteringValue{Value: 10, Unit: "RU"}).
+		Build()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		LogicalClusterIDField: "lc-1",
+		"compute_seconds":     &MeteringValue{Value: 3600, Unit: "seconds"},
+		"cpu_usage":           NewFloatMeteringValue(75.5, -1, "percent"),
+		"ru":                  &MeteringValue{Value: 10, Unit: "RU"},
+	}, record)
+
+	for name, builder := range map[string]*RecordBuilder{
+		"no logical cluster":      NewRecord().Value("ru", 1, "RU"),
+		"invalid logical cluster": NewRecord().LogicalCluster("lc/1"),
+		"empty field":             NewRecord().LogicalCluster("lc-1").Value("", 1, "RU"),
+		"reserved field":          NewRecord().LogicalCluster("lc-1").Value(LogicalClusterIDField, 1, "RU"),
+		"empty unit":              NewRecord().LogicalCluster("lc-1").Value("ru", 1, ""),
+		"duplicate field":         NewRecord().LogicalCluster("lc-1").Value("ru", 1, "RU").Value("ru", 2, "RU"),
+		"nil value":               NewRecord().LogicalCluster("lc-1").Set("ru", nil),
+		"invalid float":           NewRecord().LogicalCluster("lc-1").Float("ratio", math.NaN(), -1, "ratio"),
+		"first error wins":        NewRecord().Value("", 1, "RU").LogicalCluster("lc-1"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := builder.Build()
+			assert.Error(t, err)
+			assert.Panics(t, func() { builder.MustBuild() })
+		})
+	}
+}
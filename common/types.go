@@ -1,5 +1,7 @@
 package common
 
+import "time"
+
 // MetaType represents the type of metadata
 type MetaType string
 
@@ -16,12 +18,21 @@ var ValidMetaTypes = map[MetaType]bool{
 	MetaTypeSharedpool: true,
 }
 
-// MeteringValue represents a single metering value with its unit
+// MeteringValue represents a single metering value with its unit.
+// Integral values use Value. Fractional values set ValueFloat, see NewFloatMeteringValue.
 type MeteringValue struct {
-	Value uint64 `json:"value"` // the numeric value
-	Unit  string `json:"unit"`  // the unit of measurement
+	Value      uint64   `json:"value"`                 // the numeric value, rounded when ValueFloat is set
+	ValueFloat *float64 `json:"value_float,omitempty"` // the fractional value, takes precedence over Value when set
+	Unit       string   `json:"unit"`                  // the unit of measurement
 }
 
+// CurrentFormatVersion is the format_version of the metering data and metadata files written by this
+// SDK, bumped on incompatible format changes. Files without format_version predate it.
+const CurrentFormatVersion = 2
+
+// LogicalClusterIDField is the Data entry field identifying the logical cluster of a row
+const LogicalClusterIDField = "logical_cluster_id"
+
 // MeteringData metering data structure
 type MeteringData struct {
 	Timestamp    int64                    `json:"timestamp"`      // minute-level timestamp
@@ -38,4 +49,12 @@ type MetaData struct {
 	Category  string                 `json:"category,omitempty"` // service category (optional)
 	ModifyTS  int64                  `json:"modify_ts"`          // modification timestamp
 	Metadata  map[string]interface{} `json:"metadata"`           // metadata content
+	Deleted   bool                   `json:"deleted,omitempty"`  // tombstone, the cluster was deleted at ModifyTS
+}
+
+// FinalizeMarker lists the files published by a writer when it finalized a timestamp in staging mode
+type FinalizeMarker struct {
+	Timestamp   int64     `json:"timestamp"`    // minute-level timestamp
+	Files       []string  `json:"files"`        // paths of the published files
+	FinalizedAt time.Time `json:"finalized_at"` // time the files were published
 }
@@ -0,0 +1,152 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// NewFloatMeteringValue creates a fractional metering value rounded to precision decimal places.
+// A negative precision disables rounding. Value is set to the value rounded to an integer, so
+// readers that only understand integral values still see a close approximation.
+func NewFloatMeteringValue(value float64, precision int, unit string) *MeteringValue {
+	if precision >= 0 {
+		scale := math.Pow(10, float64(precision))
+		value = math.Round(value*scale) / scale
+	}
+	v := &MeteringValue{ValueFloat: &value, Unit: unit}
+	// 2^64 is the first float64 above the uint64 range, float64(math.MaxUint64) rounds up to it
+	if rounded := math.Round(value); rounded >= 0 && rounded < 0x1p64 {
+		v.Value = uint64(rounded)
+	}
+	return v
+}
+
+// IsFloat returns whether the value carries a fractional representation
+func (v *MeteringValue) IsFloat() bool {
+	return v.ValueFloat != nil
+}
+
+// Float64 returns the value as float64, preferring ValueFloat when set
+func (v *MeteringValue) Float64() float64 {
+	if v.ValueFloat != nil {
+		return *v.ValueFloat
+	}
+	return float64(v.Value)
+}
+
+// Validate checks that the value can be serialized and is non-negative
+func (v *MeteringValue) Validate() error {
+	if v.ValueFloat == nil {
+		return nil
+	}
+	f := *v.ValueFloat
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("metering value must be finite, got %v", f)
+	}
+	if f < 0 {
+		return fmt.Errorf("metering value must be non-negative, got %v", f)
+	}
+	return nil
+}
+
+// ParseMeteringValue converts a field of a MeteringData.Data entry into a MeteringValue.
+// It accepts the *MeteringValue / MeteringValue form used when writing, and the
+// map[string]interface{} form produced when JSON payloads are decoded by readers.
+func ParseMeteringValue(v interface{}) (*MeteringValue, bool) {
+	switch val := v.(type) {
+	case *MeteringValue:
+		if val == nil {
+			return nil, false
+		}
+		return val, true
+	case MeteringValue:
+		return &val, true
+	case map[string]interface{}:
+		rawValue, hasValue := val["value"]
+		rawUnit, hasUnit := val["unit"]
+		if !hasValue || !hasUnit {
+			return nil, false
+		}
+		unit, ok := rawUnit.(string)
+		if !ok {
+			return nil, false
+		}
+		if rawFloat, hasFloat := val["value_float"]; hasFloat && rawFloat != nil {
+			f, ok := toFloat64(rawFloat)
+			if !ok {
+				return nil, false
+			}
+			value, _ := toUint64(rawValue)
+			return &MeteringValue{Value: value, ValueFloat: &f, Unit: unit}, true
+		}
+		value, ok := toUint64(rawValue)
+		if !ok {
+			// Fractional "value" written by producers that don't use value_float
+			f, isFloat := toFloat64(rawValue)
+			if !isFloat || f < 0 {
+				return nil, false
+			}
+			return NewFloatMeteringValue(f, -1, unit), true
+		}
+		return &MeteringValue{Value: value, Unit: unit}, true
+	default:
+		return nil, false
+	}
+}
+
+// toUint64 converts a decoded JSON number into uint64
+func toUint64(v interface{}) (uint64, bool) {
+	switch n := v.(type) {
+	case float64:
+		if n < 0 || n >= 0x1p64 || n != math.Trunc(n) {
+			return 0, false
+		}
+		return uint64(n), true
+	case json.Number:
+		if u, err := strconv.ParseUint(n.String(), 10, 64); err == nil {
+			return u, true
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return 0, false
+		}
+		return toUint64(f)
+	case uint64:
+		return n, true
+	case int64:
+		if n < 0 {
+			return 0, false
+		}
+		return uint64(n), true
+	case int:
+		if n < 0 {
+			return 0, false
+		}
+		return uint64(n), true
+	default:
+		return 0, false
+	}
+}
+
+// toFloat64 converts a decoded JSON number into float64
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,82 @@
+package common
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFloatMeteringValue(t *testing.T) {
+	v := NewFloatMeteringValue(75.456, 2, "percent")
+	require.True(t, v.IsFloat())
+	assert.Equal(t, 75.46, v.Float64())
+	assert.Equal(t, uint64(75), v.Value)
+
+	unrounded := NewFloatMeteringValue(0.000123, -1, "ratio")
+	assert.Equal(t, 0.000123, unrounded.Float64())
+	assert.Equal(t, uint64(0), unrounded.Value)
+
+	// 2^64 doesn't fit, float64(math.MaxUint64) rounds up to it
+	overflow := NewFloatMeteringValue(float64(math.MaxUint64), -1, "bytes")
+	assert.Equal(t, uint64(0), overflow.Value)
+	assert.Equal(t, uint64(1<<63), NewFloatMeteringValue(0x1p63, -1, "bytes").Value)
+
+	integral := &MeteringValue{Value: 42, Unit: "count"}
+	assert.False(t, integral.IsFloat())
+	assert.Equal(t, float64(42), integral.Float64())
+}
+
+func TestMeteringValueValidate(t *testing.T) {
+	assert.NoError(t, (&MeteringValue{Value: 1, Unit: "count"}).Validate())
+	assert.NoError(t, NewFloatMeteringValue(1.5, 1, "ms").Validate())
+	assert.Error(t, NewFloatMeteringValue(math.NaN(), -1, "ms").Validate())
+	assert.Error(t, NewFloatMeteringValue(math.Inf(1), -1, "ms").Validate())
+	assert.Error(t, NewFloatMeteringValue(-1.5, -1, "ms").Validate())
+}
+
+func TestParseMeteringValue_JSONRoundTrip(t *testing.T) {
+	entry := map[string]interface{}{
+		"integral": &MeteringValue{Value: 100, Unit: "bytes"},
+		"float":    NewFloatMeteringValue(25.8, 1, "ms"),
+	}
+	raw, err := json.Marshal(entry)
+	require.NoError(t, err)
+
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(raw, &decoded))
+
+	integral, ok := ParseMeteringValue(decoded["integral"])
+	require.True(t, ok)
+	assert.False(t, integral.IsFloat())
+	assert.Equal(t, &MeteringValue{Value: 100, Unit: "bytes"}, integral)
+
+	float, ok := ParseMeteringValue(decoded["float"])
+	require.True(t, ok)
+	require.True(t, float.IsFloat())
+	assert.Equal(t, 25.8, float.Float64())
+	assert.Equal(t, uint64(26), float.Value)
+	assert.Equal(t, "ms", float.Unit)
+}
+
+func TestParseMeteringValue_FractionalValue(t *testing.T) {
+	v, ok := ParseMeteringValue(map[string]interface{}{"value": 0.25, "unit": "ratio"})
+	require.True(t, ok)
+	assert.True(t, v.IsFloat())
+	assert.Equal(t, 0.25, v.Float64())
+
+	_, ok = ParseMeteringValue(map[string]interface{}{"value": -0.25, "unit": "ratio"})
+	assert.False(t, ok)
+
+	_, ok = ParseMeteringValue(map[string]interface{}{"value": "1", "unit": "ratio"})
+	assert.False(t, ok)
+
+	// Too large for uint64, kept as a float instead of wrapping around
+	v, ok = ParseMeteringValue(map[string]interface{}{"value": 0x1p64, "unit": "bytes"})
+	require.True(t, ok)
+	assert.True(t, v.IsFloat())
+	assert.Equal(t, 0x1p64, v.Float64())
+	assert.Equal(t, uint64(0), v.Value)
+}
@@ -0,0 +1,246 @@
+// Package compaction merges the many small per-component minute files of an hour into one object
+// per category, which is much cheaper to list and read than millions of tiny objects.
+package compaction
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/pingcap/metering_sdk/config"
+	"github.com/pingcap/metering_sdk/logging"
+	"github.com/pingcap/metering_sdk/reader"
+	meteringreader "github.com/pingcap/metering_sdk/reader/metering"
+	"github.com/pingcap/metering_sdk/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultPrefix default prefix of compacted files
+	DefaultPrefix = "metering/compacted/"
+	// hourSeconds length of a compaction window in seconds
+	hourSeconds = 3600
+)
+
+// Config compaction configuration
+type Config struct {
+	// Prefix prefix of compacted files, default DefaultPrefix
+	Prefix string
+	// DeleteOriginals deletes the source files once they are stored in a compacted file
+	DeleteOriginals bool
+}
+
+// CompactedFile all metering files of one category in one hour
+type CompactedFile struct {
+	FormatVersion int                `json:"format_version"` // common.CurrentFormatVersion, 0 for files predating it
+	Hour          int64              `json:"hour"`           // hour start timestamp
+	Category      string             `json:"category"`       // service category identifier
+	Files         []*CompactedSource `json:"files"`          // source files, ordered by timestamp and path
+}
+
+// CompactedSource one source file of a compacted file
+type CompactedSource struct {
+	Path string               `json:"path"` // original file path
+	Data *common.MeteringData `json:"data"` // original file content
+}
+
+// Result summary of compacting one hour
+type Result struct {
+	Hour       int64 `json:"hour"`       // hour start timestamp
+	Categories int   `json:"categories"` // compacted files written or already present
+	Files      int   `json:"files"`      // source files stored in compacted files
+	Deleted    int   `json:"deleted"`    // source files deleted
+	Bytes      int64 `json:"bytes"`      // compressed bytes written
+}
+
+// Compactor merges minute-level metering files into hourly compacted files
+type Compactor struct {
+	provider      storage.ObjectStorageProvider
+	reader        *meteringreader.MeteringReader
+	config        *config.Config
+	compactConfig *Config
+	logger        *zap.Logger
+	calls         *storage.CallCounter // storage requests made by the compactor, except its reader
+}
+
+// NewCompactor creates a new compactor reading from and writing to the given provider
+func NewCompactor(provider storage.ObjectStorageProvider, cfg *config.Config, compactCfg *Config) *Compactor {
+	if cfg == nil {
+		cfg = config.DefaultConfig()
+	}
+	if compactCfg == nil {
+		compactCfg = &Config{}
+	}
+	if compactCfg.Prefix == "" {
+		compactCfg.Prefix = DefaultPrefix
+	}
+
+	calls := &storage.CallCounter{}
+	return &Compactor{
+		provider:      cfg.WrapProvider(provider, calls),
+		reader:        meteringreader.NewMeteringReader(provider, cfg),
+		config:        cfg,
+		compactConfig: compactCfg,
+		logger:        cfg.ProviderLogger(provider),
+		calls:         calls,
+	}
+}
+
+// CallStats returns the storage requests the compactor made, reading source files included, e.g. to
+// estimate the object store API costs of compaction
+func (c *Compactor) CallStats() storage.CallStats {
+	return c.calls.Stats().Add(c.reader.CallStats())
+}
+
+// CompactedPath returns the storage path of the compacted file of a category and hour
+func (c *Compactor) CompactedPath(hour int64, category string) string {
+	return fmt.Sprintf("%s%d/%s.json.gz", c.compactConfig.Prefix, hour, category)
+}
+
+// CompactHour writes one compacted file per category for the hour starting at hourTimestamp.
+// Only compact hours that writers have finished. A compacted file that already exists is never
+// rewritten, so that an interrupted run can be resumed safely: only source files it contains are
+// deleted, anything written later is left for inspection.
+func (c *Compactor) CompactHour(ctx context.Context, hourTimestamp int64) (*Result, error) {
+	if hourTimestamp <= 0 || hourTimestamp%hourSeconds != 0 {
+		return nil, fmt.Errorf("hour timestamp must be positive and divisible by %d", hourSeconds)
+	}
+
+	sources := make(map[string][]*meteringreader.MeteringFileInfo)
+	for info, err := range c.reader.Files(ctx, meteringreader.TimeRange{
+		Start: hourTimestamp,
+		End:   hourTimestamp + hourSeconds - 60,
+	}) {
+		if err != nil {
+			return nil, err
+		}
+		sources[info.Category] = append(sources[info.Category], info)
+	}
+
+	categories := make([]string, 0, len(sources))
+	for category := range sources {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	result := &Result{Hour: hourTimestamp}
+	for _, category := range categories {
+		compacted, size, err := c.compactCategory(ctx, hourTimestamp, category, sources[category])
+		if err != nil {
+			return result, err
+		}
+		result.Categories++
+		result.Files += len(compacted.Files)
+		result.Bytes += size
+
+		if c.compactConfig.DeleteOriginals {
+			deleted, err := c.deleteOriginals(ctx, compacted)
+			result.Deleted += deleted
+			if err != nil {
+				return result, err
+			}
+		}
+	}
+
+	c.logger.Info("Successfully compacted metering data",
+		zap.Int64("hour_timestamp", hourTimestamp),
+		zap.Int("categories_count", result.Categories),
+		zap.Int("files_count", result.Files),
+		zap.Int("deleted_count", result.Deleted),
+	)
+	return result, nil
+}
+
+// compactCategory writes the compacted file of a category, or returns the existing one,
+// together with the compressed bytes written
+func (c *Compactor) compactCategory(ctx context.Context, hour int64, category string, files []*meteringreader.MeteringFileInfo) (*CompactedFile, int64, error) {
+	path := c.CompactedPath(hour, category)
+	exists, err := c.provider.Exists(ctx, path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to check if file exists: %w", err)
+	}
+	if exists {
+		c.logger.Info("Compacted file already exists, not rewriting it",
+			logging.Path(path),
+		)
+		existing, err := c.ReadCompacted(ctx, hour, category)
+		return existing, 0, err
+	}
+
+	compacted := &CompactedFile{FormatVersion: common.CurrentFormatVersion, Hour: hour, Category: category}
+	for _, info := range files {
+		data, err := c.reader.ReadFile(ctx, info.Path)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read %s: %w", info.Path, err)
+		}
+		compacted.Files = append(compacted.Files, &CompactedSource{Path: info.Path, Data: data})
+	}
+
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	if err := json.NewEncoder(gzipWriter).Encode(compacted); err != nil {
+		return nil, 0, fmt.Errorf("failed to marshal compacted data: %w", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, 0, fmt.Errorf("failed to compress data: %w", err)
+	}
+	size := int64(buffer.Len())
+
+	if err := c.provider.Upload(c.config.UploadContext(ctx), path, &buffer); err != nil {
+		return nil, 0, fmt.Errorf("failed to upload compacted data: %w", err)
+	}
+
+	c.logger.Debug("Successfully wrote compacted data",
+		logging.Path(path),
+		zap.Int("files_count", len(compacted.Files)),
+		logging.Bytes(size),
+	)
+	return compacted, size, nil
+}
+
+// deleteOriginals deletes the source files of a compacted file that still exist
+func (c *Compactor) deleteOriginals(ctx context.Context, compacted *CompactedFile) (int, error) {
+	deleted := 0
+	for _, source := range compacted.Files {
+		exists, err := c.provider.Exists(ctx, source.Path)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to check if file exists: %w", err)
+		}
+		if !exists {
+			continue
+		}
+		if err := c.provider.Delete(ctx, source.Path); err != nil {
+			return deleted, fmt.Errorf("failed to delete %s: %w", source.Path, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// ReadCompacted reads the compacted file of a category and hour
+func (c *Compactor) ReadCompacted(ctx context.Context, hour int64, category string) (*CompactedFile, error) {
+	path := c.CompactedPath(hour, category)
+	body, info, err := reader.DownloadRaw(ctx, c.provider, path)
+	if err != nil {
+		return nil, err
+	}
+	decompressed, err := reader.Decompress(body, info)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	defer decompressed.Close()
+
+	var compacted CompactedFile
+	if err := json.NewDecoder(decompressed).Decode(&compacted); err != nil {
+		return nil, fmt.Errorf("%w: failed to unmarshal compacted data: %v", reader.ErrInvalidFormat, err)
+	}
+	if err := reader.CheckFormatVersion(c.logger, path, compacted.FormatVersion, c.config.StrictFormatVersion); err != nil {
+		return nil, err
+	}
+	return &compacted, nil
+}
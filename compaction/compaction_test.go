@@ -0,0 +1,71 @@
+package compaction
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/pingcap/metering_sdk/config"
+	"github.com/pingcap/metering_sdk/storage"
+	meteringwriter "github.com/pingcap/metering_sdk/writer/metering"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestData(t *testing.T, provider storage.ObjectStorageProvider, timestamp int64, category, selfID string) {
+	w := meteringwriter.NewMeteringWriterWithSharedPool(provider, config.DefaultConfig(), "pool1")
+	defer w.Close()
+	require.NoError(t, w.Write(context.Background(), &common.MeteringData{
+		Timestamp: timestamp,
+		Category:  category,
+		SelfID:    selfID,
+		Data: []map[string]interface{}{
+			{"logical_cluster_id": "lc1", "ru": &common.MeteringValue{Value: 1, Unit: "RU"}},
+		},
+	}))
+}
+
+func TestCompactor_CompactHour(t *testing.T) {
+	const hour = int64(1755849600)
+	provider := storage.NewMemoryProvider()
+	writeTestData(t, provider, hour, "tidb", "server1")
+	writeTestData(t, provider, hour+60, "tidb", "server1")
+	writeTestData(t, provider, hour+60, "tidb", "server2")
+	writeTestData(t, provider, hour+120, "tikv", "store1")
+	writeTestData(t, provider, hour+3600, "tidb", "server1") // next hour
+	ctx := context.Background()
+
+	compactor := NewCompactor(provider, config.DefaultConfig(), &Config{DeleteOriginals: true})
+	result, err := compactor.CompactHour(ctx, hour)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Categories)
+	assert.Equal(t, 4, result.Files)
+	assert.Equal(t, 4, result.Deleted)
+	assert.Positive(t, result.Bytes)
+
+	compacted, err := compactor.ReadCompacted(ctx, hour, "tidb")
+	require.NoError(t, err)
+	assert.Equal(t, common.CurrentFormatVersion, compacted.FormatVersion)
+	assert.Equal(t, hour, compacted.Hour)
+	require.Len(t, compacted.Files, 3)
+	assert.Equal(t, "metering/ru/1755849600/tidb/pool1/server1-0.json.gz", compacted.Files[0].Path)
+	assert.Equal(t, "server2", compacted.Files[2].Data.SelfID)
+	assert.Equal(t, "lc1", compacted.Files[2].Data.Data[0]["logical_cluster_id"])
+
+	originals, err := provider.List(ctx, "metering/ru/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"metering/ru/1755853200/tidb/pool1/server1-0.json.gz"}, originals)
+
+	// A late file is neither merged into the existing compacted file nor deleted
+	writeTestData(t, provider, hour+180, "tidb", "server3")
+	result, err = compactor.CompactHour(ctx, hour)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Files)
+	assert.Zero(t, result.Deleted)
+	exists, err := provider.Exists(ctx, "metering/ru/1755849780/tidb/pool1/server3-0.json.gz")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	_, err = compactor.CompactHour(ctx, hour+60)
+	assert.Error(t, err, "hour timestamp must be aligned")
+}
@@ -1,14 +1,60 @@
 package config
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
-
+	"time"
+
+	"github.com/pingcap/metering_sdk/codec"
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/pingcap/metering_sdk/internal/utils"
+	"github.com/pingcap/metering_sdk/layout"
+	"github.com/pingcap/metering_sdk/logging"
+	"github.com/pingcap/metering_sdk/metrics"
+	"github.com/pingcap/metering_sdk/schema"
 	"github.com/pingcap/metering_sdk/storage"
+	"github.com/pingcap/metering_sdk/tracing"
+	"github.com/pingcap/metering_sdk/units"
+	"github.com/pingcap/metering_sdk/writer"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
+// DefaultContentType Content-Type of uploaded files unless configured otherwise
+const DefaultContentType = "application/gzip"
+
+// IDValidator validates a component or cluster ID, returning why it is rejected
+type IDValidator func(id string) error
+
+// PatternIDValidator returns an IDValidator accepting the IDs pattern matches entirely
+func PatternIDValidator(pattern *regexp.Regexp) IDValidator {
+	// Anchored, since a leftmost-first match of an alternation may not be the one spanning the ID
+	anchored := regexp.MustCompile(`^(?:` + pattern.String() + `)$`)
+	return func(id string) error {
+		if !anchored.MatchString(id) {
+			return fmt.Errorf("ID %q does not match pattern %s", id, pattern)
+		}
+		return nil
+	}
+}
+
+// ClockSkew bounds how far metering timestamps may be from the wall clock, to catch clients with broken
+// clocks before their data lands in partitions nobody reads. Zero disables a bound.
+type ClockSkew struct {
+	// MaxFuture how far past the current minute timestamps may be
+	MaxFuture time.Duration
+	// MaxPast how far before the current minute timestamps may be
+	MaxPast time.Duration
+	// WarnOnly logs timestamps out of bounds instead of rejecting them
+	WarnOnly bool
+}
+
 // Config contains SDK common configuration
 type Config struct {
 	// Logger log instance, if nil will use default nop logger
@@ -19,9 +65,80 @@ type Config struct {
 	// When false, returns error if file already exists
 	// When true, directly overwrites existing file
 	OverwriteExisting bool
+	// DisableConditionalPut whether to enforce OverwriteExisting=false with an Exists check before every
+	// upload, default false. By default providers supporting it reject existing files in the upload itself
+	// (S3 If-None-Match, OSS x-oss-forbid-overwrite), so two writers can't both pass the check and
+	// double-write. Only disable it for S3-compatible stores that reject conditional requests
+	DisableConditionalPut bool
+	// DryRun whether writers validate, paginate and compress data but skip uploads, logging the paths and
+	// sizes of the files that would be written, default false. Hooks and metrics see the files as written
+	DryRun bool
+	// StreamingUpload whether to stream pages through json encoding, gzip and upload instead of
+	// buffering each compressed page in memory, default false. S3 and OSS use multipart uploads
+	StreamingUpload bool
+	// Staging whether metering writers upload pages under layout.StagingPrefix, where readers don't see
+	// them, until MeteringWriter.Finalize publishes the files of a timestamp, default false
+	Staging bool
+	// RequireFinalized whether metering readers only return files listed in the finalize markers of
+	// writers in staging mode, so files of a timestamp whose Finalize crashed halfway are skipped
+	RequireFinalized bool
+	// StrictFormatVersion whether readers reject files written in a format version newer than they
+	// support with reader.ErrUnsupportedFormat, default false logs a warning and reads the known fields
+	StrictFormatVersion bool
+	// MaxConcurrentUploads caps concurrent uploads of each writer, default 0 means unlimited.
+	// Use storage.SetGlobalUploadLimit to cap uploads across all writers of the process
+	MaxConcurrentUploads int
+	// UploadConcurrency number of pages of a paginated write uploaded in parallel, default 0 or 1
+	// uploads pages one after the other. Still subject to MaxConcurrentUploads
+	UploadConcurrency int
 	// PageSizeBytes page size in bytes, when serialized data exceeds this size, pagination is performed
 	// Default 0 means no pagination. Recommended value like 50MB = 50 * 1024 * 1024
 	PageSizeBytes int64
+	// PartNumberWidth zero-pads part numbers in file names to this width, e.g. 4 gives tikv001-0002.json.gz,
+	// so lexicographic listings follow part order. Default 0 keeps unpadded names; readers accept both
+	PartNumberWidth int
+	// AutoTruncateTimestamp whether metering writers truncate timestamps to the start of their minute
+	// instead of rejecting timestamps that are not minute-level, default false
+	AutoTruncateTimestamp bool
+	// ClockSkew bounds metering timestamps relative to the wall clock, default no bounds
+	ClockSkew ClockSkew
+	// SelfIDValidator validates the component IDs of metering data written, nil rejects IDs containing
+	// dashes. Dashes are escaped in paths, so validators may accept them; IDs are never empty, "." or ".."
+	// or contain "/"
+	SelfIDValidator IDValidator
+	// ClusterIDValidator validates cluster and shared pool IDs of data written, nil rejects IDs containing
+	// characters invalid in file names, except in metadata. IDs are never empty, "." or ".." or contain "/"
+	ClusterIDValidator IDValidator
+	// PathLayout layout of metering file paths, nil uses layout.Default(). Writers and readers
+	// of the same data must use the same layout
+	PathLayout *layout.Layout
+	// Codec serializes metering files written, nil uses codec.JSON. Readers detect the codec of each
+	// file from its suffix, the codec must be registered with codec.Register
+	Codec codec.Codec
+	// UploadOptions HTTP metadata set on uploaded files, ContentType defaults to DefaultContentType.
+	// A write whose context carries storage.WithUploadOptions uses those instead
+	UploadOptions storage.UploadOptions
+	// Timeouts bound every upload, download and listing the SDK makes, even if the caller's context has no
+	// deadline, so a hung connection can't stall a flush indefinitely. Default 0 means no timeout
+	Timeouts storage.Timeouts
+	// ErrorSink receives terminal write failures from writers, optional
+	ErrorSink writer.ErrorSink
+	// DeadLetters receives the pages of metering writes whose upload failed, so they can be replayed, optional
+	DeadLetters writer.DeadLetterQueue
+	// Hooks callbacks invoked by writers as pages are uploaded, writes fail and write calls finish, optional
+	Hooks *writer.Hooks
+	// Encryption encrypts files client-side with AES-GCM before upload and decrypts them when read, optional.
+	// Writers and readers of the same data must use keys of the same KeyProvider
+	Encryption storage.KeyProvider
+	// Schemas validates metering Data entries per category at write time, optional
+	Schemas *schema.Registry
+	// Units rejects metering values with units it doesn't know at write time, and converts between
+	// units of the same dimension when aggregating, optional
+	Units *units.Registry
+	// Metrics records Prometheus metrics for writers, readers and storage providers, optional
+	Metrics *metrics.Metrics
+	// TracerProvider creates OpenTelemetry spans for writers, readers and storage providers, optional
+	TracerProvider trace.TracerProvider
 }
 
 // DefaultConfig returns default configuration
@@ -52,6 +169,12 @@ func (c *Config) WithLogger(logger *zap.Logger) *Config {
 	return c
 }
 
+// WithSlogHandler logs through a log/slog handler instead of zap
+func (c *Config) WithSlogHandler(handler slog.Handler) *Config {
+	c.Logger = logging.NewSlogLogger(handler)
+	return c
+}
+
 // WithProductionLogger sets production environment logger
 func (c *Config) WithProductionLogger() *Config {
 	logger, err := zap.NewProduction()
@@ -103,24 +226,328 @@ func (c *Config) GetLogger() *zap.Logger {
 	return c.Logger
 }
 
+// ProviderLogger returns the logger with the provider and bucket fields of provider, if it implements
+// storage.Describer
+func (c *Config) ProviderLogger(provider storage.ObjectStorageProvider) *zap.Logger {
+	logger := c.GetLogger()
+	describer, ok := provider.(storage.Describer)
+	if !ok {
+		return logger
+	}
+	desc := describer.Describe()
+	fields := []zap.Field{logging.Provider(string(desc.Type))}
+	if desc.Bucket != "" {
+		fields = append(fields, logging.Bucket(desc.Bucket))
+	}
+	return logger.With(fields...)
+}
+
+// WrapProvider wraps provider the way writers and readers use it: requests are counted in calls, bounded
+// by Timeouts, encrypted, instrumented and traced
+func (c *Config) WrapProvider(provider storage.ObjectStorageProvider, calls *storage.CallCounter) storage.ObjectStorageProvider {
+	provider = storage.NewEncryptedProvider(storage.NewTimeoutProvider(storage.NewCountingProvider(provider, calls), c.Timeouts), c.Encryption)
+	return tracing.TraceProvider(metrics.InstrumentProvider(provider, c.Metrics), c.TracerProvider)
+}
+
 // WithOverwriteExisting sets whether to overwrite existing files
 func (c *Config) WithOverwriteExisting(overwrite bool) *Config {
 	c.OverwriteExisting = overwrite
 	return c
 }
 
+// WithDryRun sets whether writers skip uploads, e.g. to stage a new component without writing to the
+// production bucket
+func (c *Config) WithDryRun(dryRun bool) *Config {
+	c.DryRun = dryRun
+	return c
+}
+
+// WithConditionalPut sets whether to use conditional uploads instead of Exists pre-checks, default true
+func (c *Config) WithConditionalPut(enabled bool) *Config {
+	c.DisableConditionalPut = !enabled
+	return c
+}
+
+// WithMetricsRegistry enables Prometheus metrics, registering the SDK collectors with reg.
+// If the collectors cannot be registered, a warning is logged and metrics stay disabled.
+func (c *Config) WithMetricsRegistry(reg prometheus.Registerer) *Config {
+	m, err := metrics.NewMetrics(reg)
+	if err != nil {
+		c.GetLogger().Warn("Failed to register metering SDK metrics", zap.Error(err))
+		return c
+	}
+	c.Metrics = m
+	return c
+}
+
+// WithTracerProvider enables OpenTelemetry tracing with the given tracer provider
+func (c *Config) WithTracerProvider(tp trace.TracerProvider) *Config {
+	c.TracerProvider = tp
+	return c
+}
+
+// WithSchemaRegistry sets the registry used to validate metering Data entries per category
+func (c *Config) WithSchemaRegistry(registry *schema.Registry) *Config {
+	c.Schemas = registry
+	return c
+}
+
+// WithUnitRegistry sets the registry of known units, e.g. units.Default(), so writers reject unknown units
+func (c *Config) WithUnitRegistry(registry *units.Registry) *Config {
+	c.Units = registry
+	return c
+}
+
+// WithStreamingUpload sets whether pages are streamed to storage instead of buffered in memory
+func (c *Config) WithStreamingUpload(enabled bool) *Config {
+	c.StreamingUpload = enabled
+	return c
+}
+
+// WithStaging sets whether metering writers stage pages until they are finalized
+func (c *Config) WithStaging(enabled bool) *Config {
+	c.Staging = enabled
+	return c
+}
+
+// WithRequireFinalized sets whether metering readers only return finalized files
+func (c *Config) WithRequireFinalized(enabled bool) *Config {
+	c.RequireFinalized = enabled
+	return c
+}
+
+// WithStrictFormatVersion sets whether readers reject files of a newer format version
+func (c *Config) WithStrictFormatVersion(strict bool) *Config {
+	c.StrictFormatVersion = strict
+	return c
+}
+
+// WithMaxConcurrentUploads sets the maximum number of concurrent uploads per writer, 0 means unlimited
+func (c *Config) WithMaxConcurrentUploads(n int) *Config {
+	c.MaxConcurrentUploads = max(n, 0)
+	return c
+}
+
+// WithUploadConcurrency sets the number of pages of a paginated write uploaded in parallel
+func (c *Config) WithUploadConcurrency(n int) *Config {
+	c.UploadConcurrency = max(n, 0)
+	return c
+}
+
 // WithPageSize sets page size (bytes)
 func (c *Config) WithPageSize(sizeBytes int64) *Config {
 	c.PageSizeBytes = sizeBytes
 	return c
 }
 
+// WithPartNumberWidth sets the zero-padded width of part numbers in file names, 0 disables padding
+func (c *Config) WithPartNumberWidth(width int) *Config {
+	c.PartNumberWidth = max(width, 0)
+	return c
+}
+
+// WithAutoTruncateTimestamp sets whether metering writers truncate timestamps to minute level
+func (c *Config) WithAutoTruncateTimestamp(enabled bool) *Config {
+	c.AutoTruncateTimestamp = enabled
+	return c
+}
+
+// WithClockSkewTolerance rejects metering timestamps more than maxFuture after or maxPast before the
+// current minute, 0 disables a bound
+func (c *Config) WithClockSkewTolerance(maxFuture, maxPast time.Duration) *Config {
+	c.ClockSkew.MaxFuture = max(maxFuture, 0)
+	c.ClockSkew.MaxPast = max(maxPast, 0)
+	return c
+}
+
+// WithClockSkewWarnOnly sets whether timestamps out of the clock skew tolerance are only logged
+func (c *Config) WithClockSkewWarnOnly(warnOnly bool) *Config {
+	c.ClockSkew.WarnOnly = warnOnly
+	return c
+}
+
+// WithSelfIDValidator sets the validator of component IDs, e.g. PatternIDValidator to allow dashes
+func (c *Config) WithSelfIDValidator(validator IDValidator) *Config {
+	c.SelfIDValidator = validator
+	return c
+}
+
+// WithClusterIDValidator sets the validator of cluster and shared pool IDs
+func (c *Config) WithClusterIDValidator(validator IDValidator) *Config {
+	c.ClusterIDValidator = validator
+	return c
+}
+
+// ValidateSelfID validates a component ID with the configured SelfIDValidator
+func (c *Config) ValidateSelfID(selfID string) error {
+	if c.SelfIDValidator == nil {
+		return validateID("self ID", selfID, utils.ValidateSelfID)
+	}
+	return validateID("self ID", selfID, c.SelfIDValidator)
+}
+
+// ValidateClusterID validates a cluster or shared pool ID with the configured ClusterIDValidator
+func (c *Config) ValidateClusterID(clusterID string) error {
+	if c.ClusterIDValidator == nil {
+		return validateID("cluster ID", clusterID, utils.ValidateClusterID)
+	}
+	return validateID("cluster ID", clusterID, c.ClusterIDValidator)
+}
+
+// ValidateMetaClusterID validates the cluster ID of metadata with the configured ClusterIDValidator. Unlike
+// ValidateClusterID, nil only requires the ID to be a valid path segment
+func (c *Config) ValidateMetaClusterID(clusterID string) error {
+	if c.ClusterIDValidator == nil {
+		return validateID("cluster ID", clusterID, func(string) error { return nil })
+	}
+	return validateID("cluster ID", clusterID, c.ClusterIDValidator)
+}
+
+// NewRecord starts building a MeteringData.Data entry whose logical cluster ID is checked with
+// ValidateClusterID
+func (c *Config) NewRecord() *common.RecordBuilder {
+	return common.NewRecordWithValidator(c.ValidateClusterID)
+}
+
+// validateID checks id is a valid path segment before applying validator
+func validateID(kind, id string, validator IDValidator) error {
+	if id == "" {
+		return fmt.Errorf("%s cannot be empty", kind)
+	}
+	if strings.Contains(id, "/") {
+		return fmt.Errorf("%s cannot contain slash character: %s", kind, id)
+	}
+	if id == "." || id == ".." {
+		return fmt.Errorf("%s cannot be a relative path segment: %s", kind, id)
+	}
+	if err := validator(id); err != nil {
+		return fmt.Errorf("invalid %s: %w", kind, err)
+	}
+	return nil
+}
+
+// WithPathLayout sets the layout of metering file paths, e.g. layout.MustNew(layout.HiveTemplate)
+func (c *Config) WithPathLayout(l *layout.Layout) *Config {
+	c.PathLayout = l
+	return c
+}
+
+// GetPathLayout returns the configured path layout, or the default layout if none is set
+func (c *Config) GetPathLayout() *layout.Layout {
+	if c.PathLayout == nil {
+		return layout.Default()
+	}
+	return c.PathLayout
+}
+
+// WithCodec sets the codec metering files are written with, e.g. a protobuf codec registered with codec.Register
+func (c *Config) WithCodec(fileCodec codec.Codec) *Config {
+	c.Codec = fileCodec
+	return c
+}
+
+// GetCodec returns the configured codec, or codec.JSON if none is set
+func (c *Config) GetCodec() codec.Codec {
+	if c.Codec == nil {
+		return codec.JSON
+	}
+	return c.Codec
+}
+
+// WithContentType sets the Content-Type of uploaded files, e.g. application/json together with
+// WithContentEncoding("gzip") so browsers decompress downloads transparently
+func (c *Config) WithContentType(contentType string) *Config {
+	c.UploadOptions.ContentType = contentType
+	return c
+}
+
+// WithContentEncoding sets the Content-Encoding of uploaded files
+func (c *Config) WithContentEncoding(contentEncoding string) *Config {
+	c.UploadOptions.ContentEncoding = contentEncoding
+	return c
+}
+
+// WithCacheControl sets the Cache-Control of uploaded files, e.g. "max-age=3600"
+func (c *Config) WithCacheControl(cacheControl string) *Config {
+	c.UploadOptions.CacheControl = cacheControl
+	return c
+}
+
+// WithStorageClass sets the storage class of files uploaded to S3 or OSS, e.g. "STANDARD_IA" or
+// "INTELLIGENT_TIERING" on S3 and "IA" on OSS
+func (c *Config) WithStorageClass(storageClass string) *Config {
+	c.UploadOptions.StorageClass = storageClass
+	return c
+}
+
+// WithTags sets the object tags of files uploaded to S3 or OSS, e.g. {"tenant": "acme"}, so lifecycle
+// policies can be driven off them
+func (c *Config) WithTags(tags map[string]string) *Config {
+	c.UploadOptions.Tags = tags
+	return c
+}
+
+// UploadContext returns ctx carrying the configured upload options, unless ctx already carries
+// options for this write
+func (c *Config) UploadContext(ctx context.Context) context.Context {
+	if storage.UploadOptionsFromContext(ctx) != nil {
+		return ctx
+	}
+	opts := c.UploadOptions
+	if opts.ContentType == "" {
+		opts.ContentType = DefaultContentType
+	}
+	return storage.WithUploadOptions(ctx, &opts)
+}
+
 // WithPageSizeMB sets page size (MB)
 func (c *Config) WithPageSizeMB(sizeMB int64) *Config {
 	c.PageSizeBytes = sizeMB * 1024 * 1024
 	return c
 }
 
+// WithUploadTimeout sets the timeout of every upload, copy and move
+func (c *Config) WithUploadTimeout(timeout time.Duration) *Config {
+	c.Timeouts.Upload = timeout
+	return c
+}
+
+// WithDownloadTimeout sets the timeout of every download, including reading its body
+func (c *Config) WithDownloadTimeout(timeout time.Duration) *Config {
+	c.Timeouts.Download = timeout
+	return c
+}
+
+// WithListTimeout sets the timeout of every listing page and metadata request, e.g. Exists
+func (c *Config) WithListTimeout(timeout time.Duration) *Config {
+	c.Timeouts.List = timeout
+	return c
+}
+
+// WithEncryption sets the key provider of client-side encryption
+func (c *Config) WithEncryption(keys storage.KeyProvider) *Config {
+	c.Encryption = keys
+	return c
+}
+
+// WithErrorSink sets the sink that receives terminal write failures
+func (c *Config) WithErrorSink(sink writer.ErrorSink) *Config {
+	c.ErrorSink = sink
+	return c
+}
+
+// WithDeadLetterQueue sets the queue that receives pages whose upload failed
+func (c *Config) WithDeadLetterQueue(queue writer.DeadLetterQueue) *Config {
+	c.DeadLetters = queue
+	return c
+}
+
+// WithHooks sets the callbacks invoked by writers, e.g. to emit application metrics
+func (c *Config) WithHooks(hooks *writer.Hooks) *Config {
+	c.Hooks = hooks
+	return c
+}
+
 // MeteringAWSConfig AWS S3 specific configuration for high-level config
 type MeteringAWSConfig struct {
 	AssumeRoleARN    string `yaml:"assume-role-arn,omitempty" toml:"assume-role-arn,omitempty" json:"assume-role-arn,omitempty" reloadable:"false"`
@@ -128,6 +555,18 @@ type MeteringAWSConfig struct {
 	AccessKey        string `yaml:"access-key,omitempty" toml:"access-key,omitempty" json:"access-key,omitempty" reloadable:"false"`
 	SecretAccessKey  string `yaml:"secret-access-key,omitempty" toml:"secret-access-key,omitempty" json:"secret-access-key,omitempty" reloadable:"false"`
 	SessionToken     string `yaml:"session-token,omitempty" toml:"session-token,omitempty" json:"session-token,omitempty" reloadable:"false"`
+	// MinIO enables the compatibility mode for MinIO and other self-hosted S3-compatible stores
+	MinIO bool `yaml:"minio,omitempty" toml:"minio,omitempty" json:"minio,omitempty" reloadable:"false"`
+	// DisableS3ExpressSessionAuth signs S3 Express directory bucket requests without CreateSession
+	DisableS3ExpressSessionAuth bool `yaml:"disable-s3-express-session-auth,omitempty" toml:"disable-s3-express-session-auth,omitempty" json:"disable-s3-express-session-auth,omitempty" reloadable:"false"`
+	// Server-side encryption of uploaded objects: AES256, aws:kms or aws:kms:dsse
+	ServerSideEncryption string `yaml:"sse,omitempty" toml:"sse,omitempty" json:"sse,omitempty" reloadable:"false"`
+	SSEKMSKeyID          string `yaml:"sse-kms-key-id,omitempty" toml:"sse-kms-key-id,omitempty" json:"sse-kms-key-id,omitempty" reloadable:"false"`
+	SSEBucketKeyEnabled  bool   `yaml:"sse-bucket-key,omitempty" toml:"sse-bucket-key,omitempty" json:"sse-bucket-key,omitempty" reloadable:"false"`
+	// Endpoint options: S3 Transfer Acceleration, dual-stack (IPv6) endpoints and access points
+	UseAccelerate  bool   `yaml:"accelerate,omitempty" toml:"accelerate,omitempty" json:"accelerate,omitempty" reloadable:"false"`
+	UseDualStack   bool   `yaml:"dual-stack,omitempty" toml:"dual-stack,omitempty" json:"dual-stack,omitempty" reloadable:"false"`
+	AccessPointARN string `yaml:"access-point-arn,omitempty" toml:"access-point-arn,omitempty" json:"access-point-arn,omitempty" reloadable:"false"`
 }
 
 // MeteringOSSConfig Alibaba Cloud OSS specific configuration for high-level config
@@ -136,6 +575,9 @@ type MeteringOSSConfig struct {
 	AccessKey       string `yaml:"access-key,omitempty" toml:"access-key,omitempty" json:"access-key,omitempty" reloadable:"false"`
 	SecretAccessKey string `yaml:"secret-access-key,omitempty" toml:"secret-access-key,omitempty" json:"secret-access-key,omitempty" reloadable:"false"`
 	SessionToken    string `yaml:"session-token,omitempty" toml:"session-token,omitempty" json:"session-token,omitempty" reloadable:"false"`
+	// Server-side encryption of uploaded objects: AES256, KMS or SM4
+	ServerSideEncryption string `yaml:"sse,omitempty" toml:"sse,omitempty" json:"sse,omitempty" reloadable:"false"`
+	SSEKMSKeyID          string `yaml:"sse-kms-key-id,omitempty" toml:"sse-kms-key-id,omitempty" json:"sse-kms-key-id,omitempty" reloadable:"false"`
 }
 
 // MeteringAzureConfig Azure Blob Storage specific configuration for high-level config
@@ -145,11 +587,45 @@ type MeteringAzureConfig struct {
 	SASToken    string `yaml:"sas-token,omitempty" toml:"sas-token,omitempty" json:"sas-token,omitempty" reloadable:"false"`
 }
 
+// MeteringGCSConfig Google Cloud Storage specific configuration for high-level config
+type MeteringGCSConfig struct {
+	ProjectID string `yaml:"project-id,omitempty" toml:"project-id,omitempty" json:"project-id,omitempty" reloadable:"false"`
+	// Path of the service account key file, empty uses the application default credentials
+	CredentialsFile string `yaml:"credentials-file,omitempty" toml:"credentials-file,omitempty" json:"credentials-file,omitempty" reloadable:"false"`
+}
+
 // MeteringLocalFSConfig local filesystem specific configuration for high-level config
 type MeteringLocalFSConfig struct {
 	BasePath    string `yaml:"base-path,omitempty" toml:"base-path,omitempty" json:"base-path,omitempty" reloadable:"false"`
 	CreateDirs  bool   `yaml:"create-dirs,omitempty" toml:"create-dirs,omitempty" json:"create-dirs,omitempty" reloadable:"false"`
 	Permissions string `yaml:"permissions,omitempty" toml:"permissions,omitempty" json:"permissions,omitempty" reloadable:"false"`
+	Fsync       bool   `yaml:"fsync,omitempty" toml:"fsync,omitempty" json:"fsync,omitempty" reloadable:"false"`
+	Lock        bool   `yaml:"lock,omitempty" toml:"lock,omitempty" json:"lock,omitempty" reloadable:"false"`
+	MaxBytes    int64  `yaml:"max-bytes,omitempty" toml:"max-bytes,omitempty" json:"max-bytes,omitempty" reloadable:"false"`
+	QuotaPolicy string `yaml:"quota-policy,omitempty" toml:"quota-policy,omitempty" json:"quota-policy,omitempty" reloadable:"false"`
+}
+
+// MeteringSFTPConfig SFTP specific configuration for high-level config
+type MeteringSFTPConfig struct {
+	// Host name or address, with an optional port
+	Host string `yaml:"host,omitempty" toml:"host,omitempty" json:"host,omitempty" reloadable:"false"`
+	User string `yaml:"user,omitempty" toml:"user,omitempty" json:"user,omitempty" reloadable:"false"`
+	// Base directory on the host, relative to the login directory unless absolute
+	BasePath string `yaml:"base-path,omitempty" toml:"base-path,omitempty" json:"base-path,omitempty" reloadable:"false"`
+	// Private key file of the user
+	PrivateKeyFile string `yaml:"private-key-file,omitempty" toml:"private-key-file,omitempty" json:"private-key-file,omitempty" reloadable:"false"`
+	Passphrase     string `yaml:"passphrase,omitempty" toml:"passphrase,omitempty" json:"passphrase,omitempty" reloadable:"false"`
+	// known_hosts file verifying the host key, ~/.ssh/known_hosts by default
+	KnownHostsFile string `yaml:"known-hosts-file,omitempty" toml:"known-hosts-file,omitempty" json:"known-hosts-file,omitempty" reloadable:"false"`
+	// Skip host key verification, for tests only
+	InsecureIgnoreHostKey bool `yaml:"insecure-ignore-host-key,omitempty" toml:"insecure-ignore-host-key,omitempty" json:"insecure-ignore-host-key,omitempty" reloadable:"false"`
+}
+
+// MeteringRateLimitConfig client-side request and bandwidth limits for high-level config
+type MeteringRateLimitConfig struct {
+	RequestsPerSecond float64 `yaml:"requests-per-second,omitempty" toml:"requests-per-second,omitempty" json:"requests-per-second,omitempty" reloadable:"false"`
+	Burst             int     `yaml:"request-burst,omitempty" toml:"request-burst,omitempty" json:"request-burst,omitempty" reloadable:"false"`
+	BytesPerSecond    int64   `yaml:"bytes-per-second,omitempty" toml:"bytes-per-second,omitempty" json:"bytes-per-second,omitempty" reloadable:"false"`
 }
 
 // MeteringConfig represents a high-level configuration for metering SDK
@@ -170,7 +646,13 @@ type MeteringConfig struct {
 	AWS     *MeteringAWSConfig     `yaml:"aws,omitempty" toml:"aws,omitempty" json:"aws,omitempty" reloadable:"false"`
 	OSS     *MeteringOSSConfig     `yaml:"oss,omitempty" toml:"oss,omitempty" json:"oss,omitempty" reloadable:"false"`
 	Azure   *MeteringAzureConfig   `yaml:"azure,omitempty" toml:"azure,omitempty" json:"azure,omitempty" reloadable:"false"`
+	GCS     *MeteringGCSConfig     `yaml:"gcs,omitempty" toml:"gcs,omitempty" json:"gcs,omitempty" reloadable:"false"`
 	LocalFS *MeteringLocalFSConfig `yaml:"localfs,omitempty" toml:"localfs,omitempty" json:"localfs,omitempty" reloadable:"false"`
+	SFTP    *MeteringSFTPConfig    `yaml:"sftp,omitempty" toml:"sftp,omitempty" json:"sftp,omitempty" reloadable:"false"`
+	// Options settings of providers registered with storage.RegisterProvider
+	Options map[string]string `yaml:"options,omitempty" toml:"options,omitempty" json:"options,omitempty" reloadable:"false"`
+	// Client-side rate limits of requests to the storage service
+	RateLimit *MeteringRateLimitConfig `yaml:"rate-limit,omitempty" toml:"rate-limit,omitempty" json:"rate-limit,omitempty" reloadable:"false"`
 
 	// Business-specific configurations
 	// Shared pool cluster ID for sharedpool type metadata
@@ -185,6 +667,14 @@ func (mc *MeteringConfig) ToProviderConfig() *storage.ProviderConfig {
 		Bucket:   mc.Bucket,
 		Prefix:   mc.Prefix,
 		Endpoint: mc.Endpoint,
+		Options:  mc.Options,
+	}
+	if mc.RateLimit != nil {
+		config.RateLimit = &storage.RateLimitConfig{
+			RequestsPerSecond: mc.RateLimit.RequestsPerSecond,
+			Burst:             mc.RateLimit.Burst,
+			BytesPerSecond:    mc.RateLimit.BytesPerSecond,
+		}
 	}
 
 	switch mc.Type {
@@ -196,6 +686,15 @@ func (mc *MeteringConfig) ToProviderConfig() *storage.ProviderConfig {
 				AccessKey:        mc.AWS.AccessKey,
 				SecretAccessKey:  mc.AWS.SecretAccessKey,
 				SessionToken:     mc.AWS.SessionToken,
+
+				MinIO:                       mc.AWS.MinIO,
+				DisableS3ExpressSessionAuth: mc.AWS.DisableS3ExpressSessionAuth,
+				ServerSideEncryption:        mc.AWS.ServerSideEncryption,
+				SSEKMSKeyID:                 mc.AWS.SSEKMSKeyID,
+				SSEBucketKeyEnabled:         mc.AWS.SSEBucketKeyEnabled,
+				UseAccelerate:               mc.AWS.UseAccelerate,
+				UseDualStack:                mc.AWS.UseDualStack,
+				AccessPointARN:              mc.AWS.AccessPointARN,
 			}
 		}
 	case storage.ProviderTypeOSS:
@@ -205,6 +704,9 @@ func (mc *MeteringConfig) ToProviderConfig() *storage.ProviderConfig {
 				AccessKey:       mc.OSS.AccessKey,
 				SecretAccessKey: mc.OSS.SecretAccessKey,
 				SessionToken:    mc.OSS.SessionToken,
+
+				ServerSideEncryption: mc.OSS.ServerSideEncryption,
+				SSEKMSKeyID:          mc.OSS.SSEKMSKeyID,
 			}
 		}
 	case storage.ProviderTypeAzure:
@@ -215,12 +717,35 @@ func (mc *MeteringConfig) ToProviderConfig() *storage.ProviderConfig {
 				SASToken:    mc.Azure.SASToken,
 			}
 		}
+	case storage.ProviderTypeGCS:
+		if mc.GCS != nil {
+			config.GCS = &storage.GCSConfig{
+				ProjectID:       mc.GCS.ProjectID,
+				CredentialsFile: mc.GCS.CredentialsFile,
+			}
+		}
 	case storage.ProviderTypeLocalFS:
 		if mc.LocalFS != nil {
 			config.LocalFS = &storage.LocalFSConfig{
 				BasePath:    mc.LocalFS.BasePath,
 				CreateDirs:  mc.LocalFS.CreateDirs,
 				Permissions: mc.LocalFS.Permissions,
+				Fsync:       mc.LocalFS.Fsync,
+				Lock:        mc.LocalFS.Lock,
+				MaxBytes:    mc.LocalFS.MaxBytes,
+				QuotaPolicy: storage.QuotaPolicy(mc.LocalFS.QuotaPolicy),
+			}
+		}
+	case storage.ProviderTypeSFTP:
+		if mc.SFTP != nil {
+			config.SFTP = &storage.SFTPConfig{
+				Host:                  mc.SFTP.Host,
+				User:                  mc.SFTP.User,
+				BasePath:              mc.SFTP.BasePath,
+				PrivateKeyFile:        mc.SFTP.PrivateKeyFile,
+				Passphrase:            mc.SFTP.Passphrase,
+				KnownHostsFile:        mc.SFTP.KnownHostsFile,
+				InsecureIgnoreHostKey: mc.SFTP.InsecureIgnoreHostKey,
 			}
 		}
 	}
@@ -298,6 +823,17 @@ func (mc *MeteringConfig) WithAzureSASToken(accountName, container, sasToken str
 	return mc
 }
 
+// WithGCS configures for Google Cloud Storage
+func (mc *MeteringConfig) WithGCS(projectID, bucket string) *MeteringConfig {
+	mc.Type = storage.ProviderTypeGCS
+	mc.Bucket = bucket
+	if mc.GCS == nil {
+		mc.GCS = &MeteringGCSConfig{}
+	}
+	mc.GCS.ProjectID = projectID
+	return mc
+}
+
 // WithLocalFS configures for local filesystem storage
 func (mc *MeteringConfig) WithLocalFS(basePath string) *MeteringConfig {
 	mc.Type = storage.ProviderTypeLocalFS
@@ -309,6 +845,19 @@ func (mc *MeteringConfig) WithLocalFS(basePath string) *MeteringConfig {
 	return mc
 }
 
+// WithSFTP configures for an SFTP host, authenticating user with the private key file
+func (mc *MeteringConfig) WithSFTP(host, user, privateKeyFile, basePath string) *MeteringConfig {
+	mc.Type = storage.ProviderTypeSFTP
+	if mc.SFTP == nil {
+		mc.SFTP = &MeteringSFTPConfig{}
+	}
+	mc.SFTP.Host = host
+	mc.SFTP.User = user
+	mc.SFTP.PrivateKeyFile = privateKeyFile
+	mc.SFTP.BasePath = basePath
+	return mc
+}
+
 // WithAWSConfig sets AWS specific configuration
 func (mc *MeteringConfig) WithAWSConfig(awsConfig *MeteringAWSConfig) *MeteringConfig {
 	mc.AWS = awsConfig
@@ -327,6 +876,18 @@ func (mc *MeteringConfig) WithAzureConfig(azureConfig *MeteringAzureConfig) *Met
 	return mc
 }
 
+// WithGCSConfig sets GCS specific configuration
+func (mc *MeteringConfig) WithGCSConfig(gcsConfig *MeteringGCSConfig) *MeteringConfig {
+	mc.GCS = gcsConfig
+	return mc
+}
+
+// WithSFTPConfig sets SFTP specific configuration
+func (mc *MeteringConfig) WithSFTPConfig(sftpConfig *MeteringSFTPConfig) *MeteringConfig {
+	mc.SFTP = sftpConfig
+	return mc
+}
+
 // WithLocalFSConfig sets LocalFS specific configuration
 func (mc *MeteringConfig) WithLocalFSConfig(localConfig *MeteringLocalFSConfig) *MeteringConfig {
 	mc.LocalFS = localConfig
@@ -380,14 +941,22 @@ func (mc *MeteringConfig) WithOSSRoleARN(roleARN string) *MeteringConfig {
 //   - s3://my-bucket/data?region-id=us-east-1&endpoint=https://s3.example.com
 //   - oss://my-bucket/logs?region-id=oss-ap-southeast-1&access-key=AKSKEXAMPLE
 //   - azure://my-container/prefix?account-name=acct&account-key=key&endpoint=https://acct.blob.core.windows.net
+//   - gs://my-bucket/prefix?project-id=my-project&service-account=/etc/gcs/key.json
 //   - localfs:///data/storage/logs?create-dirs=true&permissions=0755
+//   - file:///C:/metering (Windows paths may use either separator)
+//   - sftp://metering@jump-host:22/var/metering?private-key-file=/etc/metering/id_ed25519
 //
-// Supported schemes: s3, oss, azure (alias: azblob), localfs, file
-// Common parameters: region-id/region, endpoint, shared-pool-id
-// AWS/S3 parameters: access-key, secret-access-key, session-token, assume-role-arn/role-arn, s3-force-path-style/force-path-style
-// OSS parameters: access-key, secret-access-key, session-token, assume-role-arn/role-arn
+// Supported schemes: s3, oss, gs (alias: gcs), azure (alias: azblob), localfs, file, sftp, memory, providers registered
+// with storage.RegisterProvider and schemes registered with RegisterURIScheme
+// Common parameters: region-id/region, endpoint, shared-pool-id, requests-per-second, request-burst, bytes-per-second
+// AWS/S3 parameters: access-key, secret-access-key, session-token, assume-role-arn/role-arn, s3-force-path-style/force-path-style,
+// minio, disable-s3-express-session-auth, sse, sse-kms-key-id, sse-bucket-key, accelerate, dual-stack, access-point-arn
+// OSS parameters: access-key, secret-access-key, session-token, assume-role-arn/role-arn, sse, sse-kms-key-id
 // Azure parameters: account-name, account-key, sas-token
-// LocalFS parameters: create-dirs, permissions
+// GCS parameters: project-id, service-account/credentials-file
+// LocalFS parameters: create-dirs, permissions, fsync, lock, max-bytes, quota-policy
+// SFTP parameters: private-key-file, passphrase, known-hosts-file, insecure-ignore-host-key, base-path (relative base
+// paths); the host, user and path of the URI are the SFTP host, user and absolute base path
 func NewFromURI(uriStr string) (*MeteringConfig, error) {
 	parsedURL, err := url.Parse(uriStr)
 	if err != nil {
@@ -397,24 +966,18 @@ func NewFromURI(uriStr string) (*MeteringConfig, error) {
 	config := NewMeteringConfig()
 
 	// Parse scheme to determine provider type
-	switch strings.ToLower(parsedURL.Scheme) {
-	case "s3":
-		config.Type = storage.ProviderTypeS3
-	case "oss":
-		config.Type = storage.ProviderTypeOSS
-	case "azure", "azblob":
-		config.Type = storage.ProviderTypeAzure
-	case "localfs", "file":
-		config.Type = storage.ProviderTypeLocalFS
-	default:
-		return nil, fmt.Errorf("unsupported URI scheme: %s", parsedURL.Scheme)
+	if config.Type, err = providerTypeOfScheme(parsedURL.Scheme); err != nil {
+		return nil, err
 	}
 
 	// Parse host and path based on provider type
 	if config.Type == storage.ProviderTypeLocalFS {
 		// For localfs, handle different path formats
 		var basePath string
-		if parsedURL.Host != "" {
+		if utils.IsWindowsDrive(parsedURL.Host) {
+			// For URI like "file://C:/path", the host is the drive
+			basePath = parsedURL.Host + "/" + strings.TrimPrefix(parsedURL.Path, "/")
+		} else if parsedURL.Host != "" {
 			// For URI like "localfs://host/path", combine host and path
 			// Ensure proper path construction without double slashes
 			hostPath := "/" + parsedURL.Host
@@ -426,13 +989,20 @@ func NewFromURI(uriStr string) (*MeteringConfig, error) {
 				basePath = hostPath
 			}
 		} else {
-			// For URI like "file:///path" or "localfs:///path", use path directly
-			basePath = parsedURL.Path
+			// For URI like "file:///path" or "localfs:///path", use path directly, without the slash before
+			// the drive of Windows paths like "file:///C:/path"
+			basePath = utils.TrimDriveSlash(parsedURL.Path)
 		}
 		config.LocalFS = &MeteringLocalFSConfig{
 			BasePath:   basePath,
 			CreateDirs: true, // default
 		}
+	} else if config.Type == storage.ProviderTypeSFTP {
+		config.SFTP = &MeteringSFTPConfig{
+			Host:     parsedURL.Host,
+			User:     parsedURL.User.Username(),
+			BasePath: parsedURL.Path,
+		}
 	} else {
 		// For cloud providers, host is bucket name
 		if parsedURL.Host != "" {
@@ -465,6 +1035,11 @@ func NewFromURI(uriStr string) (*MeteringConfig, error) {
 	if sharedPoolID := queryParams.Get("shared-pool-id"); sharedPoolID != "" {
 		config.SharedPoolID = sharedPoolID
 	}
+	rateLimit, err := parseRateLimitParams(queryParams)
+	if err != nil {
+		return nil, err
+	}
+	config.RateLimit = rateLimit
 
 	// Provider-specific parameters
 	switch config.Type {
@@ -502,6 +1077,38 @@ func NewFromURI(uriStr string) (*MeteringConfig, error) {
 			awsConfig.S3ForcePathStyle = true
 			hasAWSConfig = true
 		}
+		if queryParams.Get("minio") == "true" {
+			awsConfig.MinIO = true
+			hasAWSConfig = true
+		}
+		if queryParams.Get("disable-s3-express-session-auth") == "true" {
+			awsConfig.DisableS3ExpressSessionAuth = true
+			hasAWSConfig = true
+		}
+		if sse := queryParams.Get("sse"); sse != "" {
+			awsConfig.ServerSideEncryption = sse
+			hasAWSConfig = true
+		}
+		if keyID := queryParams.Get("sse-kms-key-id"); keyID != "" {
+			awsConfig.SSEKMSKeyID = keyID
+			hasAWSConfig = true
+		}
+		if queryParams.Get("sse-bucket-key") == "true" {
+			awsConfig.SSEBucketKeyEnabled = true
+			hasAWSConfig = true
+		}
+		if queryParams.Get("accelerate") == "true" {
+			awsConfig.UseAccelerate = true
+			hasAWSConfig = true
+		}
+		if queryParams.Get("dual-stack") == "true" {
+			awsConfig.UseDualStack = true
+			hasAWSConfig = true
+		}
+		if accessPointARN := queryParams.Get("access-point-arn"); accessPointARN != "" {
+			awsConfig.AccessPointARN = accessPointARN
+			hasAWSConfig = true
+		}
 
 		if hasAWSConfig {
 			config.AWS = awsConfig
@@ -532,6 +1139,14 @@ func NewFromURI(uriStr string) (*MeteringConfig, error) {
 			ossConfig.AssumeRoleARN = roleARN
 			hasOSSConfig = true
 		}
+		if sse := queryParams.Get("sse"); sse != "" {
+			ossConfig.ServerSideEncryption = sse
+			hasOSSConfig = true
+		}
+		if keyID := queryParams.Get("sse-kms-key-id"); keyID != "" {
+			ossConfig.SSEKMSKeyID = keyID
+			hasOSSConfig = true
+		}
 
 		if hasOSSConfig {
 			config.OSS = ossConfig
@@ -558,6 +1173,28 @@ func NewFromURI(uriStr string) (*MeteringConfig, error) {
 			config.Azure = azureConfig
 		}
 
+	case storage.ProviderTypeGCS:
+		gcsConfig := &MeteringGCSConfig{}
+		hasGCSConfig := false
+
+		if projectID := queryParams.Get("project-id"); projectID != "" {
+			gcsConfig.ProjectID = projectID
+			hasGCSConfig = true
+		}
+		// Support both "service-account" and "credentials-file" parameter names
+		credentialsFile := queryParams.Get("service-account")
+		if credentialsFile == "" {
+			credentialsFile = queryParams.Get("credentials-file")
+		}
+		if credentialsFile != "" {
+			gcsConfig.CredentialsFile = credentialsFile
+			hasGCSConfig = true
+		}
+
+		if hasGCSConfig {
+			config.GCS = gcsConfig
+		}
+
 	case storage.ProviderTypeLocalFS:
 		if config.LocalFS == nil {
 			config.LocalFS = &MeteringLocalFSConfig{CreateDirs: true}
@@ -569,17 +1206,93 @@ func NewFromURI(uriStr string) (*MeteringConfig, error) {
 		if permissions := queryParams.Get("permissions"); permissions != "" {
 			config.LocalFS.Permissions = permissions
 		}
+		if queryParams.Get("fsync") == "true" {
+			config.LocalFS.Fsync = true
+		}
+		if queryParams.Get("lock") == "true" {
+			config.LocalFS.Lock = true
+		}
+		if value := queryParams.Get("max-bytes"); value != "" {
+			maxBytes, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid max-bytes %q: %w", value, err)
+			}
+			config.LocalFS.MaxBytes = maxBytes
+		}
+		if policy := queryParams.Get("quota-policy"); policy != "" {
+			config.LocalFS.QuotaPolicy = policy
+		}
+
+	case storage.ProviderTypeSFTP:
+		if basePath := queryParams.Get("base-path"); basePath != "" {
+			config.SFTP.BasePath = basePath
+		}
+		if keyFile := queryParams.Get("private-key-file"); keyFile != "" {
+			config.SFTP.PrivateKeyFile = keyFile
+		}
+		if passphrase := queryParams.Get("passphrase"); passphrase != "" {
+			config.SFTP.Passphrase = passphrase
+		}
+		if knownHostsFile := queryParams.Get("known-hosts-file"); knownHostsFile != "" {
+			config.SFTP.KnownHostsFile = knownHostsFile
+		}
+		if queryParams.Get("insecure-ignore-host-key") == "true" {
+			config.SFTP.InsecureIgnoreHostKey = true
+		}
+
+	case storage.ProviderTypeMemory:
+		// No provider-specific parameters
+
+	default:
+		// Registered providers receive every non-common parameter as an option
+		for key := range queryParams {
+			switch key {
+			case "region-id", "region", "prefix", "endpoint", "shared-pool-id",
+				"requests-per-second", "request-burst", "bytes-per-second":
+				continue
+			}
+			if config.Options == nil {
+				config.Options = make(map[string]string)
+			}
+			config.Options[key] = queryParams.Get(key)
+		}
 	}
 
 	return config, nil
 }
 
+// parseRateLimitParams parses the rate limit URI parameters, nil if none is set
+func parseRateLimitParams(queryParams url.Values) (*MeteringRateLimitConfig, error) {
+	var rateLimit MeteringRateLimitConfig
+	var err error
+	if value := queryParams.Get("requests-per-second"); value != "" {
+		if rateLimit.RequestsPerSecond, err = strconv.ParseFloat(value, 64); err != nil {
+			return nil, fmt.Errorf("invalid requests-per-second %q: %w", value, err)
+		}
+	}
+	if value := queryParams.Get("request-burst"); value != "" {
+		if rateLimit.Burst, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("invalid request-burst %q: %w", value, err)
+		}
+	}
+	if value := queryParams.Get("bytes-per-second"); value != "" {
+		if rateLimit.BytesPerSecond, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid bytes-per-second %q: %w", value, err)
+		}
+	}
+	if rateLimit == (MeteringRateLimitConfig{}) {
+		return nil, nil
+	}
+	return &rateLimit, nil
+}
+
 // ToURI converts MeteringConfig to a URI string.
 // URI format: [scheme]://[bucket]/[prefix]?[parameters]
 // Examples:
 //   - s3://my-bucket/data?region-id=us-east-1&endpoint=https://s3.example.com
 //   - oss://my-bucket/logs?region-id=oss-ap-southeast-1&access-key=AKSKEXAMPLE
 //   - azure://my-container/prefix?account-name=acct&account-key=key&endpoint=https://acct.blob.core.windows.net
+//   - gs://my-bucket/prefix?project-id=my-project&service-account=/etc/gcs/key.json
 //   - localfs:///data/storage/logs?create-dirs=true&permissions=0755
 func (mc *MeteringConfig) ToURI() string {
 	var uri strings.Builder
@@ -591,12 +1304,21 @@ func (mc *MeteringConfig) ToURI() string {
 		uri.WriteString("s3://")
 	case storage.ProviderTypeOSS:
 		uri.WriteString("oss://")
+	case storage.ProviderTypeGCS:
+		uri.WriteString("gs://")
 	case storage.ProviderTypeAzure:
 		uri.WriteString("azure://")
 	case storage.ProviderTypeLocalFS:
 		uri.WriteString("localfs://")
+	case storage.ProviderTypeSFTP:
+		uri.WriteString("sftp://")
+	case storage.ProviderTypeMemory:
+		uri.WriteString("memory://")
 	default:
-		return ""
+		if !storage.IsRegisteredProvider(mc.Type) {
+			return ""
+		}
+		uri.WriteString(string(mc.Type) + "://")
 	}
 
 	// Build host and path based on provider type
@@ -608,6 +1330,21 @@ func (mc *MeteringConfig) ToURI() string {
 			uri.WriteString("/")
 			uri.WriteString(basePath)
 		}
+	} else if mc.Type == storage.ProviderTypeSFTP {
+		if mc.SFTP != nil {
+			if mc.SFTP.User != "" {
+				uri.WriteString(url.PathEscape(mc.SFTP.User) + "@")
+			}
+			uri.WriteString(mc.SFTP.Host)
+			if strings.HasPrefix(mc.SFTP.BasePath, "/") {
+				uri.WriteString(mc.SFTP.BasePath)
+			} else if mc.SFTP.BasePath != "" {
+				params.Set("base-path", mc.SFTP.BasePath)
+			}
+		}
+		if mc.Prefix != "" {
+			params.Set("prefix", mc.Prefix)
+		}
 	} else {
 		// For cloud providers, host is bucket name
 		if mc.Bucket != "" {
@@ -631,6 +1368,17 @@ func (mc *MeteringConfig) ToURI() string {
 	if mc.SharedPoolID != "" {
 		params.Set("shared-pool-id", mc.SharedPoolID)
 	}
+	if mc.RateLimit != nil {
+		if mc.RateLimit.RequestsPerSecond > 0 {
+			params.Set("requests-per-second", strconv.FormatFloat(mc.RateLimit.RequestsPerSecond, 'f', -1, 64))
+		}
+		if mc.RateLimit.Burst > 0 {
+			params.Set("request-burst", strconv.Itoa(mc.RateLimit.Burst))
+		}
+		if mc.RateLimit.BytesPerSecond > 0 {
+			params.Set("bytes-per-second", strconv.FormatInt(mc.RateLimit.BytesPerSecond, 10))
+		}
+	}
 
 	// Add provider-specific parameters
 	switch mc.Type {
@@ -651,6 +1399,30 @@ func (mc *MeteringConfig) ToURI() string {
 			if mc.AWS.S3ForcePathStyle {
 				params.Set("s3-force-path-style", "true")
 			}
+			if mc.AWS.MinIO {
+				params.Set("minio", "true")
+			}
+			if mc.AWS.DisableS3ExpressSessionAuth {
+				params.Set("disable-s3-express-session-auth", "true")
+			}
+			if mc.AWS.ServerSideEncryption != "" {
+				params.Set("sse", mc.AWS.ServerSideEncryption)
+			}
+			if mc.AWS.SSEKMSKeyID != "" {
+				params.Set("sse-kms-key-id", mc.AWS.SSEKMSKeyID)
+			}
+			if mc.AWS.SSEBucketKeyEnabled {
+				params.Set("sse-bucket-key", "true")
+			}
+			if mc.AWS.UseAccelerate {
+				params.Set("accelerate", "true")
+			}
+			if mc.AWS.UseDualStack {
+				params.Set("dual-stack", "true")
+			}
+			if mc.AWS.AccessPointARN != "" {
+				params.Set("access-point-arn", mc.AWS.AccessPointARN)
+			}
 		}
 
 	case storage.ProviderTypeOSS:
@@ -667,6 +1439,12 @@ func (mc *MeteringConfig) ToURI() string {
 			if mc.OSS.AssumeRoleARN != "" {
 				params.Set("assume-role-arn", mc.OSS.AssumeRoleARN)
 			}
+			if mc.OSS.ServerSideEncryption != "" {
+				params.Set("sse", mc.OSS.ServerSideEncryption)
+			}
+			if mc.OSS.SSEKMSKeyID != "" {
+				params.Set("sse-kms-key-id", mc.OSS.SSEKMSKeyID)
+			}
 		}
 
 	case storage.ProviderTypeAzure:
@@ -682,6 +1460,16 @@ func (mc *MeteringConfig) ToURI() string {
 			}
 		}
 
+	case storage.ProviderTypeGCS:
+		if mc.GCS != nil {
+			if mc.GCS.ProjectID != "" {
+				params.Set("project-id", mc.GCS.ProjectID)
+			}
+			if mc.GCS.CredentialsFile != "" {
+				params.Set("service-account", mc.GCS.CredentialsFile)
+			}
+		}
+
 	case storage.ProviderTypeLocalFS:
 		if mc.LocalFS != nil {
 			if !mc.LocalFS.CreateDirs {
@@ -690,6 +1478,39 @@ func (mc *MeteringConfig) ToURI() string {
 			if mc.LocalFS.Permissions != "" {
 				params.Set("permissions", mc.LocalFS.Permissions)
 			}
+			if mc.LocalFS.Fsync {
+				params.Set("fsync", "true")
+			}
+			if mc.LocalFS.Lock {
+				params.Set("lock", "true")
+			}
+			if mc.LocalFS.MaxBytes > 0 {
+				params.Set("max-bytes", strconv.FormatInt(mc.LocalFS.MaxBytes, 10))
+			}
+			if mc.LocalFS.QuotaPolicy != "" {
+				params.Set("quota-policy", mc.LocalFS.QuotaPolicy)
+			}
+		}
+
+	case storage.ProviderTypeSFTP:
+		if mc.SFTP != nil {
+			if mc.SFTP.PrivateKeyFile != "" {
+				params.Set("private-key-file", mc.SFTP.PrivateKeyFile)
+			}
+			if mc.SFTP.Passphrase != "" {
+				params.Set("passphrase", mc.SFTP.Passphrase)
+			}
+			if mc.SFTP.KnownHostsFile != "" {
+				params.Set("known-hosts-file", mc.SFTP.KnownHostsFile)
+			}
+			if mc.SFTP.InsecureIgnoreHostKey {
+				params.Set("insecure-ignore-host-key", "true")
+			}
+		}
+
+	default:
+		for key, value := range mc.Options {
+			params.Set(key, value)
 		}
 	}
 
@@ -3,12 +3,15 @@ package config
 import (
 	"encoding/json"
 	"os"
+	"regexp"
 	"testing"
 
 	"github.com/BurntSushi/toml"
 	"github.com/pingcap/metering_sdk/storage"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 	"gopkg.in/yaml.v3"
 )
 
@@ -112,6 +115,55 @@ func TestWithOverwriteExisting(t *testing.T) {
 	})
 }
 
+func TestIDValidators(t *testing.T) {
+	cfg := DefaultConfig()
+	assert.NoError(t, cfg.ValidateSelfID("tidb001"))
+	assert.Error(t, cfg.ValidateSelfID("tidb-001"), "dashes are rejected by default")
+	assert.NoError(t, cfg.ValidateClusterID("cluster-001"))
+	assert.Error(t, cfg.ValidateClusterID("cluster:001"))
+
+	cfg.WithSelfIDValidator(PatternIDValidator(regexp.MustCompile(`[a-z0-9-]+`))).
+		WithClusterIDValidator(func(id string) error { return nil })
+	assert.NoError(t, cfg.ValidateSelfID("tidb-001"))
+	assert.Error(t, cfg.ValidateSelfID("TiDB-001"), "patterns must match the whole ID")
+	assert.Error(t, cfg.ValidateSelfID(""))
+	assert.Error(t, cfg.ValidateSelfID("tidb/001"), "IDs are never empty or contain slashes")
+	assert.NoError(t, cfg.ValidateClusterID("cluster:001"))
+	assert.Error(t, cfg.ValidateClusterID("cluster/001"))
+	assert.Error(t, cfg.ValidateClusterID(".."), "IDs are never relative path segments")
+	assert.Error(t, cfg.ValidateSelfID("."))
+}
+
+func TestPatternIDValidator(t *testing.T) {
+	validate := PatternIDValidator(regexp.MustCompile(`a|ab`))
+	assert.NoError(t, validate("a"))
+	assert.NoError(t, validate("ab"), "an alternative spanning the ID matches even if an earlier one matches first")
+	assert.Error(t, validate("abc"))
+	assert.Error(t, validate("b"))
+
+	validate = PatternIDValidator(regexp.MustCompile(`^tidb\d+$`))
+	assert.NoError(t, validate("tidb001"), "anchored patterns are accepted as well")
+	assert.Error(t, validate("tidb001x"))
+}
+
+func TestValidateMetaClusterID(t *testing.T) {
+	cfg := DefaultConfig()
+	assert.NoError(t, cfg.ValidateMetaClusterID("cluster:001"), "metadata accepts any path segment by default")
+	assert.Error(t, cfg.ValidateMetaClusterID(""))
+	assert.Error(t, cfg.ValidateMetaClusterID("cluster/001"))
+	assert.Error(t, cfg.ValidateMetaClusterID(".."))
+	assert.Error(t, DefaultConfig().ValidateClusterID(".."))
+
+	cfg.WithClusterIDValidator(PatternIDValidator(regexp.MustCompile(`cluster\d+`)))
+	assert.NoError(t, cfg.ValidateMetaClusterID("cluster001"))
+	assert.Error(t, cfg.ValidateMetaClusterID("cluster:001"))
+
+	_, err := cfg.NewRecord().LogicalCluster("cluster-001").Build()
+	assert.Error(t, err, "records are checked with the configured validator")
+	_, err = cfg.NewRecord().LogicalCluster("cluster001").Build()
+	assert.NoError(t, err)
+}
+
 func TestMeteringConfig_ToProviderConfig(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -570,6 +622,50 @@ func TestNewFromURI(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "LocalFS URI with Windows drive",
+			uri:  "file:///C:/metering",
+			expected: &MeteringConfig{
+				Type: storage.ProviderTypeLocalFS,
+				LocalFS: &MeteringLocalFSConfig{
+					BasePath:   "C:/metering",
+					CreateDirs: true,
+				},
+			},
+		},
+		{
+			name: "LocalFS URI with Windows drive and backslashes",
+			uri:  "file:///C:\\metering\\data",
+			expected: &MeteringConfig{
+				Type: storage.ProviderTypeLocalFS,
+				LocalFS: &MeteringLocalFSConfig{
+					BasePath:   "C:\\metering\\data",
+					CreateDirs: true,
+				},
+			},
+		},
+		{
+			name: "LocalFS URI with Windows drive as host",
+			uri:  "file://d:/metering/data",
+			expected: &MeteringConfig{
+				Type: storage.ProviderTypeLocalFS,
+				LocalFS: &MeteringLocalFSConfig{
+					BasePath:   "d:/metering/data",
+					CreateDirs: true,
+				},
+			},
+		},
+		{
+			name: "LocalFS URI with Windows drive root",
+			uri:  "localfs:///C:/",
+			expected: &MeteringConfig{
+				Type: storage.ProviderTypeLocalFS,
+				LocalFS: &MeteringLocalFSConfig{
+					BasePath:   "C:/",
+					CreateDirs: true,
+				},
+			},
+		},
 		{
 			name: "S3 URI with custom endpoint",
 			uri:  "s3://bucket/data?region-id=us-west-2&endpoint=https://s3.custom.com",
@@ -1002,7 +1098,13 @@ func TestToURI_RoundTrip(t *testing.T) {
 		"oss://oss-bucket/logs?region-id=oss-ap-southeast-1&access-key=test",
 		"azure://my-container/data?account-name=acct&account-key=key&endpoint=https%3A%2F%2Facct.blob.core.windows.net",
 		"localfs:///data/storage?create-dirs=false&permissions=0755",
+		"localfs:///data/storage?fsync=true&lock=true",
+		"localfs:///data/storage?max-bytes=1073741824&quota-policy=evict-oldest",
+		"localfs:///C:/metering",
+		"localfs:///C:\\metering\\data",
 		"s3://test?region-id=us-west-2&endpoint=https%3A%2F%2Fs3.example.com&shared-pool-id=pool123",
+		"s3://my-bucket/data?accelerate=true&dual-stack=true&region-id=us-east-1",
+		"s3://my-bucket/data?access-point-arn=arn%3Aaws%3As3%3Aus-west-2%3A123456789012%3Aaccesspoint%2Fmetering&region-id=us-east-1",
 	}
 
 	for _, originalURI := range testURIs {
@@ -1055,3 +1157,217 @@ func TestToURI_RoundTrip(t *testing.T) {
 		})
 	}
 }
+
+func TestNewFromURI_RegisteredProvider(t *testing.T) {
+	storage.RegisterProvider("uritest", func(*storage.ProviderConfig) (storage.ObjectStorageProvider, error) {
+		return nil, nil
+	})
+
+	config, err := NewFromURI("uritest://my-bucket/data?region=r1&token=abc&shared-pool-id=pool1")
+	assert.NoError(t, err)
+	assert.Equal(t, storage.ProviderType("uritest"), config.Type)
+	assert.Equal(t, "my-bucket", config.Bucket)
+	assert.Equal(t, "data", config.Prefix)
+	assert.Equal(t, "r1", config.Region)
+	assert.Equal(t, "pool1", config.SharedPoolID)
+	assert.Equal(t, map[string]string{"token": "abc"}, config.Options)
+	assert.Equal(t, map[string]string{"token": "abc"}, config.ToProviderConfig().Options)
+
+	roundTrip, err := NewFromURI(config.ToURI())
+	assert.NoError(t, err)
+	assert.Equal(t, config, roundTrip)
+
+	_, err = NewFromURI("unregistered://my-bucket")
+	assert.Error(t, err)
+}
+
+func TestNewFromURI_CloudSchemes(t *testing.T) {
+	config, err := NewFromURI("gs://my-bucket/data?project-id=my-project&service-account=%2Fetc%2Fgcs%2Fkey.json")
+	assert.NoError(t, err)
+	assert.Equal(t, storage.ProviderTypeGCS, config.Type)
+	assert.Equal(t, "my-bucket", config.Bucket)
+	assert.Equal(t, "data", config.Prefix)
+	assert.Equal(t, &MeteringGCSConfig{ProjectID: "my-project", CredentialsFile: "/etc/gcs/key.json"}, config.GCS)
+	assert.Equal(t, &storage.GCSConfig{ProjectID: "my-project", CredentialsFile: "/etc/gcs/key.json"}, config.ToProviderConfig().GCS)
+	assert.Equal(t, "gs://my-bucket/data?project-id=my-project&service-account=%2Fetc%2Fgcs%2Fkey.json", config.ToURI())
+
+	alias, err := NewFromURI("gcs://my-bucket/data?project-id=my-project&credentials-file=%2Fetc%2Fgcs%2Fkey.json")
+	assert.NoError(t, err)
+	assert.Equal(t, config, alias)
+
+	config, err = NewFromURI("azblob://my-container/data?account-name=acct&sas-token=sv%3D2024")
+	assert.NoError(t, err)
+	assert.Equal(t, storage.ProviderTypeAzure, config.Type)
+	assert.Equal(t, &MeteringAzureConfig{AccountName: "acct", SASToken: "sv=2024"}, config.Azure)
+	roundTrip, err := NewFromURI(config.ToURI())
+	assert.NoError(t, err)
+	assert.Equal(t, config, roundTrip)
+}
+
+func TestRegisterURIScheme(t *testing.T) {
+	RegisterURIScheme("minio-test", storage.ProviderTypeS3)
+
+	config, err := NewFromURI("minio-test://my-bucket/data?endpoint=http%3A%2F%2Fminio%3A9000&force-path-style=true")
+	assert.NoError(t, err)
+	assert.Equal(t, storage.ProviderTypeS3, config.Type)
+	assert.Equal(t, "http://minio:9000", config.Endpoint)
+	assert.True(t, config.AWS.S3ForcePathStyle)
+
+	assert.Panics(t, func() { RegisterURIScheme("minio-test", storage.ProviderTypeS3) }, "duplicate registration")
+	assert.Panics(t, func() { RegisterURIScheme("gs", storage.ProviderTypeS3) }, "built-in scheme")
+	assert.Panics(t, func() { RegisterURIScheme("", storage.ProviderTypeS3) }, "empty scheme")
+}
+
+func TestNewFromURI_Memory(t *testing.T) {
+	config, err := NewFromURI("memory://?shared-pool-id=pool1&unknown=value")
+	assert.NoError(t, err)
+	assert.Equal(t, storage.ProviderTypeMemory, config.Type)
+	assert.Equal(t, "pool1", config.SharedPoolID)
+	assert.Empty(t, config.Options)
+
+	roundTrip, err := NewFromURI(config.ToURI())
+	assert.NoError(t, err)
+	assert.Equal(t, config, roundTrip)
+}
+
+func TestNewFromURI_RateLimit(t *testing.T) {
+	config, err := NewFromURI("s3://my-bucket/data?region=us-west-2&requests-per-second=50.5&request-burst=100&bytes-per-second=1048576")
+	assert.NoError(t, err)
+	assert.Equal(t, &MeteringRateLimitConfig{RequestsPerSecond: 50.5, Burst: 100, BytesPerSecond: 1048576}, config.RateLimit)
+	assert.Equal(t, &storage.RateLimitConfig{RequestsPerSecond: 50.5, Burst: 100, BytesPerSecond: 1048576},
+		config.ToProviderConfig().RateLimit)
+
+	roundTrip, err := NewFromURI(config.ToURI())
+	assert.NoError(t, err)
+	assert.Equal(t, config, roundTrip)
+
+	config, err = NewFromURI("s3://my-bucket/data")
+	assert.NoError(t, err)
+	assert.Nil(t, config.RateLimit)
+
+	_, err = NewFromURI("s3://my-bucket/data?requests-per-second=fast")
+	assert.Error(t, err)
+}
+
+func TestNewFromEnv(t *testing.T) {
+	t.Setenv("METERING_TYPE", "s3")
+	t.Setenv("METERING_BUCKET", "my-bucket")
+	t.Setenv("METERING_PREFIX", "data")
+	t.Setenv("METERING_REGION_ID", "us-west-2")
+	t.Setenv("METERING_SHARED_POOL_ID", "pool1")
+	t.Setenv("METERING_AWS_ROLE_ARN", "arn:aws:iam::123456789012:role/metering")
+	t.Setenv("METERING_S3_FORCE_PATH_STYLE", "true")
+	t.Setenv("METERING_REQUESTS_PER_SECOND", "50")
+	t.Setenv("METERING_ACCESS_KEY", "unqualified")
+	t.Setenv("METERING_AWS_ACCESS_KEY", "qualified")
+	t.Setenv("METERING_OSS_SECRET_ACCESS_KEY", "other-provider")
+
+	config, err := NewFromEnv("")
+	assert.NoError(t, err)
+	assert.Equal(t, storage.ProviderTypeS3, config.Type)
+	assert.Equal(t, "my-bucket", config.Bucket)
+	assert.Equal(t, "data", config.Prefix)
+	assert.Equal(t, "us-west-2", config.Region)
+	assert.Equal(t, "pool1", config.SharedPoolID)
+	assert.Equal(t, &MeteringAWSConfig{
+		AssumeRoleARN:    "arn:aws:iam::123456789012:role/metering",
+		S3ForcePathStyle: true,
+		AccessKey:        "qualified",
+	}, config.AWS)
+	assert.Equal(t, &MeteringRateLimitConfig{RequestsPerSecond: 50}, config.RateLimit)
+
+	// Custom prefix, localfs base path
+	t.Setenv("BILLING_TYPE", "localfs")
+	t.Setenv("BILLING_BASE_PATH", "/data/metering")
+	t.Setenv("BILLING_LOCALFS_CREATE_DIRS", "false")
+	config, err = NewFromEnv("BILLING")
+	assert.NoError(t, err)
+	assert.Equal(t, &MeteringLocalFSConfig{BasePath: "/data/metering"}, config.LocalFS)
+
+	_, err = NewFromEnv("UNSET")
+	assert.ErrorContains(t, err, "UNSET_TYPE is required")
+
+	t.Setenv("BILLING_TYPE", "ftp")
+	_, err = NewFromEnv("BILLING")
+	assert.ErrorContains(t, err, "unsupported URI scheme")
+}
+
+func TestNewFromURI_ServerSideEncryption(t *testing.T) {
+	config, err := NewFromURI("s3://my-bucket/data?region=us-west-2&sse=aws:kms&sse-kms-key-id=key-1&sse-bucket-key=true")
+	assert.NoError(t, err)
+	assert.Equal(t, &MeteringAWSConfig{ServerSideEncryption: "aws:kms", SSEKMSKeyID: "key-1", SSEBucketKeyEnabled: true}, config.AWS)
+	providerConfig := config.ToProviderConfig()
+	assert.Equal(t, "aws:kms", providerConfig.AWS.ServerSideEncryption)
+	assert.Equal(t, "key-1", providerConfig.AWS.SSEKMSKeyID)
+	assert.True(t, providerConfig.AWS.SSEBucketKeyEnabled)
+
+	roundTrip, err := NewFromURI(config.ToURI())
+	assert.NoError(t, err)
+	assert.Equal(t, config, roundTrip)
+
+	config, err = NewFromURI("oss://my-bucket/data?region=oss-cn-hangzhou&sse=KMS&sse-kms-key-id=key-2")
+	assert.NoError(t, err)
+	assert.Equal(t, &MeteringOSSConfig{ServerSideEncryption: "KMS", SSEKMSKeyID: "key-2"}, config.OSS)
+	assert.Equal(t, "KMS", config.ToProviderConfig().OSS.ServerSideEncryption)
+
+	roundTrip, err = NewFromURI(config.ToURI())
+	assert.NoError(t, err)
+	assert.Equal(t, config, roundTrip)
+}
+
+func TestNewFromURI_MinIO(t *testing.T) {
+	config, err := NewFromURI("s3://metering/data?endpoint=http://minio:9000&minio=true&access-key=minioadmin&secret-access-key=minioadmin")
+	assert.NoError(t, err)
+	assert.True(t, config.AWS.MinIO)
+	assert.True(t, config.ToProviderConfig().AWS.MinIO)
+
+	roundTrip, err := NewFromURI(config.ToURI())
+	assert.NoError(t, err)
+	assert.Equal(t, config, roundTrip)
+}
+
+func TestNewFromURI_SFTP(t *testing.T) {
+	config, err := NewFromURI("sftp://metering@jump-host:2222/var/metering?private-key-file=/etc/metering/id_ed25519&known-hosts-file=/etc/metering/known_hosts&prefix=tidb")
+	assert.NoError(t, err)
+	assert.Equal(t, storage.ProviderTypeSFTP, config.Type)
+	assert.Equal(t, "tidb", config.Prefix)
+	assert.Equal(t, &MeteringSFTPConfig{
+		Host:           "jump-host:2222",
+		User:           "metering",
+		BasePath:       "/var/metering",
+		PrivateKeyFile: "/etc/metering/id_ed25519",
+		KnownHostsFile: "/etc/metering/known_hosts",
+	}, config.SFTP)
+	assert.Equal(t, "/var/metering", config.ToProviderConfig().SFTP.BasePath)
+
+	roundTrip, err := NewFromURI(config.ToURI())
+	assert.NoError(t, err)
+	assert.Equal(t, config, roundTrip)
+
+	// Relative base paths round-trip through the base-path parameter
+	config = NewMeteringConfig().WithSFTP("jump-host", "metering", "/etc/metering/id_ed25519", "drop/metering")
+	roundTrip, err = NewFromURI(config.ToURI())
+	assert.NoError(t, err)
+	assert.Equal(t, config, roundTrip)
+}
+
+func TestProviderLogger(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	cfg := DefaultConfig().WithLogger(zap.New(core))
+
+	memory := storage.NewMemoryProvider()
+	cfg.ProviderLogger(memory).Info("memory")
+	localFS, err := storage.NewObjectStorageProvider(&storage.ProviderConfig{
+		Type:    storage.ProviderTypeLocalFS,
+		LocalFS: &storage.LocalFSConfig{BasePath: t.TempDir()},
+	})
+	require.NoError(t, err)
+	cfg.ProviderLogger(localFS).Info("localfs")
+	cfg.ProviderLogger(nil).Info("unknown")
+
+	entries := logs.All()
+	require.Len(t, entries, 3)
+	assert.Equal(t, map[string]interface{}{"provider": "memory"}, entries[0].ContextMap())
+	assert.Equal(t, map[string]interface{}{"provider": "localfs"}, entries[1].ContextMap())
+	assert.Empty(t, entries[2].ContextMap())
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/pingcap/metering_sdk/storage"
+)
+
+// DefaultEnvPrefix default prefix of the environment variables read by NewFromEnv
+const DefaultEnvPrefix = "METERING"
+
+// NewFromEnv creates a new MeteringConfig from environment variables, mirroring NewFromURI.
+// {prefix}_TYPE selects the provider and accepts the URI schemes, {prefix}_BUCKET sets the bucket or
+// container and {prefix}_BASE_PATH the localfs base path. Every URI parameter is read from its
+// upper-case name with underscores, e.g. METERING_REGION_ID or METERING_ASSUME_ROLE_ARN.
+// Provider-specific parameters may be qualified with the provider, e.g. METERING_AWS_ROLE_ARN,
+// METERING_OSS_ACCESS_KEY or METERING_AZURE_ACCOUNT_KEY, and take precedence over unqualified ones.
+// prefix defaults to DefaultEnvPrefix.
+func NewFromEnv(prefix string) (*MeteringConfig, error) {
+	if prefix == "" {
+		prefix = DefaultEnvPrefix
+	}
+	prefix = strings.TrimSuffix(prefix, "_") + "_"
+
+	providerType := os.Getenv(prefix + "TYPE")
+	if providerType == "" {
+		return nil, fmt.Errorf("environment variable %sTYPE is required", prefix)
+	}
+	sections := envSections(storage.ProviderType(strings.ToLower(providerType)))
+
+	uri := url.URL{
+		Scheme: strings.ToLower(providerType),
+		Host:   os.Getenv(prefix + "BUCKET"),
+		Path:   os.Getenv(prefix + "BASE_PATH"),
+	}
+	params := make(url.Values)
+	qualified := make(url.Values)
+	for _, env := range os.Environ() {
+		name, value, _ := strings.Cut(env, "=")
+		name, ok := strings.CutPrefix(name, prefix)
+		if !ok || value == "" {
+			continue
+		}
+		switch name {
+		case "TYPE", "BUCKET", "BASE_PATH":
+			continue
+		}
+
+		target := params
+		for _, section := range sections {
+			if param, ok := strings.CutPrefix(name, section+"_"); ok {
+				name, target = param, qualified
+				break
+			}
+		}
+		target.Set(strings.ReplaceAll(strings.ToLower(name), "_", "-"), value)
+	}
+	for key := range qualified {
+		params.Set(key, qualified.Get(key))
+	}
+	uri.RawQuery = params.Encode()
+
+	config, err := NewFromURI(uri.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s environment variables: %w", strings.TrimSuffix(prefix, "_"), err)
+	}
+	return config, nil
+}
+
+// envSections returns the names provider-specific environment variables may be qualified with
+func envSections(providerType storage.ProviderType) []string {
+	switch providerType {
+	case storage.ProviderTypeS3:
+		return []string{"AWS", "S3"}
+	case storage.ProviderTypeAzure, "azblob":
+		return []string{"AZURE"}
+	case storage.ProviderTypeLocalFS, "file":
+		return []string{"LOCALFS"}
+	default:
+		return []string{strings.ToUpper(string(providerType))}
+	}
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/metering_sdk/storage"
+)
+
+// builtinSchemes URI schemes understood by NewFromURI, by provider type
+var builtinSchemes = map[string]storage.ProviderType{
+	"s3":      storage.ProviderTypeS3,
+	"oss":     storage.ProviderTypeOSS,
+	"gs":      storage.ProviderTypeGCS,
+	"gcs":     storage.ProviderTypeGCS,
+	"azure":   storage.ProviderTypeAzure,
+	"azblob":  storage.ProviderTypeAzure,
+	"localfs": storage.ProviderTypeLocalFS,
+	"file":    storage.ProviderTypeLocalFS,
+	"sftp":    storage.ProviderTypeSFTP,
+	"memory":  storage.ProviderTypeMemory,
+}
+
+var (
+	schemesMu sync.RWMutex
+	schemes   = make(map[string]storage.ProviderType)
+)
+
+// RegisterURIScheme makes NewFromURI map scheme to providerType, e.g. "minio" to storage.ProviderTypeS3
+// so S3-compatible stores get a scheme of their own. Providers registered with storage.RegisterProvider
+// don't need it, their type is their scheme. It is meant to be called from init functions and panics
+// if scheme is empty, built in or already registered.
+func RegisterURIScheme(scheme string, providerType storage.ProviderType) {
+	schemesMu.Lock()
+	defer schemesMu.Unlock()
+
+	scheme = strings.ToLower(scheme)
+	if scheme == "" || providerType == "" {
+		panic("config: RegisterURIScheme requires a scheme and a provider type")
+	}
+	if _, ok := builtinSchemes[scheme]; ok {
+		panic(fmt.Sprintf("config: URI scheme %s is built in", scheme))
+	}
+	if _, exists := schemes[scheme]; exists {
+		panic(fmt.Sprintf("config: URI scheme %s registered twice", scheme))
+	}
+	schemes[scheme] = providerType
+}
+
+// providerTypeOfScheme returns the provider type of a URI scheme
+func providerTypeOfScheme(scheme string) (storage.ProviderType, error) {
+	lower := strings.ToLower(scheme)
+	if providerType, ok := builtinSchemes[lower]; ok {
+		return providerType, nil
+	}
+	schemesMu.RLock()
+	providerType, ok := schemes[lower]
+	schemesMu.RUnlock()
+	if ok {
+		return providerType, nil
+	}
+	if storage.IsRegisteredProvider(storage.ProviderType(scheme)) {
+		return storage.ProviderType(scheme), nil
+	}
+	return "", fmt.Errorf("unsupported URI scheme: %s", scheme)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/pingcap/metering_sdk/config"
+	meteringreader "github.com/pingcap/metering_sdk/reader/metering"
+	"github.com/pingcap/metering_sdk/schema"
+	"github.com/pingcap/metering_sdk/storage"
+)
+
+// Scans a time range of metering data and prints the inferred schema report as JSON.
+//
+//	go run ./examples/infer_schema -uri "s3://my-bucket/prefix?region-id=us-east-1" -start 1755850380 -end 1755853980
+func main() {
+	uri := flag.String("uri", "", "storage URI, e.g. s3://bucket/prefix?region-id=us-east-1")
+	start := flag.Int64("start", time.Now().Add(-time.Hour).Unix()/60*60, "start timestamp (minute aligned)")
+	end := flag.Int64("end", time.Now().Unix()/60*60, "end timestamp (minute aligned)")
+	category := flag.String("category", "", "only scan this category")
+	flag.Parse()
+
+	if *uri == "" {
+		log.Fatal("-uri is required")
+	}
+	meteringConfig, err := config.NewFromURI(*uri)
+	if err != nil {
+		log.Fatalf("Failed to parse URI: %v", err)
+	}
+	provider, err := storage.NewObjectStorageProvider(meteringConfig.ToProviderConfig())
+	if err != nil {
+		log.Fatalf("Failed to create storage provider: %v", err)
+	}
+
+	reader := meteringreader.NewMeteringReader(provider, config.DefaultConfig())
+	defer reader.Close()
+
+	report, err := reader.InferSchemas(context.Background(), meteringreader.RecordQuery{
+		TimeRange: meteringreader.TimeRange{Start: *start, End: *end},
+		Category:  *category,
+	})
+	if err != nil {
+		log.Fatalf("Failed to infer schemas: %v", err)
+	}
+
+	schemas := make([]*schema.Schema, 0, len(report.Categories))
+	for _, c := range report.Categories {
+		schemas = append(schemas, c.Schema())
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(map[string]interface{}{
+		"report":  report,
+		"schemas": schemas,
+	}); err != nil {
+		log.Fatalf("Failed to encode report: %v", err)
+	}
+}
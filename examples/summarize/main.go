@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/pingcap/metering_sdk/aggregator"
+	"github.com/pingcap/metering_sdk/config"
+	meteringreader "github.com/pingcap/metering_sdk/reader/metering"
+	"github.com/pingcap/metering_sdk/storage"
+)
+
+// Sums the usage of every logical cluster over a time range and prints the totals as JSON.
+//
+//	go run ./examples/summarize -uri "s3://my-bucket/prefix?region-id=us-east-1" -start 1755850380 -end 1755853980
+func main() {
+	uri := flag.String("uri", "", "storage URI, e.g. s3://bucket/prefix?region-id=us-east-1")
+	start := flag.Int64("start", time.Now().Add(-time.Hour).Unix()/60*60, "start timestamp (minute aligned)")
+	end := flag.Int64("end", time.Now().Unix()/60*60, "end timestamp (minute aligned)")
+	category := flag.String("category", "", "only summarize this category")
+	flag.Parse()
+
+	if *uri == "" {
+		log.Fatal("-uri is required")
+	}
+	meteringConfig, err := config.NewFromURI(*uri)
+	if err != nil {
+		log.Fatalf("Failed to parse URI: %v", err)
+	}
+	provider, err := storage.NewObjectStorageProvider(meteringConfig.ToProviderConfig())
+	if err != nil {
+		log.Fatalf("Failed to create storage provider: %v", err)
+	}
+
+	reader := meteringreader.NewMeteringReader(provider, config.DefaultConfig())
+	defer reader.Close()
+
+	summary, err := aggregator.SummarizeByLogicalCluster(context.Background(), reader,
+		meteringreader.TimeRange{Start: *start, End: *end},
+		aggregator.WithSummaryCategory(*category),
+	)
+	if err != nil {
+		log.Fatalf("Failed to summarize usage: %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(summary); err != nil {
+		log.Fatalf("Failed to encode summary: %v", err)
+	}
+}
@@ -108,54 +108,54 @@ func main() {
 	// Write multiple metering data entries
 	meteringDataList := []*common.MeteringData{
 		{
-			SelfID:    "tidbserver01",       // Remove dash to pass validation
+			SelfID:    "tidbserver01",
 			Timestamp: now.Unix() / 60 * 60, // Ensure minute-level timestamp
 			Category:  "tidb-server",
 			Data: []map[string]interface{}{
-				{
-					"logical_cluster_id": "lc-prod-001",
-					"compute_seconds":    &common.MeteringValue{Value: 3600, Unit: "seconds"},
-					"memory_mb":          &common.MeteringValue{Value: 4096, Unit: "MB"},
-					"storage_gb":         &common.MeteringValue{Value: 100, Unit: "GB"},
-				},
-				{
-					"logical_cluster_id": "lc-prod-002",
-					"compute_seconds":    &common.MeteringValue{Value: 7200, Unit: "seconds"},
-					"memory_mb":          &common.MeteringValue{Value: 8192, Unit: "MB"},
-					"storage_gb":         &common.MeteringValue{Value: 250, Unit: "GB"},
-				},
+				common.NewRecord().
+					LogicalCluster("lc-prod-001").
+					Value("compute_seconds", 3600, "seconds").
+					Value("memory_mb", 4096, "MB").
+					Value("storage_gb", 100, "GB").
+					MustBuild(),
+				common.NewRecord().
+					LogicalCluster("lc-prod-002").
+					Value("compute_seconds", 7200, "seconds").
+					Value("memory_mb", 8192, "MB").
+					Value("storage_gb", 250, "GB").
+					MustBuild(),
 			},
 		},
 		{
-			SelfID:    "tikvserver01",       // Remove dash to pass validation
+			SelfID:    "tikvserver01",
 			Timestamp: now.Unix() / 60 * 60, // Ensure minute-level timestamp
 			Category:  "tikv-server",
 			Data: []map[string]interface{}{
-				{
-					"logical_cluster_id":  "lc-test-001",
-					"storage_read_bytes":  &common.MeteringValue{Value: 1073741824, Unit: "bytes"}, // 1GB
-					"storage_write_bytes": &common.MeteringValue{Value: 536870912, Unit: "bytes"},  // 512MB
-					"cpu_usage_percent":   &common.MeteringValue{Value: 755, Unit: "permille"},     // 75.5% as 755 permille
-				},
+				common.NewRecord().
+					LogicalCluster("lc-test-001").
+					Value("storage_read_bytes", 1073741824, "bytes"). // 1GB
+					Value("storage_write_bytes", 536870912, "bytes"). // 512MB
+					Float("cpu_usage_percent", 75.5, 1, "percent").
+					MustBuild(),
 			},
 		},
 		{
-			SelfID:    "pdserver01",         // Remove dash to pass validation
+			SelfID:    "pdserver01",
 			Timestamp: now.Unix() / 60 * 60, // Ensure minute-level timestamp
 			Category:  "pd-server",
 			Data: []map[string]interface{}{
-				{
-					"logical_cluster_id": "lc-staging-001",
-					"request_count":      &common.MeteringValue{Value: 50000, Unit: "count"},
-					"response_time_ms":   &common.MeteringValue{Value: 258, Unit: "deciseconds"}, // 25.8ms as 258 deciseconds
-					"error_rate":         &common.MeteringValue{Value: 200, Unit: "per_million"}, // 0.02% as 200 per million
-				},
-				{
-					"logical_cluster_id": "lc-staging-002",
-					"request_count":      &common.MeteringValue{Value: 30000, Unit: "count"},
-					"response_time_ms":   &common.MeteringValue{Value: 184, Unit: "deciseconds"}, // 18.4ms as 184 deciseconds
-					"error_rate":         &common.MeteringValue{Value: 100, Unit: "per_million"}, // 0.01% as 100 per million
-				},
+				common.NewRecord().
+					LogicalCluster("lc-staging-001").
+					Value("request_count", 50000, "count").
+					Float("response_time_ms", 25.8, 1, "ms").
+					Float("error_rate", 0.02, 2, "percent").
+					MustBuild(),
+				common.NewRecord().
+					LogicalCluster("lc-staging-002").
+					Value("request_count", 30000, "count").
+					Float("response_time_ms", 18.4, 1, "ms").
+					Float("error_rate", 0.01, 2, "percent").
+					MustBuild(),
 			},
 		},
 	}
@@ -176,22 +176,22 @@ func main() {
 	defer meteringWriter2.Close()
 
 	data := common.MeteringData{
-		SelfID:    "tidbserver01xvp",    // Remove dash to pass validation
+		SelfID:    "tidbserver01xvp",
 		Timestamp: now.Unix() / 60 * 60, // Ensure minute-level timestamp
 		Category:  "tidb-server",
 		Data: []map[string]interface{}{
-			{
-				"logical_cluster_id": "lc-prod-001",
-				"compute_seconds":    &common.MeteringValue{Value: 3600, Unit: "seconds"},
-				"memory_mb":          &common.MeteringValue{Value: 4096, Unit: "MB"},
-				"storage_gb":         &common.MeteringValue{Value: 100, Unit: "GB"},
-			},
-			{
-				"logical_cluster_id": "lc-prod-002",
-				"compute_seconds":    &common.MeteringValue{Value: 7200, Unit: "seconds"},
-				"memory_mb":          &common.MeteringValue{Value: 8192, Unit: "MB"},
-				"storage_gb":         &common.MeteringValue{Value: 250, Unit: "GB"},
-			},
+			common.NewRecord().
+				LogicalCluster("lc-prod-001").
+				Value("compute_seconds", 3600, "seconds").
+				Value("memory_mb", 4096, "MB").
+				Value("storage_gb", 100, "GB").
+				MustBuild(),
+			common.NewRecord().
+				LogicalCluster("lc-prod-002").
+				Value("compute_seconds", 7200, "seconds").
+				Value("memory_mb", 8192, "MB").
+				Value("storage_gb", 250, "GB").
+				MustBuild(),
 		}}
 	// write twice
 	if err := meteringWriter2.Write(ctx, &data); err != nil {
@@ -56,23 +56,23 @@ func main() {
 	for i := 0; i < 20; i++ {
 		// #nosec G115 - i is bounded by loop condition (0-19), safe for uint64 conversion
 		idx := uint64(i)
-		logicalCluster := map[string]interface{}{
-			"logical_cluster_id": fmt.Sprintf("lc-large-%03d", i+1),
-			"compute_seconds":    &common.MeteringValue{Value: uint64(3600) + idx*100, Unit: "seconds"},
-			"memory_mb":          &common.MeteringValue{Value: uint64(4096) + idx*512, Unit: "MB"},
-			"storage_gb":         &common.MeteringValue{Value: uint64(100) + idx*10, Unit: "GB"},
-			"network_in_bytes":   &common.MeteringValue{Value: uint64(1073741824) + idx*104857600, Unit: "bytes"},
-			"network_out_bytes":  &common.MeteringValue{Value: uint64(536870912) + idx*52428800, Unit: "bytes"},
-			"disk_read_iops":     &common.MeteringValue{Value: uint64(1000) + idx*50, Unit: "iops"},
-			"disk_write_iops":    &common.MeteringValue{Value: uint64(800) + idx*40, Unit: "iops"},
-			"cpu_utilization":    &common.MeteringValue{Value: uint64(750) + idx*10, Unit: "permille"}, // 75% + variations
-		}
+		logicalCluster := common.NewRecord().
+			LogicalCluster(fmt.Sprintf("lc-large-%03d", i+1)).
+			Value("compute_seconds", 3600+idx*100, "seconds").
+			Value("memory_mb", 4096+idx*512, "MB").
+			Value("storage_gb", 100+idx*10, "GB").
+			Value("network_in_bytes", 1073741824+idx*104857600, "bytes").
+			Value("network_out_bytes", 536870912+idx*52428800, "bytes").
+			Value("disk_read_iops", 1000+idx*50, "iops").
+			Value("disk_write_iops", 800+idx*40, "iops").
+			Value("cpu_utilization", 750+idx*10, "permille"). // 75% + variations
+			MustBuild()
 		largeDataSet = append(largeDataSet, logicalCluster)
 	}
 
 	// Write large metering data that should be paginated
 	meteringData := &common.MeteringData{
-		SelfID:    "tidbserverlarge01",  // No dash to pass validation
+		SelfID:    "tidbserverlarge01",
 		Timestamp: now.Unix() / 60 * 60, // Ensure minute-level timestamp
 		Category:  "tidb-server",
 		Data:      largeDataSet,
@@ -94,19 +94,19 @@ func main() {
 	for i := 0; i < 15; i++ {
 		// #nosec G115 - i is bounded by loop condition (0-14), safe for uint64 conversion
 		idx := uint64(i)
-		logicalCluster := map[string]interface{}{
-			"logical_cluster_id":  fmt.Sprintf("lc-medium-%03d", i+1),
-			"storage_read_bytes":  &common.MeteringValue{Value: uint64(1073741824) + idx*134217728, Unit: "bytes"},
-			"storage_write_bytes": &common.MeteringValue{Value: uint64(536870912) + idx*67108864, Unit: "bytes"},
-			"cpu_usage_percent":   &common.MeteringValue{Value: uint64(600) + idx*20, Unit: "permille"},
-			"request_count":       &common.MeteringValue{Value: uint64(10000) + idx*500, Unit: "count"},
-			"error_count":         &common.MeteringValue{Value: uint64(10) + idx, Unit: "count"},
-		}
+		logicalCluster := common.NewRecord().
+			LogicalCluster(fmt.Sprintf("lc-medium-%03d", i+1)).
+			Value("storage_read_bytes", 1073741824+idx*134217728, "bytes").
+			Value("storage_write_bytes", 536870912+idx*67108864, "bytes").
+			Value("cpu_usage_percent", 600+idx*20, "permille").
+			Value("request_count", 10000+idx*500, "count").
+			Value("error_count", 10+idx, "count").
+			MustBuild()
 		secondDataSet = append(secondDataSet, logicalCluster)
 	}
 
 	secondMeteringData := &common.MeteringData{
-		SelfID:    "tikvservermedium01", // No dash to pass validation
+		SelfID:    "tikvservermedium01",
 		Timestamp: now.Unix() / 60 * 60, // Same timestamp
 		Category:  "tikv-server",
 		Data:      secondDataSet,
@@ -0,0 +1,351 @@
+// Package sqlexporter exports metering data into a relational table, e.g. in MySQL or TiDB, so billing
+// pipelines can query it with SQL.
+package sqlexporter
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/pingcap/metering_sdk/config"
+	meteringreader "github.com/pingcap/metering_sdk/reader/metering"
+	"github.com/pingcap/metering_sdk/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultTable default name of the exported table
+	DefaultTable = "metering_values"
+	// DefaultBatchSize default number of rows per INSERT statement
+	DefaultBatchSize = 500
+)
+
+// columns of the exported table, in insertion order. row_key, the primary key, hashes the identifying
+// columns, which are too long together for the 3072 bytes key limit of InnoDB and TiDB
+var columns = []string{"row_key", "timestamp", "category", "shared_pool_id", "self_id", "part", "logical_cluster_id", "metric", "value", "value_float", "unit"}
+
+// updatedColumns columns overwritten when a row is exported again. part is not identifying, an entry
+// moves to another part when its file is written again with a different page size
+var updatedColumns = []string{"part", "value", "value_float", "unit"}
+
+// Execer executes SQL statements, it is implemented by *sql.DB, *sql.Tx and *sql.Conn
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Config exporter configuration
+type Config struct {
+	// Table name of the exported table, optionally qualified by its database. Default DefaultTable
+	Table string
+	// BatchSize maximum number of rows per INSERT statement. Default DefaultBatchSize
+	BatchSize int
+	// Category only exports this category when set
+	Category string
+	// Metrics maps Data entry fields to the metric names stored in the table. When set, only the listed
+	// fields are exported; otherwise every MeteringValue field is exported under its own name. Every
+	// field must map to a distinct metric, see Validate
+	Metrics map[string]string
+}
+
+// Validate checks the metric mapping is one-to-one, fields mapped to the same metric would overwrite
+// each other's rows
+func (c *Config) Validate() error {
+	fields := make(map[string]string, len(c.Metrics))
+	for _, field := range sortedKeys(c.Metrics) {
+		metric := c.Metrics[field]
+		if metric == "" {
+			return fmt.Errorf("field %s is mapped to an empty metric name", field)
+		}
+		if other, ok := fields[metric]; ok {
+			return fmt.Errorf("fields %s and %s are both mapped to metric %s", other, field, metric)
+		}
+		fields[metric] = field
+	}
+	return nil
+}
+
+// ExportResult summary of an export
+type ExportResult struct {
+	Files   int   `json:"files"`   // metering files read
+	Records int   `json:"records"` // logical cluster entries read
+	Rows    int64 `json:"rows"`    // rows written, one per exported metering value
+}
+
+// Exporter streams metering files into a SQL table with one row per metering value. Rows are keyed by
+// timestamp, category, shared pool, component, logical cluster and metric, and inserted with ON DUPLICATE KEY UPDATE, so re-exporting a time range after a
+// failure or a late write is safe. Entries of a component for the same logical cluster, e.g. repeated
+// across parts, are summed into one row.
+type Exporter struct {
+	db             Execer
+	reader         *meteringreader.MeteringReader
+	exporterConfig *Config
+	logger         *zap.Logger
+}
+
+// NewExporter creates a new exporter reading metering data from provider and writing it through db,
+// e.g. a *sql.DB opened with a MySQL driver
+func NewExporter(provider storage.ObjectStorageProvider, db Execer, cfg *config.Config, exporterCfg *Config) *Exporter {
+	if cfg == nil {
+		cfg = config.DefaultConfig()
+	}
+	if exporterCfg == nil {
+		exporterCfg = &Config{}
+	}
+	if exporterCfg.Table == "" {
+		exporterCfg.Table = DefaultTable
+	}
+	if exporterCfg.BatchSize <= 0 {
+		exporterCfg.BatchSize = DefaultBatchSize
+	}
+
+	return &Exporter{
+		db:             db,
+		reader:         meteringreader.NewMeteringReader(provider, cfg),
+		exporterConfig: exporterCfg,
+		logger:         cfg.GetLogger(),
+	}
+}
+
+// CreateTableStatement returns the MySQL/TiDB DDL creating the exported table if it doesn't exist
+func (e *Exporter) CreateTableStatement() string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+  row_key BINARY(32) NOT NULL,
+  timestamp BIGINT NOT NULL,
+  category VARCHAR(255) NOT NULL,
+  shared_pool_id VARCHAR(255) NOT NULL,
+  self_id VARCHAR(255) NOT NULL,
+  part INT NOT NULL,
+  logical_cluster_id VARCHAR(255) NOT NULL,
+  metric VARCHAR(255) NOT NULL,
+  value BIGINT UNSIGNED NOT NULL,
+  value_float DOUBLE NULL,
+  unit VARCHAR(64) NOT NULL,
+  PRIMARY KEY (row_key)
+)`, quoteIdentifier(e.exporterConfig.Table))
+}
+
+// CreateTable creates the exported table if it doesn't exist
+func (e *Exporter) CreateTable(ctx context.Context) error {
+	if _, err := e.db.ExecContext(ctx, e.CreateTableStatement()); err != nil {
+		return fmt.Errorf("failed to create table %s: %w", e.exporterConfig.Table, err)
+	}
+	return nil
+}
+
+// Export writes the metering values of every file in the time range to the table, in batches of
+// BatchSize rows. Fields that are not metering values are skipped. Values sharing a row are summed, they
+// fail the export if their units differ. Rows are merged per timestamp and category, whose values are
+// held in memory until the next one is read. Batches already written are kept when an error is returned.
+func (e *Exporter) Export(ctx context.Context, tr meteringreader.TimeRange) (*ExportResult, error) {
+	if err := e.exporterConfig.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid exporter config: %w", err)
+	}
+	result := &ExportResult{}
+	batch := make([]any, 0, e.exporterConfig.BatchSize*len(columns))
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		rows := len(batch) / len(columns)
+		if _, err := e.db.ExecContext(ctx, e.insertStatement(rows), batch...); err != nil {
+			return fmt.Errorf("failed to insert %d rows: %w", rows, err)
+		}
+		result.Rows += int64(rows)
+		batch = batch[:0]
+		return nil
+	}
+
+	// Rows are only batched once their timestamp and category, which every row key includes, are done
+	pending := newPendingRows()
+	batchPending := func() error {
+		for _, r := range pending.rows {
+			var valueFloat any
+			if r.value.IsFloat() {
+				valueFloat = *r.value.ValueFloat
+			}
+			// The value is sent as a string, database/sql rejects uint64 arguments with the high bit set
+			batch = append(batch, r.key, r.file.Timestamp, r.file.Category, r.file.SharedPoolID, r.file.SelfID,
+				r.file.Part, r.logicalClusterID, r.metric, strconv.FormatUint(r.value.Value, 10), valueFloat, r.value.Unit)
+			if len(batch) == cap(batch) {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		pending = newPendingRows()
+		return nil
+	}
+
+	var lastFile string
+	query := meteringreader.RecordQuery{TimeRange: tr, Category: e.exporterConfig.Category}
+	for rec, err := range e.reader.Records(ctx, query) {
+		if err != nil {
+			return result, err
+		}
+		result.Records++
+		if rec.File.Path != lastFile {
+			result.Files++
+			lastFile = rec.File.Path
+		}
+		if len(pending.rows) > 0 && (rec.File.Timestamp != pending.timestamp || rec.File.Category != pending.category) {
+			if err := batchPending(); err != nil {
+				return result, err
+			}
+		}
+		pending.timestamp, pending.category = rec.File.Timestamp, rec.File.Category
+
+		logicalClusterID, _ := rec.Data[common.LogicalClusterIDField].(string)
+		for _, field := range sortedKeys(rec.Data) {
+			metric, ok := e.metricName(field)
+			if !ok {
+				continue
+			}
+			value, ok := common.ParseMeteringValue(rec.Data[field])
+			if !ok {
+				continue
+			}
+			if err := pending.add(rec.File, logicalClusterID, metric, value); err != nil {
+				return result, err
+			}
+		}
+	}
+	if err := batchPending(); err != nil {
+		return result, err
+	}
+	if err := flush(); err != nil {
+		return result, err
+	}
+
+	e.logger.Info("Exported metering data",
+		zap.String("table", e.exporterConfig.Table),
+		zap.Int64("start", tr.Start),
+		zap.Int64("end", tr.End),
+		zap.Int("files", result.Files),
+		zap.Int64("rows", result.Rows),
+	)
+	return result, nil
+}
+
+// pendingRow a row waiting to be batched
+type pendingRow struct {
+	key              []byte
+	file             *meteringreader.MeteringFileInfo // file of the first value, its part is exported
+	logicalClusterID string
+	metric           string
+	value            *common.MeteringValue
+}
+
+// pendingRows the rows of one timestamp and category, merged by row key
+type pendingRows struct {
+	timestamp int64
+	category  string
+	rows      []*pendingRow
+	keys      map[string]*pendingRow // rows by row key
+}
+
+// newPendingRows creates an empty set of pending rows
+func newPendingRows() *pendingRows {
+	return &pendingRows{keys: make(map[string]*pendingRow)}
+}
+
+// add adds the value of metric for a logical cluster read from file, summing it into the row of an earlier
+// value with the same key
+func (p *pendingRows) add(file *meteringreader.MeteringFileInfo, logicalClusterID, metric string, value *common.MeteringValue) error {
+	key := rowKey(file.Timestamp, file.Category, file.SharedPoolID, file.SelfID, logicalClusterID, metric)
+	r, ok := p.keys[string(key)]
+	if !ok {
+		r = &pendingRow{key: key, file: file, logicalClusterID: logicalClusterID, metric: metric, value: value}
+		p.keys[string(key)] = r
+		p.rows = append(p.rows, r)
+		return nil
+	}
+	sum, err := r.value.Add(value)
+	if err != nil {
+		return fmt.Errorf("failed to sum %s of logical cluster %s in %s: %w", metric, logicalClusterID, file.Path, err)
+	}
+	r.value = sum
+	return nil
+}
+
+// metricName returns the metric name field is exported under, and whether it is exported at all
+func (e *Exporter) metricName(field string) (string, bool) {
+	if field == common.LogicalClusterIDField {
+		return "", false
+	}
+	if e.exporterConfig.Metrics == nil {
+		return field, true
+	}
+	metric, ok := e.exporterConfig.Metrics[field]
+	return metric, ok
+}
+
+// insertStatement returns the INSERT statement for rows rows, updating the values of existing rows
+func (e *Exporter) insertStatement(rows int) string {
+	var b strings.Builder
+	b.WriteString("INSERT INTO ")
+	b.WriteString(quoteIdentifier(e.exporterConfig.Table))
+	b.WriteString(" (")
+	for i, column := range columns {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(quoteIdentifier(column))
+	}
+	b.WriteString(") VALUES ")
+
+	placeholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+	for i := 0; i < rows; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(placeholders)
+	}
+
+	b.WriteString(" ON DUPLICATE KEY UPDATE ")
+	for i, column := range updatedColumns {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s = VALUES(%s)", quoteIdentifier(column), quoteIdentifier(column))
+	}
+	return b.String()
+}
+
+// rowKey returns the SHA-256 of the columns identifying a row. Strings are length-prefixed, so no two
+// distinct rows hash the same input
+func rowKey(timestamp int64, fields ...string) []byte {
+	h := sha256.New()
+	var buf [binary.MaxVarintLen64]byte
+	h.Write(buf[:binary.PutVarint(buf[:], timestamp)])
+	for _, field := range fields {
+		h.Write(buf[:binary.PutUvarint(buf[:], uint64(len(field)))])
+		h.Write([]byte(field))
+	}
+	return h.Sum(nil)
+}
+
+// quoteIdentifier quotes a possibly database-qualified identifier with backticks
+func quoteIdentifier(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = "`" + strings.ReplaceAll(part, "`", "``") + "`"
+	}
+	return strings.Join(parts, ".")
+}
+
+// sortedKeys returns the keys of m in sorted order, e.g. the fields of a Data entry so rows are inserted
+// deterministically
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,204 @@
+package sqlexporter
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/pingcap/metering_sdk/config"
+	meteringreader "github.com/pingcap/metering_sdk/reader/metering"
+	"github.com/pingcap/metering_sdk/storage"
+	meteringwriter "github.com/pingcap/metering_sdk/writer/metering"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingExecer records executed statements and their arguments
+type recordingExecer struct {
+	queries []string
+	args    [][]any
+	err     error
+}
+
+func (e *recordingExecer) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	e.queries = append(e.queries, query)
+	// The exporter reuses its argument slice between batches
+	e.args = append(e.args, slices.Clone(args))
+	return nil, nil
+}
+
+// rows returns the recorded rows of all INSERT statements
+func (e *recordingExecer) rows() [][]any {
+	var rows [][]any
+	for i, query := range e.queries {
+		if !strings.HasPrefix(query, "INSERT") {
+			continue
+		}
+		for args := e.args[i]; len(args) > 0; args = args[len(columns):] {
+			rows = append(rows, args[:len(columns)])
+		}
+	}
+	return rows
+}
+
+// withoutKeys returns rows without their row_key column
+func withoutKeys(rows [][]any) [][]any {
+	trimmed := make([][]any, len(rows))
+	for i, row := range rows {
+		trimmed[i] = row[1:]
+	}
+	return trimmed
+}
+
+func writeTestData(t *testing.T, provider storage.ObjectStorageProvider) {
+	const ts = int64(1755849600)
+	w := meteringwriter.NewMeteringWriterWithSharedPool(provider, config.DefaultConfig(), "pool1")
+	defer w.Close()
+	require.NoError(t, w.Write(context.Background(), &common.MeteringData{
+		Timestamp: ts,
+		Category:  "tidb",
+		SelfID:    "server1",
+		Data: []map[string]interface{}{
+			{"logical_cluster_id": "lc1", "ru": &common.MeteringValue{Value: 10, Unit: "RU"}, "note": "ignored"},
+			{"logical_cluster_id": "lc2", "ru": common.NewFloatMeteringValue(2.5, 2, "RU"), "bytes": &common.MeteringValue{Value: 100, Unit: "B"}},
+		},
+	}))
+	require.NoError(t, w.Write(context.Background(), &common.MeteringData{
+		Timestamp: ts + 60,
+		Category:  "tikv",
+		SelfID:    "store1",
+		Data: []map[string]interface{}{
+			{"logical_cluster_id": "lc1", "bytes": &common.MeteringValue{Value: 7, Unit: "B"}},
+		},
+	}))
+}
+
+func TestExporter_Export(t *testing.T) {
+	provider := storage.NewMemoryProvider()
+	writeTestData(t, provider)
+	tr := meteringreader.TimeRange{Start: 1755849600, End: 1755849660}
+
+	db := &recordingExecer{}
+	exporter := NewExporter(provider, db, nil, &Config{Table: "billing.metering", BatchSize: 2})
+	result, err := exporter.Export(context.Background(), tr)
+	require.NoError(t, err)
+	assert.Equal(t, &ExportResult{Files: 2, Records: 3, Rows: 4}, result)
+
+	// 4 rows in batches of 2
+	require.Len(t, db.queries, 2)
+	assert.Equal(t, "INSERT INTO `billing`.`metering` (`row_key`, `timestamp`, `category`, `shared_pool_id`, `self_id`, `part`, "+
+		"`logical_cluster_id`, `metric`, `value`, `value_float`, `unit`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?), "+
+		"(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE `part` = VALUES(`part`), `value` = VALUES(`value`), "+
+		"`value_float` = VALUES(`value_float`), `unit` = VALUES(`unit`)", db.queries[0])
+	assert.Equal(t, [][]any{
+		{int64(1755849600), "tidb", "pool1", "server1", 0, "lc1", "ru", "10", nil, "RU"},
+		{int64(1755849600), "tidb", "pool1", "server1", 0, "lc2", "bytes", "100", nil, "B"},
+		{int64(1755849600), "tidb", "pool1", "server1", 0, "lc2", "ru", "3", 2.5, "RU"},
+		{int64(1755849660), "tikv", "pool1", "store1", 0, "lc1", "bytes", "7", nil, "B"},
+	}, withoutKeys(db.rows()))
+	rows := db.rows()
+	assert.Equal(t, rowKey(1755849600, "tidb", "pool1", "server1", "lc1", "ru"), rows[0][0])
+	assert.Len(t, rows[0][0], sha256.Size)
+	assert.NotEqual(t, rows[0][0], rows[2][0])
+
+	t.Run("metric mapping and category", func(t *testing.T) {
+		db := &recordingExecer{}
+		exporter := NewExporter(provider, db, nil, &Config{
+			Category: "tidb",
+			Metrics:  map[string]string{"ru": "request_units"},
+		})
+		result, err := exporter.Export(context.Background(), tr)
+		require.NoError(t, err)
+		assert.Equal(t, &ExportResult{Files: 1, Records: 2, Rows: 2}, result)
+		require.Len(t, db.queries, 1)
+		assert.True(t, strings.HasPrefix(db.queries[0], "INSERT INTO `metering_values` "))
+		assert.Equal(t, [][]any{
+			{int64(1755849600), "tidb", "pool1", "server1", 0, "lc1", "request_units", "10", nil, "RU"},
+			{int64(1755849600), "tidb", "pool1", "server1", 0, "lc2", "request_units", "3", 2.5, "RU"},
+		}, withoutKeys(db.rows()))
+	})
+
+	t.Run("insert failure", func(t *testing.T) {
+		exporter := NewExporter(provider, &recordingExecer{err: errors.New("connection refused")}, nil, nil)
+		_, err := exporter.Export(context.Background(), tr)
+		assert.ErrorContains(t, err, "failed to insert 4 rows: connection refused")
+	})
+}
+
+func TestExporter_DuplicateRows(t *testing.T) {
+	ctx := context.Background()
+	provider := storage.NewMemoryProvider()
+	w := meteringwriter.NewMeteringWriterWithSharedPool(provider, config.DefaultConfig().WithPageSize(1), "pool1")
+	defer w.Close()
+	require.NoError(t, w.Write(ctx, &common.MeteringData{
+		Timestamp: 1755849600,
+		Category:  "tidb",
+		SelfID:    "server1",
+		Data: []map[string]interface{}{
+			{"logical_cluster_id": "lc1", "ru": &common.MeteringValue{Value: 10, Unit: "RU"}},
+			{"logical_cluster_id": "lc1", "ru": &common.MeteringValue{Value: 5, Unit: "RU"}},
+			{"logical_cluster_id": "lc2", "ru": &common.MeteringValue{Value: 1, Unit: "RU"}},
+		},
+	}))
+	tr := meteringreader.TimeRange{Start: 1755849600, End: 1755849600}
+
+	// Entries of the same logical cluster, here in different parts, are summed instead of overwritten
+	db := &recordingExecer{}
+	result, err := NewExporter(provider, db, nil, &Config{BatchSize: 1}).Export(ctx, tr)
+	require.NoError(t, err)
+	assert.Equal(t, &ExportResult{Files: 3, Records: 3, Rows: 2}, result)
+	assert.Equal(t, [][]any{
+		{int64(1755849600), "tidb", "pool1", "server1", 0, "lc1", "ru", "15", nil, "RU"},
+		{int64(1755849600), "tidb", "pool1", "server1", 2, "lc2", "ru", "1", nil, "RU"},
+	}, withoutKeys(db.rows()))
+
+	// Fields mapped to the same metric are rejected
+	db = &recordingExecer{}
+	_, err = NewExporter(provider, db, nil, &Config{Metrics: map[string]string{"ru": "usage", "cpu": "usage"}}).Export(ctx, tr)
+	assert.ErrorContains(t, err, "fields cpu and ru are both mapped to metric usage")
+	assert.Empty(t, db.queries)
+
+	// Values that can't be summed fail the export
+	require.NoError(t, w.Write(ctx, &common.MeteringData{
+		Timestamp: 1755849660,
+		Category:  "tidb",
+		SelfID:    "server1",
+		Data: []map[string]interface{}{
+			{"logical_cluster_id": "lc1", "ru": &common.MeteringValue{Value: 10, Unit: "RU"}},
+			{"logical_cluster_id": "lc1", "ru": &common.MeteringValue{Value: 5, Unit: "kRU"}},
+		},
+	}))
+	_, err = NewExporter(provider, &recordingExecer{}, nil, nil).Export(ctx, meteringreader.TimeRange{Start: 1755849660, End: 1755849660})
+	assert.ErrorIs(t, err, common.ErrUnitMismatch)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	assert.NoError(t, (&Config{}).Validate())
+	assert.NoError(t, (&Config{Metrics: map[string]string{"ru": "request_units", "cpu": "cpu"}}).Validate())
+	assert.Error(t, (&Config{Metrics: map[string]string{"ru": ""}}).Validate())
+	assert.Error(t, (&Config{Metrics: map[string]string{"ru": "usage", "cpu": "usage"}}).Validate())
+}
+
+func TestExporter_CreateTable(t *testing.T) {
+	db := &recordingExecer{}
+	exporter := NewExporter(storage.NewMemoryProvider(), db, nil, nil)
+	require.NoError(t, exporter.CreateTable(context.Background()))
+	require.Len(t, db.queries, 1)
+	assert.True(t, strings.HasPrefix(db.queries[0], "CREATE TABLE IF NOT EXISTS `metering_values` ("))
+	assert.Contains(t, db.queries[0], "PRIMARY KEY (row_key)")
+}
+
+func TestRowKey(t *testing.T) {
+	// Length prefixes keep shifted field boundaries apart
+	assert.NotEqual(t, rowKey(1, "ab", "c"), rowKey(1, "a", "bc"))
+	assert.Equal(t, rowKey(1, "a", "b"), rowKey(1, "a", "b"))
+	assert.NotEqual(t, rowKey(1, "a"), rowKey(2, "a"))
+}
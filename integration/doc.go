@@ -0,0 +1,5 @@
+// Package integration holds end-to-end tests running metering writers and readers against object
+// store emulators: localstack for S3 and an OSS-compatible emulator. The tests are built with the
+// integration tag and skip backends whose endpoint isn't set, run them with `make integration-test`
+// after starting the emulators with `make integration-up`.
+package integration
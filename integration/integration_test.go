@@ -0,0 +1,315 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aliyun/alibabacloud-oss-go-sdk-v2/oss"
+	osscredentials "github.com/aliyun/alibabacloud-oss-go-sdk-v2/oss/credentials"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	awscredentials "github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/pingcap/metering_sdk/config"
+	metareader "github.com/pingcap/metering_sdk/reader/meta"
+	meteringreader "github.com/pingcap/metering_sdk/reader/metering"
+	"github.com/pingcap/metering_sdk/storage"
+	"github.com/pingcap/metering_sdk/writer"
+	metawriter "github.com/pingcap/metering_sdk/writer/meta"
+	meteringwriter "github.com/pingcap/metering_sdk/writer/metering"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// Endpoints of the emulators, set by `make integration-test`:
+//
+//	LOCALSTACK_ENDPOINT      S3 endpoint of localstack, e.g. http://127.0.0.1:4566
+//	OSS_EMULATOR_ENDPOINT    OSS endpoint of the emulator, e.g. http://127.0.0.1:8080
+//	OSS_EMULATOR_ACCESS_KEY  credentials of the OSS emulator, "test" by default
+//	OSS_EMULATOR_SECRET_KEY
+//	OSS_EMULATOR_REGION      region of the OSS emulator, cn-hangzhou by default
+
+const (
+	testRegion = "us-east-1"
+	testSecret = "test"
+	testPrefix = "it"
+)
+
+// backend an object store under test
+type backend struct {
+	provider storage.ObjectStorageProvider
+	// contentType returns the Content-Type the store recorded for the object at path
+	contentType func(t *testing.T, path string) string
+}
+
+// envOr returns the environment variable key, or fallback if it is empty
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// newBucketName returns a bucket name unique to the test run
+func newBucketName() string {
+	return fmt.Sprintf("metering-it-%d", time.Now().UnixNano())
+}
+
+// newLocalstackBackend creates a path-style S3 provider for a fresh bucket on localstack
+func newLocalstackBackend(t *testing.T) *backend {
+	t.Helper()
+	endpoint := os.Getenv("LOCALSTACK_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("LOCALSTACK_ENDPOINT is not set")
+	}
+	ctx := context.Background()
+	bucket := newBucketName()
+
+	cfg, err := awsconfig.LoadDefaultConfig(ctx,
+		awsconfig.WithRegion(testRegion),
+		awsconfig.WithCredentialsProvider(awscredentials.NewStaticCredentialsProvider(testSecret, testSecret, "")),
+	)
+	require.NoError(t, err)
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(endpoint)
+		o.UsePathStyle = true
+	})
+	_, err = client.CreateBucket(ctx, &s3.CreateBucketInput{Bucket: aws.String(bucket)})
+	require.NoError(t, err)
+
+	provider, err := storage.NewObjectStorageProvider(&storage.ProviderConfig{
+		Type:     storage.ProviderTypeS3,
+		Bucket:   bucket,
+		Region:   testRegion,
+		Endpoint: endpoint,
+		Prefix:   testPrefix,
+		AWS: &storage.AWSConfig{
+			S3ForcePathStyle: true,
+			AccessKey:        testSecret,
+			SecretAccessKey:  testSecret,
+		},
+	})
+	require.NoError(t, err)
+	return &backend{
+		provider: provider,
+		contentType: func(t *testing.T, path string) string {
+			head, err := client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(testPrefix + "/" + path)})
+			require.NoError(t, err)
+			return aws.ToString(head.ContentType)
+		},
+	}
+}
+
+// newOSSEmulatorBackend creates a path-style OSS provider for a fresh bucket on the OSS emulator
+func newOSSEmulatorBackend(t *testing.T) *backend {
+	t.Helper()
+	endpoint := os.Getenv("OSS_EMULATOR_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("OSS_EMULATOR_ENDPOINT is not set")
+	}
+	ctx := context.Background()
+	bucket := newBucketName()
+
+	cfg := oss.LoadDefaultConfig().
+		WithRegion(envOr("OSS_EMULATOR_REGION", "cn-hangzhou")).
+		WithEndpoint(endpoint).
+		WithUsePathStyle(true).
+		WithCredentialsProvider(osscredentials.NewStaticCredentialsProvider(
+			envOr("OSS_EMULATOR_ACCESS_KEY", testSecret),
+			envOr("OSS_EMULATOR_SECRET_KEY", testSecret),
+		))
+	client := oss.NewClient(cfg)
+	_, err := client.PutBucket(ctx, &oss.PutBucketRequest{Bucket: oss.Ptr(bucket)})
+	require.NoError(t, err)
+
+	provider, err := storage.NewObjectStorageProvider(&storage.ProviderConfig{
+		Type:   storage.ProviderTypeOSS,
+		Bucket: bucket,
+		Region: envOr("OSS_EMULATOR_REGION", "cn-hangzhou"),
+		Prefix: testPrefix,
+		OSS:    &storage.OSSConfig{CustomConfig: cfg},
+	})
+	require.NoError(t, err)
+	return &backend{
+		provider: provider,
+		contentType: func(t *testing.T, path string) string {
+			head, err := client.HeadObject(ctx, &oss.HeadObjectRequest{Bucket: oss.Ptr(bucket), Key: oss.Ptr(testPrefix + "/" + path)})
+			require.NoError(t, err)
+			return oss.ToString(head.ContentType)
+		},
+	}
+}
+
+func TestLocalstackIntegration(t *testing.T) {
+	runFlows(t, newLocalstackBackend(t))
+}
+
+func TestOSSEmulatorIntegration(t *testing.T) {
+	runFlows(t, newOSSEmulatorBackend(t))
+}
+
+// runFlows runs the writer and reader flows against b
+func runFlows(t *testing.T, b *backend) {
+	t.Run("metering", func(t *testing.T) { testMeteringFlow(t, b) })
+	t.Run("meta", func(t *testing.T) { testMetaFlow(t, b) })
+	t.Run("pagination", func(t *testing.T) { testListPagination(t, b) })
+	t.Run("conditional", func(t *testing.T) { testConditionalFlow(t, b) })
+}
+
+// testConditionalFlow checks existing files are never overwritten, by conditional uploads and by the
+// writer with OverwriteExisting disabled, with and without conditional put
+func testConditionalFlow(t *testing.T, b *backend) {
+	ctx := context.Background()
+	uploader, ok := b.provider.(storage.ConditionalUploader)
+	require.True(t, ok, "provider must support conditional uploads")
+	require.NoError(t, uploader.UploadIfNotExists(ctx, "conditional/a", strings.NewReader("first")))
+	assert.ErrorIs(t, uploader.UploadIfNotExists(ctx, "conditional/a", strings.NewReader("second")), storage.ErrObjectExists)
+	rc, err := b.provider.Download(ctx, "conditional/a")
+	require.NoError(t, err)
+	body, err := io.ReadAll(rc)
+	rc.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(body))
+
+	timestamp := time.Now().Truncate(time.Minute).Unix()
+	data := func() *common.MeteringData {
+		return &common.MeteringData{
+			Timestamp: timestamp,
+			Category:  "conditional",
+			SelfID:    "server1",
+			Data:      []map[string]interface{}{common.NewRecord().LogicalCluster("lc-001").Value("ru", uint64(1), "ru").MustBuild()},
+		}
+	}
+	for _, cfg := range []*config.Config{config.DefaultConfig(), config.DefaultConfig().WithConditionalPut(false)} {
+		meteringWriter := meteringwriter.NewMeteringWriterWithSharedPool(b.provider, cfg, "pool1")
+		require.NoError(t, meteringWriter.Write(ctx, data()))
+		assert.ErrorIs(t, meteringWriter.Write(ctx, data()), writer.ErrFileExists)
+		meteringWriter.Close()
+		require.NoError(t, b.provider.Delete(ctx, fmt.Sprintf("metering/ru/%d/conditional/pool1/server1-0.json.gz", timestamp)))
+	}
+}
+
+// testMeteringFlow writes paginated metering data and reads it back, checking the stored attributes
+func testMeteringFlow(t *testing.T, b *backend) {
+	ctx := context.Background()
+	cfg := config.DefaultConfig().
+		WithPageSize(1024).
+		WithContentType("application/gzip").
+		WithTags(map[string]string{"suite": "integration"})
+	writer := meteringwriter.NewMeteringWriterWithSharedPool(b.provider, cfg, "pool1")
+	defer writer.Close()
+
+	const clusters = 50
+	data := &common.MeteringData{
+		Timestamp: time.Now().Truncate(time.Minute).Unix(),
+		Category:  "tidb",
+		SelfID:    "server1",
+	}
+	for i := range clusters {
+		data.Data = append(data.Data, common.NewRecord().
+			LogicalCluster(fmt.Sprintf("lc-%03d", i)).
+			Value("ru", uint64(i), "ru").
+			MustBuild())
+	}
+	require.NoError(t, writer.Write(ctx, data))
+
+	reader := meteringreader.NewMeteringReader(b.provider, config.DefaultConfig())
+	defer reader.Close()
+	files, err := reader.GetFilesByCluster(ctx, data.Timestamp, data.Category)
+	require.NoError(t, err)
+	require.Greater(t, len(files), 1, "expected a paginated write")
+
+	read, err := reader.ReadAllParts(ctx, data.Timestamp, data.Category, data.SelfID)
+	require.NoError(t, err)
+	assert.Equal(t, "pool1", read.SharedPoolID)
+	assert.Len(t, read.Data, clusters)
+
+	_, version, err := reader.ReadFileWithFormat(ctx, files[0])
+	require.NoError(t, err)
+	assert.Equal(t, meteringreader.FormatVersionCurrent, version)
+
+	attrs, err := storage.Stat(ctx, b.provider, files[0])
+	require.NoError(t, err)
+	assert.Positive(t, attrs.Size)
+	assert.NotEmpty(t, attrs.ETag)
+	assert.WithinDuration(t, time.Now(), attrs.LastModified, 10*time.Minute)
+	assert.Equal(t, "application/gzip", b.contentType(t, files[0]))
+}
+
+// testMetaFlow writes metadata and reads it back
+func testMetaFlow(t *testing.T, b *backend) {
+	ctx := context.Background()
+	writer := metawriter.NewMetaWriter(b.provider, config.DefaultConfig())
+	defer writer.Close()
+
+	modifyTS := time.Now().Unix()
+	meta := &common.MetaData{
+		ClusterID: "cluster1",
+		Type:      common.MetaTypeLogic,
+		ModifyTS:  modifyTS,
+		Metadata:  map[string]interface{}{"region": "us-east-1"},
+	}
+	require.NoError(t, writer.Write(ctx, meta))
+
+	reader, err := metareader.NewMetaReader(b.provider, &config.Config{Logger: zap.NewNop()}, nil)
+	require.NoError(t, err)
+	defer reader.Close()
+	read, err := reader.Read(ctx, "cluster1", modifyTS)
+	require.NoError(t, err)
+	assert.Equal(t, meta.Metadata, read.Metadata)
+	assert.Equal(t, modifyTS, read.ModifyTS)
+}
+
+// testListPagination lists more objects than fit in one listing page
+func testListPagination(t *testing.T, b *backend) {
+	ctx := context.Background()
+	n := storage.DefaultListPageSize + 5
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 32)
+	errs := make(chan error, n)
+	for i := range n {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			errs <- b.provider.Upload(ctx, fmt.Sprintf("pagination/%05d", i), strings.NewReader("x"))
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	paths, err := b.provider.List(ctx, "pagination/")
+	require.NoError(t, err)
+	assert.Len(t, paths, n)
+
+	pages, listed := 0, 0
+	require.NoError(t, storage.ListPages(ctx, b.provider, "pagination/", func(page []string) error {
+		pages++
+		listed += len(page)
+		return nil
+	}))
+	assert.Equal(t, n, listed)
+	assert.GreaterOrEqual(t, pages, 2)
+
+	first, token, err := storage.ListPage(ctx, b.provider, "pagination/", "", 10)
+	require.NoError(t, err)
+	assert.Len(t, first, 10)
+	second, _, err := storage.ListPage(ctx, b.provider, "pagination/", token, 10)
+	require.NoError(t, err)
+	require.Len(t, second, 10)
+	assert.Greater(t, second[0], first[9])
+}
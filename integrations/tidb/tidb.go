@@ -0,0 +1,190 @@
+// Package tidb provides the standard metering categories of TiDB components, the metrics expected
+// for each of them, and constructors for their Data entries.
+package tidb
+
+import (
+	"time"
+
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/pingcap/metering_sdk/schema"
+)
+
+// Categories of TiDB components
+const (
+	// CategoryTiDBServer SQL layer
+	CategoryTiDBServer = "tidb-server"
+	// CategoryTiKV row storage
+	CategoryTiKV = "tikv-server"
+	// CategoryPD placement driver
+	CategoryPD = "pd-server"
+	// CategoryTiFlash columnar storage
+	CategoryTiFlash = "tiflash-server"
+)
+
+// Field names of Data entries
+const (
+	FieldLogicalClusterID  = "logical_cluster_id"
+	FieldRU                = "ru"
+	FieldComputeSeconds    = "compute_seconds"
+	FieldMemoryMB          = "memory_mb"
+	FieldRequestCount      = "request_count"
+	FieldStorageBytes      = "storage_bytes"
+	FieldStorageReadBytes  = "storage_read_bytes"
+	FieldStorageWriteBytes = "storage_write_bytes"
+	FieldCPUUsagePercent   = "cpu_usage_percent"
+	FieldResponseTimeMS    = "response_time_ms"
+)
+
+// Metric a metric expected in the Data entries of a category
+type Metric struct {
+	Name string // field name
+	Unit string // unit of the metering value
+}
+
+// categoryMetrics metrics of every category, in the order of the usage struct fields
+var categoryMetrics = map[string][]Metric{
+	CategoryTiDBServer: {
+		{FieldRU, "RU"},
+		{FieldComputeSeconds, "seconds"},
+		{FieldMemoryMB, "MB"},
+		{FieldRequestCount, "count"},
+	},
+	CategoryTiKV: {
+		{FieldStorageBytes, "bytes"},
+		{FieldStorageReadBytes, "bytes"},
+		{FieldStorageWriteBytes, "bytes"},
+		{FieldCPUUsagePercent, "percent"},
+	},
+	CategoryPD: {
+		{FieldRequestCount, "count"},
+		{FieldResponseTimeMS, "ms"},
+	},
+	CategoryTiFlash: {
+		{FieldComputeSeconds, "seconds"},
+		{FieldStorageBytes, "bytes"},
+		{FieldStorageReadBytes, "bytes"},
+	},
+}
+
+// Categories returns the standard categories
+func Categories() []string {
+	return []string{CategoryTiDBServer, CategoryTiKV, CategoryPD, CategoryTiFlash}
+}
+
+// Metrics returns the metrics expected for category, or nil if it isn't a standard category
+func Metrics(category string) []Metric {
+	metrics := categoryMetrics[category]
+	if metrics == nil {
+		return nil
+	}
+	return append([]Metric(nil), metrics...)
+}
+
+// Schema returns the schema of the Data entries of category, or nil if it isn't a standard category.
+// Entries may carry additional fields.
+func Schema(category string) *schema.Schema {
+	metrics := categoryMetrics[category]
+	if metrics == nil {
+		return nil
+	}
+	fields := []schema.Field{{Name: FieldLogicalClusterID, Type: schema.FieldTypeString, Required: true}}
+	for _, metric := range metrics {
+		fields = append(fields, schema.Field{
+			Name:     metric.Name,
+			Type:     schema.FieldTypeMeteringValue,
+			Required: true,
+			Units:    []string{metric.Unit},
+		})
+	}
+	return &schema.Schema{Category: category, Fields: fields, AllowUnknown: true}
+}
+
+// RegisterSchemas registers the schemas of all standard categories, see Schema
+func RegisterSchemas(registry *schema.Registry) error {
+	for _, category := range Categories() {
+		if err := registry.Register(Schema(category)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewMeteringData creates the metering data of category reported by selfID for the minute of ts
+func NewMeteringData(category, selfID string, ts time.Time, records ...map[string]interface{}) *common.MeteringData {
+	return &common.MeteringData{
+		SelfID:    selfID,
+		Timestamp: ts.Unix() / 60 * 60,
+		Category:  category,
+		Data:      records,
+	}
+}
+
+// TiDBServerUsage usage of a logical cluster on a TiDB server
+type TiDBServerUsage struct {
+	RU             float64 // request units consumed
+	ComputeSeconds uint64  // CPU time
+	MemoryMB       uint64  // memory in use
+	RequestCount   uint64  // SQL requests served
+}
+
+// NewTiDBServerRecord creates the tidb-server Data entry of a logical cluster
+func NewTiDBServerRecord(logicalClusterID string, usage TiDBServerUsage) map[string]interface{} {
+	return map[string]interface{}{
+		FieldLogicalClusterID: logicalClusterID,
+		FieldRU:               common.NewFloatMeteringValue(usage.RU, 2, "RU"),
+		FieldComputeSeconds:   &common.MeteringValue{Value: usage.ComputeSeconds, Unit: "seconds"},
+		FieldMemoryMB:         &common.MeteringValue{Value: usage.MemoryMB, Unit: "MB"},
+		FieldRequestCount:     &common.MeteringValue{Value: usage.RequestCount, Unit: "count"},
+	}
+}
+
+// TiKVUsage usage of a logical cluster on a TiKV server
+type TiKVUsage struct {
+	StorageBytes      uint64  // data stored
+	StorageReadBytes  uint64  // bytes read
+	StorageWriteBytes uint64  // bytes written
+	CPUUsagePercent   float64 // CPU usage
+}
+
+// NewTiKVRecord creates the tikv-server Data entry of a logical cluster
+func NewTiKVRecord(logicalClusterID string, usage TiKVUsage) map[string]interface{} {
+	return map[string]interface{}{
+		FieldLogicalClusterID:  logicalClusterID,
+		FieldStorageBytes:      &common.MeteringValue{Value: usage.StorageBytes, Unit: "bytes"},
+		FieldStorageReadBytes:  &common.MeteringValue{Value: usage.StorageReadBytes, Unit: "bytes"},
+		FieldStorageWriteBytes: &common.MeteringValue{Value: usage.StorageWriteBytes, Unit: "bytes"},
+		FieldCPUUsagePercent:   common.NewFloatMeteringValue(usage.CPUUsagePercent, 1, "percent"),
+	}
+}
+
+// PDUsage usage of a logical cluster on a PD server
+type PDUsage struct {
+	RequestCount   uint64  // requests served
+	ResponseTimeMS float64 // average response time
+}
+
+// NewPDRecord creates the pd-server Data entry of a logical cluster
+func NewPDRecord(logicalClusterID string, usage PDUsage) map[string]interface{} {
+	return map[string]interface{}{
+		FieldLogicalClusterID: logicalClusterID,
+		FieldRequestCount:     &common.MeteringValue{Value: usage.RequestCount, Unit: "count"},
+		FieldResponseTimeMS:   common.NewFloatMeteringValue(usage.ResponseTimeMS, 1, "ms"),
+	}
+}
+
+// TiFlashUsage usage of a logical cluster on a TiFlash server
+type TiFlashUsage struct {
+	ComputeSeconds   uint64 // CPU time
+	StorageBytes     uint64 // data stored
+	StorageReadBytes uint64 // bytes read
+}
+
+// NewTiFlashRecord creates the tiflash-server Data entry of a logical cluster
+func NewTiFlashRecord(logicalClusterID string, usage TiFlashUsage) map[string]interface{} {
+	return map[string]interface{}{
+		FieldLogicalClusterID: logicalClusterID,
+		FieldComputeSeconds:   &common.MeteringValue{Value: usage.ComputeSeconds, Unit: "seconds"},
+		FieldStorageBytes:     &common.MeteringValue{Value: usage.StorageBytes, Unit: "bytes"},
+		FieldStorageReadBytes: &common.MeteringValue{Value: usage.StorageReadBytes, Unit: "bytes"},
+	}
+}
@@ -0,0 +1,48 @@
+package tidb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/pingcap/metering_sdk/schema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordsMatchSchemas(t *testing.T) {
+	registry := schema.NewRegistry()
+	require.NoError(t, RegisterSchemas(registry))
+
+	records := map[string]map[string]interface{}{
+		CategoryTiDBServer: NewTiDBServerRecord("lc-001", TiDBServerUsage{RU: 12.345, ComputeSeconds: 60, MemoryMB: 4096, RequestCount: 100}),
+		CategoryTiKV:       NewTiKVRecord("lc-001", TiKVUsage{StorageBytes: 1 << 30, CPUUsagePercent: 75.55}),
+		CategoryPD:         NewPDRecord("lc-001", PDUsage{RequestCount: 10, ResponseTimeMS: 2.5}),
+		CategoryTiFlash:    NewTiFlashRecord("lc-001", TiFlashUsage{ComputeSeconds: 30}),
+	}
+	require.Len(t, records, len(Categories()))
+	for category, record := range records {
+		assert.NoError(t, registry.Validate(category, []map[string]interface{}{record}), category)
+		assert.Len(t, record, len(Metrics(category))+1, category)
+	}
+	assert.Equal(t, 12.35, records[CategoryTiDBServer][FieldRU].(*common.MeteringValue).Float64())
+
+	// Wrong units are rejected
+	record := NewPDRecord("lc-001", PDUsage{})
+	record[FieldResponseTimeMS] = &common.MeteringValue{Value: 1, Unit: "seconds"}
+	assert.Error(t, registry.Validate(CategoryPD, []map[string]interface{}{record}))
+
+	assert.Nil(t, Metrics("unknown"))
+	assert.Nil(t, Schema("unknown"))
+}
+
+func TestNewMeteringData(t *testing.T) {
+	record := NewTiKVRecord("lc-001", TiKVUsage{})
+	data := NewMeteringData(CategoryTiKV, "tikv001", time.Unix(1640995259, 0), record)
+	assert.Equal(t, &common.MeteringData{
+		SelfID:    "tikv001",
+		Timestamp: 1640995200,
+		Category:  CategoryTiKV,
+		Data:      []map[string]interface{}{record},
+	}, data)
+}
@@ -59,8 +59,11 @@ type CacheItem struct {
 	AccessedAt time.Time   `json:"accessed_at"`
 }
 
-// calculateSize calculates the size of an object
+// calculateSize calculates the size of an object, byte slices are counted exactly
 func calculateSize(value interface{}) int64 {
+	if data, ok := value.([]byte); ok {
+		return int64(len(data))
+	}
 	data, err := json.Marshal(value)
 	if err != nil {
 		return 0
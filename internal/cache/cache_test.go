@@ -59,6 +59,14 @@ func TestMemoryCache_AccessTimeTracking(t *testing.T) {
 	assert.Greater(t, cache.Count(), 0, "Cache should contain some items")
 }
 
+// TestMemoryCache_ByteSize tests that byte slices are accounted with their exact size
+func TestMemoryCache_ByteSize(t *testing.T) {
+	cache, err := NewMemoryCache(&Config{Type: CacheTypeMemory, MaxSize: 1024})
+	assert.NoError(t, err)
+	assert.NoError(t, cache.Set("key1", make([]byte, 100)))
+	assert.Equal(t, int64(100), cache.Size())
+}
+
 // TestDiskCache_AccessTimeTracking tests disk cache access time tracking functionality
 func TestDiskCache_AccessTimeTracking(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -0,0 +1,143 @@
+// Package compress provides pooled gzip compression and decompression, so concurrent writes don't
+// contend on a single gzip writer and reads and writes don't allocate new ones for every file
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// maxPooledBufferSize buffers grown beyond this size are not pooled, so a single huge page doesn't keep
+// its memory alive
+const maxPooledBufferSize = 64 << 20
+
+var (
+	writers  = sync.Pool{New: func() any { return gzip.NewWriter(io.Discard) }}
+	readers  = sync.Pool{New: func() any { return new(gzip.Reader) }}
+	buffers  = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+	encoders = sync.Pool{New: func() any { return newJSONEncoder() }}
+)
+
+// jsonEncoder json.Encoder writing into a buffer, reused as a whole since an encoder can't be pointed at
+// another writer
+type jsonEncoder struct {
+	buffer  bytes.Buffer
+	encoder *json.Encoder
+}
+
+// newJSONEncoder creates a jsonEncoder
+func newJSONEncoder() *jsonEncoder {
+	e := &jsonEncoder{}
+	e.encoder = json.NewEncoder(&e.buffer)
+	return e
+}
+
+// GzipJSON encodes v as JSON like json.Marshal and compresses it, using pooled encoders, buffers and gzip
+// writers so only the result is allocated. It is safe for concurrent use
+func GzipJSON(v any) ([]byte, error) {
+	e := encoders.Get().(*jsonEncoder)
+	e.buffer.Reset()
+	defer func() {
+		if e.buffer.Cap() <= maxPooledBufferSize {
+			encoders.Put(e)
+		}
+	}()
+
+	if err := e.encoder.Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	// Drop the newline Encode appends, so the output matches json.Marshal
+	data, err := Gzip(bytes.TrimSuffix(e.buffer.Bytes(), []byte("\n")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress data: %w", err)
+	}
+	return data, nil
+}
+
+// Gunzip decompresses the gzip stream read from r with a pooled gzip reader and buffer, failing if the
+// decompressed data is larger than limit bytes. limit 0 means unlimited
+func Gunzip(r io.Reader, limit int64) ([]byte, error) {
+	gzipReader, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer PutReader(gzipReader)
+
+	buffer := buffers.Get().(*bytes.Buffer)
+	buffer.Reset()
+	defer putBuffer(buffer)
+
+	var src io.Reader = gzipReader
+	if limit > 0 {
+		// Read one byte past the limit to tell a file of exactly limit bytes from a larger one
+		src = io.LimitReader(gzipReader, limit+1)
+	}
+	// #nosec G110 - decompression is bounded by limit where the input isn't trusted
+	if _, err := buffer.ReadFrom(src); err != nil {
+		return nil, fmt.Errorf("failed to decompress data: %w", err)
+	}
+	if limit > 0 && int64(buffer.Len()) > limit {
+		return nil, fmt.Errorf("decompressed data exceeds %d bytes", limit)
+	}
+	return bytes.Clone(buffer.Bytes()), nil
+}
+
+// NewReader returns a pooled gzip reader decompressing r. Return it with PutReader once done
+func NewReader(r io.Reader) (*gzip.Reader, error) {
+	gzipReader := readers.Get().(*gzip.Reader)
+	if err := gzipReader.Reset(r); err != nil {
+		readers.Put(gzipReader)
+		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	return gzipReader, nil
+}
+
+// PutReader closes a gzip reader obtained from NewReader and returns it to the pool
+func PutReader(gzipReader *gzip.Reader) {
+	gzipReader.Close()
+	readers.Put(gzipReader)
+}
+
+// Gzip compresses data with a pooled gzip writer and buffer, it is safe for concurrent use
+func Gzip(data []byte) ([]byte, error) {
+	buffer := buffers.Get().(*bytes.Buffer)
+	buffer.Reset()
+	defer putBuffer(buffer)
+
+	gzipWriter := NewWriter(buffer)
+	defer PutWriter(gzipWriter)
+	if _, err := gzipWriter.Write(data); err != nil {
+		return nil, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+	// The buffer is reused, return a copy
+	return bytes.Clone(buffer.Bytes()), nil
+}
+
+// NewWriter returns a pooled gzip writer writing to w. Return it with PutWriter once it is closed
+func NewWriter(w io.Writer) *gzip.Writer {
+	gzipWriter := writers.Get().(*gzip.Writer)
+	gzipWriter.Reset(w)
+	return gzipWriter
+}
+
+// PutWriter returns a gzip writer obtained from NewWriter to the pool
+func PutWriter(gzipWriter *gzip.Writer) {
+	// Don't keep the destination alive through the pool
+	gzipWriter.Reset(io.Discard)
+	writers.Put(gzipWriter)
+}
+
+// putBuffer returns a buffer to the pool unless it grew too large
+func putBuffer(buffer *bytes.Buffer) {
+	if buffer.Cap() > maxPooledBufferSize {
+		return
+	}
+	buffers.Put(buffer)
+}
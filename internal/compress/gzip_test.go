@@ -0,0 +1,131 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGzip(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				data := bytes.Repeat([]byte(fmt.Sprintf("page %d-%d;", i, j)), 100)
+				compressed, err := Gzip(data)
+				require.NoError(t, err)
+
+				reader, err := gzip.NewReader(bytes.NewReader(compressed))
+				require.NoError(t, err)
+				decompressed, err := io.ReadAll(reader)
+				require.NoError(t, err)
+				assert.Equal(t, data, decompressed)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestGzipJSON(t *testing.T) {
+	value := map[string]interface{}{"timestamp": 1640995200, "data": []string{"<a>", "b"}}
+	compressed, err := GzipJSON(value)
+	require.NoError(t, err)
+	decompressed, err := Gunzip(bytes.NewReader(compressed), 0)
+	require.NoError(t, err)
+	expected, err := json.Marshal(value)
+	require.NoError(t, err)
+	assert.Equal(t, expected, decompressed)
+
+	_, err = GzipJSON(func() {})
+	assert.Error(t, err)
+}
+
+func TestGunzip(t *testing.T) {
+	compressed, err := Gzip([]byte("0123456789"))
+	require.NoError(t, err)
+	data, err := Gunzip(bytes.NewReader(compressed), 10)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(data))
+	_, err = Gunzip(bytes.NewReader(compressed), 9)
+	assert.ErrorContains(t, err, "exceeds 9 bytes")
+	_, err = Gunzip(strings.NewReader("not gzip"), 0)
+	assert.Error(t, err)
+}
+
+// benchmarkPage resembles a metering page of 100 logical clusters
+func benchmarkPage() map[string]interface{} {
+	data := make([]map[string]interface{}, 100)
+	for i := range data {
+		data[i] = map[string]interface{}{
+			"logical_cluster_id": fmt.Sprintf("lc-%05d", i),
+			"compute_seconds":    map[string]interface{}{"value": i * 60, "unit": "seconds"},
+			"disk_usage":         map[string]interface{}{"value": i * 1024, "unit": "MB"},
+		}
+	}
+	return map[string]interface{}{"timestamp": 1640995200, "category": "tidb-server", "data": data}
+}
+
+func BenchmarkGzipJSON(b *testing.B) {
+	page := benchmarkPage()
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := GzipJSON(page); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			jsonData, err := json.Marshal(page)
+			if err != nil {
+				b.Fatal(err)
+			}
+			var buffer bytes.Buffer
+			gzipWriter := gzip.NewWriter(&buffer)
+			if _, err := gzipWriter.Write(jsonData); err != nil {
+				b.Fatal(err)
+			}
+			if err := gzipWriter.Close(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkGunzip(b *testing.B) {
+	compressed, err := GzipJSON(benchmarkPage())
+	require.NoError(b, err)
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := Gunzip(bytes.NewReader(compressed), 0); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			gzipReader, err := gzip.NewReader(bytes.NewReader(compressed))
+			if err != nil {
+				b.Fatal(err)
+			}
+			var buffer bytes.Buffer
+			if _, err := io.Copy(&buffer, gzipReader); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
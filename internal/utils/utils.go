@@ -62,6 +62,14 @@ func ValidateSelfID(selfID string) error {
 	return nil
 }
 
+// TruncateTimestamp truncates timestamp to the start of its minute
+func TruncateTimestamp(timestamp int64) int64 {
+	if timestamp <= 0 {
+		return timestamp
+	}
+	return timestamp / 60 * 60
+}
+
 // GetCurrentMinuteTimestamp gets current minute-level timestamp
 func GetCurrentMinuteTimestamp() int64 {
 	now := time.Now().UTC()
@@ -100,3 +108,20 @@ func ParseTimestampFromPath(path string) (int64, error) {
 
 	return timestamp, nil
 }
+
+// IsWindowsDrive reports whether s is a Windows drive letter with its colon, e.g. "C:"
+func IsWindowsDrive(s string) bool {
+	return len(s) == 2 && s[1] == ':' && ('a' <= s[0] && s[0] <= 'z' || 'A' <= s[0] && s[0] <= 'Z')
+}
+
+// TrimDriveSlash removes the slash file URIs put before Windows drive letters, turning "/C:/data" into
+// "C:/data". Other paths are returned unchanged.
+func TrimDriveSlash(path string) string {
+	if len(path) < 3 || path[0] != '/' || !IsWindowsDrive(path[1:3]) {
+		return path
+	}
+	if len(path) > 3 && path[3] != '/' && path[3] != '\\' {
+		return path
+	}
+	return path[1:]
+}
@@ -0,0 +1,325 @@
+// Package layout maps metering file information to object paths and back, using path templates.
+package layout
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/metering_sdk/codec"
+)
+
+// DefaultTemplate the built-in metering file layout
+const DefaultTemplate = "metering/ru/{timestamp}/{category}/{shared_pool_id}/{self_id}-{part}.json.gz"
+
+// HiveTemplate a date-partitioned layout using Hive-style key=value directories
+const HiveTemplate = "metering/ru/year={year}/month={month}/day={day}/hour={hour}/minute={minute}/" +
+	"category={category}/shared_pool_id={shared_pool_id}/{self_id}-{part}.json.gz"
+
+// Placeholders supported in templates. Date placeholders are derived from the timestamp in UTC.
+const (
+	PlaceholderTimestamp    = "timestamp"
+	PlaceholderYear         = "year"
+	PlaceholderMonth        = "month"
+	PlaceholderDay          = "day"
+	PlaceholderHour         = "hour"
+	PlaceholderMinute       = "minute"
+	PlaceholderCategory     = "category"
+	PlaceholderSharedPoolID = "shared_pool_id"
+	PlaceholderSelfID       = "self_id"
+	PlaceholderPart         = "part"
+)
+
+// placeholderPatterns regular expressions matching the rendered value of each placeholder
+var placeholderPatterns = map[string]string{
+	PlaceholderTimestamp:    `\d+`,
+	PlaceholderYear:         `\d{4}`,
+	PlaceholderMonth:        `\d{2}`,
+	PlaceholderDay:          `\d{2}`,
+	PlaceholderHour:         `\d{2}`,
+	PlaceholderMinute:       `\d{2}`,
+	PlaceholderCategory:     `[^/]+`,
+	PlaceholderSharedPoolID: `[^/]+`,
+	PlaceholderSelfID:       `(?:[^/-]|--)+`,
+	PlaceholderPart:         `\d+`,
+}
+
+// dateParts date placeholders, which together can replace {timestamp}
+var dateParts = []string{PlaceholderYear, PlaceholderMonth, PlaceholderDay, PlaceholderHour, PlaceholderMinute}
+
+var placeholderRegex = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// selfIDEscaper doubles dashes in rendered self IDs, as templates use a single dash to separate the part
+// number. IDs without dashes, the only IDs accepted by default, render unchanged
+var (
+	selfIDEscaper   = strings.NewReplacer("-", "--")
+	selfIDUnescaper = strings.NewReplacer("--", "-")
+)
+
+// Fields the information encoded in a metering file path
+type Fields struct {
+	Timestamp    int64  // minute-level Unix timestamp
+	Category     string // service category
+	SharedPoolID string // shared pool cluster ID
+	SelfID       string // component ID
+	Part         int    // part number
+}
+
+// token a literal string or a placeholder of a template
+type token struct {
+	literal     string
+	placeholder string
+}
+
+// Layout renders and parses metering file paths with a template
+type Layout struct {
+	template string
+	tokens   []token
+	regex    *regexp.Regexp
+}
+
+// New creates a layout from template. Templates must contain {category}, {shared_pool_id},
+// {self_id} and {part}, and either {timestamp} or all of {year}, {month}, {day}, {hour} and {minute}.
+// Each placeholder may appear only once.
+func New(template string) (*Layout, error) {
+	l := &Layout{template: template}
+	seen := make(map[string]bool)
+	var pattern strings.Builder
+	pattern.WriteString("^")
+
+	last := 0
+	for _, loc := range placeholderRegex.FindAllStringSubmatchIndex(template, -1) {
+		if loc[0] > last {
+			literal := template[last:loc[0]]
+			l.tokens = append(l.tokens, token{literal: literal})
+			pattern.WriteString(regexp.QuoteMeta(literal))
+		}
+		name := template[loc[2]:loc[3]]
+		expr, ok := placeholderPatterns[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown placeholder {%s} in template %q", name, template)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("placeholder {%s} appears more than once in template %q", name, template)
+		}
+		seen[name] = true
+		l.tokens = append(l.tokens, token{placeholder: name})
+		fmt.Fprintf(&pattern, "(?P<%s>%s)", name, expr)
+		last = loc[1]
+	}
+	if last < len(template) {
+		literal := template[last:]
+		l.tokens = append(l.tokens, token{literal: literal})
+		pattern.WriteString(regexp.QuoteMeta(literal))
+	}
+	pattern.WriteString("$")
+
+	for _, required := range []string{PlaceholderCategory, PlaceholderSharedPoolID, PlaceholderSelfID, PlaceholderPart} {
+		if !seen[required] {
+			return nil, fmt.Errorf("template %q is missing placeholder {%s}", template, required)
+		}
+	}
+	if !seen[PlaceholderTimestamp] {
+		for _, part := range dateParts {
+			if !seen[part] {
+				return nil, fmt.Errorf("template %q needs {timestamp} or {%s}", template, strings.Join(dateParts, "}, {"))
+			}
+		}
+	}
+
+	regex, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %q: %w", template, err)
+	}
+	l.regex = regex
+	return l, nil
+}
+
+// MustNew is like New but panics if the template is invalid
+func MustNew(template string) *Layout {
+	l, err := New(template)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+var defaultLayout = MustNew(DefaultTemplate)
+
+// Default returns the built-in layout
+func Default() *Layout {
+	return defaultLayout
+}
+
+// Template returns the template the layout was created from
+func (l *Layout) Template() string {
+	return l.template
+}
+
+// Path renders the path of a file, zero-padding the part number to partWidth digits. Dashes in the self
+// ID are doubled, Parse undoes this
+func (l *Layout) Path(f Fields, partWidth int) string {
+	var b strings.Builder
+	for _, t := range l.tokens {
+		if t.placeholder == "" {
+			b.WriteString(t.literal)
+			continue
+		}
+		b.WriteString(l.render(t.placeholder, f, partWidth))
+	}
+	return b.String()
+}
+
+// TimestampPrefix returns the longest path prefix shared by all files of timestamp, for listing.
+// Rendering stops at the first placeholder that doesn't derive from the timestamp, so with
+// templates that don't start with the timestamp the prefix also covers other timestamps.
+func (l *Layout) TimestampPrefix(timestamp int64) string {
+	return l.Prefix(Fields{Timestamp: timestamp})
+}
+
+// Prefix returns the longest path prefix shared by all files of f.Timestamp matching the non-empty
+// Category, SharedPoolID and SelfID of f, for listing. Rendering stops at the first placeholder whose
+// value is unknown, so the prefix may also cover files that don't match; filter listings with Parse.
+func (l *Layout) Prefix(f Fields) string {
+	var b strings.Builder
+	for _, t := range l.tokens {
+		if t.placeholder == "" {
+			b.WriteString(t.literal)
+			continue
+		}
+		known := t.placeholder == PlaceholderTimestamp || slices.Contains(dateParts, t.placeholder) ||
+			(t.placeholder == PlaceholderCategory && f.Category != "") ||
+			(t.placeholder == PlaceholderSharedPoolID && f.SharedPoolID != "") ||
+			(t.placeholder == PlaceholderSelfID && f.SelfID != "")
+		if !known {
+			break
+		}
+		b.WriteString(l.render(t.placeholder, f, 0))
+	}
+	return b.String()
+}
+
+// TimestampDirectory returns the path prefix under which every timestamp has its own directory, e.g.
+// "metering/ru/" for the default layout, so the timestamps can be listed as common prefixes with
+// delimiter "/". ok is false unless {timestamp} is the first placeholder and a whole path segment.
+func (l *Layout) TimestampDirectory() (prefix string, ok bool) {
+	for i, t := range l.tokens {
+		if t.placeholder == "" {
+			continue
+		}
+		if t.placeholder != PlaceholderTimestamp || i+1 >= len(l.tokens) || !strings.HasPrefix(l.tokens[i+1].literal, "/") {
+			return "", false
+		}
+		if i > 0 {
+			prefix = l.tokens[i-1].literal
+		}
+		return prefix, prefix == "" || strings.HasSuffix(prefix, "/")
+	}
+	return "", false
+}
+
+// CategoryFollowsTimestamp reports whether {category} directly follows the timestamp placeholders and
+// ends with a "/", so the categories of a timestamp can be listed as common prefixes of TimestampPrefix
+// with delimiter "/"
+func (l *Layout) CategoryFollowsTimestamp() bool {
+	complete, parts := false, 0
+	for i, t := range l.tokens {
+		switch {
+		case t.placeholder == "":
+			continue
+		case t.placeholder == PlaceholderTimestamp:
+			complete = true
+			continue
+		case slices.Contains(dateParts, t.placeholder):
+			parts++
+			complete = complete || parts == len(dateParts)
+			continue
+		}
+		return complete && t.placeholder == PlaceholderCategory && i+1 < len(l.tokens) &&
+			strings.HasPrefix(l.tokens[i+1].literal, "/")
+	}
+	return false
+}
+
+// Parse extracts the fields encoded in path, zero-padded part numbers are accepted. Files written with
+// a registered codec other than JSON match templates ending with ".json.gz", see codec.Path
+func (l *Layout) Parse(path string) (*Fields, error) {
+	matches := l.regex.FindStringSubmatch(path)
+	if matches == nil {
+		matches = l.regex.FindStringSubmatch(codec.JSONPath(path))
+	}
+	if matches == nil {
+		return nil, fmt.Errorf("path %s does not match layout %s", path, l.template)
+	}
+
+	f := &Fields{}
+	values := make(map[string]int, len(dateParts))
+	for i, name := range l.regex.SubexpNames() {
+		value := matches[i]
+		switch name {
+		case PlaceholderTimestamp:
+			timestamp, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timestamp in path %s: %w", path, err)
+			}
+			f.Timestamp = timestamp
+		case PlaceholderCategory:
+			f.Category = value
+		case PlaceholderSharedPoolID:
+			f.SharedPoolID = value
+		case PlaceholderSelfID:
+			f.SelfID = selfIDUnescaper.Replace(value)
+		case PlaceholderPart:
+			part, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid part number in path %s: %w", path, err)
+			}
+			f.Part = part
+		case PlaceholderYear, PlaceholderMonth, PlaceholderDay, PlaceholderHour, PlaceholderMinute:
+			values[name], _ = strconv.Atoi(value)
+		}
+	}
+
+	if l.regex.SubexpIndex(PlaceholderTimestamp) < 0 {
+		t := time.Date(values[PlaceholderYear], time.Month(values[PlaceholderMonth]), values[PlaceholderDay],
+			values[PlaceholderHour], values[PlaceholderMinute], 0, 0, time.UTC)
+		// time.Date normalizes out of range values, e.g. month 13, reject them instead
+		if t.Month() != time.Month(values[PlaceholderMonth]) || t.Day() != values[PlaceholderDay] ||
+			t.Hour() != values[PlaceholderHour] || t.Minute() != values[PlaceholderMinute] {
+			return nil, fmt.Errorf("invalid date in path %s", path)
+		}
+		f.Timestamp = t.Unix()
+	}
+	return f, nil
+}
+
+// render renders a single placeholder
+func (l *Layout) render(placeholder string, f Fields, partWidth int) string {
+	t := time.Unix(f.Timestamp, 0).UTC()
+	switch placeholder {
+	case PlaceholderTimestamp:
+		return strconv.FormatInt(f.Timestamp, 10)
+	case PlaceholderYear:
+		return fmt.Sprintf("%04d", t.Year())
+	case PlaceholderMonth:
+		return fmt.Sprintf("%02d", int(t.Month()))
+	case PlaceholderDay:
+		return fmt.Sprintf("%02d", t.Day())
+	case PlaceholderHour:
+		return fmt.Sprintf("%02d", t.Hour())
+	case PlaceholderMinute:
+		return fmt.Sprintf("%02d", t.Minute())
+	case PlaceholderCategory:
+		return f.Category
+	case PlaceholderSharedPoolID:
+		return f.SharedPoolID
+	case PlaceholderSelfID:
+		return selfIDEscaper.Replace(f.SelfID)
+	case PlaceholderPart:
+		return fmt.Sprintf("%0*d", partWidth, f.Part)
+	}
+	return ""
+}
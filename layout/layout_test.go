@@ -0,0 +1,95 @@
+package layout
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultLayout(t *testing.T) {
+	fields := Fields{Timestamp: 1755850380, Category: "tidb", SharedPoolID: "pool1", SelfID: "server1", Part: 2}
+
+	path := Default().Path(fields, 0)
+	assert.Equal(t, "metering/ru/1755850380/tidb/pool1/server1-2.json.gz", path)
+	assert.Equal(t, "metering/ru/1755850380/tidb/pool1/server1-0002.json.gz", Default().Path(fields, 4))
+	assert.Equal(t, "metering/ru/1755850380/", Default().TimestampPrefix(fields.Timestamp))
+	assert.Equal(t, "metering/ru/1755850380/tidb/pool1/server1-", Default().Prefix(fields))
+	assert.Equal(t, "metering/ru/1755850380/tidb/", Default().Prefix(Fields{Timestamp: fields.Timestamp, Category: "tidb", SelfID: "server1"}))
+	assert.True(t, Default().CategoryFollowsTimestamp())
+	directory, ok := Default().TimestampDirectory()
+	assert.True(t, ok)
+	assert.Equal(t, "metering/ru/", directory)
+
+	parsed, err := Default().Parse(path)
+	require.NoError(t, err)
+	assert.Equal(t, fields, *parsed)
+
+	parsed, err = Default().Parse("metering/ru/1755850380/tidb/pool1/server1-0002.json.gz")
+	require.NoError(t, err)
+	assert.Equal(t, 2, parsed.Part)
+
+	for _, invalid := range []string{
+		"metering/ru/1755850380/tidb/server1-0.json.gz",
+		"metering/ru/1755850380/tidb/pool1/server-1-0.json.gz",
+		"metering/ru/abc/tidb/pool1/server1-0.json.gz",
+	} {
+		_, err := Default().Parse(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestSelfIDEscaping(t *testing.T) {
+	for selfID, rendered := range map[string]string{
+		"server1":       "server1",
+		"tidb-server-0": "tidb--server--0",
+		"tidb%2D0":      "tidb%2D0",
+		"-tidb--0-":     "--tidb----0--",
+	} {
+		fields := Fields{Timestamp: 1755850380, Category: "tidb", SharedPoolID: "pool-1", SelfID: selfID, Part: 3}
+		path := Default().Path(fields, 0)
+		assert.Equal(t, "metering/ru/1755850380/tidb/pool-1/"+rendered+"-3.json.gz", path)
+		assert.Equal(t, "metering/ru/1755850380/tidb/pool-1/"+rendered+"-", Default().Prefix(fields))
+		parsed, err := Default().Parse(path)
+		require.NoError(t, err)
+		assert.Equal(t, fields, *parsed)
+	}
+}
+
+func TestHiveLayout(t *testing.T) {
+	l := MustNew(HiveTemplate)
+	// 2025-08-22T08:13:00Z
+	fields := Fields{Timestamp: 1755850380, Category: "tidb", SharedPoolID: "pool1", SelfID: "server1", Part: 0}
+
+	path := l.Path(fields, 0)
+	assert.Equal(t, "metering/ru/year=2025/month=08/day=22/hour=08/minute=13/category=tidb/shared_pool_id=pool1/server1-0.json.gz", path)
+	assert.Equal(t, "metering/ru/year=2025/month=08/day=22/hour=08/minute=13/category=", l.TimestampPrefix(fields.Timestamp))
+	assert.True(t, l.CategoryFollowsTimestamp())
+	_, ok := l.TimestampDirectory()
+	assert.False(t, ok)
+	_, ok = MustNew("metering/ts-{timestamp}/{category}/{shared_pool_id}/{self_id}-{part}.json.gz").TimestampDirectory()
+	assert.False(t, ok)
+	assert.False(t, MustNew("metering/{category}/{timestamp}/{shared_pool_id}/{self_id}-{part}.json.gz").CategoryFollowsTimestamp())
+	assert.False(t, MustNew("metering/{timestamp}/{category}-{shared_pool_id}/{self_id}-{part}.json.gz").CategoryFollowsTimestamp())
+
+	parsed, err := l.Parse(path)
+	require.NoError(t, err)
+	assert.Equal(t, fields, *parsed)
+
+	_, err = l.Parse("metering/ru/year=2025/month=13/day=22/hour=08/minute=13/category=tidb/shared_pool_id=pool1/server1-0.json.gz")
+	assert.Error(t, err)
+}
+
+func TestNewInvalidTemplates(t *testing.T) {
+	for _, template := range []string{
+		"metering/{category}/{shared_pool_id}/{self_id}-{part}.json.gz",                        // no time
+		"metering/{timestamp}/{shared_pool_id}/{self_id}-{part}.json.gz",                       // no category
+		"metering/{timestamp}/{category}/{shared_pool_id}/{self_id}.json.gz",                   // no part
+		"metering/{timestamp}/{category}/{shared_pool_id}/{self_id}-{part}-{region}.json.gz",   // unknown
+		"metering/{timestamp}/{category}/{category}/{shared_pool_id}/{self_id}-{part}.json.gz", // duplicate
+		"metering/{year}/{month}/{day}/{category}/{shared_pool_id}/{self_id}-{part}.json.gz",   // no hour/minute
+	} {
+		_, err := New(template)
+		assert.Error(t, err, template)
+	}
+}
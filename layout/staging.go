@@ -0,0 +1,23 @@
+package layout
+
+import (
+	"strconv"
+	"strings"
+)
+
+// StagingPrefix prefix of the files written by writers in staging mode until they are finalized.
+// Staged files are outside of every layout, so readers never see them.
+const StagingPrefix = ".tmp/"
+
+// FinalizedPrefix prefix of the markers written when the files of a timestamp are finalized
+const FinalizedPrefix = "metering/finalized/"
+
+// StagingPath returns the path path is staged at
+func StagingPath(path string) string {
+	return StagingPrefix + strings.TrimPrefix(path, "/")
+}
+
+// FinalizedMarkerPrefix returns the path prefix of the finalize markers of timestamp
+func FinalizedMarkerPrefix(timestamp int64) string {
+	return FinalizedPrefix + strconv.FormatInt(timestamp, 10) + "/"
+}
@@ -0,0 +1,53 @@
+// Package logging defines the fields shared by SDK logs, so log pipelines can parse them, and bridges
+// SDK logs to log/slog.
+package logging
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Names of the fields shared by writer, reader and storage logs
+const (
+	// FieldProvider storage provider type, e.g. "s3"
+	FieldProvider = "provider"
+	// FieldBucket bucket or container of the storage provider
+	FieldBucket = "bucket"
+	// FieldPath object path
+	FieldPath = "path"
+	// FieldBytes size of the object or payload in bytes
+	FieldBytes = "bytes"
+	// FieldDuration duration of the operation
+	FieldDuration = "duration"
+)
+
+// Provider returns the storage provider type field
+func Provider(providerType string) zap.Field {
+	return zap.String(FieldProvider, providerType)
+}
+
+// Bucket returns the bucket field
+func Bucket(bucket string) zap.Field {
+	return zap.String(FieldBucket, bucket)
+}
+
+// Path returns the object path field
+func Path(path string) zap.Field {
+	return zap.String(FieldPath, path)
+}
+
+// Bytes returns the size field
+func Bytes(n int64) zap.Field {
+	return zap.Int64(FieldBytes, n)
+}
+
+// Duration returns the duration field
+func Duration(d time.Duration) zap.Field {
+	return zap.Duration(FieldDuration, d)
+}
+
+// Since returns the duration field of an operation started at start
+func Since(start time.Time) zap.Field {
+	return Duration(time.Since(start))
+}
@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})
+	logger := NewSlogLogger(handler).With(Provider("s3"), Bucket("metering"))
+
+	logger.Debug("dropped", Path("a"))
+	logger.Info("Successfully wrote page data",
+		Path("metering/ru/1755850380/tidb/pool1/server1-0.json.gz"),
+		Bytes(1024),
+		Duration(1500*time.Millisecond),
+		zap.Int("logical_clusters", 3),
+	)
+	logger.Named("writer").Warn("slow", zap.Error(assert.AnError))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	assert.Equal(t, "INFO", record["level"])
+	assert.Equal(t, "Successfully wrote page data", record["msg"])
+	assert.Equal(t, "s3", record[FieldProvider])
+	assert.Equal(t, "metering", record[FieldBucket])
+	assert.Equal(t, "metering/ru/1755850380/tidb/pool1/server1-0.json.gz", record[FieldPath])
+	assert.Equal(t, float64(1024), record[FieldBytes])
+	assert.Equal(t, float64(1500*time.Millisecond), record[FieldDuration])
+	assert.Equal(t, float64(3), record["logical_clusters"])
+	// fields keep their order
+	assert.Less(t, strings.Index(lines[0], `"path"`), strings.Index(lines[0], `"bytes"`))
+
+	record = nil
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &record))
+	assert.Equal(t, "WARN", record["level"])
+	assert.Equal(t, "writer", record["logger"])
+	assert.Equal(t, assert.AnError.Error(), record["error"])
+}
@@ -0,0 +1,219 @@
+// Package metrics provides optional Prometheus instrumentation for writers, readers and storage providers.
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "metering_sdk"
+
+// Result label values
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// Metrics holds the SDK collectors. All methods are safe to call on a nil *Metrics, which records nothing.
+type Metrics struct {
+	writes            *prometheus.CounterVec
+	writeDuration     *prometheus.HistogramVec
+	writeFailures     *prometheus.CounterVec
+	pagesWritten      *prometheus.CounterVec
+	bytesUploaded     *prometheus.CounterVec
+	reads             *prometheus.CounterVec
+	readDuration      *prometheus.HistogramVec
+	cacheRequests     *prometheus.CounterVec
+	storageOperations *prometheus.CounterVec
+	storageDuration   *prometheus.HistogramVec
+	replicationLag    *prometheus.GaugeVec
+}
+
+// NewMetrics creates the SDK collectors and registers them with reg.
+// Collectors already registered with reg, e.g. by another Metrics, are reused.
+func NewMetrics(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		writes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "writes_total",
+			Help:      "Number of Write calls by writer and result.",
+		}, []string{"writer", "result"}),
+		writeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "write_duration_seconds",
+			Help:      "Latency of Write calls by writer.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"writer"}),
+		writeFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "write_failures_total",
+			Help:      "Number of terminal write failures by writer and error class.",
+		}, []string{"writer", "class"}),
+		pagesWritten: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "pages_written_total",
+			Help:      "Number of files uploaded by writer.",
+		}, []string{"writer"}),
+		bytesUploaded: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "uploaded_bytes_total",
+			Help:      "Compressed bytes uploaded by writer.",
+		}, []string{"writer"}),
+		reads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "reads_total",
+			Help:      "Number of file reads by reader and result.",
+		}, []string{"reader", "result"}),
+		readDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "read_duration_seconds",
+			Help:      "Latency of file reads by reader.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"reader"}),
+		cacheRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_requests_total",
+			Help:      "Number of reader cache lookups by reader and result (hit or miss).",
+		}, []string{"reader", "result"}),
+		storageOperations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "storage_operations_total",
+			Help:      "Number of storage provider operations by operation and result.",
+		}, []string{"operation", "result"}),
+		storageDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "storage_operation_duration_seconds",
+			Help:      "Latency of storage provider operations by operation.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation"}),
+		replicationLag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "replication_lag_seconds",
+			Help:      "Age of the newest metering data replicated to each destination.",
+		}, []string{"destination"}),
+	}
+
+	var err error
+	if m.writes, err = register(reg, m.writes); err != nil {
+		return nil, err
+	}
+	if m.writeDuration, err = register(reg, m.writeDuration); err != nil {
+		return nil, err
+	}
+	if m.writeFailures, err = register(reg, m.writeFailures); err != nil {
+		return nil, err
+	}
+	if m.pagesWritten, err = register(reg, m.pagesWritten); err != nil {
+		return nil, err
+	}
+	if m.bytesUploaded, err = register(reg, m.bytesUploaded); err != nil {
+		return nil, err
+	}
+	if m.reads, err = register(reg, m.reads); err != nil {
+		return nil, err
+	}
+	if m.readDuration, err = register(reg, m.readDuration); err != nil {
+		return nil, err
+	}
+	if m.cacheRequests, err = register(reg, m.cacheRequests); err != nil {
+		return nil, err
+	}
+	if m.storageOperations, err = register(reg, m.storageOperations); err != nil {
+		return nil, err
+	}
+	if m.storageDuration, err = register(reg, m.storageDuration); err != nil {
+		return nil, err
+	}
+	if m.replicationLag, err = register(reg, m.replicationLag); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// register registers c with reg, returning the existing collector if an identical one is already registered
+func register[T prometheus.Collector](reg prometheus.Registerer, c T) (T, error) {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(T); ok {
+				return existing, nil
+			}
+		}
+		return c, err
+	}
+	return c, nil
+}
+
+// result returns the result label value for err
+func result(err error) string {
+	if err != nil {
+		return ResultFailure
+	}
+	return ResultSuccess
+}
+
+// ObserveWrite records a Write call of writer started at start
+func (m *Metrics) ObserveWrite(writer string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	m.writes.WithLabelValues(writer, result(err)).Inc()
+	m.writeDuration.WithLabelValues(writer).Observe(time.Since(start).Seconds())
+}
+
+// ObserveWriteFailure records a terminal write failure of the given error class
+func (m *Metrics) ObserveWriteFailure(writer string, class string) {
+	if m == nil {
+		return
+	}
+	m.writeFailures.WithLabelValues(writer, class).Inc()
+}
+
+// ObservePage records one uploaded file of size bytes
+func (m *Metrics) ObservePage(writer string, size int) {
+	if m == nil {
+		return
+	}
+	m.pagesWritten.WithLabelValues(writer).Inc()
+	m.bytesUploaded.WithLabelValues(writer).Add(float64(size))
+}
+
+// ObserveRead records a file read of reader started at start
+func (m *Metrics) ObserveRead(reader string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	m.reads.WithLabelValues(reader, result(err)).Inc()
+	m.readDuration.WithLabelValues(reader).Observe(time.Since(start).Seconds())
+}
+
+// ObserveCache records a reader cache lookup
+func (m *Metrics) ObserveCache(reader string, hit bool) {
+	if m == nil {
+		return
+	}
+	if hit {
+		m.cacheRequests.WithLabelValues(reader, "hit").Inc()
+	} else {
+		m.cacheRequests.WithLabelValues(reader, "miss").Inc()
+	}
+}
+
+// ObserveStorage records a storage provider operation started at start
+func (m *Metrics) ObserveStorage(operation string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+	m.storageOperations.WithLabelValues(operation, result(err)).Inc()
+	m.storageDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// ObserveReplicationLag records how far replication to destination is behind
+func (m *Metrics) ObserveReplicationLag(destination string, lag time.Duration) {
+	if m == nil {
+		return
+	}
+	m.replicationLag.WithLabelValues(destination).Set(lag.Seconds())
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pingcap/metering_sdk/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMetrics_ReusesRegisteredCollectors(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m1, err := NewMetrics(reg)
+	require.NoError(t, err)
+	m2, err := NewMetrics(reg)
+	require.NoError(t, err)
+
+	m1.ObserveWrite("metering", time.Now(), nil)
+	m2.ObserveWrite("metering", time.Now(), errors.New("boom"))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m1.writes.WithLabelValues("metering", ResultSuccess)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m1.writes.WithLabelValues("metering", ResultFailure)))
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+	assert.NotPanics(t, func() {
+		m.ObserveWrite("metering", time.Now(), nil)
+		m.ObserveWriteFailure("metering", "storage")
+		m.ObservePage("metering", 10)
+		m.ObserveRead("metering", time.Now(), nil)
+		m.ObserveCache("meta", true)
+		m.ObserveStorage("upload", time.Now(), nil)
+		m.ObserveReplicationLag("dr", time.Minute)
+	})
+
+	provider := storage.NewMemoryProvider()
+	assert.Same(t, provider, InstrumentProvider(provider, nil))
+}
+
+func TestInstrumentProvider(t *testing.T) {
+	m, err := NewMetrics(prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	provider := InstrumentProvider(storage.NewMemoryProvider(), m)
+	assert.Same(t, provider, InstrumentProvider(provider, m), "wrapping twice should be a no-op")
+
+	conditional, ok := provider.(storage.ConditionalUploader)
+	require.True(t, ok, "conditional upload support should be preserved")
+	stater, ok := provider.(storage.ObjectStater)
+	require.True(t, ok, "stats should be preserved")
+	pager, ok := provider.(storage.PageLister)
+	require.True(t, ok, "paginated listing should be preserved")
+	prefixes, ok := provider.(storage.PrefixLister)
+	require.True(t, ok, "delimiter-based listing should be preserved")
+	versioned, ok := provider.(storage.VersionedProvider)
+	require.True(t, ok, "object versions should be forwarded")
+
+	ctx := context.Background()
+	require.NoError(t, provider.Upload(ctx, "a", strings.NewReader("data")))
+	_, err = provider.Exists(ctx, "a")
+	require.NoError(t, err)
+	_, err = provider.Download(ctx, "missing")
+	require.Error(t, err)
+	assert.ErrorIs(t, conditional.UploadIfNotExists(ctx, "a", strings.NewReader("data")), storage.ErrObjectExists)
+	_, err = stater.Stat(ctx, "a")
+	require.NoError(t, err)
+	require.NoError(t, pager.ListPages(ctx, "", func([]string) error { return nil }))
+	_, err = prefixes.ListCommonPrefixes(ctx, "", "/")
+	require.NoError(t, err)
+	_, err = versioned.ListVersions(ctx, "a")
+	assert.ErrorIs(t, err, storage.ErrVersioningNotSupported)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.storageOperations.WithLabelValues("upload", ResultSuccess)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.storageOperations.WithLabelValues("exists", ResultSuccess)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.storageOperations.WithLabelValues("download", ResultFailure)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.storageOperations.WithLabelValues("upload_if_not_exists", ResultFailure)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.storageOperations.WithLabelValues("stat", ResultSuccess)))
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.storageOperations.WithLabelValues("list", ResultSuccess)))
+}
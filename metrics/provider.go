@@ -0,0 +1,171 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/pingcap/metering_sdk/storage"
+)
+
+// instrumentedProvider records metrics for every storage operation
+type instrumentedProvider struct {
+	storage.ObjectStorageProvider
+	metrics *Metrics
+}
+
+// conditionalInstrumentedProvider additionally forwards conditional uploads
+type conditionalInstrumentedProvider struct {
+	*instrumentedProvider
+	conditional storage.ConditionalUploader
+}
+
+// InstrumentProvider wraps provider so that every operation is recorded in m.
+// If m is nil, provider is returned unchanged. Conditional uploads, paginated listing, stats, copies and
+// object versions are preserved.
+func InstrumentProvider(provider storage.ObjectStorageProvider, m *Metrics) storage.ObjectStorageProvider {
+	if m == nil || provider == nil {
+		return provider
+	}
+	if _, ok := provider.(*instrumentedProvider); ok {
+		return provider
+	}
+	if _, ok := provider.(*conditionalInstrumentedProvider); ok {
+		return provider
+	}
+	p := &instrumentedProvider{ObjectStorageProvider: provider, metrics: m}
+	if conditional, ok := provider.(storage.ConditionalUploader); ok {
+		return &conditionalInstrumentedProvider{instrumentedProvider: p, conditional: conditional}
+	}
+	return p
+}
+
+// Upload implements ObjectStorageProvider interface
+func (p *instrumentedProvider) Upload(ctx context.Context, path string, data io.Reader) error {
+	start := time.Now()
+	err := p.ObjectStorageProvider.Upload(ctx, path, data)
+	p.metrics.ObserveStorage("upload", start, err)
+	return err
+}
+
+// Download implements ObjectStorageProvider interface
+func (p *instrumentedProvider) Download(ctx context.Context, path string) (io.ReadCloser, error) {
+	start := time.Now()
+	rc, err := p.ObjectStorageProvider.Download(ctx, path)
+	p.metrics.ObserveStorage("download", start, err)
+	return rc, err
+}
+
+// Delete implements ObjectStorageProvider interface
+func (p *instrumentedProvider) Delete(ctx context.Context, path string) error {
+	start := time.Now()
+	err := p.ObjectStorageProvider.Delete(ctx, path)
+	p.metrics.ObserveStorage("delete", start, err)
+	return err
+}
+
+// Exists implements ObjectStorageProvider interface
+func (p *instrumentedProvider) Exists(ctx context.Context, path string) (bool, error) {
+	start := time.Now()
+	exists, err := p.ObjectStorageProvider.Exists(ctx, path)
+	p.metrics.ObserveStorage("exists", start, err)
+	return exists, err
+}
+
+// List implements ObjectStorageProvider interface
+func (p *instrumentedProvider) List(ctx context.Context, prefix string) ([]string, error) {
+	start := time.Now()
+	objects, err := p.ObjectStorageProvider.List(ctx, prefix)
+	p.metrics.ObserveStorage("list", start, err)
+	return objects, err
+}
+
+// Copy implements storage.Copier interface
+func (p *instrumentedProvider) Copy(ctx context.Context, src, dst string) error {
+	start := time.Now()
+	err := storage.Copy(ctx, p.ObjectStorageProvider, src, dst)
+	p.metrics.ObserveStorage("copy", start, err)
+	return err
+}
+
+// Move implements storage.Mover interface
+func (p *instrumentedProvider) Move(ctx context.Context, src, dst string) error {
+	start := time.Now()
+	err := storage.Move(ctx, p.ObjectStorageProvider, src, dst)
+	p.metrics.ObserveStorage("move", start, err)
+	return err
+}
+
+// ListPages implements storage.PageLister interface, recording the whole listing as one operation
+func (p *instrumentedProvider) ListPages(ctx context.Context, prefix string, fn func(page []string) error) error {
+	start := time.Now()
+	err := storage.ListPages(ctx, p.ObjectStorageProvider, prefix, fn)
+	p.metrics.ObserveStorage("list", start, err)
+	return err
+}
+
+// ListPage implements storage.PageTokenLister interface
+func (p *instrumentedProvider) ListPage(ctx context.Context, prefix, token string, limit int) ([]string, string, error) {
+	start := time.Now()
+	objects, next, err := storage.ListPage(ctx, p.ObjectStorageProvider, prefix, token, limit)
+	p.metrics.ObserveStorage("list", start, err)
+	return objects, next, err
+}
+
+// ListCommonPrefixes implements storage.PrefixLister interface
+func (p *instrumentedProvider) ListCommonPrefixes(ctx context.Context, prefix, delimiter string) ([]string, error) {
+	start := time.Now()
+	prefixes, err := storage.ListCommonPrefixes(ctx, p.ObjectStorageProvider, prefix, delimiter)
+	p.metrics.ObserveStorage("list", start, err)
+	return prefixes, err
+}
+
+// ListAttributes implements storage.AttributeLister interface
+func (p *instrumentedProvider) ListAttributes(ctx context.Context, prefix string) ([]storage.ObjectAttributes, error) {
+	start := time.Now()
+	objects, err := storage.ListAttributes(ctx, p.ObjectStorageProvider, prefix)
+	p.metrics.ObserveStorage("list", start, err)
+	return objects, err
+}
+
+// Stat implements storage.ObjectStater interface
+func (p *instrumentedProvider) Stat(ctx context.Context, path string) (*storage.ObjectAttributes, error) {
+	start := time.Now()
+	attrs, err := storage.Stat(ctx, p.ObjectStorageProvider, path)
+	p.metrics.ObserveStorage("stat", start, err)
+	return attrs, err
+}
+
+// DownloadVersion implements storage.VersionedProvider interface, failing with
+// storage.ErrVersioningNotSupported if the wrapped provider doesn't support versions
+func (p *instrumentedProvider) DownloadVersion(ctx context.Context, path string, versionID string) (io.ReadCloser, error) {
+	versioned, ok := p.ObjectStorageProvider.(storage.VersionedProvider)
+	if !ok {
+		return nil, storage.ErrVersioningNotSupported
+	}
+	start := time.Now()
+	rc, err := versioned.DownloadVersion(ctx, path, versionID)
+	p.metrics.ObserveStorage("download_version", start, err)
+	return rc, err
+}
+
+// ListVersions implements storage.VersionedProvider interface, failing with
+// storage.ErrVersioningNotSupported if the wrapped provider doesn't support versions
+func (p *instrumentedProvider) ListVersions(ctx context.Context, path string) ([]storage.ObjectVersion, error) {
+	versioned, ok := p.ObjectStorageProvider.(storage.VersionedProvider)
+	if !ok {
+		return nil, storage.ErrVersioningNotSupported
+	}
+	start := time.Now()
+	versions, err := versioned.ListVersions(ctx, path)
+	p.metrics.ObserveStorage("list_versions", start, err)
+	return versions, err
+}
+
+// UploadIfNotExists implements storage.ConditionalUploader interface
+func (p *conditionalInstrumentedProvider) UploadIfNotExists(ctx context.Context, path string, data io.Reader) error {
+	start := time.Now()
+	err := p.conditional.UploadIfNotExists(ctx, path, data)
+	p.metrics.ObserveStorage("upload_if_not_exists", start, err)
+	return err
+}
@@ -0,0 +1,566 @@
+// Protobuf encoding of metering files, used by codec/pbcodec.
+// Regenerate metering.pb.go with `make proto`.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: metering.proto
+
+package meteringpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// MeteringValue a single metering value with its unit, see common.MeteringValue
+type MeteringValue struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// the numeric value, rounded when value_float is set
+	Value uint64 `protobuf:"varint,1,opt,name=value,proto3" json:"value,omitempty"`
+	// the fractional value, takes precedence over value when set
+	ValueFloat *float64 `protobuf:"fixed64,2,opt,name=value_float,json=valueFloat,proto3,oneof" json:"value_float,omitempty"`
+	// the unit of measurement
+	Unit          string `protobuf:"bytes,3,opt,name=unit,proto3" json:"unit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MeteringValue) Reset() {
+	*x = MeteringValue{}
+	mi := &file_metering_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MeteringValue) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MeteringValue) ProtoMessage() {}
+
+func (x *MeteringValue) ProtoReflect() protoreflect.Message {
+	mi := &file_metering_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MeteringValue.ProtoReflect.Descriptor instead.
+func (*MeteringValue) Descriptor() ([]byte, []int) {
+	return file_metering_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *MeteringValue) GetValue() uint64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+func (x *MeteringValue) GetValueFloat() float64 {
+	if x != nil && x.ValueFloat != nil {
+		return *x.ValueFloat
+	}
+	return 0
+}
+
+func (x *MeteringValue) GetUnit() string {
+	if x != nil {
+		return x.Unit
+	}
+	return ""
+}
+
+// Value a field of a logical cluster entry
+type Value struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Kind:
+	//
+	//	*Value_MeteringValue
+	//	*Value_StringValue
+	//	*Value_NumberValue
+	//	*Value_BoolValue
+	//	*Value_JsonValue
+	Kind          isValue_Kind `protobuf_oneof:"kind"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Value) Reset() {
+	*x = Value{}
+	mi := &file_metering_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Value) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Value) ProtoMessage() {}
+
+func (x *Value) ProtoReflect() protoreflect.Message {
+	mi := &file_metering_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Value.ProtoReflect.Descriptor instead.
+func (*Value) Descriptor() ([]byte, []int) {
+	return file_metering_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Value) GetKind() isValue_Kind {
+	if x != nil {
+		return x.Kind
+	}
+	return nil
+}
+
+func (x *Value) GetMeteringValue() *MeteringValue {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_MeteringValue); ok {
+			return x.MeteringValue
+		}
+	}
+	return nil
+}
+
+func (x *Value) GetStringValue() string {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_StringValue); ok {
+			return x.StringValue
+		}
+	}
+	return ""
+}
+
+func (x *Value) GetNumberValue() float64 {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_NumberValue); ok {
+			return x.NumberValue
+		}
+	}
+	return 0
+}
+
+func (x *Value) GetBoolValue() bool {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_BoolValue); ok {
+			return x.BoolValue
+		}
+	}
+	return false
+}
+
+func (x *Value) GetJsonValue() []byte {
+	if x != nil {
+		if x, ok := x.Kind.(*Value_JsonValue); ok {
+			return x.JsonValue
+		}
+	}
+	return nil
+}
+
+type isValue_Kind interface {
+	isValue_Kind()
+}
+
+type Value_MeteringValue struct {
+	MeteringValue *MeteringValue `protobuf:"bytes,1,opt,name=metering_value,json=meteringValue,proto3,oneof"`
+}
+
+type Value_StringValue struct {
+	StringValue string `protobuf:"bytes,2,opt,name=string_value,json=stringValue,proto3,oneof"`
+}
+
+type Value_NumberValue struct {
+	// numbers are decoded as float64, like JSON numbers
+	NumberValue float64 `protobuf:"fixed64,3,opt,name=number_value,json=numberValue,proto3,oneof"`
+}
+
+type Value_BoolValue struct {
+	BoolValue bool `protobuf:"varint,4,opt,name=bool_value,json=boolValue,proto3,oneof"`
+}
+
+type Value_JsonValue struct {
+	// any other value, e.g. nested objects, encoded as JSON
+	JsonValue []byte `protobuf:"bytes,5,opt,name=json_value,json=jsonValue,proto3,oneof"`
+}
+
+func (*Value_MeteringValue) isValue_Kind() {}
+
+func (*Value_StringValue) isValue_Kind() {}
+
+func (*Value_NumberValue) isValue_Kind() {}
+
+func (*Value_BoolValue) isValue_Kind() {}
+
+func (*Value_JsonValue) isValue_Kind() {}
+
+// Entry the metering data of one logical cluster
+type Entry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Fields        map[string]*Value      `protobuf:"bytes,1,rep,name=fields,proto3" json:"fields,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Entry) Reset() {
+	*x = Entry{}
+	mi := &file_metering_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Entry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Entry) ProtoMessage() {}
+
+func (x *Entry) ProtoReflect() protoreflect.Message {
+	mi := &file_metering_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Entry.ProtoReflect.Descriptor instead.
+func (*Entry) Descriptor() ([]byte, []int) {
+	return file_metering_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Entry) GetFields() map[string]*Value {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+// MeteringData metering data of a component, see common.MeteringData
+type MeteringData struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// minute-level timestamp
+	Timestamp int64 `protobuf:"varint,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// service category identifier
+	Category string `protobuf:"bytes,2,opt,name=category,proto3" json:"category,omitempty"`
+	// component ID
+	SelfId string `protobuf:"bytes,3,opt,name=self_id,json=selfId,proto3" json:"self_id,omitempty"`
+	// shared pool cluster ID
+	SharedPoolId string `protobuf:"bytes,4,opt,name=shared_pool_id,json=sharedPoolId,proto3" json:"shared_pool_id,omitempty"`
+	// logical cluster metering data list
+	Data []*Entry `protobuf:"bytes,6,rep,name=data,proto3" json:"data,omitempty"`
+	// format the file was written with, see common.CurrentFormatVersion; 0 for files predating it
+	FormatVersion int32 `protobuf:"varint,7,opt,name=format_version,json=formatVersion,proto3" json:"format_version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MeteringData) Reset() {
+	*x = MeteringData{}
+	mi := &file_metering_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MeteringData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MeteringData) ProtoMessage() {}
+
+func (x *MeteringData) ProtoReflect() protoreflect.Message {
+	mi := &file_metering_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MeteringData.ProtoReflect.Descriptor instead.
+func (*MeteringData) Descriptor() ([]byte, []int) {
+	return file_metering_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *MeteringData) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *MeteringData) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *MeteringData) GetSelfId() string {
+	if x != nil {
+		return x.SelfId
+	}
+	return ""
+}
+
+func (x *MeteringData) GetSharedPoolId() string {
+	if x != nil {
+		return x.SharedPoolId
+	}
+	return ""
+}
+
+func (x *MeteringData) GetData() []*Entry {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *MeteringData) GetFormatVersion() int32 {
+	if x != nil {
+		return x.FormatVersion
+	}
+	return 0
+}
+
+// PageMeteringData one page of metering data, the content of a metering file. Wire compatible with
+// MeteringData, which it extends with the part number
+type PageMeteringData struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// minute-level timestamp
+	Timestamp int64 `protobuf:"varint,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	// service category identifier
+	Category string `protobuf:"bytes,2,opt,name=category,proto3" json:"category,omitempty"`
+	// component ID
+	SelfId string `protobuf:"bytes,3,opt,name=self_id,json=selfId,proto3" json:"self_id,omitempty"`
+	// shared pool cluster ID
+	SharedPoolId string `protobuf:"bytes,4,opt,name=shared_pool_id,json=sharedPoolId,proto3" json:"shared_pool_id,omitempty"`
+	// pagination number
+	Part int32 `protobuf:"varint,5,opt,name=part,proto3" json:"part,omitempty"`
+	// current page logical cluster metering data
+	Data []*Entry `protobuf:"bytes,6,rep,name=data,proto3" json:"data,omitempty"`
+	// format the file was written with, see common.CurrentFormatVersion; 0 for files predating it
+	FormatVersion int32 `protobuf:"varint,7,opt,name=format_version,json=formatVersion,proto3" json:"format_version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PageMeteringData) Reset() {
+	*x = PageMeteringData{}
+	mi := &file_metering_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PageMeteringData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PageMeteringData) ProtoMessage() {}
+
+func (x *PageMeteringData) ProtoReflect() protoreflect.Message {
+	mi := &file_metering_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PageMeteringData.ProtoReflect.Descriptor instead.
+func (*PageMeteringData) Descriptor() ([]byte, []int) {
+	return file_metering_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *PageMeteringData) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *PageMeteringData) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *PageMeteringData) GetSelfId() string {
+	if x != nil {
+		return x.SelfId
+	}
+	return ""
+}
+
+func (x *PageMeteringData) GetSharedPoolId() string {
+	if x != nil {
+		return x.SharedPoolId
+	}
+	return ""
+}
+
+func (x *PageMeteringData) GetPart() int32 {
+	if x != nil {
+		return x.Part
+	}
+	return 0
+}
+
+func (x *PageMeteringData) GetData() []*Entry {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *PageMeteringData) GetFormatVersion() int32 {
+	if x != nil {
+		return x.FormatVersion
+	}
+	return 0
+}
+
+var File_metering_proto protoreflect.FileDescriptor
+
+const file_metering_proto_rawDesc = "" +
+	"\n" +
+	"\x0emetering.proto\x12\vmetering.v1\"o\n" +
+	"\rMeteringValue\x12\x14\n" +
+	"\x05value\x18\x01 \x01(\x04R\x05value\x12$\n" +
+	"\vvalue_float\x18\x02 \x01(\x01H\x00R\n" +
+	"valueFloat\x88\x01\x01\x12\x12\n" +
+	"\x04unit\x18\x03 \x01(\tR\x04unitB\x0e\n" +
+	"\f_value_float\"\xe0\x01\n" +
+	"\x05Value\x12C\n" +
+	"\x0emetering_value\x18\x01 \x01(\v2\x1a.metering.v1.MeteringValueH\x00R\rmeteringValue\x12#\n" +
+	"\fstring_value\x18\x02 \x01(\tH\x00R\vstringValue\x12#\n" +
+	"\fnumber_value\x18\x03 \x01(\x01H\x00R\vnumberValue\x12\x1f\n" +
+	"\n" +
+	"bool_value\x18\x04 \x01(\bH\x00R\tboolValue\x12\x1f\n" +
+	"\n" +
+	"json_value\x18\x05 \x01(\fH\x00R\tjsonValueB\x06\n" +
+	"\x04kind\"\x8e\x01\n" +
+	"\x05Entry\x126\n" +
+	"\x06fields\x18\x01 \x03(\v2\x1e.metering.v1.Entry.FieldsEntryR\x06fields\x1aM\n" +
+	"\vFieldsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12(\n" +
+	"\x05value\x18\x02 \x01(\v2\x12.metering.v1.ValueR\x05value:\x028\x01\"\xdc\x01\n" +
+	"\fMeteringData\x12\x1c\n" +
+	"\ttimestamp\x18\x01 \x01(\x03R\ttimestamp\x12\x1a\n" +
+	"\bcategory\x18\x02 \x01(\tR\bcategory\x12\x17\n" +
+	"\aself_id\x18\x03 \x01(\tR\x06selfId\x12$\n" +
+	"\x0eshared_pool_id\x18\x04 \x01(\tR\fsharedPoolId\x12&\n" +
+	"\x04data\x18\x06 \x03(\v2\x12.metering.v1.EntryR\x04data\x12%\n" +
+	"\x0eformat_version\x18\a \x01(\x05R\rformatVersionJ\x04\b\x05\x10\x06\"\xee\x01\n" +
+	"\x10PageMeteringData\x12\x1c\n" +
+	"\ttimestamp\x18\x01 \x01(\x03R\ttimestamp\x12\x1a\n" +
+	"\bcategory\x18\x02 \x01(\tR\bcategory\x12\x17\n" +
+	"\aself_id\x18\x03 \x01(\tR\x06selfId\x12$\n" +
+	"\x0eshared_pool_id\x18\x04 \x01(\tR\fsharedPoolId\x12\x12\n" +
+	"\x04part\x18\x05 \x01(\x05R\x04part\x12&\n" +
+	"\x04data\x18\x06 \x03(\v2\x12.metering.v1.EntryR\x04data\x12%\n" +
+	"\x0eformat_version\x18\a \x01(\x05R\rformatVersionB2Z0github.com/pingcap/metering_sdk/proto/meteringpbb\x06proto3"
+
+var (
+	file_metering_proto_rawDescOnce sync.Once
+	file_metering_proto_rawDescData []byte
+)
+
+func file_metering_proto_rawDescGZIP() []byte {
+	file_metering_proto_rawDescOnce.Do(func() {
+		file_metering_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_metering_proto_rawDesc), len(file_metering_proto_rawDesc)))
+	})
+	return file_metering_proto_rawDescData
+}
+
+var file_metering_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_metering_proto_goTypes = []any{
+	(*MeteringValue)(nil),    // 0: metering.v1.MeteringValue
+	(*Value)(nil),            // 1: metering.v1.Value
+	(*Entry)(nil),            // 2: metering.v1.Entry
+	(*MeteringData)(nil),     // 3: metering.v1.MeteringData
+	(*PageMeteringData)(nil), // 4: metering.v1.PageMeteringData
+	nil,                      // 5: metering.v1.Entry.FieldsEntry
+}
+var file_metering_proto_depIdxs = []int32{
+	0, // 0: metering.v1.Value.metering_value:type_name -> metering.v1.MeteringValue
+	5, // 1: metering.v1.Entry.fields:type_name -> metering.v1.Entry.FieldsEntry
+	2, // 2: metering.v1.MeteringData.data:type_name -> metering.v1.Entry
+	2, // 3: metering.v1.PageMeteringData.data:type_name -> metering.v1.Entry
+	1, // 4: metering.v1.Entry.FieldsEntry.value:type_name -> metering.v1.Value
+	5, // [5:5] is the sub-list for method output_type
+	5, // [5:5] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_metering_proto_init() }
+func file_metering_proto_init() {
+	if File_metering_proto != nil {
+		return
+	}
+	file_metering_proto_msgTypes[0].OneofWrappers = []any{}
+	file_metering_proto_msgTypes[1].OneofWrappers = []any{
+		(*Value_MeteringValue)(nil),
+		(*Value_StringValue)(nil),
+		(*Value_NumberValue)(nil),
+		(*Value_BoolValue)(nil),
+		(*Value_JsonValue)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_metering_proto_rawDesc), len(file_metering_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_metering_proto_goTypes,
+		DependencyIndexes: file_metering_proto_depIdxs,
+		MessageInfos:      file_metering_proto_msgTypes,
+	}.Build()
+	File_metering_proto = out.File
+	file_metering_proto_goTypes = nil
+	file_metering_proto_depIdxs = nil
+}
@@ -0,0 +1,146 @@
+// Package checkpoint persists the progress of metering data consumers in object storage.
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/metering_sdk/storage"
+)
+
+// DefaultPrefix default path prefix of checkpoints
+const DefaultPrefix = "metering/checkpoints"
+
+// ErrConflict is returned by Save when another consumer saved the checkpoint of the group after it was loaded
+var ErrConflict = errors.New("checkpoint was updated concurrently")
+
+// Checkpoint progress of a consumer group
+type Checkpoint struct {
+	Timestamp int64     `json:"timestamp"`      // last minute-level timestamp fully processed
+	File      string    `json:"file,omitempty"` // last file processed, for consumers checkpointing within a minute
+	UpdatedAt time.Time `json:"updated_at"`     // time the checkpoint was saved
+	// Generation generation the checkpoint was loaded at, 0 if none was saved yet. Save only succeeds
+	// if it is still the latest one
+	Generation int64 `json:"-"`
+}
+
+// Store persists checkpoints per consumer group with compare-and-swap semantics. Every save creates
+// the object {prefix}/{group}/{generation}.json with a conditional upload, so of two consumers saving
+// the same generation only one succeeds. It is safe for concurrent use.
+type Store struct {
+	provider    storage.ObjectStorageProvider
+	conditional storage.ConditionalUploader
+	prefix      string
+}
+
+// NewStore creates a checkpoint store keeping checkpoints under prefix in provider, usually the
+// provider the metering data is read from. prefix defaults to DefaultPrefix. The provider must
+// support conditional uploads.
+func NewStore(provider storage.ObjectStorageProvider, prefix string) (*Store, error) {
+	conditional, ok := provider.(storage.ConditionalUploader)
+	if !ok {
+		return nil, fmt.Errorf("checkpoint store requires a provider with conditional upload support")
+	}
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	return &Store{provider: provider, conditional: conditional, prefix: prefix}, nil
+}
+
+// Load returns the latest checkpoint of group, or nil if none has been saved yet
+func (s *Store) Load(ctx context.Context, group string) (*Checkpoint, error) {
+	if err := validateGroup(group); err != nil {
+		return nil, err
+	}
+	generation, err := s.latestGeneration(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	if generation == 0 {
+		return nil, nil
+	}
+
+	rc, err := s.provider.Download(ctx, s.path(group, generation))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download checkpoint of %s: %w", group, err)
+	}
+	defer rc.Close()
+	checkpoint := &Checkpoint{Generation: generation}
+	if err := json.NewDecoder(rc).Decode(checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint of %s: %w", group, err)
+	}
+	return checkpoint, nil
+}
+
+// Save saves checkpoint as the new checkpoint of group if checkpoint.Generation is still the latest
+// generation, and advances checkpoint.Generation. It returns ErrConflict otherwise; Load the
+// checkpoint again before retrying. Use a checkpoint with Generation 0 to save the first one.
+func (s *Store) Save(ctx context.Context, group string, checkpoint *Checkpoint) error {
+	if err := validateGroup(group); err != nil {
+		return err
+	}
+	saved := *checkpoint
+	saved.UpdatedAt = time.Now().UTC()
+	data, err := json.Marshal(&saved)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+
+	generation := checkpoint.Generation + 1
+	err = s.conditional.UploadIfNotExists(ctx, s.path(group, generation), bytes.NewReader(data))
+	if errors.Is(err, storage.ErrObjectExists) {
+		return fmt.Errorf("%w: group %s, generation %d", ErrConflict, group, checkpoint.Generation)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to upload checkpoint of %s: %w", group, err)
+	}
+	checkpoint.UpdatedAt = saved.UpdatedAt
+	checkpoint.Generation = generation
+
+	// Keep the previous generation for consumers that listed it just now, drop older ones
+	if generation > 2 {
+		_ = s.provider.Delete(ctx, s.path(group, generation-2))
+	}
+	return nil
+}
+
+// latestGeneration returns the latest saved generation of group, or 0 if there is none
+func (s *Store) latestGeneration(ctx context.Context, group string) (int64, error) {
+	files, err := s.provider.List(ctx, s.prefix+"/"+group+"/")
+	if err != nil {
+		return 0, fmt.Errorf("failed to list checkpoints of %s: %w", group, err)
+	}
+	var latest int64
+	for _, file := range files {
+		name, ok := strings.CutSuffix(path.Base(file), ".json")
+		if !ok {
+			continue
+		}
+		generation, err := strconv.ParseInt(name, 10, 64)
+		if err == nil && generation > latest {
+			latest = generation
+		}
+	}
+	return latest, nil
+}
+
+// path returns the path of a checkpoint generation, zero-padded so generations sort by name
+func (s *Store) path(group string, generation int64) string {
+	return fmt.Sprintf("%s/%s/%020d.json", s.prefix, group, generation)
+}
+
+// validateGroup validates a consumer group name
+func validateGroup(group string) error {
+	if group == "" || strings.Contains(group, "/") {
+		return fmt.Errorf("invalid consumer group %q, must be non-empty without slashes", group)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package checkpoint
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pingcap/metering_sdk/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	provider := storage.NewMemoryProvider()
+	store, err := NewStore(provider, "")
+	require.NoError(t, err)
+
+	checkpoint, err := store.Load(ctx, "billing")
+	require.NoError(t, err)
+	assert.Nil(t, checkpoint)
+
+	// Two consumers start from scratch, only the first save wins
+	first := &Checkpoint{Timestamp: 1755687600}
+	require.NoError(t, store.Save(ctx, "billing", first))
+	assert.Equal(t, int64(1), first.Generation)
+	assert.ErrorIs(t, store.Save(ctx, "billing", &Checkpoint{Timestamp: 1755687660}), ErrConflict)
+
+	for ts := int64(1755687660); ts <= 1755687840; ts += 60 {
+		first.Timestamp = ts
+		first.File = "metering/ru/1755687840/tidb/pool1/server1-0.json.gz"
+		require.NoError(t, store.Save(ctx, "billing", first))
+	}
+	loaded, err := store.Load(ctx, "billing")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1755687840), loaded.Timestamp)
+	assert.Equal(t, first.File, loaded.File)
+	assert.Equal(t, int64(5), loaded.Generation)
+	assert.False(t, loaded.UpdatedAt.IsZero())
+
+	// Only the last two generations are kept
+	assert.Len(t, provider.Snapshot(), 2)
+
+	// Groups are independent
+	other, err := store.Load(ctx, "audit")
+	require.NoError(t, err)
+	assert.Nil(t, other)
+
+	_, err = store.Load(ctx, "a/b")
+	assert.Error(t, err)
+}
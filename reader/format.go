@@ -0,0 +1,29 @@
+package reader
+
+import (
+	"fmt"
+
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/pingcap/metering_sdk/logging"
+	"go.uber.org/zap"
+)
+
+// CheckFormatVersion validates the format_version of the file at path, 0 for files predating it. Files
+// of a version newer than common.CurrentFormatVersion fail with ErrUnsupportedFormat if strict, otherwise
+// a warning is logged and the fields known to this SDK are read on a best-effort basis.
+func CheckFormatVersion(logger *zap.Logger, path string, version int, strict bool) error {
+	switch {
+	case version < 0:
+		return fmt.Errorf("%w: negative format version %d", ErrInvalidFormat, version)
+	case version <= common.CurrentFormatVersion:
+		return nil
+	case strict:
+		return fmt.Errorf("%w: %s has format version %d, newer than %d", ErrUnsupportedFormat, path, version, common.CurrentFormatVersion)
+	}
+	logger.Warn("File written in a newer format version, reading known fields only",
+		logging.Path(path),
+		zap.Int("format_version", version),
+		zap.Int("supported_format_version", common.CurrentFormatVersion),
+	)
+	return nil
+}
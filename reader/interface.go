@@ -13,6 +13,12 @@ var (
 	ErrFileNotFound = errors.New("file not found")
 	// ErrInvalidFormat invalid file format error
 	ErrInvalidFormat = errors.New("invalid file format")
+	// ErrClusterDeleted the latest metadata of the cluster is a tombstone written by MetaWriter.Delete
+	ErrClusterDeleted = errors.New("cluster deleted")
+	// ErrReaderClosed error when reading from a reader that has been closed
+	ErrReaderClosed = errors.New("reader closed")
+	// ErrUnsupportedFormat the file was written in a format version newer than this SDK supports
+	ErrUnsupportedFormat = errors.New("unsupported file format version")
 )
 
 // MetaReader metadata reader interface
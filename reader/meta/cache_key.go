@@ -0,0 +1,125 @@
+package metareader
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CacheKeyMode selects the timestamp metadata is cached under
+type CacheKeyMode string
+
+const (
+	// CacheKeyTimestamp caches metadata under the requested timestamp, so each distinct timestamp
+	// read is cached separately even if it resolves to the same file
+	CacheKeyTimestamp CacheKeyMode = "timestamp"
+	// CacheKeyModifyTS caches metadata once under the ModifyTS it resolves to, with a small index of the
+	// requested timestamp ranges known to resolve to it
+	CacheKeyModifyTS CacheKeyMode = "modify_ts"
+	// CacheKeyBucket truncates requested timestamps to CacheConfig.BucketSize, reads resolve as of the
+	// start of their bucket
+	CacheKeyBucket CacheKeyMode = "bucket"
+)
+
+const (
+	// maxResolvedRanges ranges indexed per cluster in CacheKeyModifyTS mode, the oldest are dropped first
+	maxResolvedRanges = 64
+	// maxResolvedKeys clusters indexed in CacheKeyModifyTS mode before the index is reset
+	maxResolvedKeys = 10000
+)
+
+// resolvedRange requested timestamps from..to all resolve to the metadata of modifyTS
+type resolvedRange struct {
+	from, to int64
+	modifyTS int64
+}
+
+// cacheKeys builds meta cache keys for the configured CacheKeyMode
+type cacheKeys struct {
+	mode   CacheKeyMode
+	bucket int64 // bucket size in seconds
+
+	mu     sync.Mutex
+	ranges map[string][]resolvedRange
+}
+
+// newCacheKeys creates the cache keys of a cache configuration
+func newCacheKeys(cacheConfig *CacheConfig) (*cacheKeys, error) {
+	keys := &cacheKeys{mode: cacheConfig.KeyMode}
+	switch keys.mode {
+	case "":
+		keys.mode = CacheKeyTimestamp
+	case CacheKeyTimestamp:
+	case CacheKeyModifyTS:
+		keys.ranges = make(map[string][]resolvedRange)
+	case CacheKeyBucket:
+		keys.bucket = int64(cacheConfig.BucketSize / time.Second)
+		if keys.bucket <= 0 {
+			return nil, fmt.Errorf("cache bucket size must be at least 1s, got %s", cacheConfig.BucketSize)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported cache key mode: %s", keys.mode)
+	}
+	return keys, nil
+}
+
+// timestamp returns the timestamp a read at timestamp resolves as of, its bucket start in CacheKeyBucket mode
+func (k *cacheKeys) timestamp(timestamp int64) int64 {
+	if k == nil || k.mode != CacheKeyBucket {
+		return timestamp
+	}
+	start := timestamp - timestamp%k.bucket
+	if start > timestamp {
+		start -= k.bucket
+	}
+	return start
+}
+
+// lookup returns the key metadata read at timestamp is cached under, false if it is unknown
+func (k *cacheKeys) lookup(prefix string, timestamp int64) (string, bool) {
+	if k.mode != CacheKeyModifyTS {
+		return fmt.Sprintf("%s:%d", prefix, timestamp), true
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for _, rng := range k.ranges[prefix] {
+		if rng.from <= timestamp && timestamp <= rng.to {
+			return fmt.Sprintf("%s:%d", prefix, rng.modifyTS), true
+		}
+	}
+	return "", false
+}
+
+// store returns the key metadata read at timestamp and resolved to modifyTS is cached under
+func (k *cacheKeys) store(prefix string, timestamp, modifyTS int64) string {
+	if k.mode != CacheKeyModifyTS {
+		return fmt.Sprintf("%s:%d", prefix, timestamp)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	// Resolution is monotonic, so every timestamp between two resolving to modifyTS resolves to it too
+	merged := resolvedRange{from: timestamp, to: timestamp, modifyTS: modifyTS}
+	for _, rng := range k.ranges[prefix] {
+		if rng.modifyTS == modifyTS {
+			merged.from = min(merged.from, rng.from)
+			merged.to = max(merged.to, rng.to)
+		}
+	}
+	// Ranges overlapping the merged one are outdated, metadata was written since they were indexed
+	ranges := make([]resolvedRange, 0, len(k.ranges[prefix])+1)
+	for _, rng := range k.ranges[prefix] {
+		if rng.to < merged.from || rng.from > merged.to {
+			ranges = append(ranges, rng)
+		}
+	}
+	ranges = append(ranges, merged)
+	if len(ranges) > maxResolvedRanges {
+		ranges = ranges[len(ranges)-maxResolvedRanges:]
+	}
+	if _, ok := k.ranges[prefix]; !ok && len(k.ranges) >= maxResolvedKeys {
+		clear(k.ranges)
+	}
+	k.ranges[prefix] = ranges
+	return fmt.Sprintf("%s:%d", prefix, modifyTS)
+}
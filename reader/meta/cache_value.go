@@ -0,0 +1,55 @@
+package metareader
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pingcap/metering_sdk/common"
+)
+
+// encodeCachedMeta encodes metadata as gzip-compressed JSON, the cache value of compressed caches
+func encodeCachedMeta(metaData *common.MetaData) ([]byte, error) {
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gzipWriter).Encode(metaData); err != nil {
+		return nil, fmt.Errorf("failed to encode meta data: %w", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress meta data: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeCachedMeta decodes a cache value into metadata, false if it is not metadata
+func decodeCachedMeta(value interface{}) (*common.MetaData, bool) {
+	var data []byte
+	switch v := value.(type) {
+	case *common.MetaData:
+		return v, true
+	case []byte:
+		data = v
+	case string:
+		// The disk cache round-trips byte slices through JSON as base64
+		decoded, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, false
+		}
+		data = decoded
+	default:
+		return nil, false
+	}
+
+	gzipReader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, false
+	}
+	defer gzipReader.Close()
+	var metaData common.MetaData
+	if err := json.NewDecoder(gzipReader).Decode(&metaData); err != nil {
+		return nil, false
+	}
+	return &metaData, true
+}
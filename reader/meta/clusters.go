@@ -0,0 +1,156 @@
+package metareader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/pingcap/metering_sdk/reader"
+	"github.com/pingcap/metering_sdk/storage"
+	"github.com/pingcap/metering_sdk/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// DefaultReadConcurrency default number of clusters read in parallel by ReadAll
+const DefaultReadConcurrency = 8
+
+// ListClusters lists the IDs of every cluster with metadata of the specified type, sorted. Metadata
+// written with a category is not included.
+func (r *MetaReader) ListClusters(ctx context.Context, metaType common.MetaType) ([]string, error) {
+	ctx, span := tracing.Start(ctx, r.config.TracerProvider, "MetaReader.ListClusters",
+		attribute.String("metering.meta_type", string(metaType)),
+	)
+	clusters, err := r.listClusters(ctx, metaType)
+	tracing.End(span, err)
+	return clusters, err
+}
+
+// listClusters lists the clusters with metadata of the specified type from storage
+func (r *MetaReader) listClusters(ctx context.Context, metaType common.MetaType) ([]string, error) {
+	if err := r.checkOpen(); err != nil {
+		return nil, err
+	}
+	if !common.ValidMetaTypes[metaType] {
+		return nil, fmt.Errorf("invalid metadata type: %s, must be one of: logic, sharedpool", metaType)
+	}
+
+	// A delimiter listing finds the directories without listing the history of every cluster. Categories
+	// are directories at the same level, only those holding metadata files directly are clusters
+	prefix := fmt.Sprintf("metering/meta/%s/", string(metaType))
+	dirs, err := storage.ListCommonPrefixes(ctx, r.provider, prefix, "/")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list clusters: %w", err)
+	}
+
+	var clusters []string
+	for _, dir := range dirs {
+		// Listed prefixes may start with the provider's own prefix
+		clusterID := path.Base(dir)
+		ok, err := r.hasMetaFiles(ctx, prefix+clusterID+"/")
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files of cluster %s: %w", clusterID, err)
+		}
+		if ok {
+			clusters = append(clusters, clusterID)
+		}
+	}
+	sort.Strings(clusters)
+	return clusters, nil
+}
+
+// errFound stops a listing once the object looked for is found
+var errFound = errors.New("found")
+
+// hasMetaFiles reports whether dir holds metadata files directly, as opposed to in category directories.
+// Timestamps sort before most names, so the first page usually decides
+func (r *MetaReader) hasMetaFiles(ctx context.Context, dir string) (bool, error) {
+	err := storage.ListPages(ctx, r.provider, dir, func(page []string) error {
+		for _, file := range page {
+			_, fileName, ok := strings.Cut(file, dir)
+			if !ok || strings.Contains(fileName, "/") {
+				continue
+			}
+			if _, err := r.extractTimestampFromFilename(fileName); err == nil {
+				return errFound
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errFound) {
+		return true, nil
+	}
+	return false, err
+}
+
+// ReadAll reads the latest metadata at or before timestamp of every cluster with metadata of the
+// specified type, by cluster ID, e.g. to build a control-plane inventory. Clusters are read in
+// parallel, up to Config.Concurrency at a time. Clusters deleted at timestamp or without metadata
+// before it are left out; any other failure fails the whole read.
+func (r *MetaReader) ReadAll(ctx context.Context, metaType common.MetaType, timestamp int64) (map[string]*common.MetaData, error) {
+	ctx, span := tracing.Start(ctx, r.config.TracerProvider, "MetaReader.ReadAll",
+		tracing.AttributeTimestamp.Int64(timestamp),
+		attribute.String("metering.meta_type", string(metaType)),
+	)
+	all, err := r.readAll(ctx, metaType, timestamp)
+	tracing.End(span, err)
+	return all, err
+}
+
+// readAll lists the clusters and reads their metadata concurrently
+func (r *MetaReader) readAll(ctx context.Context, metaType common.MetaType, timestamp int64) (map[string]*common.MetaData, error) {
+	clusters, err := r.listClusters(ctx, metaType)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var mu sync.Mutex
+	all := make(map[string]*common.MetaData, len(clusters))
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, r.concurrency)
+	for _, clusterID := range clusters {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			metaData, err := r.ReadByType(ctx, clusterID, metaType, timestamp)
+			if errors.Is(err, reader.ErrFileNotFound) || errors.Is(err, reader.ErrClusterDeleted) {
+				return
+			}
+			if err != nil {
+				cancel(fmt.Errorf("failed to read meta data of cluster %s: %w", clusterID, err))
+				return
+			}
+			mu.Lock()
+			all[clusterID] = metaData
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+	r.logger.Debug("Read meta data of all clusters",
+		zap.String("type", string(metaType)),
+		zap.Int64("timestamp", timestamp),
+		zap.Int("cluster_count", len(clusters)),
+		zap.Int("read_count", len(all)),
+	)
+	return all, nil
+}
@@ -1,33 +1,56 @@
 package metareader
 
 import (
-	"bytes"
-	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pingcap/metering_sdk/common"
 	"github.com/pingcap/metering_sdk/config"
 	"github.com/pingcap/metering_sdk/internal/cache"
+	"github.com/pingcap/metering_sdk/internal/compress"
+	"github.com/pingcap/metering_sdk/logging"
 	"github.com/pingcap/metering_sdk/reader"
 	"github.com/pingcap/metering_sdk/storage"
+	"github.com/pingcap/metering_sdk/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 )
 
+// metaFile metadata file structure, 0 FormatVersion for files predating format_version
+type metaFile struct {
+	FormatVersion int `json:"format_version"`
+	common.MetaData
+}
+
 // MetaReader metadata reader
 type MetaReader struct {
-	provider storage.ObjectStorageProvider
-	config   *config.Config
-	logger   *zap.Logger
-	cache    cache.Cache  // Add cache
-	mu       sync.RWMutex // Protect concurrent reads
+	provider      storage.ObjectStorageProvider
+	stater        storage.ObjectStater // nil if the provider can't stat objects
+	config        *config.Config
+	logger        *zap.Logger
+	cache         cache.Cache    // Add cache
+	cacheKeys     *cacheKeys     // builds cache keys, nil without cache
+	compressCache bool           // cache metadata as compressed JSON
+	skewTolerance time.Duration  // accepted ModifyTS skew past the requested timestamp
+	concurrency   int            // clusters read in parallel by ReadAll
+	negative      *negativeCache // clusters recently found without metadata, nil if disabled
+	mu            sync.RWMutex   // Protect concurrent reads
+
+	filesMu sync.Mutex
+	files   map[string]*validatedFile // parsed meta files by path, revalidated with their ETag
+
+	calls  *storage.CallCounter // storage requests made by this reader
+	closed atomic.Bool          // reads fail with reader.ErrReaderClosed once set by Close
 }
 
 // CacheType represents the cache type
@@ -50,6 +73,13 @@ type CacheConfig struct {
 	DiskPath string `json:"disk_path,omitempty"`
 	// EvictionTime access-time-based eviction time (items not accessed for longer than this time will be evicted first)
 	EvictionTime time.Duration `json:"eviction_time,omitempty"`
+	// KeyMode timestamp metadata is cached under, default CacheKeyTimestamp
+	KeyMode CacheKeyMode `json:"key_mode,omitempty"`
+	// BucketSize size of the time buckets in CacheKeyBucket mode, at least one second
+	BucketSize time.Duration `json:"bucket_size,omitempty"`
+	// Compressed caches metadata as gzip-compressed JSON decoded on every hit, trading CPU for a
+	// smaller memory footprint whose size is accounted exactly
+	Compressed bool `json:"compressed,omitempty"`
 }
 
 // toInternalConfig converts CacheConfig to internal cache.Config
@@ -69,6 +99,15 @@ func (c *CacheConfig) toInternalConfig() *cache.Config {
 type Config struct {
 	// Cache cache configuration (optional)
 	Cache *CacheConfig `json:"cache,omitempty"`
+	// ClockSkewTolerance also accepts metadata whose ModifyTS is up to this much after the requested
+	// timestamp, so callers with a slightly late clock still see the metadata just written
+	ClockSkewTolerance time.Duration `json:"clock_skew_tolerance,omitempty"`
+	// Concurrency number of clusters ReadAll reads in parallel, default DefaultReadConcurrency
+	Concurrency int `json:"concurrency,omitempty"`
+	// NegativeCacheTTL remembers for this long that a cluster has no metadata at or before a timestamp,
+	// so repeated reads of nonexistent clusters don't list storage every time. Metadata written in the
+	// meantime is only seen once the entry expires. Zero disables negative caching
+	NegativeCacheTTL time.Duration `json:"negative_cache_ttl,omitempty"`
 }
 
 // NewMetaReader creates a new metadata reader
@@ -77,28 +116,60 @@ func NewMetaReader(provider storage.ObjectStorageProvider, cfg *config.Config, r
 		cfg = config.DefaultConfig()
 	}
 
+	// The wrappers always implement ObjectStater, only use it if provider does natively
+	var stater storage.ObjectStater
+	calls := &storage.CallCounter{}
+	wrapped := cfg.WrapProvider(provider, calls)
+	if _, ok := provider.(storage.ObjectStater); ok {
+		stater, _ = wrapped.(storage.ObjectStater)
+	}
 	reader := &MetaReader{
-		provider: provider,
-		config:   cfg,
-		logger:   cfg.GetLogger(),
+		provider:    wrapped,
+		stater:      stater,
+		config:      cfg,
+		logger:      cfg.ProviderLogger(provider),
+		files:       make(map[string]*validatedFile),
+		concurrency: DefaultReadConcurrency,
+		calls:       calls,
+	}
+
+	if readerCfg != nil {
+		reader.skewTolerance = max(readerCfg.ClockSkewTolerance, 0)
+		if readerCfg.Concurrency > 0 {
+			reader.concurrency = readerCfg.Concurrency
+		}
+		reader.negative = newNegativeCache(readerCfg.NegativeCacheTTL)
 	}
 
 	// Initialize cache
 	if readerCfg != nil && readerCfg.Cache != nil {
+		keys, err := newCacheKeys(readerCfg.Cache)
+		if err != nil {
+			return nil, err
+		}
 		c, err := cache.NewCache(readerCfg.Cache.toInternalConfig())
 		if err != nil {
 			return nil, fmt.Errorf("failed to create cache: %w", err)
 		}
 		reader.cache = c
+		reader.cacheKeys = keys
+		reader.compressCache = readerCfg.Cache.Compressed
 		reader.logger.Info("Meta reader cache initialized",
 			zap.String("type", string(readerCfg.Cache.Type)),
 			zap.Int64("max_size", readerCfg.Cache.MaxSize),
+			zap.String("key_mode", string(keys.mode)),
+			zap.Bool("compressed", readerCfg.Cache.Compressed),
 		)
 	}
 
 	return reader, nil
 }
 
+// CallStats returns the storage requests this reader made, cache hits make none
+func (r *MetaReader) CallStats() storage.CallStats {
+	return r.calls.Stats()
+}
+
 // Read reads the latest metadata for the specified cluster at or before the specified timestamp
 func (r *MetaReader) Read(ctx context.Context, clusterID string, timestamp int64) (*common.MetaData, error) {
 	return r.ReadWithCategory(ctx, clusterID, "", timestamp)
@@ -106,6 +177,21 @@ func (r *MetaReader) Read(ctx context.Context, clusterID string, timestamp int64
 
 // ReadWithCategory reads the latest metadata for the specified cluster and category at or before the specified timestamp
 func (r *MetaReader) ReadWithCategory(ctx context.Context, clusterID string, category string, timestamp int64) (*common.MetaData, error) {
+	ctx, span := tracing.Start(ctx, r.config.TracerProvider, "MetaReader.ReadWithCategory",
+		tracing.AttributeClusterID.String(clusterID),
+		tracing.AttributeCategory.String(category),
+		tracing.AttributeTimestamp.Int64(timestamp),
+	)
+	metaData, err := r.readWithCategory(ctx, clusterID, category, timestamp)
+	tracing.End(span, err)
+	return metaData, err
+}
+
+// readWithCategory serves ReadWithCategory from the cache or storage
+func (r *MetaReader) readWithCategory(ctx context.Context, clusterID string, category string, timestamp int64) (*common.MetaData, error) {
+	if err := r.checkOpen(); err != nil {
+		return nil, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -115,37 +201,46 @@ func (r *MetaReader) ReadWithCategory(ctx context.Context, clusterID string, cat
 		zap.Int64("timestamp", timestamp),
 	)
 
-	// Use the incoming timestamp as cache key for exact lookup
-	var cacheKey string
+	// Cache keys end with the timestamp given by the cache key mode
+	var keyPrefix string
 	if category != "" {
-		cacheKey = fmt.Sprintf("meta:%s:%s:%d", category, clusterID, timestamp)
+		keyPrefix = fmt.Sprintf("meta:%s:%s", category, clusterID)
 	} else {
-		cacheKey = fmt.Sprintf("meta:%s:%d", clusterID, timestamp)
+		keyPrefix = fmt.Sprintf("meta:%s", clusterID)
 	}
+	timestamp = r.cacheKeys.timestamp(timestamp)
 
 	if r.cache != nil {
-		if cached, found := r.cache.Get(cacheKey); found {
-			if metaData, ok := cached.(*common.MetaData); ok {
-				r.logger.Debug("Meta data cache hit",
-					zap.String("cluster_id", clusterID),
-					zap.String("category", category),
-					zap.Int64("timestamp", timestamp),
-					zap.Int64("actual_timestamp", metaData.ModifyTS),
-				)
-				return metaData, nil
-			}
+		if metaData, found := r.cachedMeta(keyPrefix, timestamp); found {
+			r.logger.Debug("Meta data cache hit",
+				zap.String("cluster_id", clusterID),
+				zap.String("category", category),
+				zap.Int64("timestamp", timestamp),
+				zap.Int64("actual_timestamp", metaData.ModifyTS),
+			)
+			return metaData, nil
 		}
 	}
 
+	negativeKey := fmt.Sprintf("meta:%s:%s", category, clusterID)
+	if r.negative.missing(negativeKey, timestamp) {
+		r.config.Metrics.ObserveCache("meta_negative", true)
+		return nil, fmt.Errorf("%w: no meta files found for cluster %s before timestamp %d (cached)",
+			reader.ErrFileNotFound, clusterID, timestamp)
+	}
+
 	// Cache miss, get from storage
 	metaData, err := r.readLatestFromStorageWithCategory(ctx, clusterID, category, timestamp)
+	if errors.Is(err, reader.ErrFileNotFound) {
+		r.negative.add(negativeKey, timestamp, err)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	// Store in cache (using incoming timestamp as key)
+	// Store in cache
 	if r.cache != nil && metaData != nil {
-		if err := r.cache.Set(cacheKey, metaData); err != nil {
+		if err := r.setCachedMeta(r.cacheKeys.store(keyPrefix, timestamp, metaData.ModifyTS), metaData); err != nil {
 			r.logger.Warn("Failed to cache meta data",
 				zap.String("cluster_id", clusterID),
 				zap.String("category", category),
@@ -166,6 +261,32 @@ func (r *MetaReader) ReadWithCategory(ctx context.Context, clusterID string, cat
 	return metaData, nil
 }
 
+// cachedMeta looks up the metadata read at timestamp in the cache
+func (r *MetaReader) cachedMeta(keyPrefix string, timestamp int64) (*common.MetaData, bool) {
+	var metaData *common.MetaData
+	cacheKey, found := r.cacheKeys.lookup(keyPrefix, timestamp)
+	if found {
+		var cached interface{}
+		if cached, found = r.cache.Get(cacheKey); found {
+			metaData, found = decodeCachedMeta(cached)
+		}
+	}
+	r.config.Metrics.ObserveCache("meta", found)
+	return metaData, found
+}
+
+// setCachedMeta stores metadata in the cache, compressed if configured
+func (r *MetaReader) setCachedMeta(cacheKey string, metaData *common.MetaData) error {
+	if !r.compressCache {
+		return r.cache.Set(cacheKey, metaData)
+	}
+	data, err := encodeCachedMeta(metaData)
+	if err != nil {
+		return err
+	}
+	return r.cache.Set(cacheKey, data)
+}
+
 // ReadByType reads the latest metadata for the specified cluster and type at or before the specified timestamp
 func (r *MetaReader) ReadByType(ctx context.Context, clusterID string, metaType common.MetaType, timestamp int64) (*common.MetaData, error) {
 	return r.ReadByTypeWithCategory(ctx, clusterID, metaType, "", timestamp)
@@ -173,6 +294,22 @@ func (r *MetaReader) ReadByType(ctx context.Context, clusterID string, metaType
 
 // ReadByTypeWithCategory reads the latest metadata for the specified cluster, type, and category at or before the specified timestamp
 func (r *MetaReader) ReadByTypeWithCategory(ctx context.Context, clusterID string, metaType common.MetaType, category string, timestamp int64) (*common.MetaData, error) {
+	ctx, span := tracing.Start(ctx, r.config.TracerProvider, "MetaReader.ReadByTypeWithCategory",
+		tracing.AttributeClusterID.String(clusterID),
+		tracing.AttributeCategory.String(category),
+		tracing.AttributeTimestamp.Int64(timestamp),
+		attribute.String("metering.meta_type", string(metaType)),
+	)
+	metaData, err := r.readByTypeWithCategory(ctx, clusterID, metaType, category, timestamp)
+	tracing.End(span, err)
+	return metaData, err
+}
+
+// readByTypeWithCategory serves ReadByTypeWithCategory from the cache or storage
+func (r *MetaReader) readByTypeWithCategory(ctx context.Context, clusterID string, metaType common.MetaType, category string, timestamp int64) (*common.MetaData, error) {
+	if err := r.checkOpen(); err != nil {
+		return nil, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -189,37 +326,46 @@ func (r *MetaReader) ReadByTypeWithCategory(ctx context.Context, clusterID strin
 	)
 
 	// Use type-specific cache key
-	var cacheKey string
+	var keyPrefix string
 	if category != "" {
-		cacheKey = fmt.Sprintf("meta:%s:%s:%s:%d", string(metaType), category, clusterID, timestamp)
+		keyPrefix = fmt.Sprintf("meta:%s:%s:%s", string(metaType), category, clusterID)
 	} else {
-		cacheKey = fmt.Sprintf("meta:%s:%s:%d", string(metaType), clusterID, timestamp)
+		keyPrefix = fmt.Sprintf("meta:%s:%s", string(metaType), clusterID)
 	}
+	timestamp = r.cacheKeys.timestamp(timestamp)
 
 	if r.cache != nil {
-		if cached, found := r.cache.Get(cacheKey); found {
-			if metaData, ok := cached.(*common.MetaData); ok {
-				r.logger.Debug("Meta data cache hit",
-					zap.String("cluster_id", clusterID),
-					zap.String("type", string(metaType)),
-					zap.String("category", category),
-					zap.Int64("timestamp", timestamp),
-					zap.Int64("actual_timestamp", metaData.ModifyTS),
-				)
-				return metaData, nil
-			}
+		if metaData, found := r.cachedMeta(keyPrefix, timestamp); found {
+			r.logger.Debug("Meta data cache hit",
+				zap.String("cluster_id", clusterID),
+				zap.String("type", string(metaType)),
+				zap.String("category", category),
+				zap.Int64("timestamp", timestamp),
+				zap.Int64("actual_timestamp", metaData.ModifyTS),
+			)
+			return metaData, nil
 		}
 	}
 
+	negativeKey := fmt.Sprintf("meta:%s:%s:%s", string(metaType), category, clusterID)
+	if r.negative.missing(negativeKey, timestamp) {
+		r.config.Metrics.ObserveCache("meta_negative", true)
+		return nil, fmt.Errorf("%w: no meta files found for cluster %s with type %s before timestamp %d (cached)",
+			reader.ErrFileNotFound, clusterID, metaType, timestamp)
+	}
+
 	// Cache miss, get from storage
 	metaData, err := r.readLatestFromStorageByTypeWithCategory(ctx, clusterID, metaType, category, timestamp)
+	if errors.Is(err, reader.ErrFileNotFound) {
+		r.negative.add(negativeKey, timestamp, err)
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	// Store in cache
 	if r.cache != nil && metaData != nil {
-		if err := r.cache.Set(cacheKey, metaData); err != nil {
+		if err := r.setCachedMeta(r.cacheKeys.store(keyPrefix, timestamp, metaData.ModifyTS), metaData); err != nil {
 			r.logger.Warn("Failed to cache meta data",
 				zap.String("cluster_id", clusterID),
 				zap.String("type", string(metaType)),
@@ -244,20 +390,54 @@ func (r *MetaReader) ReadByTypeWithCategory(ctx context.Context, clusterID strin
 
 // ReadFile reads metadata file at the specified path (original functionality preserved)
 func (r *MetaReader) ReadFile(ctx context.Context, path string) (interface{}, error) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, r.config.TracerProvider, "MetaReader.ReadFile", tracing.AttributePath.String(path))
+	data, err := r.readFile(ctx, path)
+	tracing.End(span, err)
+	r.config.Metrics.ObserveRead("meta", start, err)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// DownloadRaw opens the metadata file at path as stored, without decompressing or decoding it.
+// Use reader.Decompress to read the JSON.
+func (r *MetaReader) DownloadRaw(ctx context.Context, path string) (io.ReadCloser, reader.ObjectInfo, error) {
+	if err := r.checkOpen(); err != nil {
+		return nil, reader.ObjectInfo{}, err
+	}
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, r.config.TracerProvider, "MetaReader.DownloadRaw", tracing.AttributePath.String(path))
+	body, info, err := reader.DownloadRaw(ctx, r.provider, path)
+	tracing.End(span, err)
+	r.config.Metrics.ObserveRead("meta", start, err)
+	return body, info, err
+}
+
+// readFile downloads, decompresses and parses the metadata file at the specified path
+func (r *MetaReader) readFile(ctx context.Context, path string) (*common.MetaData, error) {
+	if err := r.checkOpen(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	r.logger.Debug("Reading meta data file",
-		zap.String("path", path),
+		logging.Path(path),
 	)
 
-	// Check if file exists
-	exists, err := r.provider.Exists(ctx, path)
+	// Check if file exists, and if it is unchanged since it was last read
+	etag, err := r.checkFile(ctx, path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check if file exists: %w", err)
+		return nil, err
 	}
-	if !exists {
-		return nil, fmt.Errorf("%w: %s", reader.ErrFileNotFound, path)
+	if metaData := r.validatedFile(path, etag); metaData != nil {
+		r.logger.Debug("Meta data file unchanged, skipping download",
+			logging.Path(path),
+		)
+		return metaData, nil
 	}
 
 	// Download file
@@ -274,20 +454,61 @@ func (r *MetaReader) ReadFile(ctx context.Context, path string) (interface{}, er
 	}
 
 	// Parse JSON
-	var metaData common.MetaData
-	if err := json.Unmarshal(data, &metaData); err != nil {
+	var file metaFile
+	if err := json.Unmarshal(data, &file); err != nil {
 		return nil, fmt.Errorf("%w: failed to unmarshal meta data: %v", reader.ErrInvalidFormat, err)
 	}
+	if err := reader.CheckFormatVersion(r.logger, path, file.FormatVersion, r.config.StrictFormatVersion); err != nil {
+		return nil, err
+	}
+	metaData := file.MetaData
 
 	r.logger.Debug("Successfully read meta data file",
-		zap.String("path", path),
+		logging.Path(path),
 		zap.String("cluster_id", metaData.ClusterID),
 		zap.Int64("modify_ts", metaData.ModifyTS),
+		logging.Since(start),
 	)
 
+	r.storeValidatedFile(path, etag, &metaData)
 	return &metaData, nil
 }
 
+// ReadLatest reads the most recent metadata of the specified cluster and type regardless of its
+// timestamp, for callers that want the current metadata. Results are never cached.
+func (r *MetaReader) ReadLatest(ctx context.Context, clusterID string, metaType common.MetaType) (*common.MetaData, error) {
+	ctx, span := tracing.Start(ctx, r.config.TracerProvider, "MetaReader.ReadLatest",
+		tracing.AttributeClusterID.String(clusterID),
+		attribute.String("metering.meta_type", string(metaType)),
+	)
+	metaData, err := r.readLatest(ctx, clusterID, metaType)
+	tracing.End(span, err)
+	return metaData, err
+}
+
+// readLatest reads the most recent metadata of the specified cluster and type from storage
+func (r *MetaReader) readLatest(ctx context.Context, clusterID string, metaType common.MetaType) (*common.MetaData, error) {
+	if err := r.checkOpen(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if !common.ValidMetaTypes[metaType] {
+		return nil, fmt.Errorf("invalid metadata type: %s, must be one of: logic, sharedpool", metaType)
+	}
+	return r.readLatestFromStorageByTypeWithCategory(ctx, clusterID, metaType, "", math.MaxInt64)
+}
+
+// timestampLimit returns the latest ModifyTS accepted for a requested timestamp
+func (r *MetaReader) timestampLimit(timestamp int64) int64 {
+	tolerance := int64(r.skewTolerance / time.Second)
+	if timestamp > math.MaxInt64-tolerance {
+		return math.MaxInt64
+	}
+	return timestamp + tolerance
+}
+
 // readLatestFromStorageWithCategory reads the latest metadata from storage with optional category
 func (r *MetaReader) readLatestFromStorageWithCategory(ctx context.Context, clusterID string, category string, timestamp int64) (*common.MetaData, error) {
 	// Build prefix path based on whether category is set
@@ -307,12 +528,13 @@ func (r *MetaReader) readLatestFromStorageWithCategory(ctx context.Context, clus
 
 	if len(files) == 0 {
 		if category != "" {
-			return nil, fmt.Errorf("%w: no meta files found for cluster %s with category %s", reader.ErrFileNotFound, clusterID, category)
+			return nil, fmt.Errorf("%w: no meta files found for cluster %s with category %s", errNoMetaFiles, clusterID, category)
 		}
-		return nil, fmt.Errorf("%w: no meta files found for cluster %s", reader.ErrFileNotFound, clusterID)
+		return nil, fmt.Errorf("%w: no meta files found for cluster %s", errNoMetaFiles, clusterID)
 	}
 
-	// Find the latest file with timestamp not greater than the specified time
+	// Find the latest file with timestamp not greater than the specified time, allowing for clock skew
+	limit := r.timestampLimit(timestamp)
 	var latestFile string
 	var latestTimestamp int64 = -1
 
@@ -328,7 +550,7 @@ func (r *MetaReader) readLatestFromStorageWithCategory(ctx context.Context, clus
 		}
 
 		// Check if timestamp condition is met and update
-		if fileTimestamp <= timestamp && fileTimestamp > latestTimestamp {
+		if fileTimestamp <= limit && fileTimestamp > latestTimestamp {
 			latestTimestamp = fileTimestamp
 			latestFile = file
 		}
@@ -354,6 +576,9 @@ func (r *MetaReader) readLatestFromStorageWithCategory(ctx context.Context, clus
 	if !ok {
 		return nil, fmt.Errorf("invalid data type from file %s", latestFile)
 	}
+	if metaData.Deleted {
+		return nil, fmt.Errorf("%w: cluster %s at %d", reader.ErrClusterDeleted, clusterID, latestTimestamp)
+	}
 	// Add more information
 	metaData.ClusterID = clusterID
 	metaData.Category = category
@@ -380,13 +605,14 @@ func (r *MetaReader) readLatestFromStorageByTypeWithCategory(ctx context.Context
 	if len(files) == 0 {
 		if category != "" {
 			return nil, fmt.Errorf("%w: no meta files found for cluster %s with type %s and category %s",
-				reader.ErrFileNotFound, clusterID, metaType, category)
+				errNoMetaFiles, clusterID, metaType, category)
 		}
 		return nil, fmt.Errorf("%w: no meta files found for cluster %s with type %s",
-			reader.ErrFileNotFound, clusterID, metaType)
+			errNoMetaFiles, clusterID, metaType)
 	}
 
-	// Find the latest file with timestamp not greater than the specified time
+	// Find the latest file with timestamp not greater than the specified time, allowing for clock skew
+	limit := r.timestampLimit(timestamp)
 	var latestFile string
 	var latestTimestamp int64 = -1
 
@@ -402,7 +628,7 @@ func (r *MetaReader) readLatestFromStorageByTypeWithCategory(ctx context.Context
 		}
 
 		// Check if timestamp condition is met and update
-		if fileTimestamp <= timestamp && fileTimestamp > latestTimestamp {
+		if fileTimestamp <= limit && fileTimestamp > latestTimestamp {
 			latestTimestamp = fileTimestamp
 			latestFile = file
 		}
@@ -429,6 +655,10 @@ func (r *MetaReader) readLatestFromStorageByTypeWithCategory(ctx context.Context
 		return nil, fmt.Errorf("invalid data type from file %s", latestFile)
 	}
 
+	if metaData.Deleted {
+		return nil, fmt.Errorf("%w: cluster %s with type %s at %d", reader.ErrClusterDeleted, clusterID, metaType, latestTimestamp)
+	}
+
 	// Ensure the metadata has the correct information
 	metaData.ClusterID = clusterID
 	metaData.Type = metaType
@@ -458,6 +688,9 @@ func (r *MetaReader) extractTimestampFromFilename(filename string) (int64, error
 
 // List implements MetaReader interface, lists all data paths under the specified prefix
 func (r *MetaReader) List(ctx context.Context, prefix string) ([]string, error) {
+	if err := r.checkOpen(); err != nil {
+		return nil, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -478,8 +711,12 @@ func (r *MetaReader) List(ctx context.Context, prefix string) ([]string, error)
 	return files, nil
 }
 
-// Close implements MetaReader interface, closes the reader
+// Close implements MetaReader interface, closes the reader and its cache. Later reads fail with
+// reader.ErrReaderClosed, in-flight reads are not waited for. Closing twice is a no-op.
 func (r *MetaReader) Close() error {
+	if !r.closed.CompareAndSwap(false, true) {
+		return nil
+	}
 	r.logger.Debug("Closing meta reader")
 	// Close cache
 	if r.cache != nil {
@@ -490,19 +727,16 @@ func (r *MetaReader) Close() error {
 	return nil
 }
 
-// decompressData decompresses gzip data
-func (r *MetaReader) decompressData(reader io.Reader) ([]byte, error) {
-	gzipReader, err := gzip.NewReader(reader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
-	}
-	defer gzipReader.Close()
-
-	var buffer bytes.Buffer
-	// #nosec G110 - reader must deal all file,the file input is safe
-	if _, err := io.Copy(&buffer, gzipReader); err != nil {
-		return nil, fmt.Errorf("failed to decompress data: %w", err)
+// checkOpen fails with reader.ErrReaderClosed once Close has been called
+func (r *MetaReader) checkOpen() error {
+	if r.closed.Load() {
+		return reader.ErrReaderClosed
 	}
+	return nil
+}
 
-	return buffer.Bytes(), nil
+// decompressData decompresses gzip data
+func (r *MetaReader) decompressData(reader io.Reader) ([]byte, error) {
+	// reader must deal all file, the file input is safe
+	return compress.Gunzip(reader, 0)
 }
@@ -7,12 +7,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/pingcap/metering_sdk/common"
 	"github.com/pingcap/metering_sdk/config"
+	"github.com/pingcap/metering_sdk/reader"
+	"github.com/pingcap/metering_sdk/storage"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
 )
@@ -166,11 +169,15 @@ func TestMetaReader_Close(t *testing.T) {
 	cfg := &config.Config{
 		Logger: zap.NewNop(),
 	}
-	metaReader, err := NewMetaReader(provider, cfg, nil)
+	metaReader, err := NewMetaReader(provider, cfg, &Config{Cache: &CacheConfig{Type: CacheTypeMemory, MaxSize: 1024}})
 	assert.NoError(t, err, "Failed to create meta reader")
 
 	err = metaReader.Close()
 	assert.NoError(t, err, "Unexpected error")
+	assert.NoError(t, metaReader.Close(), "Closing twice should be a no-op")
+
+	_, err = metaReader.Read(context.Background(), "cluster-123", 1755687660)
+	assert.ErrorIs(t, err, reader.ErrReaderClosed)
 }
 
 // TestMetaReader_Read_WithTimestamp tests the new Read method (based on cluster ID and timestamp)
@@ -367,6 +374,40 @@ func TestMetaReader_DiskCache(t *testing.T) {
 	assert.NoError(t, err, "Failed to close MetaReader")
 }
 
+// TestMetaReader_CompressedCache tests caching metadata as compressed JSON in memory and on disk
+func TestMetaReader_CompressedCache(t *testing.T) {
+	ctx := context.Background()
+	provider := &listCountingProvider{MemoryProvider: storage.NewMemoryProvider()}
+	compressedData, err := createCompressedTestData(&common.MetaData{
+		ClusterID: "compressed-cluster",
+		Type:      common.MetaTypeLogic,
+		ModifyTS:  1000,
+		Metadata:  map[string]interface{}{"name": strings.Repeat("pool", 1000)},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, provider.Upload(ctx, "metering/meta/logic/compressed-cluster/1000.json.gz", bytes.NewReader(compressedData)))
+
+	for _, cacheType := range []CacheType{CacheTypeMemory, CacheTypeDisk} {
+		t.Run(string(cacheType), func(t *testing.T) {
+			metaReader, err := NewMetaReader(provider, &config.Config{Logger: zap.NewNop()}, &Config{
+				Cache: &CacheConfig{Type: cacheType, MaxSize: 1024 * 1024, DiskPath: t.TempDir(), Compressed: true},
+			})
+			assert.NoError(t, err)
+			defer metaReader.Close()
+
+			first, err := metaReader.ReadByType(ctx, "compressed-cluster", common.MetaTypeLogic, 1500)
+			assert.NoError(t, err)
+			lists := provider.lists
+			second, err := metaReader.ReadByType(ctx, "compressed-cluster", common.MetaTypeLogic, 1500)
+			assert.NoError(t, err)
+			assert.Equal(t, lists, provider.lists, "second read should hit the cache")
+			assert.Equal(t, first, second)
+			assert.NotSame(t, first, second, "hits decode a fresh copy")
+			assert.Less(t, metaReader.cache.Size(), int64(1024), "cached metadata should be compressed")
+		})
+	}
+}
+
 // TestMetaReader_NoCacheConfig tests behavior when no cache configuration is provided
 func TestMetaReader_NoCacheConfig(t *testing.T) {
 	provider := newMockObjectStorageProvider()
@@ -517,6 +558,53 @@ func TestMetaReader_CacheKeyLogic(t *testing.T) {
 	}
 }
 
+// TestMetaReader_CacheKeyModes tests caching by resolved ModifyTS and by time bucket
+func TestMetaReader_CacheKeyModes(t *testing.T) {
+	ctx := context.Background()
+	provider := &listCountingProvider{MemoryProvider: storage.NewMemoryProvider()}
+	for _, ts := range []int64{1000, 2000} {
+		compressedData, err := createCompressedTestData(&common.MetaData{ClusterID: "key-cluster", Type: common.MetaTypeLogic, ModifyTS: ts})
+		assert.NoError(t, err)
+		assert.NoError(t, provider.Upload(ctx, fmt.Sprintf("metering/meta/logic/key-cluster/%d.json.gz", ts), bytes.NewReader(compressedData)))
+	}
+	newReader := func(cacheConfig *CacheConfig) *MetaReader {
+		cacheConfig.Type = CacheTypeMemory
+		cacheConfig.MaxSize = 1024 * 1024
+		metaReader, err := NewMetaReader(provider, &config.Config{Logger: zap.NewNop()}, &Config{Cache: cacheConfig})
+		assert.NoError(t, err)
+		return metaReader
+	}
+	read := func(metaReader *MetaReader, ts int64) int64 {
+		metaData, err := metaReader.ReadByType(ctx, "key-cluster", common.MetaTypeLogic, ts)
+		assert.NoError(t, err)
+		return metaData.ModifyTS
+	}
+
+	// Timestamps between two read ones resolve to the same file and hit the cache
+	metaReader := newReader(&CacheConfig{KeyMode: CacheKeyModifyTS})
+	assert.Equal(t, int64(1000), read(metaReader, 1200))
+	assert.Equal(t, int64(1000), read(metaReader, 1800))
+	lists := provider.lists
+	assert.Equal(t, int64(1000), read(metaReader, 1500))
+	assert.Equal(t, int64(2000), read(metaReader, 2500))
+	assert.Equal(t, lists+1, provider.lists)
+	assert.ElementsMatch(t, []string{"meta:logic:key-cluster:1000", "meta:logic:key-cluster:2000"}, metaReader.cache.KeysWithPrefix("meta:logic:key-cluster:"))
+
+	// Reads resolve as of the start of their bucket
+	metaReader = newReader(&CacheConfig{KeyMode: CacheKeyBucket, BucketSize: time.Hour})
+	assert.Equal(t, int64(0), metaReader.cacheKeys.timestamp(3599))
+	assert.Equal(t, int64(2000), read(metaReader, 3700))
+	lists = provider.lists
+	assert.Equal(t, int64(2000), read(metaReader, 7199))
+	assert.Equal(t, lists, provider.lists)
+	assert.Equal(t, []string{"meta:logic:key-cluster:3600"}, metaReader.cache.KeysWithPrefix("meta:logic:key-cluster:"))
+
+	for _, cacheConfig := range []*CacheConfig{{KeyMode: CacheKeyBucket}, {KeyMode: "unknown"}} {
+		_, err := NewMetaReader(provider, &config.Config{Logger: zap.NewNop()}, &Config{Cache: cacheConfig})
+		assert.Error(t, err)
+	}
+}
+
 // TestMetaReader_ReadByType tests the new ReadByType functionality
 func TestMetaReader_ReadByType(t *testing.T) {
 	provider := newMockObjectStorageProvider()
@@ -594,6 +682,44 @@ func TestMetaReader_ReadByType(t *testing.T) {
 }
 
 // TestMetaReader_ReadByTypeWithCache tests ReadByType with caching
+func TestMetaReader_ClockSkew(t *testing.T) {
+	provider := newMockObjectStorageProvider()
+	for _, ts := range []int64{1000, 2000} {
+		compressed, err := createCompressedTestData(&common.MetaData{
+			ClusterID: "test-cluster",
+			Type:      common.MetaTypeLogic,
+			ModifyTS:  ts,
+			Metadata:  map[string]interface{}{"modify_ts": ts},
+		})
+		assert.NoError(t, err)
+		provider.files[fmt.Sprintf("metering/meta/logic/test-cluster/%d.json.gz", ts)] = compressed
+	}
+	cfg := &config.Config{Logger: zap.NewNop()}
+	ctx := context.Background()
+
+	strict, err := NewMetaReader(provider, cfg, nil)
+	assert.NoError(t, err)
+	result, err := strict.ReadByType(ctx, "test-cluster", common.MetaTypeLogic, 1995)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1000), result.ModifyTS)
+
+	tolerant, err := NewMetaReader(provider, cfg, &Config{ClockSkewTolerance: 10 * time.Second})
+	assert.NoError(t, err)
+	result, err = tolerant.ReadByType(ctx, "test-cluster", common.MetaTypeLogic, 1995)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2000), result.ModifyTS)
+
+	result, err = strict.ReadLatest(ctx, "test-cluster", common.MetaTypeLogic)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2000), result.ModifyTS)
+	assert.Equal(t, common.MetaTypeLogic, result.Type)
+
+	_, err = strict.ReadLatest(ctx, "missing-cluster", common.MetaTypeLogic)
+	assert.ErrorIs(t, err, reader.ErrFileNotFound)
+	_, err = strict.ReadLatest(ctx, "test-cluster", "invalid")
+	assert.Error(t, err)
+}
+
 func TestMetaReader_ReadByTypeWithCache(t *testing.T) {
 	provider := newMockObjectStorageProvider()
 
@@ -896,3 +1022,393 @@ func TestMetaReader_MixedCategoryAndNonCategory(t *testing.T) {
 		assert.Equal(t, "test-cluster-with-category", result.Metadata["name"].(string))
 	})
 }
+
+// TestMetaReader_ListVersions tests listing and reading the history of a cluster's metadata
+func TestMetaReader_ListVersions(t *testing.T) {
+	ctx := context.Background()
+	memory := storage.NewMemoryProvider()
+	mock := newMockObjectStorageProvider()
+
+	sizes := make(map[int64]int64)
+	for _, modifyTS := range []int64{3000, 1000, 2000} {
+		compressedData, err := createCompressedTestData(&common.MetaData{
+			ClusterID: "history-cluster",
+			Type:      common.MetaTypeLogic,
+			ModifyTS:  modifyTS,
+			Metadata:  map[string]interface{}{"revision": fmt.Sprintf("r%d", modifyTS)},
+		})
+		assert.NoError(t, err)
+		path := fmt.Sprintf("metering/meta/logic/history-cluster/%d.json.gz", modifyTS)
+		assert.NoError(t, memory.Upload(ctx, path, bytes.NewReader(compressedData)))
+		mock.files[path] = compressedData
+		sizes[modifyTS] = int64(len(compressedData))
+	}
+	// A category named like the cluster is not part of its history
+	mock.files["metering/meta/logic/history-cluster/other-cluster/1500.json.gz"] = []byte("x")
+
+	// The memory provider lists sizes, the mock provider is downloaded
+	for _, provider := range []storage.ObjectStorageProvider{memory, mock} {
+		metaReader, err := NewMetaReader(provider, &config.Config{Logger: zap.NewNop()}, nil)
+		assert.NoError(t, err)
+
+		versions, err := metaReader.ListVersions(ctx, "history-cluster", common.MetaTypeLogic, 0, math.MaxInt64)
+		assert.NoError(t, err)
+		assert.Len(t, versions, 3)
+		for i, modifyTS := range []int64{1000, 2000, 3000} {
+			assert.Equal(t, modifyTS, versions[i].ModifyTS)
+			assert.Equal(t, sizes[modifyTS], versions[i].Size)
+		}
+		if provider == memory {
+			stats := metaReader.CallStats()
+			assert.Equal(t, storage.CallStats{Lists: 1}, stats, "sizes are taken from the listing")
+		}
+
+		versions, err = metaReader.ListVersions(ctx, "history-cluster", common.MetaTypeLogic, 1500, 2000)
+		assert.NoError(t, err)
+		assert.Len(t, versions, 1)
+		assert.Equal(t, int64(2000), versions[0].ModifyTS)
+
+		metaData, err := metaReader.ReadVersion(ctx, "history-cluster", common.MetaTypeLogic, 2000)
+		assert.NoError(t, err)
+		assert.Equal(t, "r2000", metaData.Metadata["revision"])
+		assert.Equal(t, int64(2000), metaData.ModifyTS)
+
+		_, err = metaReader.ReadVersion(ctx, "history-cluster", common.MetaTypeLogic, 2500)
+		assert.ErrorIs(t, err, reader.ErrFileNotFound)
+
+		_, err = metaReader.ListVersions(ctx, "history-cluster", common.MetaType("invalid"), 0, math.MaxInt64)
+		assert.Error(t, err)
+	}
+}
+
+// TestMetaReader_Diff tests comparing two versions of a cluster's metadata
+func TestMetaReader_Diff(t *testing.T) {
+	ctx := context.Background()
+	provider := storage.NewMemoryProvider()
+	for modifyTS, metadata := range map[int64]map[string]interface{}{
+		1000: {"region": "us-west-2", "replicas": 3, "tags": map[string]interface{}{"env": "prod"}, "tier": "basic"},
+		2000: {"region": "us-west-2", "replicas": 5, "tags": map[string]interface{}{"env": "prod"}, "owner": "team-a"},
+	} {
+		compressedData, err := createCompressedTestData(&common.MetaData{
+			ClusterID: "diff-cluster",
+			Type:      common.MetaTypeLogic,
+			ModifyTS:  modifyTS,
+			Metadata:  metadata,
+		})
+		assert.NoError(t, err)
+		path := fmt.Sprintf("metering/meta/logic/diff-cluster/%d.json.gz", modifyTS)
+		assert.NoError(t, provider.Upload(ctx, path, bytes.NewReader(compressedData)))
+	}
+	metaReader, err := NewMetaReader(provider, &config.Config{Logger: zap.NewNop()}, nil)
+	assert.NoError(t, err)
+
+	// The order of the timestamps doesn't matter
+	for _, order := range [][2]int64{{1000, 2000}, {2000, 1000}} {
+		diff, err := metaReader.Diff(ctx, "diff-cluster", common.MetaTypeLogic, order[0], order[1])
+		assert.NoError(t, err)
+		assert.Equal(t, &MetaDiff{
+			From:    1000,
+			To:      2000,
+			Added:   map[string]interface{}{"owner": "team-a"},
+			Removed: map[string]interface{}{"tier": "basic"},
+			Changed: map[string]MetaChange{"replicas": {From: float64(3), To: float64(5)}},
+		}, diff)
+		assert.False(t, diff.Empty())
+	}
+
+	diff, err := metaReader.Diff(ctx, "diff-cluster", common.MetaTypeLogic, 1000, 1000)
+	assert.NoError(t, err)
+	assert.True(t, diff.Empty())
+
+	_, err = metaReader.Diff(ctx, "diff-cluster", common.MetaTypeLogic, 1000, 1500)
+	assert.ErrorIs(t, err, reader.ErrFileNotFound)
+}
+
+// TestReadTyped tests decoding metadata into a struct
+func TestReadTyped(t *testing.T) {
+	type clusterConfig struct {
+		Region   string            `json:"region"`
+		Replicas int               `json:"replicas"`
+		Tags     map[string]string `json:"tags"`
+	}
+
+	ctx := context.Background()
+	provider := storage.NewMemoryProvider()
+	for modifyTS, metadata := range map[int64]map[string]interface{}{
+		1000: {"region": "us-west-2", "replicas": 3, "tags": map[string]interface{}{"env": "prod"}},
+		2000: {"region": "us-west-2", "replicas": 3, "tier": "basic"},
+	} {
+		compressedData, err := createCompressedTestData(&common.MetaData{
+			ClusterID: "typed-cluster",
+			Type:      common.MetaTypeLogic,
+			ModifyTS:  modifyTS,
+			Metadata:  metadata,
+		})
+		assert.NoError(t, err)
+		path := fmt.Sprintf("metering/meta/logic/typed-cluster/%d.json.gz", modifyTS)
+		assert.NoError(t, provider.Upload(ctx, path, bytes.NewReader(compressedData)))
+	}
+	metaReader, err := NewMetaReader(provider, &config.Config{Logger: zap.NewNop()}, nil)
+	assert.NoError(t, err)
+
+	typed, err := ReadTyped[clusterConfig](ctx, metaReader, "typed-cluster", common.MetaTypeLogic, 1500)
+	assert.NoError(t, err)
+	assert.Equal(t, &clusterConfig{Region: "us-west-2", Replicas: 3, Tags: map[string]string{"env": "prod"}}, typed)
+
+	// Unknown keys fail
+	_, err = ReadTyped[clusterConfig](ctx, metaReader, "typed-cluster", common.MetaTypeLogic, 2000)
+	assert.ErrorContains(t, err, `unknown field "tier"`)
+
+	_, err = ReadTyped[clusterConfig](ctx, metaReader, "typed-cluster", common.MetaTypeLogic, 500)
+	assert.ErrorIs(t, err, reader.ErrFileNotFound)
+
+	// Type mismatches fail
+	_, err = DecodeMetadata[clusterConfig](&common.MetaData{Metadata: map[string]interface{}{"replicas": "three"}})
+	assert.Error(t, err)
+}
+
+// TestMetaReader_ReadAll tests listing clusters and reading the metadata of all of them
+func TestMetaReader_ReadAll(t *testing.T) {
+	ctx := context.Background()
+	provider := storage.NewMemoryProvider()
+	upload := func(path string, metaData *common.MetaData) {
+		compressedData, err := createCompressedTestData(metaData)
+		assert.NoError(t, err)
+		assert.NoError(t, provider.Upload(ctx, path, bytes.NewReader(compressedData)))
+	}
+	for i := 0; i < 20; i++ {
+		clusterID := fmt.Sprintf("cluster-%02d", i)
+		upload(fmt.Sprintf("metering/meta/logic/%s/1000.json.gz", clusterID), &common.MetaData{
+			ClusterID: clusterID,
+			Type:      common.MetaTypeLogic,
+			ModifyTS:  1000,
+			Metadata:  map[string]interface{}{"index": i},
+		})
+	}
+	// Created after the timestamp read, deleted before it, with a category or of another type
+	upload("metering/meta/logic/late-cluster/3000.json.gz", &common.MetaData{Metadata: map[string]interface{}{}})
+	upload("metering/meta/logic/deleted-cluster/1500.json.gz", &common.MetaData{Metadata: map[string]interface{}{}, Deleted: true})
+	upload("metering/meta/logic/tidb/category-cluster/1000.json.gz", &common.MetaData{Metadata: map[string]interface{}{}})
+	upload("metering/meta/sharedpool/pool-cluster/1000.json.gz", &common.MetaData{Metadata: map[string]interface{}{}})
+
+	metaReader, err := NewMetaReader(provider, &config.Config{Logger: zap.NewNop()}, &Config{Concurrency: 4})
+	assert.NoError(t, err)
+
+	clusters, err := metaReader.ListClusters(ctx, common.MetaTypeLogic)
+	assert.NoError(t, err)
+	assert.Len(t, clusters, 22)
+	assert.Equal(t, "cluster-00", clusters[0])
+	assert.NotContains(t, clusters, "tidb")
+	assert.NotContains(t, clusters, "pool-cluster")
+
+	all, err := metaReader.ReadAll(ctx, common.MetaTypeLogic, 2000)
+	assert.NoError(t, err)
+	assert.Len(t, all, 20)
+	assert.Equal(t, float64(7), all["cluster-07"].Metadata["index"])
+	assert.Equal(t, "cluster-07", all["cluster-07"].ClusterID)
+
+	// Failures other than missing metadata fail the read
+	assert.NoError(t, provider.Upload(ctx, "metering/meta/logic/cluster-13/1100.json.gz", strings.NewReader("corrupt")))
+	_, err = metaReader.ReadAll(ctx, common.MetaTypeLogic, 2000)
+	assert.ErrorContains(t, err, "failed to read meta data of cluster cluster-13")
+
+	_, err = metaReader.ListClusters(ctx, common.MetaType("invalid"))
+	assert.Error(t, err)
+}
+
+// prefixListingProvider lists common prefixes natively, like S3, and records the prefixes listed in full
+type prefixListingProvider struct {
+	storage.ObjectStorageProvider
+	listed []string
+}
+
+func (p *prefixListingProvider) ListCommonPrefixes(ctx context.Context, prefix, delimiter string) ([]string, error) {
+	return storage.ListCommonPrefixes(ctx, p.ObjectStorageProvider, prefix, delimiter)
+}
+
+func (p *prefixListingProvider) List(ctx context.Context, prefix string) ([]string, error) {
+	p.listed = append(p.listed, prefix)
+	return p.ObjectStorageProvider.List(ctx, prefix)
+}
+
+// TestMetaReader_ListClustersDelimiter tests that clusters are found without listing every metadata file
+func TestMetaReader_ListClustersDelimiter(t *testing.T) {
+	ctx := context.Background()
+	memory := storage.NewMemoryProvider()
+	for _, path := range []string{
+		"metering/meta/logic/cluster-1/1000.json.gz",
+		"metering/meta/logic/cluster-1/2000.json.gz",
+		"metering/meta/logic/cluster-2/1000.json.gz",
+		// A category, with a cluster whose ID sorts before timestamps, and a cluster named like it
+		"metering/meta/logic/tidb/0cluster/1000.json.gz",
+		"metering/meta/logic/tikv/cluster-3/1000.json.gz",
+		"metering/meta/logic/tidb/1000.json.gz",
+	} {
+		assert.NoError(t, memory.Upload(ctx, path, strings.NewReader("{}")))
+	}
+	provider := &prefixListingProvider{ObjectStorageProvider: memory}
+	metaReader, err := NewMetaReader(provider, &config.Config{Logger: zap.NewNop()}, nil)
+	assert.NoError(t, err)
+
+	clusters, err := metaReader.ListClusters(ctx, common.MetaTypeLogic)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cluster-1", "cluster-2", "tidb"}, clusters)
+	assert.NotContains(t, provider.listed, "metering/meta/logic/", "the type directory is not listed in full")
+}
+
+// TestMetaReader_ClusterDeleted tests that reads after a tombstone fail with ErrClusterDeleted
+func TestMetaReader_ClusterDeleted(t *testing.T) {
+	provider := newMockObjectStorageProvider()
+	for _, metaData := range []*common.MetaData{
+		{ClusterID: "deleted-cluster", Type: common.MetaTypeLogic, ModifyTS: 1000, Metadata: map[string]interface{}{"name": "live"}},
+		{ClusterID: "deleted-cluster", Type: common.MetaTypeLogic, ModifyTS: 2000, Deleted: true},
+	} {
+		compressedData, err := createCompressedTestData(metaData)
+		assert.NoError(t, err)
+		provider.files[fmt.Sprintf("metering/meta/logic/deleted-cluster/%d.json.gz", metaData.ModifyTS)] = compressedData
+	}
+
+	metaReader, err := NewMetaReader(provider, &config.Config{Logger: zap.NewNop()}, nil)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	// Before the tombstone the cluster is alive
+	metaData, err := metaReader.ReadByType(ctx, "deleted-cluster", common.MetaTypeLogic, 1500)
+	assert.NoError(t, err)
+	assert.Equal(t, "live", metaData.Metadata["name"])
+
+	_, err = metaReader.ReadByType(ctx, "deleted-cluster", common.MetaTypeLogic, 2500)
+	assert.ErrorIs(t, err, reader.ErrClusterDeleted)
+	_, err = metaReader.ReadLatest(ctx, "deleted-cluster", common.MetaTypeLogic)
+	assert.ErrorIs(t, err, reader.ErrClusterDeleted)
+
+	// A cluster without metadata is not deleted
+	_, err = metaReader.ReadByType(ctx, "unknown-cluster", common.MetaTypeLogic, 2500)
+	assert.ErrorIs(t, err, reader.ErrFileNotFound)
+	assert.NotErrorIs(t, err, reader.ErrClusterDeleted)
+}
+
+// downloadCountingProvider counts the downloads of the wrapped memory provider
+type downloadCountingProvider struct {
+	*storage.MemoryProvider
+	downloads int
+}
+
+func (p *downloadCountingProvider) Download(ctx context.Context, path string) (io.ReadCloser, error) {
+	p.downloads++
+	return p.MemoryProvider.Download(ctx, path)
+}
+
+// TestMetaReader_UnchangedFile tests that unchanged meta files are not downloaded again
+func TestMetaReader_UnchangedFile(t *testing.T) {
+	ctx := context.Background()
+	provider := &downloadCountingProvider{MemoryProvider: storage.NewMemoryProvider()}
+	path := "metering/meta/logic/etag-cluster/1000.json.gz"
+	upload := func(revision string) {
+		compressedData, err := createCompressedTestData(&common.MetaData{
+			ClusterID: "etag-cluster",
+			Type:      common.MetaTypeLogic,
+			ModifyTS:  1000,
+			Metadata:  map[string]interface{}{"revision": revision},
+		})
+		assert.NoError(t, err)
+		assert.NoError(t, provider.Upload(ctx, path, bytes.NewReader(compressedData)))
+	}
+	upload("r1")
+
+	metaReader, err := NewMetaReader(provider, &config.Config{Logger: zap.NewNop()}, nil)
+	assert.NoError(t, err)
+	for range 3 {
+		metaData, err := metaReader.ReadLatest(ctx, "etag-cluster", common.MetaTypeLogic)
+		assert.NoError(t, err)
+		assert.Equal(t, "r1", metaData.Metadata["revision"])
+	}
+	assert.Equal(t, 1, provider.downloads)
+
+	// Overwritten files are downloaded again
+	upload("r2")
+	metaData, err := metaReader.ReadLatest(ctx, "etag-cluster", common.MetaTypeLogic)
+	assert.NoError(t, err)
+	assert.Equal(t, "r2", metaData.Metadata["revision"])
+	assert.Equal(t, 2, provider.downloads)
+}
+
+// listCountingProvider counts the listings of the wrapped memory provider
+type listCountingProvider struct {
+	*storage.MemoryProvider
+	lists int
+}
+
+func (p *listCountingProvider) List(ctx context.Context, prefix string) ([]string, error) {
+	p.lists++
+	return p.MemoryProvider.List(ctx, prefix)
+}
+
+// TestMetaReader_NegativeCache tests that missing metadata is remembered until the entry expires
+func TestMetaReader_NegativeCache(t *testing.T) {
+	ctx := context.Background()
+	provider := &listCountingProvider{MemoryProvider: storage.NewMemoryProvider()}
+	metaReader, err := NewMetaReader(provider, &config.Config{Logger: zap.NewNop()}, &Config{NegativeCacheTTL: time.Minute})
+	assert.NoError(t, err)
+	now := time.Unix(1000, 0)
+	metaReader.negative.now = func() time.Time { return now }
+
+	// Without any file, later timestamps are known to be missing too
+	for _, ts := range []int64{1000, 1000, 5000} {
+		_, err = metaReader.ReadByType(ctx, "missing-cluster", common.MetaTypeLogic, ts)
+		assert.ErrorIs(t, err, reader.ErrFileNotFound)
+		_, err = metaReader.Read(ctx, "missing-cluster", ts)
+		assert.ErrorIs(t, err, reader.ErrFileNotFound)
+	}
+	assert.Equal(t, 2, provider.lists)
+
+	// Metadata written meanwhile is seen once the entry expires
+	compressedData, err := createCompressedTestData(&common.MetaData{ClusterID: "missing-cluster", Type: common.MetaTypeLogic, ModifyTS: 3000})
+	assert.NoError(t, err)
+	assert.NoError(t, provider.Upload(ctx, "metering/meta/logic/missing-cluster/3000.json.gz", bytes.NewReader(compressedData)))
+	_, err = metaReader.ReadByType(ctx, "missing-cluster", common.MetaTypeLogic, 5000)
+	assert.ErrorIs(t, err, reader.ErrFileNotFound)
+	now = now.Add(time.Minute)
+	metaData, err := metaReader.ReadByType(ctx, "missing-cluster", common.MetaTypeLogic, 5000)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3000), metaData.ModifyTS)
+
+	// Timestamps before the first version are only missing up to the timestamp read
+	lists := provider.lists
+	for _, ts := range []int64{2000, 1500} {
+		_, err = metaReader.ReadByType(ctx, "missing-cluster", common.MetaTypeLogic, ts)
+		assert.ErrorIs(t, err, reader.ErrFileNotFound)
+	}
+	assert.Equal(t, lists+1, provider.lists)
+	_, err = metaReader.ReadByType(ctx, "missing-cluster", common.MetaTypeLogic, 3500)
+	assert.NoError(t, err)
+
+	// Disabled by default
+	metaReader, err = NewMetaReader(provider, &config.Config{Logger: zap.NewNop()}, nil)
+	assert.NoError(t, err)
+	assert.Nil(t, metaReader.negative)
+}
+
+func TestMetaReader_FormatVersion(t *testing.T) {
+	provider := newMockObjectStorageProvider()
+	compressedData, err := createCompressedTestData(&metaFile{
+		FormatVersion: common.CurrentFormatVersion + 1,
+		MetaData:      common.MetaData{ClusterID: "cluster-123", Type: common.MetaTypeLogic, ModifyTS: 1755687660},
+	})
+	assert.NoError(t, err)
+	path := "metering/meta/logic/cluster-123/1755687660.json.gz"
+	provider.files[path] = compressedData
+	ctx := context.Background()
+
+	// Best effort by default
+	metaReader, err := NewMetaReader(provider, &config.Config{Logger: zap.NewNop()}, nil)
+	assert.NoError(t, err)
+	result, err := metaReader.ReadFile(ctx, path)
+	assert.NoError(t, err)
+	assert.Equal(t, "cluster-123", result.(*common.MetaData).ClusterID)
+
+	strictReader, err := NewMetaReader(provider, (&config.Config{Logger: zap.NewNop()}).WithStrictFormatVersion(true), nil)
+	assert.NoError(t, err)
+	_, err = strictReader.ReadFile(ctx, path)
+	assert.ErrorIs(t, err, reader.ErrUnsupportedFormat)
+}
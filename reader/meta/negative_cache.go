@@ -0,0 +1,78 @@
+package metareader
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pingcap/metering_sdk/reader"
+)
+
+// maxNegativeEntries number of negative cache entries above which expired ones are swept
+const maxNegativeEntries = 10000
+
+// errNoMetaFiles not found error of clusters without any metadata file, at any timestamp
+var errNoMetaFiles = fmt.Errorf("%w", reader.ErrFileNotFound)
+
+// negativeCache remembers clusters found to have no metadata, so hot loops reading nonexistent
+// clusters don't list storage on every read
+type negativeCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]negativeEntry
+}
+
+// negativeEntry metadata of a cluster is missing at or before timestamp until expires
+type negativeEntry struct {
+	timestamp int64
+	expires   time.Time
+}
+
+// newNegativeCache creates a negative cache keeping entries for ttl, nil if ttl is not positive
+func newNegativeCache(ttl time.Duration) *negativeCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &negativeCache{ttl: ttl, now: time.Now, entries: make(map[string]negativeEntry)}
+}
+
+// missing reports whether the metadata under key is known to be missing at timestamp
+func (c *negativeCache) missing(key string, timestamp int64) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	return ok && c.now().Before(entry.expires) && timestamp <= entry.timestamp
+}
+
+// add records that the metadata under key was not found at timestamp, err being the not found error
+func (c *negativeCache) add(key string, timestamp int64, err error) {
+	if c == nil {
+		return
+	}
+	// Without any file the metadata is missing at every timestamp
+	if errors.Is(err, errNoMetaFiles) {
+		timestamp = math.MaxInt64
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if len(c.entries) >= maxNegativeEntries {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxNegativeEntries {
+			clear(c.entries)
+		}
+	}
+	c.entries[key] = negativeEntry{timestamp: timestamp, expires: now.Add(c.ttl)}
+}
@@ -0,0 +1,38 @@
+package metareader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pingcap/metering_sdk/common"
+)
+
+// ReadTyped reads the metadata of the specified cluster and type at or before timestamp like
+// MetaReader.ReadByType, and decodes its Metadata into T. Decoding is strict, keys without a matching
+// field of T fail, so schema drift is caught instead of silently dropped.
+func ReadTyped[T any](ctx context.Context, r *MetaReader, clusterID string, metaType common.MetaType, timestamp int64) (*T, error) {
+	metaData, err := r.ReadByType(ctx, clusterID, metaType, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeMetadata[T](metaData)
+}
+
+// DecodeMetadata decodes the Metadata of metaData into T with the same strict field checking as
+// ReadTyped, e.g. for metadata read with ReadLatest or ReadVersion
+func DecodeMetadata[T any](metaData *common.MetaData) (*T, error) {
+	// Metadata was decoded from JSON with numbers as float64, integers beyond 2^53 have already lost precision
+	data, err := json.Marshal(metaData.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata of cluster %s: %w", metaData.ClusterID, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var typed T
+	if err := decoder.Decode(&typed); err != nil {
+		return nil, fmt.Errorf("failed to decode metadata of cluster %s into %T: %w", metaData.ClusterID, typed, err)
+	}
+	return &typed, nil
+}
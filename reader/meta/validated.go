@@ -0,0 +1,79 @@
+package metareader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/pingcap/metering_sdk/reader"
+	"github.com/pingcap/metering_sdk/storage"
+)
+
+// maxValidatedFiles number of parsed meta files kept for ETag revalidation
+const maxValidatedFiles = 1024
+
+// validatedFile a parsed meta file and the ETag it was read at
+type validatedFile struct {
+	etag     string
+	metaData *common.MetaData
+}
+
+// checkFile checks that the file at path exists and returns its ETag, "" if the provider can't stat objects
+func (r *MetaReader) checkFile(ctx context.Context, path string) (string, error) {
+	if r.stater == nil {
+		exists, err := r.provider.Exists(ctx, path)
+		if err != nil {
+			return "", fmt.Errorf("failed to check if file exists: %w", err)
+		}
+		if !exists {
+			return "", fmt.Errorf("%w: %s", reader.ErrFileNotFound, path)
+		}
+		return "", nil
+	}
+
+	attrs, err := r.stater.Stat(ctx, path)
+	if errors.Is(err, storage.ErrNotFound) {
+		return "", fmt.Errorf("%w: %s", reader.ErrFileNotFound, path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to check if file exists: %w", err)
+	}
+	return attrs.ETag, nil
+}
+
+// validatedFile returns a copy of the meta file read from path if its ETag is still etag, otherwise nil
+func (r *MetaReader) validatedFile(path, etag string) *common.MetaData {
+	if etag == "" {
+		return nil
+	}
+	r.filesMu.Lock()
+	file, ok := r.files[path]
+	r.filesMu.Unlock()
+	hit := ok && file.etag == etag
+	r.config.Metrics.ObserveCache("meta_file", hit)
+	if !hit {
+		return nil
+	}
+	// Callers may set the identifying fields, the metadata content is shared
+	metaData := *file.metaData
+	return &metaData
+}
+
+// storeValidatedFile keeps a copy of the meta file read from path at etag
+func (r *MetaReader) storeValidatedFile(path, etag string, metaData *common.MetaData) {
+	if etag == "" {
+		return
+	}
+	stored := *metaData
+	r.filesMu.Lock()
+	defer r.filesMu.Unlock()
+	if _, ok := r.files[path]; !ok && len(r.files) >= maxValidatedFiles {
+		// Evict an arbitrary file, it is only downloaded again
+		for evicted := range r.files {
+			delete(r.files, evicted)
+			break
+		}
+	}
+	r.files[path] = &validatedFile{etag: etag, metaData: &stored}
+}
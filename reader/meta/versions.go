@@ -0,0 +1,198 @@
+package metareader
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/pingcap/metering_sdk/storage"
+	"github.com/pingcap/metering_sdk/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// MetaVersion describes one version of the metadata of a cluster, identified by its ModifyTS
+type MetaVersion struct {
+	ModifyTS int64  `json:"modify_ts"` // modify timestamp of the version
+	Path     string `json:"path"`      // path of the metadata file
+	Size     int64  `json:"size"`      // stored, i.e. compressed, size in bytes
+}
+
+// ListVersions lists every version of the metadata of the specified cluster and type with ModifyTS
+// between from and to inclusive, oldest first, e.g. to audit how the metadata changed over time.
+// To page through a long history, pass the ModifyTS after the last one returned as the next from.
+func (r *MetaReader) ListVersions(ctx context.Context, clusterID string, metaType common.MetaType, from, to int64) ([]MetaVersion, error) {
+	ctx, span := tracing.Start(ctx, r.config.TracerProvider, "MetaReader.ListVersions",
+		tracing.AttributeClusterID.String(clusterID),
+		attribute.String("metering.meta_type", string(metaType)),
+	)
+	versions, err := r.listVersions(ctx, clusterID, metaType, from, to)
+	tracing.End(span, err)
+	return versions, err
+}
+
+// listVersions lists the versions of the metadata of the specified cluster and type from storage
+func (r *MetaReader) listVersions(ctx context.Context, clusterID string, metaType common.MetaType, from, to int64) ([]MetaVersion, error) {
+	if err := r.checkOpen(); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if !common.ValidMetaTypes[metaType] {
+		return nil, fmt.Errorf("invalid metadata type: %s, must be one of: logic, sharedpool", metaType)
+	}
+
+	// Sizes come with the listing, providers that can't list them are stat'ed per file
+	prefix := fmt.Sprintf("metering/meta/%s/%s/", string(metaType), clusterID)
+	objects, err := storage.ListAttributes(ctx, r.provider, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	var versions []MetaVersion
+	for _, object := range objects {
+		file := object.Path
+		// Skip files of a category named like the cluster, they are one level deeper
+		if !strings.HasSuffix(path.Dir(file)+"/", prefix) {
+			continue
+		}
+		modifyTS, err := r.extractTimestampFromFilename(file)
+		if err != nil {
+			r.logger.Debug("Failed to extract timestamp from filename",
+				zap.String("file", file),
+				zap.Error(err),
+			)
+			continue
+		}
+		if modifyTS < from || modifyTS > to {
+			continue
+		}
+		versions = append(versions, MetaVersion{ModifyTS: modifyTS, Path: file, Size: object.Size})
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].ModifyTS < versions[j].ModifyTS
+	})
+
+	r.logger.Debug("Listed meta data versions",
+		zap.String("cluster_id", clusterID),
+		zap.String("type", string(metaType)),
+		zap.Int64("from", from),
+		zap.Int64("to", to),
+		zap.Int("version_count", len(versions)),
+	)
+	return versions, nil
+}
+
+// ReadVersion reads the metadata of the specified cluster and type with exactly the given ModifyTS,
+// as returned by ListVersions. Results are never cached.
+func (r *MetaReader) ReadVersion(ctx context.Context, clusterID string, metaType common.MetaType, modifyTS int64) (*common.MetaData, error) {
+	ctx, span := tracing.Start(ctx, r.config.TracerProvider, "MetaReader.ReadVersion",
+		tracing.AttributeClusterID.String(clusterID),
+		tracing.AttributeTimestamp.Int64(modifyTS),
+		attribute.String("metering.meta_type", string(metaType)),
+	)
+	metaData, err := r.readVersion(ctx, clusterID, metaType, modifyTS)
+	tracing.End(span, err)
+	return metaData, err
+}
+
+// readVersion reads the metadata version with the given ModifyTS from storage
+func (r *MetaReader) readVersion(ctx context.Context, clusterID string, metaType common.MetaType, modifyTS int64) (*common.MetaData, error) {
+	if err := r.checkOpen(); err != nil {
+		return nil, err
+	}
+	if !common.ValidMetaTypes[metaType] {
+		return nil, fmt.Errorf("invalid metadata type: %s, must be one of: logic, sharedpool", metaType)
+	}
+
+	filePath := fmt.Sprintf("metering/meta/%s/%s/%d.json.gz", string(metaType), clusterID, modifyTS)
+	data, err := r.ReadFile(ctx, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read meta file %s: %w", filePath, err)
+	}
+	metaData, ok := data.(*common.MetaData)
+	if !ok {
+		return nil, fmt.Errorf("invalid data type from file %s", filePath)
+	}
+
+	metaData.ClusterID = clusterID
+	metaData.Type = metaType
+	metaData.ModifyTS = modifyTS
+	return metaData, nil
+}
+
+// MetaDiff top-level metadata keys that differ between two versions of the metadata of a cluster
+type MetaDiff struct {
+	From    int64                  `json:"from"`    // ModifyTS of the older version
+	To      int64                  `json:"to"`      // ModifyTS of the newer version
+	Added   map[string]interface{} `json:"added"`   // keys only in the newer version, with their values
+	Removed map[string]interface{} `json:"removed"` // keys only in the older version, with their values
+	Changed map[string]MetaChange  `json:"changed"` // keys in both versions with different values
+}
+
+// MetaChange values of a metadata key changed between two versions
+type MetaChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Empty reports whether the versions have the same metadata
+func (d *MetaDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Diff compares the metadata versions of the specified cluster and type with ModifyTS tsA and tsB, as
+// returned by ListVersions, e.g. for audit tooling showing what changed in a cluster's configuration.
+// Keys are compared at the top level; the diff reads from the older to the newer version whatever the
+// order of tsA and tsB. A tombstone has no metadata, so every key appears removed.
+func (r *MetaReader) Diff(ctx context.Context, clusterID string, metaType common.MetaType, tsA, tsB int64) (*MetaDiff, error) {
+	ctx, span := tracing.Start(ctx, r.config.TracerProvider, "MetaReader.Diff",
+		tracing.AttributeClusterID.String(clusterID),
+		attribute.String("metering.meta_type", string(metaType)),
+	)
+	diff, err := r.diff(ctx, clusterID, metaType, tsA, tsB)
+	tracing.End(span, err)
+	return diff, err
+}
+
+// diff reads both versions and compares their metadata
+func (r *MetaReader) diff(ctx context.Context, clusterID string, metaType common.MetaType, tsA, tsB int64) (*MetaDiff, error) {
+	if tsA > tsB {
+		tsA, tsB = tsB, tsA
+	}
+	from, err := r.readVersion(ctx, clusterID, metaType, tsA)
+	if err != nil {
+		return nil, err
+	}
+	to, err := r.readVersion(ctx, clusterID, metaType, tsB)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &MetaDiff{
+		From:    tsA,
+		To:      tsB,
+		Added:   make(map[string]interface{}),
+		Removed: make(map[string]interface{}),
+		Changed: make(map[string]MetaChange),
+	}
+	for key, value := range from.Metadata {
+		newValue, ok := to.Metadata[key]
+		if !ok {
+			diff.Removed[key] = value
+		} else if !reflect.DeepEqual(value, newValue) {
+			diff.Changed[key] = MetaChange{From: value, To: newValue}
+		}
+	}
+	for key, value := range to.Metadata {
+		if _, ok := from.Metadata[key]; !ok {
+			diff.Added[key] = value
+		}
+	}
+	return diff, nil
+}
@@ -0,0 +1,69 @@
+package meteringreader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/pingcap/metering_sdk/layout"
+)
+
+// finalizedFiles returns the set of files listed in the finalize markers of timestamp, or nil unless
+// RequireFinalized is set
+func (r *MeteringReader) finalizedFiles(ctx context.Context, timestamp int64) (map[string]struct{}, error) {
+	if err := r.checkOpen(); err != nil {
+		return nil, err
+	}
+	if !r.config.RequireFinalized {
+		return nil, nil
+	}
+	prefix := layout.FinalizedMarkerPrefix(timestamp)
+	markers, err := r.provider.List(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list finalize markers with prefix %s: %w", prefix, err)
+	}
+
+	files := make(map[string]struct{})
+	for _, path := range markers {
+		marker, err := r.readFinalizeMarker(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range marker.Files {
+			files[file] = struct{}{}
+		}
+	}
+	return files, nil
+}
+
+// readFinalizeMarker reads the finalize marker at path
+func (r *MeteringReader) readFinalizeMarker(ctx context.Context, path string) (*common.FinalizeMarker, error) {
+	if err := r.checkOpen(); err != nil {
+		return nil, err
+	}
+	body, err := r.provider.Download(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read finalize marker %s: %w", path, err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read finalize marker %s: %w", path, err)
+	}
+	marker := &common.FinalizeMarker{}
+	if err := json.Unmarshal(data, marker); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal finalize marker %s: %w", path, err)
+	}
+	return marker, nil
+}
+
+// isFinalized reports whether path is in finalized, the result of finalizedFiles
+func isFinalized(finalized map[string]struct{}, path string) bool {
+	if finalized == nil {
+		return true
+	}
+	_, ok := finalized[path]
+	return ok
+}
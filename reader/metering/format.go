@@ -0,0 +1,54 @@
+package meteringreader
+
+import (
+	"fmt"
+
+	"github.com/pingcap/metering_sdk/common"
+)
+
+// FormatVersion identifies the format a metering data file was written with, from its format_version
+// field or, for files predating it, from its fields
+type FormatVersion int
+
+const (
+	// FormatVersionLegacy files attribute their data to a physical_cluster_id and have no shared_pool_id
+	FormatVersionLegacy FormatVersion = 1
+	// FormatVersionCurrent files attribute their data to a shared_pool_id, as written by this SDK
+	FormatVersionCurrent FormatVersion = common.CurrentFormatVersion
+)
+
+// String returns the name of the format version
+func (v FormatVersion) String() string {
+	switch v {
+	case FormatVersionLegacy:
+		return "legacy"
+	case FormatVersionCurrent:
+		return "current"
+	default:
+		return fmt.Sprintf("v%d", int(v))
+	}
+}
+
+// versionedMeteringData metering data decoded with the fields of every historical format
+type versionedMeteringData struct {
+	common.MeteringData
+	FormatVersion     int    `json:"format_version"`      // 0 for files predating format_version
+	PhysicalClusterID string `json:"physical_cluster_id"` // legacy name of SharedPoolID
+}
+
+// normalize returns the data in the current format along with the format it was written with. Legacy
+// files are recognized by a physical_cluster_id without shared_pool_id.
+func (d *versionedMeteringData) normalize() (*common.MeteringData, FormatVersion) {
+	data := d.MeteringData
+	version := FormatVersion(d.FormatVersion)
+	if d.SharedPoolID == "" && d.PhysicalClusterID != "" {
+		data.SharedPoolID = d.PhysicalClusterID
+		if version == 0 {
+			version = FormatVersionLegacy
+		}
+	}
+	if version == 0 {
+		version = FormatVersionCurrent
+	}
+	return &data, version
+}
@@ -0,0 +1,128 @@
+package meteringreader
+
+import (
+	"context"
+	"fmt"
+	"iter"
+
+	"github.com/pingcap/metering_sdk/internal/utils"
+	"github.com/pingcap/metering_sdk/logging"
+	"github.com/pingcap/metering_sdk/schema"
+	"go.uber.org/zap"
+)
+
+// TimeRange minute-level time range, both ends inclusive
+type TimeRange struct {
+	Start int64 `json:"start"` // first minute-level timestamp
+	End   int64 `json:"end"`   // last minute-level timestamp
+}
+
+// Validate validates the time range
+func (tr TimeRange) Validate() error {
+	if err := utils.ValidateTimestamp(tr.Start); err != nil {
+		return fmt.Errorf("invalid range start: %w", err)
+	}
+	if err := utils.ValidateTimestamp(tr.End); err != nil {
+		return fmt.Errorf("invalid range end: %w", err)
+	}
+	if tr.End < tr.Start {
+		return fmt.Errorf("range end %d is before range start %d", tr.End, tr.Start)
+	}
+	return nil
+}
+
+// RecordQuery selects the records returned by Records
+type RecordQuery struct {
+	TimeRange TimeRange `json:"time_range"`         // time range to scan
+	Category  string    `json:"category,omitempty"` // only scan this category when set
+	// Predicates only return the records matching every predicate, see ReadFileStream
+	Predicates []Predicate `json:"-"`
+}
+
+// Record a single logical cluster entry together with the file it was read from
+type Record struct {
+	File *MeteringFileInfo      // source file information
+	Data map[string]interface{} // logical cluster metering data
+}
+
+// Files returns an iterator over all metering files in the time range matching opts, ordered by
+// timestamp, category and path. Listing is performed lazily one timestamp at a time, narrowed by opts as
+// in ListFilesByTimestamp, and iteration stops when ctx is cancelled. An error is yielded once and ends
+// the iteration.
+func (r *MeteringReader) Files(ctx context.Context, tr TimeRange, opts ...ListOption) iter.Seq2[*MeteringFileInfo, error] {
+	return func(yield func(*MeteringFileInfo, error) bool) {
+		if err := tr.Validate(); err != nil {
+			yield(nil, err)
+			return
+		}
+
+		for ts := tr.Start; ts <= tr.End; ts += 60 {
+			if err := ctx.Err(); err != nil {
+				yield(nil, err)
+				return
+			}
+
+			timestampFiles, err := r.ListFilesByTimestamp(ctx, ts, opts...)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			for _, filePath := range sortedFiles(timestampFiles) {
+				info, err := r.GetFileInfo(filePath)
+				if err != nil {
+					r.logger.Warn("Failed to parse file info, skipping",
+						logging.Path(filePath),
+						zap.Error(err),
+					)
+					continue
+				}
+				if !yield(info, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Records returns an iterator over every logical cluster entry of the files matched by query.
+// Files are downloaded lazily as iteration proceeds and their entries decoded one at a time, see
+// ReadFileStream.
+// An error is yielded once and ends the iteration.
+func (r *MeteringReader) Records(ctx context.Context, query RecordQuery) iter.Seq2[*Record, error] {
+	return func(yield func(*Record, error) bool) {
+		var opts []ListOption
+		if query.Category != "" {
+			opts = append(opts, WithCategoryFilter(query.Category))
+		}
+		for info, err := range r.Files(ctx, query.TimeRange, opts...) {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			for entry, err := range r.ReadFileStream(ctx, info.Path, query.Predicates...) {
+				if err != nil {
+					yield(nil, fmt.Errorf("failed to read file %s: %w", info.Path, err))
+					return
+				}
+				if !yield(&Record{File: info, Data: entry}, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// InferSchemas scans the records matched by query and infers the schema of every category observed,
+// e.g. to bootstrap schema registration or to detect drift against registered schemas
+func (r *MeteringReader) InferSchemas(ctx context.Context, query RecordQuery) (*schema.Report, error) {
+	inferrer := schema.NewInferrer()
+	for record, err := range r.Records(ctx, query) {
+		if err != nil {
+			return nil, err
+		}
+		inferrer.Observe(record.File.Category, record.Data)
+	}
+	return inferrer.Report(), nil
+}
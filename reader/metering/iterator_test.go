@@ -0,0 +1,322 @@
+package meteringreader
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/pingcap/metering_sdk/config"
+	"github.com/pingcap/metering_sdk/internal/compress"
+	"github.com/pingcap/metering_sdk/reader"
+	"github.com/pingcap/metering_sdk/schema"
+	"github.com/pingcap/metering_sdk/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func putTestMeteringFile(t *testing.T, provider *mockObjectStorageProvider, ts int64, category, selfID string, part int, data []map[string]interface{}) string {
+	t.Helper()
+	compressed, err := createCompressedTestData(common.MeteringData{
+		Timestamp:    ts,
+		Category:     category,
+		SelfID:       selfID,
+		SharedPoolID: "pool1",
+		Data:         data,
+	})
+	require.NoError(t, err)
+	path := fmt.Sprintf("metering/ru/%d/%s/pool1/%s-%d.json.gz", ts, category, selfID, part)
+	provider.files[path] = compressed
+	return path
+}
+
+func TestMeteringReader_Files(t *testing.T) {
+	provider := newMockObjectStorageProvider()
+	putTestMeteringFile(t, provider, 1755687660, "tikv", "server1", 0, nil)
+	putTestMeteringFile(t, provider, 1755687660, "tidb", "server1", 0, nil)
+	putTestMeteringFile(t, provider, 1755687780, "tidb", "server2", 0, nil)
+	putTestMeteringFile(t, provider, 1755687840, "tidb", "server3", 0, nil) // out of range
+
+	r := NewMeteringReader(provider, &config.Config{Logger: zap.NewNop()})
+	ctx := context.Background()
+
+	var got []string
+	for info, err := range r.Files(ctx, TimeRange{Start: 1755687660, End: 1755687780}) {
+		require.NoError(t, err)
+		got = append(got, fmt.Sprintf("%d/%s/%s", info.Timestamp, info.Category, info.SelfID))
+	}
+	assert.Equal(t, []string{
+		"1755687660/tidb/server1",
+		"1755687660/tikv/server1",
+		"1755687780/tidb/server2",
+	}, got)
+
+	// Early break stops iteration
+	count := 0
+	for range r.Files(ctx, TimeRange{Start: 1755687660, End: 1755687840}) {
+		count++
+		break
+	}
+	assert.Equal(t, 1, count)
+
+	// Invalid range yields an error
+	for _, err := range r.Files(ctx, TimeRange{Start: 1755687780, End: 1755687660}) {
+		assert.Error(t, err)
+	}
+}
+
+func TestMeteringReader_FilesCancelled(t *testing.T) {
+	provider := newMockObjectStorageProvider()
+	putTestMeteringFile(t, provider, 1755687660, "tidb", "server1", 0, nil)
+
+	r := NewMeteringReader(provider, &config.Config{Logger: zap.NewNop()})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var errs []error
+	for info, err := range r.Files(ctx, TimeRange{Start: 1755687660, End: 1755687660}) {
+		assert.Nil(t, info)
+		errs = append(errs, err)
+	}
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], context.Canceled)
+}
+
+func TestMeteringReader_Records(t *testing.T) {
+	provider := newMockObjectStorageProvider()
+	putTestMeteringFile(t, provider, 1755687660, "tidb", "server1", 0, []map[string]interface{}{
+		{"logical_cluster_id": "lc1"},
+		{"logical_cluster_id": "lc2"},
+	})
+	putTestMeteringFile(t, provider, 1755687660, "tikv", "server1", 0, []map[string]interface{}{
+		{"logical_cluster_id": "lc3"},
+	})
+
+	r := NewMeteringReader(provider, &config.Config{Logger: zap.NewNop()})
+	ctx := context.Background()
+
+	var ids []string
+	for rec, err := range r.Records(ctx, RecordQuery{TimeRange: TimeRange{Start: 1755687660, End: 1755687660}}) {
+		require.NoError(t, err)
+		ids = append(ids, rec.Data["logical_cluster_id"].(string))
+	}
+	assert.Equal(t, []string{"lc1", "lc2", "lc3"}, ids)
+
+	ids = nil
+	for rec, err := range r.Records(ctx, RecordQuery{TimeRange: TimeRange{Start: 1755687660, End: 1755687660}, Category: "tikv"}) {
+		require.NoError(t, err)
+		assert.Equal(t, "tikv", rec.File.Category)
+		ids = append(ids, rec.Data["logical_cluster_id"].(string))
+	}
+	assert.Equal(t, []string{"lc3"}, ids)
+
+	// The category narrows the listing instead of being filtered client-side
+	recording := &prefixRecordingProvider{mockObjectStorageProvider: provider}
+	r = NewMeteringReader(recording, &config.Config{Logger: zap.NewNop()})
+	for _, err := range r.Records(ctx, RecordQuery{TimeRange: TimeRange{Start: 1755687660, End: 1755687660}, Category: "tikv"}) {
+		require.NoError(t, err)
+	}
+	assert.Contains(t, recording.prefixes, "metering/ru/1755687660/tikv/")
+	assert.NotContains(t, recording.prefixes, "metering/ru/1755687660/")
+}
+
+func TestMeteringReader_ReadFileStream(t *testing.T) {
+	provider := newMockObjectStorageProvider()
+	path := putTestMeteringFile(t, provider, 1755687660, "tidb", "server1", 0, []map[string]interface{}{
+		{"logical_cluster_id": "lc1", "ru": map[string]interface{}{"value": 1.5, "unit": "RU"}},
+		{"logical_cluster_id": "lc2"},
+		{"logical_cluster_id": "lc3"},
+	})
+	r := NewMeteringReader(provider, &config.Config{Logger: zap.NewNop()})
+	ctx := context.Background()
+
+	var entries []map[string]interface{}
+	for entry, err := range r.ReadFileStream(ctx, path) {
+		require.NoError(t, err)
+		entries = append(entries, entry)
+	}
+	data, err := r.ReadFile(ctx, path)
+	require.NoError(t, err)
+	assert.Equal(t, data.Data, entries)
+
+	// Stopping early
+	var ids []string
+	for entry, err := range r.ReadFileStream(ctx, path) {
+		require.NoError(t, err)
+		ids = append(ids, entry["logical_cluster_id"].(string))
+		break
+	}
+	assert.Equal(t, []string{"lc1"}, ids)
+
+	// Truncated files yield the entries before the error
+	compressed, err := compress.Gzip([]byte(`{"timestamp":1755687660,"data":[{"logical_cluster_id":"lc1"},{"logical_cluster_id":"lc2"},{"logi`))
+	require.NoError(t, err)
+	provider.files[path] = compressed
+	ids = nil
+	for entry, err := range r.ReadFileStream(ctx, path) {
+		if err != nil {
+			assert.ErrorIs(t, err, reader.ErrInvalidFormat)
+			break
+		}
+		ids = append(ids, entry["logical_cluster_id"].(string))
+	}
+	assert.Equal(t, []string{"lc1", "lc2"}, ids)
+
+	memory := NewMeteringReader(storage.NewMemoryProvider(), nil)
+	for _, err := range memory.ReadFileStream(ctx, path) {
+		assert.ErrorIs(t, err, reader.ErrFileNotFound)
+	}
+}
+
+func TestMeteringReader_Predicates(t *testing.T) {
+	provider := newMockObjectStorageProvider()
+	path := putTestMeteringFile(t, provider, 1755687660, "tidb", "server1", 0, []map[string]interface{}{
+		{"logical_cluster_id": "lc1", "compute_seconds": map[string]interface{}{"value": 10, "unit": "seconds"}},
+		{"logical_cluster_id": "lc2", "compute_seconds": map[string]interface{}{"value": 0, "unit": "seconds"}},
+		{"logical_cluster_id": "lc3", "compute_seconds": map[string]interface{}{"value": 5, "unit": "seconds"}},
+		{"logical_cluster_id": "lc4"},
+	})
+	putTestMeteringFile(t, provider, 1755687660, "tikv", "server1", 0, []map[string]interface{}{
+		{"logical_cluster_id": "lc1", "compute_seconds": 3},
+	})
+	r := NewMeteringReader(provider, &config.Config{Logger: zap.NewNop()})
+	ctx := context.Background()
+
+	ids := func(entries []map[string]interface{}) []string {
+		var ids []string
+		for _, entry := range entries {
+			ids = append(ids, entry["logical_cluster_id"].(string))
+		}
+		return ids
+	}
+
+	predicates := []Predicate{
+		FieldIn(common.LogicalClusterIDField, "lc1", "lc2", "lc3"),
+		FieldGreaterThan("compute_seconds", 0),
+	}
+	data, err := r.ReadFile(ctx, path, predicates...)
+	require.NoError(t, err)
+	assert.Equal(t, "tidb", data.Category)
+	assert.Equal(t, []string{"lc1", "lc3"}, ids(data.Data))
+	assert.Equal(t, map[string]interface{}{"value": float64(10), "unit": "seconds"}, data.Data[0]["compute_seconds"])
+
+	var streamed []map[string]interface{}
+	for entry, err := range r.ReadFileStream(ctx, path, FieldLessThan("compute_seconds", 6)) {
+		require.NoError(t, err)
+		streamed = append(streamed, entry)
+	}
+	assert.Equal(t, []string{"lc2", "lc3"}, ids(streamed))
+
+	// Predicates apply to every file of Records, plain numbers are compared too
+	var records []string
+	query := RecordQuery{TimeRange: TimeRange{Start: 1755687660, End: 1755687660}, Predicates: predicates}
+	for rec, err := range r.Records(ctx, query) {
+		require.NoError(t, err)
+		records = append(records, rec.File.Category+"/"+rec.Data["logical_cluster_id"].(string))
+	}
+	assert.Equal(t, []string{"tidb/lc1", "tidb/lc3", "tikv/lc1"}, records)
+
+	// Custom predicates see missing fields as nil
+	data, err = r.ReadFile(ctx, path, Predicate{Field: "compute_seconds", Match: func(value interface{}) bool { return value == nil }})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"lc4"}, ids(data.Data))
+
+	_, err = r.ReadFile(ctx, path, Predicate{Field: "compute_seconds"})
+	assert.Error(t, err)
+}
+
+func TestMeteringReader_InferSchemas(t *testing.T) {
+	provider := newMockObjectStorageProvider()
+	putTestMeteringFile(t, provider, 1755687660, "tidb", "server1", 0, []map[string]interface{}{
+		{"logical_cluster_id": "lc1", "ru": &common.MeteringValue{Value: 10, Unit: "count"}, "region": "us-east-1"},
+		{"logical_cluster_id": "lc2", "ru": &common.MeteringValue{Value: 20, Unit: "count"}},
+	})
+	putTestMeteringFile(t, provider, 1755687720, "tidb", "server1", 0, []map[string]interface{}{
+		{"logical_cluster_id": "lc1", "ru": common.NewFloatMeteringValue(1.5, 1, "kcount")},
+	})
+	putTestMeteringFile(t, provider, 1755687720, "tikv", "server1", 0, []map[string]interface{}{
+		{"logical_cluster_id": "lc1", "disk": &common.MeteringValue{Value: 100, Unit: "GB"}},
+	})
+
+	r := NewMeteringReader(provider, &config.Config{Logger: zap.NewNop()})
+	report, err := r.InferSchemas(context.Background(), RecordQuery{TimeRange: TimeRange{Start: 1755687660, End: 1755687720}})
+	require.NoError(t, err)
+	require.Len(t, report.Categories, 2)
+
+	tidb := report.Category("tidb")
+	require.NotNil(t, tidb)
+	assert.Equal(t, int64(3), tidb.Entries)
+	require.Len(t, tidb.Fields, 3)
+
+	lcID := tidb.Fields[0]
+	assert.Equal(t, "logical_cluster_id", lcID.Name)
+	assert.Equal(t, 2, lcID.Cardinality)
+	assert.Equal(t, schema.FieldTypeString, lcID.DominantType)
+
+	region := tidb.Fields[1]
+	assert.Equal(t, "region", region.Name)
+	assert.InDelta(t, 2.0/3.0, region.NullRate, 1e-9)
+
+	ru := tidb.Fields[2]
+	assert.Equal(t, schema.FieldTypeMeteringValue, ru.DominantType)
+	assert.Equal(t, []string{"count", "kcount"}, ru.Units)
+
+	inferred := tidb.Schema()
+	assert.Equal(t, []schema.Field{
+		{Name: "logical_cluster_id", Type: schema.FieldTypeString, Required: true},
+		{Name: "region", Type: schema.FieldTypeString},
+		{Name: "ru", Type: schema.FieldTypeMeteringValue, Required: true, Units: []string{"count", "kcount"}},
+	}, inferred.Fields)
+	assert.Empty(t, tidb.Drift(inferred))
+
+	registered := &schema.Schema{
+		Category: "tidb",
+		Fields: []schema.Field{
+			{Name: "logical_cluster_id", Type: schema.FieldTypeString, Required: true},
+			{Name: "region", Type: schema.FieldTypeString, Required: true},
+			{Name: "ru", Type: schema.FieldTypeMeteringValue, Required: true, Units: []string{"count"}},
+		},
+	}
+	assert.Equal(t, []string{
+		`field ru has new unit "kcount"`,
+		"required field region is missing in 66.7% of entries",
+	}, tidb.Drift(registered))
+}
+
+func TestMeteringReader_ReadLogicalCluster(t *testing.T) {
+	provider := newMockObjectStorageProvider()
+	putTestMeteringFile(t, provider, 1755687720, "tidb", "server1", 0, []map[string]interface{}{
+		{"logical_cluster_id": "lc1", "ru": 3.0},
+	})
+	putTestMeteringFile(t, provider, 1755687660, "tikv", "server1", 0, []map[string]interface{}{
+		{"logical_cluster_id": "lc2", "ru": 1.0},
+		{"logical_cluster_id": "lc1", "ru": 2.0},
+	})
+	putTestMeteringFile(t, provider, 1755687660, "tidb", "server1", 1, []map[string]interface{}{
+		{"logical_cluster_id": "lc1", "ru": 1.0},
+	})
+	putTestMeteringFile(t, provider, 1755687660, "tidb", "server1", 0, []map[string]interface{}{
+		{"logical_cluster_id": "lc1", "ru": 0.0},
+		{"logical_cluster_id": "lc2", "ru": 0.0},
+	})
+
+	r := NewMeteringReader(provider, &config.Config{Logger: zap.NewNop()})
+	records, err := r.ReadLogicalCluster(context.Background(), TimeRange{Start: 1755687660, End: 1755687720}, "lc1")
+	require.NoError(t, err)
+	var got []string
+	for _, rec := range records {
+		assert.Equal(t, "lc1", rec.Data["logical_cluster_id"])
+		got = append(got, fmt.Sprintf("%d/%s/%d/%v", rec.File.Timestamp, rec.File.Category, rec.File.Part, rec.Data["ru"]))
+	}
+	assert.Equal(t, []string{
+		"1755687660/tidb/0/0",
+		"1755687660/tidb/1/1",
+		"1755687660/tikv/0/2",
+		"1755687720/tidb/0/3",
+	}, got)
+
+	records, err = r.ReadLogicalCluster(context.Background(), TimeRange{Start: 1755687660, End: 1755687720}, "lc3")
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
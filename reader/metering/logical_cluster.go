@@ -0,0 +1,73 @@
+package meteringreader
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pingcap/metering_sdk/common"
+	"go.uber.org/zap"
+)
+
+// ReadLogicalCluster returns the rows of logicalClusterID, i.e. the Data entries whose
+// common.LogicalClusterIDField matches it, of every metering file in the time range, e.g. to reconcile
+// the bill of a single tenant. Files of each timestamp are read in parallel as in ScanTimestamp, and
+// only the matching rows are kept. Rows are ordered by timestamp, category, shared pool, self ID and
+// part, then as written.
+func (r *MeteringReader) ReadLogicalCluster(ctx context.Context, tr TimeRange, logicalClusterID string) ([]*Record, error) {
+	if err := tr.Validate(); err != nil {
+		return nil, err
+	}
+
+	type fileRows struct {
+		info *MeteringFileInfo
+		rows []map[string]interface{}
+	}
+	var files []fileRows
+	for ts := tr.Start; ts <= tr.End; ts += 60 {
+		err := r.ScanTimestamp(ctx, ts, nil, func(info *MeteringFileInfo, data *common.MeteringData) error {
+			var rows []map[string]interface{}
+			for _, entry := range data.Data {
+				if id, _ := entry[common.LogicalClusterIDField].(string); id == logicalClusterID {
+					rows = append(rows, entry)
+				}
+			}
+			if len(rows) > 0 {
+				files = append(files, fileRows{info: info, rows: rows})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		a, b := files[i].info, files[j].info
+		if a.Timestamp != b.Timestamp {
+			return a.Timestamp < b.Timestamp
+		}
+		if a.Category != b.Category {
+			return a.Category < b.Category
+		}
+		if a.SharedPoolID != b.SharedPoolID {
+			return a.SharedPoolID < b.SharedPoolID
+		}
+		if a.SelfID != b.SelfID {
+			return a.SelfID < b.SelfID
+		}
+		return a.Part < b.Part
+	})
+	var records []*Record
+	for _, file := range files {
+		for _, row := range file.rows {
+			records = append(records, &Record{File: file.info, Data: row})
+		}
+	}
+
+	r.logger.Debug("Read logical cluster rows",
+		zap.String("logical_cluster_id", logicalClusterID),
+		zap.Int("files", len(files)),
+		zap.Int("rows", len(records)),
+	)
+	return records, nil
+}
@@ -1,22 +1,23 @@
 package meteringreader
 
 import (
-	"bytes"
-	"compress/gzip"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"regexp"
 	"sort"
-	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/pingcap/metering_sdk/common"
 	"github.com/pingcap/metering_sdk/config"
+	"github.com/pingcap/metering_sdk/layout"
+	"github.com/pingcap/metering_sdk/logging"
 	"github.com/pingcap/metering_sdk/reader"
 	"github.com/pingcap/metering_sdk/storage"
+	"github.com/pingcap/metering_sdk/tracing"
 	"go.uber.org/zap"
 )
 
@@ -28,6 +29,7 @@ type MeteringFileInfo struct {
 	SharedPoolID string `json:"shared_pool_id"` // Shared pool cluster ID
 	SelfID       string `json:"self_id"`        // Component ID
 	Part         int    `json:"part"`           // Part number
+	Size         int64  `json:"size,omitempty"` // Stored size in bytes, only set by StatFile
 }
 
 // TimestampFiles file information organized by timestamp
@@ -38,10 +40,16 @@ type TimestampFiles struct {
 
 // MeteringReader metering data reader
 type MeteringReader struct {
-	provider storage.ObjectStorageProvider
-	config   *config.Config
-	logger   *zap.Logger
-	mu       sync.RWMutex // Protect concurrent reads
+	provider  storage.ObjectStorageProvider
+	stater    storage.ObjectStater      // nil if the provider can't stat objects
+	versioned storage.VersionedProvider // nil if the provider doesn't support object versions
+	pager     storage.PageLister        // nil if the provider doesn't support paged listing
+	prefixes  storage.PrefixLister      // nil if the provider doesn't support delimiter-based listing
+	config    *config.Config
+	logger    *zap.Logger
+	calls     *storage.CallCounter // storage requests made by this reader
+	mu        sync.RWMutex         // Protect concurrent reads
+	closed    atomic.Bool          // reads fail with reader.ErrReaderClosed once set by Close
 }
 
 // NewMeteringReader creates a new metering data reader
@@ -50,174 +58,366 @@ func NewMeteringReader(provider storage.ObjectStorageProvider, cfg *config.Confi
 		cfg = config.DefaultConfig()
 	}
 
+	logger := cfg.ProviderLogger(provider)
+	calls := &storage.CallCounter{}
+	provider = cfg.WrapProvider(provider, calls)
+	// Asserted on the wrapped provider, so every operation is counted, instrumented and traced
+	stater, _ := provider.(storage.ObjectStater)
+	versioned, _ := provider.(storage.VersionedProvider)
+	pager, _ := provider.(storage.PageLister)
+	prefixes, _ := provider.(storage.PrefixLister)
 	return &MeteringReader{
-		provider: provider,
-		config:   cfg,
-		logger:   cfg.GetLogger(),
+		provider:  provider,
+		stater:    stater,
+		versioned: versioned,
+		pager:     pager,
+		prefixes:  prefixes,
+		config:    cfg,
+		logger:    logger,
+		calls:     calls,
 	}
 }
 
+// CallStats returns the storage requests this reader made, e.g. to estimate the object store API costs
+// of metering or tune the page size of listings
+func (r *MeteringReader) CallStats() storage.CallStats {
+	return r.calls.Stats()
+}
+
+// ListOption narrows the files listed by ListFilesByTimestamp
+type ListOption func(filter *layout.Fields)
+
+// WithCategoryFilter only lists files of category
+func WithCategoryFilter(category string) ListOption {
+	return func(filter *layout.Fields) { filter.Category = category }
+}
+
+// WithSharedPoolFilter only lists files of the shared pool sharedPoolID
+func WithSharedPoolFilter(sharedPoolID string) ListOption {
+	return func(filter *layout.Fields) { filter.SharedPoolID = sharedPoolID }
+}
+
+// WithSelfIDFilter only lists files written by the component selfID
+func WithSelfIDFilter(selfID string) ListOption {
+	return func(filter *layout.Fields) { filter.SelfID = selfID }
+}
+
 // ListFilesByTimestamp lists all metering file information by timestamp
-// Path format: /metering/ru/{timestamp}/{category}/{shared_pool_id}/{self_id}-{part}.json.gz (new format)
-//
-//	/metering/ru/{timestamp}/{category}/{self_id}-{part}.json.gz (old format for backward compatibility)
-func (r *MeteringReader) ListFilesByTimestamp(ctx context.Context, timestamp int64) (*TimestampFiles, error) {
+// Path format: the configured path layout, by default
+// /metering/ru/{timestamp}/{category}/{shared_pool_id}/{self_id}-{part}.json.gz, with the suffix of
+// registered codecs in place of .json
+// Filters are applied server-side by listing the most specific prefix the layout allows, e.g.
+// metering/ru/{timestamp}/{category}/ with WithCategoryFilter, and client-side for the rest.
+func (r *MeteringReader) ListFilesByTimestamp(ctx context.Context, timestamp int64, opts ...ListOption) (*TimestampFiles, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
+	filter := layout.Fields{Timestamp: timestamp}
+	for _, opt := range opts {
+		opt(&filter)
+	}
 	r.logger.Debug("Listing metering files by timestamp",
 		zap.Int64("timestamp", timestamp),
+		zap.String("category", filter.Category),
+		zap.String("shared_pool_id", filter.SharedPoolID),
+		zap.String("self_id", filter.SelfID),
 	)
 
-	// Build timestamp prefix
-	prefix := fmt.Sprintf("metering/ru/%d/", timestamp)
+	// Build the most specific prefix of the filter
+	pathLayout := r.config.GetPathLayout()
+	prefix := pathLayout.Prefix(filter)
 
-	// Get all files
-	files, err := r.provider.List(ctx, prefix)
+	finalized, err := r.finalizedFiles(ctx, timestamp)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list files with prefix %s: %w", prefix, err)
+		return nil, err
 	}
 
-	// Parse file paths and organize data
+	// Parse file paths and organize data, page by page so the full listing is never held in memory
 	result := &TimestampFiles{
 		Timestamp: timestamp,
 		Files:     make(map[string][]string),
 	}
-
-	// Only support new path format with SharedPoolID
-	// Path format: metering/ru/{timestamp}/{category}/{shared_pool_id}/{self_id}-{part}.json.gz
-	pathRegex := regexp.MustCompile(`^metering/ru/(\d+)/([^/]+)/([^/]+)/([^-]+)-(\d+)\.json\.gz$`)
-
-	for _, filePath := range files {
-		matches := pathRegex.FindStringSubmatch(filePath)
-		if len(matches) == 6 {
-			fileTimestamp, _ := strconv.ParseInt(matches[1], 10, 64)
-			if fileTimestamp != timestamp {
-				continue // Skip non-matching timestamps
-			}
-
-			category := matches[2]
-			selfID := matches[4]
-			//TODO improve selfID validation
-			if strings.Contains(selfID, "-") {
-				r.logger.Warn("Invalid self_id contains dash, skipping",
-					zap.String("path", filePath),
-					zap.String("self_id", selfID),
+	parsed := make(map[string]*layout.Fields)
+	total := 0
+	err = r.listPages(ctx, prefix, func(page []string) error {
+		total += len(page)
+		for _, filePath := range page {
+			fields, err := pathLayout.Parse(filePath)
+			if err != nil {
+				// Log warning for unrecognized path format
+				r.logger.Warn("Unrecognized file path format, skipping",
+					logging.Path(filePath),
 				)
 				continue
 			}
+			if fields.Timestamp != timestamp || !matchesFilter(fields, &filter) || !isFinalized(finalized, filePath) {
+				continue // Skip non-matching files and unfinalized files
+			}
 
 			// Add file path
-			result.Files[category] = append(
-				result.Files[category],
+			parsed[filePath] = fields
+			result.Files[fields.Category] = append(
+				result.Files[fields.Category],
 				filePath,
 			)
-			continue
 		}
-
-		// Log warning for unrecognized path format
-		r.logger.Warn("Unrecognized file path format, skipping",
-			zap.String("path", filePath),
-		)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files with prefix %s: %w", prefix, err)
 	}
 
 	// Sort file paths to ensure consistent results
 	for category := range result.Files {
-		sort.Strings(result.Files[category])
+		sortFilePaths(result.Files[category], parsed)
 	}
 
 	r.logger.Info("Successfully listed metering files by timestamp",
 		zap.Int64("timestamp", timestamp),
 		zap.Int("categories_count", len(result.Files)),
-		zap.Int("total_files", len(files)),
+		zap.Int("total_files", total),
 	)
 
 	return result, nil
 }
 
-// GetFileInfo parses file path and returns file information
-func (r *MeteringReader) GetFileInfo(filePath string) (*MeteringFileInfo, error) {
-	// Only support new path format with SharedPoolID
-	// Path format: metering/ru/{timestamp}/{category}/{shared_pool_id}/{self_id}-{part}.json.gz
-	pathRegex := regexp.MustCompile(`^metering/ru/(\d+)/([^/]+)/([^/]+)/([^-]+)-(\d+)\.json\.gz$`)
+// matchesFilter reports whether fields match the non-empty fields of filter
+func matchesFilter(fields, filter *layout.Fields) bool {
+	return (filter.Category == "" || fields.Category == filter.Category) &&
+		(filter.SharedPoolID == "" || fields.SharedPoolID == filter.SharedPoolID) &&
+		(filter.SelfID == "" || fields.SelfID == filter.SelfID)
+}
 
-	matches := pathRegex.FindStringSubmatch(filePath)
-	if len(matches) == 6 {
-		timestamp, err := strconv.ParseInt(matches[1], 10, 64)
-		if err != nil {
-			return nil, fmt.Errorf("invalid timestamp in path %s: %w", filePath, err)
+// sortFilePaths sorts metering file paths by shared pool, self ID and numeric part, so that
+// zero-padded and unpadded part numbers both list in logical order
+func sortFilePaths(paths []string, fields map[string]*layout.Fields) {
+	sort.SliceStable(paths, func(i, j int) bool {
+		a, b := fields[paths[i]], fields[paths[j]]
+		if a.SharedPoolID != b.SharedPoolID {
+			return a.SharedPoolID < b.SharedPoolID
 		}
-
-		category := matches[2]
-		sharedPoolID := matches[3]
-		selfID := matches[4]
-		part, err := strconv.Atoi(matches[5])
-		if err != nil {
-			return nil, fmt.Errorf("invalid part number in path %s: %w", filePath, err)
+		if a.SelfID != b.SelfID {
+			return a.SelfID < b.SelfID
 		}
-
-		if strings.Contains(selfID, "-") {
-			return nil, fmt.Errorf("self_id cannot contain dash character: %s", selfID)
+		if a.Part != b.Part {
+			return a.Part < b.Part
 		}
+		return paths[i] < paths[j]
+	})
+}
+
+// checkOpen fails with reader.ErrReaderClosed once Close has been called
+func (r *MeteringReader) checkOpen() error {
+	if r.closed.Load() {
+		return reader.ErrReaderClosed
+	}
+	return nil
+}
+
+// GetFileInfo parses file path with the configured path layout and returns file information
+func (r *MeteringReader) GetFileInfo(filePath string) (*MeteringFileInfo, error) {
+	fields, err := r.config.GetPathLayout().Parse(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid file path format: %w", err)
+	}
+	return &MeteringFileInfo{
+		Path:         filePath,
+		Timestamp:    fields.Timestamp,
+		Category:     fields.Category,
+		SharedPoolID: fields.SharedPoolID,
+		SelfID:       fields.SelfID,
+		Part:         fields.Part,
+	}, nil
+}
+
+// StatFile returns the information of the metering file at filePath including its stored size, without
+// downloading it if the provider can stat objects. It fails with reader.ErrFileNotFound if the file
+// doesn't exist.
+func (r *MeteringReader) StatFile(ctx context.Context, filePath string) (*MeteringFileInfo, error) {
+	if err := r.checkOpen(); err != nil {
+		return nil, err
+	}
+	info, err := r.GetFileInfo(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var attrs *storage.ObjectAttributes
+	if r.stater != nil {
+		attrs, err = r.stater.Stat(ctx, filePath)
+	} else {
+		attrs, err = storage.Stat(ctx, r.provider, filePath)
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", reader.ErrFileNotFound, filePath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file %s: %w", filePath, err)
+	}
+	info.Size = attrs.Size
+	return info, nil
+}
 
-		return &MeteringFileInfo{
-			Path:         filePath,
-			Timestamp:    timestamp,
-			Category:     category,
-			SharedPoolID: sharedPoolID,
-			SelfID:       selfID,
-			Part:         part,
-		}, nil
+// ReadFile reads and parses metering data file at the specified path. With predicates, only the Data
+// entries matching every predicate are kept, the others are skipped without being decoded
+func (r *MeteringReader) ReadFile(ctx context.Context, filePath string, predicates ...Predicate) (*common.MeteringData, error) {
+	filter, err := newRowFilter(predicates)
+	if err != nil {
+		return nil, err
 	}
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, r.config.TracerProvider, "MeteringReader.ReadFile", tracing.AttributePath.String(filePath))
+	data, _, err := r.readFile(ctx, filePath, filter)
+	tracing.End(span, err)
+	r.config.Metrics.ObserveRead("metering", start, err)
+	return data, err
+}
 
-	return nil, fmt.Errorf("invalid file path format: %s", filePath)
+// ReadFileWithFormat is like ReadFile but also returns the format version the file was written with.
+// The data is normalized into the current format either way, e.g. the physical_cluster_id of legacy
+// files is returned as SharedPoolID.
+func (r *MeteringReader) ReadFileWithFormat(ctx context.Context, filePath string, predicates ...Predicate) (*common.MeteringData, FormatVersion, error) {
+	filter, err := newRowFilter(predicates)
+	if err != nil {
+		return nil, 0, err
+	}
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, r.config.TracerProvider, "MeteringReader.ReadFileWithFormat", tracing.AttributePath.String(filePath))
+	data, version, err := r.readFile(ctx, filePath, filter)
+	tracing.End(span, err)
+	r.config.Metrics.ObserveRead("metering", start, err)
+	return data, version, err
 }
 
-// ReadFile reads and parses metering data file at the specified path
-func (r *MeteringReader) ReadFile(ctx context.Context, filePath string) (*common.MeteringData, error) {
+// readFile downloads, decompresses and parses the metering data file at the specified path
+func (r *MeteringReader) readFile(ctx context.Context, filePath string, filter *rowFilter) (*common.MeteringData, FormatVersion, error) {
+	if err := r.checkOpen(); err != nil {
+		return nil, 0, err
+	}
+	start := time.Now()
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	r.logger.Debug("Reading metering data file",
-		zap.String("path", filePath),
+		logging.Path(filePath),
 	)
 
 	// Check if file exists
 	exists, err := r.provider.Exists(ctx, filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check if file exists: %w", err)
+		return nil, 0, fmt.Errorf("failed to check if file exists: %w", err)
 	}
 	if !exists {
-		return nil, fmt.Errorf("%w: %s", reader.ErrFileNotFound, filePath)
+		return nil, 0, fmt.Errorf("%w: %s", reader.ErrFileNotFound, filePath)
 	}
 
 	// Download file
 	readCloser, err := r.provider.Download(ctx, filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to download file: %w", err)
+		return nil, 0, fmt.Errorf("failed to download file: %w", err)
 	}
 	defer readCloser.Close()
 
-	// Decompress data
-	data, err := r.decompressData(readCloser)
+	meteringData, version, err := r.decodeFile(filePath, readCloser, filter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decompress data: %w", err)
-	}
-
-	// Parse JSON
-	var meteringData common.MeteringData
-	if err := json.Unmarshal(data, &meteringData); err != nil {
-		return nil, fmt.Errorf("%w: failed to unmarshal metering data: %v", reader.ErrInvalidFormat, err)
+		return nil, 0, err
 	}
 
 	r.logger.Info("Successfully read metering data file",
-		zap.String("path", filePath),
+		logging.Path(filePath),
 		zap.Int64("timestamp", meteringData.Timestamp),
 		zap.String("category", meteringData.Category),
 		zap.Int("logical_clusters_count", len(meteringData.Data)),
+		zap.Stringer("format_version", version),
+		logging.Since(start),
+	)
+
+	return meteringData, version, nil
+}
+
+// DownloadRaw opens the metering data file at filePath as stored, without decompressing or
+// decoding it, so tools can copy or checksum files cheaply. Use reader.Decompress to read the JSON.
+func (r *MeteringReader) DownloadRaw(ctx context.Context, filePath string) (io.ReadCloser, reader.ObjectInfo, error) {
+	if err := r.checkOpen(); err != nil {
+		return nil, reader.ObjectInfo{}, err
+	}
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, r.config.TracerProvider, "MeteringReader.DownloadRaw", tracing.AttributePath.String(filePath))
+	body, info, err := reader.DownloadRaw(ctx, r.provider, filePath)
+	tracing.End(span, err)
+	r.config.Metrics.ObserveRead("metering", start, err)
+	return body, info, err
+}
+
+// ListFileVersions lists all versions of the metering file at filePath, newest first.
+// The provider must implement storage.VersionedProvider and the bucket must have versioning enabled.
+func (r *MeteringReader) ListFileVersions(ctx context.Context, filePath string) ([]storage.ObjectVersion, error) {
+	if err := r.checkOpen(); err != nil {
+		return nil, err
+	}
+	if r.versioned == nil {
+		return nil, storage.ErrVersioningNotSupported
+	}
+	versions, err := r.versioned.ListVersions(ctx, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list file versions: %w", err)
+	}
+	return versions, nil
+}
+
+// ReadFileVersion reads the given version of the metering data file at filePath
+func (r *MeteringReader) ReadFileVersion(ctx context.Context, filePath string, versionID string) (*common.MeteringData, error) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, r.config.TracerProvider, "MeteringReader.ReadFileVersion",
+		tracing.AttributePath.String(filePath),
+		tracing.AttributeVersionID.String(versionID),
+	)
+	data, err := r.readFileVersion(ctx, filePath, versionID)
+	tracing.End(span, err)
+	r.config.Metrics.ObserveRead("metering", start, err)
+	return data, err
+}
+
+// readFileVersion downloads and parses the given version of the metering data file at filePath
+func (r *MeteringReader) readFileVersion(ctx context.Context, filePath string, versionID string) (*common.MeteringData, error) {
+	if err := r.checkOpen(); err != nil {
+		return nil, err
+	}
+	if r.versioned == nil {
+		return nil, storage.ErrVersioningNotSupported
+	}
+
+	r.logger.Debug("Reading metering data file version",
+		logging.Path(filePath),
+		zap.String("version_id", versionID),
 	)
 
-	return &meteringData, nil
+	readCloser, err := r.versioned.DownloadVersion(ctx, filePath, versionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file version %s: %w", versionID, err)
+	}
+	defer readCloser.Close()
+
+	meteringData, _, err := r.decodeFile(filePath, readCloser, nil)
+	return meteringData, err
+}
+
+// ReadFileAsOf reads the version of the metering data file at filePath that was current at time at,
+// e.g. to audit what was read when an invoice was generated even if the file was restated later.
+// The returned version identifies exactly which object version was read.
+func (r *MeteringReader) ReadFileAsOf(ctx context.Context, filePath string, at time.Time) (*common.MeteringData, *storage.ObjectVersion, error) {
+	versions, err := r.ListFileVersions(ctx, filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	version, ok := storage.VersionAt(versions, at)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s at %s", reader.ErrFileNotFound, filePath, at.Format(time.RFC3339))
+	}
+	data, err := r.ReadFileVersion(ctx, filePath, version.VersionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, version, nil
 }
 
 // Read implements MeteringReader interface, reads metering data at the specified path
@@ -225,8 +425,14 @@ func (r *MeteringReader) Read(ctx context.Context, path string) (interface{}, er
 	return r.ReadFile(ctx, path)
 }
 
-// GetCategories gets all categories under the specified timestamp
+// GetCategories gets all categories under the specified timestamp. With layouts where the category
+// directly follows the timestamp, such as the default one, it lists the category directories with a
+// single delimiter-based listing instead of listing every file, unless RequireFinalized is set.
 func (r *MeteringReader) GetCategories(ctx context.Context, timestamp int64) ([]string, error) {
+	if pathLayout := r.config.GetPathLayout(); pathLayout.CategoryFollowsTimestamp() && !r.config.RequireFinalized {
+		return r.listCategories(ctx, pathLayout.TimestampPrefix(timestamp))
+	}
+
 	timestampFiles, err := r.ListFilesByTimestamp(ctx, timestamp)
 	if err != nil {
 		return nil, err
@@ -241,9 +447,39 @@ func (r *MeteringReader) GetCategories(ctx context.Context, timestamp int64) ([]
 	return categories, nil
 }
 
+// listCategories lists the categories under prefix, the timestamp prefix of a layout whose category
+// directly follows the timestamp
+func (r *MeteringReader) listCategories(ctx context.Context, prefix string) ([]string, error) {
+	if err := r.checkOpen(); err != nil {
+		return nil, err
+	}
+	var (
+		dirs []string
+		err  error
+	)
+	if r.prefixes != nil {
+		dirs, err = r.prefixes.ListCommonPrefixes(ctx, prefix, "/")
+	} else {
+		dirs, err = storage.ListCommonPrefixes(ctx, r.provider, prefix, "/")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list categories with prefix %s: %w", prefix, err)
+	}
+
+	// The last segment of prefix is the literal before {category}, e.g. "category=" in Hive layouts
+	literal := prefix[strings.LastIndex(prefix, "/")+1:]
+	categories := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		dir = strings.TrimSuffix(dir, "/")
+		categories = append(categories, strings.TrimPrefix(dir[strings.LastIndex(dir, "/")+1:], literal))
+	}
+	sort.Strings(categories)
+	return categories, nil
+}
+
 // GetFilesByCategory gets all file paths under the specified timestamp and category
 func (r *MeteringReader) GetFilesByCategory(ctx context.Context, timestamp int64, category string) ([]string, error) {
-	timestampFiles, err := r.ListFilesByTimestamp(ctx, timestamp)
+	timestampFiles, err := r.ListFilesByTimestamp(ctx, timestamp, WithCategoryFilter(category))
 	if err != nil {
 		return nil, err
 	}
@@ -253,16 +489,37 @@ func (r *MeteringReader) GetFilesByCategory(ctx context.Context, timestamp int64
 		return []string{}, nil
 	}
 
+	// Already sorted by ListFilesByTimestamp, parts in numeric order
 	var allFiles []string
 	allFiles = append(allFiles, categoryFiles...)
-	sort.Strings(allFiles)
 
 	return allFiles, nil
 }
 
+// GetFilesBySharedPool gets all file paths under the specified timestamp written for the given
+// shared pool, across categories. Paths are ordered by category, then as in ListFilesByTimestamp.
+func (r *MeteringReader) GetFilesBySharedPool(ctx context.Context, timestamp int64, sharedPoolID string) ([]string, error) {
+	timestampFiles, err := r.ListFilesByTimestamp(ctx, timestamp, WithSharedPoolFilter(sharedPoolID))
+	if err != nil {
+		return nil, err
+	}
+
+	categories := make([]string, 0, len(timestampFiles.Files))
+	for category := range timestampFiles.Files {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	poolFiles := []string{}
+	for _, category := range categories {
+		poolFiles = append(poolFiles, timestampFiles.Files[category]...)
+	}
+	return poolFiles, nil
+}
+
 // GetFilesByCluster gets all file paths under the specified timestamp, category
 func (r *MeteringReader) GetFilesByCluster(ctx context.Context, timestamp int64, category string) ([]string, error) {
-	timestampFiles, err := r.ListFilesByTimestamp(ctx, timestamp)
+	timestampFiles, err := r.ListFilesByTimestamp(ctx, timestamp, WithCategoryFilter(category))
 	if err != nil {
 		return nil, err
 	}
@@ -279,17 +536,79 @@ func (r *MeteringReader) GetFilesByCluster(ctx context.Context, timestamp int64,
 	return result, nil
 }
 
+// ReadAllParts reads every part written by a component at timestamp and merges them into a single
+// MeteringData, with Data entries in part order. Parts must be numbered contiguously from 0,
+// a missing part is reported as reader.ErrFileNotFound.
+func (r *MeteringReader) ReadAllParts(ctx context.Context, timestamp int64, category, selfID string) (*common.MeteringData, error) {
+	timestampFiles, err := r.ListFilesByTimestamp(ctx, timestamp, WithCategoryFilter(category), WithSelfIDFilter(selfID))
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []*MeteringFileInfo
+	for _, filePath := range timestampFiles.Files[category] {
+		info, err := r.GetFileInfo(filePath)
+		if err != nil {
+			continue
+		}
+		parts = append(parts, info)
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("%w: no parts for %s/%s at %d", reader.ErrFileNotFound, category, selfID, timestamp)
+	}
+
+	paths := make([]string, len(parts))
+	for i, info := range parts {
+		if info.SharedPoolID != parts[0].SharedPoolID {
+			return nil, fmt.Errorf("component %s/%s wrote parts to multiple shared pools at %d: %s, %s",
+				category, selfID, timestamp, parts[0].SharedPoolID, info.SharedPoolID)
+		}
+		if info.Part != i {
+			return nil, fmt.Errorf("%w: part %d of %s/%s at %d", reader.ErrFileNotFound, i, category, selfID, timestamp)
+		}
+		paths[i] = info.Path
+	}
+
+	pages, err := r.ReadMultipleFiles(ctx, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := &common.MeteringData{
+		Timestamp:    timestamp,
+		Category:     category,
+		SelfID:       selfID,
+		SharedPoolID: parts[0].SharedPoolID,
+	}
+	for _, page := range pages {
+		merged.Data = append(merged.Data, page.Data...)
+	}
+
+	r.logger.Debug("Merged metering data parts",
+		zap.Int64("timestamp", timestamp),
+		zap.String("category", category),
+		zap.String("self_id", selfID),
+		zap.Int("parts", len(parts)),
+		zap.Int("logical_clusters_count", len(merged.Data)),
+	)
+
+	return merged, nil
+}
+
 // ReadMultipleFiles reads multiple files in batch
 func (r *MeteringReader) ReadMultipleFiles(ctx context.Context, filePaths []string) ([]*common.MeteringData, error) {
 	results := make([]*common.MeteringData, len(filePaths))
 	errors := make([]error, len(filePaths))
 
-	// Use goroutines to read files concurrently
+	// Use goroutines to read files concurrently, bounded so huge batches don't exhaust memory
 	var wg sync.WaitGroup
+	slots := make(chan struct{}, DefaultReadConcurrency)
 	for i, filePath := range filePaths {
 		wg.Add(1)
+		slots <- struct{}{}
 		go func(index int, path string) {
 			defer wg.Done()
+			defer func() { <-slots }()
 			data, err := r.ReadFile(ctx, path)
 			results[index] = data
 			errors[index] = err
@@ -307,7 +626,7 @@ func (r *MeteringReader) ReadMultipleFiles(ctx context.Context, filePaths []stri
 				firstError = fmt.Errorf("failed to read file %s: %w", filePaths[i], err)
 			}
 			r.logger.Error("Failed to read file",
-				zap.String("path", filePaths[i]),
+				logging.Path(filePaths[i]),
 				zap.Error(err),
 			)
 		} else {
@@ -333,6 +652,9 @@ func (r *MeteringReader) ReadMultipleFiles(ctx context.Context, filePaths []stri
 
 // List implements MeteringReader interface, lists all data paths under the specified prefix
 func (r *MeteringReader) List(ctx context.Context, prefix string) ([]string, error) {
+	if err := r.checkOpen(); err != nil {
+		return nil, err
+	}
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
@@ -353,27 +675,13 @@ func (r *MeteringReader) List(ctx context.Context, prefix string) ([]string, err
 	return files, nil
 }
 
-// Close implements MeteringReader interface, closes the reader
+// Close implements MeteringReader interface, closes the reader. Later reads fail with
+// reader.ErrReaderClosed, in-flight reads are not waited for. Closing twice is a no-op.
 func (r *MeteringReader) Close() error {
+	if !r.closed.CompareAndSwap(false, true) {
+		return nil
+	}
 	r.logger.Debug("Closing metering reader")
 	// Metering data reader has no resources to clean up
 	return nil
 }
-
-// decompressData decompresses gzip data
-func (r *MeteringReader) decompressData(reader io.Reader) ([]byte, error) {
-	gzipReader, err := gzip.NewReader(reader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
-	}
-	defer gzipReader.Close()
-
-	var buffer bytes.Buffer
-	// Limit decompression to prevent DoS attacks (max 100MB)
-	limitedReader := io.LimitReader(gzipReader, 100*1024*1024)
-	if _, err := io.Copy(&buffer, limitedReader); err != nil {
-		return nil, fmt.Errorf("failed to decompress data: %w", err)
-	}
-
-	return buffer.Bytes(), nil
-}
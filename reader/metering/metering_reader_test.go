@@ -5,14 +5,20 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/pingcap/metering_sdk/common"
 	"github.com/pingcap/metering_sdk/config"
+	"github.com/pingcap/metering_sdk/layout"
+	"github.com/pingcap/metering_sdk/reader"
+	"github.com/pingcap/metering_sdk/storage"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
@@ -165,9 +171,20 @@ func TestMeteringReader_Close(t *testing.T) {
 		Logger: zap.NewNop(),
 	}
 	meteringReader := NewMeteringReader(provider, cfg)
+	path := putTestMeteringFile(t, provider, 1755687660, "tikv", "server1", 0, nil)
 
 	err := meteringReader.Close()
 	assert.NoError(t, err, "Unexpected error")
+	assert.NoError(t, meteringReader.Close(), "Closing twice should be a no-op")
+
+	ctx := context.Background()
+	_, err = meteringReader.ReadFile(ctx, path)
+	assert.ErrorIs(t, err, reader.ErrReaderClosed)
+	_, err = meteringReader.ListFilesByTimestamp(ctx, 1755687660)
+	assert.ErrorIs(t, err, reader.ErrReaderClosed)
+	for _, err := range meteringReader.ReadFileStream(ctx, path) {
+		assert.ErrorIs(t, err, reader.ErrReaderClosed)
+	}
 }
 
 // TestMeteringReader_GetFileInfo tests file information parsing
@@ -304,6 +321,76 @@ func TestMeteringReader_ListFilesByTimestamp(t *testing.T) {
 	}
 }
 
+// prefixRecordingProvider records the prefixes listed
+type prefixRecordingProvider struct {
+	*mockObjectStorageProvider
+	prefixes []string
+}
+
+func (p *prefixRecordingProvider) List(ctx context.Context, prefix string) ([]string, error) {
+	p.prefixes = append(p.prefixes, prefix)
+	return p.mockObjectStorageProvider.List(ctx, prefix)
+}
+
+// TestMeteringReader_ListFilesByTimestampFilters tests that filters narrow the listed prefix
+func TestMeteringReader_ListFilesByTimestampFilters(t *testing.T) {
+	provider := &prefixRecordingProvider{mockObjectStorageProvider: newMockObjectStorageProvider()}
+	for _, filePath := range []string{
+		"metering/ru/1755687660/tikv/pool1/tikv001-0.json.gz",
+		"metering/ru/1755687660/tikv/pool1/tikv0010-0.json.gz",
+		"metering/ru/1755687660/tikv/pool2/tikv001-0.json.gz",
+		"metering/ru/1755687660/tidb/pool1/tidb001-0.json.gz",
+	} {
+		provider.files[filePath] = []byte("mock data")
+	}
+	meteringReader := NewMeteringReader(provider, &config.Config{Logger: zap.NewNop()})
+	ctx := context.Background()
+
+	result, err := meteringReader.ListFilesByTimestamp(ctx, 1755687660, WithCategoryFilter("tikv"), WithSharedPoolFilter("pool1"), WithSelfIDFilter("tikv001"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"tikv": {"metering/ru/1755687660/tikv/pool1/tikv001-0.json.gz"}}, result.Files)
+	assert.Equal(t, []string{"metering/ru/1755687660/tikv/pool1/tikv001-"}, provider.prefixes)
+
+	// Filters the layout can't turn into a prefix are applied client-side
+	provider.prefixes = nil
+	result, err = meteringReader.ListFilesByTimestamp(ctx, 1755687660, WithSelfIDFilter("tikv001"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"tikv": {
+		"metering/ru/1755687660/tikv/pool1/tikv001-0.json.gz",
+		"metering/ru/1755687660/tikv/pool2/tikv001-0.json.gz",
+	}}, result.Files)
+	assert.Equal(t, []string{"metering/ru/1755687660/"}, provider.prefixes)
+}
+
+// TestMeteringReader_ListFilesByTimestampPartOrder tests that parts list in numeric order, padded or not
+func TestMeteringReader_ListFilesByTimestampPartOrder(t *testing.T) {
+	provider := newMockObjectStorageProvider()
+	for _, filePath := range []string{
+		"metering/ru/1755687660/tikv/pool1/tikv001-10.json.gz",
+		"metering/ru/1755687660/tikv/pool1/tikv001-2.json.gz",
+		"metering/ru/1755687660/tikv/pool1/tikv001-0.json.gz",
+		"metering/ru/1755687660/tikv/pool1/tikv002-0003.json.gz",
+		"metering/ru/1755687660/tikv/pool1/tikv002-0001.json.gz",
+	} {
+		provider.files[filePath] = []byte("mock data")
+	}
+
+	meteringReader := NewMeteringReader(provider, &config.Config{Logger: zap.NewNop()})
+	result, err := meteringReader.ListFilesByTimestamp(context.Background(), 1755687660)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"metering/ru/1755687660/tikv/pool1/tikv001-0.json.gz",
+		"metering/ru/1755687660/tikv/pool1/tikv001-2.json.gz",
+		"metering/ru/1755687660/tikv/pool1/tikv001-10.json.gz",
+		"metering/ru/1755687660/tikv/pool1/tikv002-0001.json.gz",
+		"metering/ru/1755687660/tikv/pool1/tikv002-0003.json.gz",
+	}, result.Files["tikv"])
+
+	info, err := meteringReader.GetFileInfo("metering/ru/1755687660/tikv/pool1/tikv002-0003.json.gz")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, info.Part)
+}
+
 // TestMeteringReader_GetCategories tests getting categories
 func TestMeteringReader_GetCategories(t *testing.T) {
 	provider := newMockObjectStorageProvider()
@@ -337,6 +424,35 @@ func TestMeteringReader_GetCategories(t *testing.T) {
 	}
 }
 
+func TestMeteringReader_GetCategoriesDelimited(t *testing.T) {
+	provider := &prefixListingProvider{mockObjectStorageProvider: newMockObjectStorageProvider()}
+	hive := config.DefaultConfig().WithLogger(zap.NewNop()).WithPathLayout(layout.MustNew(layout.HiveTemplate))
+	for _, filePath := range []string{
+		"metering/ru/year=2025/month=08/day=22/hour=08/minute=13/category=tidb/shared_pool_id=pool1/server1-0.json.gz",
+		"metering/ru/year=2025/month=08/day=22/hour=08/minute=13/category=tidb/shared_pool_id=pool2/server2-0.json.gz",
+		"metering/ru/year=2025/month=08/day=22/hour=08/minute=13/category=tikv/shared_pool_id=pool1/tikv1-0.json.gz",
+		"metering/ru/year=2025/month=08/day=22/hour=08/minute=14/category=pd/shared_pool_id=pool1/pd1-0.json.gz",
+	} {
+		provider.files[filePath] = []byte("mock data")
+	}
+
+	categories, err := NewMeteringReader(provider, hive).GetCategories(context.Background(), 1755850380)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tidb", "tikv"}, categories)
+	assert.Equal(t, 1, provider.calls, "categories should be listed with the delimiter")
+}
+
+// prefixListingProvider adds delimiter-based listing to the mock provider, counting calls
+type prefixListingProvider struct {
+	*mockObjectStorageProvider
+	calls int
+}
+
+func (p *prefixListingProvider) ListCommonPrefixes(ctx context.Context, prefix, delimiter string) ([]string, error) {
+	p.calls++
+	return storage.ListCommonPrefixes(ctx, p.mockObjectStorageProvider, prefix, delimiter)
+}
+
 // TestMeteringReader_GetFilesByCategory tests getting files by category
 func TestMeteringReader_GetFilesByCategory(t *testing.T) {
 	provider := newMockObjectStorageProvider()
@@ -365,3 +481,390 @@ func TestMeteringReader_GetFilesByCategory(t *testing.T) {
 	expectedFileCount := 2
 	assert.Equal(t, expectedFileCount, len(files), "Expected %d files but got %d", expectedFileCount, len(files))
 }
+
+func TestMeteringReader_GetFilesBySharedPool(t *testing.T) {
+	provider := newMockObjectStorageProvider()
+	meteringReader := NewMeteringReader(provider, &config.Config{Logger: zap.NewNop()})
+
+	testFiles := []string{
+		"metering/ru/1755687660/tidbserver/pool-cluster-001/server001-0.json.gz",
+		"metering/ru/1755687660/tidbserver/pool-cluster-002/server002-0.json.gz",
+		"metering/ru/1755687660/tikv/pool-cluster-001/tikv001-10.json.gz",
+		"metering/ru/1755687660/tikv/pool-cluster-001/tikv001-2.json.gz",
+		"metering/ru/1755687720/tikv/pool-cluster-001/tikv001-0.json.gz",
+	}
+	for _, filePath := range testFiles {
+		provider.files[filePath] = []byte("mock data")
+	}
+
+	ctx := context.Background()
+	files, err := meteringReader.GetFilesBySharedPool(ctx, 1755687660, "pool-cluster-001")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"metering/ru/1755687660/tidbserver/pool-cluster-001/server001-0.json.gz",
+		"metering/ru/1755687660/tikv/pool-cluster-001/tikv001-2.json.gz",
+		"metering/ru/1755687660/tikv/pool-cluster-001/tikv001-10.json.gz",
+	}, files)
+
+	files, err = meteringReader.GetFilesBySharedPool(ctx, 1755687660, "pool-cluster-003")
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+// versionedMockProvider keeps every uploaded version of a file
+type versionedMockProvider struct {
+	*mockObjectStorageProvider
+	versions map[string][]storage.ObjectVersion // newest first
+	contents map[string][]byte                  // version ID -> content
+}
+
+func (m *versionedMockProvider) uploadVersion(path string, content []byte, at time.Time) string {
+	versionID := fmt.Sprintf("v%d", len(m.contents)+1)
+	for i := range m.versions[path] {
+		m.versions[path][i].IsLatest = false
+	}
+	m.versions[path] = append([]storage.ObjectVersion{{Path: path, VersionID: versionID, LastModified: at, Size: int64(len(content)), IsLatest: true}}, m.versions[path]...)
+	m.contents[versionID] = content
+	m.files[path] = content
+	return versionID
+}
+
+func (m *versionedMockProvider) DownloadVersion(ctx context.Context, path string, versionID string) (io.ReadCloser, error) {
+	content, ok := m.contents[versionID]
+	if !ok {
+		return nil, fmt.Errorf("version not found: %s", versionID)
+	}
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+func (m *versionedMockProvider) ListVersions(ctx context.Context, path string) ([]storage.ObjectVersion, error) {
+	return m.versions[path], nil
+}
+
+func TestMeteringReader_ReadFileVersion(t *testing.T) {
+	provider := &versionedMockProvider{
+		mockObjectStorageProvider: newMockObjectStorageProvider(),
+		versions:                  make(map[string][]storage.ObjectVersion),
+		contents:                  make(map[string][]byte),
+	}
+	path := "metering/ru/1640995200/storage/pool1/tikv001-0.json.gz"
+	invoiceTime := time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	original, err := createCompressedTestData(&common.MeteringData{Timestamp: 1640995200, Category: "storage", SelfID: "tikv001"})
+	assert.NoError(t, err)
+	restated, err := createCompressedTestData(&common.MeteringData{Timestamp: 1640995200, Category: "storage", SelfID: "tikv002"})
+	assert.NoError(t, err)
+	originalID := provider.uploadVersion(path, original, invoiceTime.Add(-time.Hour))
+	provider.uploadVersion(path, restated, invoiceTime.Add(time.Hour))
+
+	meteringReader := NewMeteringReader(provider, config.DefaultConfig())
+	ctx := context.Background()
+
+	versions, err := meteringReader.ListFileVersions(ctx, path)
+	assert.NoError(t, err)
+	assert.Len(t, versions, 2)
+
+	data, err := meteringReader.ReadFileVersion(ctx, path, originalID)
+	assert.NoError(t, err)
+	assert.Equal(t, "tikv001", data.SelfID)
+
+	data, version, err := meteringReader.ReadFileAsOf(ctx, path, invoiceTime)
+	assert.NoError(t, err)
+	assert.Equal(t, originalID, version.VersionID)
+	assert.Equal(t, "tikv001", data.SelfID)
+
+	latest, err := meteringReader.ReadFile(ctx, path)
+	assert.NoError(t, err)
+	assert.Equal(t, "tikv002", latest.SelfID)
+
+	_, _, err = meteringReader.ReadFileAsOf(ctx, path, invoiceTime.Add(-2*time.Hour))
+	assert.ErrorIs(t, err, reader.ErrFileNotFound)
+
+	// Providers without version support are rejected
+	_, err = NewMeteringReader(newMockObjectStorageProvider(), config.DefaultConfig()).ReadFileVersion(ctx, path, originalID)
+	assert.ErrorIs(t, err, storage.ErrVersioningNotSupported)
+}
+
+func TestMeteringReader_ReadAllParts(t *testing.T) {
+	provider := newMockObjectStorageProvider()
+	for part := 0; part < 12; part++ {
+		putTestMeteringFile(t, provider, 1755687660, "tikv", "server1", part, []map[string]interface{}{
+			{"logical_cluster_id": fmt.Sprintf("lc%d", part)},
+		})
+	}
+	putTestMeteringFile(t, provider, 1755687660, "tikv", "server2", 0, []map[string]interface{}{{"logical_cluster_id": "other"}})
+	putTestMeteringFile(t, provider, 1755687660, "tikv", "server3", 1, nil) // part 0 missing
+
+	meteringReader := NewMeteringReader(provider, &config.Config{Logger: zap.NewNop()})
+	ctx := context.Background()
+
+	merged, err := meteringReader.ReadAllParts(ctx, 1755687660, "tikv", "server1")
+	assert.NoError(t, err)
+	assert.Equal(t, "server1", merged.SelfID)
+	assert.Equal(t, "pool1", merged.SharedPoolID)
+	assert.Len(t, merged.Data, 12)
+	for i, entry := range merged.Data {
+		assert.Equal(t, fmt.Sprintf("lc%d", i), entry["logical_cluster_id"])
+	}
+
+	_, err = meteringReader.ReadAllParts(ctx, 1755687660, "tikv", "server3")
+	assert.ErrorIs(t, err, reader.ErrFileNotFound)
+
+	_, err = meteringReader.ReadAllParts(ctx, 1755687660, "tikv", "server4")
+	assert.ErrorIs(t, err, reader.ErrFileNotFound)
+}
+
+func TestMeteringReader_ReadTimestampAll(t *testing.T) {
+	provider := newMockObjectStorageProvider()
+	for part := 0; part < 3; part++ {
+		putTestMeteringFile(t, provider, 1755687660, "tikv", "server1", part, []map[string]interface{}{
+			{"logical_cluster_id": fmt.Sprintf("lc%d", part)},
+		})
+	}
+	putTestMeteringFile(t, provider, 1755687660, "tikv", "server2", 0, []map[string]interface{}{{"logical_cluster_id": "other"}})
+	putTestMeteringFile(t, provider, 1755687660, "tidb", "server1", 0, []map[string]interface{}{{"logical_cluster_id": "tidb"}})
+	putTestMeteringFile(t, provider, 1755687720, "tikv", "server1", 0, nil)
+
+	meteringReader := NewMeteringReader(provider, &config.Config{Logger: zap.NewNop()})
+	ctx := context.Background()
+
+	all, err := meteringReader.ReadTimestampAll(ctx, 1755687660, &ReadOptions{Concurrency: 2})
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	require.Len(t, all["tikv"], 2)
+	assert.Equal(t, "server1", all["tikv"][0].SelfID)
+	assert.Equal(t, "pool1", all["tikv"][0].SharedPoolID)
+	require.Len(t, all["tikv"][0].Data, 3)
+	for i, entry := range all["tikv"][0].Data {
+		assert.Equal(t, fmt.Sprintf("lc%d", i), entry["logical_cluster_id"])
+	}
+	assert.Equal(t, "server2", all["tikv"][1].SelfID)
+	require.Len(t, all["tidb"], 1)
+	assert.Equal(t, "tidb", all["tidb"][0].Data[0]["logical_cluster_id"])
+
+	filtered, err := meteringReader.ReadTimestampAll(ctx, 1755687660, &ReadOptions{Categories: []string{"tidb"}})
+	require.NoError(t, err)
+	assert.Len(t, filtered, 1)
+	assert.Contains(t, filtered, "tidb")
+
+	putTestMeteringFile(t, provider, 1755687660, "tikv", "server3", 1, nil) // part 0 missing
+	_, err = meteringReader.ReadTimestampAll(ctx, 1755687660, nil)
+	assert.ErrorIs(t, err, reader.ErrFileNotFound)
+}
+
+func TestMeteringReader_ScanTimestamp(t *testing.T) {
+	provider := newMockObjectStorageProvider()
+	for i := 0; i < 20; i++ {
+		putTestMeteringFile(t, provider, 1755687660, "tikv", fmt.Sprintf("server%d", i), 0, []map[string]interface{}{{"logical_cluster_id": "lc"}})
+	}
+	putTestMeteringFile(t, provider, 1755687660, "tidb", "server1", 0, nil)
+
+	meteringReader := NewMeteringReader(provider, &config.Config{Logger: zap.NewNop()})
+	ctx := context.Background()
+
+	var progress []ScanProgress
+	seen := make(map[string]bool)
+	err := meteringReader.ScanTimestamp(ctx, 1755687660, &ReadOptions{
+		Categories:  []string{"tikv"},
+		Concurrency: 3,
+		Progress:    func(p ScanProgress) { progress = append(progress, p) },
+	}, func(info *MeteringFileInfo, data *common.MeteringData) error {
+		assert.Equal(t, "tikv", info.Category)
+		assert.Len(t, data.Data, 1)
+		seen[info.Path] = true
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Len(t, seen, 20)
+	require.Len(t, progress, 20)
+	assert.Equal(t, ScanProgress{Listed: 20, Processed: 20}, progress[19])
+
+	// An error returned by the callback stops the scan
+	stop := errors.New("stop")
+	calls := 0
+	err = meteringReader.ScanTimestamp(ctx, 1755687660, nil, func(*MeteringFileInfo, *common.MeteringData) error {
+		calls++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, calls)
+}
+
+func TestMeteringReader_ScanTimestampCelebrityMinute(t *testing.T) {
+	if testing.Short() {
+		t.Skip("stress test")
+	}
+
+	// A minute with 100k files, as written when many components catch up after an incident
+	const files = 100000
+	compressed, err := createCompressedTestData(common.MeteringData{
+		Timestamp: 1755687660,
+		Data:      []map[string]interface{}{{"logical_cluster_id": "lc"}},
+	})
+	require.NoError(t, err)
+	provider := storage.NewMemoryProvider()
+	ctx := context.Background()
+	for i := 0; i < files; i++ {
+		path := fmt.Sprintf("metering/ru/1755687660/tikv/pool%d/server%d-0.json.gz", i%100, i)
+		require.NoError(t, provider.Upload(ctx, path, bytes.NewReader(compressed)))
+	}
+
+	meteringReader := NewMeteringReader(provider, &config.Config{Logger: zap.NewNop()})
+	var last ScanProgress
+	entries := 0
+	err = meteringReader.ScanTimestamp(ctx, 1755687660, &ReadOptions{
+		Concurrency: 32,
+		Progress:    func(p ScanProgress) { last = p },
+	}, func(_ *MeteringFileInfo, data *common.MeteringData) error {
+		entries += len(data.Data)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, files, entries)
+	assert.Equal(t, ScanProgress{Listed: files, Processed: files}, last)
+}
+
+func TestMeteringReader_DownloadRaw(t *testing.T) {
+	provider := newMockObjectStorageProvider()
+	path := putTestMeteringFile(t, provider, 1755687660, "tikv", "server1", 0, []map[string]interface{}{
+		{"logical_cluster_id": "lc1"},
+	})
+	provider.files["metering/ru/plain.json"] = []byte(`{"category":"tikv"}`)
+
+	meteringReader := NewMeteringReader(provider, &config.Config{Logger: zap.NewNop()})
+	ctx := context.Background()
+
+	body, info, err := meteringReader.DownloadRaw(ctx, path)
+	require.NoError(t, err)
+	raw, err := io.ReadAll(body)
+	require.NoError(t, err)
+	require.NoError(t, body.Close())
+	assert.Equal(t, provider.files[path], raw)
+	assert.Equal(t, reader.ObjectInfo{Path: path, Compressed: true}, info)
+
+	body, info, err = meteringReader.DownloadRaw(ctx, path)
+	require.NoError(t, err)
+	decompressed, err := reader.Decompress(body, info)
+	require.NoError(t, err)
+	var data common.MeteringData
+	require.NoError(t, json.NewDecoder(decompressed).Decode(&data))
+	require.NoError(t, decompressed.Close())
+	assert.Equal(t, "lc1", data.Data[0]["logical_cluster_id"])
+
+	body, info, err = meteringReader.DownloadRaw(ctx, "metering/ru/plain.json")
+	require.NoError(t, err)
+	assert.False(t, info.Compressed)
+	plain, err := reader.Decompress(body, info)
+	require.NoError(t, err)
+	raw, err = io.ReadAll(plain)
+	require.NoError(t, err)
+	assert.Equal(t, `{"category":"tikv"}`, string(raw))
+
+	_, _, err = meteringReader.DownloadRaw(ctx, "metering/ru/missing.json.gz")
+	assert.ErrorIs(t, err, reader.ErrFileNotFound)
+}
+
+func TestMeteringReader_StatFile(t *testing.T) {
+	ctx := context.Background()
+	memory := storage.NewMemoryProvider()
+	mock := newMockObjectStorageProvider()
+	path := putTestMeteringFile(t, mock, 1755687660, "tikv", "server1", 0, []map[string]interface{}{
+		{"logical_cluster_id": "lc1"},
+	})
+	require.NoError(t, memory.Upload(ctx, path, bytes.NewReader(mock.files[path])))
+
+	// The memory provider is stat'ed, the mock provider is downloaded
+	for _, provider := range []storage.ObjectStorageProvider{memory, mock} {
+		meteringReader := NewMeteringReader(provider, &config.Config{Logger: zap.NewNop()})
+		info, err := meteringReader.StatFile(ctx, path)
+		require.NoError(t, err)
+		assert.Equal(t, "tikv", info.Category)
+		assert.Equal(t, int64(len(mock.files[path])), info.Size)
+
+		_, err = meteringReader.StatFile(ctx, "metering/ru/1755687660/tikv/pool/missing-0.json.gz")
+		assert.ErrorIs(t, err, reader.ErrFileNotFound)
+	}
+}
+
+func TestMeteringReader_ReadFileWithFormat(t *testing.T) {
+	provider := newMockObjectStorageProvider()
+	current := putTestMeteringFile(t, provider, 1755687660, "tikv", "server1", 0, []map[string]interface{}{
+		{"logical_cluster_id": "lc1"},
+	})
+	legacy := "metering/ru/1755687660/tikv/pool1/server2-0.json.gz"
+	compressed, err := createCompressedTestData(map[string]interface{}{
+		"timestamp":           1755687660,
+		"category":            "tikv",
+		"self_id":             "server2",
+		"physical_cluster_id": "pc1",
+		"data": []map[string]interface{}{
+			{"logical_cluster_id": "lc1"},
+			{"logical_cluster_id": "lc2"},
+		},
+	})
+	require.NoError(t, err)
+	provider.files[legacy] = compressed
+
+	meteringReader := NewMeteringReader(provider, &config.Config{Logger: zap.NewNop()})
+	ctx := context.Background()
+
+	data, version, err := meteringReader.ReadFileWithFormat(ctx, current)
+	require.NoError(t, err)
+	assert.Equal(t, FormatVersionCurrent, version)
+	assert.Equal(t, "pool1", data.SharedPoolID)
+
+	data, version, err = meteringReader.ReadFileWithFormat(ctx, legacy)
+	require.NoError(t, err)
+	assert.Equal(t, FormatVersionLegacy, version)
+	assert.Equal(t, "legacy", version.String())
+	assert.Equal(t, "pc1", data.SharedPoolID)
+	assert.Len(t, data.Data, 2)
+
+	// Normalized with predicates and through ReadFile too
+	data, version, err = meteringReader.ReadFileWithFormat(ctx, legacy, FieldIn("logical_cluster_id", "lc2"))
+	require.NoError(t, err)
+	assert.Equal(t, FormatVersionLegacy, version)
+	assert.Equal(t, "pc1", data.SharedPoolID)
+	require.Len(t, data.Data, 1)
+	assert.Equal(t, "lc2", data.Data[0]["logical_cluster_id"])
+
+	data, err = meteringReader.ReadFile(ctx, legacy)
+	require.NoError(t, err)
+	assert.Equal(t, "pc1", data.SharedPoolID)
+}
+
+func TestMeteringReader_FormatVersion(t *testing.T) {
+	provider := newMockObjectStorageProvider()
+	path := "metering/ru/1755687660/tikv/pool1/server1-0.json.gz"
+	// format_version comes first, as written by the writer
+	compressed, err := createCompressedTestData(json.RawMessage(fmt.Sprintf(`{"format_version":%d,"timestamp":1755687660,`+
+		`"category":"tikv","self_id":"server1","shared_pool_id":"pool1","new_field":"ignored",`+
+		`"data":[{"logical_cluster_id":"lc1"}]}`, common.CurrentFormatVersion+1)))
+	require.NoError(t, err)
+	provider.files[path] = compressed
+	ctx := context.Background()
+
+	// Best effort by default
+	meteringReader := NewMeteringReader(provider, &config.Config{Logger: zap.NewNop()})
+	data, version, err := meteringReader.ReadFileWithFormat(ctx, path)
+	require.NoError(t, err)
+	assert.Equal(t, FormatVersion(common.CurrentFormatVersion+1), version)
+	assert.Equal(t, "pool1", data.SharedPoolID)
+	assert.Len(t, data.Data, 1)
+	var entries int
+	for _, err := range meteringReader.ReadFileStream(ctx, path) {
+		require.NoError(t, err)
+		entries++
+	}
+	assert.Equal(t, 1, entries)
+
+	strictReader := NewMeteringReader(provider, (&config.Config{Logger: zap.NewNop()}).WithStrictFormatVersion(true))
+	_, err = strictReader.ReadFile(ctx, path)
+	assert.ErrorIs(t, err, reader.ErrUnsupportedFormat)
+	_, err = strictReader.ReadFile(ctx, path, FieldIn("logical_cluster_id", "lc1"))
+	assert.ErrorIs(t, err, reader.ErrUnsupportedFormat)
+	for entry, err := range strictReader.ReadFileStream(ctx, path) {
+		assert.Nil(t, entry)
+		assert.ErrorIs(t, err, reader.ErrUnsupportedFormat)
+	}
+}
@@ -0,0 +1,158 @@
+package meteringreader
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/pingcap/metering_sdk/common"
+)
+
+// Predicate a condition on one field of the Data entries of a metering file. Entries of JSON files are
+// only decoded once every predicate matches, so selective scans of large pages skip most of the work.
+type Predicate struct {
+	Field string                       // entry field the predicate applies to
+	Match func(value interface{}) bool // reports whether the entry matches, value is nil if the field is missing
+}
+
+// FieldIn matches entries whose field is one of the given strings, e.g.
+// FieldIn(common.LogicalClusterIDField, "lc-1", "lc-2")
+func FieldIn(field string, values ...string) Predicate {
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+	return Predicate{Field: field, Match: func(value interface{}) bool {
+		s, ok := value.(string)
+		if !ok {
+			return false
+		}
+		_, ok = set[s]
+		return ok
+	}}
+}
+
+// FieldGreaterThan matches entries whose field is a number or metering value greater than threshold
+func FieldGreaterThan(field string, threshold float64) Predicate {
+	return Predicate{Field: field, Match: func(value interface{}) bool {
+		f, ok := numericValue(value)
+		return ok && f > threshold
+	}}
+}
+
+// FieldLessThan matches entries whose field is a number or metering value less than threshold
+func FieldLessThan(field string, threshold float64) Predicate {
+	return Predicate{Field: field, Match: func(value interface{}) bool {
+		f, ok := numericValue(value)
+		return ok && f < threshold
+	}}
+}
+
+// numericValue returns the value of a number or metering value field
+func numericValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	if mv, ok := common.ParseMeteringValue(value); ok {
+		return mv.Float64(), true
+	}
+	return 0, false
+}
+
+// rowFilter evaluates predicates on Data entries, either decoded or still encoded as JSON
+type rowFilter struct {
+	predicates []Predicate
+	fieldIndex []int        // index of the field of each predicate in fields
+	fields     reflect.Type // struct with one json.RawMessage field per distinct predicate field
+}
+
+// newRowFilter returns a filter of predicates, nil if there are none
+func newRowFilter(predicates []Predicate) (*rowFilter, error) {
+	if len(predicates) == 0 {
+		return nil, nil
+	}
+	f := &rowFilter{predicates: predicates, fieldIndex: make([]int, len(predicates))}
+	indexes := make(map[string]int)
+	var structFields []reflect.StructField
+	for i, p := range predicates {
+		if p.Field == "" || p.Match == nil {
+			return nil, fmt.Errorf("predicate %d must have a field and a match function", i)
+		}
+		index, ok := indexes[p.Field]
+		if !ok {
+			index = len(structFields)
+			indexes[p.Field] = index
+			structFields = append(structFields, reflect.StructField{
+				Name: fmt.Sprintf("F%d", index),
+				Type: reflect.TypeOf(json.RawMessage(nil)),
+				Tag:  reflect.StructTag(fmt.Sprintf(`json:%q`, p.Field)),
+			})
+		}
+		f.fieldIndex[i] = index
+	}
+	// decoding into a struct only keeps the predicate fields, the rest of the entry is skipped
+	f.fields = reflect.StructOf(structFields)
+	return f, nil
+}
+
+// match reports whether a decoded entry matches every predicate
+func (f *rowFilter) match(entry map[string]interface{}) bool {
+	for _, p := range f.predicates {
+		if !p.Match(entry[p.Field]) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchRaw reports whether a JSON encoded entry matches every predicate, decoding only the predicate fields.
+// Like any struct, the fields are matched case-insensitively if the entry has no key spelled exactly alike
+func (f *rowFilter) matchRaw(raw json.RawMessage) (bool, error) {
+	fields := reflect.New(f.fields)
+	if err := json.Unmarshal(raw, fields.Interface()); err != nil {
+		return false, err
+	}
+	values := make([]interface{}, f.fields.NumField())
+	decoded := make([]bool, len(values))
+	for i, p := range f.predicates {
+		index := f.fieldIndex[i]
+		if !decoded[index] {
+			if rawValue := fields.Elem().Field(index).Bytes(); len(rawValue) > 0 {
+				if err := json.Unmarshal(rawValue, &values[index]); err != nil {
+					return false, err
+				}
+			}
+			decoded[index] = true
+		}
+		if !p.Match(values[index]) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// filterEntries returns the entries of data matching f, all of them if f is nil
+func (f *rowFilter) filterEntries(data []map[string]interface{}) []map[string]interface{} {
+	if f == nil {
+		return data
+	}
+	matched := data[:0]
+	for _, entry := range data {
+		if f.match(entry) {
+			matched = append(matched, entry)
+		}
+	}
+	return matched
+}
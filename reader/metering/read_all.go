@@ -0,0 +1,224 @@
+package meteringreader
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/pingcap/metering_sdk/logging"
+	"github.com/pingcap/metering_sdk/reader"
+	"github.com/pingcap/metering_sdk/storage"
+	"go.uber.org/zap"
+)
+
+// DefaultReadConcurrency default number of files downloaded in parallel by ReadTimestampAll,
+// ScanTimestamp and ReadMultipleFiles
+const DefaultReadConcurrency = 8
+
+// ReadOptions options of ReadTimestampAll and ScanTimestamp
+type ReadOptions struct {
+	// Categories categories to read, empty means all
+	Categories []string
+	// Concurrency number of files downloaded in parallel, default DefaultReadConcurrency.
+	// It also bounds the number of files ScanTimestamp holds in memory.
+	Concurrency int
+	// Progress, if set, is called after every file processed
+	Progress func(ScanProgress)
+}
+
+// ScanProgress reports how far a timestamp has been read
+type ScanProgress struct {
+	Listed    int // matching files listed so far, listing runs ahead of processing
+	Processed int // files processed so far
+}
+
+// scanResult a downloaded file, or the error reading it
+type scanResult struct {
+	info *MeteringFileInfo
+	data *common.MeteringData
+	err  error
+}
+
+// ScanTimestamp streams every metering file written at timestamp to fn, for timestamps with more
+// files than fit in memory, e.g. after a regional incident floods a single minute. Listing is paginated
+// and overlaps with downloads, and at most opts.Concurrency downloaded files are held at a time.
+// fn is called by one goroutine at a time, in completion order; an error returned by fn stops the
+// scan and is returned. opts may be nil.
+func (r *MeteringReader) ScanTimestamp(ctx context.Context, timestamp int64, opts *ReadOptions,
+	fn func(info *MeteringFileInfo, data *common.MeteringData) error) error {
+	if opts == nil {
+		opts = &ReadOptions{}
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultReadConcurrency
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	finalized, err := r.finalizedFiles(ctx, timestamp)
+	if err != nil {
+		return err
+	}
+	pathLayout := r.config.GetPathLayout()
+	prefix := pathLayout.TimestampPrefix(timestamp)
+	work := make(chan *MeteringFileInfo)
+	results := make(chan scanResult)
+	var listed atomic.Int64
+
+	go func() {
+		defer close(work)
+		err := r.listPages(ctx, prefix, func(page []string) error {
+			for _, filePath := range page {
+				info, err := r.GetFileInfo(filePath)
+				if err != nil {
+					r.logger.Warn("Unrecognized file path format, skipping",
+						logging.Path(filePath),
+					)
+					continue
+				}
+				if info.Timestamp != timestamp || !isFinalized(finalized, filePath) {
+					continue
+				}
+				if len(opts.Categories) > 0 && !slices.Contains(opts.Categories, info.Category) {
+					continue
+				}
+				listed.Add(1)
+				select {
+				case work <- info:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			cancel(fmt.Errorf("failed to list files with prefix %s: %w", prefix, err))
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for info := range work {
+				data, err := r.ReadFile(ctx, info.Path)
+				select {
+				case results <- scanResult{info: info, data: data, err: err}:
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	processed := 0
+	for result := range results {
+		if ctx.Err() != nil {
+			continue // drain until the workers stop
+		}
+		if result.err != nil {
+			cancel(fmt.Errorf("failed to read file %s: %w", result.info.Path, result.err))
+			continue
+		}
+		if err := fn(result.info, result.data); err != nil {
+			cancel(err)
+			continue
+		}
+		processed++
+		if opts.Progress != nil {
+			opts.Progress(ScanProgress{Listed: int(listed.Load()), Processed: processed})
+		}
+	}
+
+	if err := context.Cause(ctx); err != nil {
+		return err
+	}
+	r.logger.Info("Scanned metering files of timestamp",
+		zap.Int64("timestamp", timestamp),
+		zap.Int("total_files", processed),
+	)
+	return nil
+}
+
+// listPages lists prefix page by page, through the provider's own pagination if it supports it
+func (r *MeteringReader) listPages(ctx context.Context, prefix string, fn func(page []string) error) error {
+	if err := r.checkOpen(); err != nil {
+		return err
+	}
+	if r.pager != nil {
+		return r.pager.ListPages(ctx, prefix, fn)
+	}
+	return storage.ListPages(ctx, r.provider, prefix, fn)
+}
+
+// ReadTimestampAll reads every metering file written at timestamp with a single listing and returns
+// the data by category. The parts written by a component are merged into one MeteringData as in
+// ReadAllParts; components are ordered by shared pool and self ID. opts may be nil.
+// Everything is held in memory, use ScanTimestamp for very large timestamps.
+func (r *MeteringReader) ReadTimestampAll(ctx context.Context, timestamp int64, opts *ReadOptions) (map[string][]*common.MeteringData, error) {
+	var infos []*MeteringFileInfo
+	pages := make(map[string]*common.MeteringData)
+	err := r.ScanTimestamp(ctx, timestamp, opts, func(info *MeteringFileInfo, data *common.MeteringData) error {
+		infos = append(infos, info)
+		pages[info.Path] = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		a, b := infos[i], infos[j]
+		if a.Category != b.Category {
+			return a.Category < b.Category
+		}
+		if a.SharedPoolID != b.SharedPoolID {
+			return a.SharedPoolID < b.SharedPoolID
+		}
+		if a.SelfID != b.SelfID {
+			return a.SelfID < b.SelfID
+		}
+		return a.Part < b.Part
+	})
+
+	// The parts of a component are now adjacent and in order
+	result := make(map[string][]*common.MeteringData)
+	var current *common.MeteringData
+	var nextPart int
+	for _, info := range infos {
+		if current == nil || info.Category != current.Category ||
+			info.SharedPoolID != current.SharedPoolID || info.SelfID != current.SelfID {
+			current = &common.MeteringData{
+				Timestamp:    timestamp,
+				Category:     info.Category,
+				SelfID:       info.SelfID,
+				SharedPoolID: info.SharedPoolID,
+			}
+			result[info.Category] = append(result[info.Category], current)
+			nextPart = 0
+		}
+		if info.Part != nextPart {
+			return nil, fmt.Errorf("%w: part %d of %s/%s at %d", reader.ErrFileNotFound, nextPart, info.Category, info.SelfID, timestamp)
+		}
+		nextPart++
+		current.Data = append(current.Data, pages[info.Path].Data...)
+	}
+
+	r.logger.Info("Read all metering data of timestamp",
+		zap.Int64("timestamp", timestamp),
+		zap.Int("categories_count", len(result)),
+		zap.Int("total_files", len(infos)),
+	)
+
+	return result, nil
+}
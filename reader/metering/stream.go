@@ -0,0 +1,278 @@
+package meteringreader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"time"
+
+	"github.com/pingcap/metering_sdk/codec"
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/pingcap/metering_sdk/internal/compress"
+	"github.com/pingcap/metering_sdk/reader"
+	"github.com/pingcap/metering_sdk/storage"
+	"github.com/pingcap/metering_sdk/tracing"
+)
+
+// maxDecompressedSize limits the decompressed size of a metering file, to prevent DoS attacks
+const maxDecompressedSize = 100 * 1024 * 1024
+
+// newDecoder returns a JSON decoder streaming the decompressed content of body, release must be called
+// once decoding is done
+func newDecoder(body io.Reader) (*json.Decoder, func(), error) {
+	gzipReader, err := compress.NewReader(body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decompress data: %w", err)
+	}
+	decoder := json.NewDecoder(io.LimitReader(gzipReader, maxDecompressedSize))
+	return decoder, func() { compress.PutReader(gzipReader) }, nil
+}
+
+// filteredMeteringData metering data whose entries are decoded once they match a rowFilter
+type filteredMeteringData struct {
+	versionedMeteringData
+	Data []json.RawMessage `json:"data"`
+}
+
+// decodeFile decompresses and parses the metering data file at filePath with the codec of its suffix,
+// keeping only the entries matching filter if it is not nil. JSON files are streamed through the JSON
+// decoder. Files written in a legacy format are normalized into the current one, the format they were
+// written with is returned along with the data. Other codecs only ever wrote the current format, their
+// files are checked against the format version recorded by codecs implementing codec.Versioned.
+func (r *MeteringReader) decodeFile(filePath string, body io.Reader, filter *rowFilter) (*common.MeteringData, FormatVersion, error) {
+	if fileCodec := codec.ForPath(filePath); !codec.IsJSON(fileCodec) {
+		return r.decodeCodecFile(filePath, fileCodec, body, filter)
+	}
+
+	decoder, release, err := newDecoder(body)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer release()
+
+	if filter == nil {
+		var versioned versionedMeteringData
+		if err := decoder.Decode(&versioned); err != nil {
+			return nil, 0, fmt.Errorf("%w: failed to unmarshal metering data: %v", reader.ErrInvalidFormat, err)
+		}
+		if err := r.checkFormatVersion(filePath, versioned.FormatVersion); err != nil {
+			return nil, 0, err
+		}
+		meteringData, version := versioned.normalize()
+		return meteringData, version, nil
+	}
+
+	var filtered filteredMeteringData
+	if err := decoder.Decode(&filtered); err != nil {
+		return nil, 0, fmt.Errorf("%w: failed to unmarshal metering data: %v", reader.ErrInvalidFormat, err)
+	}
+	if err := r.checkFormatVersion(filePath, filtered.FormatVersion); err != nil {
+		return nil, 0, err
+	}
+	meteringData, version := filtered.normalize()
+	for _, raw := range filtered.Data {
+		entry, err := decodeEntry(raw, filter)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: failed to unmarshal metering data: %v", reader.ErrInvalidFormat, err)
+		}
+		if entry != nil {
+			meteringData.Data = append(meteringData.Data, entry)
+		}
+	}
+	return meteringData, version, nil
+}
+
+// decodeCodecFile decodes a metering data file serialized with a codec other than JSON, see decodeFile
+func (r *MeteringReader) decodeCodecFile(filePath string, fileCodec codec.Codec, body io.Reader, filter *rowFilter) (*common.MeteringData, FormatVersion, error) {
+	data, err := compress.Gunzip(body, maxDecompressedSize)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decompress data: %w", err)
+	}
+	var meteringData common.MeteringData
+	version := 0
+	if versioned, ok := fileCodec.(codec.Versioned); ok {
+		version, err = versioned.UnmarshalVersion(data, &meteringData)
+	} else {
+		err = fileCodec.Unmarshal(data, &meteringData)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: failed to unmarshal metering data with codec %s: %v", reader.ErrInvalidFormat, fileCodec.Name(), err)
+	}
+	if err := r.checkFormatVersion(filePath, version); err != nil {
+		return nil, 0, err
+	}
+	meteringData.Data = filter.filterEntries(meteringData.Data)
+	if version == 0 {
+		version = int(FormatVersionCurrent)
+	}
+	return &meteringData, FormatVersion(version), nil
+}
+
+// checkFormatVersion validates the format_version of the file at filePath, see reader.CheckFormatVersion
+func (r *MeteringReader) checkFormatVersion(filePath string, version int) error {
+	return reader.CheckFormatVersion(r.logger, filePath, version, r.config.StrictFormatVersion)
+}
+
+// decodeEntry decodes a JSON encoded Data entry if it matches filter, returning nil otherwise
+func decodeEntry(raw json.RawMessage, filter *rowFilter) (map[string]interface{}, error) {
+	matched, err := filter.matchRaw(raw)
+	if err != nil || !matched {
+		return nil, err
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// ReadFileStream returns an iterator over the logical cluster entries of the metering data file at
+// filePath, decoded one at a time as the file is downloaded, so huge pages are never held in memory as a
+// whole. The other fields of the file are skipped, see GetFileInfo. Only the entries matching every
+// predicate are yielded. An error is yielded once and ends the iteration; entries yielded before it were
+// read from a file that turned out to be invalid.
+func (r *MeteringReader) ReadFileStream(ctx context.Context, filePath string, predicates ...Predicate) iter.Seq2[map[string]interface{}, error] {
+	return func(yield func(map[string]interface{}, error) bool) {
+		filter, err := newRowFilter(predicates)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		start := time.Now()
+		ctx, span := tracing.Start(ctx, r.config.TracerProvider, "MeteringReader.ReadFileStream", tracing.AttributePath.String(filePath))
+		err = r.streamFile(ctx, filePath, filter, func(entry map[string]interface{}) bool {
+			return yield(entry, nil)
+		})
+		tracing.End(span, err)
+		r.config.Metrics.ObserveRead("metering", start, err)
+		if err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// streamFile downloads the file at filePath and calls fn with every entry of its data array matching
+// filter until fn returns false. Only JSON files are decoded as they are downloaded, others are decoded
+// as a whole
+func (r *MeteringReader) streamFile(ctx context.Context, filePath string, filter *rowFilter, fn func(entry map[string]interface{}) bool) error {
+	if err := r.checkOpen(); err != nil {
+		return err
+	}
+	body, err := r.provider.Download(ctx, filePath)
+	if errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("%w: %s", reader.ErrFileNotFound, filePath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to download file: %w", err)
+	}
+	defer body.Close()
+
+	if !codec.IsJSON(codec.ForPath(filePath)) {
+		meteringData, _, err := r.decodeFile(filePath, body, filter)
+		if err != nil {
+			return err
+		}
+		for _, entry := range meteringData.Data {
+			if !fn(entry) {
+				return nil
+			}
+		}
+		return nil
+	}
+
+	decoder, release, err := newDecoder(body)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	invalid := func(err error) error {
+		return fmt.Errorf("%w: failed to decode metering data: %v", reader.ErrInvalidFormat, err)
+	}
+	if err := expectDelim(decoder, '{'); err != nil {
+		return invalid(err)
+	}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return invalid(err)
+		}
+		if key == "format_version" {
+			// Written before data, so files of an unsupported version fail before any entry is yielded
+			var version int
+			if err := decoder.Decode(&version); err != nil {
+				return invalid(err)
+			}
+			if err := r.checkFormatVersion(filePath, version); err != nil {
+				return err
+			}
+			continue
+		}
+		if key != "data" {
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return invalid(err)
+			}
+			continue
+		}
+
+		token, err := decoder.Token()
+		if err != nil {
+			return invalid(err)
+		}
+		if token == nil {
+			// "data": null
+			continue
+		}
+		if token != json.Delim('[') {
+			return invalid(fmt.Errorf("expected [, got %v", token))
+		}
+		for decoder.More() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var entry map[string]interface{}
+			if filter == nil {
+				if err := decoder.Decode(&entry); err != nil {
+					return invalid(err)
+				}
+			} else {
+				var raw json.RawMessage
+				if err := decoder.Decode(&raw); err != nil {
+					return invalid(err)
+				}
+				if entry, err = decodeEntry(raw, filter); err != nil {
+					return invalid(err)
+				}
+				if entry == nil {
+					continue
+				}
+			}
+			if !fn(entry) {
+				return nil
+			}
+		}
+		if err := expectDelim(decoder, ']'); err != nil {
+			return invalid(err)
+		}
+	}
+	if err := expectDelim(decoder, '}'); err != nil {
+		return invalid(err)
+	}
+	return nil
+}
+
+// expectDelim reads the next token of decoder, failing unless it is delim
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %s, got %v", delim, token)
+	}
+	return nil
+}
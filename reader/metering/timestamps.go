@@ -0,0 +1,109 @@
+package meteringreader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/metering_sdk/internal/utils"
+	"github.com/pingcap/metering_sdk/layout"
+	"github.com/pingcap/metering_sdk/storage"
+)
+
+// errTimestampFound stops the listing of a timestamp once a file was found
+var errTimestampFound = errors.New("timestamp found")
+
+// ListTimestamps returns the minute-level timestamps between start and end, both inclusive, that
+// contain at least one metering file, or with RequireFinalized at least one finalize marker, in
+// ascending order. Minutes missing from the result are gaps in
+// the metering stream. With layouts that give every timestamp its own directory, such as the default
+// one, the timestamps are found with a single delimiter-based listing; otherwise every minute of the
+// range is probed.
+func (r *MeteringReader) ListTimestamps(ctx context.Context, start, end int64) ([]int64, error) {
+	if err := utils.ValidateTimestamp(start); err != nil {
+		return nil, fmt.Errorf("invalid start timestamp: %w", err)
+	}
+	if err := utils.ValidateTimestamp(end); err != nil {
+		return nil, fmt.Errorf("invalid end timestamp: %w", err)
+	}
+	if end < start {
+		return nil, fmt.Errorf("end timestamp %d is before start timestamp %d", end, start)
+	}
+
+	if r.config.RequireFinalized {
+		// Every finalized timestamp has a directory of finalize markers
+		return r.listTimestampDirectories(ctx, layout.FinalizedPrefix, start, end)
+	}
+	if directory, ok := r.config.GetPathLayout().TimestampDirectory(); ok {
+		return r.listTimestampDirectories(ctx, directory, start, end)
+	}
+
+	var timestamps []int64
+	for ts := start; ts <= end; ts += 60 {
+		found, err := r.hasFiles(ctx, ts)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			timestamps = append(timestamps, ts)
+		}
+	}
+	return timestamps, nil
+}
+
+// listTimestampDirectories lists the timestamp directories under prefix and keeps those in range
+func (r *MeteringReader) listTimestampDirectories(ctx context.Context, prefix string, start, end int64) ([]int64, error) {
+	if err := r.checkOpen(); err != nil {
+		return nil, err
+	}
+	var (
+		dirs []string
+		err  error
+	)
+	if r.prefixes != nil {
+		dirs, err = r.prefixes.ListCommonPrefixes(ctx, prefix, "/")
+	} else {
+		dirs, err = storage.ListCommonPrefixes(ctx, r.provider, prefix, "/")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list timestamps with prefix %s: %w", prefix, err)
+	}
+
+	var timestamps []int64
+	for _, dir := range dirs {
+		dir = strings.TrimSuffix(dir, "/")
+		ts, err := strconv.ParseInt(dir[strings.LastIndex(dir, "/")+1:], 10, 64)
+		if err != nil || ts < start || ts > end {
+			continue
+		}
+		timestamps = append(timestamps, ts)
+	}
+	// Prefixes are sorted as strings, which only matches numeric order for timestamps of equal length
+	slices.Sort(timestamps)
+	return timestamps, nil
+}
+
+// hasFiles reports whether any metering file was written at timestamp, stopping at the first one
+func (r *MeteringReader) hasFiles(ctx context.Context, timestamp int64) (bool, error) {
+	pathLayout := r.config.GetPathLayout()
+	prefix := pathLayout.TimestampPrefix(timestamp)
+	err := r.listPages(ctx, prefix, func(page []string) error {
+		for _, path := range page {
+			// The prefix may cover other timestamps, depending on the layout
+			if fields, err := pathLayout.Parse(path); err == nil && fields.Timestamp == timestamp {
+				return errTimestampFound
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errTimestampFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to list files with prefix %s: %w", prefix, err)
+	}
+	return false, nil
+}
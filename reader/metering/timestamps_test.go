@@ -0,0 +1,39 @@
+package meteringreader
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pingcap/metering_sdk/config"
+	"github.com/pingcap/metering_sdk/layout"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestMeteringReader_ListTimestamps(t *testing.T) {
+	ctx := context.Background()
+	for name, cfg := range map[string]*config.Config{
+		"default": {Logger: zap.NewNop()},
+		"hive":    config.DefaultConfig().WithLogger(zap.NewNop()).WithPathLayout(layout.MustNew(layout.HiveTemplate)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			provider := newMockObjectStorageProvider()
+			for _, ts := range []int64{1755687540, 1755687600, 1755687720, 1755687780, 1755687900} {
+				path := cfg.GetPathLayout().Path(layout.Fields{Timestamp: ts, Category: "tidb", SharedPoolID: "pool1", SelfID: "server1"}, 0)
+				provider.files[path] = []byte("mock data")
+			}
+			r := NewMeteringReader(provider, cfg)
+
+			// 1755687660 and 1755687840 are gaps, 1755687540 and 1755687900 are out of range
+			timestamps, err := r.ListTimestamps(ctx, 1755687600, 1755687840)
+			require.NoError(t, err)
+			assert.Equal(t, []int64{1755687600, 1755687720, 1755687780}, timestamps)
+
+			_, err = r.ListTimestamps(ctx, 1755687600, 1755687540)
+			assert.Error(t, err)
+			_, err = r.ListTimestamps(ctx, 1755687601, 1755687840)
+			assert.Error(t, err)
+		})
+	}
+}
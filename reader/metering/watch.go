@@ -0,0 +1,195 @@
+package meteringreader
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pingcap/metering_sdk/internal/utils"
+	"github.com/pingcap/metering_sdk/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultWatchInterval default polling interval of Watch
+	DefaultWatchInterval = 30 * time.Second
+	// DefaultWatchLag default time a minute is still polled for late files after it ended
+	DefaultWatchLag = 2 * time.Minute
+)
+
+// WatchHandler handles a metering file found by Watch. Returning an error stops Watch.
+type WatchHandler func(ctx context.Context, file *MeteringFileInfo) error
+
+// WatchOptions options of Watch
+type WatchOptions struct {
+	// Interval polling interval. Default DefaultWatchInterval
+	Interval time.Duration
+	// Lag time a minute is still polled after it ended, so files of late writers are not missed.
+	// Default DefaultWatchLag
+	Lag time.Duration
+	// Notifications paths of newly created objects, e.g. received from S3 event notifications through
+	// SQS. Files are handled as soon as they are notified instead of on the next poll; polling still
+	// catches files whose notification was lost. Optional
+	Notifications <-chan string
+	// OnCheckpoint is called after every file of a minute has been handled and the minute is complete,
+	// with its timestamp. Persist it to resume with Watch(ctx, timestamp+60, ...) after a restart. Optional
+	OnCheckpoint func(ctx context.Context, timestamp int64) error
+}
+
+// Watch tails the metering files from the minute-level timestamp startTs on, calling handler once for
+// every file. Minutes up to now are caught up with first, ordered by timestamp, category and path; after
+// that new files are picked up by polling every Interval and from Notifications. A minute is polled
+// until Lag after it ended. Watch runs until ctx is cancelled or handler fails, returning the error.
+func (r *MeteringReader) Watch(ctx context.Context, startTs int64, handler WatchHandler, opts *WatchOptions) error {
+	if err := utils.ValidateTimestamp(startTs); err != nil {
+		return fmt.Errorf("invalid start timestamp: %w", err)
+	}
+	w := newWatcher(r, startTs, handler, opts)
+
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+	for {
+		if err := w.poll(ctx, time.Now()); err != nil {
+			return err
+		}
+
+		for polled := false; !polled; {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case path, ok := <-w.opts.Notifications:
+				if !ok {
+					// Keep polling without notifications
+					w.opts.Notifications = nil
+					continue
+				}
+				if err := w.notify(ctx, path); err != nil {
+					return err
+				}
+			case <-ticker.C:
+				polled = true
+			}
+		}
+	}
+}
+
+// watcher state of a Watch call
+type watcher struct {
+	reader  *MeteringReader
+	handler WatchHandler
+	opts    WatchOptions
+	next    int64                         // first minute that isn't complete yet
+	seen    map[int64]map[string]struct{} // handled files of incomplete minutes, by timestamp
+}
+
+// newWatcher creates a watcher starting at startTs, applying option defaults
+func newWatcher(r *MeteringReader, startTs int64, handler WatchHandler, opts *WatchOptions) *watcher {
+	w := &watcher{
+		reader:  r,
+		handler: handler,
+		next:    startTs,
+		seen:    make(map[int64]map[string]struct{}),
+	}
+	if opts != nil {
+		w.opts = *opts
+	}
+	if w.opts.Interval <= 0 {
+		w.opts.Interval = DefaultWatchInterval
+	}
+	if w.opts.Lag <= 0 {
+		w.opts.Lag = DefaultWatchLag
+	}
+	return w
+}
+
+// poll lists every incomplete minute up to now, handles new files and completes minutes older than Lag
+func (w *watcher) poll(ctx context.Context, now time.Time) error {
+	last := now.Unix() / 60 * 60
+	complete := now.Add(-w.opts.Lag).Unix()/60*60 - 60 // last minute that ended at least Lag ago
+	for ts := w.next; ts <= last; ts += 60 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		timestampFiles, err := w.reader.ListFilesByTimestamp(ctx, ts)
+		if err != nil {
+			return err
+		}
+		for _, path := range sortedFiles(timestampFiles) {
+			if err := w.handle(ctx, ts, path); err != nil {
+				return err
+			}
+		}
+
+		if ts <= complete && ts == w.next {
+			delete(w.seen, ts)
+			w.next = ts + 60
+			if w.opts.OnCheckpoint != nil {
+				if err := w.opts.OnCheckpoint(ctx, ts); err != nil {
+					return fmt.Errorf("failed to save watch checkpoint %d: %w", ts, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// notify handles a file reported by an event notification
+func (w *watcher) notify(ctx context.Context, path string) error {
+	info, err := w.reader.GetFileInfo(path)
+	if err != nil {
+		w.reader.logger.Debug("Ignoring notification for unrecognized path",
+			logging.Path(path),
+		)
+		return nil
+	}
+	if info.Timestamp < w.next {
+		w.reader.logger.Warn("Ignoring notification for file of a completed minute",
+			logging.Path(path),
+			zap.Int64("timestamp", info.Timestamp),
+		)
+		return nil
+	}
+	return w.handle(ctx, info.Timestamp, path)
+}
+
+// handle calls the handler for the file at path of minute ts, unless it was handled before
+func (w *watcher) handle(ctx context.Context, ts int64, path string) error {
+	seen := w.seen[ts]
+	if seen == nil {
+		seen = make(map[string]struct{})
+		w.seen[ts] = seen
+	}
+	if _, ok := seen[path]; ok {
+		return nil
+	}
+
+	info, err := w.reader.GetFileInfo(path)
+	if err != nil {
+		w.reader.logger.Warn("Failed to parse file info, skipping",
+			logging.Path(path),
+			zap.Error(err),
+		)
+		return nil
+	}
+	if err := w.handler(ctx, info); err != nil {
+		return fmt.Errorf("failed to handle %s: %w", path, err)
+	}
+	seen[path] = struct{}{}
+	return nil
+}
+
+// sortedFiles returns the files of a timestamp ordered by category and path
+func sortedFiles(timestampFiles *TimestampFiles) []string {
+	categories := make([]string, 0, len(timestampFiles.Files))
+	for category := range timestampFiles.Files {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	var files []string
+	for _, category := range categories {
+		files = append(files, timestampFiles.Files[category]...)
+	}
+	return files
+}
@@ -0,0 +1,93 @@
+package meteringreader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pingcap/metering_sdk/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestMeteringReader_WatchPoll(t *testing.T) {
+	provider := newMockObjectStorageProvider()
+	putTestMeteringFile(t, provider, 1755687600, "tikv", "server1", 0, nil)
+	putTestMeteringFile(t, provider, 1755687600, "tidb", "server1", 0, nil)
+	putTestMeteringFile(t, provider, 1755687720, "tidb", "server2", 0, nil)
+	r := NewMeteringReader(provider, &config.Config{Logger: zap.NewNop()})
+	ctx := context.Background()
+
+	var handled []string
+	var checkpoints []int64
+	w := newWatcher(r, 1755687600, func(ctx context.Context, file *MeteringFileInfo) error {
+		handled = append(handled, fmt.Sprintf("%d/%s/%s", file.Timestamp, file.Category, file.SelfID))
+		return nil
+	}, &WatchOptions{
+		Lag: time.Minute,
+		OnCheckpoint: func(ctx context.Context, timestamp int64) error {
+			checkpoints = append(checkpoints, timestamp)
+			return nil
+		},
+	})
+
+	// Catch up: minutes ending at least a minute ago are complete
+	now := time.Unix(1755687750, 0)
+	require.NoError(t, w.poll(ctx, now))
+	assert.Equal(t, []string{"1755687600/tidb/server1", "1755687600/tikv/server1", "1755687720/tidb/server2"}, handled)
+	assert.Equal(t, []int64{1755687600}, checkpoints)
+
+	// Late and notified files of incomplete minutes are handled once
+	putTestMeteringFile(t, provider, 1755687660, "tidb", "late", 0, nil)
+	notified := putTestMeteringFile(t, provider, 1755687720, "tidb", "server3", 0, nil)
+	require.NoError(t, w.notify(ctx, notified))
+	require.NoError(t, w.notify(ctx, "not/a/metering/file"))
+	require.NoError(t, w.poll(ctx, now.Add(2*time.Minute)))
+	assert.Equal(t, []string{"1755687720/tidb/server3", "1755687660/tidb/late"}, handled[3:])
+	assert.Equal(t, []int64{1755687600, 1755687660, 1755687720}, checkpoints)
+
+	// Files of completed minutes are not handled again
+	require.NoError(t, w.notify(ctx, notified))
+	assert.Len(t, handled, 5)
+}
+
+func TestMeteringReader_Watch(t *testing.T) {
+	provider := newMockObjectStorageProvider()
+	putTestMeteringFile(t, provider, 1755687600, "tidb", "server1", 0, nil)
+	putTestMeteringFile(t, provider, 1755687660, "tidb", "server2", 0, nil)
+	r := NewMeteringReader(provider, &config.Config{Logger: zap.NewNop()})
+
+	// Handler errors stop Watch, the failed file is retried after a restart from the checkpoint
+	var checkpoint int64
+	failure := errors.New("sink unavailable")
+	err := r.Watch(context.Background(), 1755687600, func(ctx context.Context, file *MeteringFileInfo) error {
+		if file.SelfID == "server2" {
+			return failure
+		}
+		return nil
+	}, &WatchOptions{OnCheckpoint: func(ctx context.Context, timestamp int64) error {
+		checkpoint = timestamp
+		return nil
+	}})
+	assert.ErrorIs(t, err, failure)
+	assert.Equal(t, int64(1755687600), checkpoint)
+
+	// Notifications are handled between polls
+	ctx, cancel := context.WithCancel(context.Background())
+	notifications := make(chan string, 1)
+	current := time.Now().Unix() / 60 * 60
+	notifications <- putTestMeteringFile(t, provider, current, "tidb", "server3", 0, nil)
+	var handled []string
+	err = r.Watch(ctx, checkpoint+60, func(ctx context.Context, file *MeteringFileInfo) error {
+		handled = append(handled, file.SelfID)
+		if file.SelfID == "server3" {
+			cancel()
+		}
+		return nil
+	}, &WatchOptions{Interval: time.Hour, Notifications: notifications})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, []string{"server2", "server3"}, handled)
+}
@@ -0,0 +1,72 @@
+package reader
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/pingcap/metering_sdk/storage"
+)
+
+// gzipMagic the first bytes of every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// ObjectInfo describes an object opened with DownloadRaw
+type ObjectInfo struct {
+	Path       string `json:"path"`       // object path
+	Compressed bool   `json:"compressed"` // content is gzip compressed
+}
+
+// DownloadRaw opens the object at path as stored, without decompressing or decoding it.
+// The caller must close the returned reader.
+func DownloadRaw(ctx context.Context, provider storage.ObjectStorageProvider, path string) (io.ReadCloser, ObjectInfo, error) {
+	info := ObjectInfo{Path: path}
+
+	exists, err := provider.Exists(ctx, path)
+	if err != nil {
+		return nil, info, fmt.Errorf("failed to check if file exists: %w", err)
+	}
+	if !exists {
+		return nil, info, fmt.Errorf("%w: %s", ErrFileNotFound, path)
+	}
+
+	body, err := provider.Download(ctx, path)
+	if err != nil {
+		return nil, info, fmt.Errorf("failed to download file: %w", err)
+	}
+
+	buffered := bufio.NewReader(body)
+	magic, err := buffered.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		body.Close()
+		return nil, info, fmt.Errorf("failed to read file: %w", err)
+	}
+	info.Compressed = bytes.Equal(magic, gzipMagic)
+	return &readCloser{Reader: buffered, closer: body}, info, nil
+}
+
+// Decompress returns a reader of the decompressed content of body if info reports it as
+// compressed, body itself otherwise. Closing the returned reader closes body.
+func Decompress(body io.ReadCloser, info ObjectInfo) (io.ReadCloser, error) {
+	if !info.Compressed {
+		return body, nil
+	}
+	gzipReader, err := gzip.NewReader(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+	return &readCloser{Reader: gzipReader, closer: body}, nil
+}
+
+// readCloser reads from Reader and closes closer
+type readCloser struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (r *readCloser) Close() error {
+	return r.closer.Close()
+}
@@ -0,0 +1,70 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pingcap/metering_sdk/storage"
+)
+
+// Checkpoint records relay progress
+type Checkpoint struct {
+	Timestamp int64 `json:"timestamp"` // last minute-level timestamp fully copied
+}
+
+// CheckpointStore persists relay checkpoints
+type CheckpointStore interface {
+	// Load returns the saved checkpoint, or nil if none has been saved yet
+	Load(ctx context.Context) (*Checkpoint, error)
+	// Save persists the checkpoint
+	Save(ctx context.Context, checkpoint *Checkpoint) error
+}
+
+// providerCheckpointStore stores the checkpoint as a JSON object in a storage provider
+type providerCheckpointStore struct {
+	provider storage.ObjectStorageProvider
+	path     string
+}
+
+// NewProviderCheckpointStore creates a checkpoint store keeping the checkpoint at path in provider,
+// e.g. a LocalFS provider for relay-local state
+func NewProviderCheckpointStore(provider storage.ObjectStorageProvider, path string) CheckpointStore {
+	return &providerCheckpointStore{provider: provider, path: path}
+}
+
+// Load implements CheckpointStore interface
+func (s *providerCheckpointStore) Load(ctx context.Context) (*Checkpoint, error) {
+	exists, err := s.provider.Exists(ctx, s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check checkpoint: %w", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	rc, err := s.provider.Download(ctx, s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download checkpoint: %w", err)
+	}
+	defer rc.Close()
+
+	var checkpoint Checkpoint
+	if err := json.NewDecoder(rc).Decode(&checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint: %w", err)
+	}
+	return &checkpoint, nil
+}
+
+// Save implements CheckpointStore interface
+func (s *providerCheckpointStore) Save(ctx context.Context, checkpoint *Checkpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+	if err := s.provider.Upload(ctx, s.path, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to upload checkpoint: %w", err)
+	}
+	return nil
+}
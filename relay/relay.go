@@ -0,0 +1,242 @@
+// Package relay copies metering data between storage deployments, e.g. from an on-prem
+// bucket to the central billing bucket.
+package relay
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pingcap/metering_sdk/config"
+	"github.com/pingcap/metering_sdk/internal/utils"
+	"github.com/pingcap/metering_sdk/logging"
+	"github.com/pingcap/metering_sdk/storage"
+	"github.com/pingcap/metering_sdk/writer"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultLag default delay before a minute is considered complete and copied
+	DefaultLag = 2 * time.Minute
+	// DefaultInterval default polling interval of Run
+	DefaultInterval = 30 * time.Second
+)
+
+// ErrChecksumMismatch is returned when a copy read back from the destination differs from the source
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// Config relay configuration
+type Config struct {
+	// StartTimestamp first minute-level timestamp to copy when no checkpoint exists
+	StartTimestamp int64
+	// Lag delay before a minute is copied, so late writers can finish. Default DefaultLag
+	Lag time.Duration
+	// Interval polling interval of Run. Default DefaultInterval
+	Interval time.Duration
+	// SkipVerify skips reading copies back from the destination to verify their checksum
+	SkipVerify bool
+	// Throttle limits the bandwidth of source downloads and destination transfers, optional.
+	// Share one Throttle between relays to cap their combined bandwidth
+	Throttle *storage.Throttle
+}
+
+// SyncResult summary of copying one timestamp
+type SyncResult struct {
+	Timestamp int64 `json:"timestamp"` // minute-level timestamp
+	Copied    int   `json:"copied"`    // objects copied
+	Skipped   int   `json:"skipped"`   // objects already present with identical content
+	Bytes     int64 `json:"bytes"`     // bytes copied
+}
+
+// Relay tails a source provider by timestamp and copies new metering files to a destination provider
+type Relay struct {
+	source      storage.ObjectStorageProvider
+	destination storage.ObjectStorageProvider
+	checkpoints CheckpointStore
+	config      *config.Config
+	relayConfig *Config
+	logger      *zap.Logger
+}
+
+// NewRelay creates a new relay. Existing destination objects with different content are
+// only replaced when cfg.OverwriteExisting is set.
+func NewRelay(source, destination storage.ObjectStorageProvider, checkpoints CheckpointStore, cfg *config.Config, relayCfg *Config) *Relay {
+	if cfg == nil {
+		cfg = config.DefaultConfig()
+	}
+	if relayCfg == nil {
+		relayCfg = &Config{}
+	}
+	if relayCfg.Lag <= 0 {
+		relayCfg.Lag = DefaultLag
+	}
+	if relayCfg.Interval <= 0 {
+		relayCfg.Interval = DefaultInterval
+	}
+
+	return &Relay{
+		source:      storage.NewThrottledProvider(source, relayCfg.Throttle),
+		destination: storage.NewThrottledProvider(destination, relayCfg.Throttle),
+		checkpoints: checkpoints,
+		config:      cfg,
+		relayConfig: relayCfg,
+		logger:      cfg.GetLogger(),
+	}
+}
+
+// Run copies new data every Interval until ctx is cancelled
+func (r *Relay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.relayConfig.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.RunOnce(ctx, time.Now()); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			r.logger.Warn("Relay iteration failed", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce copies every complete minute after the checkpoint up to now minus Lag,
+// saving the checkpoint after each minute
+func (r *Relay) RunOnce(ctx context.Context, now time.Time) ([]*SyncResult, error) {
+	checkpoint, err := r.checkpoints.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var next int64
+	if checkpoint != nil {
+		next = checkpoint.Timestamp + 60
+	} else {
+		if err := utils.ValidateTimestamp(r.relayConfig.StartTimestamp); err != nil {
+			return nil, fmt.Errorf("invalid start timestamp without checkpoint: %w", err)
+		}
+		next = r.relayConfig.StartTimestamp
+	}
+
+	last := now.Add(-r.relayConfig.Lag).Unix() / 60 * 60
+	var results []*SyncResult
+	for ts := next; ts <= last; ts += 60 {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		result, err := r.SyncTimestamp(ctx, ts)
+		if err != nil {
+			return results, err
+		}
+		if err := r.checkpoints.Save(ctx, &Checkpoint{Timestamp: ts}); err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// SyncTimestamp copies all metering files of a minute-level timestamp that are missing in the destination
+func (r *Relay) SyncTimestamp(ctx context.Context, timestamp int64) (*SyncResult, error) {
+	if err := utils.ValidateTimestamp(timestamp); err != nil {
+		return nil, err
+	}
+
+	pathLayout := r.config.GetPathLayout()
+	paths, err := r.source.List(ctx, pathLayout.TimestampPrefix(timestamp))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list source objects: %w", err)
+	}
+	sort.Strings(paths)
+
+	result := &SyncResult{Timestamp: timestamp}
+	for _, path := range paths {
+		// The prefix may cover other timestamps when the layout doesn't start with the timestamp
+		if fields, err := pathLayout.Parse(path); err == nil && fields.Timestamp != timestamp {
+			continue
+		}
+		copied, size, err := r.copyObject(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		if copied {
+			result.Copied++
+			result.Bytes += size
+		} else {
+			result.Skipped++
+		}
+	}
+
+	r.logger.Info("Relayed metering data",
+		zap.Int64("timestamp", timestamp),
+		zap.Int("copied", result.Copied),
+		zap.Int("skipped", result.Skipped),
+		logging.Bytes(result.Bytes),
+	)
+	return result, nil
+}
+
+// copyObject copies one object, returning false if the destination already holds identical content
+func (r *Relay) copyObject(ctx context.Context, path string) (bool, int64, error) {
+	data, err := download(ctx, r.source, path)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to download source %s: %w", path, err)
+	}
+	checksum := sha256.Sum256(data)
+
+	exists, err := r.destination.Exists(ctx, path)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to check destination %s: %w", path, err)
+	}
+	if exists {
+		existing, err := download(ctx, r.destination, path)
+		if err != nil {
+			return false, 0, fmt.Errorf("failed to download destination %s: %w", path, err)
+		}
+		if sha256.Sum256(existing) == checksum {
+			return false, 0, nil
+		}
+		if !r.config.OverwriteExisting {
+			return false, 0, fmt.Errorf("%w with different content: %s", writer.ErrFileExists, path)
+		}
+		r.logger.Warn("Overwriting destination object with different content",
+			logging.Path(path),
+		)
+	}
+
+	if err := r.destination.Upload(r.config.UploadContext(ctx), path, bytes.NewReader(data)); err != nil {
+		return false, 0, fmt.Errorf("failed to upload destination %s: %w", path, err)
+	}
+
+	if !r.relayConfig.SkipVerify {
+		copied, err := download(ctx, r.destination, path)
+		if err != nil {
+			return false, 0, fmt.Errorf("failed to verify destination %s: %w", path, err)
+		}
+		if sha256.Sum256(copied) != checksum {
+			return false, 0, fmt.Errorf("%w after copying %s", ErrChecksumMismatch, path)
+		}
+	}
+
+	return true, int64(len(data)), nil
+}
+
+// download reads a whole object into memory
+func download(ctx context.Context, provider storage.ObjectStorageProvider, path string) ([]byte, error) {
+	rc, err := provider.Download(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
@@ -0,0 +1,118 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/pingcap/metering_sdk/config"
+	"github.com/pingcap/metering_sdk/storage"
+	"github.com/pingcap/metering_sdk/writer"
+	meteringwriter "github.com/pingcap/metering_sdk/writer/metering"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestData(t *testing.T, provider storage.ObjectStorageProvider, timestamp int64, selfID string) {
+	w := meteringwriter.NewMeteringWriterWithSharedPool(provider, config.DefaultConfig(), "pool1")
+	defer w.Close()
+	require.NoError(t, w.Write(context.Background(), &common.MeteringData{
+		Timestamp: timestamp,
+		Category:  "tidb",
+		SelfID:    selfID,
+		Data: []map[string]interface{}{
+			{"logical_cluster_id": "lc1", "ru": &common.MeteringValue{Value: 1, Unit: "RU"}},
+		},
+	}))
+}
+
+func readAll(t *testing.T, provider storage.ObjectStorageProvider, path string) []byte {
+	rc, err := provider.Download(context.Background(), path)
+	require.NoError(t, err)
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	return data
+}
+
+func TestRelay_RunOnce(t *testing.T) {
+	const start = int64(1755849600)
+	source := storage.NewMemoryProvider()
+	destination := storage.NewMemoryProvider()
+	checkpoints := NewProviderCheckpointStore(storage.NewMemoryProvider(), "relay/checkpoint.json")
+
+	writeTestData(t, source, start, "server1")
+	writeTestData(t, source, start, "server2")
+	writeTestData(t, source, start+60, "server1")
+	writeTestData(t, source, start+180, "server1") // not complete yet
+
+	r := NewRelay(source, destination, checkpoints, nil, &Config{StartTimestamp: start, Lag: time.Minute})
+	ctx := context.Background()
+
+	// now - lag covers start .. start+120
+	results, err := r.RunOnce(ctx, time.Unix(start+180, 0))
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, 2, results[0].Copied)
+	assert.Equal(t, 1, results[1].Copied)
+	assert.Equal(t, 0, results[2].Copied)
+
+	path := "metering/ru/1755849600/tidb/pool1/server1-0.json.gz"
+	assert.Equal(t, readAll(t, source, path), readAll(t, destination, path))
+
+	exists, err := destination.Exists(ctx, "metering/ru/1755849780/tidb/pool1/server1-0.json.gz")
+	require.NoError(t, err)
+	assert.False(t, exists, "minutes within lag must not be copied yet")
+
+	checkpoint, err := checkpoints.Load(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, start+120, checkpoint.Timestamp)
+
+	// Resumes from the checkpoint
+	results, err = r.RunOnce(ctx, time.Unix(start+240, 0))
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, start+180, results[0].Timestamp)
+	assert.Equal(t, 1, results[0].Copied)
+}
+
+func TestRelay_SyncTimestampExisting(t *testing.T) {
+	const ts = int64(1755849600)
+	source := storage.NewMemoryProvider()
+	destination := storage.NewMemoryProvider()
+	writeTestData(t, source, ts, "server1")
+
+	r := NewRelay(source, destination, nil, nil, nil)
+	ctx := context.Background()
+
+	result, err := r.SyncTimestamp(ctx, ts)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Copied)
+
+	// Identical content is skipped
+	result, err = r.SyncTimestamp(ctx, ts)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Copied)
+	assert.Equal(t, 1, result.Skipped)
+
+	// Different content is a conflict unless overwriting is enabled
+	path := "metering/ru/1755849600/tidb/pool1/server1-0.json.gz"
+	require.NoError(t, destination.Upload(ctx, path, bytes.NewReader([]byte("restated"))))
+	_, err = r.SyncTimestamp(ctx, ts)
+	assert.ErrorIs(t, err, writer.ErrFileExists)
+
+	r = NewRelay(source, destination, nil, config.DefaultConfig().WithOverwriteExisting(true), nil)
+	result, err = r.SyncTimestamp(ctx, ts)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Copied)
+	assert.Equal(t, readAll(t, source, path), readAll(t, destination, path))
+}
+
+func TestRelay_RequiresStartTimestamp(t *testing.T) {
+	r := NewRelay(storage.NewMemoryProvider(), storage.NewMemoryProvider(), NewProviderCheckpointStore(storage.NewMemoryProvider(), "cp.json"), nil, nil)
+	_, err := r.RunOnce(context.Background(), time.Now())
+	assert.Error(t, err)
+}
@@ -0,0 +1,240 @@
+// Package replicator replicates metering data and metadata from a source provider to one or more
+// destination providers, e.g. buckets in other regions or clouds for disaster recovery of billing data.
+package replicator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pingcap/metering_sdk/config"
+	"github.com/pingcap/metering_sdk/relay"
+	"github.com/pingcap/metering_sdk/storage"
+	"go.uber.org/zap"
+)
+
+// MetaPrefix prefix of the metadata objects replicated besides metering data
+const MetaPrefix = "metering/meta/"
+
+// Destination a replication target
+type Destination struct {
+	// Name identifies the destination in logs, results and the replication lag metric, e.g. "us-west-2"
+	Name string
+	// Provider storage provider of the destination
+	Provider storage.ObjectStorageProvider
+	// Checkpoints persists replication progress of metering data to this destination
+	Checkpoints relay.CheckpointStore
+}
+
+// Config replicator configuration
+type Config struct {
+	// StartTimestamp first minute-level timestamp to replicate to destinations without a checkpoint
+	StartTimestamp int64
+	// Lag delay before a minute is replicated, so late writers can finish. Default relay.DefaultLag
+	Lag time.Duration
+	// Interval polling interval of Run. Default relay.DefaultInterval
+	Interval time.Duration
+	// SkipVerify skips reading copies back from destinations to verify their checksum
+	SkipVerify bool
+	// SkipMeta only replicates metering data, not the metadata under MetaPrefix
+	SkipMeta bool
+	// Throttle limits the combined bandwidth of replication to all destinations, optional
+	Throttle *storage.Throttle
+}
+
+// DestinationResult summary of one replication round to a destination
+type DestinationResult struct {
+	Destination string              `json:"destination"` // destination name
+	Metering    []*relay.SyncResult `json:"metering"`    // metering data copied per minute
+	MetaCopied  int                 `json:"meta_copied"` // metadata objects copied
+	Lag         time.Duration       `json:"lag"`         // age of the newest metering data replicated
+	Err         error               `json:"-"`           // error of the round, if any
+}
+
+// replication state of a single destination
+type replication struct {
+	destination Destination
+	provider    storage.ObjectStorageProvider
+	relay       *relay.Relay
+}
+
+// Replicator copies newly written metering files and metadata from a source provider to destinations.
+// Each destination is replicated independently, so a failing region doesn't hold the others back.
+type Replicator struct {
+	source           storage.ObjectStorageProvider
+	replications     []*replication
+	config           *config.Config
+	replicatorConfig *Config
+	logger           *zap.Logger
+}
+
+// NewReplicator creates a new replicator. Metering data is copied minute by minute with a relay.Relay
+// per destination; existing destination objects with different content are only replaced when
+// cfg.OverwriteExisting is set.
+func NewReplicator(source storage.ObjectStorageProvider, destinations []Destination, cfg *config.Config, replicatorCfg *Config) *Replicator {
+	if cfg == nil {
+		cfg = config.DefaultConfig()
+	}
+	if replicatorCfg == nil {
+		replicatorCfg = &Config{}
+	}
+	if replicatorCfg.Interval <= 0 {
+		replicatorCfg.Interval = relay.DefaultInterval
+	}
+
+	relayCfg := &relay.Config{
+		StartTimestamp: replicatorCfg.StartTimestamp,
+		Lag:            replicatorCfg.Lag,
+		Interval:       replicatorCfg.Interval,
+		SkipVerify:     replicatorCfg.SkipVerify,
+		Throttle:       replicatorCfg.Throttle,
+	}
+	replications := make([]*replication, 0, len(destinations))
+	for _, destination := range destinations {
+		// Relays fill in defaults, so each gets its own copy of the configuration
+		destinationRelayCfg := *relayCfg
+		replications = append(replications, &replication{
+			destination: destination,
+			provider:    storage.NewThrottledProvider(destination.Provider, replicatorCfg.Throttle),
+			relay:       relay.NewRelay(source, destination.Provider, destination.Checkpoints, cfg, &destinationRelayCfg),
+		})
+	}
+
+	return &Replicator{
+		source:           storage.NewThrottledProvider(source, replicatorCfg.Throttle),
+		replications:     replications,
+		config:           cfg,
+		replicatorConfig: replicatorCfg,
+		logger:           cfg.GetLogger(),
+	}
+}
+
+// Run replicates new data every Interval until ctx is cancelled
+func (r *Replicator) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.replicatorConfig.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.RunOnce(ctx, time.Now()); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			r.logger.Warn("Replication round failed", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce replicates to every destination the metering data of all complete minutes after its checkpoint,
+// up to now minus Lag, and the metadata objects it is missing. The result of every destination is
+// returned, together with the errors of the failed ones joined.
+func (r *Replicator) RunOnce(ctx context.Context, now time.Time) ([]*DestinationResult, error) {
+	var errs []error
+	results := make([]*DestinationResult, 0, len(r.replications))
+	for _, rep := range r.replications {
+		result := r.replicate(ctx, rep, now)
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("destination %s: %w", rep.destination.Name, result.Err))
+		}
+		results = append(results, result)
+	}
+	return results, errors.Join(errs...)
+}
+
+// replicate runs one replication round to a destination
+func (r *Replicator) replicate(ctx context.Context, rep *replication, now time.Time) *DestinationResult {
+	result := &DestinationResult{Destination: rep.destination.Name}
+	result.Metering, result.Err = rep.relay.RunOnce(ctx, now)
+
+	if result.Err == nil && !r.replicatorConfig.SkipMeta {
+		result.MetaCopied, result.Err = r.syncMeta(ctx, rep)
+	}
+
+	// Report the lag even after a failure, as progress made before it is checkpointed
+	lag, err := r.lag(ctx, rep, now)
+	if err != nil {
+		result.Err = errors.Join(result.Err, err)
+	} else {
+		result.Lag = lag
+		r.config.Metrics.ObserveReplicationLag(rep.destination.Name, lag)
+	}
+
+	r.logger.Info("Replicated metering data",
+		zap.String("destination", rep.destination.Name),
+		zap.Int("minutes", len(result.Metering)),
+		zap.Int("meta_copied", result.MetaCopied),
+		zap.Duration("lag", result.Lag),
+		zap.Error(result.Err),
+	)
+	return result
+}
+
+// syncMeta copies the metadata objects missing in the destination. Metadata files are immutable, one per
+// modification, so objects already present are skipped without comparing their content. The destination
+// is listed once per round and diffed against the source listing.
+func (r *Replicator) syncMeta(ctx context.Context, rep *replication) (int, error) {
+	present := make(map[string]struct{})
+	err := storage.ListPages(ctx, rep.provider, MetaPrefix, func(page []string) error {
+		for _, path := range page {
+			present[path] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list destination metadata: %w", err)
+	}
+
+	copied := 0
+	err = storage.ListPages(ctx, r.source, MetaPrefix, func(page []string) error {
+		for _, path := range page {
+			if _, ok := present[path]; ok {
+				continue
+			}
+			if err := r.copyObject(ctx, rep.provider, path); err != nil {
+				return err
+			}
+			copied++
+		}
+		return nil
+	})
+	return copied, err
+}
+
+// copyObject copies one object from the source to destination
+func (r *Replicator) copyObject(ctx context.Context, destination storage.ObjectStorageProvider, path string) error {
+	rc, err := r.source.Download(ctx, path)
+	if err != nil {
+		return fmt.Errorf("failed to download source %s: %w", path, err)
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return fmt.Errorf("failed to download source %s: %w", path, err)
+	}
+	if err := destination.Upload(r.config.UploadContext(ctx), path, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to upload destination %s: %w", path, err)
+	}
+	return nil
+}
+
+// lag returns the age of the newest metering data replicated to a destination, i.e. the time since the end
+// of its checkpointed minute, or since StartTimestamp when nothing was replicated yet
+func (r *Replicator) lag(ctx context.Context, rep *replication, now time.Time) (time.Duration, error) {
+	checkpoint, err := rep.destination.Checkpoints.Load(ctx)
+	if err != nil {
+		return 0, err
+	}
+	replicatedUntil := r.replicatorConfig.StartTimestamp
+	if checkpoint != nil {
+		replicatedUntil = checkpoint.Timestamp + 60
+	}
+	return max(now.Sub(time.Unix(replicatedUntil, 0)), 0), nil
+}
@@ -0,0 +1,143 @@
+package replicator
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/pingcap/metering_sdk/config"
+	"github.com/pingcap/metering_sdk/relay"
+	"github.com/pingcap/metering_sdk/storage"
+	metawriter "github.com/pingcap/metering_sdk/writer/meta"
+	meteringwriter "github.com/pingcap/metering_sdk/writer/metering"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const start = int64(1755849600)
+
+func writeTestData(t *testing.T, provider storage.ObjectStorageProvider, timestamp int64) {
+	w := meteringwriter.NewMeteringWriterWithSharedPool(provider, config.DefaultConfig(), "pool1")
+	defer w.Close()
+	require.NoError(t, w.Write(context.Background(), &common.MeteringData{
+		Timestamp: timestamp,
+		Category:  "tidb",
+		SelfID:    "server1",
+		Data: []map[string]interface{}{
+			{"logical_cluster_id": "lc1", "ru": &common.MeteringValue{Value: 1, Unit: "RU"}},
+		},
+	}))
+}
+
+func writeTestMeta(t *testing.T, provider storage.ObjectStorageProvider, modifyTS int64) {
+	w := metawriter.NewMetaWriter(provider, config.DefaultConfig())
+	defer w.Close()
+	require.NoError(t, w.Write(context.Background(), &common.MetaData{
+		ClusterID: "cluster001",
+		Type:      common.MetaTypeLogic,
+		ModifyTS:  modifyTS,
+		Metadata:  map[string]interface{}{"region": "us-west-2"},
+	}))
+}
+
+func readAll(t *testing.T, provider storage.ObjectStorageProvider, path string) []byte {
+	rc, err := provider.Download(context.Background(), path)
+	require.NoError(t, err)
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	return data
+}
+
+func newDestination(name string, provider storage.ObjectStorageProvider) Destination {
+	return Destination{
+		Name:        name,
+		Provider:    provider,
+		Checkpoints: relay.NewProviderCheckpointStore(storage.NewMemoryProvider(), "replicator/"+name+".json"),
+	}
+}
+
+func TestReplicator_RunOnce(t *testing.T) {
+	source := storage.NewMemoryProvider()
+	writeTestData(t, source, start)
+	writeTestData(t, source, start+60)
+	writeTestMeta(t, source, start)
+
+	reg := prometheus.NewRegistry()
+	cfg := config.DefaultConfig().WithMetricsRegistry(reg)
+
+	east := storage.NewMemoryProvider()
+	west := storage.NewMemoryProvider()
+	var westCalls storage.CallCounter
+	r := NewReplicator(source, []Destination{newDestination("east", east), newDestination("west", storage.NewCountingProvider(west, &westCalls))}, cfg, &Config{
+		StartTimestamp: start,
+		Lag:            time.Minute,
+	})
+	ctx := context.Background()
+
+	// now - lag covers start and start+60
+	results, err := r.RunOnce(ctx, time.Unix(start+150, 0))
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	for i, destination := range []storage.ObjectStorageProvider{east, west} {
+		assert.Len(t, results[i].Metering, 2)
+		assert.Equal(t, 1, results[i].MetaCopied)
+		assert.Equal(t, 30*time.Second, results[i].Lag)
+
+		for _, path := range []string{
+			"metering/ru/1755849600/tidb/pool1/server1-0.json.gz",
+			"metering/ru/1755849660/tidb/pool1/server1-0.json.gz",
+			"metering/meta/logic/cluster001/1755849600.json.gz",
+		} {
+			assert.Equal(t, readAll(t, source, path), readAll(t, destination, path))
+		}
+	}
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP metering_sdk_replication_lag_seconds Age of the newest metering data replicated to each destination.
+# TYPE metering_sdk_replication_lag_seconds gauge
+metering_sdk_replication_lag_seconds{destination="east"} 30
+metering_sdk_replication_lag_seconds{destination="west"} 30
+`), "metering_sdk_replication_lag_seconds"))
+
+	// Only new data is copied
+	writeTestMeta(t, source, start+120)
+	heads := westCalls.Stats().Heads
+	results, err = r.RunOnce(ctx, time.Unix(start+150, 0))
+	require.NoError(t, err)
+	assert.Empty(t, results[0].Metering)
+	assert.Equal(t, 1, results[0].MetaCopied)
+	assert.Equal(t, heads, westCalls.Stats().Heads, "metadata is diffed against a listing, not checked one by one")
+}
+
+func TestReplicator_DestinationFailure(t *testing.T) {
+	source := storage.NewMemoryProvider()
+	writeTestData(t, source, start)
+	writeTestMeta(t, source, start)
+
+	healthy := storage.NewMemoryProvider()
+	failing := storage.NewFaultyProvider(storage.NewMemoryProvider(), storage.FaultConfig{
+		Operations: []storage.FaultOperation{storage.FaultOpUpload},
+		ErrorRate:  1,
+	})
+	r := NewReplicator(source, []Destination{newDestination("failing", failing), newDestination("healthy", healthy)}, nil, &Config{
+		StartTimestamp: start,
+		Lag:            time.Minute,
+	})
+
+	// The failing destination doesn't hold back the healthy one
+	results, err := r.RunOnce(context.Background(), time.Unix(start+120, 0))
+	require.ErrorIs(t, err, storage.ErrInjectedFailure)
+	assert.ErrorContains(t, err, "destination failing")
+	require.Len(t, results, 2)
+	assert.Error(t, results[0].Err)
+	assert.Equal(t, 120*time.Second, results[0].Lag)
+	assert.NoError(t, results[1].Err)
+	assert.Len(t, results[1].Metering, 2)
+	assert.Equal(t, 1, results[1].MetaCopied)
+	assert.Equal(t, time.Duration(0), results[1].Lag)
+}
@@ -0,0 +1,291 @@
+// Package retention deletes or archives metering and metadata files older than a retention period,
+// so buckets don't grow without bound.
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/metering_sdk/layout"
+	"github.com/pingcap/metering_sdk/logging"
+	"github.com/pingcap/metering_sdk/storage"
+	"go.uber.org/zap"
+)
+
+// DefaultConcurrency default number of files processed in parallel
+const DefaultConcurrency = 4
+
+// DefaultPrefixes roots scanned for expired files: metering data, metadata, aggregates and compacted files
+var DefaultPrefixes = []string{"metering/ru/", "metering/meta/", "metering/agg/", "metering/compacted/"}
+
+// timestampDirRoots roots of files stored under a timestamp directory, aggregates and compacted files
+var timestampDirRoots = []string{"metering/agg/", "metering/compacted/"}
+
+// Action what happens to expired files
+type Action string
+
+const (
+	// ActionDelete deletes expired files
+	ActionDelete Action = "delete"
+	// ActionArchive copies expired files to Policy.Archive under the same path, then deletes them
+	ActionArchive Action = "archive"
+)
+
+// Policy retention policy
+type Policy struct {
+	// MaxAge files whose data timestamp is older than this are expired, e.g. 90 * 24 * time.Hour
+	MaxAge time.Duration
+	// Action applied to expired files, default ActionDelete
+	Action Action
+	// Archive destination of ActionArchive
+	Archive storage.ObjectStorageProvider
+	// Prefixes roots to scan, default DefaultPrefixes
+	Prefixes []string
+	// Layout path layout of metering files, nil uses layout.Default()
+	Layout *layout.Layout
+	// DryRun only reports the files that would be expired
+	DryRun bool
+	// Concurrency number of files processed in parallel, default DefaultConcurrency
+	Concurrency int
+	// Progress is called after each expired file is processed, optional. Calls are serialized
+	Progress func(Progress)
+	// Logger log instance, if nil will use default nop logger
+	Logger *zap.Logger
+}
+
+// Progress reports the processing of one expired file
+type Progress struct {
+	Path  string `json:"path"`            // processed file
+	Done  int    `json:"done"`            // expired files processed so far
+	Total int    `json:"total"`           // expired files found
+	Err   error  `json:"error,omitempty"` // failure, nil on success
+}
+
+// Result summary of one retention run
+type Result struct {
+	Scanned  int      `json:"scanned"`         // files listed
+	Expired  int      `json:"expired"`         // files older than MaxAge
+	Deleted  int      `json:"deleted"`         // files deleted
+	Archived int      `json:"archived"`        // files archived before deletion
+	Failed   int      `json:"failed"`          // files that could not be processed
+	Skipped  int      `json:"skipped"`         // files without a recognizable timestamp
+	DryRun   bool     `json:"dry_run"`         // nothing was changed
+	Paths    []string `json:"paths,omitempty"` // expired files, only reported in dry-run mode
+}
+
+// RetentionManager applies a retention policy to a provider
+type RetentionManager struct {
+	provider storage.ObjectStorageProvider
+	policy   Policy
+	logger   *zap.Logger
+}
+
+// NewRetentionManager creates a retention manager, validating policy
+func NewRetentionManager(provider storage.ObjectStorageProvider, policy *Policy) (*RetentionManager, error) {
+	if provider == nil {
+		return nil, errors.New("provider is required")
+	}
+	if policy == nil || policy.MaxAge <= 0 {
+		return nil, errors.New("policy with a positive MaxAge is required")
+	}
+
+	p := *policy
+	if p.Action == "" {
+		p.Action = ActionDelete
+	}
+	switch p.Action {
+	case ActionDelete:
+	case ActionArchive:
+		if p.Archive == nil {
+			return nil, errors.New("archive provider is required for the archive action")
+		}
+	default:
+		return nil, fmt.Errorf("unknown retention action %q", p.Action)
+	}
+	if len(p.Prefixes) == 0 {
+		p.Prefixes = DefaultPrefixes
+	}
+	if p.Layout == nil {
+		p.Layout = layout.Default()
+	}
+	if p.Concurrency <= 0 {
+		p.Concurrency = DefaultConcurrency
+	}
+	logger := p.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return &RetentionManager{provider: provider, policy: p, logger: logger}, nil
+}
+
+// Run expires every file whose data timestamp is before now minus MaxAge. Failures of single files
+// don't stop the run, they are counted in the result and the first one is returned.
+func (m *RetentionManager) Run(ctx context.Context, now time.Time) (*Result, error) {
+	cutoff := now.Add(-m.policy.MaxAge).Unix()
+	result := &Result{DryRun: m.policy.DryRun}
+
+	var expired []string
+	for _, prefix := range m.policy.Prefixes {
+		paths, err := m.provider.List(ctx, prefix)
+		if err != nil {
+			return result, fmt.Errorf("failed to list files with prefix %s: %w", prefix, err)
+		}
+		result.Scanned += len(paths)
+		for _, p := range paths {
+			// Providers with a configured prefix list keys with it, it is added again by Delete and Download
+			if _, rest, ok := strings.Cut(p, prefix); ok {
+				p = prefix + rest
+			}
+			timestamp, ok := m.timestamp(p)
+			if !ok {
+				result.Skipped++
+				continue
+			}
+			if timestamp < cutoff {
+				expired = append(expired, p)
+			}
+		}
+	}
+	result.Expired = len(expired)
+
+	if m.policy.DryRun {
+		result.Paths = expired
+		m.logger.Info("Retention dry run finished",
+			zap.Int("scanned", result.Scanned),
+			zap.Int("expired", result.Expired),
+		)
+		return result, nil
+	}
+
+	firstErr := m.expire(ctx, expired, result)
+
+	m.logger.Info("Retention run finished",
+		zap.Int("scanned", result.Scanned),
+		zap.Int("expired", result.Expired),
+		zap.Int("deleted", result.Deleted),
+		zap.Int("archived", result.Archived),
+		zap.Int("failed", result.Failed),
+	)
+	if firstErr != nil {
+		return result, fmt.Errorf("%d of %d expired files failed: %w", result.Failed, result.Expired, firstErr)
+	}
+	return result, nil
+}
+
+// expire processes paths with the configured concurrency, updating result, and returns the first failure
+func (m *RetentionManager) expire(ctx context.Context, paths []string, result *Result) error {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	work := make(chan string)
+
+	for i := 0; i < m.policy.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range work {
+				archived, err := m.expireFile(ctx, p)
+
+				mu.Lock()
+				if err != nil {
+					result.Failed++
+					if firstErr == nil {
+						firstErr = err
+					}
+					m.logger.Warn("Failed to expire file", logging.Path(p), zap.Error(err))
+				} else {
+					result.Deleted++
+					if archived {
+						result.Archived++
+					}
+				}
+				if m.policy.Progress != nil {
+					m.policy.Progress(Progress{
+						Path:  p,
+						Done:  result.Deleted + result.Failed,
+						Total: len(paths),
+						Err:   err,
+					})
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, p := range paths {
+		if ctx.Err() != nil {
+			break
+		}
+		work <- p
+	}
+	close(work)
+	wg.Wait()
+
+	if firstErr == nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return firstErr
+}
+
+// expireFile archives the file if configured and deletes it, reporting whether it was archived
+func (m *RetentionManager) expireFile(ctx context.Context, p string) (bool, error) {
+	archived := false
+	if m.policy.Action == ActionArchive {
+		if err := m.archive(ctx, p); err != nil {
+			return false, err
+		}
+		archived = true
+	}
+	if err := m.provider.Delete(ctx, p); err != nil {
+		return archived, fmt.Errorf("failed to delete %s: %w", p, err)
+	}
+	return archived, nil
+}
+
+// archive copies the file at p to the archive provider
+func (m *RetentionManager) archive(ctx context.Context, p string) error {
+	rc, err := m.provider.Download(ctx, p)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %w", p, err)
+	}
+	defer rc.Close()
+	if err := m.policy.Archive.Upload(ctx, p, rc); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", p, err)
+	}
+	return nil
+}
+
+// timestamp extracts the data timestamp of a file: metering files are parsed with the layout,
+// metadata files are named {timestamp}.json.gz, aggregates and compacted files are stored under a
+// timestamp directory. Other numeric directories, e.g. numeric shared pool IDs, are never taken for one.
+func (m *RetentionManager) timestamp(p string) (int64, bool) {
+	if fields, err := m.policy.Layout.Parse(p); err == nil {
+		return fields.Timestamp, true
+	}
+	if timestamp, err := strconv.ParseInt(strings.TrimSuffix(path.Base(p), ".json.gz"), 10, 64); err == nil {
+		return timestamp, true
+	}
+	var rest string
+	for _, root := range timestampDirRoots {
+		if r, ok := strings.CutPrefix(p, root); ok {
+			rest = r
+			break
+		}
+	}
+	segments := strings.Split(rest, "/")
+	for i := len(segments) - 2; i >= 0; i-- {
+		if timestamp, err := strconv.ParseInt(segments[i], 10, 64); err == nil {
+			return timestamp, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,162 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pingcap/metering_sdk/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPaths returns a metering, a metadata and an aggregate file path of timestamp
+func testPaths(timestamp int64) []string {
+	return []string{
+		fmt.Sprintf("metering/ru/%d/tidbserver/pool1/server001-0.json.gz", timestamp),
+		fmt.Sprintf("metering/meta/logic/cluster001/%d.json.gz", timestamp),
+		fmt.Sprintf("metering/agg/hour/%d/tidbserver.json.gz", timestamp),
+	}
+}
+
+func TestRetentionManager(t *testing.T) {
+	now := time.Unix(1755850380, 0)
+	expired := testPaths(now.Add(-40*24*time.Hour).Unix() / 60 * 60)
+	kept := testPaths(now.Add(-time.Hour).Unix() / 60 * 60)
+	ctx := context.Background()
+
+	setup := func(t *testing.T) storage.ObjectStorageProvider {
+		provider := storage.NewMemoryProvider()
+		for _, path := range append(append([]string{"metering/ru/README"}, expired...), kept...) {
+			require.NoError(t, provider.Upload(ctx, path, strings.NewReader(path)))
+		}
+		return provider
+	}
+	assertExists := func(t *testing.T, provider storage.ObjectStorageProvider, paths []string, want bool) {
+		for _, path := range paths {
+			exists, err := provider.Exists(ctx, path)
+			require.NoError(t, err)
+			assert.Equal(t, want, exists, path)
+		}
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		provider := setup(t)
+		manager, err := NewRetentionManager(provider, &Policy{MaxAge: 30 * 24 * time.Hour, DryRun: true})
+		require.NoError(t, err)
+
+		result, err := manager.Run(ctx, now)
+		require.NoError(t, err)
+		assert.Equal(t, 7, result.Scanned)
+		assert.Equal(t, 3, result.Expired)
+		assert.Equal(t, 1, result.Skipped)
+		assert.Zero(t, result.Deleted)
+		sort.Strings(result.Paths)
+		want := append([]string(nil), expired...)
+		sort.Strings(want)
+		assert.Equal(t, want, result.Paths)
+		assertExists(t, provider, expired, true)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		provider := setup(t)
+		var progress []Progress
+		manager, err := NewRetentionManager(provider, &Policy{
+			MaxAge:      30 * 24 * time.Hour,
+			Concurrency: 2,
+			Progress:    func(p Progress) { progress = append(progress, p) },
+		})
+		require.NoError(t, err)
+
+		result, err := manager.Run(ctx, now)
+		require.NoError(t, err)
+		assert.Equal(t, 3, result.Deleted)
+		assert.Zero(t, result.Archived)
+		assertExists(t, provider, expired, false)
+		assertExists(t, provider, kept, true)
+
+		require.Len(t, progress, 3)
+		assert.Equal(t, Progress{Path: progress[2].Path, Done: 3, Total: 3}, progress[2])
+	})
+
+	t.Run("archive", func(t *testing.T) {
+		provider, archive := setup(t), storage.NewMemoryProvider()
+		manager, err := NewRetentionManager(provider, &Policy{
+			MaxAge:  30 * 24 * time.Hour,
+			Action:  ActionArchive,
+			Archive: archive,
+		})
+		require.NoError(t, err)
+
+		result, err := manager.Run(ctx, now)
+		require.NoError(t, err)
+		assert.Equal(t, 3, result.Archived)
+		assertExists(t, provider, expired, false)
+		assertExists(t, archive, expired, true)
+		assertExists(t, archive, kept, false)
+	})
+
+	t.Run("invalid policy", func(t *testing.T) {
+		provider := storage.NewMemoryProvider()
+		_, err := NewRetentionManager(provider, &Policy{})
+		assert.Error(t, err)
+		_, err = NewRetentionManager(provider, &Policy{MaxAge: time.Hour, Action: ActionArchive})
+		assert.Error(t, err)
+		_, err = NewRetentionManager(provider, &Policy{MaxAge: time.Hour, Action: "compress"})
+		assert.Error(t, err)
+	})
+}
+
+// prefixedListProvider lists keys with a provider prefix, like S3 or LocalFS with a configured prefix
+type prefixedListProvider struct {
+	*storage.MemoryProvider
+}
+
+func (p *prefixedListProvider) List(ctx context.Context, prefix string) ([]string, error) {
+	paths, err := p.MemoryProvider.List(ctx, prefix)
+	for i := range paths {
+		paths[i] = "tenant/" + paths[i]
+	}
+	return paths, err
+}
+
+func TestRetentionManagerPrefixedKeys(t *testing.T) {
+	now := time.Unix(1755850380, 0)
+	old := now.Add(-40*24*time.Hour).Unix() / 60 * 60
+	recent := now.Add(-time.Hour).Unix() / 60 * 60
+	ctx := context.Background()
+	provider := &prefixedListProvider{storage.NewMemoryProvider()}
+	expired := []string{
+		fmt.Sprintf("metering/ru/%d/tidbserver/1000/server001-0.json.gz", old),
+		fmt.Sprintf("metering/agg/hour/%d/tidbserver.json.gz", old),
+	}
+	kept := []string{
+		// The numeric shared pool ID is not the data timestamp
+		fmt.Sprintf("metering/ru/%d/tidbserver/1000/server001-0.json.gz", recent),
+		// Neither of a file the layout can't parse
+		fmt.Sprintf("metering/ru/%d/tidbserver/1000/notes.txt", recent),
+	}
+	for _, path := range append(append([]string(nil), expired...), kept...) {
+		require.NoError(t, provider.Upload(ctx, path, strings.NewReader(path)))
+	}
+
+	manager, err := NewRetentionManager(provider, &Policy{MaxAge: 30 * 24 * time.Hour})
+	require.NoError(t, err)
+	result, err := manager.Run(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Deleted)
+	assert.Equal(t, 1, result.Skipped)
+	for _, path := range expired {
+		exists, err := provider.Exists(ctx, path)
+		require.NoError(t, err)
+		assert.False(t, exists, path)
+	}
+	for _, path := range kept {
+		exists, err := provider.Exists(ctx, path)
+		require.NoError(t, err)
+		assert.True(t, exists, path)
+	}
+}
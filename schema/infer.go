@@ -0,0 +1,262 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/pingcap/metering_sdk/common"
+)
+
+// maxTrackedValues limits the distinct values tracked per field for cardinality estimation
+const maxTrackedValues = 10000
+
+// FieldTypeUnknown is reported for values that match no FieldType, e.g. nested objects
+const FieldTypeUnknown FieldType = "unknown"
+
+// FieldReport describes the observed values of one field of a category
+type FieldReport struct {
+	Name              string              `json:"name"`                    // field name
+	Types             map[FieldType]int64 `json:"types"`                   // number of values seen per type
+	Units             []string            `json:"units,omitempty"`         // units seen on metering values, sorted
+	Present           int64               `json:"present"`                 // entries with a non-null value
+	NullRate          float64             `json:"null_rate"`               // fraction of entries where the field was missing or null
+	Cardinality       int                 `json:"cardinality"`             // number of distinct values
+	CardinalityCapped bool                `json:"cardinality_capped"`      // Cardinality stopped counting at the tracking limit
+	Examples          []string            `json:"examples,omitempty"`      // up to three example values
+	DominantType      FieldType           `json:"dominant_type,omitempty"` // most frequently seen type
+}
+
+// CategoryReport describes the observed Data entries of a category
+type CategoryReport struct {
+	Category string         `json:"category"` // service category
+	Entries  int64          `json:"entries"`  // number of Data entries observed
+	Fields   []*FieldReport `json:"fields"`   // observed fields, sorted by name
+}
+
+// Report is the result of schema inference over observed data
+type Report struct {
+	Categories []*CategoryReport `json:"categories"` // observed categories, sorted by name
+}
+
+// Category returns the report for category, or nil if it wasn't observed
+func (r *Report) Category(category string) *CategoryReport {
+	for _, c := range r.Categories {
+		if c.Category == category {
+			return c
+		}
+	}
+	return nil
+}
+
+// Schema builds a schema from the observed data, suitable as a starting point for registration.
+// Fields present in every entry are required, types are the dominant observed type, and the units
+// of metering values are restricted to the observed ones.
+func (c *CategoryReport) Schema() *Schema {
+	s := &Schema{Category: c.Category}
+	for _, f := range c.Fields {
+		if f.DominantType == FieldTypeUnknown || f.Present == 0 {
+			s.AllowUnknown = true
+			continue
+		}
+		field := Field{
+			Name:     f.Name,
+			Type:     f.DominantType,
+			Required: f.Present == c.Entries,
+		}
+		if field.Type == FieldTypeMeteringValue {
+			field.Units = append([]string(nil), f.Units...)
+		}
+		s.Fields = append(s.Fields, field)
+	}
+	return s
+}
+
+// Drift compares the observed data with a registered schema and describes every difference,
+// e.g. new fields, type changes, new units or required fields that were missing
+func (c *CategoryReport) Drift(s *Schema) []string {
+	var drift []string
+	observed := make(map[string]*FieldReport, len(c.Fields))
+	for _, f := range c.Fields {
+		observed[f.Name] = f
+	}
+
+	for _, field := range s.Fields {
+		f, ok := observed[field.Name]
+		if !ok || f.Present == 0 {
+			if field.Required {
+				drift = append(drift, fmt.Sprintf("required field %s was never observed", field.Name))
+			}
+			continue
+		}
+		if field.Required && f.Present < c.Entries {
+			drift = append(drift, fmt.Sprintf("required field %s is missing in %.1f%% of entries", field.Name, f.NullRate*100))
+		}
+		for typ, count := range f.Types {
+			// observed numbers are not split by kind, integer fields are checked when writing
+			if typ != field.Type && !(typ == FieldTypeNumber && field.Type == FieldTypeInteger) {
+				drift = append(drift, fmt.Sprintf("field %s has %d %s values, schema type is %s", field.Name, count, typ, field.Type))
+			}
+		}
+		if field.Type == FieldTypeMeteringValue && len(field.Units) > 0 {
+			for _, unit := range f.Units {
+				if !slices.Contains(field.Units, unit) {
+					drift = append(drift, fmt.Sprintf("field %s has new unit %q", field.Name, unit))
+				}
+			}
+		}
+	}
+
+	if !s.AllowUnknown {
+		for _, f := range c.Fields {
+			if _, ok := s.field(f.Name); !ok {
+				drift = append(drift, fmt.Sprintf("new field %s", f.Name))
+			}
+		}
+	}
+	sort.Strings(drift)
+	return drift
+}
+
+// field returns the schema field with the given name
+func (s *Schema) field(name string) (*Field, bool) {
+	for i := range s.Fields {
+		if s.Fields[i].Name == name {
+			return &s.Fields[i], true
+		}
+	}
+	return nil, false
+}
+
+// fieldStats accumulates observations of a single field
+type fieldStats struct {
+	types    map[FieldType]int64
+	units    map[string]struct{}
+	present  int64
+	values   map[string]struct{}
+	capped   bool
+	examples []string
+}
+
+// categoryStats accumulates observations of a single category
+type categoryStats struct {
+	entries int64
+	fields  map[string]*fieldStats
+}
+
+// Inferrer infers schemas from observed Data entries, it is safe for concurrent use
+type Inferrer struct {
+	mu         sync.Mutex
+	categories map[string]*categoryStats
+}
+
+// NewInferrer creates an empty schema inferrer
+func NewInferrer() *Inferrer {
+	return &Inferrer{categories: make(map[string]*categoryStats)}
+}
+
+// Observe records a single Data entry of category
+func (i *Inferrer) Observe(category string, entry map[string]interface{}) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	c, ok := i.categories[category]
+	if !ok {
+		c = &categoryStats{fields: make(map[string]*fieldStats)}
+		i.categories[category] = c
+	}
+	c.entries++
+
+	for name, raw := range entry {
+		f, ok := c.fields[name]
+		if !ok {
+			f = &fieldStats{
+				types:  make(map[FieldType]int64),
+				units:  make(map[string]struct{}),
+				values: make(map[string]struct{}),
+			}
+			c.fields[name] = f
+		}
+		if raw == nil {
+			continue
+		}
+		f.present++
+
+		typ, key, unit := classify(raw)
+		f.types[typ]++
+		if unit != "" {
+			f.units[unit] = struct{}{}
+		}
+		if _, seen := f.values[key]; !seen {
+			if len(f.values) < maxTrackedValues {
+				f.values[key] = struct{}{}
+				if len(f.examples) < 3 {
+					f.examples = append(f.examples, key)
+				}
+			} else {
+				f.capped = true
+			}
+		}
+	}
+}
+
+// Report summarizes everything observed so far
+func (i *Inferrer) Report() *Report {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	report := &Report{}
+	for category, c := range i.categories {
+		cr := &CategoryReport{Category: category, Entries: c.entries}
+		for name, f := range c.fields {
+			fr := &FieldReport{
+				Name:              name,
+				Types:             make(map[FieldType]int64, len(f.types)),
+				Present:           f.present,
+				NullRate:          float64(c.entries-f.present) / float64(c.entries),
+				Cardinality:       len(f.values),
+				CardinalityCapped: f.capped,
+				Examples:          append([]string(nil), f.examples...),
+			}
+			var dominant int64
+			for typ, count := range f.types {
+				fr.Types[typ] = count
+				if count > dominant || (count == dominant && typ < fr.DominantType) {
+					dominant, fr.DominantType = count, typ
+				}
+			}
+			for unit := range f.units {
+				fr.Units = append(fr.Units, unit)
+			}
+			sort.Strings(fr.Units)
+			cr.Fields = append(cr.Fields, fr)
+		}
+		sort.Slice(cr.Fields, func(a, b int) bool { return cr.Fields[a].Name < cr.Fields[b].Name })
+		report.Categories = append(report.Categories, cr)
+	}
+	sort.Slice(report.Categories, func(a, b int) bool {
+		return report.Categories[a].Category < report.Categories[b].Category
+	})
+	return report
+}
+
+// classify returns the type of a field value, a key identifying the value and the unit of metering values
+func classify(raw interface{}) (FieldType, string, string) {
+	switch v := raw.(type) {
+	case string:
+		return FieldTypeString, v, ""
+	case bool:
+		return FieldTypeBool, fmt.Sprint(v), ""
+	case int, int32, int64, uint, uint32, uint64, float32, float64, json.Number:
+		return FieldTypeNumber, fmt.Sprint(v), ""
+	}
+	if value, ok := common.ParseMeteringValue(raw); ok {
+		if value.IsFloat() {
+			return FieldTypeMeteringValue, fmt.Sprint(value.Float64()), value.Unit
+		}
+		return FieldTypeMeteringValue, fmt.Sprint(value.Value), value.Unit
+	}
+	return FieldTypeUnknown, fmt.Sprint(raw), ""
+}
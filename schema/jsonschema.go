@@ -0,0 +1,117 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// jsonSchema is the subset of JSON Schema understood by RegisterJSONSchema
+type jsonSchema struct {
+	Type                 string                 `json:"type"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Enum                 []interface{}          `json:"enum"`
+	Const                interface{}            `json:"const"`
+}
+
+// RegisterJSONSchema registers a schema for category from a JSON Schema document describing one Data entry.
+//
+// Supported keywords are type, properties, required and additionalProperties on the entry, and
+// type on properties. Properties of type "string", "number", "integer" and "boolean" map to the
+// matching field type, integers must be whole numbers. A property of type "object" with "value" and
+// "unit" properties is a metering value, the allowed units are taken from the enum or const of "unit".
+func (r *Registry) RegisterJSONSchema(category string, data []byte) error {
+	var doc *jsonSchema
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse JSON schema for category %s: %w", category, err)
+	}
+	s, err := fromJSONSchema(category, doc)
+	if err != nil {
+		return err
+	}
+	return r.Register(s)
+}
+
+// fromJSONSchema converts a parsed JSON Schema document into a Schema
+func fromJSONSchema(category string, doc *jsonSchema) (*Schema, error) {
+	if doc == nil {
+		return nil, fmt.Errorf("JSON schema for category %s must be an object, got null", category)
+	}
+	if doc.Type != "" && doc.Type != "object" {
+		return nil, fmt.Errorf("JSON schema for category %s must describe an object, got %q", category, doc.Type)
+	}
+
+	required := make(map[string]bool, len(doc.Required))
+	for _, name := range doc.Required {
+		if _, ok := doc.Properties[name]; !ok {
+			return nil, fmt.Errorf("JSON schema for category %s requires undefined property %s", category, name)
+		}
+		required[name] = true
+	}
+
+	names := make([]string, 0, len(doc.Properties))
+	for name := range doc.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	s := &Schema{
+		Category:     category,
+		Fields:       make([]Field, 0, len(names)),
+		AllowUnknown: doc.AdditionalProperties == nil || *doc.AdditionalProperties,
+	}
+	for _, name := range names {
+		prop := doc.Properties[name]
+		if prop == nil {
+			return nil, fmt.Errorf("JSON schema for category %s: property %s must be an object, got null", category, name)
+		}
+		field := Field{Name: name, Required: required[name]}
+		switch prop.Type {
+		case "string":
+			field.Type = FieldTypeString
+		case "number":
+			field.Type = FieldTypeNumber
+		case "integer":
+			field.Type = FieldTypeInteger
+		case "boolean":
+			field.Type = FieldTypeBool
+		case "object":
+			unit, hasUnit := prop.Properties["unit"]
+			if _, hasValue := prop.Properties["value"]; !hasValue || !hasUnit {
+				return nil, fmt.Errorf("JSON schema for category %s: object property %s must define value and unit", category, name)
+			}
+			field.Type = FieldTypeMeteringValue
+			units, err := unitsOf(unit)
+			if err != nil {
+				return nil, fmt.Errorf("JSON schema for category %s: property %s: %w", category, name, err)
+			}
+			field.Units = units
+		default:
+			return nil, fmt.Errorf("JSON schema for category %s: unsupported type %q for property %s", category, prop.Type, name)
+		}
+		s.Fields = append(s.Fields, field)
+	}
+	return s, nil
+}
+
+// unitsOf extracts the allowed units from the enum or const of a unit property
+func unitsOf(unit *jsonSchema) ([]string, error) {
+	if unit == nil {
+		return nil, fmt.Errorf("unit must be an object, got null")
+	}
+	values := unit.Enum
+	if unit.Const != nil {
+		values = append(values, unit.Const)
+	}
+	units := make([]string, 0, len(values))
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("unit must be a string, got %T", v)
+		}
+		units = append(units, s)
+	}
+	return units, nil
+}
@@ -0,0 +1,197 @@
+// Package schema provides typed validation of metering Data entries per category.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/metering_sdk/common"
+)
+
+// FieldType type of a Data entry field
+type FieldType string
+
+const (
+	// FieldTypeString string field, e.g. logical_cluster_id
+	FieldTypeString FieldType = "string"
+	// FieldTypeNumber plain JSON number
+	FieldTypeNumber FieldType = "number"
+	// FieldTypeInteger plain JSON number without fractional part
+	FieldTypeInteger FieldType = "integer"
+	// FieldTypeBool boolean field
+	FieldTypeBool FieldType = "bool"
+	// FieldTypeMeteringValue common.MeteringValue field
+	FieldTypeMeteringValue FieldType = "metering_value"
+)
+
+// Field describes one field of a Data entry
+type Field struct {
+	Name     string    `json:"name"`            // field name
+	Type     FieldType `json:"type"`            // field type
+	Required bool      `json:"required"`        // whether the field must be present
+	Units    []string  `json:"units,omitempty"` // allowed units for metering values, empty allows any
+}
+
+// Schema describes the Data entries of a category
+type Schema struct {
+	Category     string  `json:"category"`      // service category the schema applies to
+	Fields       []Field `json:"fields"`        // known fields
+	AllowUnknown bool    `json:"allow_unknown"` // whether fields not listed in Fields are accepted
+}
+
+// Validate validates a single Data entry against the schema
+func (s *Schema) Validate(entry map[string]interface{}) error {
+	known := make(map[string]struct{}, len(s.Fields))
+	for _, field := range s.Fields {
+		known[field.Name] = struct{}{}
+		raw, ok := entry[field.Name]
+		if !ok || raw == nil {
+			if field.Required {
+				return fmt.Errorf("missing required field %s", field.Name)
+			}
+			continue
+		}
+		if err := field.validate(raw); err != nil {
+			return err
+		}
+	}
+
+	if !s.AllowUnknown {
+		var unknown []string
+		for name := range entry {
+			if _, ok := known[name]; !ok {
+				unknown = append(unknown, name)
+			}
+		}
+		if len(unknown) > 0 {
+			sort.Strings(unknown)
+			return fmt.Errorf("unknown fields: %s", strings.Join(unknown, ", "))
+		}
+	}
+	return nil
+}
+
+// validate checks the type of a field value
+func (f *Field) validate(raw interface{}) error {
+	switch f.Type {
+	case FieldTypeString:
+		if _, ok := raw.(string); !ok {
+			return fmt.Errorf("field %s must be a string, got %T", f.Name, raw)
+		}
+	case FieldTypeBool:
+		if _, ok := raw.(bool); !ok {
+			return fmt.Errorf("field %s must be a bool, got %T", f.Name, raw)
+		}
+	case FieldTypeNumber:
+		switch raw.(type) {
+		case int, int32, int64, uint, uint32, uint64, float32, float64, json.Number:
+		default:
+			return fmt.Errorf("field %s must be a number, got %T", f.Name, raw)
+		}
+	case FieldTypeInteger:
+		if !isInteger(raw) {
+			return fmt.Errorf("field %s must be an integer, got %v", f.Name, raw)
+		}
+	case FieldTypeMeteringValue:
+		value, ok := common.ParseMeteringValue(raw)
+		if !ok {
+			return fmt.Errorf("field %s must be a metering value, got %T", f.Name, raw)
+		}
+		if len(f.Units) > 0 && !slices.Contains(f.Units, value.Unit) {
+			return fmt.Errorf("field %s has unit %q, allowed units: %s", f.Name, value.Unit, strings.Join(f.Units, ", "))
+		}
+	default:
+		return fmt.Errorf("field %s has unsupported type %q", f.Name, f.Type)
+	}
+	return nil
+}
+
+// isInteger reports whether raw is a number without fractional part
+func isInteger(raw interface{}) bool {
+	var f float64
+	switch v := raw.(type) {
+	case int, int32, int64, uint, uint32, uint64:
+		return true
+	case float32:
+		f = float64(v)
+	case float64:
+		f = v
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return true
+		}
+		parsed, err := v.Float64()
+		if err != nil {
+			return false
+		}
+		f = parsed
+	default:
+		return false
+	}
+	return !math.IsInf(f, 0) && f == math.Trunc(f)
+}
+
+// Registry holds category schemas, it is safe for concurrent use
+type Registry struct {
+	mu      sync.RWMutex
+	schemas map[string]*Schema
+}
+
+// NewRegistry creates an empty schema registry
+func NewRegistry() *Registry {
+	return &Registry{schemas: make(map[string]*Schema)}
+}
+
+// Register registers a schema for its category, replacing any previous one
+func (r *Registry) Register(s *Schema) error {
+	if s == nil || s.Category == "" {
+		return fmt.Errorf("schema category cannot be empty")
+	}
+	names := make(map[string]struct{}, len(s.Fields))
+	for _, field := range s.Fields {
+		if field.Name == "" {
+			return fmt.Errorf("schema for category %s has a field without name", s.Category)
+		}
+		if _, dup := names[field.Name]; dup {
+			return fmt.Errorf("schema for category %s has duplicate field %s", s.Category, field.Name)
+		}
+		names[field.Name] = struct{}{}
+		switch field.Type {
+		case FieldTypeString, FieldTypeNumber, FieldTypeInteger, FieldTypeBool, FieldTypeMeteringValue:
+		default:
+			return fmt.Errorf("schema for category %s has unsupported type %q for field %s", s.Category, field.Type, field.Name)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.schemas[s.Category] = s
+	return nil
+}
+
+// Get returns the schema registered for category
+func (r *Registry) Get(category string) (*Schema, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.schemas[category]
+	return s, ok
+}
+
+// Validate validates all Data entries of a category. Categories without a schema are accepted.
+func (r *Registry) Validate(category string, data []map[string]interface{}) error {
+	s, ok := r.Get(category)
+	if !ok {
+		return nil
+	}
+	for i, entry := range data {
+		if err := s.Validate(entry); err != nil {
+			return fmt.Errorf("data entry %d does not match schema for category %s: %w", i, category, err)
+		}
+	}
+	return nil
+}
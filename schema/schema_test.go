@@ -0,0 +1,144 @@
+package schema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTiDBSchema() *Schema {
+	return &Schema{
+		Category: "tidb",
+		Fields: []Field{
+			{Name: "logical_cluster_id", Type: FieldTypeString, Required: true},
+			{Name: "ru", Type: FieldTypeMeteringValue, Required: true, Units: []string{"RU"}},
+			{Name: "sampled", Type: FieldTypeBool},
+		},
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	s := newTiDBSchema()
+
+	tests := []struct {
+		name    string
+		entry   map[string]interface{}
+		wantErr string
+	}{
+		{
+			name:  "valid entry",
+			entry: map[string]interface{}{"logical_cluster_id": "lc1", "ru": &common.MeteringValue{Value: 1, Unit: "RU"}},
+		},
+		{
+			name:  "decoded metering value",
+			entry: map[string]interface{}{"logical_cluster_id": "lc1", "ru": map[string]interface{}{"value": float64(1), "unit": "RU"}, "sampled": true},
+		},
+		{
+			name:    "missing required field",
+			entry:   map[string]interface{}{"logical_cluster_id": "lc1"},
+			wantErr: "missing required field ru",
+		},
+		{
+			name:    "wrong type",
+			entry:   map[string]interface{}{"logical_cluster_id": 1, "ru": &common.MeteringValue{Value: 1, Unit: "RU"}},
+			wantErr: "must be a string",
+		},
+		{
+			name:    "unit not allowed",
+			entry:   map[string]interface{}{"logical_cluster_id": "lc1", "ru": &common.MeteringValue{Value: 1, Unit: "bytes"}},
+			wantErr: `unit "bytes"`,
+		},
+		{
+			name:    "unknown field",
+			entry:   map[string]interface{}{"logical_cluster_id": "lc1", "ru": &common.MeteringValue{Value: 1, Unit: "RU"}, "extra": 1},
+			wantErr: "unknown fields: extra",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.Validate(tt.entry)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	require.NoError(t, registry.Register(newTiDBSchema()))
+
+	assert.Error(t, registry.Register(&Schema{}))
+	assert.Error(t, registry.Register(&Schema{Category: "bad", Fields: []Field{{Name: "a", Type: "date"}}}))
+	assert.Error(t, registry.Register(&Schema{Category: "bad", Fields: []Field{{Name: "a", Type: FieldTypeBool}, {Name: "a", Type: FieldTypeBool}}}))
+
+	// Categories without a schema are accepted
+	assert.NoError(t, registry.Validate("tikv", []map[string]interface{}{{"anything": 1}}))
+
+	err := registry.Validate("tidb", []map[string]interface{}{
+		{"logical_cluster_id": "lc1", "ru": &common.MeteringValue{Value: 1, Unit: "RU"}},
+		{"logical_cluster_id": "lc2"},
+	})
+	assert.ErrorContains(t, err, "data entry 1")
+}
+
+func TestRegisterJSONSchema(t *testing.T) {
+	registry := NewRegistry()
+	doc := `{
+		"type": "object",
+		"required": ["logical_cluster_id", "ru"],
+		"additionalProperties": false,
+		"properties": {
+			"logical_cluster_id": {"type": "string"},
+			"requests": {"type": "integer"},
+			"ru": {
+				"type": "object",
+				"properties": {"value": {"type": "integer"}, "unit": {"enum": ["RU", "kRU"]}}
+			}
+		}
+	}`
+	require.NoError(t, registry.RegisterJSONSchema("tidb", []byte(doc)))
+
+	s, ok := registry.Get("tidb")
+	require.True(t, ok)
+	assert.False(t, s.AllowUnknown)
+	assert.Equal(t, []Field{
+		{Name: "logical_cluster_id", Type: FieldTypeString, Required: true},
+		{Name: "requests", Type: FieldTypeInteger},
+		{Name: "ru", Type: FieldTypeMeteringValue, Required: true, Units: []string{"RU", "kRU"}},
+	}, s.Fields)
+
+	assert.NoError(t, s.Validate(map[string]interface{}{
+		"logical_cluster_id": "lc1",
+		"requests":           json.Number("10"),
+		"ru":                 &common.MeteringValue{Value: 1, Unit: "kRU"},
+	}))
+	assert.NoError(t, s.Validate(map[string]interface{}{
+		"logical_cluster_id": "lc1",
+		"requests":           float64(10),
+		"ru":                 &common.MeteringValue{Value: 1, Unit: "kRU"},
+	}))
+	for _, requests := range []interface{}{json.Number("1.5"), 1.5, "10"} {
+		assert.Error(t, s.Validate(map[string]interface{}{
+			"logical_cluster_id": "lc1",
+			"requests":           requests,
+			"ru":                 &common.MeteringValue{Value: 1, Unit: "kRU"},
+		}), "requests %v", requests)
+	}
+
+	assert.Error(t, registry.RegisterJSONSchema("bad", []byte(`{"type": "array"}`)))
+	assert.Error(t, registry.RegisterJSONSchema("bad", []byte(`{"required": ["a"]}`)))
+	assert.Error(t, registry.RegisterJSONSchema("bad", []byte(`{"properties": {"a": {"type": "object"}}}`)))
+	assert.Error(t, registry.RegisterJSONSchema("bad", []byte(`not json`)))
+
+	// null schemas are rejected instead of dereferenced
+	assert.Error(t, registry.RegisterJSONSchema("bad", []byte(`null`)))
+	assert.Error(t, registry.RegisterJSONSchema("bad", []byte(`{"properties": {"x": null}}`)))
+	assert.Error(t, registry.RegisterJSONSchema("bad", []byte(`{"properties": {"x": {"type": "object", "properties": {"value": {}, "unit": null}}}}`)))
+}
@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+)
+
+// CallStats numbers of storage requests, by the classes object stores bill them in. Failed requests
+// are counted too, they are billed as well.
+type CallStats struct {
+	// Puts uploads, copies and moves
+	Puts int64
+	// Gets downloads
+	Gets int64
+	// Lists listing requests, one per page of up to DefaultListPageSize objects
+	Lists int64
+	// Heads Exists and Stat requests
+	Heads int64
+	// Deletes deletes and moves
+	Deletes int64
+}
+
+// Add returns the sum of s and other
+func (s CallStats) Add(other CallStats) CallStats {
+	return CallStats{
+		Puts:    s.Puts + other.Puts,
+		Gets:    s.Gets + other.Gets,
+		Lists:   s.Lists + other.Lists,
+		Heads:   s.Heads + other.Heads,
+		Deletes: s.Deletes + other.Deletes,
+	}
+}
+
+// Total returns the number of requests of all classes
+func (s CallStats) Total() int64 {
+	return s.Puts + s.Gets + s.Lists + s.Heads + s.Deletes
+}
+
+// CallCounter counts the storage requests made through the providers returned by NewCountingProvider.
+// The zero value is ready to use.
+type CallCounter struct {
+	puts, gets, lists, heads, deletes atomic.Int64
+}
+
+// Stats returns the requests counted so far
+func (c *CallCounter) Stats() CallStats {
+	return CallStats{
+		Puts:    c.puts.Load(),
+		Gets:    c.gets.Load(),
+		Lists:   c.lists.Load(),
+		Heads:   c.heads.Load(),
+		Deletes: c.deletes.Load(),
+	}
+}
+
+// countingProvider counts the requests made to the wrapped provider
+type countingProvider struct {
+	ObjectStorageProvider
+	calls *CallCounter
+}
+
+// conditionalCountingProvider additionally forwards conditional uploads
+type conditionalCountingProvider struct {
+	*countingProvider
+	conditional ConditionalUploader
+}
+
+// NewCountingProvider wraps provider so that its requests are counted in calls, e.g. to estimate the
+// object store API costs of a writer. Requests are counted as a native implementation makes them: a
+// List of n objects counts as one listing per page, and a server-side Move as a copy and a delete.
+// provider is returned unchanged if calls is nil. Conditional uploads, paginated listing, stats, copies
+// and object versions are preserved.
+func NewCountingProvider(provider ObjectStorageProvider, calls *CallCounter) ObjectStorageProvider {
+	if provider == nil || calls == nil {
+		return provider
+	}
+	p := &countingProvider{ObjectStorageProvider: provider, calls: calls}
+	if conditional, ok := provider.(ConditionalUploader); ok {
+		return &conditionalCountingProvider{countingProvider: p, conditional: conditional}
+	}
+	return p
+}
+
+// Upload implements ObjectStorageProvider interface
+func (p *countingProvider) Upload(ctx context.Context, path string, data io.Reader) error {
+	p.calls.puts.Add(1)
+	return p.ObjectStorageProvider.Upload(ctx, path, data)
+}
+
+// Download implements ObjectStorageProvider interface
+func (p *countingProvider) Download(ctx context.Context, path string) (io.ReadCloser, error) {
+	p.calls.gets.Add(1)
+	return p.ObjectStorageProvider.Download(ctx, path)
+}
+
+// Delete implements ObjectStorageProvider interface
+func (p *countingProvider) Delete(ctx context.Context, path string) error {
+	p.calls.deletes.Add(1)
+	return p.ObjectStorageProvider.Delete(ctx, path)
+}
+
+// Exists implements ObjectStorageProvider interface
+func (p *countingProvider) Exists(ctx context.Context, path string) (bool, error) {
+	p.calls.heads.Add(1)
+	return p.ObjectStorageProvider.Exists(ctx, path)
+}
+
+// List implements ObjectStorageProvider interface, counting a listing per page of the result
+func (p *countingProvider) List(ctx context.Context, prefix string) ([]string, error) {
+	paths, err := p.ObjectStorageProvider.List(ctx, prefix)
+	p.calls.lists.Add(max(1, int64((len(paths)+DefaultListPageSize-1)/DefaultListPageSize)))
+	return paths, err
+}
+
+// ListPages implements PageLister interface
+func (p *countingProvider) ListPages(ctx context.Context, prefix string, fn func(page []string) error) error {
+	pages := 0
+	err := ListPages(ctx, p.ObjectStorageProvider, prefix, func(page []string) error {
+		pages++
+		p.calls.lists.Add(1)
+		return fn(page)
+	})
+	if pages == 0 {
+		p.calls.lists.Add(1)
+	}
+	return err
+}
+
+// ListPage implements PageTokenLister interface
+func (p *countingProvider) ListPage(ctx context.Context, prefix, token string, limit int) ([]string, string, error) {
+	p.calls.lists.Add(1)
+	return ListPage(ctx, p.ObjectStorageProvider, prefix, token, limit)
+}
+
+// ListCommonPrefixes implements PrefixLister interface. Without native support, every page listed to
+// compute the prefixes is counted
+func (p *countingProvider) ListCommonPrefixes(ctx context.Context, prefix, delimiter string) ([]string, error) {
+	if _, ok := p.ObjectStorageProvider.(PrefixLister); ok {
+		p.calls.lists.Add(1)
+		return ListCommonPrefixes(ctx, p.ObjectStorageProvider, prefix, delimiter)
+	}
+	return ListCommonPrefixes(ctx, struct {
+		ObjectStorageProvider
+		PageLister
+	}{p, p}, prefix, delimiter)
+}
+
+// ListAttributes implements AttributeLister interface, counting a listing per page of the result. Without
+// native support, the requests of the fallback are counted
+func (p *countingProvider) ListAttributes(ctx context.Context, prefix string) ([]ObjectAttributes, error) {
+	if _, ok := p.ObjectStorageProvider.(AttributeLister); ok {
+		objects, err := ListAttributes(ctx, p.ObjectStorageProvider, prefix)
+		p.calls.lists.Add(max(1, int64((len(objects)+DefaultListPageSize-1)/DefaultListPageSize)))
+		return objects, err
+	}
+	return ListAttributes(ctx, struct {
+		ObjectStorageProvider
+		ObjectStater
+	}{p, p}, prefix)
+}
+
+// Stat implements ObjectStater interface. Without native support, the requests of the fallback are counted
+func (p *countingProvider) Stat(ctx context.Context, path string) (*ObjectAttributes, error) {
+	if _, ok := p.ObjectStorageProvider.(ObjectStater); ok {
+		p.calls.heads.Add(1)
+		return Stat(ctx, p.ObjectStorageProvider, path)
+	}
+	return Stat(ctx, struct{ ObjectStorageProvider }{p}, path)
+}
+
+// Copy implements Copier interface. Without native support, the download and upload are counted
+func (p *countingProvider) Copy(ctx context.Context, src, dst string) error {
+	if _, ok := p.ObjectStorageProvider.(Copier); ok {
+		p.calls.puts.Add(1)
+		return Copy(ctx, p.ObjectStorageProvider, src, dst)
+	}
+	return Copy(ctx, struct{ ObjectStorageProvider }{p}, src, dst)
+}
+
+// Move implements Mover interface. Without native support, the copy and delete are counted
+func (p *countingProvider) Move(ctx context.Context, src, dst string) error {
+	if _, ok := p.ObjectStorageProvider.(Mover); ok {
+		p.calls.puts.Add(1)
+		p.calls.deletes.Add(1)
+		return Move(ctx, p.ObjectStorageProvider, src, dst)
+	}
+	return Move(ctx, struct {
+		ObjectStorageProvider
+		Copier
+	}{p, p}, src, dst)
+}
+
+// DownloadVersion implements VersionedProvider interface, failing with ErrVersioningNotSupported if
+// the wrapped provider doesn't support versions
+func (p *countingProvider) DownloadVersion(ctx context.Context, path string, versionID string) (io.ReadCloser, error) {
+	versioned, ok := p.ObjectStorageProvider.(VersionedProvider)
+	if !ok {
+		return nil, ErrVersioningNotSupported
+	}
+	p.calls.gets.Add(1)
+	return versioned.DownloadVersion(ctx, path, versionID)
+}
+
+// ListVersions implements VersionedProvider interface, failing with ErrVersioningNotSupported if
+// the wrapped provider doesn't support versions
+func (p *countingProvider) ListVersions(ctx context.Context, path string) ([]ObjectVersion, error) {
+	versioned, ok := p.ObjectStorageProvider.(VersionedProvider)
+	if !ok {
+		return nil, ErrVersioningNotSupported
+	}
+	p.calls.lists.Add(1)
+	return versioned.ListVersions(ctx, path)
+}
+
+// UploadIfNotExists implements ConditionalUploader interface
+func (p *conditionalCountingProvider) UploadIfNotExists(ctx context.Context, path string, data io.Reader) error {
+	p.calls.puts.Add(1)
+	return p.conditional.UploadIfNotExists(ctx, path, data)
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountingProvider(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMemoryProvider()
+	assert.Same(t, inner, NewCountingProvider(inner, nil))
+
+	calls := &CallCounter{}
+	provider := NewCountingProvider(inner, calls)
+	conditional, ok := provider.(ConditionalUploader)
+	require.True(t, ok)
+
+	for i := 0; i < DefaultListPageSize+10; i++ {
+		require.NoError(t, provider.Upload(ctx, fmt.Sprintf("data/%05d", i), strings.NewReader("x")))
+	}
+	assert.ErrorIs(t, conditional.UploadIfNotExists(ctx, "data/00000", strings.NewReader("x")), ErrObjectExists)
+	body, err := provider.Download(ctx, "data/00000")
+	require.NoError(t, err)
+	require.NoError(t, body.Close())
+	_, err = provider.Exists(ctx, "data/00001")
+	require.NoError(t, err)
+	_, err = Stat(ctx, provider, "data/00001")
+	require.NoError(t, err)
+	require.NoError(t, Copy(ctx, provider, "data/00001", "copy/a"))
+	require.NoError(t, Move(ctx, provider, "copy/a", "copy/b"))
+	require.NoError(t, provider.Delete(ctx, "copy/b"))
+	assert.Equal(t, CallStats{Puts: DefaultListPageSize + 10 + 1 + 2, Gets: 1, Heads: 2, Deletes: 2}, calls.Stats())
+
+	// Listings count one request per page, even if the provider lists everything at once
+	_, err = provider.List(ctx, "data/")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), calls.Stats().Lists)
+	_, err = provider.List(ctx, "none/")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), calls.Stats().Lists)
+	require.NoError(t, ListPages(ctx, provider, "data/", func([]string) error { return nil }))
+	assert.Equal(t, int64(5), calls.Stats().Lists)
+	_, err = ListCommonPrefixes(ctx, provider, "", "/")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), calls.Stats().Lists)
+
+	_, err = provider.(VersionedProvider).ListVersions(ctx, "data/00000")
+	assert.ErrorIs(t, err, ErrVersioningNotSupported)
+
+	stats := calls.Stats()
+	assert.Equal(t, stats.Puts+stats.Gets+stats.Lists+stats.Heads+stats.Deletes, stats.Total())
+	assert.Equal(t, CallStats{Puts: 2 * stats.Puts, Gets: 2, Lists: 14, Heads: 4, Deletes: 4}, stats.Add(stats))
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// Copier is implemented by providers that can copy an object server-side, without downloading it
+type Copier interface {
+	// Copy copies the object at src to dst, replacing dst if it exists
+	Copy(ctx context.Context, src, dst string) error
+}
+
+// Mover is implemented by providers that can move an object without copying its data, e.g. with a rename
+type Mover interface {
+	// Move moves the object at src to dst, replacing dst if it exists
+	Move(ctx context.Context, src, dst string) error
+}
+
+// Copy copies the object at src to dst, replacing dst if it exists, using provider's Copier support if
+// available and otherwise downloading and re-uploading the object
+func Copy(ctx context.Context, provider ObjectStorageProvider, src, dst string) error {
+	if copier, ok := provider.(Copier); ok {
+		return copier.Copy(ctx, src, dst)
+	}
+
+	body, err := provider.Download(ctx, src)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", src, err)
+	}
+	defer body.Close()
+	if err := provider.Upload(ctx, dst, body); err != nil {
+		return fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+	return nil
+}
+
+// Move moves the object at src to dst, replacing dst if it exists, using provider's Mover support if
+// available and otherwise copying the object with Copy and deleting src. Without Mover support the
+// object can be left at both paths if deleting src fails.
+func Move(ctx context.Context, provider ObjectStorageProvider, src, dst string) error {
+	if mover, ok := provider.(Mover); ok {
+		return mover.Move(ctx, src, dst)
+	}
+
+	if err := Copy(ctx, provider, src, dst); err != nil {
+		return err
+	}
+	if err := provider.Delete(ctx, src); err != nil {
+		return fmt.Errorf("failed to delete %s after copying it: %w", src, err)
+	}
+	return nil
+}
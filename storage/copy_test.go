@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// basicProvider hides the optional interfaces of the wrapped provider
+type basicProvider struct {
+	ObjectStorageProvider
+}
+
+func TestCopyMove(t *testing.T) {
+	ctx := context.Background()
+	for name, wrap := range map[string]func(*MemoryProvider) ObjectStorageProvider{
+		"native":   func(m *MemoryProvider) ObjectStorageProvider { return m },
+		"fallback": func(m *MemoryProvider) ObjectStorageProvider { return basicProvider{m} },
+	} {
+		t.Run(name, func(t *testing.T) {
+			inner := NewMemoryProvider()
+			provider := wrap(inner)
+			require.NoError(t, inner.Upload(ctx, "a", strings.NewReader("data")))
+
+			require.NoError(t, Copy(ctx, provider, "a", "b"))
+			require.NoError(t, Move(ctx, provider, "b", "c"))
+			assert.Equal(t, map[string][]byte{"a": []byte("data"), "c": []byte("data")}, inner.Snapshot())
+
+			assert.ErrorIs(t, Copy(ctx, provider, "missing", "d"), ErrNotFound)
+			assert.ErrorIs(t, Move(ctx, provider, "missing", "d"), ErrNotFound)
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// encryptionMagic prefixes client-side encrypted objects, it can't be mistaken for gzip data
+var encryptionMagic = []byte("MSE\x01")
+
+// nonceSize AES-GCM nonce size
+const nonceSize = 12
+
+// ErrDecryptionFailed is returned when a client-side encrypted object can't be decrypted, e.g. because
+// its key is unknown or the object was tampered with
+var ErrDecryptionFailed = errors.New("decryption failed")
+
+// KeyProvider supplies the AES keys of client-side encryption. Implementations can hold static keys,
+// or fetch and cache data keys from a KMS or Vault.
+type KeyProvider interface {
+	// EncryptionKey returns the ID and value of the key new objects are encrypted with
+	EncryptionKey(ctx context.Context) (keyID string, key []byte, err error)
+	// DecryptionKey returns the value of the key with the given ID
+	DecryptionKey(ctx context.Context, keyID string) ([]byte, error)
+}
+
+// StaticKeyProvider KeyProvider with a fixed set of keys
+type StaticKeyProvider struct {
+	currentKeyID string
+	keys         map[string][]byte
+}
+
+// NewStaticKeyProvider creates a KeyProvider encrypting with keys[currentKeyID]. The other keys are
+// only used to decrypt objects written before a key rotation. Keys must be 16, 24 or 32 bytes long.
+func NewStaticKeyProvider(currentKeyID string, keys map[string][]byte) (*StaticKeyProvider, error) {
+	if _, ok := keys[currentKeyID]; !ok {
+		return nil, fmt.Errorf("current key %q is missing", currentKeyID)
+	}
+	for keyID, key := range keys {
+		if len(keyID) == 0 || len(keyID) > 255 {
+			return nil, fmt.Errorf("key ID %q must be 1 to 255 bytes long", keyID)
+		}
+		if _, err := aes.NewCipher(key); err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", keyID, err)
+		}
+	}
+	return &StaticKeyProvider{currentKeyID: currentKeyID, keys: keys}, nil
+}
+
+// EncryptionKey implements KeyProvider interface
+func (p *StaticKeyProvider) EncryptionKey(ctx context.Context) (string, []byte, error) {
+	return p.currentKeyID, p.keys[p.currentKeyID], nil
+}
+
+// DecryptionKey implements KeyProvider interface
+func (p *StaticKeyProvider) DecryptionKey(ctx context.Context, keyID string) ([]byte, error) {
+	key, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", keyID)
+	}
+	return key, nil
+}
+
+// Encrypt encrypts data with AES-GCM using the current key of keys. The result starts with a header
+// naming the key, so Decrypt can pick the right one after a key rotation.
+func Encrypt(ctx context.Context, keys KeyProvider, data []byte) ([]byte, error) {
+	keyID, key, err := keys.EncryptionKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get encryption key: %w", err)
+	}
+	if len(keyID) == 0 || len(keyID) > 255 {
+		return nil, fmt.Errorf("key ID %q must be 1 to 255 bytes long", keyID)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key %q: %w", keyID, err)
+	}
+
+	// Header: magic, key ID length, key ID, nonce. Everything before the nonce is authenticated
+	aad := make([]byte, 0, len(encryptionMagic)+1+len(keyID))
+	aad = append(aad, encryptionMagic...)
+	aad = append(aad, byte(len(keyID)))
+	aad = append(aad, keyID...)
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	out := make([]byte, 0, len(aad)+nonceSize+len(data)+aead.Overhead())
+	out = append(out, aad...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, data, aad), nil
+}
+
+// Decrypt decrypts data encrypted by Encrypt. Data without the encryption header is returned
+// unchanged, so objects written before encryption was enabled stay readable.
+func Decrypt(ctx context.Context, keys KeyProvider, data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, encryptionMagic) {
+		return data, nil
+	}
+	rest := data[len(encryptionMagic):]
+	if len(rest) < 1 || len(rest) < 1+int(rest[0])+nonceSize {
+		return nil, fmt.Errorf("%w: truncated header", ErrDecryptionFailed)
+	}
+	keyID := string(rest[1 : 1+rest[0]])
+	aad := data[:len(encryptionMagic)+1+len(keyID)]
+	nonce := data[len(aad) : len(aad)+nonceSize]
+	ciphertext := data[len(aad)+nonceSize:]
+
+	key, err := keys.DecryptionKey(ctx, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDecryptionFailed, err)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid key %q: %w", ErrDecryptionFailed, keyID, err)
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("%w: key %q: %w", ErrDecryptionFailed, keyID, err)
+	}
+	return plaintext, nil
+}
+
+// newAEAD creates an AES-GCM cipher
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptedProvider encrypts uploads and decrypts downloads of the wrapped provider
+type encryptedProvider struct {
+	ObjectStorageProvider
+	keys KeyProvider
+}
+
+// conditionalEncryptedProvider additionally forwards conditional uploads
+type conditionalEncryptedProvider struct {
+	*encryptedProvider
+	conditional ConditionalUploader
+}
+
+// NewEncryptedProvider wraps provider so that uploaded objects are encrypted with AES-GCM using keys,
+// and downloaded ones decrypted, see Encrypt. Uploads are buffered in memory. provider is returned
+// unchanged if keys is nil. Conditional uploads, paginated listing, stats, copies and object versions
+// are preserved.
+func NewEncryptedProvider(provider ObjectStorageProvider, keys KeyProvider) ObjectStorageProvider {
+	if provider == nil || keys == nil {
+		return provider
+	}
+	p := &encryptedProvider{ObjectStorageProvider: provider, keys: keys}
+	if conditional, ok := provider.(ConditionalUploader); ok {
+		return &conditionalEncryptedProvider{encryptedProvider: p, conditional: conditional}
+	}
+	return p
+}
+
+// encrypt reads and encrypts data
+func (p *encryptedProvider) encrypt(ctx context.Context, data io.Reader) (io.Reader, error) {
+	plaintext, err := io.ReadAll(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload data: %w", err)
+	}
+	ciphertext, err := Encrypt(ctx, p.keys, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(ciphertext), nil
+}
+
+// decrypt decrypts body if it is encrypted, plaintext bodies are streamed unchanged
+func (p *encryptedProvider) decrypt(ctx context.Context, body io.ReadCloser) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(body)
+	magic, _ := buffered.Peek(len(encryptionMagic))
+	if !bytes.Equal(magic, encryptionMagic) {
+		return struct {
+			io.Reader
+			io.Closer
+		}{buffered, body}, nil
+	}
+
+	defer body.Close()
+	ciphertext, err := io.ReadAll(buffered)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encrypted data: %w", err)
+	}
+	plaintext, err := Decrypt(ctx, p.keys, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(plaintext)), nil
+}
+
+// Upload implements ObjectStorageProvider interface
+func (p *encryptedProvider) Upload(ctx context.Context, path string, data io.Reader) error {
+	body, err := p.encrypt(ctx, data)
+	if err != nil {
+		return err
+	}
+	return p.ObjectStorageProvider.Upload(ctx, path, body)
+}
+
+// Download implements ObjectStorageProvider interface
+func (p *encryptedProvider) Download(ctx context.Context, path string) (io.ReadCloser, error) {
+	body, err := p.ObjectStorageProvider.Download(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return p.decrypt(ctx, body)
+}
+
+// ListPages implements PageLister interface
+func (p *encryptedProvider) ListPages(ctx context.Context, prefix string, fn func(page []string) error) error {
+	return ListPages(ctx, p.ObjectStorageProvider, prefix, fn)
+}
+
+// ListPage implements PageTokenLister interface
+func (p *encryptedProvider) ListPage(ctx context.Context, prefix, token string, limit int) ([]string, string, error) {
+	return ListPage(ctx, p.ObjectStorageProvider, prefix, token, limit)
+}
+
+// ListCommonPrefixes implements PrefixLister interface
+func (p *encryptedProvider) ListCommonPrefixes(ctx context.Context, prefix, delimiter string) ([]string, error) {
+	return ListCommonPrefixes(ctx, p.ObjectStorageProvider, prefix, delimiter)
+}
+
+// ListAttributes implements AttributeLister interface, sizes are sizes as stored, i.e. encrypted
+func (p *encryptedProvider) ListAttributes(ctx context.Context, prefix string) ([]ObjectAttributes, error) {
+	return ListAttributes(ctx, p.ObjectStorageProvider, prefix)
+}
+
+// Stat implements ObjectStater interface, the size is the size as stored, i.e. encrypted
+func (p *encryptedProvider) Stat(ctx context.Context, path string) (*ObjectAttributes, error) {
+	return Stat(ctx, p.ObjectStorageProvider, path)
+}
+
+// Copy implements Copier interface, objects are copied as stored, without decrypting them
+func (p *encryptedProvider) Copy(ctx context.Context, src, dst string) error {
+	return Copy(ctx, p.ObjectStorageProvider, src, dst)
+}
+
+// Move implements Mover interface
+func (p *encryptedProvider) Move(ctx context.Context, src, dst string) error {
+	return Move(ctx, p.ObjectStorageProvider, src, dst)
+}
+
+// DownloadVersion implements VersionedProvider interface, failing with ErrVersioningNotSupported if
+// the wrapped provider doesn't support versions
+func (p *encryptedProvider) DownloadVersion(ctx context.Context, path string, versionID string) (io.ReadCloser, error) {
+	versioned, ok := p.ObjectStorageProvider.(VersionedProvider)
+	if !ok {
+		return nil, ErrVersioningNotSupported
+	}
+	body, err := versioned.DownloadVersion(ctx, path, versionID)
+	if err != nil {
+		return nil, err
+	}
+	return p.decrypt(ctx, body)
+}
+
+// ListVersions implements VersionedProvider interface, failing with ErrVersioningNotSupported if
+// the wrapped provider doesn't support versions
+func (p *encryptedProvider) ListVersions(ctx context.Context, path string) ([]ObjectVersion, error) {
+	versioned, ok := p.ObjectStorageProvider.(VersionedProvider)
+	if !ok {
+		return nil, ErrVersioningNotSupported
+	}
+	return versioned.ListVersions(ctx, path)
+}
+
+// UploadIfNotExists implements ConditionalUploader interface
+func (p *conditionalEncryptedProvider) UploadIfNotExists(ctx context.Context, path string, data io.Reader) error {
+	body, err := p.encrypt(ctx, data)
+	if err != nil {
+		return err
+	}
+	return p.conditional.UploadIfNotExists(ctx, path, body)
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	ctx := context.Background()
+	oldKeys, err := NewStaticKeyProvider("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+	require.NoError(t, err)
+	ciphertext, err := Encrypt(ctx, oldKeys, []byte("metering data"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(ciphertext), "metering data")
+
+	// After a rotation, data encrypted with the previous key is still readable
+	keys, err := NewStaticKeyProvider("k2", map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, 32),
+		"k2": bytes.Repeat([]byte{2}, 16),
+	})
+	require.NoError(t, err)
+	plaintext, err := Decrypt(ctx, keys, ciphertext)
+	require.NoError(t, err)
+	assert.Equal(t, "metering data", string(plaintext))
+
+	// Tampering is detected
+	tampered := bytes.Clone(ciphertext)
+	tampered[len(tampered)-1] ^= 0xff
+	_, err = Decrypt(ctx, keys, tampered)
+	assert.ErrorIs(t, err, ErrDecryptionFailed)
+
+	// Unknown key
+	otherKeys, err := NewStaticKeyProvider("k3", map[string][]byte{"k3": bytes.Repeat([]byte{3}, 32)})
+	require.NoError(t, err)
+	_, err = Decrypt(ctx, otherKeys, ciphertext)
+	assert.ErrorIs(t, err, ErrDecryptionFailed)
+
+	// Plaintext passes through
+	plaintext, err = Decrypt(ctx, keys, []byte{0x1f, 0x8b, 0x08})
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x1f, 0x8b, 0x08}, plaintext)
+
+	_, err = NewStaticKeyProvider("k1", map[string][]byte{"k1": []byte("short")})
+	assert.Error(t, err)
+	_, err = NewStaticKeyProvider("missing", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+	assert.Error(t, err)
+}
+
+func TestEncryptedProvider(t *testing.T) {
+	ctx := context.Background()
+	keys, err := NewStaticKeyProvider("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)})
+	require.NoError(t, err)
+	inner := NewMemoryProvider()
+	require.NoError(t, inner.Upload(ctx, "plain.txt", strings.NewReader("written before encryption")))
+
+	encrypted := NewEncryptedProvider(inner, keys)
+	conditional, ok := encrypted.(ConditionalUploader)
+	require.True(t, ok, "conditional upload support should be preserved")
+	require.NoError(t, encrypted.Upload(ctx, "a.txt", strings.NewReader("secret")))
+	assert.ErrorIs(t, conditional.UploadIfNotExists(ctx, "a.txt", strings.NewReader("again")), ErrObjectExists)
+
+	// Stored encrypted, read back decrypted
+	stored := inner.Snapshot()["a.txt"]
+	assert.NotContains(t, string(stored), "secret")
+	// Copies keep the stored ciphertext
+	require.NoError(t, Copy(ctx, encrypted, "a.txt", "b.txt"))
+	assert.Equal(t, stored, inner.Snapshot()["b.txt"])
+	for path, want := range map[string]string{"a.txt": "secret", "b.txt": "secret", "plain.txt": "written before encryption"} {
+		body, err := encrypted.Download(ctx, path)
+		require.NoError(t, err)
+		data, err := io.ReadAll(body)
+		require.NoError(t, err)
+		require.NoError(t, body.Close())
+		assert.Equal(t, want, string(data))
+	}
+
+	_, err = encrypted.(VersionedProvider).ListVersions(ctx, "a.txt")
+	assert.ErrorIs(t, err, ErrVersioningNotSupported)
+	assert.Same(t, inner, NewEncryptedProvider(inner, nil))
+}
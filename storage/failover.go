@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// FallbackMarkerPrefix prefix of the markers a failover provider writes to its secondary for every
+	// object stored there, so a recovery job can find them without listing the whole bucket
+	FallbackMarkerPrefix = "_fallback/"
+	// FallbackTag object tag set on objects written to the secondary, on providers supporting tags
+	FallbackTag = "metering-fallback"
+)
+
+// FallbackMarker records an object written to the secondary of a failover provider
+type FallbackMarker struct {
+	Path  string    `json:"path"`  // path of the object
+	Time  time.Time `json:"time"`  // time of the fallback
+	Error string    `json:"error"` // primary upload error that caused the fallback
+}
+
+// failoverProvider writes to a primary provider and falls back to a secondary one when it fails
+type failoverProvider struct {
+	primary   ObjectStorageProvider
+	secondary ObjectStorageProvider
+}
+
+// conditionalFailoverProvider additionally supports conditional uploads when both providers do
+type conditionalFailoverProvider struct {
+	*failoverProvider
+	primary   ConditionalUploader
+	secondary ConditionalUploader
+}
+
+// NewFailoverProvider returns a provider uploading to primary and, when that fails, to secondary, e.g. local
+// disk or another region. Objects written to secondary are tagged with FallbackTag and recorded by a
+// marker under FallbackMarkerPrefix; RecoverFallbacks moves them to primary once it is healthy again.
+// Reads try primary first, listings merge both providers. Conditional upload support is preserved when
+// both providers support it.
+func NewFailoverProvider(primary, secondary ObjectStorageProvider) ObjectStorageProvider {
+	p := &failoverProvider{primary: primary, secondary: secondary}
+	primaryConditional, ok1 := primary.(ConditionalUploader)
+	secondaryConditional, ok2 := secondary.(ConditionalUploader)
+	if ok1 && ok2 {
+		return &conditionalFailoverProvider{failoverProvider: p, primary: primaryConditional, secondary: secondaryConditional}
+	}
+	return p
+}
+
+// Upload implements ObjectStorageProvider interface
+func (p *failoverProvider) Upload(ctx context.Context, path string, data io.Reader) error {
+	return p.upload(ctx, path, data, p.primary.Upload, p.secondary.Upload)
+}
+
+// UploadIfNotExists implements ConditionalUploader interface. An object existing in primary is not
+// written to secondary; the existence check against primary is skipped when primary is unavailable.
+func (p *conditionalFailoverProvider) UploadIfNotExists(ctx context.Context, path string, data io.Reader) error {
+	return p.upload(ctx, path, data, p.primary.UploadIfNotExists, p.secondary.UploadIfNotExists)
+}
+
+// upload writes data with primaryUpload, falling back to secondaryUpload and a marker when it fails
+func (p *failoverProvider) upload(ctx context.Context, path string, data io.Reader, primaryUpload, secondaryUpload func(context.Context, string, io.Reader) error) error {
+	body, err := io.ReadAll(data)
+	if err != nil {
+		return fmt.Errorf("failed to read upload data: %w", err)
+	}
+	primaryErr := primaryUpload(ctx, path, bytes.NewReader(body))
+	if primaryErr == nil || errors.Is(primaryErr, ErrObjectExists) || ctx.Err() != nil {
+		return primaryErr
+	}
+
+	if err := secondaryUpload(fallbackContext(ctx), path, bytes.NewReader(body)); err != nil {
+		return errors.Join(primaryErr, fmt.Errorf("fallback upload failed: %w", err))
+	}
+	marker, err := json.Marshal(&FallbackMarker{Path: path, Time: time.Now(), Error: primaryErr.Error()})
+	if err != nil {
+		return fmt.Errorf("failed to marshal fallback marker: %w", err)
+	}
+	if err := p.secondary.Upload(ctx, FallbackMarkerPrefix+path, bytes.NewReader(marker)); err != nil {
+		return errors.Join(primaryErr, fmt.Errorf("failed to write fallback marker: %w", err))
+	}
+	return nil
+}
+
+// fallbackContext returns ctx with FallbackTag added to its upload options
+func fallbackContext(ctx context.Context) context.Context {
+	opts := UploadOptions{}
+	if existing := UploadOptionsFromContext(ctx); existing != nil {
+		opts = *existing
+	}
+	opts.Tags = maps.Clone(opts.Tags)
+	if opts.Tags == nil {
+		opts.Tags = make(map[string]string, 1)
+	}
+	opts.Tags[FallbackTag] = "true"
+	return WithUploadOptions(ctx, &opts)
+}
+
+// Download implements ObjectStorageProvider interface, reading from secondary when primary fails
+func (p *failoverProvider) Download(ctx context.Context, path string) (io.ReadCloser, error) {
+	rc, err := p.primary.Download(ctx, path)
+	if err == nil || ctx.Err() != nil {
+		return rc, err
+	}
+	rc, secondaryErr := p.secondary.Download(ctx, path)
+	if secondaryErr != nil {
+		// Report the primary error unless the object is simply missing from secondary too
+		if errors.Is(secondaryErr, ErrNotFound) {
+			return nil, err
+		}
+		return nil, errors.Join(err, secondaryErr)
+	}
+	return rc, nil
+}
+
+// Delete implements ObjectStorageProvider interface, deleting the object and its marker from both providers
+func (p *failoverProvider) Delete(ctx context.Context, path string) error {
+	if err := p.primary.Delete(ctx, path); err != nil {
+		return err
+	}
+	exists, err := p.secondary.Exists(ctx, FallbackMarkerPrefix+path)
+	if err != nil || !exists {
+		return err
+	}
+	if err := p.secondary.Delete(ctx, path); err != nil {
+		return err
+	}
+	return p.secondary.Delete(ctx, FallbackMarkerPrefix+path)
+}
+
+// Exists implements ObjectStorageProvider interface
+func (p *failoverProvider) Exists(ctx context.Context, path string) (bool, error) {
+	exists, err := p.primary.Exists(ctx, path)
+	if err == nil && exists {
+		return true, nil
+	}
+	secondaryExists, secondaryErr := p.secondary.Exists(ctx, path)
+	if secondaryErr == nil && secondaryExists {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return false, secondaryErr
+}
+
+// List implements ObjectStorageProvider interface, merging the objects of both providers. Objects only
+// in secondary are included while primary is unavailable, so readers still see the data written there.
+func (p *failoverProvider) List(ctx context.Context, prefix string) ([]string, error) {
+	primaryPaths, err := p.primary.List(ctx, prefix)
+	secondaryPaths, secondaryErr := p.secondary.List(ctx, prefix)
+	if err != nil && secondaryErr != nil {
+		return nil, errors.Join(err, secondaryErr)
+	}
+
+	seen := make(map[string]struct{}, len(primaryPaths)+len(secondaryPaths))
+	paths := make([]string, 0, len(primaryPaths)+len(secondaryPaths))
+	for _, path := range append(primaryPaths, secondaryPaths...) {
+		if _, ok := fallbackMarkedPath(path); ok {
+			continue
+		}
+		if _, ok := seen[path]; !ok {
+			seen[path] = struct{}{}
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// RecoverFallbacks moves the objects a failover provider wrote to secondary over to primary, removing them
+// and their markers from secondary. Objects already present in primary are kept as they are, since they
+// were written there later. It returns the number of objects moved; recovery stops at the first error
+// and can simply be run again.
+func RecoverFallbacks(ctx context.Context, primary, secondary ObjectStorageProvider) (int, error) {
+	moved := 0
+	err := ListPages(ctx, secondary, FallbackMarkerPrefix, func(page []string) error {
+		for _, key := range page {
+			path, ok := fallbackMarkedPath(key)
+			if !ok {
+				continue
+			}
+			marker := FallbackMarkerPrefix + path
+			exists, err := primary.Exists(ctx, path)
+			if err != nil {
+				return fmt.Errorf("failed to check primary %s: %w", path, err)
+			}
+			if !exists {
+				if err := copyBetween(ctx, secondary, primary, path); err != nil {
+					return err
+				}
+				moved++
+			}
+			if err := secondary.Delete(ctx, path); err != nil {
+				return fmt.Errorf("failed to delete fallback %s: %w", path, err)
+			}
+			if err := secondary.Delete(ctx, marker); err != nil {
+				return fmt.Errorf("failed to delete fallback marker %s: %w", marker, err)
+			}
+		}
+		return nil
+	})
+	return moved, err
+}
+
+// fallbackMarkedPath returns the path of the object recorded by the fallback marker listed as key, and
+// whether key is a marker. Listed keys may carry the provider prefix, e.g. LocalFS and S3 with Prefix set.
+func fallbackMarkedPath(key string) (string, bool) {
+	if path, ok := strings.CutPrefix(key, FallbackMarkerPrefix); ok {
+		return path, true
+	}
+	_, path, ok := strings.Cut(key, "/"+FallbackMarkerPrefix)
+	return path, ok
+}
+
+// copyBetween copies the object at path from one provider to another
+func copyBetween(ctx context.Context, from, to ObjectStorageProvider, path string) error {
+	rc, err := from.Download(ctx, path)
+	if err != nil {
+		return fmt.Errorf("failed to download fallback %s: %w", path, err)
+	}
+	defer rc.Close()
+	if err := to.Upload(ctx, path, rc); err != nil {
+		return fmt.Errorf("failed to upload %s to primary: %w", path, err)
+	}
+	return nil
+}
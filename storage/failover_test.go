@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tagRecordingProvider records the tags of every upload
+type tagRecordingProvider struct {
+	ObjectStorageProvider
+	tags map[string]map[string]string
+}
+
+func (p *tagRecordingProvider) Upload(ctx context.Context, path string, data io.Reader) error {
+	if opts := UploadOptionsFromContext(ctx); opts != nil {
+		p.tags[path] = opts.Tags
+	}
+	return p.ObjectStorageProvider.Upload(ctx, path, data)
+}
+
+func readString(t *testing.T, provider ObjectStorageProvider, path string) string {
+	t.Helper()
+	rc, err := provider.Download(context.Background(), path)
+	require.NoError(t, err)
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestFailoverProvider(t *testing.T) {
+	primary := NewMemoryProvider()
+	secondary := &tagRecordingProvider{ObjectStorageProvider: NewMemoryProvider(), tags: map[string]map[string]string{}}
+	failover := NewFailoverProvider(primary, secondary)
+	ctx := WithUploadOptions(context.Background(), &UploadOptions{Tags: map[string]string{"tenant": "acme"}})
+
+	healthy := "metering/ru/1755849600/tidb/pool1/server1-0.json.gz"
+	fallback := "metering/ru/1755849660/tidb/pool1/server1-0.json.gz"
+	require.NoError(t, failover.Upload(ctx, healthy, strings.NewReader("healthy")))
+
+	primary.SetErrorRate(1)
+	require.NoError(t, failover.Upload(ctx, fallback, strings.NewReader("fallback")))
+	primary.SetErrorRate(0)
+
+	assert.Equal(t, "fallback", readString(t, secondary, fallback))
+	assert.Equal(t, map[string]string{"tenant": "acme", FallbackTag: "true"}, secondary.tags[fallback])
+	assert.Equal(t, map[string]string{"tenant": "acme"}, UploadOptionsFromContext(ctx).Tags, "caller options must not be modified")
+	assert.Contains(t, readString(t, secondary, FallbackMarkerPrefix+fallback), `"path":"`+fallback+`"`)
+
+	// Reads and listings see objects of both providers
+	assert.Equal(t, "fallback", readString(t, failover, fallback))
+	exists, err := failover.Exists(ctx, fallback)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	paths, err := failover.List(ctx, "metering/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{healthy, fallback}, paths)
+	_, err = failover.Download(ctx, "metering/missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Recovery moves fallback objects to the primary
+	moved, err := RecoverFallbacks(ctx, primary, secondary)
+	require.NoError(t, err)
+	assert.Equal(t, 1, moved)
+	assert.Equal(t, "fallback", readString(t, primary, fallback))
+	remaining, err := secondary.List(ctx, "")
+	require.NoError(t, err)
+	assert.Empty(t, remaining)
+
+	moved, err = RecoverFallbacks(ctx, primary, secondary)
+	require.NoError(t, err)
+	assert.Zero(t, moved)
+}
+
+func TestFailoverProvider_PrefixedSecondary(t *testing.T) {
+	ctx := context.Background()
+	primary := NewMemoryProvider()
+	secondary, err := NewObjectStorageProvider(&ProviderConfig{
+		Type:    ProviderTypeLocalFS,
+		Prefix:  "pfx",
+		LocalFS: &LocalFSConfig{BasePath: t.TempDir(), CreateDirs: true},
+	})
+	require.NoError(t, err)
+	failover := NewFailoverProvider(primary, secondary)
+
+	primary.SetErrorRate(1)
+	require.NoError(t, failover.Upload(ctx, "metering/a.json", strings.NewReader("fallback")))
+	primary.SetErrorRate(0)
+
+	// Markers are hidden from listings even though the secondary lists keys with its prefix
+	paths, err := failover.List(ctx, "")
+	require.NoError(t, err)
+	for _, path := range paths {
+		assert.NotContains(t, path, FallbackMarkerPrefix)
+	}
+
+	moved, err := RecoverFallbacks(ctx, primary, secondary)
+	require.NoError(t, err)
+	assert.Equal(t, 1, moved)
+	assert.Equal(t, "fallback", readString(t, primary, "metering/a.json"))
+	remaining, err := secondary.List(ctx, "")
+	require.NoError(t, err)
+	assert.Empty(t, remaining)
+}
+
+func TestFailoverProvider_Conditional(t *testing.T) {
+	primary, secondary := NewMemoryProvider(), NewMemoryProvider()
+	failover := NewFailoverProvider(primary, secondary)
+	conditional, ok := failover.(ConditionalUploader)
+	require.True(t, ok, "conditional upload support should be preserved")
+	ctx := context.Background()
+
+	require.NoError(t, conditional.UploadIfNotExists(ctx, "a", strings.NewReader("1")))
+	assert.ErrorIs(t, conditional.UploadIfNotExists(ctx, "a", strings.NewReader("2")), ErrObjectExists)
+	exists, err := secondary.Exists(ctx, "a")
+	require.NoError(t, err)
+	assert.False(t, exists, "existing objects must not fall back")
+
+	// Both providers failing reports both errors
+	primary.SetErrorRate(1)
+	secondary.SetErrorRate(1)
+	err = failover.Upload(ctx, "b", strings.NewReader("b"))
+	assert.ErrorIs(t, err, ErrInjectedFailure)
+	assert.ErrorContains(t, err, "fallback upload failed")
+}
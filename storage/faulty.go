@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FaultOperation names a provider operation faults can be injected into
+type FaultOperation string
+
+// Operations of a faulty provider
+const (
+	FaultOpUpload   FaultOperation = "upload"
+	FaultOpDownload FaultOperation = "download"
+	FaultOpDelete   FaultOperation = "delete"
+	FaultOpExists   FaultOperation = "exists"
+	FaultOpList     FaultOperation = "list"
+)
+
+// FaultConfig configures the faults injected by NewFaultyProvider
+type FaultConfig struct {
+	// Operations operations faults are injected into, empty means all
+	Operations []FaultOperation
+	// FailFirst number of matching operations that fail unconditionally before ErrorRate applies
+	FailFirst int
+	// ErrorRate fraction of matching operations, between 0 and 1, that fail with ErrInjectedFailure
+	ErrorRate float64
+	// Latency delay added to every matching operation
+	Latency time.Duration
+	// LatencyJitter upper bound of a random delay added on top of Latency
+	LatencyJitter time.Duration
+	// PartialWriteRate fraction of uploads, between 0 and 1, that store only part of the data and then fail
+	PartialWriteRate float64
+	// Seed makes fault decisions reproducible, 0 uses a random seed
+	Seed uint64
+}
+
+// faultyProvider injects faults into the operations of the wrapped provider
+type faultyProvider struct {
+	ObjectStorageProvider
+	config   FaultConfig
+	attempts atomic.Int64
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// conditionalFaultyProvider additionally forwards conditional uploads
+type conditionalFaultyProvider struct {
+	*faultyProvider
+	conditional ConditionalUploader
+}
+
+// NewFaultyProvider wraps provider so that its operations fail, slow down or write partial data
+// as configured, for testing retry and recovery logic. Injected errors wrap ErrInjectedFailure.
+// Conditional upload support is preserved.
+func NewFaultyProvider(provider ObjectStorageProvider, config FaultConfig) ObjectStorageProvider {
+	if provider == nil {
+		return provider
+	}
+	seed := config.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	p := &faultyProvider{
+		ObjectStorageProvider: provider,
+		config:                config,
+		rng:                   rand.New(rand.NewPCG(seed, seed)),
+	}
+	if conditional, ok := provider.(ConditionalUploader); ok {
+		return &conditionalFaultyProvider{faultyProvider: p, conditional: conditional}
+	}
+	return p
+}
+
+// float64 returns a random number in [0, 1)
+func (p *faultyProvider) float64() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rng.Float64()
+}
+
+// affects reports whether faults are injected into op
+func (p *faultyProvider) affects(op FaultOperation) bool {
+	return len(p.config.Operations) == 0 || slices.Contains(p.config.Operations, op)
+}
+
+// inject applies latency and decides whether op fails
+func (p *faultyProvider) inject(ctx context.Context, op FaultOperation, path string) error {
+	if !p.affects(op) {
+		return nil
+	}
+
+	delay := p.config.Latency
+	if p.config.LatencyJitter > 0 {
+		delay += time.Duration(p.float64() * float64(p.config.LatencyJitter))
+	}
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	if p.attempts.Add(1) <= int64(p.config.FailFirst) {
+		return fmt.Errorf("%w: %s %s", ErrInjectedFailure, op, path)
+	}
+	if p.config.ErrorRate > 0 && p.float64() < p.config.ErrorRate {
+		return fmt.Errorf("%w: %s %s", ErrInjectedFailure, op, path)
+	}
+	return nil
+}
+
+// partialWrite decides whether an upload writes partial data, and returns the data to write instead
+func (p *faultyProvider) partialWrite(data io.Reader) (io.Reader, bool, error) {
+	if p.config.PartialWriteRate <= 0 || p.float64() >= p.config.PartialWriteRate {
+		return data, false, nil
+	}
+	content, err := io.ReadAll(data)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read upload data: %w", err)
+	}
+	return bytes.NewReader(content[:len(content)/2]), true, nil
+}
+
+// upload injects faults around upload
+func (p *faultyProvider) upload(ctx context.Context, path string, data io.Reader, upload func(context.Context, string, io.Reader) error) error {
+	if err := p.inject(ctx, FaultOpUpload, path); err != nil {
+		return err
+	}
+	if !p.affects(FaultOpUpload) {
+		return upload(ctx, path, data)
+	}
+	body, partial, err := p.partialWrite(data)
+	if err != nil {
+		return err
+	}
+	if err := upload(ctx, path, body); err != nil {
+		return err
+	}
+	if partial {
+		return fmt.Errorf("%w: partial write %s", ErrInjectedFailure, path)
+	}
+	return nil
+}
+
+// Upload implements ObjectStorageProvider interface
+func (p *faultyProvider) Upload(ctx context.Context, path string, data io.Reader) error {
+	return p.upload(ctx, path, data, p.ObjectStorageProvider.Upload)
+}
+
+// Download implements ObjectStorageProvider interface
+func (p *faultyProvider) Download(ctx context.Context, path string) (io.ReadCloser, error) {
+	if err := p.inject(ctx, FaultOpDownload, path); err != nil {
+		return nil, err
+	}
+	return p.ObjectStorageProvider.Download(ctx, path)
+}
+
+// Delete implements ObjectStorageProvider interface
+func (p *faultyProvider) Delete(ctx context.Context, path string) error {
+	if err := p.inject(ctx, FaultOpDelete, path); err != nil {
+		return err
+	}
+	return p.ObjectStorageProvider.Delete(ctx, path)
+}
+
+// Exists implements ObjectStorageProvider interface
+func (p *faultyProvider) Exists(ctx context.Context, path string) (bool, error) {
+	if err := p.inject(ctx, FaultOpExists, path); err != nil {
+		return false, err
+	}
+	return p.ObjectStorageProvider.Exists(ctx, path)
+}
+
+// List implements ObjectStorageProvider interface
+func (p *faultyProvider) List(ctx context.Context, prefix string) ([]string, error) {
+	if err := p.inject(ctx, FaultOpList, prefix); err != nil {
+		return nil, err
+	}
+	return p.ObjectStorageProvider.List(ctx, prefix)
+}
+
+// UploadIfNotExists implements ConditionalUploader interface
+func (p *conditionalFaultyProvider) UploadIfNotExists(ctx context.Context, path string, data io.Reader) error {
+	return p.upload(ctx, path, data, p.conditional.UploadIfNotExists)
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFaultyProviderFailFirst(t *testing.T) {
+	inner := NewMemoryProvider()
+	faulty := NewFaultyProvider(inner, FaultConfig{Operations: []FaultOperation{FaultOpUpload}, FailFirst: 2})
+	_, ok := faulty.(ConditionalUploader)
+	assert.True(t, ok, "conditional upload support should be preserved")
+
+	ctx := context.Background()
+	assert.ErrorIs(t, faulty.Upload(ctx, "a.txt", strings.NewReader("a")), ErrInjectedFailure)
+	assert.ErrorIs(t, faulty.Upload(ctx, "a.txt", strings.NewReader("a")), ErrInjectedFailure)
+	require.NoError(t, faulty.Upload(ctx, "a.txt", strings.NewReader("a")))
+
+	// Other operations are unaffected
+	exists, err := faulty.Exists(ctx, "a.txt")
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestFaultyProviderErrorRate(t *testing.T) {
+	faulty := NewFaultyProvider(NewMemoryProvider(), FaultConfig{ErrorRate: 0.5, Seed: 42})
+	ctx := context.Background()
+
+	failures := 0
+	for i := 0; i < 200; i++ {
+		if _, err := faulty.List(ctx, ""); err != nil {
+			assert.ErrorIs(t, err, ErrInjectedFailure)
+			failures++
+		}
+	}
+	assert.InDelta(t, 100, failures, 30)
+}
+
+func TestFaultyProviderPartialWrite(t *testing.T) {
+	inner := NewMemoryProvider()
+	faulty := NewFaultyProvider(inner, FaultConfig{PartialWriteRate: 1})
+	ctx := context.Background()
+
+	err := faulty.(ConditionalUploader).UploadIfNotExists(ctx, "a.txt", strings.NewReader("abcdef"))
+	assert.ErrorIs(t, err, ErrInjectedFailure)
+
+	rc, err := inner.Download(ctx, "a.txt")
+	require.NoError(t, err)
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", string(data))
+}
+
+func TestFaultyProviderLatency(t *testing.T) {
+	faulty := NewFaultyProvider(NewMemoryProvider(), FaultConfig{Latency: 50 * time.Millisecond})
+
+	start := time.Now()
+	_, err := faulty.Exists(context.Background(), "a.txt")
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = faulty.Download(ctx, "a.txt")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// DefaultListPageSize number of paths per page when ListPages falls back to List
+const DefaultListPageSize = 1000
+
+// PageLister is implemented by providers that can list a prefix page by page, so callers
+// listing very large prefixes don't need to hold every path in memory
+type PageLister interface {
+	// ListPages calls fn with successive pages of the objects under prefix, stopping at the first
+	// error returned by fn or by the listing. The order of paths is provider-specific.
+	ListPages(ctx context.Context, prefix string, fn func(page []string) error) error
+}
+
+// PageTokenLister is implemented by providers that list one page per call, resuming from a continuation
+// token, so callers such as paginated APIs don't need to keep a listing open between pages
+type PageTokenLister interface {
+	// ListPage lists at most limit objects under prefix, continuing after token, "" for the first page.
+	// It returns the token of the next page, "" after the last page. Tokens are provider-specific.
+	ListPage(ctx context.Context, prefix, token string, limit int) ([]string, string, error)
+}
+
+// PrefixLister is implemented by providers that can list the distinct "directories" under a prefix
+// with a single delimiter-based listing, without listing every object
+type PrefixLister interface {
+	// ListCommonPrefixes returns the distinct prefixes of the objects under prefix up to and including
+	// the first delimiter after prefix, e.g. "a/b/" for "a/b/c/d" with prefix "a/" and delimiter "/".
+	ListCommonPrefixes(ctx context.Context, prefix, delimiter string) ([]string, error)
+}
+
+// AttributeLister is implemented by providers whose listings include the size and modification time of
+// objects, saving a Stat per object
+type AttributeLister interface {
+	// ListAttributes returns the attributes of the objects under prefix, with paths as returned by List.
+	// Metadata is never set, and the ETag may be empty.
+	ListAttributes(ctx context.Context, prefix string) ([]ObjectAttributes, error)
+}
+
+// ListPages lists the objects under prefix page by page, using provider's PageLister or PageTokenLister
+// support if available and otherwise splitting the result of List into pages of DefaultListPageSize paths
+func ListPages(ctx context.Context, provider ObjectStorageProvider, prefix string, fn func(page []string) error) error {
+	if lister, ok := provider.(PageLister); ok {
+		return lister.ListPages(ctx, prefix, fn)
+	}
+	if lister, ok := provider.(PageTokenLister); ok {
+		return ListTokenPages(ctx, lister, prefix, fn)
+	}
+
+	paths, err := provider.List(ctx, prefix)
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(paths); start += DefaultListPageSize {
+		if err := fn(paths[start:min(start+DefaultListPageSize, len(paths))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListTokenPages lists the objects under prefix page by page with lister, following continuation tokens
+func ListTokenPages(ctx context.Context, lister PageTokenLister, prefix string, fn func(page []string) error) error {
+	for token := ""; ; {
+		page, next, err := lister.ListPage(ctx, prefix, token, DefaultListPageSize)
+		if err != nil {
+			return err
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		if next == "" {
+			return nil
+		}
+		token = next
+	}
+}
+
+// ListPage lists at most limit objects under prefix, continuing after token, "" for the first page, and
+// returns the token of the next page, "" after the last page. limit defaults to DefaultListPageSize.
+// Providers without PageTokenLister support are listed in full and the path of the last object
+// returned is used as token.
+func ListPage(ctx context.Context, provider ObjectStorageProvider, prefix, token string, limit int) ([]string, string, error) {
+	if limit <= 0 {
+		limit = DefaultListPageSize
+	}
+	if lister, ok := provider.(PageTokenLister); ok {
+		return lister.ListPage(ctx, prefix, token, limit)
+	}
+
+	paths, err := provider.List(ctx, prefix)
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Strings(paths)
+	start := 0
+	if token != "" {
+		start = sort.Search(len(paths), func(i int) bool { return paths[i] > token })
+	}
+	end := min(start+limit, len(paths))
+	page := paths[start:end]
+	if end == len(paths) {
+		return page, "", nil
+	}
+	return page, page[len(page)-1], nil
+}
+
+// ListAttributes returns the attributes of the objects under prefix, see AttributeLister. Providers without
+// AttributeLister support are listed and every object is passed to Stat.
+func ListAttributes(ctx context.Context, provider ObjectStorageProvider, prefix string) ([]ObjectAttributes, error) {
+	if lister, ok := provider.(AttributeLister); ok {
+		return lister.ListAttributes(ctx, prefix)
+	}
+
+	paths, err := provider.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]ObjectAttributes, 0, len(paths))
+	for _, path := range paths {
+		attrs, err := Stat(ctx, provider, path)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, *attrs)
+	}
+	return objects, nil
+}
+
+// ListCommonPrefixes returns the sorted distinct prefixes of the objects under prefix up to and including
+// the first delimiter after prefix, see PrefixLister. Providers without PrefixLister support are listed
+// page by page and the prefixes computed client-side.
+func ListCommonPrefixes(ctx context.Context, provider ObjectStorageProvider, prefix, delimiter string) ([]string, error) {
+	if lister, ok := provider.(PrefixLister); ok {
+		prefixes, err := lister.ListCommonPrefixes(ctx, prefix, delimiter)
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(prefixes)
+		return prefixes, nil
+	}
+
+	seen := make(map[string]struct{})
+	err := ListPages(ctx, provider, prefix, func(page []string) error {
+		for _, path := range page {
+			// Listed paths may start with the provider's own prefix
+			start := strings.Index(path, prefix)
+			if start < 0 || delimiter == "" {
+				continue
+			}
+			end := start + len(prefix)
+			if i := strings.Index(path[end:], delimiter); i >= 0 {
+				seen[path[:end+i+len(delimiter)]] = struct{}{}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	prefixes := make([]string, 0, len(seen))
+	for p := range seen {
+		prefixes = append(prefixes, p)
+	}
+	sort.Strings(prefixes)
+	return prefixes, nil
+}
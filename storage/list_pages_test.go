@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListPages(t *testing.T) {
+	provider := NewMemoryProvider()
+	ctx := context.Background()
+	for i := 0; i < DefaultListPageSize+10; i++ {
+		require.NoError(t, provider.Upload(ctx, fmt.Sprintf("data/%05d", i), strings.NewReader("x")))
+	}
+
+	var sizes []int
+	err := ListPages(ctx, provider, "data/", func(page []string) error {
+		sizes = append(sizes, len(page))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int{DefaultListPageSize, 10}, sizes)
+
+	err = ListPages(ctx, provider, "data/", func([]string) error { return assert.AnError })
+	assert.ErrorIs(t, err, assert.AnError)
+}
+
+func TestListPage(t *testing.T) {
+	provider := NewMemoryProvider()
+	ctx := context.Background()
+	for _, path := range []string{"data/c", "data/a", "data/e", "data/b", "data/d", "other/f"} {
+		require.NoError(t, provider.Upload(ctx, path, strings.NewReader("x")))
+	}
+
+	var pages [][]string
+	for token := ""; ; {
+		page, next, err := ListPage(ctx, provider, "data/", token, 2)
+		require.NoError(t, err)
+		pages = append(pages, page)
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	assert.Equal(t, [][]string{{"data/a", "data/b"}, {"data/c", "data/d"}, {"data/e"}}, pages)
+
+	// The default limit lists everything at once
+	page, next, err := ListPage(ctx, provider, "data/", "", 0)
+	require.NoError(t, err)
+	assert.Len(t, page, 5)
+	assert.Empty(t, next)
+
+	// ListPages follows the tokens of a PageTokenLister
+	var listed []string
+	err = ListPages(ctx, tokenLister{provider}, "data/", func(page []string) error {
+		listed = append(listed, page...)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"data/a", "data/b", "data/c", "data/d", "data/e"}, listed)
+}
+
+// tokenLister lists its provider one page per call, with pages of two paths
+type tokenLister struct {
+	ObjectStorageProvider
+}
+
+func (l tokenLister) ListPage(ctx context.Context, prefix, token string, limit int) ([]string, string, error) {
+	return ListPage(ctx, l.ObjectStorageProvider, prefix, token, 2)
+}
+
+func TestListCommonPrefixes(t *testing.T) {
+	provider := NewMemoryProvider()
+	ctx := context.Background()
+	for _, path := range []string{"ts/1/tidb/a", "ts/1/tidb/b", "ts/1/tikv/c", "ts/1/file", "ts/2/pd/d"} {
+		require.NoError(t, provider.Upload(ctx, path, strings.NewReader("x")))
+	}
+
+	prefixes, err := ListCommonPrefixes(ctx, provider, "ts/", "/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ts/1/", "ts/2/"}, prefixes)
+
+	prefixes, err = ListCommonPrefixes(ctx, provider, "ts/1/", "/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ts/1/tidb/", "ts/1/tikv/"}, prefixes)
+
+	prefixes, err = ListCommonPrefixes(ctx, provider, "ts/1/ti", "/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ts/1/tidb/", "ts/1/tikv/"}, prefixes)
+}
+
+func TestListAttributes(t *testing.T) {
+	provider := NewMemoryProvider()
+	ctx := context.Background()
+	for _, path := range []string{"meta/1", "meta/22", "other/333"} {
+		require.NoError(t, provider.Upload(ctx, path, strings.NewReader(path)))
+	}
+
+	calls := &CallCounter{}
+	// Native listings take a single request, without native support every object is stat'ed
+	for _, lister := range []ObjectStorageProvider{
+		NewCountingProvider(provider, calls),
+		NewCountingProvider(struct{ ObjectStorageProvider }{provider}, calls),
+	} {
+		objects, err := ListAttributes(ctx, lister, "meta/")
+		require.NoError(t, err)
+		require.Len(t, objects, 2)
+		assert.Equal(t, "meta/1", objects[0].Path)
+		assert.Equal(t, int64(len("meta/1")), objects[0].Size)
+		assert.Equal(t, "meta/22", objects[1].Path)
+		assert.Equal(t, int64(len("meta/22")), objects[1].Size)
+	}
+	assert.Equal(t, CallStats{Lists: 2, Heads: 2, Gets: 2}, calls.Stats())
+}
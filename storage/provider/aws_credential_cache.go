@@ -0,0 +1,58 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+)
+
+// awsAssumeRoleDuration validity of AWS assume role credentials, long enough for the refresh threshold
+const awsAssumeRoleDuration = time.Hour
+
+// NewAWSCredentialCache creates a credential cache assuming roleARN with client, an STS client using the
+// base credentials. Like the OSS cache, credentials are refreshed 15 minutes before they expire instead of
+// when the SDK finds them expired in the middle of an upload. The cache implements aws.CredentialsProvider
+func NewAWSCredentialCache(client stscreds.AssumeRoleAPIClient, roleARN string) (*CredentialCache, error) {
+	if roleARN == "" {
+		return nil, fmt.Errorf("assume role ARN is required")
+	}
+
+	assumeRole := stscreds.NewAssumeRoleProvider(client, roleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.Duration = awsAssumeRoleDuration
+	})
+	return &CredentialCache{
+		assumeRoleARN:    roleARN,
+		refreshThreshold: defaultRefreshThreshold,
+		assumeRole: func(ctx context.Context) (*AssumeRoleCredentials, error) {
+			creds, err := assumeRole.Retrieve(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to assume role: %w", err)
+			}
+			return &AssumeRoleCredentials{
+				AccessKeyID:     creds.AccessKeyID,
+				AccessKeySecret: creds.SecretAccessKey,
+				SecurityToken:   creds.SessionToken,
+				Expiration:      creds.Expires,
+			}, nil
+		},
+	}, nil
+}
+
+// Retrieve implements aws.CredentialsProvider interface
+func (c *CredentialCache) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	cached, err := c.get(ctx)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	return aws.Credentials{
+		AccessKeyID:     cached.AccessKeyID,
+		SecretAccessKey: cached.AccessKeySecret,
+		SessionToken:    cached.SecurityToken,
+		Source:          "CredentialCache",
+		CanExpire:       !cached.Expiration.IsZero(),
+		Expires:         cached.Expiration,
+	}, nil
+}
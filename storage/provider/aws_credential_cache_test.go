@@ -0,0 +1,85 @@
+package provider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSTS returns credentials expiring after validity
+type fakeSTS struct {
+	calls    int
+	validity time.Duration
+	inputs   []*sts.AssumeRoleInput
+}
+
+func (f *fakeSTS) AssumeRole(ctx context.Context, params *sts.AssumeRoleInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleOutput, error) {
+	f.calls++
+	f.inputs = append(f.inputs, params)
+	return &sts.AssumeRoleOutput{Credentials: &types.Credentials{
+		AccessKeyId:     aws.String("assumed-key"),
+		SecretAccessKey: aws.String("assumed-secret"),
+		SessionToken:    aws.String("assumed-token"),
+		Expiration:      aws.Time(time.Now().Add(f.validity)),
+	}}, nil
+}
+
+func TestAWSCredentialCache(t *testing.T) {
+	_, err := NewAWSCredentialCache(&fakeSTS{}, "")
+	assert.Error(t, err)
+
+	client := &fakeSTS{validity: time.Hour}
+	cache, err := NewAWSCredentialCache(client, "arn:aws:iam::123456789012:role/MeteringWriterRole")
+	require.NoError(t, err)
+	var _ aws.CredentialsProvider = cache
+
+	ctx := context.Background()
+	for range 3 {
+		creds, err := cache.Retrieve(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "assumed-key", creds.AccessKeyID)
+		assert.Equal(t, "assumed-secret", creds.SecretAccessKey)
+		assert.Equal(t, "assumed-token", creds.SessionToken)
+		assert.True(t, creds.CanExpire)
+	}
+	assert.Equal(t, 1, client.calls, "credentials should be cached")
+	assert.Equal(t, "arn:aws:iam::123456789012:role/MeteringWriterRole", aws.ToString(client.inputs[0].RoleArn))
+	assert.Equal(t, int32(3600), aws.ToInt32(client.inputs[0].DurationSeconds))
+
+	// Credentials within the refresh threshold are refreshed before they expire
+	client.validity = 10 * time.Minute
+	cache.cached.Expiration = time.Now().Add(10 * time.Minute)
+	_, err = cache.Retrieve(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, client.calls)
+	assert.Equal(t, int64(2), cache.Credentials().RefreshCount)
+}
+
+func TestNewS3Provider_AssumeRoleCache(t *testing.T) {
+	provider, err := NewS3Provider(&ProviderConfig{
+		Type:   ProviderTypeS3,
+		Bucket: "metering",
+		Region: "us-west-2",
+		AWS: &AWSConfig{
+			AccessKey:       "base-key",
+			SecretAccessKey: "base-secret",
+			AssumeRoleARN:   "arn:aws:iam::123456789012:role/MeteringWriterRole",
+		},
+	})
+	require.NoError(t, err)
+	state, ok := provider.Credentials()
+	require.True(t, ok)
+	assert.Zero(t, state.RefreshCount, "credentials are assumed on first use")
+	assert.Same(t, provider.credentials, provider.client.Options().Credentials)
+
+	provider, err = NewS3Provider(&ProviderConfig{Type: ProviderTypeS3, Bucket: "metering", Region: "us-west-2"})
+	require.NoError(t, err)
+	_, ok = provider.Credentials()
+	assert.False(t, ok)
+}
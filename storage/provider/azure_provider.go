@@ -12,6 +12,9 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
 )
 
 // AzureProvider Azure Blob Storage provider implementation
@@ -35,7 +38,12 @@ func NewAzureProvider(providerConfig *ProviderConfig) (*AzureProvider, error) {
 		return nil, err
 	}
 
-	client, err := buildAzureClient(serviceURL, providerConfig.Azure)
+	var options *azblob.ClientOptions
+	if providerConfig.RateLimit != nil {
+		options = &azblob.ClientOptions{}
+		options.Transport = NewRateLimitedDoer(nil, providerConfig.RateLimit)
+	}
+	client, err := buildAzureClient(serviceURL, providerConfig.Azure, options)
 	if err != nil {
 		return nil, err
 	}
@@ -63,7 +71,7 @@ func buildAzureServiceURL(providerConfig *ProviderConfig) (string, error) {
 	return fmt.Sprintf("https://%s.blob.core.windows.net", accountName), nil
 }
 
-func buildAzureClient(serviceURL string, azureConfig *AzureConfig) (*azblob.Client, error) {
+func buildAzureClient(serviceURL string, azureConfig *AzureConfig, options *azblob.ClientOptions) (*azblob.Client, error) {
 	if azureConfig != nil && azureConfig.AccountKey != "" {
 		if azureConfig.AccountName == "" {
 			return nil, fmt.Errorf("azure account name is required when account key is set")
@@ -72,7 +80,7 @@ func buildAzureClient(serviceURL string, azureConfig *AzureConfig) (*azblob.Clie
 		if err != nil {
 			return nil, fmt.Errorf("failed to create Azure shared key credential: %w", err)
 		}
-		return azblob.NewClientWithSharedKeyCredential(serviceURL, cred, nil)
+		return azblob.NewClientWithSharedKeyCredential(serviceURL, cred, options)
 	}
 
 	if azureConfig != nil && azureConfig.SASToken != "" {
@@ -80,14 +88,14 @@ func buildAzureClient(serviceURL string, azureConfig *AzureConfig) (*azblob.Clie
 		if err != nil {
 			return nil, err
 		}
-		return azblob.NewClientWithNoCredential(sasURL, nil)
+		return azblob.NewClientWithNoCredential(sasURL, options)
 	}
 
 	credential, err := azidentity.NewDefaultAzureCredential(nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create default Azure credential: %w", err)
 	}
-	return azblob.NewClient(serviceURL, credential, nil)
+	return azblob.NewClient(serviceURL, credential, options)
 }
 
 func appendSASToken(serviceURL, sasToken string) (string, error) {
@@ -124,8 +132,30 @@ func (a *AzureProvider) Upload(ctx context.Context, path string, data io.Reader)
 	_, err := a.client.ServiceClient().
 		NewContainerClient(a.container).
 		NewBlockBlobClient(fullPath).
-		UploadStream(ctx, data, nil)
-	return err
+		UploadStream(ctx, data, &blockblob.UploadStreamOptions{HTTPHeaders: azureHTTPHeaders(ctx)})
+	return classifyError(err)
+}
+
+// UploadIfNotExists uploads data only if no blob exists at path, using If-None-Match: *
+func (a *AzureProvider) UploadIfNotExists(ctx context.Context, path string, data io.Reader) error {
+	fullPath := a.buildPath(path)
+	etagAny := azcore.ETagAny
+	_, err := a.client.ServiceClient().
+		NewContainerClient(a.container).
+		NewBlockBlobClient(fullPath).
+		UploadStream(ctx, data, &blockblob.UploadStreamOptions{
+			HTTPHeaders: azureHTTPHeaders(ctx),
+			AccessConditions: &blob.AccessConditions{
+				ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfNoneMatch: &etagAny},
+			},
+		})
+	if err != nil {
+		if isAzureAlreadyExists(err) {
+			return fmt.Errorf("%w: %s", ErrObjectExists, path)
+		}
+		return classifyError(err)
+	}
+	return nil
 }
 
 // Download implements ObjectStorageProvider interface
@@ -136,7 +166,7 @@ func (a *AzureProvider) Download(ctx context.Context, path string) (io.ReadClose
 		NewBlobClient(fullPath).
 		DownloadStream(ctx, nil)
 	if err != nil {
-		return nil, err
+		return nil, classifyError(err)
 	}
 	return result.Body, nil
 }
@@ -149,7 +179,7 @@ func (a *AzureProvider) Delete(ctx context.Context, path string) error {
 		NewBlobClient(fullPath).
 		Delete(ctx, nil)
 	if err != nil && !isAzureNotFound(err) {
-		return err
+		return classifyError(err)
 	}
 	return nil
 }
@@ -165,32 +195,233 @@ func (a *AzureProvider) Exists(ctx context.Context, path string) (bool, error) {
 		if isAzureNotFound(err) {
 			return false, nil
 		}
-		return false, err
+		return false, classifyError(err)
 	}
 	return true, nil
 }
 
+// Stat implements storage.ObjectStater interface
+func (a *AzureProvider) Stat(ctx context.Context, path string) (*ObjectAttributes, error) {
+	result, err := a.client.ServiceClient().
+		NewContainerClient(a.container).
+		NewBlobClient(a.buildPath(path)).
+		GetProperties(ctx, nil)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	attrs := &ObjectAttributes{Path: path}
+	if result.ContentLength != nil {
+		attrs.Size = *result.ContentLength
+	}
+	if result.LastModified != nil {
+		attrs.LastModified = *result.LastModified
+	}
+	if result.ETag != nil {
+		attrs.ETag = string(*result.ETag)
+	}
+	for key, value := range result.Metadata {
+		if attrs.Metadata == nil {
+			attrs.Metadata = make(map[string]string, len(result.Metadata))
+		}
+		if value != nil {
+			attrs.Metadata[key] = *value
+		}
+	}
+	return attrs, nil
+}
+
 // List implements ObjectStorageProvider interface
 func (a *AzureProvider) List(ctx context.Context, prefix string) ([]string, error) {
+	var objects []string
+	err := a.ListPages(ctx, prefix, func(page []string) error {
+		objects = append(objects, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// ListPages implements storage.PageLister interface
+func (a *AzureProvider) ListPages(ctx context.Context, prefix string, fn func(page []string) error) error {
 	fullPrefix := a.buildPath(prefix)
 	pager := a.client.ServiceClient().
 		NewContainerClient(a.container).
 		NewListBlobsFlatPager(&azblob.ListBlobsFlatOptions{Prefix: &fullPrefix})
-	var objects []string
 	for pager.More() {
 		page, err := pager.NextPage(ctx)
 		if err != nil {
-			return nil, err
+			return classifyError(err)
 		}
+		objects := make([]string, 0, len(page.Segment.BlobItems))
 		for _, blob := range page.Segment.BlobItems {
 			if blob.Name != nil {
 				objects = append(objects, *blob.Name)
 			}
 		}
+		if err := fn(objects); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListAttributes implements storage.AttributeLister interface
+func (a *AzureProvider) ListAttributes(ctx context.Context, prefix string) ([]ObjectAttributes, error) {
+	fullPrefix := a.buildPath(prefix)
+	pager := a.client.ServiceClient().
+		NewContainerClient(a.container).
+		NewListBlobsFlatPager(&azblob.ListBlobsFlatOptions{Prefix: &fullPrefix})
+	var objects []ObjectAttributes
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, classifyError(err)
+		}
+		for _, blob := range page.Segment.BlobItems {
+			if blob.Name == nil {
+				continue
+			}
+			attrs := ObjectAttributes{Path: *blob.Name}
+			if props := blob.Properties; props != nil {
+				if props.ContentLength != nil {
+					attrs.Size = *props.ContentLength
+				}
+				if props.LastModified != nil {
+					attrs.LastModified = *props.LastModified
+				}
+				if props.ETag != nil {
+					attrs.ETag = string(*props.ETag)
+				}
+			}
+			objects = append(objects, attrs)
+		}
 	}
 	return objects, nil
 }
 
+// ListPage implements storage.PageTokenLister interface
+func (a *AzureProvider) ListPage(ctx context.Context, prefix, token string, limit int) ([]string, string, error) {
+	fullPrefix := a.buildPath(prefix)
+	maxResults := pageLimit(limit, 5000)
+	pager := a.client.ServiceClient().
+		NewContainerClient(a.container).
+		NewListBlobsFlatPager(&azblob.ListBlobsFlatOptions{
+			Prefix:     &fullPrefix,
+			Marker:     optionalString(token),
+			MaxResults: &maxResults,
+		})
+	page, err := pager.NextPage(ctx)
+	if err != nil {
+		return nil, "", classifyError(err)
+	}
+
+	objects := make([]string, 0, len(page.Segment.BlobItems))
+	for _, blob := range page.Segment.BlobItems {
+		if blob.Name != nil {
+			objects = append(objects, *blob.Name)
+		}
+	}
+	var next string
+	if page.NextMarker != nil {
+		next = *page.NextMarker
+	}
+	return objects, next, nil
+}
+
+// ListCommonPrefixes implements storage.PrefixLister interface
+func (a *AzureProvider) ListCommonPrefixes(ctx context.Context, prefix, delimiter string) ([]string, error) {
+	fullPrefix := a.buildPath(prefix)
+	pager := a.client.ServiceClient().
+		NewContainerClient(a.container).
+		NewListBlobsHierarchyPager(delimiter, &container.ListBlobsHierarchyOptions{Prefix: &fullPrefix})
+	var prefixes []string
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, classifyError(err)
+		}
+		for _, blobPrefix := range page.Segment.BlobPrefixes {
+			if blobPrefix.Name != nil {
+				prefixes = append(prefixes, *blobPrefix.Name)
+			}
+		}
+	}
+	return prefixes, nil
+}
+
+// DownloadVersion implements storage.VersionedProvider interface
+func (a *AzureProvider) DownloadVersion(ctx context.Context, path string, versionID string) (io.ReadCloser, error) {
+	fullPath := a.buildPath(path)
+	blobClient, err := a.client.ServiceClient().
+		NewContainerClient(a.container).
+		NewBlobClient(fullPath).
+		WithVersionID(versionID)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	result, err := blobClient.DownloadStream(ctx, nil)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	return result.Body, nil
+}
+
+// ListVersions implements storage.VersionedProvider interface. Azure has no delete markers,
+// a deleted blob only has non-current versions.
+func (a *AzureProvider) ListVersions(ctx context.Context, path string) ([]ObjectVersion, error) {
+	fullPath := a.buildPath(path)
+	pager := a.client.ServiceClient().
+		NewContainerClient(a.container).
+		NewListBlobsFlatPager(&azblob.ListBlobsFlatOptions{
+			Prefix:  &fullPath,
+			Include: azblob.ListBlobsInclude{Versions: true},
+		})
+	var versions []ObjectVersion
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, classifyError(err)
+		}
+		// The prefix also matches longer names, keep only the blob itself
+		for _, blob := range page.Segment.BlobItems {
+			if blob.Name == nil || *blob.Name != fullPath || blob.VersionID == nil {
+				continue
+			}
+			version := ObjectVersion{
+				Path:      path,
+				VersionID: *blob.VersionID,
+				IsLatest:  blob.IsCurrentVersion != nil && *blob.IsCurrentVersion,
+			}
+			if blob.Properties != nil {
+				if blob.Properties.LastModified != nil {
+					version.LastModified = *blob.Properties.LastModified
+				}
+				if blob.Properties.ContentLength != nil {
+					version.Size = *blob.Properties.ContentLength
+				}
+			}
+			versions = append(versions, version)
+		}
+	}
+	sortVersions(versions)
+	return versions, nil
+}
+
+// azureHTTPHeaders returns the blob HTTP headers for the UploadOptions carried by ctx
+func azureHTTPHeaders(ctx context.Context) *blob.HTTPHeaders {
+	opts := UploadOptionsFromContext(ctx)
+	if opts == nil {
+		return nil
+	}
+	return &blob.HTTPHeaders{
+		BlobContentType:     optionalString(opts.ContentType),
+		BlobContentEncoding: optionalString(opts.ContentEncoding),
+		BlobCacheControl:    optionalString(opts.CacheControl),
+	}
+}
+
 func isAzureNotFound(err error) bool {
 	var respErr *azcore.ResponseError
 	if errors.As(err, &respErr) {
@@ -204,3 +435,14 @@ func isAzureNotFound(err error) bool {
 	}
 	return false
 }
+
+func isAzureAlreadyExists(err error) bool {
+	var respErr *azcore.ResponseError
+	if errors.As(err, &respErr) {
+		switch respErr.ErrorCode {
+		case "BlobAlreadyExists", "ConditionNotMet":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,200 @@
+package provider
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	stsclient "github.com/alibabacloud-go/sts-20150401/v2/client"
+	openapicred "github.com/aliyun/credentials-go/credentials"
+)
+
+// defaultRefreshThreshold how long before expiration cached credentials are refreshed
+const defaultRefreshThreshold = 15 * time.Minute
+
+// AssumeRoleCredentials represents the credentials obtained from assume role
+type AssumeRoleCredentials struct {
+	AccessKeyID     string
+	AccessKeySecret string
+	SecurityToken   string
+	Expiration      time.Time
+}
+
+// CredentialState snapshot of a credential cache for monitoring, without the secrets
+type CredentialState struct {
+	AccessKeyID  string    `json:"access_key_id,omitempty"` // access key ID of the cached credentials, empty before the first refresh
+	Expiration   time.Time `json:"expiration"`              // expiration of the cached credentials, zero before the first refresh
+	LastRefresh  time.Time `json:"last_refresh"`            // time of the last successful refresh
+	RefreshCount int64     `json:"refresh_count"`           // number of successful refreshes
+	ErrorCount   int64     `json:"error_count"`             // number of failed refreshes
+	LastError    error     `json:"-"`                       // error of the last refresh, nil once a refresh succeeds
+}
+
+// RefreshErrorFunc is called when refreshing cached credentials fails, with the state after the failure.
+// state.Expiration tells how long the cached credentials remain usable
+type RefreshErrorFunc func(err error, state CredentialState)
+
+// CredentialCache manages the caching of assume role credentials. Credentials are refreshed once they are
+// about to expire, by the first caller or by the background refresh, while other callers keep using the
+// cached ones. It backs both the OSS and the S3 providers, see NewCredentialCache and NewAWSCredentialCache.
+type CredentialCache struct {
+	mu               sync.RWMutex
+	baseCred         openapicred.Credential // OSS only
+	assumeRoleARN    string
+	region           string // OSS only
+	cached           *AssumeRoleCredentials
+	stsCli           *stsclient.Client // OSS only
+	refreshThreshold time.Duration     // refresh threshold, how long before expiration to start refreshing
+	refreshing       bool
+	refreshCount     int64
+	errorCount       int64
+	lastRefresh      time.Time
+	lastError        error
+	onRefreshError   RefreshErrorFunc
+	// assumeRole fetches new credentials
+	assumeRole func(ctx context.Context) (*AssumeRoleCredentials, error)
+}
+
+// WithOnRefreshError sets a callback invoked when a refresh fails, including background refreshes, so
+// operators can alert before the cached credentials expire. It must be set before the cache is used
+func (c *CredentialCache) WithOnRefreshError(fn RefreshErrorFunc) *CredentialCache {
+	c.onRefreshError = fn
+	return c
+}
+
+// Credentials returns the current state of the cache
+func (c *CredentialCache) Credentials() CredentialState {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.stateLocked()
+}
+
+// stateLocked returns the current state of the cache, c.mu must be held
+func (c *CredentialCache) stateLocked() CredentialState {
+	state := CredentialState{
+		LastRefresh:  c.lastRefresh,
+		RefreshCount: c.refreshCount,
+		ErrorCount:   c.errorCount,
+		LastError:    c.lastError,
+	}
+	if c.cached != nil {
+		state.AccessKeyID = c.cached.AccessKeyID
+		state.Expiration = c.cached.Expiration
+	}
+	return state
+}
+
+// get returns cached credentials or fetches new ones if needed
+func (c *CredentialCache) get(ctx context.Context) (AssumeRoleCredentials, error) {
+	c.mu.RLock()
+	cached := c.cached
+	refreshing := c.refreshing
+	c.mu.RUnlock()
+
+	// check if refresh is needed
+	if cached == nil || c.needsRefresh(cached.Expiration) {
+		// if no cache or needs refresh, and not currently refreshing
+		if !refreshing {
+			return c.refreshCredentials(ctx)
+		}
+		// if refreshing but has cache, return cached credentials
+		if cached != nil {
+			// Create local copy to avoid potential race condition
+			return *cached, nil
+		}
+		// if refreshing and no cache, wait for refresh to complete
+		return c.waitForRefresh(ctx)
+	}
+
+	// Create local copy to avoid potential race condition
+	return *cached, nil
+}
+
+// needsRefresh checks if credentials need to be refreshed
+func (c *CredentialCache) needsRefresh(expiration time.Time) bool {
+	return time.Now().Add(c.refreshThreshold).After(expiration)
+}
+
+// refreshCredentials fetches new credentials using assume role
+func (c *CredentialCache) refreshCredentials(ctx context.Context) (AssumeRoleCredentials, error) {
+	c.mu.Lock()
+
+	// double check to prevent concurrent refresh
+	if c.refreshing {
+		c.mu.Unlock()
+		return c.waitForRefresh(ctx)
+	}
+
+	c.refreshing = true
+	fetched, err := c.assumeRole(ctx)
+	c.refreshing = false
+	if err != nil {
+		c.errorCount++
+		c.lastError = err
+		state, onRefreshError := c.stateLocked(), c.onRefreshError
+		c.mu.Unlock()
+
+		// Call back without holding the lock, so the callback can inspect the cache
+		if onRefreshError != nil {
+			onRefreshError(err, state)
+		}
+		return AssumeRoleCredentials{}, err
+	}
+
+	// cache new credentials
+	c.cached = fetched
+	c.refreshCount++
+	c.lastRefresh = time.Now()
+	c.lastError = nil
+	c.mu.Unlock()
+
+	return *fetched, nil
+}
+
+// waitForRefresh waits for ongoing refresh to complete
+func (c *CredentialCache) waitForRefresh(ctx context.Context) (AssumeRoleCredentials, error) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return AssumeRoleCredentials{}, ctx.Err()
+		case <-ticker.C:
+			c.mu.RLock()
+			refreshing := c.refreshing
+			cached := c.cached
+			c.mu.RUnlock()
+
+			if !refreshing && cached != nil {
+				// Create local copy to avoid potential race condition
+				return *cached, nil
+			}
+		}
+	}
+}
+
+// StartBackgroundRefresh starts a background goroutine to refresh credentials
+func (c *CredentialCache) StartBackgroundRefresh(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(5 * time.Minute) // check every 5 minutes
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.mu.RLock()
+				cached := c.cached
+				refreshing := c.refreshing
+				c.mu.RUnlock()
+
+				if cached != nil && !refreshing && c.needsRefresh(cached.Expiration) {
+					// background refresh, errors are reported to OnRefreshError and retried on the next call
+					_, _ = c.refreshCredentials(context.Background())
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,37 @@
+package provider
+
+// Description identifies the storage behind a provider, e.g. in logs
+type Description struct {
+	Type   ProviderType // provider type
+	Bucket string       // bucket or container, empty for providers without one
+}
+
+// Describe returns the description of the provider
+func (s *S3Provider) Describe() Description {
+	return Description{Type: ProviderTypeS3, Bucket: s.bucket}
+}
+
+// Describe returns the description of the provider
+func (o *OSSProvider) Describe() Description {
+	return Description{Type: ProviderTypeOSS, Bucket: o.bucket}
+}
+
+// Describe returns the description of the provider
+func (a *AzureProvider) Describe() Description {
+	return Description{Type: ProviderTypeAzure, Bucket: a.container}
+}
+
+// Describe returns the description of the provider
+func (l *LocalFSProvider) Describe() Description {
+	return Description{Type: ProviderTypeLocalFS}
+}
+
+// Describe returns the description of the provider
+func (s *SFTPProvider) Describe() Description {
+	return Description{Type: ProviderTypeSFTP}
+}
+
+// Describe returns the description of the provider
+func (m *MemoryProvider) Describe() Description {
+	return Description{Type: ProviderTypeMemory}
+}
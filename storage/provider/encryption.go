@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// s3Encryption server-side encryption settings sent with S3 uploads
+type s3Encryption struct {
+	mode      types.ServerSideEncryption
+	kmsKeyID  *string
+	bucketKey *bool
+}
+
+// newS3Encryption validates the server-side encryption settings of cfg, a KMS key implies SSE-KMS
+func newS3Encryption(cfg *AWSConfig) (s3Encryption, error) {
+	if cfg == nil {
+		return s3Encryption{}, nil
+	}
+	mode := types.ServerSideEncryption(cfg.ServerSideEncryption)
+	if mode == "" && cfg.SSEKMSKeyID != "" {
+		mode = types.ServerSideEncryptionAwsKms
+	}
+
+	switch mode {
+	case "":
+		if cfg.SSEBucketKeyEnabled {
+			return s3Encryption{}, fmt.Errorf("S3 bucket key requires SSE-KMS encryption")
+		}
+		return s3Encryption{}, nil
+	case types.ServerSideEncryptionAes256:
+		if cfg.SSEKMSKeyID != "" || cfg.SSEBucketKeyEnabled {
+			return s3Encryption{}, fmt.Errorf("KMS key and bucket key require SSE-KMS encryption, got %s", mode)
+		}
+		return s3Encryption{mode: mode}, nil
+	case types.ServerSideEncryptionAwsKms, types.ServerSideEncryptionAwsKmsDsse:
+		encryption := s3Encryption{mode: mode, kmsKeyID: optionalString(cfg.SSEKMSKeyID)}
+		if cfg.SSEBucketKeyEnabled {
+			encryption.bucketKey = aws.Bool(true)
+		}
+		return encryption, nil
+	default:
+		return s3Encryption{}, fmt.Errorf("unsupported S3 server-side encryption %q, must be one of: AES256, aws:kms, aws:kms:dsse", mode)
+	}
+}
+
+// ossEncryption server-side encryption settings sent with OSS uploads
+type ossEncryption struct {
+	mode     *string
+	kmsKeyID *string
+}
+
+// newOSSEncryption validates the server-side encryption settings of cfg, a KMS key implies KMS encryption
+func newOSSEncryption(cfg *OSSConfig) (ossEncryption, error) {
+	if cfg == nil {
+		return ossEncryption{}, nil
+	}
+	mode := cfg.ServerSideEncryption
+	if mode == "" && cfg.SSEKMSKeyID != "" {
+		mode = "KMS"
+	}
+
+	switch mode {
+	case "":
+		return ossEncryption{}, nil
+	case "AES256", "SM4":
+		if cfg.SSEKMSKeyID != "" {
+			return ossEncryption{}, fmt.Errorf("KMS key requires KMS encryption, got %s", mode)
+		}
+		return ossEncryption{mode: &mode}, nil
+	case "KMS":
+		return ossEncryption{mode: &mode, kmsKeyID: optionalString(cfg.SSEKMSKeyID)}, nil
+	default:
+		return ossEncryption{}, fmt.Errorf("unsupported OSS server-side encryption %q, must be one of: AES256, KMS, SM4", mode)
+	}
+}
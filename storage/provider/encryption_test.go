@@ -0,0 +1,79 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewS3Encryption(t *testing.T) {
+	encryption, err := newS3Encryption(nil)
+	require.NoError(t, err)
+	assert.Equal(t, s3Encryption{}, encryption)
+
+	// A KMS key implies SSE-KMS
+	encryption, err = newS3Encryption(&AWSConfig{SSEKMSKeyID: "key-1", SSEBucketKeyEnabled: true})
+	require.NoError(t, err)
+	assert.Equal(t, types.ServerSideEncryptionAwsKms, encryption.mode)
+	assert.Equal(t, "key-1", *encryption.kmsKeyID)
+	assert.True(t, *encryption.bucketKey)
+
+	_, err = newS3Encryption(&AWSConfig{ServerSideEncryption: "AES256", SSEKMSKeyID: "key-1"})
+	assert.Error(t, err)
+	_, err = newS3Encryption(&AWSConfig{SSEBucketKeyEnabled: true})
+	assert.Error(t, err)
+	_, err = newS3Encryption(&AWSConfig{ServerSideEncryption: "rot13"})
+	assert.Error(t, err)
+}
+
+func TestNewOSSEncryption(t *testing.T) {
+	encryption, err := newOSSEncryption(&OSSConfig{SSEKMSKeyID: "key-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "KMS", *encryption.mode)
+	assert.Equal(t, "key-1", *encryption.kmsKeyID)
+
+	encryption, err = newOSSEncryption(&OSSConfig{ServerSideEncryption: "SM4"})
+	require.NoError(t, err)
+	assert.Equal(t, "SM4", *encryption.mode)
+	assert.Nil(t, encryption.kmsKeyID)
+
+	_, err = newOSSEncryption(&OSSConfig{ServerSideEncryption: "AES256", SSEKMSKeyID: "key-1"})
+	assert.Error(t, err)
+	_, err = newOSSEncryption(&OSSConfig{ServerSideEncryption: "aws:kms"})
+	assert.Error(t, err)
+}
+
+func TestS3ProviderEncryptionHeaders(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+	}))
+	defer server.Close()
+
+	provider, err := NewS3Provider(&ProviderConfig{
+		Type:     ProviderTypeS3,
+		Region:   "us-east-1",
+		Bucket:   "bucket",
+		Endpoint: server.URL,
+		AWS: &AWSConfig{
+			S3ForcePathStyle:    true,
+			AccessKey:           "access",
+			SecretAccessKey:     "secret",
+			SSEKMSKeyID:         "arn:aws:kms:us-east-1:123456789012:key/metering",
+			SSEBucketKeyEnabled: true,
+		},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, provider.Upload(context.Background(), "file.json.gz", strings.NewReader("data")))
+	header := <-headers
+	assert.Equal(t, "aws:kms", header.Get("X-Amz-Server-Side-Encryption"))
+	assert.Equal(t, "arn:aws:kms:us-east-1:123456789012:key/metering", header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+	assert.Equal(t, "true", header.Get("X-Amz-Server-Side-Encryption-Bucket-Key-Enabled"))
+}
@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/aws/smithy-go"
+)
+
+// Error classes of storage operations, matched with errors.Is. The S3, OSS and Azure providers wrap
+// service errors with the class matching their HTTP status or error code; the original error stays
+// available through errors.As.
+var (
+	// ErrNotFound the object or bucket does not exist
+	ErrNotFound = errors.New("object not found")
+	// ErrPermissionDenied the credentials are missing, invalid or not allowed to perform the operation
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrThrottled the service rejected the request because of its request rate
+	ErrThrottled = errors.New("request throttled")
+)
+
+// throttlingCodes service error codes of rejected request rates, some services send them with status 503
+var throttlingCodes = map[string]bool{
+	"SlowDown":             true,
+	"Throttling":           true,
+	"ThrottlingException":  true,
+	"RequestLimitExceeded": true,
+	"TooManyRequests":      true,
+	"ServerBusy":           true,
+	"QpsLimitExceeded":     true,
+}
+
+// classifiedError an error annotated with its class
+type classifiedError struct {
+	class error
+	err   error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() []error {
+	return []error{e.class, e.err}
+}
+
+// classifyError wraps err with the error class matching the HTTP status or error code of the
+// service error it contains. Errors that match no class are returned unchanged.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var class error
+	status, code := serviceErrorDetails(err)
+	switch {
+	case throttlingCodes[code] || status == http.StatusTooManyRequests:
+		class = ErrThrottled
+	case status == http.StatusNotFound || code == "NoSuchKey" || code == "NoSuchBucket" || code == "BlobNotFound":
+		class = ErrNotFound
+	case status == http.StatusUnauthorized || status == http.StatusForbidden || code == "AccessDenied":
+		class = ErrPermissionDenied
+	}
+	if class == nil || errors.Is(err, class) {
+		return err
+	}
+	return &classifiedError{class: class, err: err}
+}
+
+// serviceErrorDetails returns the HTTP status and error code of the AWS, OSS or Azure service error
+// contained in err, zero values if there is none
+func serviceErrorDetails(err error) (int, string) {
+	var status int
+	var code string
+
+	var awsStatus interface{ HTTPStatusCode() int }
+	if errors.As(err, &awsStatus) {
+		status = awsStatus.HTTPStatusCode()
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		code = apiErr.ErrorCode()
+	}
+
+	var ossErr interface {
+		HttpStatusCode() int
+		ErrorCode() string
+	}
+	if errors.As(err, &ossErr) {
+		status, code = ossErr.HttpStatusCode(), ossErr.ErrorCode()
+	}
+
+	var azureErr *azcore.ResponseError
+	if errors.As(err, &azureErr) {
+		status, code = azureErr.StatusCode, azureErr.ErrorCode
+	}
+	return status, code
+}
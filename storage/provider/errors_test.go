@@ -0,0 +1,81 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/aliyun/alibabacloud-oss-go-sdk-v2/oss"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyError(t *testing.T) {
+	awsStatusErr := func(status int, err error) error {
+		return &awshttp.ResponseError{ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: status}},
+			Err:      err,
+		}}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"s3 no such key", awsStatusErr(404, &smithy.GenericAPIError{Code: "NoSuchKey"}), ErrNotFound},
+		{"s3 access denied", awsStatusErr(403, &smithy.GenericAPIError{Code: "AccessDenied"}), ErrPermissionDenied},
+		{"s3 slow down", awsStatusErr(503, &smithy.GenericAPIError{Code: "SlowDown"}), ErrThrottled},
+		{"oss no such key", &oss.ServiceError{StatusCode: 404, Code: "NoSuchKey"}, ErrNotFound},
+		{"oss qps limit", &oss.ServiceError{StatusCode: 503, Code: "QpsLimitExceeded"}, ErrThrottled},
+		{"azure blob not found", &azcore.ResponseError{StatusCode: 404, ErrorCode: "BlobNotFound"}, ErrNotFound},
+		{"azure too many requests", &azcore.ResponseError{StatusCode: 429}, ErrThrottled},
+		{"azure unauthorized", &azcore.ResponseError{StatusCode: 401}, ErrPermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyError(fmt.Errorf("operation failed: %w", tt.err))
+			assert.ErrorIs(t, err, tt.want)
+			assert.Equal(t, "operation failed: "+tt.err.Error(), err.Error())
+
+			// The service error stays available
+			target := reflectTarget(tt.err)
+			assert.True(t, errors.As(err, target))
+		})
+	}
+
+	plain := errors.New("connection reset")
+	assert.Same(t, plain, classifyError(plain))
+	assert.Nil(t, classifyError(nil))
+}
+
+// reflectTarget returns a pointer errors.As can fill with an error of the type of err
+func reflectTarget(err error) any {
+	switch err.(type) {
+	case *awshttp.ResponseError:
+		return new(*awshttp.ResponseError)
+	case *oss.ServiceError:
+		return new(*oss.ServiceError)
+	default:
+		return new(*azcore.ResponseError)
+	}
+}
+
+func TestNotFoundErrors(t *testing.T) {
+	ctx := context.Background()
+
+	memory, err := NewMemoryProvider(&ProviderConfig{Type: ProviderTypeMemory})
+	assert.NoError(t, err)
+	_, err = memory.Download(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	localFS, err := NewLocalFSProvider(&ProviderConfig{Type: ProviderTypeLocalFS, LocalFS: &LocalFSConfig{BasePath: t.TempDir()}})
+	assert.NoError(t, err)
+	_, err = localFS.Download(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package provider
+
+// lockFile is a no-op on platforms without flock, uploads are still atomic
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
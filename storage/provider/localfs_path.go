@@ -0,0 +1,77 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrInvalidPath is returned by a LocalFS provider for keys that would resolve outside of its base path
+var ErrInvalidPath = errors.New("invalid path")
+
+// validateKey rejects keys with a ".." element or a volume name, which could escape the base path.
+// Leading separators are allowed, keys are relative to the base path like object keys to a bucket.
+func validateKey(key string) error {
+	if filepath.VolumeName(key) != "" {
+		return fmt.Errorf("%w: %s is absolute", ErrInvalidPath, key)
+	}
+	elems := strings.FieldsFunc(key, func(r rune) bool { return r == '/' || os.IsPathSeparator(uint8(r)) })
+	for _, elem := range elems {
+		if elem == ".." {
+			return fmt.Errorf("%w: %s contains a parent directory element", ErrInvalidPath, key)
+		}
+	}
+	return nil
+}
+
+// checkResolved verifies that fullPath stays under the base path once symlinks are resolved, so a
+// symlink inside the base path can't redirect reads and writes outside of it. Only the deepest
+// existing ancestor of fullPath is resolved, the rest doesn't exist and can't be a symlink. A symlink
+// swapped in after the check isn't caught, the base path must not be writable by untrusted users.
+func (l *LocalFSProvider) checkResolved(fullPath string) error {
+	basePath, err := filepath.Abs(l.basePath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve base directory %s: %w", l.basePath, err)
+	}
+	if fullPath, err = filepath.Abs(fullPath); err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", fullPath, err)
+	}
+	base, err := filepath.EvalSymlinks(basePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// Nothing exists under the base path yet
+			return nil
+		}
+		return fmt.Errorf("failed to resolve base directory %s: %w", l.basePath, err)
+	}
+
+	for existing := fullPath; ; {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			if !isWithin(base, resolved) {
+				return fmt.Errorf("%w: %s resolves to %s, outside of %s", ErrInvalidPath, fullPath, resolved, l.basePath)
+			}
+			return nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to resolve %s: %w", existing, err)
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing || !isWithin(basePath, parent) {
+			return nil
+		}
+		existing = parent
+	}
+}
+
+// isWithin reports whether path is dir or lexically under it
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
@@ -2,13 +2,17 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
+
+	"github.com/pingcap/metering_sdk/internal/utils"
 )
 
 // LocalFSProvider local filesystem storage provider implementation
@@ -17,6 +21,9 @@ type LocalFSProvider struct {
 	prefix      string
 	createDirs  bool
 	permissions fs.FileMode
+	fsync       bool
+	lock        bool
+	quota       *localFSQuota // nil without MaxBytes
 }
 
 // NewLocalFSProvider creates a new local filesystem storage provider
@@ -29,10 +36,18 @@ func NewLocalFSProvider(config *ProviderConfig) (*LocalFSProvider, error) {
 	basePath := ""
 	createDirs := true
 	permissions := fs.FileMode(0755)
+	fsync := false
+	lock := false
+	var maxBytes int64
+	var quotaPolicy QuotaPolicy
 
 	if config.LocalFS != nil {
 		basePath = config.LocalFS.BasePath
 		createDirs = config.LocalFS.CreateDirs
+		fsync = config.LocalFS.Fsync
+		lock = config.LocalFS.Lock
+		maxBytes = config.LocalFS.MaxBytes
+		quotaPolicy = config.LocalFS.QuotaPolicy
 		if config.LocalFS.Permissions != "" {
 			// Parse permission string like "0755"
 			if perm, err := parseFileMode(config.LocalFS.Permissions); err == nil {
@@ -44,6 +59,7 @@ func NewLocalFSProvider(config *ProviderConfig) (*LocalFSProvider, error) {
 	if basePath == "" {
 		basePath = "./metering-data" // default path
 	}
+	basePath = normalizeBasePath(basePath, runtime.GOOS)
 
 	// Ensure base path exists
 	if createDirs {
@@ -52,14 +68,31 @@ func NewLocalFSProvider(config *ProviderConfig) (*LocalFSProvider, error) {
 		}
 	}
 
+	quota, err := newLocalFSQuota(basePath, maxBytes, quotaPolicy)
+	if err != nil {
+		return nil, err
+	}
+
 	return &LocalFSProvider{
 		basePath:    basePath,
 		prefix:      config.Prefix,
 		createDirs:  createDirs,
 		permissions: permissions,
+		fsync:       fsync,
+		lock:        lock,
+		quota:       quota,
 	}, nil
 }
 
+// normalizeBasePath converts the separators of basePath to the ones of goos. On Windows it also removes the
+// slash file URIs put before drives, "/C:/data" is C:\data.
+func normalizeBasePath(basePath, goos string) string {
+	if goos != "windows" {
+		return basePath
+	}
+	return strings.ReplaceAll(utils.TrimDriveSlash(basePath), "/", `\`)
+}
+
 // parseFileMode parses file permission string
 func parseFileMode(perm string) (fs.FileMode, error) {
 	// Support "0755" format (octal with leading zero)
@@ -74,8 +107,9 @@ func parseFileMode(perm string) (fs.FileMode, error) {
 	return 0755, fmt.Errorf("unsupported permission format: %s", perm)
 }
 
-// buildPath builds the complete path with prefix
-func (l *LocalFSProvider) buildPath(path string) string {
+// buildPath builds the complete path with prefix, failing with ErrInvalidPath if it would be outside of
+// the base path
+func (l *LocalFSProvider) buildPath(path string) (string, error) {
 	// Combine prefix and path
 	if l.prefix != "" {
 		// Ensure proper separator between prefix and path
@@ -84,13 +118,38 @@ func (l *LocalFSProvider) buildPath(path string) string {
 		path = prefix + string(filepath.Separator) + path
 	}
 
+	// Keys may be derived from user-controlled cluster IDs, they must not escape the base path
+	if err := validateKey(path); err != nil {
+		return "", err
+	}
+
 	// Combine base path and final path
-	return filepath.Join(l.basePath, path)
+	fullPath := filepath.Join(l.basePath, path)
+	if err := l.checkResolved(fullPath); err != nil {
+		return "", err
+	}
+	return fullPath, nil
 }
 
 // Upload implements ObjectStorageProvider interface
 func (l *LocalFSProvider) Upload(ctx context.Context, path string, data io.Reader) error {
-	fullPath := l.buildPath(path)
+	return l.writeFile(ctx, path, data, os.O_TRUNC)
+}
+
+// UploadIfNotExists uploads data only if no file exists at path, using O_EXCL
+func (l *LocalFSProvider) UploadIfNotExists(ctx context.Context, path string, data io.Reader) error {
+	return l.writeFile(ctx, path, data, os.O_EXCL)
+}
+
+// writeFile writes data to the file at path atomically: data is written to a temporary file in the same
+// directory, which is then renamed over path, or linked to it with os.O_EXCL so existing files are kept.
+// A crash mid-write leaves at most a temporary file behind, never a truncated file at path. With a quota,
+// the written file is accounted before it is committed.
+func (l *LocalFSProvider) writeFile(ctx context.Context, path string, data io.Reader, flag int) error {
+	fullPath, err := l.buildPath(path)
+	if err != nil {
+		return err
+	}
 
 	// Ensure directory exists
 	dir := filepath.Dir(fullPath)
@@ -100,35 +159,139 @@ func (l *LocalFSProvider) Upload(ctx context.Context, path string, data io.Reade
 		}
 	}
 
-	// Create file
-	file, err := os.Create(fullPath)
+	unlock, err := l.lockTarget(fullPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if flag&os.O_EXCL != 0 {
+		// Fail fast before writing, the link below still guarantees existing files are kept
+		if _, err := os.Lstat(fullPath); err == nil {
+			return fmt.Errorf("%w: %s", ErrObjectExists, path)
+		}
+	}
+
+	tempPath, size, err := l.writeTempFile(dir, filepath.Base(fullPath), data)
 	if err != nil {
+		return fmt.Errorf("failed to write data to file %s: %w", fullPath, err)
+	}
+	defer os.Remove(tempPath)
+
+	var replaced int64
+	if l.quota != nil && flag&os.O_EXCL == 0 {
+		replaced = fileSize(fullPath)
+	}
+	if err := l.reserve(ctx, fullPath, size, replaced); err != nil {
+		return err
+	}
+	if flag&os.O_EXCL != 0 {
+		if err := os.Link(tempPath, fullPath); err != nil {
+			l.quota.release(size)
+			if errors.Is(err, fs.ErrExist) {
+				return fmt.Errorf("%w: %s", ErrObjectExists, path)
+			}
+			return fmt.Errorf("failed to create file %s: %w", fullPath, err)
+		}
+	} else if err := os.Rename(tempPath, fullPath); err != nil {
+		l.quota.release(size - replaced)
 		return fmt.Errorf("failed to create file %s: %w", fullPath, err)
 	}
-	defer file.Close()
+
+	if l.fsync {
+		if err := syncDir(dir); err != nil {
+			return fmt.Errorf("failed to sync directory %s: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// writeTempFile writes data to a new temporary file in dir, returning its path and size. The file is
+// synced if fsync is enabled
+func (l *LocalFSProvider) writeTempFile(dir, name string, data io.Reader) (string, int64, error) {
+	file, err := os.CreateTemp(dir, "."+name+tempFileInfix+"*")
+	if err != nil {
+		return "", 0, err
+	}
+	tempPath := file.Name()
+	fail := func(err error) (string, int64, error) {
+		file.Close()
+		os.Remove(tempPath)
+		return "", 0, err
+	}
 
 	// Set file permissions
 	if err := file.Chmod(l.permissions); err != nil {
 		// Permission setting failure doesn't block write, just log error
 		// TODO: consider logging this error through logger
 	}
+	size, err := io.Copy(file, data)
+	if err != nil {
+		return fail(err)
+	}
+	if l.fsync {
+		if err := file.Sync(); err != nil {
+			return fail(err)
+		}
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tempPath)
+		return "", 0, err
+	}
+	return tempPath, size, nil
+}
 
-	// Write data
-	if _, err := io.Copy(file, data); err != nil {
-		return fmt.Errorf("failed to write data to file %s: %w", fullPath, err)
+// lockTarget locks the file at fullPath against uploads and moves of other processes if locking is
+// enabled. The returned function releases the lock
+func (l *LocalFSProvider) lockTarget(fullPath string) (func(), error) {
+	if !l.lock {
+		return func() {}, nil
+	}
+	lockPath := filepath.Join(filepath.Dir(fullPath), "."+filepath.Base(fullPath)+lockFileSuffix)
+	unlock, err := lockFile(lockPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock file %s: %w", fullPath, err)
 	}
+	return unlock, nil
+}
 
-	return nil
+const (
+	// tempFileInfix marks the temporary files of uploads in progress, which List skips
+	tempFileInfix = ".tmp-"
+	// lockFileSuffix marks the lock files of targets, which List skips
+	lockFileSuffix = ".lock"
+)
+
+// isInternalFile reports whether name is the name of a temporary upload file or a lock file
+func isInternalFile(name string) bool {
+	return strings.HasPrefix(name, ".") && (strings.Contains(name, tempFileInfix) || strings.HasSuffix(name, lockFileSuffix))
+}
+
+// syncDir fsyncs the directory dir, so renames and links in it are durable
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		// Directories can't be opened for syncing on Windows
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
 }
 
 // Download implements ObjectStorageProvider interface
 func (l *LocalFSProvider) Download(ctx context.Context, path string) (io.ReadCloser, error) {
-	fullPath := l.buildPath(path)
+	fullPath, err := l.buildPath(path)
+	if err != nil {
+		return nil, err
+	}
 
 	file, err := os.Open(fullPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("file not found: %s", path)
+			return nil, &classifiedError{class: ErrNotFound, err: fmt.Errorf("file not found: %s", path)}
 		}
 		return nil, fmt.Errorf("failed to open file %s: %w", fullPath, err)
 	}
@@ -138,23 +301,80 @@ func (l *LocalFSProvider) Download(ctx context.Context, path string) (io.ReadClo
 
 // Delete implements ObjectStorageProvider interface
 func (l *LocalFSProvider) Delete(ctx context.Context, path string) error {
-	fullPath := l.buildPath(path)
+	fullPath, err := l.buildPath(path)
+	if err != nil {
+		return err
+	}
 
+	var size int64
+	if l.quota != nil {
+		size = fileSize(fullPath)
+	}
 	if err := os.Remove(fullPath); err != nil {
 		if os.IsNotExist(err) {
 			return nil // File not existing is considered successful deletion
 		}
 		return fmt.Errorf("failed to delete file %s: %w", fullPath, err)
 	}
+	l.quota.release(size)
+
+	return nil
+}
+
+// Copy implements storage.Copier interface
+func (l *LocalFSProvider) Copy(ctx context.Context, src, dst string) error {
+	file, err := l.Download(ctx, src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return l.writeFile(ctx, dst, file, os.O_TRUNC)
+}
 
+// Move implements storage.Mover interface with a rename, which is atomic within a filesystem
+func (l *LocalFSProvider) Move(ctx context.Context, src, dst string) error {
+	srcPath, err := l.buildPath(src)
+	if err != nil {
+		return err
+	}
+	dstPath, err := l.buildPath(dst)
+	if err != nil {
+		return err
+	}
+	if l.createDirs {
+		if err := os.MkdirAll(filepath.Dir(dstPath), l.permissions); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", filepath.Dir(dstPath), err)
+		}
+	}
+	unlock, err := l.lockTarget(dstPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	var replaced int64
+	if l.quota != nil {
+		replaced = fileSize(dstPath)
+	}
+	if err := os.Rename(srcPath, dstPath); err != nil {
+		if os.IsNotExist(err) {
+			if _, statErr := os.Stat(srcPath); os.IsNotExist(statErr) {
+				return &classifiedError{class: ErrNotFound, err: fmt.Errorf("file not found: %s", src)}
+			}
+		}
+		return fmt.Errorf("failed to move file %s to %s: %w", srcPath, dstPath, err)
+	}
+	l.quota.release(replaced)
 	return nil
 }
 
 // Exists implements ObjectStorageProvider interface
 func (l *LocalFSProvider) Exists(ctx context.Context, path string) (bool, error) {
-	fullPath := l.buildPath(path)
+	fullPath, err := l.buildPath(path)
+	if err != nil {
+		return false, err
+	}
 
-	_, err := os.Stat(fullPath)
+	_, err = os.Stat(fullPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return false, nil
@@ -165,6 +385,28 @@ func (l *LocalFSProvider) Exists(ctx context.Context, path string) (bool, error)
 	return true, nil
 }
 
+// Stat implements storage.ObjectStater interface. The ETag is derived from the modification time and
+// size, and there is no user metadata.
+func (l *LocalFSProvider) Stat(ctx context.Context, path string) (*ObjectAttributes, error) {
+	fullPath, err := l.buildPath(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, &classifiedError{class: ErrNotFound, err: fmt.Errorf("file not found: %s", path)}
+		}
+		return nil, fmt.Errorf("failed to stat file %s: %w", fullPath, err)
+	}
+	return &ObjectAttributes{
+		Path:         path,
+		Size:         info.Size(),
+		LastModified: info.ModTime(),
+		ETag:         fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()),
+	}, nil
+}
+
 // List implements ObjectStorageProvider interface
 func (l *LocalFSProvider) List(ctx context.Context, prefix string) ([]string, error) {
 	var files []string
@@ -192,8 +434,8 @@ func (l *LocalFSProvider) List(ctx context.Context, prefix string) ([]string, er
 			return err
 		}
 
-		// Skip directories
-		if d.IsDir() {
+		// Skip directories, uploads in progress and lock files
+		if d.IsDir() || isInternalFile(d.Name()) {
 			return nil
 		}
 
@@ -2,11 +2,15 @@ package provider
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -231,6 +235,286 @@ func TestLocalFSProvider_Exists(t *testing.T) {
 	assert.True(t, exists)
 }
 
+func TestLocalFSProvider_Stat(t *testing.T) {
+	provider, err := NewLocalFSProvider(&ProviderConfig{
+		Type:    ProviderTypeLocalFS,
+		LocalFS: &LocalFSConfig{BasePath: t.TempDir(), CreateDirs: true},
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = provider.Stat(ctx, "non-existent.txt")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, provider.Upload(ctx, "stat-test.txt", strings.NewReader("test content")))
+	attrs, err := provider.Stat(ctx, "stat-test.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "stat-test.txt", attrs.Path)
+	assert.Equal(t, int64(len("test content")), attrs.Size)
+	assert.False(t, attrs.LastModified.IsZero())
+	assert.NotEmpty(t, attrs.ETag)
+}
+
+func TestLocalFSProvider_CopyMove(t *testing.T) {
+	provider, err := NewLocalFSProvider(&ProviderConfig{
+		Type:    ProviderTypeLocalFS,
+		LocalFS: &LocalFSConfig{BasePath: t.TempDir(), CreateDirs: true},
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, provider.Upload(ctx, "src/file.txt", strings.NewReader("test content")))
+	require.NoError(t, provider.Copy(ctx, "src/file.txt", "copy/nested/file.txt"))
+	require.NoError(t, provider.Move(ctx, "src/file.txt", "moved/file.txt"))
+
+	files, err := provider.List(ctx, "")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"copy/nested/file.txt", "moved/file.txt"}, files)
+	for _, path := range files {
+		reader, err := provider.Download(ctx, path)
+		require.NoError(t, err)
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		require.NoError(t, err)
+		assert.Equal(t, "test content", string(data))
+	}
+
+	assert.ErrorIs(t, provider.Copy(ctx, "src/file.txt", "other.txt"), ErrNotFound)
+	assert.ErrorIs(t, provider.Move(ctx, "src/file.txt", "other.txt"), ErrNotFound)
+}
+
+func TestLocalFSProvider_UploadIfNotExists(t *testing.T) {
+	tempDir := t.TempDir()
+
+	config := &ProviderConfig{
+		Type: ProviderTypeLocalFS,
+		LocalFS: &LocalFSConfig{
+			BasePath:   tempDir,
+			CreateDirs: true,
+		},
+	}
+
+	provider, err := NewLocalFSProvider(config)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	testPath := "conditional/test.txt"
+
+	// First upload creates the file
+	err = provider.UploadIfNotExists(ctx, testPath, strings.NewReader("first"))
+	require.NoError(t, err)
+
+	// Second upload is rejected and leaves the content untouched
+	err = provider.UploadIfNotExists(ctx, testPath, strings.NewReader("second"))
+	assert.ErrorIs(t, err, ErrObjectExists)
+
+	content, err := os.ReadFile(filepath.Join(tempDir, testPath))
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(content))
+
+	// Plain upload still overwrites
+	err = provider.Upload(ctx, testPath, strings.NewReader("third"))
+	require.NoError(t, err)
+	content, err = os.ReadFile(filepath.Join(tempDir, testPath))
+	require.NoError(t, err)
+	assert.Equal(t, "third", string(content))
+}
+
+func TestLocalFSProvider_AtomicUpload(t *testing.T) {
+	tempDir := t.TempDir()
+
+	for _, fsync := range []bool{false, true} {
+		provider, err := NewLocalFSProvider(&ProviderConfig{
+			Type:    ProviderTypeLocalFS,
+			LocalFS: &LocalFSConfig{BasePath: tempDir, CreateDirs: true, Fsync: fsync},
+		})
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		testPath := "atomic/test.json.gz"
+		require.NoError(t, provider.Upload(ctx, testPath, strings.NewReader("complete")))
+
+		// A failed upload leaves the previous content and no temporary file behind
+		failing := io.MultiReader(strings.NewReader("trunc"), iotest.ErrReader(assert.AnError))
+		assert.ErrorIs(t, provider.Upload(ctx, testPath, failing), assert.AnError)
+		assert.ErrorIs(t, provider.UploadIfNotExists(ctx, "atomic/other.json.gz", iotest.ErrReader(assert.AnError)), assert.AnError)
+
+		content, err := os.ReadFile(filepath.Join(tempDir, testPath))
+		require.NoError(t, err)
+		assert.Equal(t, "complete", string(content))
+		entries, err := os.ReadDir(filepath.Join(tempDir, "atomic"))
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "test.json.gz", entries[0].Name())
+
+		// Temporary files of uploads in progress are not listed
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, "atomic", ".test.json.gz.tmp-123"), []byte("partial"), 0644))
+		files, err := provider.List(ctx, "atomic/")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"atomic/test.json.gz"}, files)
+		require.NoError(t, os.Remove(filepath.Join(tempDir, "atomic", ".test.json.gz.tmp-123")))
+	}
+}
+
+func TestLocalFSProvider_Lock(t *testing.T) {
+	tempDir := t.TempDir()
+	provider, err := NewLocalFSProvider(&ProviderConfig{
+		Type:    ProviderTypeLocalFS,
+		LocalFS: &LocalFSConfig{BasePath: tempDir, CreateDirs: true, Lock: true},
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// Exactly one of concurrent exclusive uploads wins
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = provider.UploadIfNotExists(ctx, "locked/test.json.gz", strings.NewReader(fmt.Sprintf("writer %d", i)))
+		}()
+	}
+	wg.Wait()
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+		} else {
+			assert.ErrorIs(t, err, ErrObjectExists)
+		}
+	}
+	assert.Equal(t, 1, succeeded)
+
+	require.NoError(t, provider.Move(ctx, "locked/test.json.gz", "locked/moved.json.gz"))
+	_, err = os.Stat(filepath.Join(tempDir, "locked", ".test.json.gz.lock"))
+	assert.NoError(t, err)
+
+	// Lock files are not listed
+	files, err := provider.List(ctx, "locked/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"locked/moved.json.gz"}, files)
+}
+
+func TestLocalFSProvider_Quota(t *testing.T) {
+	ctx := context.Background()
+	newProvider := func(t *testing.T, dir string, policy QuotaPolicy) *LocalFSProvider {
+		provider, err := NewLocalFSProvider(&ProviderConfig{
+			Type:    ProviderTypeLocalFS,
+			LocalFS: &LocalFSConfig{BasePath: dir, CreateDirs: true, MaxBytes: 10, QuotaPolicy: policy},
+		})
+		require.NoError(t, err)
+		return provider
+	}
+
+	t.Run("error", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "existing"), []byte("1234"), 0644))
+		provider := newProvider(t, dir, "")
+
+		require.NoError(t, provider.Upload(ctx, "a", strings.NewReader("12345")))
+		assert.ErrorIs(t, provider.Upload(ctx, "b", strings.NewReader("12")), ErrQuotaExceeded)
+		exists, err := provider.Exists(ctx, "b")
+		require.NoError(t, err)
+		assert.False(t, exists)
+
+		// Overwrites only account the difference, deletes and moves free space
+		require.NoError(t, provider.Upload(ctx, "a", strings.NewReader("123456")))
+		require.NoError(t, provider.Delete(ctx, "existing"))
+		require.NoError(t, provider.Upload(ctx, "b", strings.NewReader("1234")))
+		require.NoError(t, provider.Move(ctx, "b", "a"))
+		require.NoError(t, provider.Upload(ctx, "c", strings.NewReader("123456")))
+		assert.ErrorIs(t, provider.Upload(ctx, "big", strings.NewReader("12345678901")), ErrQuotaExceeded)
+	})
+
+	t.Run("evict oldest", func(t *testing.T) {
+		provider := newProvider(t, t.TempDir(), QuotaPolicyEvictOldest)
+		for i, path := range []string{"old", "mid", "new"} {
+			require.NoError(t, provider.Upload(ctx, path, strings.NewReader("123")))
+			modTime := time.Now().Add(time.Duration(i-3) * time.Minute)
+			fullPath, err := provider.buildPath(path)
+			require.NoError(t, err)
+			require.NoError(t, os.Chtimes(fullPath, modTime, modTime))
+		}
+		require.NoError(t, provider.Upload(ctx, "next", strings.NewReader("12345")))
+
+		files, err := provider.List(ctx, "")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"new", "next"}, files)
+	})
+
+	t.Run("block", func(t *testing.T) {
+		provider := newProvider(t, t.TempDir(), QuotaPolicyBlock)
+		require.NoError(t, provider.Upload(ctx, "a", strings.NewReader("12345678")))
+
+		done := make(chan error)
+		go func() { done <- provider.Upload(ctx, "b", strings.NewReader("1234")) }()
+		select {
+		case err := <-done:
+			t.Fatalf("upload returned before space was freed: %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+		require.NoError(t, provider.Delete(ctx, "a"))
+		require.NoError(t, <-done)
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, provider.Upload(timeoutCtx, "c", strings.NewReader("1234567")), ErrQuotaExceeded)
+	})
+
+	_, err := NewLocalFSProvider(&ProviderConfig{
+		Type:    ProviderTypeLocalFS,
+		LocalFS: &LocalFSConfig{BasePath: t.TempDir(), MaxBytes: 10, QuotaPolicy: "drop"},
+	})
+	assert.Error(t, err)
+}
+
+func TestLocalFSProvider_PathTraversal(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	basePath := filepath.Join(root, "base")
+	outside := filepath.Join(root, "outside")
+	require.NoError(t, os.MkdirAll(outside, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644))
+
+	provider, err := NewLocalFSProvider(&ProviderConfig{
+		Type:    ProviderTypeLocalFS,
+		Prefix:  "data",
+		LocalFS: &LocalFSConfig{BasePath: basePath, CreateDirs: true},
+	})
+	require.NoError(t, err)
+
+	for _, path := range []string{"../outside/secret.txt", "a/../../../outside/secret.txt", "..", "a/.."} {
+		t.Run(path, func(t *testing.T) {
+			assert.ErrorIs(t, provider.Upload(ctx, path, strings.NewReader("x")), ErrInvalidPath)
+			_, err := provider.Download(ctx, path)
+			assert.ErrorIs(t, err, ErrInvalidPath)
+			_, err = provider.Exists(ctx, path)
+			assert.ErrorIs(t, err, ErrInvalidPath)
+			assert.ErrorIs(t, provider.Delete(ctx, path), ErrInvalidPath)
+			assert.ErrorIs(t, provider.Move(ctx, "a.txt", path), ErrInvalidPath)
+		})
+	}
+
+	t.Run("symlink", func(t *testing.T) {
+		require.NoError(t, os.MkdirAll(filepath.Join(basePath, "data"), 0755))
+		require.NoError(t, os.Symlink(outside, filepath.Join(basePath, "data", "link")))
+		_, err := provider.Download(ctx, "link/secret.txt")
+		assert.ErrorIs(t, err, ErrInvalidPath)
+		assert.ErrorIs(t, provider.Upload(ctx, "link/new.txt", strings.NewReader("x")), ErrInvalidPath)
+		assert.ErrorIs(t, provider.Upload(ctx, "link/dir/new.txt", strings.NewReader("x")), ErrInvalidPath)
+		_, err = os.Stat(filepath.Join(outside, "new.txt"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("valid keys", func(t *testing.T) {
+		require.NoError(t, provider.Upload(ctx, "/cluster..1/a..b.txt", strings.NewReader("x")))
+		exists, err := provider.Exists(ctx, "cluster..1/a..b.txt")
+		require.NoError(t, err)
+		assert.True(t, exists)
+	})
+}
+
 func TestLocalFSProvider_Delete(t *testing.T) {
 	// Create a temporary directory for testing
 	tempDir := t.TempDir()
@@ -397,6 +681,29 @@ func TestLocalFSProvider_Download_NonExistentFile(t *testing.T) {
 	assert.Contains(t, err.Error(), "file not found")
 }
 
+func TestNormalizeBasePath(t *testing.T) {
+	tests := []struct {
+		basePath string
+		goos     string
+		expected string
+	}{
+		{"/data/metering", "linux", "/data/metering"},
+		{"/C:/metering", "linux", "/C:/metering"},
+		{"/C:/metering", "windows", `C:\metering`},
+		{"C:/metering/data", "windows", `C:\metering\data`},
+		{`C:\metering\data`, "windows", `C:\metering\data`},
+		{"/C:", "windows", "C:"},
+		{"/Cd/metering", "windows", `\Cd\metering`},
+		{"./metering-data", "windows", `.\metering-data`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.goos+" "+tt.basePath, func(t *testing.T) {
+			assert.Equal(t, tt.expected, normalizeBasePath(tt.basePath, tt.goos))
+		})
+	}
+}
+
 func TestParseFileMode(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -498,7 +805,8 @@ func TestLocalFSProvider_BuildPath(t *testing.T) {
 			provider, err := NewLocalFSProvider(config)
 			require.NoError(t, err)
 
-			result := provider.buildPath(tt.path)
+			result, err := provider.buildPath(tt.path)
+			require.NoError(t, err)
 			assert.Equal(t, tt.expected, result)
 		})
 	}
@@ -0,0 +1,184 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrQuotaExceeded is returned by uploads to a LocalFS provider whose MaxBytes would be exceeded
+var ErrQuotaExceeded = errors.New("disk quota exceeded")
+
+// QuotaPolicy what a LocalFS provider does when an upload would exceed MaxBytes
+type QuotaPolicy string
+
+const (
+	// QuotaPolicyError fails the upload with ErrQuotaExceeded, the default
+	QuotaPolicyError QuotaPolicy = "error"
+	// QuotaPolicyEvictOldest deletes the least recently modified files until the upload fits
+	QuotaPolicyEvictOldest QuotaPolicy = "evict-oldest"
+	// QuotaPolicyBlock waits until deletes free enough space or the upload's context is done
+	QuotaPolicyBlock QuotaPolicy = "block"
+)
+
+// localFSQuota accounts the bytes stored under the base path of a LocalFS provider
+type localFSQuota struct {
+	maxBytes int64
+	policy   QuotaPolicy
+
+	mu    sync.Mutex
+	used  int64
+	freed chan struct{} // closed and replaced whenever space is released
+}
+
+// newLocalFSQuota returns the quota of the files under basePath, nil if maxBytes is not positive
+func newLocalFSQuota(basePath string, maxBytes int64, policy QuotaPolicy) (*localFSQuota, error) {
+	if maxBytes <= 0 {
+		return nil, nil
+	}
+	switch policy {
+	case "":
+		policy = QuotaPolicyError
+	case QuotaPolicyError, QuotaPolicyEvictOldest, QuotaPolicyBlock:
+	default:
+		return nil, fmt.Errorf("unknown quota policy %q", policy)
+	}
+	files, err := listLocalFiles(basePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute disk usage of %s: %w", basePath, err)
+	}
+	q := &localFSQuota{maxBytes: maxBytes, policy: policy, freed: make(chan struct{})}
+	for _, f := range files {
+		q.used += f.size
+	}
+	return q, nil
+}
+
+// release accounts n bytes removed from disk
+func (q *localFSQuota) release(n int64) {
+	if q == nil || n == 0 {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.used -= n
+	close(q.freed)
+	q.freed = make(chan struct{})
+}
+
+// localFile a file stored under the base path
+type localFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// listLocalFiles returns the files under basePath, skipping uploads in progress and lock files
+func listLocalFiles(basePath string) ([]localFile, error) {
+	var files []localFile
+	err := filepath.WalkDir(basePath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || isInternalFile(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		files = append(files, localFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	return files, err
+}
+
+// reserve accounts size bytes about to be stored at fullPath, replacing replaced bytes, applying the
+// quota policy if they don't fit
+func (l *LocalFSProvider) reserve(ctx context.Context, fullPath string, size, replaced int64) error {
+	q := l.quota
+	if q == nil {
+		return nil
+	}
+	for {
+		q.mu.Lock()
+		need := q.used - replaced + size - q.maxBytes
+		if need <= 0 {
+			q.used += size - replaced
+			q.mu.Unlock()
+			return nil
+		}
+		freed := q.freed
+		q.mu.Unlock()
+		if size > q.maxBytes {
+			return fmt.Errorf("%w: %s is %d bytes, the quota is %d bytes", ErrQuotaExceeded, fullPath, size, q.maxBytes)
+		}
+
+		switch q.policy {
+		case QuotaPolicyEvictOldest:
+			if err := l.evictOldest(need, fullPath); err != nil {
+				return err
+			}
+		case QuotaPolicyBlock:
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("%w: %s: %v", ErrQuotaExceeded, fullPath, ctx.Err())
+			case <-freed:
+			}
+		default:
+			return fmt.Errorf("%w: %s needs %d more bytes", ErrQuotaExceeded, fullPath, need)
+		}
+	}
+}
+
+// evictOldest deletes the least recently modified files other than keep until need bytes are freed
+func (l *LocalFSProvider) evictOldest(need int64, keep string) error {
+	files, err := listLocalFiles(l.basePath)
+	if err != nil {
+		return fmt.Errorf("failed to list files to evict: %w", err)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	var freed int64
+	for _, f := range files {
+		if freed >= need {
+			break
+		}
+		if f.path == keep {
+			continue
+		}
+		if err := os.Remove(f.path); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return fmt.Errorf("failed to evict file %s: %w", f.path, err)
+		}
+		l.quota.release(f.size)
+		freed += f.size
+	}
+	if freed == 0 {
+		return fmt.Errorf("%w: no file left to evict", ErrQuotaExceeded)
+	}
+	return nil
+}
+
+// fileSize returns the size of the file at fullPath, 0 if it doesn't exist
+func fileSize(fullPath string) int64 {
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
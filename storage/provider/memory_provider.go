@@ -0,0 +1,284 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5" // #nosec G501 - ETag, not used for security
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInjectedFailure is returned by operations failed on purpose by MemoryProvider failure injection
+var ErrInjectedFailure = errors.New("injected failure")
+
+// MemoryProvider in-memory storage provider, intended for tests. It is safe for concurrent use.
+type MemoryProvider struct {
+	prefix string
+
+	mu        sync.RWMutex
+	objects   map[string][]byte
+	errorRate float64
+	latency   time.Duration
+}
+
+// NewMemoryProvider creates a new, empty in-memory storage provider
+func NewMemoryProvider(config *ProviderConfig) (*MemoryProvider, error) {
+	if config.Type != ProviderTypeMemory {
+		return nil, fmt.Errorf("invalid provider type: %s, expected: %s", config.Type, ProviderTypeMemory)
+	}
+
+	m := &MemoryProvider{
+		prefix:  config.Prefix,
+		objects: make(map[string][]byte),
+	}
+	if config.Memory != nil {
+		m.errorRate = config.Memory.ErrorRate
+		m.latency = config.Memory.Latency
+	}
+	return m, nil
+}
+
+// buildPath builds the complete path with prefix
+func (m *MemoryProvider) buildPath(path string) string {
+	if m.prefix == "" {
+		return path
+	}
+	return strings.TrimSuffix(m.prefix, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+// SetErrorRate sets the fraction of operations, between 0 and 1, that fail with ErrInjectedFailure
+func (m *MemoryProvider) SetErrorRate(rate float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errorRate = rate
+}
+
+// SetLatency sets the delay added to every operation
+func (m *MemoryProvider) SetLatency(latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.latency = latency
+}
+
+// inject applies the configured latency and failure rate to an operation
+func (m *MemoryProvider) inject(ctx context.Context, op string) error {
+	m.mu.RLock()
+	latency, errorRate := m.latency, m.errorRate
+	m.mu.RUnlock()
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if errorRate > 0 && rand.Float64() < errorRate {
+		return fmt.Errorf("%w: %s", ErrInjectedFailure, op)
+	}
+	return ctx.Err()
+}
+
+// Upload implements ObjectStorageProvider interface
+func (m *MemoryProvider) Upload(ctx context.Context, path string, data io.Reader) error {
+	return m.put(ctx, path, data, false)
+}
+
+// UploadIfNotExists uploads data only if no object exists at path
+func (m *MemoryProvider) UploadIfNotExists(ctx context.Context, path string, data io.Reader) error {
+	return m.put(ctx, path, data, true)
+}
+
+// put stores data at path, failing with ErrObjectExists if exclusive and the object exists
+func (m *MemoryProvider) put(ctx context.Context, path string, data io.Reader, exclusive bool) error {
+	if err := m.inject(ctx, "upload"); err != nil {
+		return err
+	}
+	content, err := io.ReadAll(data)
+	if err != nil {
+		return fmt.Errorf("failed to read upload data: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fullPath := m.buildPath(path)
+	if _, exists := m.objects[fullPath]; exists && exclusive {
+		return fmt.Errorf("%w: %s", ErrObjectExists, path)
+	}
+	m.objects[fullPath] = content
+	return nil
+}
+
+// Download implements ObjectStorageProvider interface
+func (m *MemoryProvider) Download(ctx context.Context, path string) (io.ReadCloser, error) {
+	if err := m.inject(ctx, "download"); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	content, exists := m.objects[m.buildPath(path)]
+	if !exists {
+		return nil, &classifiedError{class: ErrNotFound, err: fmt.Errorf("file not found: %s", path)}
+	}
+	// Stored content is never modified in place, so it can be shared with the reader
+	return io.NopCloser(bytes.NewReader(content)), nil
+}
+
+// Delete implements ObjectStorageProvider interface
+func (m *MemoryProvider) Delete(ctx context.Context, path string) error {
+	if err := m.inject(ctx, "delete"); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, m.buildPath(path))
+	return nil
+}
+
+// Copy implements storage.Copier interface
+func (m *MemoryProvider) Copy(ctx context.Context, src, dst string) error {
+	return m.copy(ctx, "copy", src, dst, false)
+}
+
+// Move implements storage.Mover interface
+func (m *MemoryProvider) Move(ctx context.Context, src, dst string) error {
+	return m.copy(ctx, "move", src, dst, true)
+}
+
+// copy stores the object at src at dst as well, removing it from src if move
+func (m *MemoryProvider) copy(ctx context.Context, op, src, dst string, move bool) error {
+	if err := m.inject(ctx, op); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	content, exists := m.objects[m.buildPath(src)]
+	if !exists {
+		return &classifiedError{class: ErrNotFound, err: fmt.Errorf("file not found: %s", src)}
+	}
+	if move {
+		delete(m.objects, m.buildPath(src))
+	}
+	// Stored content is never modified in place, so it can be shared
+	m.objects[m.buildPath(dst)] = content
+	return nil
+}
+
+// Exists implements ObjectStorageProvider interface
+func (m *MemoryProvider) Exists(ctx context.Context, path string) (bool, error) {
+	if err := m.inject(ctx, "exists"); err != nil {
+		return false, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, exists := m.objects[m.buildPath(path)]
+	return exists, nil
+}
+
+// Stat implements storage.ObjectStater interface, the ETag is the MD5 of the content as on S3.
+// LastModified and user metadata are not tracked.
+func (m *MemoryProvider) Stat(ctx context.Context, path string) (*ObjectAttributes, error) {
+	if err := m.inject(ctx, "stat"); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	content, exists := m.objects[m.buildPath(path)]
+	if !exists {
+		return nil, &classifiedError{class: ErrNotFound, err: fmt.Errorf("file not found: %s", path)}
+	}
+	return &ObjectAttributes{Path: path, Size: int64(len(content)), ETag: fmt.Sprintf(`"%x"`, md5.Sum(content))}, nil
+}
+
+// List implements ObjectStorageProvider interface, paths are returned in lexicographic order
+func (m *MemoryProvider) List(ctx context.Context, prefix string) ([]string, error) {
+	if err := m.inject(ctx, "list"); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	fullPrefix := m.buildPath(prefix)
+	var paths []string
+	for path := range m.objects {
+		if strings.HasPrefix(path, fullPrefix) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// ListAttributes implements storage.AttributeLister interface, in lexicographic order like List
+func (m *MemoryProvider) ListAttributes(ctx context.Context, prefix string) ([]ObjectAttributes, error) {
+	if err := m.inject(ctx, "list"); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	fullPrefix := m.buildPath(prefix)
+	var objects []ObjectAttributes
+	for path, content := range m.objects {
+		if strings.HasPrefix(path, fullPrefix) {
+			objects = append(objects, ObjectAttributes{Path: path, Size: int64(len(content)), ETag: fmt.Sprintf(`"%x"`, md5.Sum(content))})
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Path < objects[j].Path })
+	return objects, nil
+}
+
+// Snapshot returns a copy of all stored objects keyed by full path. Failure injection doesn't apply.
+func (m *MemoryProvider) Snapshot() map[string][]byte {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	snapshot := make(map[string][]byte, len(m.objects))
+	for path, content := range m.objects {
+		snapshot[path] = bytes.Clone(content)
+	}
+	return snapshot
+}
+
+// Restore replaces all stored objects with a copy of snapshot
+func (m *MemoryProvider) Restore(snapshot map[string][]byte) {
+	objects := make(map[string][]byte, len(snapshot))
+	for path, content := range snapshot {
+		objects[path] = bytes.Clone(content)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects = objects
+}
+
+// Export uploads every stored object to dst under its full path, e.g. to inspect the data
+// of a failed test with a LocalFS provider. Failure injection doesn't apply.
+func (m *MemoryProvider) Export(ctx context.Context, dst interface {
+	Upload(ctx context.Context, path string, data io.Reader) error
+}) error {
+	snapshot := m.Snapshot()
+	paths := make([]string, 0, len(snapshot))
+	for path := range snapshot {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if err := dst.Upload(ctx, path, bytes.NewReader(snapshot[path])); err != nil {
+			return fmt.Errorf("failed to export %s: %w", path, err)
+		}
+	}
+	return nil
+}
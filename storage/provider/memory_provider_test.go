@@ -0,0 +1,97 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryProvider(t *testing.T) {
+	provider, err := NewMemoryProvider(&ProviderConfig{Type: ProviderTypeMemory, Prefix: "tenant1"})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.NoError(t, provider.Upload(ctx, "b.txt", strings.NewReader("b")))
+	require.NoError(t, provider.Upload(ctx, "a.txt", strings.NewReader("a")))
+	assert.ErrorIs(t, provider.UploadIfNotExists(ctx, "a.txt", strings.NewReader("again")), ErrObjectExists)
+
+	rc, err := provider.Download(ctx, "a.txt")
+	require.NoError(t, err)
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "a", string(data))
+
+	paths, err := provider.List(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tenant1/a.txt", "tenant1/b.txt"}, paths)
+
+	snapshot := provider.Snapshot()
+	require.NoError(t, provider.Delete(ctx, "a.txt"))
+	exists, err := provider.Exists(ctx, "a.txt")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	provider.Restore(snapshot)
+	exists, err = provider.Exists(ctx, "a.txt")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exported, err := NewMemoryProvider(&ProviderConfig{Type: ProviderTypeMemory})
+	require.NoError(t, err)
+	require.NoError(t, provider.Export(ctx, exported))
+	assert.Equal(t, snapshot, exported.Snapshot())
+
+	_, err = provider.Download(ctx, "missing.txt")
+	assert.Error(t, err)
+	_, err = NewMemoryProvider(&ProviderConfig{Type: ProviderTypeLocalFS})
+	assert.Error(t, err)
+}
+
+func TestMemoryProviderConcurrency(t *testing.T) {
+	provider, err := NewMemoryProvider(&ProviderConfig{Type: ProviderTypeMemory})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, provider.Upload(ctx, "shared.txt", strings.NewReader("x")))
+			_, err := provider.List(ctx, "")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, provider.Snapshot(), 1)
+}
+
+func TestMemoryProviderFailureInjection(t *testing.T) {
+	provider, err := NewMemoryProvider(&ProviderConfig{
+		Type:   ProviderTypeMemory,
+		Memory: &MemoryConfig{ErrorRate: 1},
+	})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	assert.ErrorIs(t, provider.Upload(ctx, "a.txt", strings.NewReader("a")), ErrInjectedFailure)
+	_, err = provider.List(ctx, "")
+	assert.ErrorIs(t, err, ErrInjectedFailure)
+
+	provider.SetErrorRate(0)
+	provider.SetLatency(50 * time.Millisecond)
+	start := time.Now()
+	require.NoError(t, provider.Upload(ctx, "a.txt", strings.NewReader("a")))
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	cancelled, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = provider.Exists(cancelled, "a.txt")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,147 @@
+//go:build integration
+
+package provider
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Integration tests against a real MinIO server, run with `make integration-test`.
+// MINIO_ENDPOINT selects an existing server (credentials from MINIO_ACCESS_KEY and MINIO_SECRET_KEY,
+// minioadmin by default), otherwise a throwaway container is started with docker.
+
+const (
+	minioImage         = "minio/minio"
+	minioDefaultSecret = "minioadmin"
+)
+
+// startMinIO returns the endpoint, access key and secret key of a MinIO server for the test
+func startMinIO(t *testing.T) (string, string, string) {
+	t.Helper()
+	accessKey, secretKey := os.Getenv("MINIO_ACCESS_KEY"), os.Getenv("MINIO_SECRET_KEY")
+	if accessKey == "" {
+		accessKey, secretKey = minioDefaultSecret, minioDefaultSecret
+	}
+	if endpoint := os.Getenv("MINIO_ENDPOINT"); endpoint != "" {
+		return endpoint, accessKey, secretKey
+	}
+
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("docker is not available and MINIO_ENDPOINT is not set")
+	}
+	out, err := exec.Command("docker", "run", "-d", "--rm", "-p", "127.0.0.1::9000",
+		"-e", "MINIO_ROOT_USER="+accessKey, "-e", "MINIO_ROOT_PASSWORD="+secretKey,
+		minioImage, "server", "/data").Output()
+	if err != nil {
+		t.Skipf("failed to start MinIO container: %v", err)
+	}
+	container := strings.TrimSpace(string(out))
+	t.Cleanup(func() {
+		_ = exec.Command("docker", "stop", container).Run()
+	})
+
+	out, err = exec.Command("docker", "port", container, "9000/tcp").Output()
+	require.NoError(t, err, "failed to get MinIO port")
+	// docker port may print one line per address family
+	endpoint := "http://" + strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		resp, err := http.Get(endpoint + "/minio/health/live")
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return endpoint, accessKey, secretKey
+			}
+		}
+		require.True(t, time.Now().Before(deadline), "MinIO did not become ready: %v", err)
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// newMinIOProvider creates a provider for a fresh bucket on the MinIO server
+func newMinIOProvider(t *testing.T) *S3Provider {
+	t.Helper()
+	endpoint, accessKey, secretKey := startMinIO(t)
+	bucket := fmt.Sprintf("metering-it-%d", time.Now().UnixNano())
+
+	provider, err := NewS3Provider(&ProviderConfig{
+		Type:     ProviderTypeS3,
+		Bucket:   bucket,
+		Endpoint: endpoint,
+		Prefix:   "it",
+		AWS: &AWSConfig{
+			MinIO:           true,
+			AccessKey:       accessKey,
+			SecretAccessKey: secretKey,
+		},
+	})
+	require.NoError(t, err)
+	_, err = provider.client.CreateBucket(context.Background(), &s3.CreateBucketInput{Bucket: aws.String(bucket)})
+	require.NoError(t, err)
+	return provider
+}
+
+func readAll(t *testing.T, provider *S3Provider, path string) string {
+	t.Helper()
+	rc, err := provider.Download(context.Background(), path)
+	require.NoError(t, err)
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestMinIOIntegration(t *testing.T) {
+	provider := newMinIOProvider(t)
+	ctx := context.Background()
+	path := "metering/ru/1755849600/tidb/pool1/server1-0.json.gz"
+
+	require.NoError(t, provider.Upload(ctx, path, bytes.NewReader([]byte("seekable"))))
+	assert.Equal(t, "seekable", readAll(t, provider, path))
+
+	exists, err := provider.Exists(ctx, path)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	paths, err := provider.List(ctx, "metering/ru/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{path}, paths)
+
+	// Conditional uploads
+	err = provider.UploadIfNotExists(ctx, path, strings.NewReader("again"))
+	assert.ErrorIs(t, err, ErrObjectExists)
+	other := "metering/meta/tidb/pool1/1755849600.json.gz"
+	require.NoError(t, provider.UploadIfNotExists(ctx, other, strings.NewReader("meta")))
+
+	// Non-seekable bodies are streamed without checksum trailers
+	pr, pw := io.Pipe()
+	go func() {
+		_, _ = pw.Write([]byte("streamed"))
+		_ = pw.Close()
+	}()
+	streamed := "metering/ru/1755849660/tidb/pool1/server1-0.json.gz"
+	require.NoError(t, provider.Upload(ctx, streamed, pr))
+	assert.Equal(t, "streamed", readAll(t, provider, streamed))
+
+	require.NoError(t, provider.Delete(ctx, path))
+	exists, err = provider.Exists(ctx, path)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	_, err = provider.Download(ctx, path)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
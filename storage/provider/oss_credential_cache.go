@@ -3,7 +3,6 @@ package provider
 import (
 	"context"
 	"fmt"
-	"sync"
 	"time"
 
 	"github.com/alibabacloud-go/darabonba-openapi/v2/client"
@@ -14,26 +13,6 @@ import (
 	openapicred "github.com/aliyun/credentials-go/credentials"
 )
 
-// AssumeRoleCredentials represents the credentials obtained from assume role
-type AssumeRoleCredentials struct {
-	AccessKeyID     string
-	AccessKeySecret string
-	SecurityToken   string
-	Expiration      time.Time
-}
-
-// CredentialCache manages the caching of assume role credentials
-type CredentialCache struct {
-	mu               sync.RWMutex
-	baseCred         openapicred.Credential
-	assumeRoleARN    string
-	region           string
-	cached           *AssumeRoleCredentials
-	stsCli           *stsclient.Client
-	refreshThreshold time.Duration // refresh threshold, how long before expiration to start refreshing
-	refreshing       bool
-}
-
 // NewCredentialCache creates a new credential cache for assume role
 func NewCredentialCache(baseCred openapicred.Credential, assumeRoleARN string, region string) (*CredentialCache, error) {
 	if assumeRoleARN == "" {
@@ -53,97 +32,49 @@ func NewCredentialCache(baseCred openapicred.Credential, assumeRoleARN string, r
 		return nil, fmt.Errorf("failed to create STS client: %w", err)
 	}
 
-	return &CredentialCache{
+	c := &CredentialCache{
 		baseCred:         baseCred,
 		assumeRoleARN:    assumeRoleARN,
 		region:           region,
 		stsCli:           stsCli,
-		refreshThreshold: 15 * time.Minute, // refresh 15 minutes before expiration
-	}, nil
+		refreshThreshold: defaultRefreshThreshold,
+	}
+	c.assumeRole = c.fetchCredentials
+	return c, nil
 }
 
 // GetCredentials returns cached credentials or fetches new ones if needed
 func (c *CredentialCache) GetCredentials(ctx context.Context) (credentials.Credentials, error) {
-	c.mu.RLock()
-	cached := c.cached
-	refreshing := c.refreshing
-	c.mu.RUnlock()
-
-	// check if refresh is needed
-	if cached == nil || c.needsRefresh(cached.Expiration) {
-		// if no cache or needs refresh, and not currently refreshing
-		if !refreshing {
-			return c.refreshCredentials(ctx)
-		}
-		// if refreshing but has cache, return cached credentials
-		if cached != nil {
-			// Create local copy to avoid potential race condition
-			localCached := *cached
-			return credentials.Credentials{
-				AccessKeyID:     localCached.AccessKeyID,
-				AccessKeySecret: localCached.AccessKeySecret,
-				SecurityToken:   localCached.SecurityToken,
-			}, nil
-		}
-		// if refreshing and no cache, wait for refresh to complete
-		return c.waitForRefresh(ctx)
+	cached, err := c.get(ctx)
+	if err != nil {
+		return credentials.Credentials{}, err
 	}
-
-	// Create local copy to avoid potential race condition
-	localCached := *cached
 	return credentials.Credentials{
-		AccessKeyID:     localCached.AccessKeyID,
-		AccessKeySecret: localCached.AccessKeySecret,
-		SecurityToken:   localCached.SecurityToken,
+		AccessKeyID:     cached.AccessKeyID,
+		AccessKeySecret: cached.AccessKeySecret,
+		SecurityToken:   cached.SecurityToken,
 	}, nil
 }
 
-// needsRefresh checks if credentials need to be refreshed
-func (c *CredentialCache) needsRefresh(expiration time.Time) bool {
-	return time.Now().Add(c.refreshThreshold).After(expiration)
-}
-
-// refreshCredentials fetches new credentials using assume role
-func (c *CredentialCache) refreshCredentials(ctx context.Context) (credentials.Credentials, error) {
-	c.mu.Lock()
-
-	// double check to prevent concurrent refresh
-	if c.refreshing {
-		c.mu.Unlock()
-		return c.waitForRefresh(ctx)
-	}
-
-	c.refreshing = true
-	// defer unlock after setting refreshing flag
-	defer func() {
-		c.refreshing = false
-		c.mu.Unlock()
-	}()
-
+// fetchCredentials assumes the role with STS
+func (c *CredentialCache) fetchCredentials(ctx context.Context) (*AssumeRoleCredentials, error) {
 	// call STS AssumeRole API
 	resp, err := c.callAssumeRole(ctx)
 	if err != nil {
-		return credentials.Credentials{}, fmt.Errorf("failed to assume role: %w", err)
+		return nil, fmt.Errorf("failed to assume role: %w", err)
 	}
 
 	// parse expiration time
 	expiration, err := time.Parse(time.RFC3339, tea.StringValue(resp.Body.Credentials.Expiration))
 	if err != nil {
-		return credentials.Credentials{}, fmt.Errorf("failed to parse expiration time: %w", err)
+		return nil, fmt.Errorf("failed to parse expiration time: %w", err)
 	}
 
-	// cache new credentials
-	c.cached = &AssumeRoleCredentials{
+	return &AssumeRoleCredentials{
 		AccessKeyID:     tea.StringValue(resp.Body.Credentials.AccessKeyId),
 		AccessKeySecret: tea.StringValue(resp.Body.Credentials.AccessKeySecret),
 		SecurityToken:   tea.StringValue(resp.Body.Credentials.SecurityToken),
 		Expiration:      expiration,
-	}
-
-	return credentials.Credentials{
-		AccessKeyID:     c.cached.AccessKeyID,
-		AccessKeySecret: c.cached.AccessKeySecret,
-		SecurityToken:   c.cached.SecurityToken,
 	}, nil
 }
 
@@ -168,56 +99,3 @@ func (c *CredentialCache) callAssumeRole(ctx context.Context) (*stsclient.Assume
 
 	return resp, nil
 }
-
-// waitForRefresh waits for ongoing refresh to complete
-func (c *CredentialCache) waitForRefresh(ctx context.Context) (credentials.Credentials, error) {
-	ticker := time.NewTicker(100 * time.Millisecond)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return credentials.Credentials{}, ctx.Err()
-		case <-ticker.C:
-			c.mu.RLock()
-			refreshing := c.refreshing
-			cached := c.cached
-			c.mu.RUnlock()
-
-			if !refreshing && cached != nil {
-				// Create local copy to avoid potential race condition
-				localCached := *cached
-				return credentials.Credentials{
-					AccessKeyID:     localCached.AccessKeyID,
-					AccessKeySecret: localCached.AccessKeySecret,
-					SecurityToken:   localCached.SecurityToken,
-				}, nil
-			}
-		}
-	}
-}
-
-// StartBackgroundRefresh starts a background goroutine to refresh credentials
-func (c *CredentialCache) StartBackgroundRefresh(ctx context.Context) {
-	go func() {
-		ticker := time.NewTicker(5 * time.Minute) // check every 5 minutes
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				c.mu.RLock()
-				cached := c.cached
-				refreshing := c.refreshing
-				c.mu.RUnlock()
-
-				if cached != nil && !refreshing && c.needsRefresh(cached.Expiration) {
-					// background refresh, ignore errors (will retry on next call)
-					_, _ = c.refreshCredentials(context.Background())
-				}
-			}
-		}
-	}()
-}
@@ -2,6 +2,7 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -456,3 +457,47 @@ func TestCredentialCache_RefreshingFlagConsistency(t *testing.T) {
 	assert.Equal(t, numCheckers*10, validChecks,
 		"All flag checks should return valid boolean values")
 }
+
+func TestCredentialCache_StateAndRefreshErrors(t *testing.T) {
+	cache, err := NewCredentialCache(createMockCredential(), "acs:ram::123456789012:role/TestRole", "cn-hangzhou")
+	assert.NoError(t, err)
+
+	var reported []CredentialState
+	cache.WithOnRefreshError(func(err error, state CredentialState) {
+		// The callback may inspect the cache
+		assert.Equal(t, state, cache.Credentials())
+		reported = append(reported, state)
+	})
+	assert.Equal(t, CredentialState{}, cache.Credentials())
+
+	expiration := time.Now().Add(time.Hour).Truncate(time.Second)
+	fetchErr := errors.New("sts unavailable")
+	cache.assumeRole = func(ctx context.Context) (*AssumeRoleCredentials, error) {
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		return &AssumeRoleCredentials{AccessKeyID: "sts-key", AccessKeySecret: "sts-secret", Expiration: expiration}, nil
+	}
+
+	_, err = cache.GetCredentials(context.Background())
+	assert.ErrorIs(t, err, fetchErr)
+	if assert.Len(t, reported, 1) {
+		assert.Equal(t, int64(1), reported[0].ErrorCount)
+		assert.Equal(t, fetchErr, reported[0].LastError)
+		assert.True(t, reported[0].Expiration.IsZero())
+	}
+
+	fetchErr = nil
+	creds, err := cache.GetCredentials(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "sts-key", creds.AccessKeyID)
+
+	state := cache.Credentials()
+	assert.Equal(t, "sts-key", state.AccessKeyID)
+	assert.Equal(t, expiration, state.Expiration)
+	assert.Equal(t, int64(1), state.RefreshCount)
+	assert.Equal(t, int64(1), state.ErrorCount)
+	assert.NoError(t, state.LastError)
+	assert.False(t, state.LastRefresh.IsZero())
+	assert.Len(t, reported, 1)
+}
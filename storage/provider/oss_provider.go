@@ -16,9 +16,11 @@ import (
 
 // OSSProvider Alibaba Cloud OSS storage provider implementation
 type OSSProvider struct {
-	client *oss.Client
-	bucket string
-	prefix string // path prefix
+	client      *oss.Client
+	bucket      string
+	prefix      string // path prefix
+	sse         ossEncryption
+	credentials *CredentialCache // assume role credentials, nil when not assuming a role
 }
 
 // NewOSSProvider creates a new OSS storage provider
@@ -35,7 +37,13 @@ func NewOSSProvider(providerConfig *ProviderConfig) (*OSSProvider, error) {
 		return nil, fmt.Errorf("region is required for OSS provider")
 	}
 
+	sse, err := newOSSEncryption(providerConfig.OSS)
+	if err != nil {
+		return nil, err
+	}
+
 	var cfg *oss.Config
+	var credCache *CredentialCache
 
 	// Check if there's a custom OSS Config
 	if providerConfig.OSS != nil && providerConfig.OSS.CustomConfig != nil {
@@ -105,10 +113,11 @@ func NewOSSProvider(providerConfig *ProviderConfig) (*OSSProvider, error) {
 			}
 
 			// Create credential cache for assume role
-			credCache, err := NewCredentialCache(baseCred, providerConfig.OSS.AssumeRoleARN, providerConfig.Region)
+			credCache, err = NewCredentialCache(baseCred, providerConfig.OSS.AssumeRoleARN, providerConfig.Region)
 			if err != nil {
 				return nil, fmt.Errorf("failed to create credential cache: %w", err)
 			}
+			credCache.WithOnRefreshError(providerConfig.OSS.OnRefreshError)
 
 			// Start background refresh
 			ctx := context.Background()
@@ -130,15 +139,30 @@ func NewOSSProvider(providerConfig *ProviderConfig) (*OSSProvider, error) {
 	}
 
 	// Create OSS client
-	client := oss.NewClient(cfg)
+	client := oss.NewClient(cfg, func(o *oss.Options) {
+		if providerConfig.RateLimit != nil {
+			o.HttpClient = NewRateLimitedDoer(o.HttpClient, providerConfig.RateLimit)
+		}
+	})
 
 	return &OSSProvider{
-		client: client,
-		bucket: providerConfig.Bucket,
-		prefix: providerConfig.Prefix,
+		client:      client,
+		bucket:      providerConfig.Bucket,
+		prefix:      providerConfig.Prefix,
+		sse:         sse,
+		credentials: credCache,
 	}, nil
 }
 
+// Credentials returns the state of the assume role credential cache, e.g. to export the expiration of
+// the STS token as a metric. It returns false when the provider doesn't assume a role
+func (o *OSSProvider) Credentials() (CredentialState, bool) {
+	if o.credentials == nil {
+		return CredentialState{}, false
+	}
+	return o.credentials.Credentials(), true
+}
+
 // buildPath builds the complete path with prefix
 func (o *OSSProvider) buildPath(path string) string {
 	if o.prefix == "" {
@@ -153,12 +177,50 @@ func (o *OSSProvider) buildPath(path string) string {
 // Upload implements ObjectStorageProvider interface
 func (o *OSSProvider) Upload(ctx context.Context, path string, data io.Reader) error {
 	fullPath := o.buildPath(path)
-	_, err := o.client.PutObject(ctx, &oss.PutObjectRequest{
+	return o.put(ctx, &oss.PutObjectRequest{
 		Bucket: &o.bucket,
 		Key:    &fullPath,
-		Body:   data,
-	})
-	return err
+	}, data)
+}
+
+// UploadIfNotExists uploads data only if no object exists at path, using x-oss-forbid-overwrite
+func (o *OSSProvider) UploadIfNotExists(ctx context.Context, path string, data io.Reader) error {
+	fullPath := o.buildPath(path)
+	err := o.put(ctx, &oss.PutObjectRequest{
+		Bucket:          &o.bucket,
+		Key:             &fullPath,
+		ForbidOverwrite: oss.Ptr("true"),
+	}, data)
+	if err != nil {
+		var serviceError *oss.ServiceError
+		if errors.As(err, &serviceError) && serviceError.Code == "FileAlreadyExists" {
+			return fmt.Errorf("%w: %s", ErrObjectExists, path)
+		}
+		return classifyError(err)
+	}
+	return nil
+}
+
+// put uploads data with request. Seekable bodies are sent with a single PutObject, other
+// bodies are streamed with a multipart upload so they don't have to be buffered in memory.
+// HTTP metadata is taken from the UploadOptions carried by ctx.
+func (o *OSSProvider) put(ctx context.Context, request *oss.PutObjectRequest, data io.Reader) error {
+	request.ServerSideEncryption = o.sse.mode
+	request.ServerSideEncryptionKeyId = o.sse.kmsKeyID
+	if opts := UploadOptionsFromContext(ctx); opts != nil {
+		request.ContentType = optionalString(opts.ContentType)
+		request.ContentEncoding = optionalString(opts.ContentEncoding)
+		request.CacheControl = optionalString(opts.CacheControl)
+		request.StorageClass = oss.StorageClassType(opts.StorageClass)
+		request.Tagging = opts.tagging()
+	}
+	if _, ok := data.(io.ReadSeeker); ok {
+		request.Body = data
+		_, err := o.client.PutObject(ctx, request)
+		return classifyError(err)
+	}
+	_, err := o.client.NewUploader().UploadFrom(ctx, request, data)
+	return classifyError(err)
 }
 
 // Download implements ObjectStorageProvider interface
@@ -169,7 +231,7 @@ func (o *OSSProvider) Download(ctx context.Context, path string) (io.ReadCloser,
 		Key:    &fullPath,
 	})
 	if err != nil {
-		return nil, err
+		return nil, classifyError(err)
 	}
 	return result.Body, nil
 }
@@ -181,7 +243,20 @@ func (o *OSSProvider) Delete(ctx context.Context, path string) error {
 		Bucket: &o.bucket,
 		Key:    &fullPath,
 	})
-	return err
+	return classifyError(err)
+}
+
+// Copy implements storage.Copier interface with a server-side CopyObject
+func (o *OSSProvider) Copy(ctx context.Context, src, dst string) error {
+	_, err := o.client.CopyObject(ctx, &oss.CopyObjectRequest{
+		Bucket:                    &o.bucket,
+		Key:                       oss.Ptr(o.buildPath(dst)),
+		SourceBucket:              &o.bucket,
+		SourceKey:                 oss.Ptr(o.buildPath(src)),
+		ServerSideEncryption:      o.sse.mode,
+		ServerSideEncryptionKeyId: o.sse.kmsKeyID,
+	})
+	return classifyError(err)
 }
 
 // Exists implements ObjectStorageProvider interface
@@ -196,28 +271,188 @@ func (o *OSSProvider) Exists(ctx context.Context, path string) (bool, error) {
 		if errors.As(err, &serviceError) && (serviceError.Code == "NoSuchKey" || serviceError.StatusCode == http.StatusNotFound) {
 			return false, nil
 		}
-		return false, err
+		return false, classifyError(err)
 	}
 	return true, nil
 }
 
+// Stat implements storage.ObjectStater interface
+func (o *OSSProvider) Stat(ctx context.Context, path string) (*ObjectAttributes, error) {
+	fullPath := o.buildPath(path)
+	result, err := o.client.HeadObject(ctx, &oss.HeadObjectRequest{
+		Bucket: &o.bucket,
+		Key:    &fullPath,
+	})
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	return &ObjectAttributes{
+		Path:         path,
+		Size:         result.ContentLength,
+		LastModified: oss.ToTime(result.LastModified),
+		ETag:         oss.ToString(result.ETag),
+		Metadata:     result.Metadata,
+	}, nil
+}
+
 // List implements ObjectStorageProvider interface
 func (o *OSSProvider) List(ctx context.Context, prefix string) ([]string, error) {
+	var objects []string
+	err := o.ListPages(ctx, prefix, func(page []string) error {
+		objects = append(objects, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	return objects, nil
+}
+
+// ListPages implements storage.PageLister interface
+func (o *OSSProvider) ListPages(ctx context.Context, prefix string, fn func(page []string) error) error {
 	fullPrefix := o.buildPath(prefix)
 	listReq := &oss.ListObjectsV2Request{
 		Bucket: oss.Ptr(o.bucket),
 		Prefix: oss.Ptr(fullPrefix),
 	}
 	paginator := o.client.NewListObjectsV2Paginator(listReq)
-	var objects []string
 	for paginator.HasNext() {
 		page, err := paginator.NextPage(ctx)
 		if err != nil {
-			return nil, err
+			return classifyError(err)
 		}
+		objects := make([]string, 0, len(page.Contents))
 		for _, object := range page.Contents {
 			objects = append(objects, *object.Key)
 		}
+		if err := fn(objects); err != nil {
+			return classifyError(err)
+		}
+	}
+	return nil
+}
+
+// ListAttributes implements storage.AttributeLister interface
+func (o *OSSProvider) ListAttributes(ctx context.Context, prefix string) ([]ObjectAttributes, error) {
+	fullPrefix := o.buildPath(prefix)
+	paginator := o.client.NewListObjectsV2Paginator(&oss.ListObjectsV2Request{
+		Bucket: oss.Ptr(o.bucket),
+		Prefix: oss.Ptr(fullPrefix),
+	})
+	var objects []ObjectAttributes
+	for paginator.HasNext() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, classifyError(err)
+		}
+		for _, object := range page.Contents {
+			objects = append(objects, ObjectAttributes{
+				Path:         oss.ToString(object.Key),
+				Size:         object.Size,
+				LastModified: oss.ToTime(object.LastModified),
+				ETag:         oss.ToString(object.ETag),
+			})
+		}
 	}
 	return objects, nil
 }
+
+// ListPage implements storage.PageTokenLister interface
+func (o *OSSProvider) ListPage(ctx context.Context, prefix, token string, limit int) ([]string, string, error) {
+	fullPrefix := o.buildPath(prefix)
+	page, err := o.client.ListObjectsV2(ctx, &oss.ListObjectsV2Request{
+		Bucket:            oss.Ptr(o.bucket),
+		Prefix:            oss.Ptr(fullPrefix),
+		ContinuationToken: optionalString(token),
+		MaxKeys:           pageLimit(limit, 1000),
+	})
+	if err != nil {
+		return nil, "", classifyError(err)
+	}
+
+	objects := make([]string, 0, len(page.Contents))
+	for _, object := range page.Contents {
+		objects = append(objects, *object.Key)
+	}
+	if !page.IsTruncated {
+		return objects, "", nil
+	}
+	return objects, oss.ToString(page.NextContinuationToken), nil
+}
+
+// ListCommonPrefixes implements storage.PrefixLister interface
+func (o *OSSProvider) ListCommonPrefixes(ctx context.Context, prefix, delimiter string) ([]string, error) {
+	paginator := o.client.NewListObjectsV2Paginator(&oss.ListObjectsV2Request{
+		Bucket:    oss.Ptr(o.bucket),
+		Prefix:    oss.Ptr(o.buildPath(prefix)),
+		Delimiter: oss.Ptr(delimiter),
+	})
+	var prefixes []string
+	for paginator.HasNext() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, classifyError(err)
+		}
+		for _, commonPrefix := range page.CommonPrefixes {
+			if commonPrefix.Prefix != nil {
+				prefixes = append(prefixes, *commonPrefix.Prefix)
+			}
+		}
+	}
+	return prefixes, nil
+}
+
+// DownloadVersion implements storage.VersionedProvider interface
+func (o *OSSProvider) DownloadVersion(ctx context.Context, path string, versionID string) (io.ReadCloser, error) {
+	fullPath := o.buildPath(path)
+	result, err := o.client.GetObject(ctx, &oss.GetObjectRequest{
+		Bucket:    &o.bucket,
+		Key:       &fullPath,
+		VersionId: &versionID,
+	})
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	return result.Body, nil
+}
+
+// ListVersions implements storage.VersionedProvider interface
+func (o *OSSProvider) ListVersions(ctx context.Context, path string) ([]ObjectVersion, error) {
+	fullPath := o.buildPath(path)
+	paginator := o.client.NewListObjectVersionsPaginator(&oss.ListObjectVersionsRequest{
+		Bucket: oss.Ptr(o.bucket),
+		Prefix: oss.Ptr(fullPath),
+	})
+	var versions []ObjectVersion
+	for paginator.HasNext() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, classifyError(err)
+		}
+		// The prefix also matches longer keys, keep only the object itself
+		for _, v := range page.ObjectVersions {
+			if oss.ToString(v.Key) == fullPath {
+				versions = append(versions, ObjectVersion{
+					Path:         path,
+					VersionID:    oss.ToString(v.VersionId),
+					LastModified: oss.ToTime(v.LastModified),
+					Size:         v.Size,
+					IsLatest:     v.IsLatest,
+				})
+			}
+		}
+		for _, m := range page.ObjectDeleteMarkers {
+			if oss.ToString(m.Key) == fullPath {
+				versions = append(versions, ObjectVersion{
+					Path:           path,
+					VersionID:      oss.ToString(m.VersionId),
+					LastModified:   oss.ToTime(m.LastModified),
+					IsLatest:       m.IsLatest,
+					IsDeleteMarker: true,
+				})
+			}
+		}
+	}
+	sortVersions(versions)
+	return versions, nil
+}
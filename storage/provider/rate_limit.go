@@ -0,0 +1,103 @@
+package provider
+
+import (
+	"io"
+	"math"
+	"net/http"
+
+	"golang.org/x/time/rate"
+)
+
+// minBandwidthBurst lower bound of the bandwidth limiter burst, so small limits still allow reasonably sized reads
+const minBandwidthBurst = 32 * 1024
+
+// RateLimitConfig client-side limits of the requests a provider sends to the storage service,
+// e.g. to stay below S3 or OSS request rate limits when many components upload at once
+type RateLimitConfig struct {
+	// RequestsPerSecond sustained request rate, 0 means unlimited
+	RequestsPerSecond float64 `json:"requests_per_second,omitempty"`
+	// Burst requests that may be sent at once, default the request rate rounded up
+	Burst int `json:"burst,omitempty"`
+	// BytesPerSecond combined upload and download bandwidth, 0 means unlimited
+	BytesPerSecond int64 `json:"bytes_per_second,omitempty"`
+}
+
+// HTTPDoer sends HTTP requests, the interface of the HTTP clients of the cloud SDKs
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// rateLimitedDoer waits for the request limiter before each request and throttles bodies
+type rateLimitedDoer struct {
+	base      HTTPDoer
+	requests  *rate.Limiter
+	bandwidth *rate.Limiter
+}
+
+// NewRateLimitedDoer wraps base so that requests respect limits, waiting is aborted when the
+// request context is done. base is returned unchanged if limits is nil or sets no limit.
+// Providers registered with storage.RegisterProvider can use it for their HTTP client.
+func NewRateLimitedDoer(base HTTPDoer, limits *RateLimitConfig) HTTPDoer {
+	if limits == nil || (limits.RequestsPerSecond <= 0 && limits.BytesPerSecond <= 0) {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultClient
+	}
+
+	d := &rateLimitedDoer{base: base}
+	if limits.RequestsPerSecond > 0 {
+		burst := limits.Burst
+		if burst <= 0 {
+			burst = int(math.Ceil(limits.RequestsPerSecond))
+		}
+		d.requests = rate.NewLimiter(rate.Limit(limits.RequestsPerSecond), burst)
+	}
+	if limits.BytesPerSecond > 0 {
+		d.bandwidth = rate.NewLimiter(rate.Limit(limits.BytesPerSecond), int(max(limits.BytesPerSecond, minBandwidthBurst)))
+	}
+	return d
+}
+
+// Do implements HTTPDoer interface
+func (d *rateLimitedDoer) Do(req *http.Request) (*http.Response, error) {
+	if d.requests != nil {
+		if err := d.requests.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+	if d.bandwidth == nil {
+		return d.base.Do(req)
+	}
+
+	if req.Body != nil && req.Body != http.NoBody {
+		req = req.Clone(req.Context())
+		req.Body = &bandwidthLimitedBody{ReadCloser: req.Body, limiter: d.bandwidth, req: req}
+	}
+	resp, err := d.base.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &bandwidthLimitedBody{ReadCloser: resp.Body, limiter: d.bandwidth, req: req}
+	return resp, nil
+}
+
+// bandwidthLimitedBody waits for the bandwidth limiter after each read
+type bandwidthLimitedBody struct {
+	io.ReadCloser
+	limiter *rate.Limiter
+	req     *http.Request
+}
+
+func (b *bandwidthLimitedBody) Read(p []byte) (int, error) {
+	if burst := b.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		if waitErr := b.limiter.WaitN(b.req.Context(), n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,63 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitedDoerRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	base := server.Client()
+	assert.Equal(t, HTTPDoer(base), NewRateLimitedDoer(base, nil))
+	assert.Equal(t, HTTPDoer(base), NewRateLimitedDoer(base, &RateLimitConfig{}))
+
+	doer := NewRateLimitedDoer(base, &RateLimitConfig{RequestsPerSecond: 10, Burst: 1})
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+		resp, err := doer.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond, "only the first request is free")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	_, err = doer.Do(req)
+	assert.Error(t, err, "cancelled context should abort waiting")
+}
+
+func TestRateLimitedDoerBandwidth(t *testing.T) {
+	payload := bytes.Repeat([]byte("x"), 96*1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	// Upload and download share the budget: after the free 64KiB burst, the other 128KiB take about 2 seconds
+	doer := NewRateLimitedDoer(server.Client(), &RateLimitConfig{BytesPerSecond: 64 * 1024})
+	start := time.Now()
+	req, err := http.NewRequest(http.MethodPut, server.URL, bytes.NewReader(payload))
+	require.NoError(t, err)
+	resp, err := doer.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, payload, body)
+	assert.GreaterOrEqual(t, time.Since(start), 1800*time.Millisecond)
+}
@@ -0,0 +1,41 @@
+package provider
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+)
+
+// validateS3Endpoint checks that the endpoint options of awsConfig can be combined
+func validateS3Endpoint(awsConfig *AWSConfig) error {
+	if awsConfig == nil {
+		return nil
+	}
+	if awsConfig.UseAccelerate && (awsConfig.MinIO || awsConfig.S3ForcePathStyle) {
+		return fmt.Errorf("S3 transfer acceleration requires virtual-hosted-style requests, it can't be used with path-style requests or MinIO")
+	}
+	if awsConfig.AccessPointARN == "" {
+		return nil
+	}
+	parsed, err := arn.Parse(awsConfig.AccessPointARN)
+	if err != nil {
+		return fmt.Errorf("invalid access point ARN %s: %w", awsConfig.AccessPointARN, err)
+	}
+	if parsed.Service != "s3" || !strings.HasPrefix(parsed.Resource, "accesspoint/") {
+		return fmt.Errorf("invalid access point ARN %s: not an S3 access point", awsConfig.AccessPointARN)
+	}
+	if awsConfig.UseAccelerate || awsConfig.MinIO {
+		return fmt.Errorf("S3 access points can't be used with transfer acceleration or MinIO")
+	}
+	return nil
+}
+
+// copySource returns the CopySource of CopyObject requests copying the object at key
+func (s *S3Provider) copySource(key string) string {
+	if s.accessPoint {
+		// Objects of access points are addressed as {arn}/object/{key}
+		return s.bucket + "/object/" + escapeKey(key)
+	}
+	return s.bucket + "/" + escapeKey(key)
+}
@@ -0,0 +1,40 @@
+package provider
+
+import (
+	"sort"
+	"strings"
+)
+
+// s3ExpressBucketSuffix suffix of S3 Express One Zone directory bucket names, e.g. metering--usw2-az1--x-s3
+const s3ExpressBucketSuffix = "--x-s3"
+
+// IsS3ExpressBucket reports whether bucket is an S3 Express One Zone directory bucket. The AWS SDK
+// authenticates requests to these buckets with CreateSession and routes them to the zonal endpoint.
+func IsS3ExpressBucket(bucket string) bool {
+	return strings.HasSuffix(bucket, s3ExpressBucketSuffix)
+}
+
+// s3ExpressListPrefix returns the prefix to list in a directory bucket, which only accepts prefixes
+// ending in "/", and whether results must be filtered by the original prefix
+func s3ExpressListPrefix(prefix string) (string, bool) {
+	if prefix == "" || strings.HasSuffix(prefix, "/") {
+		return prefix, false
+	}
+	return prefix[:strings.LastIndex(prefix, "/")+1], true
+}
+
+// filterS3ExpressKeys keeps the keys under prefix and sorts them, directory buckets don't list in
+// lexicographic order
+func filterS3ExpressKeys(keys []string, prefix string, filter bool) []string {
+	if filter {
+		filtered := keys[:0]
+		for _, key := range keys {
+			if strings.HasPrefix(key, prefix) {
+				filtered = append(filtered, key)
+			}
+		}
+		keys = filtered
+	}
+	sort.Strings(keys)
+	return keys
+}
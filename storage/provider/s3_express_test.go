@@ -0,0 +1,30 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestS3Express(t *testing.T) {
+	assert.True(t, IsS3ExpressBucket("metering--usw2-az1--x-s3"))
+	assert.False(t, IsS3ExpressBucket("metering"))
+
+	prefix, filter := s3ExpressListPrefix("metering/ru/1755850320/")
+	assert.Equal(t, "metering/ru/1755850320/", prefix)
+	assert.False(t, filter)
+
+	prefix, filter = s3ExpressListPrefix("metering/meta/logic/cluster001/17558")
+	assert.Equal(t, "metering/meta/logic/cluster001/", prefix)
+	assert.True(t, filter)
+
+	keys := filterS3ExpressKeys([]string{
+		"metering/meta/logic/cluster001/1755850380.json.gz",
+		"metering/meta/logic/cluster001/1755790000.json.gz",
+		"metering/meta/logic/cluster001/1755850320.json.gz",
+	}, "metering/meta/logic/cluster001/17558", true)
+	assert.Equal(t, []string{
+		"metering/meta/logic/cluster001/1755850320.json.gz",
+		"metering/meta/logic/cluster001/1755850380.json.gz",
+	}, keys)
+}
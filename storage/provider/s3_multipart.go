@@ -0,0 +1,123 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// s3MultipartPartSize is the part size used when streaming non-seekable bodies, S3 requires at least 5MiB
+const s3MultipartPartSize = 8 * 1024 * 1024
+
+// put uploads data to fullPath. Seekable bodies are sent with a single PutObject, other
+// bodies are streamed with a multipart upload so only one part is held in memory at a time.
+// ifNoneMatch, if not nil, is sent with the final request to make the upload conditional.
+// HTTP metadata is taken from the UploadOptions carried by ctx.
+func (s *S3Provider) put(ctx context.Context, fullPath string, data io.Reader, ifNoneMatch *string) error {
+	opts := UploadOptionsFromContext(ctx)
+	if opts == nil {
+		opts = &UploadOptions{}
+	}
+	if _, ok := data.(io.ReadSeeker); ok {
+		_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:               aws.String(s.bucket),
+			Key:                  aws.String(fullPath),
+			Body:                 data,
+			IfNoneMatch:          ifNoneMatch,
+			ContentType:          optionalString(opts.ContentType),
+			ContentEncoding:      optionalString(opts.ContentEncoding),
+			CacheControl:         optionalString(opts.CacheControl),
+			StorageClass:         types.StorageClass(opts.StorageClass),
+			Tagging:              opts.tagging(),
+			ServerSideEncryption: s.sse.mode,
+			SSEKMSKeyId:          s.sse.kmsKeyID,
+			BucketKeyEnabled:     s.sse.bucketKey,
+		})
+		return classifyError(err)
+	}
+
+	part := make([]byte, s3MultipartPartSize)
+	n, err := io.ReadFull(data, part)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		// Small enough for a single request
+		return s.put(ctx, fullPath, bytes.NewReader(part[:n]), ifNoneMatch)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read upload data: %w", err)
+	}
+
+	created, err := s.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:               aws.String(s.bucket),
+		Key:                  aws.String(fullPath),
+		ContentType:          optionalString(opts.ContentType),
+		ContentEncoding:      optionalString(opts.ContentEncoding),
+		CacheControl:         optionalString(opts.CacheControl),
+		StorageClass:         types.StorageClass(opts.StorageClass),
+		Tagging:              opts.tagging(),
+		ServerSideEncryption: s.sse.mode,
+		SSEKMSKeyId:          s.sse.kmsKeyID,
+		BucketKeyEnabled:     s.sse.bucketKey,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create multipart upload: %w", err)
+	}
+
+	if err := s.uploadParts(ctx, fullPath, created.UploadId, data, part, n, ifNoneMatch); err != nil {
+		// Abort even if ctx is cancelled, otherwise the uploaded parts keep incurring storage costs
+		_, abortErr := s.client.AbortMultipartUpload(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(s.bucket),
+			Key:      aws.String(fullPath),
+			UploadId: created.UploadId,
+		})
+		return errors.Join(err, abortErr)
+	}
+	return nil
+}
+
+// uploadParts uploads the first part already read into part and the rest of data, then completes the upload
+func (s *S3Provider) uploadParts(ctx context.Context, fullPath string, uploadID *string, data io.Reader, part []byte, n int, ifNoneMatch *string) error {
+	var completed []types.CompletedPart
+	for partNumber := int32(1); n > 0; partNumber++ {
+		result, err := s.client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:     aws.String(s.bucket),
+			Key:        aws.String(fullPath),
+			UploadId:   uploadID,
+			PartNumber: aws.Int32(partNumber),
+			Body:       bytes.NewReader(part[:n]),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to upload part %d: %w", partNumber, err)
+		}
+		completed = append(completed, types.CompletedPart{
+			ETag:       result.ETag,
+			PartNumber: aws.Int32(partNumber),
+		})
+
+		n, err = io.ReadFull(data, part)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return fmt.Errorf("failed to read upload data: %w", err)
+		}
+	}
+
+	_, err := s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.bucket),
+		Key:             aws.String(fullPath),
+		UploadId:        uploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+		IfNoneMatch:     ifNoneMatch,
+	})
+	return classifyError(err)
+}
+
+// isS3PreconditionFailed reports whether err is an S3 conditional write rejection
+func isS3PreconditionFailed(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "PreconditionFailed"
+}
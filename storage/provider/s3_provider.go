@@ -4,21 +4,31 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/url"
+	"sort"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
-	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 )
 
+// DefaultMinIORegion region used in MinIO mode when none is configured, MinIO's default
+const DefaultMinIORegion = "us-east-1"
+
 // S3Provider AWS S3 storage provider implementation
 type S3Provider struct {
-	client *s3.Client
-	bucket string
-	prefix string // path prefix
+	client  *s3.Client
+	bucket  string
+	prefix  string // path prefix
+	express bool   // bucket is an S3 Express One Zone directory bucket
+	sse     s3Encryption
+	// accessPoint bucket is the ARN of an access point
+	accessPoint bool
+	// credentials assume role credentials, nil when not assuming a role
+	credentials *CredentialCache
 }
 
 // NewS3Provider creates a new S3 storage provider
@@ -27,8 +37,16 @@ func NewS3Provider(providerConfig *ProviderConfig) (*S3Provider, error) {
 		return nil, fmt.Errorf("invalid provider type: %s, expected: %s", providerConfig.Type, ProviderTypeS3)
 	}
 
+	sse, err := newS3Encryption(providerConfig.AWS)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateS3Endpoint(providerConfig.AWS); err != nil {
+		return nil, err
+	}
+
 	var cfg aws.Config
-	var err error
+	var credCache *CredentialCache
 
 	// Check if there's a custom AWS Config
 	if providerConfig.AWS != nil && providerConfig.AWS.CustomConfig != nil {
@@ -37,6 +55,9 @@ func NewS3Provider(providerConfig *ProviderConfig) (*S3Provider, error) {
 		} else {
 			return nil, fmt.Errorf("invalid AWS config type, expected aws.Config")
 		}
+		if providerConfig.AWS.CredentialsProvider != nil {
+			cfg.Credentials = aws.NewCredentialsCache(providerConfig.AWS.CredentialsProvider)
+		}
 	} else {
 		// Build config options
 		var configOptions []func(*config.LoadOptions) error
@@ -46,8 +67,10 @@ func NewS3Provider(providerConfig *ProviderConfig) (*S3Provider, error) {
 			configOptions = append(configOptions, config.WithRegion(providerConfig.Region))
 		}
 
-		// Set credentials if provided
-		if providerConfig.AWS != nil && providerConfig.AWS.AccessKey != "" && providerConfig.AWS.SecretAccessKey != "" {
+		// Set credentials if provided, a custom provider takes precedence over static keys
+		if providerConfig.AWS != nil && providerConfig.AWS.CredentialsProvider != nil {
+			configOptions = append(configOptions, config.WithCredentialsProvider(providerConfig.AWS.CredentialsProvider))
+		} else if providerConfig.AWS != nil && providerConfig.AWS.AccessKey != "" && providerConfig.AWS.SecretAccessKey != "" {
 			staticCredentials := credentials.NewStaticCredentialsProvider(
 				providerConfig.AWS.AccessKey,
 				providerConfig.AWS.SecretAccessKey,
@@ -69,24 +92,75 @@ func NewS3Provider(providerConfig *ProviderConfig) (*S3Provider, error) {
 
 		// Set up assume role if configured (this takes precedence over static credentials for STS operations)
 		if providerConfig.AWS != nil && providerConfig.AWS.AssumeRoleARN != "" {
-			cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), providerConfig.AWS.AssumeRoleARN))
+			credCache, err = NewAWSCredentialCache(sts.NewFromConfig(cfg), providerConfig.AWS.AssumeRoleARN)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create credential cache: %w", err)
+			}
+			credCache.WithOnRefreshError(providerConfig.AWS.OnRefreshError)
+			credCache.StartBackgroundRefresh(context.Background())
+			cfg.Credentials = credCache
+		}
+	}
+
+	minio := providerConfig.AWS != nil && providerConfig.AWS.MinIO
+	if minio {
+		if cfg.Region == "" {
+			cfg.Region = DefaultMinIORegion
 		}
+		// Many S3-compatible stores reject the CRC checksum trailers the SDK sends by default
+		cfg.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+		cfg.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
+	}
+
+	bucket := providerConfig.Bucket
+	accessPoint := providerConfig.AWS != nil && providerConfig.AWS.AccessPointARN != ""
+	if accessPoint {
+		bucket = providerConfig.AWS.AccessPointARN
 	}
 
 	// Create S3 client
 	s3Client := s3.NewFromConfig(cfg, func(o *s3.Options) {
-		if providerConfig.AWS != nil && providerConfig.AWS.S3ForcePathStyle {
+		if minio || providerConfig.AWS != nil && providerConfig.AWS.S3ForcePathStyle {
 			o.UsePathStyle = true
 		}
+		if providerConfig.AWS != nil && providerConfig.AWS.DisableS3ExpressSessionAuth {
+			o.DisableS3ExpressSessionAuth = aws.Bool(true)
+		}
+		if providerConfig.AWS != nil && providerConfig.AWS.UseAccelerate {
+			o.UseAccelerate = true
+		}
+		if providerConfig.AWS != nil && providerConfig.AWS.UseDualStack {
+			o.EndpointOptions.UseDualStackEndpoint = aws.DualStackEndpointStateEnabled
+		}
+		if accessPoint {
+			// Requests go to the region of the access point, which may not be the configured one
+			o.UseARNRegion = true
+		}
+		if providerConfig.RateLimit != nil {
+			o.HTTPClient = NewRateLimitedDoer(o.HTTPClient, providerConfig.RateLimit)
+		}
 	})
 
 	return &S3Provider{
-		client: s3Client,
-		bucket: providerConfig.Bucket,
-		prefix: providerConfig.Prefix,
+		client:      s3Client,
+		bucket:      bucket,
+		prefix:      providerConfig.Prefix,
+		express:     !minio && !accessPoint && IsS3ExpressBucket(providerConfig.Bucket),
+		accessPoint: accessPoint,
+		sse:         sse,
+		credentials: credCache,
 	}, nil
 }
 
+// Credentials returns the state of the assume role credential cache, e.g. to export the expiration of
+// the STS token as a metric. It returns false when the provider doesn't assume a role
+func (s *S3Provider) Credentials() (CredentialState, bool) {
+	if s.credentials == nil {
+		return CredentialState{}, false
+	}
+	return s.credentials.Credentials(), true
+}
+
 // buildPath builds the complete path with prefix
 func (s *S3Provider) buildPath(path string) string {
 	if s.prefix == "" {
@@ -100,13 +174,16 @@ func (s *S3Provider) buildPath(path string) string {
 
 // Upload implements ObjectStorageProvider interface
 func (s *S3Provider) Upload(ctx context.Context, path string, data io.Reader) error {
-	fullPath := s.buildPath(path)
-	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket: aws.String(s.bucket),
-		Key:    aws.String(fullPath),
-		Body:   data,
-	})
-	return err
+	return s.put(ctx, s.buildPath(path), data, nil)
+}
+
+// UploadIfNotExists uploads data only if no object exists at path, using If-None-Match: *
+func (s *S3Provider) UploadIfNotExists(ctx context.Context, path string, data io.Reader) error {
+	err := s.put(ctx, s.buildPath(path), data, aws.String("*"))
+	if isS3PreconditionFailed(err) {
+		return fmt.Errorf("%w: %s", ErrObjectExists, path)
+	}
+	return classifyError(err)
 }
 
 // Download implements ObjectStorageProvider interface
@@ -117,7 +194,7 @@ func (s *S3Provider) Download(ctx context.Context, path string) (io.ReadCloser,
 		Key:    aws.String(fullPath),
 	})
 	if err != nil {
-		return nil, err
+		return nil, classifyError(err)
 	}
 	return result.Body, nil
 }
@@ -129,7 +206,21 @@ func (s *S3Provider) Delete(ctx context.Context, path string) error {
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(fullPath),
 	})
-	return err
+	return classifyError(err)
+}
+
+// Copy implements storage.Copier interface with a server-side CopyObject, objects larger than 5GiB
+// can't be copied
+func (s *S3Provider) Copy(ctx context.Context, src, dst string) error {
+	_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:               aws.String(s.bucket),
+		Key:                  aws.String(s.buildPath(dst)),
+		CopySource:           aws.String(s.copySource(s.buildPath(src))),
+		ServerSideEncryption: s.sse.mode,
+		SSEKMSKeyId:          s.sse.kmsKeyID,
+		BucketKeyEnabled:     s.sse.bucketKey,
+	})
+	return classifyError(err)
 }
 
 // Exists implements ObjectStorageProvider interface
@@ -143,32 +234,241 @@ func (s *S3Provider) Exists(ctx context.Context, path string) (bool, error) {
 		if strings.Contains(err.Error(), "NotFound") || strings.Contains(err.Error(), "NoSuchKey") {
 			return false, nil
 		}
-		return false, err
+		return false, classifyError(err)
 	}
 	return true, nil
 }
 
+// Stat implements storage.ObjectStater interface
+func (s *S3Provider) Stat(ctx context.Context, path string) (*ObjectAttributes, error) {
+	result, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.buildPath(path)),
+	})
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	return &ObjectAttributes{
+		Path:         path,
+		Size:         aws.ToInt64(result.ContentLength),
+		LastModified: aws.ToTime(result.LastModified),
+		ETag:         aws.ToString(result.ETag),
+		Metadata:     result.Metadata,
+	}, nil
+}
+
 // List implements ObjectStorageProvider interface
 func (s *S3Provider) List(ctx context.Context, prefix string) ([]string, error) {
 	var objects []string
+	err := s.ListPages(ctx, prefix, func(page []string) error {
+		objects = append(objects, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	if s.express {
+		// Directory buckets don't list in lexicographic order, not even across pages
+		sort.Strings(objects)
+	}
+	return objects, nil
+}
+
+// ListPages implements storage.PageLister interface
+func (s *S3Provider) ListPages(ctx context.Context, prefix string, fn func(page []string) error) error {
 	fullPrefix := s.buildPath(prefix)
+	listPrefix, filter := fullPrefix, false
+	if s.express {
+		listPrefix, filter = s3ExpressListPrefix(fullPrefix)
+	}
 	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
 		Bucket: aws.String(s.bucket),
-		Prefix: aws.String(fullPrefix),
+		Prefix: aws.String(listPrefix),
 	})
 
 	for paginator.HasMorePages() {
 		page, err := paginator.NextPage(ctx)
 		if err != nil {
-			return nil, err
+			return classifyError(err)
 		}
 
+		objects := make([]string, 0, len(page.Contents))
 		for _, obj := range page.Contents {
 			if obj.Key != nil {
 				objects = append(objects, *obj.Key)
 			}
 		}
+		if s.express {
+			objects = filterS3ExpressKeys(objects, fullPrefix, filter)
+		}
+		if err := fn(objects); err != nil {
+			return classifyError(err)
+		}
 	}
+	return nil
+}
 
+// ListAttributes implements storage.AttributeLister interface
+func (s *S3Provider) ListAttributes(ctx context.Context, prefix string) ([]ObjectAttributes, error) {
+	fullPrefix := s.buildPath(prefix)
+	listPrefix, filter := fullPrefix, false
+	if s.express {
+		listPrefix, filter = s3ExpressListPrefix(fullPrefix)
+	}
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(listPrefix),
+	})
+
+	var objects []ObjectAttributes
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, classifyError(err)
+		}
+		for _, obj := range page.Contents {
+			if obj.Key == nil || (filter && !strings.HasPrefix(*obj.Key, fullPrefix)) {
+				continue
+			}
+			objects = append(objects, ObjectAttributes{
+				Path:         *obj.Key,
+				Size:         aws.ToInt64(obj.Size),
+				LastModified: aws.ToTime(obj.LastModified),
+				ETag:         aws.ToString(obj.ETag),
+			})
+		}
+	}
+	if s.express {
+		sort.Slice(objects, func(i, j int) bool { return objects[i].Path < objects[j].Path })
+	}
 	return objects, nil
 }
+
+// ListPage implements storage.PageTokenLister interface
+func (s *S3Provider) ListPage(ctx context.Context, prefix, token string, limit int) ([]string, string, error) {
+	fullPrefix := s.buildPath(prefix)
+	listPrefix, filter := fullPrefix, false
+	if s.express {
+		listPrefix, filter = s3ExpressListPrefix(fullPrefix)
+	}
+	page, err := s.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:            aws.String(s.bucket),
+		Prefix:            aws.String(listPrefix),
+		ContinuationToken: optionalString(token),
+		MaxKeys:           aws.Int32(pageLimit(limit, 1000)),
+	})
+	if err != nil {
+		return nil, "", classifyError(err)
+	}
+
+	objects := make([]string, 0, len(page.Contents))
+	for _, obj := range page.Contents {
+		if obj.Key != nil {
+			objects = append(objects, *obj.Key)
+		}
+	}
+	if s.express {
+		objects = filterS3ExpressKeys(objects, fullPrefix, filter)
+	}
+	if !aws.ToBool(page.IsTruncated) {
+		return objects, "", nil
+	}
+	return objects, aws.ToString(page.NextContinuationToken), nil
+}
+
+// ListCommonPrefixes implements storage.PrefixLister interface
+func (s *S3Provider) ListCommonPrefixes(ctx context.Context, prefix, delimiter string) ([]string, error) {
+	fullPrefix := s.buildPath(prefix)
+	listPrefix, filter := fullPrefix, false
+	if s.express {
+		listPrefix, filter = s3ExpressListPrefix(fullPrefix)
+	}
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.bucket),
+		Prefix:    aws.String(listPrefix),
+		Delimiter: aws.String(delimiter),
+	})
+
+	var prefixes []string
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, classifyError(err)
+		}
+		for _, commonPrefix := range page.CommonPrefixes {
+			if commonPrefix.Prefix != nil {
+				prefixes = append(prefixes, *commonPrefix.Prefix)
+			}
+		}
+	}
+	if s.express {
+		prefixes = filterS3ExpressKeys(prefixes, fullPrefix, filter)
+	}
+	return prefixes, nil
+}
+
+// DownloadVersion implements storage.VersionedProvider interface
+func (s *S3Provider) DownloadVersion(ctx context.Context, path string, versionID string) (io.ReadCloser, error) {
+	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket:    aws.String(s.bucket),
+		Key:       aws.String(s.buildPath(path)),
+		VersionId: aws.String(versionID),
+	})
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	return result.Body, nil
+}
+
+// ListVersions implements storage.VersionedProvider interface
+func (s *S3Provider) ListVersions(ctx context.Context, path string) ([]ObjectVersion, error) {
+	var versions []ObjectVersion
+	fullPath := s.buildPath(path)
+	paginator := s3.NewListObjectVersionsPaginator(s.client, &s3.ListObjectVersionsInput{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(fullPath),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, classifyError(err)
+		}
+
+		// The prefix also matches longer keys, keep only the object itself
+		for _, v := range page.Versions {
+			if aws.ToString(v.Key) == fullPath {
+				versions = append(versions, ObjectVersion{
+					Path:         path,
+					VersionID:    aws.ToString(v.VersionId),
+					LastModified: aws.ToTime(v.LastModified),
+					Size:         aws.ToInt64(v.Size),
+					IsLatest:     aws.ToBool(v.IsLatest),
+				})
+			}
+		}
+		for _, m := range page.DeleteMarkers {
+			if aws.ToString(m.Key) == fullPath {
+				versions = append(versions, ObjectVersion{
+					Path:           path,
+					VersionID:      aws.ToString(m.VersionId),
+					LastModified:   aws.ToTime(m.LastModified),
+					IsLatest:       aws.ToBool(m.IsLatest),
+					IsDeleteMarker: true,
+				})
+			}
+		}
+	}
+
+	sortVersions(versions)
+	return versions, nil
+}
+
+// escapeKey URL-encodes every segment of an object key, as required in copy sources
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,116 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewS3Provider_CredentialsProvider(t *testing.T) {
+	calls := 0
+	broker := aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		calls++
+		return aws.Credentials{AccessKeyID: "broker-key", SecretAccessKey: "broker-secret", Source: "broker"}, nil
+	})
+
+	for name, awsConfig := range map[string]*AWSConfig{
+		"takes precedence over static keys": {
+			AccessKey:           "static-key",
+			SecretAccessKey:     "static-secret",
+			CredentialsProvider: broker,
+		},
+		"overrides custom config credentials": {
+			CustomConfig:        aws.Config{Region: "us-west-2"},
+			CredentialsProvider: broker,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			calls = 0
+			provider, err := NewS3Provider(&ProviderConfig{
+				Type:   ProviderTypeS3,
+				Bucket: "metering",
+				Region: "us-west-2",
+				AWS:    awsConfig,
+			})
+			require.NoError(t, err)
+
+			credentials := provider.client.Options().Credentials
+			for range 2 {
+				creds, err := credentials.Retrieve(context.Background())
+				require.NoError(t, err)
+				assert.Equal(t, "broker-key", creds.AccessKeyID)
+			}
+			assert.Equal(t, 1, calls, "credentials should be cached")
+		})
+	}
+}
+
+func TestNewS3Provider_MinIO(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	provider, err := NewS3Provider(&ProviderConfig{
+		Type:     ProviderTypeS3,
+		Bucket:   "metering--usw2-az1--x-s3",
+		Endpoint: "http://minio:9000",
+		AWS:      &AWSConfig{MinIO: true, AccessKey: "minioadmin", SecretAccessKey: "minioadmin"},
+	})
+	require.NoError(t, err)
+
+	options := provider.client.Options()
+	assert.True(t, options.UsePathStyle)
+	assert.Equal(t, DefaultMinIORegion, options.Region)
+	assert.Equal(t, aws.RequestChecksumCalculationWhenRequired, options.RequestChecksumCalculation)
+	assert.Equal(t, aws.ResponseChecksumValidationWhenRequired, options.ResponseChecksumValidation)
+	assert.False(t, provider.express, "bucket names have no special meaning on MinIO")
+
+	provider, err = NewS3Provider(&ProviderConfig{
+		Type:     ProviderTypeS3,
+		Bucket:   "metering",
+		Region:   "eu-central-1",
+		Endpoint: "http://minio:9000",
+		AWS:      &AWSConfig{MinIO: true},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "eu-central-1", provider.client.Options().Region)
+}
+
+func TestNewS3Provider_Endpoints(t *testing.T) {
+	provider, err := NewS3Provider(&ProviderConfig{
+		Type:   ProviderTypeS3,
+		Bucket: "metering",
+		Region: "ap-southeast-1",
+		AWS:    &AWSConfig{UseAccelerate: true, UseDualStack: true},
+	})
+	require.NoError(t, err)
+	options := provider.client.Options()
+	assert.True(t, options.UseAccelerate)
+	assert.Equal(t, aws.DualStackEndpointStateEnabled, options.EndpointOptions.UseDualStackEndpoint)
+
+	accessPointARN := "arn:aws:s3:us-west-2:123456789012:accesspoint/metering"
+	provider, err = NewS3Provider(&ProviderConfig{
+		Type:   ProviderTypeS3,
+		Bucket: "metering",
+		Region: "ap-southeast-1",
+		AWS:    &AWSConfig{AccessPointARN: accessPointARN},
+	})
+	require.NoError(t, err)
+	assert.True(t, provider.client.Options().UseARNRegion)
+	assert.Equal(t, accessPointARN, provider.bucket)
+	assert.Equal(t, accessPointARN+"/object/a/b%20c.json", provider.copySource("a/b c.json"))
+
+	for name, awsConfig := range map[string]*AWSConfig{
+		"accelerate with path style":   {UseAccelerate: true, S3ForcePathStyle: true},
+		"accelerate with MinIO":        {UseAccelerate: true, MinIO: true},
+		"invalid access point ARN":     {AccessPointARN: "metering"},
+		"not an access point":          {AccessPointARN: "arn:aws:s3:::metering"},
+		"access point with accelerate": {AccessPointARN: accessPointARN, UseAccelerate: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewS3Provider(&ProviderConfig{Type: ProviderTypeS3, Bucket: "metering", Region: "us-west-2", AWS: awsConfig})
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,492 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	// DefaultSFTPPort port used when SFTPConfig.Host has none
+	DefaultSFTPPort = "22"
+	// sftpDialTimeout timeout of establishing the SSH connection
+	sftpDialTimeout = 30 * time.Second
+	// sftpPartialMarker marks files being uploaded, which are renamed into place once complete
+	sftpPartialMarker = ".partial-"
+)
+
+// SFTPProvider SFTP storage provider implementation, for environments where metering files are dropped
+// onto a jump host. Uploads are written to a partial file renamed into place once complete, so consumers
+// on the host never see truncated files. The connection is established on first use and re-established
+// after it is lost.
+type SFTPProvider struct {
+	basePath string
+	prefix   string
+	dial     func() (*sftp.Client, error)
+
+	mu      sync.Mutex
+	session *sftpSession
+}
+
+// sftpSession an SFTP client and whether its connection is closed
+type sftpSession struct {
+	client *sftp.Client
+	closed chan struct{}
+}
+
+// alive reports whether the connection of the session is open
+func (s *sftpSession) alive() bool {
+	select {
+	case <-s.closed:
+		return false
+	default:
+		return true
+	}
+}
+
+// NewSFTPProvider creates a new SFTP storage provider authenticating with a private key
+func NewSFTPProvider(providerConfig *ProviderConfig) (*SFTPProvider, error) {
+	if providerConfig.Type != ProviderTypeSFTP {
+		return nil, fmt.Errorf("invalid provider type: %s, expected: %s", providerConfig.Type, ProviderTypeSFTP)
+	}
+	sftpConfig := providerConfig.SFTP
+	if sftpConfig == nil || sftpConfig.Host == "" {
+		return nil, fmt.Errorf("SFTP host is required")
+	}
+	if sftpConfig.User == "" {
+		return nil, fmt.Errorf("SFTP user is required")
+	}
+
+	signer, err := sftpSigner(sftpConfig)
+	if err != nil {
+		return nil, err
+	}
+	hostKeyCallback, err := sftpHostKeyCallback(sftpConfig)
+	if err != nil {
+		return nil, err
+	}
+	clientConfig := &ssh.ClientConfig{
+		User:            sftpConfig.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         sftpDialTimeout,
+	}
+
+	addr := sftpConfig.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, DefaultSFTPPort)
+	}
+	dial := func() (*sftp.Client, error) {
+		conn, err := ssh.Dial("tcp", addr, clientConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to SFTP host %s: %w", addr, err)
+		}
+		client, err := sftp.NewClient(conn)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to start SFTP session on %s: %w", addr, err)
+		}
+		// Closing the SFTP client only ends the session, the connection is closed with it
+		go func() {
+			_ = client.Wait()
+			conn.Close()
+		}()
+		return client, nil
+	}
+
+	return newSFTPProvider(sftpConfig.BasePath, providerConfig.Prefix, dial), nil
+}
+
+// newSFTPProvider creates a provider using dial to establish SFTP sessions
+func newSFTPProvider(basePath, prefix string, dial func() (*sftp.Client, error)) *SFTPProvider {
+	return &SFTPProvider{
+		basePath: basePath,
+		prefix:   prefix,
+		dial:     dial,
+	}
+}
+
+// sftpSigner loads the private key of the SFTP user
+func sftpSigner(sftpConfig *SFTPConfig) (ssh.Signer, error) {
+	key := []byte(sftpConfig.PrivateKey)
+	if len(key) == 0 {
+		if sftpConfig.PrivateKeyFile == "" {
+			return nil, fmt.Errorf("SFTP private key or private key file is required")
+		}
+		var err error
+		if key, err = os.ReadFile(sftpConfig.PrivateKeyFile); err != nil {
+			return nil, fmt.Errorf("failed to read SFTP private key file: %w", err)
+		}
+	}
+
+	var signer ssh.Signer
+	var err error
+	if sftpConfig.Passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(sftpConfig.Passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey(key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SFTP private key: %w", err)
+	}
+	return signer, nil
+}
+
+// sftpHostKeyCallback verifies the host key against the known hosts file, ~/.ssh/known_hosts by default
+func sftpHostKeyCallback(sftpConfig *SFTPConfig) (ssh.HostKeyCallback, error) {
+	if sftpConfig.InsecureIgnoreHostKey {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	knownHostsFile := sftpConfig.KnownHostsFile
+	if knownHostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate known hosts file: %w", err)
+		}
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	callback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known hosts file %s: %w", knownHostsFile, err)
+	}
+	return callback, nil
+}
+
+// buildPath builds the complete remote path with base path and prefix, failing with ErrInvalidPath if it
+// would be outside of the base path
+func (s *SFTPProvider) buildPath(p string) (string, error) {
+	// Keys may be derived from user-controlled cluster IDs, they must not escape the base path
+	if err := validateKey(p); err != nil {
+		return "", err
+	}
+	return path.Join(s.basePath, s.prefix, p), nil
+}
+
+// do runs fn with the SFTP client, connecting first if there is no session or its connection was lost
+func (s *SFTPProvider) do(ctx context.Context, fn func(client *sftp.Client) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	session := s.session
+	if session == nil || !session.alive() {
+		client, err := s.dial()
+		if err != nil {
+			s.mu.Unlock()
+			return err
+		}
+		session = &sftpSession{client: client, closed: make(chan struct{})}
+		go func() {
+			_ = client.Wait()
+			close(session.closed)
+		}()
+		s.session = session
+	}
+	s.mu.Unlock()
+
+	return fn(session.client)
+}
+
+// Close closes the SFTP connection, a later operation reconnects
+func (s *SFTPProvider) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.session == nil {
+		return nil
+	}
+	err := s.session.client.Close()
+	s.session = nil
+	return err
+}
+
+// Upload implements ObjectStorageProvider interface
+func (s *SFTPProvider) Upload(ctx context.Context, path string, data io.Reader) error {
+	fullPath, err := s.buildPath(path)
+	if err != nil {
+		return err
+	}
+	return s.do(ctx, func(client *sftp.Client) error {
+		partial, err := s.writePartial(client, fullPath, data)
+		if err != nil {
+			return err
+		}
+		if err := posixRename(client, partial, fullPath); err != nil {
+			client.Remove(partial)
+			return fmt.Errorf("failed to rename %s to %s: %w", partial, fullPath, err)
+		}
+		return nil
+	})
+}
+
+// UploadIfNotExists uploads data only if no file exists at path. The partial file is hard linked into
+// place, which fails atomically when the target exists. Servers without the hardlink extension fall back
+// to a rename, which the SFTP protocol also requires to fail when the target exists; servers that replace
+// the target on rename instead leave a window where concurrent uploads can overwrite each other
+func (s *SFTPProvider) UploadIfNotExists(ctx context.Context, path string, data io.Reader) error {
+	fullPath, err := s.buildPath(path)
+	if err != nil {
+		return err
+	}
+	return s.do(ctx, func(client *sftp.Client) error {
+		// Skip the upload if the file already exists, the link below decides races
+		if _, err := client.Stat(fullPath); err == nil {
+			return fmt.Errorf("%w: %s", ErrObjectExists, path)
+		}
+		partial, err := s.writePartial(client, fullPath, data)
+		if err != nil {
+			return err
+		}
+		defer client.Remove(partial)
+		err = client.Link(partial, fullPath)
+		var status *sftp.StatusError
+		if errors.As(err, &status) && status.FxCode() == sftp.ErrSSHFxOpUnsupported {
+			err = client.Rename(partial, fullPath)
+		}
+		if err != nil {
+			if _, statErr := client.Stat(fullPath); statErr == nil {
+				return fmt.Errorf("%w: %s", ErrObjectExists, path)
+			}
+			return fmt.Errorf("failed to link %s to %s: %w", partial, fullPath, err)
+		}
+		return nil
+	})
+}
+
+// writePartial writes data to a partial file next to fullPath and returns its path
+func (s *SFTPProvider) writePartial(client *sftp.Client, fullPath string, data io.Reader) (string, error) {
+	dir := path.Dir(fullPath)
+	if err := client.MkdirAll(dir); err != nil {
+		return "", fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	var suffix [8]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return "", fmt.Errorf("failed to generate partial file name: %w", err)
+	}
+	partial := path.Join(dir, "."+path.Base(fullPath)+sftpPartialMarker+hex.EncodeToString(suffix[:]))
+
+	file, err := client.OpenFile(partial, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return "", fmt.Errorf("failed to create file %s: %w", partial, err)
+	}
+	if _, err := file.ReadFrom(data); err != nil {
+		file.Close()
+		client.Remove(partial)
+		return "", fmt.Errorf("failed to write data to file %s: %w", partial, err)
+	}
+	if err := file.Close(); err != nil {
+		client.Remove(partial)
+		return "", fmt.Errorf("failed to close file %s: %w", partial, err)
+	}
+	return partial, nil
+}
+
+// posixRename renames oldPath to newPath, replacing newPath if it exists. Servers without the
+// posix-rename extension fall back to removing newPath first
+func posixRename(client *sftp.Client, oldPath, newPath string) error {
+	err := client.PosixRename(oldPath, newPath)
+	var status *sftp.StatusError
+	if err == nil || !errors.As(err, &status) || status.FxCode() != sftp.ErrSSHFxOpUnsupported {
+		return err
+	}
+	if err := client.Remove(newPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return client.Rename(oldPath, newPath)
+}
+
+// Download implements ObjectStorageProvider interface
+func (s *SFTPProvider) Download(ctx context.Context, path string) (io.ReadCloser, error) {
+	fullPath, err := s.buildPath(path)
+	if err != nil {
+		return nil, err
+	}
+	var file *sftp.File
+	err = s.do(ctx, func(client *sftp.Client) error {
+		var err error
+		if file, err = client.Open(fullPath); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return &classifiedError{class: ErrNotFound, err: fmt.Errorf("file not found: %s", path)}
+			}
+			return fmt.Errorf("failed to open file %s: %w", fullPath, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// Delete implements ObjectStorageProvider interface
+func (s *SFTPProvider) Delete(ctx context.Context, path string) error {
+	fullPath, err := s.buildPath(path)
+	if err != nil {
+		return err
+	}
+	return s.do(ctx, func(client *sftp.Client) error {
+		if err := client.Remove(fullPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to delete file %s: %w", fullPath, err)
+		}
+		return nil
+	})
+}
+
+// Copy implements storage.Copier interface, SFTP has no server-side copy
+func (s *SFTPProvider) Copy(ctx context.Context, src, dst string) error {
+	file, err := s.Download(ctx, src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return s.Upload(ctx, dst, file)
+}
+
+// Move implements storage.Mover interface with a rename
+func (s *SFTPProvider) Move(ctx context.Context, src, dst string) error {
+	srcPath, err := s.buildPath(src)
+	if err != nil {
+		return err
+	}
+	dstPath, err := s.buildPath(dst)
+	if err != nil {
+		return err
+	}
+	return s.do(ctx, func(client *sftp.Client) error {
+		if _, err := client.Stat(srcPath); errors.Is(err, fs.ErrNotExist) {
+			return &classifiedError{class: ErrNotFound, err: fmt.Errorf("file not found: %s", src)}
+		}
+		if err := client.MkdirAll(path.Dir(dstPath)); err != nil {
+			return fmt.Errorf("failed to create directory %s: %w", path.Dir(dstPath), err)
+		}
+		if err := posixRename(client, srcPath, dstPath); err != nil {
+			return fmt.Errorf("failed to move file %s to %s: %w", srcPath, dstPath, err)
+		}
+		return nil
+	})
+}
+
+// Exists implements ObjectStorageProvider interface
+func (s *SFTPProvider) Exists(ctx context.Context, path string) (bool, error) {
+	fullPath, err := s.buildPath(path)
+	if err != nil {
+		return false, err
+	}
+	exists := false
+	err = s.do(ctx, func(client *sftp.Client) error {
+		if _, err := client.Stat(fullPath); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return fmt.Errorf("failed to check file existence %s: %w", fullPath, err)
+		}
+		exists = true
+		return nil
+	})
+	return exists, err
+}
+
+// Stat implements storage.ObjectStater interface. Like LocalFS, the ETag is derived from the modification
+// time and size, and there is no user metadata.
+func (s *SFTPProvider) Stat(ctx context.Context, path string) (*ObjectAttributes, error) {
+	fullPath, err := s.buildPath(path)
+	if err != nil {
+		return nil, err
+	}
+	var attrs *ObjectAttributes
+	err = s.do(ctx, func(client *sftp.Client) error {
+		info, err := client.Stat(fullPath)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return &classifiedError{class: ErrNotFound, err: fmt.Errorf("file not found: %s", path)}
+			}
+			return fmt.Errorf("failed to stat file %s: %w", fullPath, err)
+		}
+		attrs = &ObjectAttributes{
+			Path:         path,
+			Size:         info.Size(),
+			LastModified: info.ModTime(),
+			ETag:         fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()),
+		}
+		return nil
+	})
+	return attrs, err
+}
+
+// List implements ObjectStorageProvider interface. Like LocalFS, paths are relative to the base path,
+// and partial files of ongoing uploads are skipped
+func (s *SFTPProvider) List(ctx context.Context, prefix string) ([]string, error) {
+	if err := validateKey(prefix); err != nil {
+		return nil, err
+	}
+	expectedPrefix := strings.TrimPrefix(path.Join(s.prefix, prefix), "/")
+	if expectedPrefix == "." {
+		expectedPrefix = ""
+	}
+	if prefix != "" && strings.HasSuffix(prefix, "/") {
+		expectedPrefix += "/"
+	}
+
+	// Only walk the directory containing the prefix
+	root := ""
+	if i := strings.LastIndex(expectedPrefix, "/"); i >= 0 {
+		root = expectedPrefix[:i]
+	}
+
+	var files []string
+	err := s.do(ctx, func(client *sftp.Client) error {
+		var walk func(dir string) error
+		walk = func(dir string) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			entries, err := client.ReadDir(path.Join(s.basePath, dir))
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			for _, entry := range entries {
+				relPath := path.Join(dir, entry.Name())
+				if !strings.HasPrefix(relPath, expectedPrefix) && !strings.HasPrefix(expectedPrefix, relPath+"/") {
+					continue
+				}
+				if entry.IsDir() {
+					if err := walk(relPath); err != nil {
+						return err
+					}
+				} else if !strings.Contains(entry.Name(), sftpPartialMarker) && strings.HasPrefix(relPath, expectedPrefix) {
+					files = append(files, relPath)
+				}
+			}
+			return nil
+		}
+		if err := walk(root); err != nil {
+			return fmt.Errorf("failed to list files with prefix %s: %w", prefix, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
@@ -0,0 +1,212 @@
+package provider
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// memorySFTPServer serves an in-memory filesystem, one session per dial
+type memorySFTPServer struct {
+	handlers sftp.Handlers
+
+	mu    sync.Mutex
+	conns []net.Conn
+	dials int
+}
+
+func (m *memorySFTPServer) dial() (*sftp.Client, error) {
+	serverConn, clientConn := net.Pipe()
+	server := sftp.NewRequestServer(serverConn, m.handlers)
+	go server.Serve()
+
+	m.mu.Lock()
+	m.conns = append(m.conns, serverConn)
+	m.dials++
+	m.mu.Unlock()
+	return sftp.NewClientPipe(clientConn, clientConn)
+}
+
+// dropConnections closes the server side of every session
+func (m *memorySFTPServer) dropConnections() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, conn := range m.conns {
+		conn.Close()
+	}
+	m.conns = nil
+}
+
+func newMemorySFTPProvider(t *testing.T, prefix string) (*SFTPProvider, *memorySFTPServer) {
+	server := &memorySFTPServer{handlers: sftp.InMemHandler()}
+	provider := newSFTPProvider("/upload", prefix, server.dial)
+	t.Cleanup(func() {
+		provider.Close()
+		server.dropConnections()
+	})
+	return provider, server
+}
+
+func readSFTP(t *testing.T, provider *SFTPProvider, path string) string {
+	t.Helper()
+	rc, err := provider.Download(context.Background(), path)
+	require.NoError(t, err)
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestSFTPProvider(t *testing.T) {
+	provider, _ := newMemorySFTPProvider(t, "metering-data")
+	ctx := context.Background()
+	path := "metering/ru/1755849600/tidb/pool1/server1-0.json.gz"
+
+	require.NoError(t, provider.Upload(ctx, path, strings.NewReader("first")))
+	require.NoError(t, provider.Upload(ctx, path, strings.NewReader("second")))
+	assert.Equal(t, "second", readSFTP(t, provider, path))
+
+	exists, err := provider.Exists(ctx, path)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	attrs, err := provider.Stat(ctx, path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len("second")), attrs.Size)
+
+	// Conditional uploads
+	err = provider.UploadIfNotExists(ctx, path, strings.NewReader("third"))
+	assert.ErrorIs(t, err, ErrObjectExists)
+	meta := "metering/meta/tidb/pool1/1755849600.json.gz"
+	require.NoError(t, provider.UploadIfNotExists(ctx, meta, strings.NewReader("meta")))
+
+	// Listings are relative to the base path and skip partial files
+	paths, err := provider.List(ctx, "metering/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"metering-data/" + meta, "metering-data/" + path}, paths)
+	paths, err = provider.List(ctx, "metering/ru/17558496")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"metering-data/" + path}, paths)
+	paths, err = provider.List(ctx, "missing/")
+	require.NoError(t, err)
+	assert.Empty(t, paths)
+
+	moved := "metering/ru/1755849660/tidb/pool1/server1-0.json.gz"
+	require.NoError(t, provider.Move(ctx, path, moved))
+	assert.Equal(t, "second", readSFTP(t, provider, moved))
+	assert.ErrorIs(t, provider.Move(ctx, path, moved), ErrNotFound)
+
+	require.NoError(t, provider.Delete(ctx, moved))
+	require.NoError(t, provider.Delete(ctx, moved), "deleting a missing file succeeds")
+	_, err = provider.Download(ctx, moved)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = provider.Stat(ctx, moved)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestSFTPProvider_UploadIfNotExistsRace(t *testing.T) {
+	provider, _ := newMemorySFTPProvider(t, "")
+	ctx := context.Background()
+	path := "metering/meta/logic/cluster1/1755849600.json.gz"
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = provider.UploadIfNotExists(ctx, path, strings.NewReader(fmt.Sprint(i)))
+		}(i)
+	}
+	wg.Wait()
+
+	winner := -1
+	for i, err := range errs {
+		if err == nil {
+			assert.Equal(t, -1, winner, "only one upload succeeds")
+			winner = i
+		} else {
+			assert.ErrorIs(t, err, ErrObjectExists)
+		}
+	}
+	require.NotEqual(t, -1, winner)
+	assert.Equal(t, fmt.Sprint(winner), readSFTP(t, provider, path))
+}
+
+func TestSFTPProvider_InvalidPath(t *testing.T) {
+	provider, _ := newMemorySFTPProvider(t, "metering-data")
+	ctx := context.Background()
+
+	assert.ErrorIs(t, provider.Upload(ctx, "../escape.json.gz", strings.NewReader("x")), ErrInvalidPath)
+	assert.ErrorIs(t, provider.UploadIfNotExists(ctx, "metering/../../escape", strings.NewReader("x")), ErrInvalidPath)
+	_, err := provider.Download(ctx, "../escape.json.gz")
+	assert.ErrorIs(t, err, ErrInvalidPath)
+	_, err = provider.List(ctx, "../")
+	assert.ErrorIs(t, err, ErrInvalidPath)
+}
+
+func TestSFTPProvider_Reconnect(t *testing.T) {
+	provider, server := newMemorySFTPProvider(t, "")
+	ctx := context.Background()
+
+	require.NoError(t, provider.Upload(ctx, "a", strings.NewReader("a")))
+	server.dropConnections()
+
+	// Operations racing with the connection loss fail, later ones reconnect
+	assert.Eventually(t, func() bool {
+		exists, err := provider.Exists(ctx, "a")
+		return err == nil && exists
+	}, 5*time.Second, 10*time.Millisecond)
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	assert.Equal(t, 2, server.dials)
+}
+
+func TestNewSFTPProvider(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	block, err := ssh.MarshalPrivateKey(key, "")
+	require.NoError(t, err)
+	privateKey := string(pem.EncodeToMemory(block))
+
+	tests := []struct {
+		name   string
+		config *SFTPConfig
+		errMsg string
+	}{
+		{"missing config", nil, "host is required"},
+		{"missing user", &SFTPConfig{Host: "jump-host"}, "user is required"},
+		{"missing key", &SFTPConfig{Host: "jump-host", User: "metering"}, "private key or private key file is required"},
+		{"invalid key", &SFTPConfig{Host: "jump-host", User: "metering", PrivateKey: "invalid"}, "failed to parse SFTP private key"},
+		{
+			"missing known hosts",
+			&SFTPConfig{Host: "jump-host", User: "metering", PrivateKey: privateKey, KnownHostsFile: filepath.Join(t.TempDir(), "known_hosts")},
+			"failed to load known hosts file",
+		},
+		{"valid", &SFTPConfig{Host: "jump-host:2222", User: "metering", PrivateKey: privateKey, InsecureIgnoreHostKey: true}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := NewSFTPProvider(&ProviderConfig{Type: ProviderTypeSFTP, SFTP: tt.config})
+			if tt.errMsg != "" {
+				assert.ErrorContains(t, err, tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, provider)
+		})
+	}
+}
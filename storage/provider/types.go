@@ -1,5 +1,12 @@
 package provider
 
+import (
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
 // ProviderType storage provider type
 type ProviderType string
 
@@ -14,6 +21,10 @@ const (
 	ProviderTypeOSS ProviderType = "oss"
 	// ProviderTypeLocalFS local filesystem storage provider
 	ProviderTypeLocalFS ProviderType = "localfs"
+	// ProviderTypeSFTP SFTP storage provider
+	ProviderTypeSFTP ProviderType = "sftp"
+	// ProviderTypeMemory in-memory storage provider for tests
+	ProviderTypeMemory ProviderType = "memory"
 )
 
 // ProviderConfig storage provider configuration
@@ -30,6 +41,13 @@ type ProviderConfig struct {
 	Azure   *AzureConfig   `json:"azure,omitempty"`   // Azure Blob Storage specific configuration
 	OSS     *OSSConfig     `json:"oss,omitempty"`     // Alibaba Cloud OSS specific configuration
 	LocalFS *LocalFSConfig `json:"localfs,omitempty"` // local filesystem specific configuration
+	SFTP    *SFTPConfig    `json:"sftp,omitempty"`    // SFTP specific configuration
+	Memory  *MemoryConfig  `json:"memory,omitempty"`  // in-memory provider specific configuration
+
+	// Options settings of providers registered with storage.RegisterProvider
+	Options map[string]string `json:"options,omitempty"`
+	// RateLimit client-side request and bandwidth limits of the S3, OSS and Azure providers
+	RateLimit *RateLimitConfig `json:"rate_limit,omitempty"`
 }
 
 // AWSConfig AWS S3 specific configuration
@@ -39,6 +57,33 @@ type AWSConfig struct {
 	AccessKey        string `json:"access_key,omitempty"`
 	SecretAccessKey  string `json:"secret_access_key,omitempty"`
 	SessionToken     string `json:"session_token,omitempty"`
+	// CredentialsProvider custom credentials source, e.g. an internal STS broker. Takes precedence over
+	// the static keys, and is the source credentials of AssumeRoleARN when both are set
+	CredentialsProvider aws.CredentialsProvider `json:"-"`
+	// OnRefreshError is called when refreshing AssumeRoleARN credentials fails, see S3Provider.Credentials
+	OnRefreshError RefreshErrorFunc `json:"-"`
+	// MinIO compatibility mode for MinIO and other self-hosted S3-compatible stores: path-style requests,
+	// checksums only where the API requires them, so no aws-chunked checksum trailers, region DefaultMinIORegion
+	// unless configured, and no S3 Express bucket detection
+	MinIO bool `json:"minio,omitempty"`
+	// DisableS3ExpressSessionAuth signs requests to S3 Express directory buckets with the regular
+	// credentials instead of CreateSession tokens
+	DisableS3ExpressSessionAuth bool `json:"disable_s3_express_session_auth,omitempty"`
+	// ServerSideEncryption encryption of uploaded objects: "AES256" (SSE-S3), "aws:kms" (SSE-KMS) or
+	// "aws:kms:dsse", empty uses the bucket default. Setting SSEKMSKeyID implies "aws:kms"
+	ServerSideEncryption string `json:"server_side_encryption,omitempty"`
+	// SSEKMSKeyID ID or ARN of the KMS key used by SSE-KMS, empty uses the AWS managed key
+	SSEKMSKeyID string `json:"sse_kms_key_id,omitempty"`
+	// SSEBucketKeyEnabled uses an S3 Bucket Key with SSE-KMS, reducing the requests made to KMS
+	SSEBucketKeyEnabled bool `json:"sse_bucket_key_enabled,omitempty"`
+	// UseAccelerate sends requests to the S3 Transfer Acceleration endpoint, which routes them through the
+	// nearest edge location. The bucket must have acceleration enabled and a name without dots
+	UseAccelerate bool `json:"use_accelerate,omitempty"`
+	// UseDualStack sends requests to the dual-stack endpoints, reachable over IPv6 as well as IPv4
+	UseDualStack bool `json:"use_dual_stack,omitempty"`
+	// AccessPointARN ARN of an S3 access point to send requests to instead of the bucket, e.g.
+	// arn:aws:s3:us-west-2:123456789012:accesspoint/metering. Requests go to the region of the ARN
+	AccessPointARN string `json:"access_point_arn,omitempty"`
 	// Custom AWS Config object for aws-sdk-go-v2
 	CustomConfig interface{} `json:"-"` // not serialized, used to pass aws.Config
 }
@@ -62,6 +107,13 @@ type OSSConfig struct {
 	AccessKey       string `json:"access_key,omitempty"`
 	SecretAccessKey string `json:"secret_access_key,omitempty"`
 	SessionToken    string `json:"session_token,omitempty"`
+	// ServerSideEncryption encryption of uploaded objects: "AES256", "KMS" or "SM4", empty uses the
+	// bucket default. Setting SSEKMSKeyID implies "KMS"
+	ServerSideEncryption string `json:"server_side_encryption,omitempty"`
+	// SSEKMSKeyID ID of the KMS customer master key used by KMS encryption, empty uses the default key
+	SSEKMSKeyID string `json:"sse_kms_key_id,omitempty"`
+	// OnRefreshError is called when refreshing AssumeRoleARN credentials fails, see OSSProvider.Credentials
+	OnRefreshError RefreshErrorFunc `json:"-"`
 	// Custom OSS Config object for oss-sdk-go-v2
 	CustomConfig interface{} `json:"-"` // not serialized, used to pass oss config
 }
@@ -71,4 +123,67 @@ type LocalFSConfig struct {
 	BasePath    string `json:"base_path"`             // base path for local filesystem
 	CreateDirs  bool   `json:"create_dirs,omitempty"` // whether to automatically create directories, default true
 	Permissions string `json:"permissions,omitempty"` // file permissions, e.g. "0755"
+	// Fsync whether to fsync files and their directory before an upload returns, so written files survive
+	// a power loss, default false. Uploads are atomic regardless
+	Fsync bool `json:"fsync,omitempty"`
+	// Lock whether uploads and moves take an advisory flock on the target, so processes of one host writing
+	// the same base path are serialized per file and uploads that must not overwrite fail before writing,
+	// default false. Lock files are named .{name}.lock, kept next to the target and not listed
+	Lock bool `json:"lock,omitempty"`
+	// MaxBytes caps the bytes stored under the base path, default 0 means unlimited. Usage is computed when
+	// the provider is created and tracked by its uploads and deletes, so files written by other processes
+	// are only seen by providers created afterwards
+	MaxBytes int64 `json:"max_bytes,omitempty"`
+	// QuotaPolicy what uploads do when MaxBytes would be exceeded, default QuotaPolicyError
+	QuotaPolicy QuotaPolicy `json:"quota_policy,omitempty"`
+}
+
+// SFTPConfig SFTP specific configuration, authenticating with a private key
+type SFTPConfig struct {
+	Host           string `json:"host"`                       // host name or address, with an optional port, 22 by default
+	User           string `json:"user"`                       // login user
+	BasePath       string `json:"base_path,omitempty"`        // base directory on the host, relative to the login directory unless absolute
+	PrivateKeyFile string `json:"private_key_file,omitempty"` // path of the private key file
+	PrivateKey     string `json:"-"`                          // PEM encoded private key, takes precedence over PrivateKeyFile
+	Passphrase     string `json:"-"`                          // passphrase of an encrypted private key
+	// KnownHostsFile known_hosts file verifying the host key, ~/.ssh/known_hosts by default
+	KnownHostsFile string `json:"known_hosts_file,omitempty"`
+	// InsecureIgnoreHostKey skips host key verification, for tests only
+	InsecureIgnoreHostKey bool `json:"insecure_ignore_host_key,omitempty"`
+}
+
+// MemoryConfig in-memory provider specific configuration
+type MemoryConfig struct {
+	ErrorRate float64       `json:"error_rate,omitempty"` // fraction of operations failing with ErrInjectedFailure
+	Latency   time.Duration `json:"latency,omitempty"`    // delay added to every operation
+}
+
+// ErrObjectExists is returned by conditional uploads when the target object already exists
+var ErrObjectExists = errors.New("object already exists")
+
+// ObjectAttributes describes a stored object
+type ObjectAttributes struct {
+	Path         string            `json:"path"`               // object path, as passed to Stat
+	Size         int64             `json:"size"`               // size in bytes as stored, i.e. compressed
+	LastModified time.Time         `json:"last_modified"`      // time the object was written, zero if unknown
+	ETag         string            `json:"etag,omitempty"`     // entity tag, changes whenever the content changes
+	Metadata     map[string]string `json:"metadata,omitempty"` // user metadata, e.g. x-amz-meta-* on S3
+}
+
+// ObjectVersion describes one version of an object in a versioned bucket
+type ObjectVersion struct {
+	Path           string    `json:"path"`                       // object path, without the provider prefix
+	VersionID      string    `json:"version_id"`                 // provider assigned version ID
+	LastModified   time.Time `json:"last_modified"`              // time the version was created
+	Size           int64     `json:"size"`                       // size in bytes, 0 for delete markers
+	IsLatest       bool      `json:"is_latest"`                  // whether this is the current version
+	IsDeleteMarker bool      `json:"is_delete_marker,omitempty"` // whether the object was deleted at this version
+}
+
+// pageLimit returns the number of keys to request for a page of at most limit keys, capped at maxKeys
+func pageLimit(limit, maxKeys int) int32 {
+	if limit <= 0 || limit > maxKeys {
+		limit = maxKeys
+	}
+	return int32(limit) // #nosec G115 - bounded by maxKeys
 }
@@ -0,0 +1,50 @@
+package provider
+
+import (
+	"context"
+	"net/url"
+)
+
+// UploadOptions HTTP metadata set on uploaded objects, so CDN-fronted reads and browser downloads
+// behave correctly. Providers without HTTP metadata, e.g. LocalFS, ignore them.
+// StorageClass and Tags are only supported by S3 and OSS, they let lifecycle policies act on uploads.
+type UploadOptions struct {
+	ContentType     string            `json:"content_type,omitempty"`     // e.g. application/gzip
+	ContentEncoding string            `json:"content_encoding,omitempty"` // e.g. gzip, so HTTP clients decompress transparently
+	CacheControl    string            `json:"cache_control,omitempty"`    // e.g. max-age=3600
+	StorageClass    string            `json:"storage_class,omitempty"`    // e.g. STANDARD_IA or INTELLIGENT_TIERING on S3, IA on OSS
+	Tags            map[string]string `json:"tags,omitempty"`             // object tags, e.g. cluster, category, tenant
+}
+
+// tagging returns Tags URL-encoded as expected by S3 and OSS, or nil if there are none
+func (o *UploadOptions) tagging() *string {
+	if len(o.Tags) == 0 {
+		return nil
+	}
+	values := make(url.Values, len(o.Tags))
+	for key, value := range o.Tags {
+		values.Set(key, value)
+	}
+	return optionalString(values.Encode())
+}
+
+type uploadOptionsKey struct{}
+
+// WithUploadOptions returns a context carrying opts for uploads made with it
+func WithUploadOptions(ctx context.Context, opts *UploadOptions) context.Context {
+	return context.WithValue(ctx, uploadOptionsKey{}, opts)
+}
+
+// UploadOptionsFromContext returns the upload options carried by ctx, or nil
+func UploadOptionsFromContext(ctx context.Context) *UploadOptions {
+	opts, _ := ctx.Value(uploadOptionsKey{}).(*UploadOptions)
+	return opts
+}
+
+// optionalString returns nil for empty strings, for optional SDK request fields
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,44 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3ProviderStorageClassAndTags(t *testing.T) {
+	headers := make(chan http.Header, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+	}))
+	defer server.Close()
+
+	provider, err := NewS3Provider(&ProviderConfig{
+		Type:     ProviderTypeS3,
+		Region:   "us-east-1",
+		Bucket:   "bucket",
+		Endpoint: server.URL,
+		AWS:      &AWSConfig{S3ForcePathStyle: true, AccessKey: "access", SecretAccessKey: "secret"},
+	})
+	require.NoError(t, err)
+
+	ctx := WithUploadOptions(context.Background(), &UploadOptions{
+		StorageClass: "STANDARD_IA",
+		Tags:         map[string]string{"cluster": "c1", "tenant": "acme corp"},
+	})
+	require.NoError(t, provider.Upload(ctx, "file.json.gz", strings.NewReader("data")))
+	header := <-headers
+	assert.Equal(t, "STANDARD_IA", header.Get("X-Amz-Storage-Class"))
+	assert.Equal(t, "cluster=c1&tenant=acme+corp", header.Get("X-Amz-Tagging"))
+
+	// No tags by default
+	require.NoError(t, provider.Upload(context.Background(), "file.json.gz", strings.NewReader("data")))
+	header = <-headers
+	assert.Empty(t, header.Get("X-Amz-Storage-Class"))
+	assert.Empty(t, header.Get("X-Amz-Tagging"))
+}
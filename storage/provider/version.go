@@ -0,0 +1,13 @@
+package provider
+
+import "sort"
+
+// sortVersions sorts versions newest first, keeping the latest version first on equal timestamps
+func sortVersions(versions []ObjectVersion) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		if versions[i].LastModified.Equal(versions[j].LastModified) {
+			return versions[i].IsLatest && !versions[j].IsLatest
+		}
+		return versions[i].LastModified.After(versions[j].LastModified)
+	})
+}
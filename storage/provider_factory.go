@@ -6,7 +6,8 @@ import (
 	"github.com/pingcap/metering_sdk/storage/provider"
 )
 
-// NewObjectStorageProvider creates object storage provider based on configuration
+// NewObjectStorageProvider creates object storage provider based on configuration,
+// including providers added with RegisterProvider
 func NewObjectStorageProvider(config *ProviderConfig) (ObjectStorageProvider, error) {
 	// Directly use provider.ProviderConfig since they are now the same type
 	switch config.Type {
@@ -16,11 +17,18 @@ func NewObjectStorageProvider(config *ProviderConfig) (ObjectStorageProvider, er
 		return provider.NewOSSProvider(config)
 	case provider.ProviderTypeLocalFS:
 		return provider.NewLocalFSProvider(config)
+	case provider.ProviderTypeSFTP:
+		return provider.NewSFTPProvider(config)
+	case provider.ProviderTypeMemory:
+		return provider.NewMemoryProvider(config)
 	case provider.ProviderTypeGCS:
 		return nil, fmt.Errorf("provider GCS  not implemented yet")
 	case provider.ProviderTypeAzure:
 		return provider.NewAzureProvider(config)
 	default:
+		if factory, ok := registeredProvider(config.Type); ok {
+			return factory(config)
+		}
 		return nil, fmt.Errorf("unsupported provider type: %s", config.Type)
 	}
 }
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pingcap/metering_sdk/storage/provider"
+)
+
+// ProviderFactory creates a provider from its configuration. Custom providers read their settings
+// from the common fields and ProviderConfig.Options
+type ProviderFactory func(config *ProviderConfig) (ObjectStorageProvider, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[ProviderType]ProviderFactory)
+)
+
+// builtinProviderTypes provider types implemented by this package, which can't be registered
+var builtinProviderTypes = map[ProviderType]bool{
+	provider.ProviderTypeS3:      true,
+	provider.ProviderTypeGCS:     true,
+	provider.ProviderTypeAzure:   true,
+	provider.ProviderTypeOSS:     true,
+	provider.ProviderTypeLocalFS: true,
+	provider.ProviderTypeSFTP:    true,
+	provider.ProviderTypeMemory:  true,
+}
+
+// RegisterProvider makes an out-of-tree provider available to NewObjectStorageProvider and URI
+// configuration, where providerType is also the URI scheme. It is meant to be called from init
+// functions and panics if providerType is empty, built in or already registered, or factory is nil.
+func RegisterProvider(providerType ProviderType, factory ProviderFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if providerType == "" || factory == nil {
+		panic("storage: RegisterProvider requires a provider type and a factory")
+	}
+	if builtinProviderTypes[providerType] {
+		panic(fmt.Sprintf("storage: provider type %s is built in", providerType))
+	}
+	if _, exists := registry[providerType]; exists {
+		panic(fmt.Sprintf("storage: provider type %s registered twice", providerType))
+	}
+	registry[providerType] = factory
+}
+
+// IsRegisteredProvider reports whether providerType was registered with RegisterProvider
+func IsRegisteredProvider(providerType ProviderType) bool {
+	_, ok := registeredProvider(providerType)
+	return ok
+}
+
+// registeredProvider returns the factory registered for providerType
+func registeredProvider(providerType ProviderType) (ProviderFactory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	factory, ok := registry[providerType]
+	return factory, ok
+}
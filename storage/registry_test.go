@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterProvider(t *testing.T) {
+	const providerType ProviderType = "registrytest"
+	RegisterProvider(providerType, func(config *ProviderConfig) (ObjectStorageProvider, error) {
+		return NewObjectStorageProvider(&ProviderConfig{
+			Type:    ProviderTypeLocalFS,
+			LocalFS: &LocalFSConfig{BasePath: config.Options["base-path"], CreateDirs: true},
+		})
+	})
+	assert.True(t, IsRegisteredProvider(providerType))
+	assert.False(t, IsRegisteredProvider("unknown"))
+
+	provider, err := NewObjectStorageProvider(&ProviderConfig{
+		Type:    providerType,
+		Options: map[string]string{"base-path": t.TempDir()},
+	})
+	require.NoError(t, err)
+	require.NoError(t, provider.Upload(context.Background(), "a.txt", strings.NewReader("hello")))
+
+	noop := func(*ProviderConfig) (ObjectStorageProvider, error) { return nil, nil }
+	assert.Panics(t, func() { RegisterProvider(providerType, noop) }, "duplicate registration")
+	assert.Panics(t, func() { RegisterProvider(ProviderTypeS3, noop) }, "built-in type")
+	assert.Panics(t, func() { RegisterProvider("other", nil) }, "nil factory")
+
+	_, err = NewObjectStorageProvider(&ProviderConfig{Type: "unknown"})
+	assert.Error(t, err)
+}
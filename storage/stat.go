@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// Stat returns the attributes of the object at path, failing with ErrNotFound if it doesn't exist.
+// It uses provider's ObjectStater support if available and otherwise downloads the object to measure
+// its size; the other attributes are then unknown.
+func Stat(ctx context.Context, provider ObjectStorageProvider, path string) (*ObjectAttributes, error) {
+	if stater, ok := provider.(ObjectStater); ok {
+		return stater.Stat(ctx, path)
+	}
+
+	exists, err := provider.Exists(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, path)
+	}
+	body, err := provider.Download(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	size, err := io.Copy(io.Discard, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return &ObjectAttributes{Path: path, Size: size}, nil
+}
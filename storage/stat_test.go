@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStat(t *testing.T) {
+	ctx := context.Background()
+	provider := NewMemoryProvider()
+	require.NoError(t, provider.Upload(ctx, "a", strings.NewReader("data")))
+
+	attrs, err := Stat(ctx, provider, "a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), attrs.Size)
+	assert.NotEmpty(t, attrs.ETag)
+
+	// The ETag changes with the content
+	require.NoError(t, provider.Upload(ctx, "a", strings.NewReader("other")))
+	changed, err := Stat(ctx, provider, "a")
+	require.NoError(t, err)
+	assert.NotEqual(t, attrs.ETag, changed.ETag)
+
+	// Without ObjectStater support only the size is known
+	attrs, err = Stat(ctx, basicProvider{provider}, "a")
+	require.NoError(t, err)
+	assert.Equal(t, &ObjectAttributes{Path: "a", Size: 5}, attrs)
+
+	_, err = Stat(ctx, basicProvider{provider}, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
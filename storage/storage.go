@@ -21,6 +21,49 @@ type ObjectStorageProvider interface {
 	List(ctx context.Context, prefix string) ([]string, error)
 }
 
+// ConditionalUploader is implemented by providers that can enforce "create only" uploads
+// server-side, so writers can skip the Exists round-trip before each upload
+type ConditionalUploader interface {
+	// UploadIfNotExists uploads data to specified path, failing with ErrObjectExists if it already exists
+	UploadIfNotExists(ctx context.Context, path string, data io.Reader) error
+}
+
+// ObjectStater is implemented by providers that can read the attributes of an object without downloading it
+type ObjectStater interface {
+	// Stat returns the attributes of the object at path, failing with ErrNotFound if it doesn't exist
+	Stat(ctx context.Context, path string) (*ObjectAttributes, error)
+}
+
+// Describer is implemented by providers that can describe their storage, the built-in ones do
+type Describer interface {
+	// Describe returns the type and bucket of the provider
+	Describe() Description
+}
+
+// ErrObjectExists is returned by conditional uploads when the target object already exists
+var ErrObjectExists = provider.ErrObjectExists
+
+// Error classes of storage operations, matched with errors.Is. The S3, OSS and Azure providers wrap
+// service errors with the class matching their HTTP status or error code.
+var (
+	// ErrNotFound the object or bucket does not exist
+	ErrNotFound = provider.ErrNotFound
+	// ErrPermissionDenied the credentials are missing, invalid or not allowed to perform the operation
+	ErrPermissionDenied = provider.ErrPermissionDenied
+	// ErrThrottled the service rejected the request because of its request rate
+	ErrThrottled = provider.ErrThrottled
+)
+
+// WithUploadOptions returns a context carrying opts, providers set them as HTTP metadata on uploads made with it
+func WithUploadOptions(ctx context.Context, opts *UploadOptions) context.Context {
+	return provider.WithUploadOptions(ctx, opts)
+}
+
+// UploadOptionsFromContext returns the upload options carried by ctx, or nil
+func UploadOptionsFromContext(ctx context.Context) *UploadOptions {
+	return provider.UploadOptionsFromContext(ctx)
+}
+
 // Re-export types from provider package for external use
 type (
 	ProviderType   = provider.ProviderType
@@ -30,6 +73,19 @@ type (
 	AzureConfig    = provider.AzureConfig
 	OSSConfig      = provider.OSSConfig
 	LocalFSConfig  = provider.LocalFSConfig
+	SFTPConfig     = provider.SFTPConfig
+	UploadOptions  = provider.UploadOptions
+	MemoryConfig   = provider.MemoryConfig
+	MemoryProvider = provider.MemoryProvider
+
+	ObjectAttributes = provider.ObjectAttributes
+	Description      = provider.Description
+
+	CredentialState  = provider.CredentialState
+	RefreshErrorFunc = provider.RefreshErrorFunc
+
+	RateLimitConfig = provider.RateLimitConfig
+	HTTPDoer        = provider.HTTPDoer
 )
 
 // Re-export constants
@@ -39,4 +95,36 @@ const (
 	ProviderTypeAzure   = provider.ProviderTypeAzure
 	ProviderTypeOSS     = provider.ProviderTypeOSS
 	ProviderTypeLocalFS = provider.ProviderTypeLocalFS
+	ProviderTypeSFTP    = provider.ProviderTypeSFTP
+	ProviderTypeMemory  = provider.ProviderTypeMemory
 )
+
+// LocalFS quota policies, see LocalFSConfig.QuotaPolicy
+type QuotaPolicy = provider.QuotaPolicy
+
+const (
+	QuotaPolicyError       = provider.QuotaPolicyError
+	QuotaPolicyEvictOldest = provider.QuotaPolicyEvictOldest
+	QuotaPolicyBlock       = provider.QuotaPolicyBlock
+)
+
+// ErrQuotaExceeded is returned by LocalFS uploads that would exceed LocalFSConfig.MaxBytes
+var ErrQuotaExceeded = provider.ErrQuotaExceeded
+
+// ErrInvalidPath is returned by LocalFS operations on keys that would resolve outside of the base path
+var ErrInvalidPath = provider.ErrInvalidPath
+
+// NewRateLimitedDoer wraps an HTTP client so that requests respect limits, for providers registered
+// with RegisterProvider. The built-in cloud providers apply ProviderConfig.RateLimit themselves.
+func NewRateLimitedDoer(base HTTPDoer, limits *RateLimitConfig) HTTPDoer {
+	return provider.NewRateLimitedDoer(base, limits)
+}
+
+// ErrInjectedFailure is returned by operations failed on purpose by failure injection
+var ErrInjectedFailure = provider.ErrInjectedFailure
+
+// NewMemoryProvider creates an empty in-memory provider with failure injection disabled, for tests
+func NewMemoryProvider() *MemoryProvider {
+	m, _ := provider.NewMemoryProvider(&ProviderConfig{Type: ProviderTypeMemory})
+	return m
+}
@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// minThrottleBurst lower bound of the limiter burst, so small limits still allow reasonably sized reads
+const minThrottleBurst = 32 * 1024
+
+// ThrottleRule limits bandwidth during a daily time window
+type ThrottleRule struct {
+	// StartHour first hour (0-23) of the window
+	StartHour int
+	// EndHour hour (0-24) the window ends, exclusive. A window with EndHour <= StartHour wraps past midnight
+	EndHour int
+	// Weekdays days the rule applies to, empty means every day
+	Weekdays []time.Weekday
+	// BytesPerSecond limit during the window, 0 means unlimited
+	BytesPerSecond int64
+}
+
+// matches reports whether t falls in the rule's window
+func (r *ThrottleRule) matches(t time.Time) bool {
+	if len(r.Weekdays) > 0 && !slices.Contains(r.Weekdays, t.Weekday()) {
+		return false
+	}
+	hour := t.Hour()
+	if r.StartHour < r.EndHour {
+		return hour >= r.StartHour && hour < r.EndHour
+	}
+	return hour >= r.StartHour || hour < r.EndHour
+}
+
+// Throttle limits transfer bandwidth in bytes per second. A single Throttle can be shared by
+// any number of providers, which then share the bandwidth budget.
+type Throttle struct {
+	mu           sync.Mutex
+	limiter      *rate.Limiter
+	defaultLimit int64
+	rules        []ThrottleRule
+	location     *time.Location
+	current      int64
+	now          func() time.Time
+}
+
+// NewThrottle creates a throttle with a fixed limit, 0 means unlimited
+func NewThrottle(bytesPerSecond int64) *Throttle {
+	return NewScheduledThrottle(bytesPerSecond, time.Local)
+}
+
+// NewScheduledThrottle creates a throttle whose limit follows a daily schedule in loc.
+// The first matching rule wins; defaultBytesPerSecond applies outside all rules, 0 means unlimited.
+func NewScheduledThrottle(defaultBytesPerSecond int64, loc *time.Location, rules ...ThrottleRule) *Throttle {
+	if loc == nil {
+		loc = time.Local
+	}
+	t := &Throttle{
+		limiter:      rate.NewLimiter(rate.Inf, minThrottleBurst),
+		defaultLimit: defaultBytesPerSecond,
+		rules:        rules,
+		location:     loc,
+		current:      -1,
+		now:          time.Now,
+	}
+	t.refresh()
+	return t
+}
+
+// Limit returns the limit in bytes per second in effect at t, 0 means unlimited
+func (t *Throttle) Limit(at time.Time) int64 {
+	at = at.In(t.location)
+	for i := range t.rules {
+		if t.rules[i].matches(at) {
+			return t.rules[i].BytesPerSecond
+		}
+	}
+	return t.defaultLimit
+}
+
+// refresh applies the limit currently in effect and returns the limiter burst
+func (t *Throttle) refresh() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	limit := t.Limit(t.now())
+	if limit != t.current {
+		t.current = limit
+		if limit <= 0 {
+			t.limiter.SetLimit(rate.Inf)
+			t.limiter.SetBurst(minThrottleBurst)
+		} else {
+			t.limiter.SetLimit(rate.Limit(limit))
+			t.limiter.SetBurst(int(max(limit, minThrottleBurst)))
+		}
+	}
+	return t.limiter.Burst()
+}
+
+// WaitN blocks until n bytes may be transferred or ctx is done
+func (t *Throttle) WaitN(ctx context.Context, n int) error {
+	for n > 0 {
+		burst := t.refresh()
+		chunk := min(n, burst)
+		if err := t.limiter.WaitN(ctx, chunk); err != nil {
+			return err
+		}
+		n -= chunk
+	}
+	return nil
+}
+
+// Reader wraps r so that reads are throttled
+func (t *Throttle) Reader(ctx context.Context, r io.Reader) io.Reader {
+	return &throttledReader{ctx: ctx, r: r, throttle: t}
+}
+
+// throttledReader waits for the throttle before returning data
+type throttledReader struct {
+	ctx      context.Context
+	r        io.Reader
+	throttle *Throttle
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if burst := r.throttle.refresh(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if waitErr := r.throttle.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// throttledReadCloser is a throttled io.ReadCloser
+type throttledReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// throttledProvider throttles uploaded and downloaded bytes
+type throttledProvider struct {
+	ObjectStorageProvider
+	throttle *Throttle
+}
+
+// conditionalThrottledProvider additionally forwards conditional uploads
+type conditionalThrottledProvider struct {
+	*throttledProvider
+	conditional ConditionalUploader
+}
+
+// NewThrottledProvider wraps provider so that upload and download bodies are limited by throttle.
+// If throttle is nil, provider is returned unchanged. Conditional upload support is preserved.
+func NewThrottledProvider(provider ObjectStorageProvider, throttle *Throttle) ObjectStorageProvider {
+	if throttle == nil || provider == nil {
+		return provider
+	}
+	p := &throttledProvider{ObjectStorageProvider: provider, throttle: throttle}
+	if conditional, ok := provider.(ConditionalUploader); ok {
+		return &conditionalThrottledProvider{throttledProvider: p, conditional: conditional}
+	}
+	return p
+}
+
+// Upload implements ObjectStorageProvider interface
+func (p *throttledProvider) Upload(ctx context.Context, path string, data io.Reader) error {
+	return p.ObjectStorageProvider.Upload(ctx, path, p.throttle.Reader(ctx, data))
+}
+
+// Download implements ObjectStorageProvider interface
+func (p *throttledProvider) Download(ctx context.Context, path string) (io.ReadCloser, error) {
+	rc, err := p.ObjectStorageProvider.Download(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return &throttledReadCloser{Reader: p.throttle.Reader(ctx, rc), Closer: rc}, nil
+}
+
+// UploadIfNotExists implements ConditionalUploader interface
+func (p *conditionalThrottledProvider) UploadIfNotExists(ctx context.Context, path string, data io.Reader) error {
+	return p.conditional.UploadIfNotExists(ctx, path, p.throttle.Reader(ctx, data))
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottleLimitSchedule(t *testing.T) {
+	throttle := NewScheduledThrottle(0, time.UTC,
+		ThrottleRule{StartHour: 9, EndHour: 18, Weekdays: []time.Weekday{time.Monday, time.Tuesday}, BytesPerSecond: 1000},
+		ThrottleRule{StartHour: 22, EndHour: 6, BytesPerSecond: 5000},
+	)
+
+	monday := time.Date(2025, 8, 18, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, int64(1000), throttle.Limit(monday.Add(10*time.Hour)))
+	assert.Equal(t, int64(0), throttle.Limit(monday.Add(18*time.Hour)), "window end is exclusive")
+	assert.Equal(t, int64(0), throttle.Limit(monday.Add(24*time.Hour*3+10*time.Hour)), "thursday not listed")
+	assert.Equal(t, int64(5000), throttle.Limit(monday.Add(23*time.Hour)), "window wraps past midnight")
+	assert.Equal(t, int64(5000), throttle.Limit(monday.Add(2*time.Hour)))
+}
+
+func TestThrottleReader(t *testing.T) {
+	throttle := NewThrottle(64 * 1024)
+	data := bytes.Repeat([]byte("x"), 96*1024)
+
+	// The first burst is free, the remaining 32KiB take about half a second
+	start := time.Now()
+	out, err := io.ReadAll(throttle.Reader(context.Background(), bytes.NewReader(data)))
+	require.NoError(t, err)
+	assert.Equal(t, data, out)
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = io.ReadAll(throttle.Reader(ctx, bytes.NewReader(data)))
+	assert.Error(t, err, "cancelled context should abort a throttled read")
+}
+
+func TestNewThrottledProvider(t *testing.T) {
+	provider, err := NewObjectStorageProvider(&ProviderConfig{
+		Type:    ProviderTypeLocalFS,
+		LocalFS: &LocalFSConfig{BasePath: t.TempDir(), CreateDirs: true},
+	})
+	require.NoError(t, err)
+	assert.Same(t, provider, NewThrottledProvider(provider, nil))
+
+	throttled := NewThrottledProvider(provider, NewThrottle(0))
+	_, ok := throttled.(ConditionalUploader)
+	assert.True(t, ok, "conditional upload support should be preserved")
+
+	ctx := context.Background()
+	require.NoError(t, throttled.Upload(ctx, "a.txt", strings.NewReader("hello")))
+	rc, err := throttled.Download(ctx, "a.txt")
+	require.NoError(t, err)
+	defer rc.Close()
+	content, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(content))
+}
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pingcap/metering_sdk/layout"
+)
+
+// DefaultHotWindow age of data kept in the hot tier unless configured otherwise
+const DefaultHotWindow = time.Hour
+
+// TierPolicy decides which objects a TieredProvider keeps in its hot tier
+type TierPolicy struct {
+	// HotWindow data newer than this is written to and read from the hot tier, default DefaultHotWindow
+	HotWindow time.Duration
+	// Timestamp returns the data timestamp encoded in path, false keeps the object in the cold tier only.
+	// Default parses metering paths with layout.Default()
+	Timestamp func(path string) (int64, bool)
+
+	now func() time.Time
+}
+
+// isHot reports whether path belongs in the hot tier
+func (p *TierPolicy) isHot(path string) bool {
+	timestamp, ok := p.Timestamp(path)
+	if !ok {
+		return false
+	}
+	return p.now().Sub(time.Unix(timestamp, 0)) < p.HotWindow
+}
+
+// tieredProvider keeps recent data in a low-latency hot tier, e.g. an S3 Express One Zone directory
+// bucket, and every object in a durable cold tier
+type tieredProvider struct {
+	hot    ObjectStorageProvider
+	cold   ObjectStorageProvider
+	policy TierPolicy
+}
+
+// conditionalTieredProvider additionally supports conditional uploads through the cold tier
+type conditionalTieredProvider struct {
+	*tieredProvider
+	conditional ConditionalUploader
+}
+
+// NewTieredProvider combines a low-latency hot tier with a durable cold tier. Every object is written
+// to the cold tier, recent ones according to policy are mirrored to the hot tier and read from it first.
+// Failed hot tier uploads are ignored since reads fall back to the cold tier, which is also the one listed.
+// Expiring old data from the hot tier is left to its lifecycle rules. Conditional upload support of
+// the cold tier is preserved.
+func NewTieredProvider(hot, cold ObjectStorageProvider, policy *TierPolicy) ObjectStorageProvider {
+	p := &tieredProvider{hot: hot, cold: cold}
+	if policy != nil {
+		p.policy = *policy
+	}
+	if p.policy.HotWindow <= 0 {
+		p.policy.HotWindow = DefaultHotWindow
+	}
+	if p.policy.Timestamp == nil {
+		p.policy.Timestamp = meteringTimestamp
+	}
+	if p.policy.now == nil {
+		p.policy.now = time.Now
+	}
+	if conditional, ok := cold.(ConditionalUploader); ok {
+		return &conditionalTieredProvider{tieredProvider: p, conditional: conditional}
+	}
+	return p
+}
+
+// meteringTimestamp extracts the timestamp of a metering file path in the default layout
+func meteringTimestamp(path string) (int64, bool) {
+	fields, err := layout.Default().Parse(path)
+	if err != nil {
+		return 0, false
+	}
+	return fields.Timestamp, true
+}
+
+// Upload implements ObjectStorageProvider interface
+func (p *tieredProvider) Upload(ctx context.Context, path string, data io.Reader) error {
+	return p.upload(ctx, path, data, p.cold.Upload)
+}
+
+// UploadIfNotExists implements ConditionalUploader interface
+func (p *conditionalTieredProvider) UploadIfNotExists(ctx context.Context, path string, data io.Reader) error {
+	return p.upload(ctx, path, data, p.conditional.UploadIfNotExists)
+}
+
+// upload writes data to the cold tier with coldUpload, then mirrors hot objects to the hot tier
+func (p *tieredProvider) upload(ctx context.Context, path string, data io.Reader, coldUpload func(context.Context, string, io.Reader) error) error {
+	if !p.policy.isHot(path) {
+		return coldUpload(ctx, path, data)
+	}
+
+	body, err := io.ReadAll(data)
+	if err != nil {
+		return fmt.Errorf("failed to read upload data: %w", err)
+	}
+	if err := coldUpload(ctx, path, bytes.NewReader(body)); err != nil {
+		return err
+	}
+	_ = p.hot.Upload(ctx, path, bytes.NewReader(body))
+	return nil
+}
+
+// Download implements ObjectStorageProvider interface
+func (p *tieredProvider) Download(ctx context.Context, path string) (io.ReadCloser, error) {
+	if p.policy.isHot(path) {
+		if rc, err := p.hot.Download(ctx, path); err == nil {
+			return rc, nil
+		}
+	}
+	return p.cold.Download(ctx, path)
+}
+
+// Delete implements ObjectStorageProvider interface
+func (p *tieredProvider) Delete(ctx context.Context, path string) error {
+	if err := p.cold.Delete(ctx, path); err != nil {
+		return err
+	}
+	if exists, err := p.hot.Exists(ctx, path); err != nil || exists {
+		return p.hot.Delete(ctx, path)
+	}
+	return nil
+}
+
+// Exists implements ObjectStorageProvider interface
+func (p *tieredProvider) Exists(ctx context.Context, path string) (bool, error) {
+	if p.policy.isHot(path) {
+		if exists, err := p.hot.Exists(ctx, path); err == nil && exists {
+			return true, nil
+		}
+	}
+	return p.cold.Exists(ctx, path)
+}
+
+// List implements ObjectStorageProvider interface, listing the cold tier which holds every object
+func (p *tieredProvider) List(ctx context.Context, prefix string) ([]string, error) {
+	return p.cold.List(ctx, prefix)
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTieredProvider(t *testing.T) {
+	hot, cold := NewMemoryProvider(), NewMemoryProvider()
+	now := time.Unix(1755850380, 0)
+	tiered := NewTieredProvider(hot, cold, &TierPolicy{now: func() time.Time { return now }})
+	ctx := context.Background()
+
+	recent := "metering/ru/1755850320/tidbserver/pool1/server001-0.json.gz"
+	old := "metering/ru/1755840000/tidbserver/pool1/server001-0.json.gz"
+	meta := "metering/meta/logic/cluster001/1755850320.json.gz"
+
+	for _, path := range []string{recent, old, meta} {
+		require.NoError(t, tiered.Upload(ctx, path, strings.NewReader(path)))
+		exists, err := cold.Exists(ctx, path)
+		require.NoError(t, err)
+		assert.True(t, exists, "every object is written to the cold tier: %s", path)
+	}
+	for path, want := range map[string]bool{recent: true, old: false, meta: false} {
+		exists, err := hot.Exists(ctx, path)
+		require.NoError(t, err)
+		assert.Equal(t, want, exists, path)
+	}
+
+	// Recent objects are read from the hot tier first
+	require.NoError(t, hot.Upload(ctx, recent, strings.NewReader("hot copy")))
+	rc, err := tiered.Download(ctx, recent)
+	require.NoError(t, err)
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	assert.Equal(t, "hot copy", string(data))
+
+	// and fall back to the cold tier when missing
+	require.NoError(t, hot.Delete(ctx, recent))
+	rc, err = tiered.Download(ctx, recent)
+	require.NoError(t, err)
+	data, err = io.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	assert.Equal(t, recent, string(data))
+
+	conditional, ok := tiered.(ConditionalUploader)
+	require.True(t, ok, "conditional upload support of the cold tier should be preserved")
+	assert.ErrorIs(t, conditional.UploadIfNotExists(ctx, old, strings.NewReader("again")), ErrObjectExists)
+
+	require.NoError(t, tiered.Delete(ctx, old))
+	exists, err := tiered.Exists(ctx, old)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
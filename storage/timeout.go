@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Timeouts bounds storage operations, so a hung connection can't stall callers indefinitely even if
+// their context has no deadline. Zero disables a timeout.
+type Timeouts struct {
+	// Upload bounds every upload, copy and move
+	Upload time.Duration
+	// Download bounds every download, including reading the body until it is closed
+	Download time.Duration
+	// List bounds every listing and the other metadata requests: Exists, Stat and Delete.
+	// Paginated listings apply it to every page
+	List time.Duration
+}
+
+// timeoutProvider applies Timeouts to every operation of the wrapped provider
+type timeoutProvider struct {
+	ObjectStorageProvider
+	timeouts Timeouts
+}
+
+// conditionalTimeoutProvider additionally forwards conditional uploads
+type conditionalTimeoutProvider struct {
+	*timeoutProvider
+	conditional ConditionalUploader
+}
+
+// NewTimeoutProvider wraps provider so that its operations fail with context.DeadlineExceeded once they
+// run longer than timeouts allow. provider is returned unchanged if no timeout is set. Conditional
+// uploads, paginated listing, stats, copies and object versions are preserved.
+func NewTimeoutProvider(provider ObjectStorageProvider, timeouts Timeouts) ObjectStorageProvider {
+	if provider == nil || timeouts == (Timeouts{}) {
+		return provider
+	}
+	p := &timeoutProvider{ObjectStorageProvider: provider, timeouts: timeouts}
+	if conditional, ok := provider.(ConditionalUploader); ok {
+		return &conditionalTimeoutProvider{timeoutProvider: p, conditional: conditional}
+	}
+	return p
+}
+
+// withTimeout returns ctx bounded by timeout, unless timeout is 0
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Upload implements ObjectStorageProvider interface
+func (p *timeoutProvider) Upload(ctx context.Context, path string, data io.Reader) error {
+	ctx, cancel := withTimeout(ctx, p.timeouts.Upload)
+	defer cancel()
+	return p.ObjectStorageProvider.Upload(ctx, path, data)
+}
+
+// Download implements ObjectStorageProvider interface, the timeout also covers reading the body
+func (p *timeoutProvider) Download(ctx context.Context, path string) (io.ReadCloser, error) {
+	return p.download(ctx, func(ctx context.Context) (io.ReadCloser, error) {
+		return p.ObjectStorageProvider.Download(ctx, path)
+	})
+}
+
+// download calls open with a context bounded by the download timeout, which ends when the body is closed
+func (p *timeoutProvider) download(ctx context.Context, open func(ctx context.Context) (io.ReadCloser, error)) (io.ReadCloser, error) {
+	ctx, cancel := withTimeout(ctx, p.timeouts.Download)
+	body, err := open(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &cancelOnClose{ReadCloser: body, cancel: cancel}, nil
+}
+
+// cancelOnClose releases the context of a download when its body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close implements io.Closer
+func (b *cancelOnClose) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+// Delete implements ObjectStorageProvider interface
+func (p *timeoutProvider) Delete(ctx context.Context, path string) error {
+	ctx, cancel := withTimeout(ctx, p.timeouts.List)
+	defer cancel()
+	return p.ObjectStorageProvider.Delete(ctx, path)
+}
+
+// Exists implements ObjectStorageProvider interface
+func (p *timeoutProvider) Exists(ctx context.Context, path string) (bool, error) {
+	ctx, cancel := withTimeout(ctx, p.timeouts.List)
+	defer cancel()
+	return p.ObjectStorageProvider.Exists(ctx, path)
+}
+
+// List implements ObjectStorageProvider interface
+func (p *timeoutProvider) List(ctx context.Context, prefix string) ([]string, error) {
+	ctx, cancel := withTimeout(ctx, p.timeouts.List)
+	defer cancel()
+	return p.ObjectStorageProvider.List(ctx, prefix)
+}
+
+// ListPages implements PageLister interface. The timeout applies to fetching every page, the time spent
+// in fn doesn't count.
+func (p *timeoutProvider) ListPages(ctx context.Context, prefix string, fn func(page []string) error) error {
+	if p.timeouts.List <= 0 {
+		return ListPages(ctx, p.ObjectStorageProvider, prefix, fn)
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	timedOut := fmt.Errorf("listing a page of %s: %w", prefix, context.DeadlineExceeded)
+	timer := time.AfterFunc(p.timeouts.List, func() { cancel(timedOut) })
+	defer timer.Stop()
+
+	err := ListPages(ctx, p.ObjectStorageProvider, prefix, func(page []string) error {
+		if !timer.Stop() {
+			return context.Cause(ctx)
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		timer.Reset(p.timeouts.List)
+		return nil
+	})
+	if err != nil && errors.Is(context.Cause(ctx), timedOut) {
+		return timedOut
+	}
+	return err
+}
+
+// ListPage implements PageTokenLister interface
+func (p *timeoutProvider) ListPage(ctx context.Context, prefix, token string, limit int) ([]string, string, error) {
+	ctx, cancel := withTimeout(ctx, p.timeouts.List)
+	defer cancel()
+	return ListPage(ctx, p.ObjectStorageProvider, prefix, token, limit)
+}
+
+// ListCommonPrefixes implements PrefixLister interface
+func (p *timeoutProvider) ListCommonPrefixes(ctx context.Context, prefix, delimiter string) ([]string, error) {
+	ctx, cancel := withTimeout(ctx, p.timeouts.List)
+	defer cancel()
+	return ListCommonPrefixes(ctx, p.ObjectStorageProvider, prefix, delimiter)
+}
+
+// ListAttributes implements AttributeLister interface
+func (p *timeoutProvider) ListAttributes(ctx context.Context, prefix string) ([]ObjectAttributes, error) {
+	ctx, cancel := withTimeout(ctx, p.timeouts.List)
+	defer cancel()
+	return ListAttributes(ctx, p.ObjectStorageProvider, prefix)
+}
+
+// Stat implements ObjectStater interface
+func (p *timeoutProvider) Stat(ctx context.Context, path string) (*ObjectAttributes, error) {
+	ctx, cancel := withTimeout(ctx, p.timeouts.List)
+	defer cancel()
+	return Stat(ctx, p.ObjectStorageProvider, path)
+}
+
+// Copy implements Copier interface
+func (p *timeoutProvider) Copy(ctx context.Context, src, dst string) error {
+	ctx, cancel := withTimeout(ctx, p.timeouts.Upload)
+	defer cancel()
+	return Copy(ctx, p.ObjectStorageProvider, src, dst)
+}
+
+// Move implements Mover interface
+func (p *timeoutProvider) Move(ctx context.Context, src, dst string) error {
+	ctx, cancel := withTimeout(ctx, p.timeouts.Upload)
+	defer cancel()
+	return Move(ctx, p.ObjectStorageProvider, src, dst)
+}
+
+// DownloadVersion implements VersionedProvider interface, failing with ErrVersioningNotSupported if
+// the wrapped provider doesn't support versions
+func (p *timeoutProvider) DownloadVersion(ctx context.Context, path string, versionID string) (io.ReadCloser, error) {
+	versioned, ok := p.ObjectStorageProvider.(VersionedProvider)
+	if !ok {
+		return nil, ErrVersioningNotSupported
+	}
+	return p.download(ctx, func(ctx context.Context) (io.ReadCloser, error) {
+		return versioned.DownloadVersion(ctx, path, versionID)
+	})
+}
+
+// ListVersions implements VersionedProvider interface, failing with ErrVersioningNotSupported if
+// the wrapped provider doesn't support versions
+func (p *timeoutProvider) ListVersions(ctx context.Context, path string) ([]ObjectVersion, error) {
+	versioned, ok := p.ObjectStorageProvider.(VersionedProvider)
+	if !ok {
+		return nil, ErrVersioningNotSupported
+	}
+	ctx, cancel := withTimeout(ctx, p.timeouts.List)
+	defer cancel()
+	return versioned.ListVersions(ctx, path)
+}
+
+// UploadIfNotExists implements ConditionalUploader interface
+func (p *conditionalTimeoutProvider) UploadIfNotExists(ctx context.Context, path string, data io.Reader) error {
+	ctx, cancel := withTimeout(ctx, p.timeouts.Upload)
+	defer cancel()
+	return p.conditional.UploadIfNotExists(ctx, path, data)
+}
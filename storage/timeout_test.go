@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeoutProvider(t *testing.T) {
+	ctx := context.Background()
+	inner := NewMemoryProvider()
+	assert.Same(t, inner, NewTimeoutProvider(inner, Timeouts{}))
+
+	provider := NewTimeoutProvider(inner, Timeouts{Upload: 20 * time.Millisecond, Download: 20 * time.Millisecond, List: 20 * time.Millisecond})
+	_, ok := provider.(ConditionalUploader)
+	assert.True(t, ok)
+	for i := 0; i < DefaultListPageSize+10; i++ {
+		require.NoError(t, provider.Upload(ctx, fmt.Sprintf("data/%05d", i), strings.NewReader("x")))
+	}
+
+	// Operations within the timeouts succeed, the time spent handling pages doesn't count
+	body, err := provider.Download(ctx, "data/00000")
+	require.NoError(t, err)
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+	require.NoError(t, body.Close())
+	assert.Equal(t, "x", string(data))
+	pages := 0
+	err = ListPages(ctx, provider, "data/", func([]string) error {
+		pages++
+		time.Sleep(30 * time.Millisecond)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, pages)
+
+	// A hung provider fails even though the context has no deadline
+	inner.SetLatency(time.Second)
+	start := time.Now()
+	assert.ErrorIs(t, provider.Upload(ctx, "data/slow", strings.NewReader("x")), context.DeadlineExceeded)
+	_, err = provider.Download(ctx, "data/00000")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	_, err = provider.List(ctx, "data/")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	_, err = provider.Exists(ctx, "data/00000")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	err = ListPages(ctx, provider, "data/", func([]string) error { return nil })
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+
+	_, err = provider.(VersionedProvider).ListVersions(ctx, "data/00000")
+	assert.ErrorIs(t, err, ErrVersioningNotSupported)
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+)
+
+// UploadLimiter caps the number of concurrent uploads. A single UploadLimiter can be shared by
+// any number of providers, which then share the concurrency budget.
+type UploadLimiter struct {
+	slots chan struct{}
+}
+
+// NewUploadLimiter creates a limiter allowing n concurrent uploads, nil if n <= 0 (unlimited)
+func NewUploadLimiter(n int) *UploadLimiter {
+	if n <= 0 {
+		return nil
+	}
+	return &UploadLimiter{slots: make(chan struct{}, n)}
+}
+
+// Acquire blocks until an upload slot is free or ctx is done. A nil limiter never blocks.
+func (l *UploadLimiter) Acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release frees a slot taken by Acquire
+func (l *UploadLimiter) Release() {
+	if l != nil {
+		<-l.slots
+	}
+}
+
+// globalUploadLimiter process-wide limiter applied by every upload-limited provider
+var globalUploadLimiter atomic.Pointer[UploadLimiter]
+
+// SetGlobalUploadLimit caps concurrent uploads across all writers of the process, 0 removes the cap.
+// Uploads already waiting keep the limit that was in effect when they started.
+func SetGlobalUploadLimit(n int) {
+	globalUploadLimiter.Store(NewUploadLimiter(n))
+}
+
+// uploadLimitedProvider holds an upload slot of its own limiter and the global one for every upload
+type uploadLimitedProvider struct {
+	ObjectStorageProvider
+	limiter *UploadLimiter
+}
+
+// conditionalUploadLimitedProvider additionally forwards conditional uploads
+type conditionalUploadLimitedProvider struct {
+	*uploadLimitedProvider
+	conditional ConditionalUploader
+}
+
+// NewUploadLimitedProvider wraps provider so that uploads respect limiter, which may be nil, and
+// the limit set with SetGlobalUploadLimit. Copies and moves hold a slot too. Conditional upload support
+// is preserved.
+func NewUploadLimitedProvider(provider ObjectStorageProvider, limiter *UploadLimiter) ObjectStorageProvider {
+	if provider == nil {
+		return provider
+	}
+	p := &uploadLimitedProvider{ObjectStorageProvider: provider, limiter: limiter}
+	if conditional, ok := provider.(ConditionalUploader); ok {
+		return &conditionalUploadLimitedProvider{uploadLimitedProvider: p, conditional: conditional}
+	}
+	return p
+}
+
+// acquire takes a slot of the provider's limiter, then of the global one, and returns the release func
+func (p *uploadLimitedProvider) acquire(ctx context.Context) (func(), error) {
+	if err := p.limiter.Acquire(ctx); err != nil {
+		return nil, err
+	}
+	global := globalUploadLimiter.Load()
+	if err := global.Acquire(ctx); err != nil {
+		p.limiter.Release()
+		return nil, err
+	}
+	return func() {
+		global.Release()
+		p.limiter.Release()
+	}, nil
+}
+
+// Upload implements ObjectStorageProvider interface
+func (p *uploadLimitedProvider) Upload(ctx context.Context, path string, data io.Reader) error {
+	release, err := p.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return p.ObjectStorageProvider.Upload(ctx, path, data)
+}
+
+// Copy implements Copier interface, holding an upload slot
+func (p *uploadLimitedProvider) Copy(ctx context.Context, src, dst string) error {
+	release, err := p.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return Copy(ctx, p.ObjectStorageProvider, src, dst)
+}
+
+// Move implements Mover interface, holding an upload slot
+func (p *uploadLimitedProvider) Move(ctx context.Context, src, dst string) error {
+	release, err := p.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return Move(ctx, p.ObjectStorageProvider, src, dst)
+}
+
+// UploadIfNotExists implements ConditionalUploader interface
+func (p *conditionalUploadLimitedProvider) UploadIfNotExists(ctx context.Context, path string, data io.Reader) error {
+	release, err := p.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return p.conditional.UploadIfNotExists(ctx, path, data)
+}
@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowProvider records the peak number of concurrent uploads
+type slowProvider struct {
+	ObjectStorageProvider
+	active atomic.Int32
+	peak   atomic.Int32
+}
+
+func (p *slowProvider) Upload(ctx context.Context, path string, data io.Reader) error {
+	active := p.active.Add(1)
+	defer p.active.Add(-1)
+	for {
+		peak := p.peak.Load()
+		if active <= peak || p.peak.CompareAndSwap(peak, active) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	return nil
+}
+
+// peakConcurrency runs uploads concurrently through each provider and returns the peak seen by inner
+func peakConcurrency(t *testing.T, inner *slowProvider, providers ...ObjectStorageProvider) int32 {
+	t.Helper()
+	var wg sync.WaitGroup
+	for _, provider := range providers {
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, provider.Upload(context.Background(), "a.txt", strings.NewReader("x")))
+			}()
+		}
+	}
+	wg.Wait()
+	return inner.peak.Load()
+}
+
+func TestUploadLimitedProvider(t *testing.T) {
+	t.Run("per provider limit", func(t *testing.T) {
+		inner := &slowProvider{}
+		assert.Equal(t, int32(2), peakConcurrency(t, inner, NewUploadLimitedProvider(inner, NewUploadLimiter(2))))
+	})
+
+	t.Run("global limit across providers", func(t *testing.T) {
+		SetGlobalUploadLimit(3)
+		defer SetGlobalUploadLimit(0)
+
+		inner := &slowProvider{}
+		a := NewUploadLimitedProvider(inner, NewUploadLimiter(2))
+		b := NewUploadLimitedProvider(inner, nil)
+		assert.Equal(t, int32(3), peakConcurrency(t, inner, a, b))
+	})
+
+	t.Run("cancelled while waiting", func(t *testing.T) {
+		limiter := NewUploadLimiter(1)
+		require.NoError(t, limiter.Acquire(context.Background()))
+		defer limiter.Release()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := NewUploadLimitedProvider(&slowProvider{}, limiter).Upload(ctx, "a.txt", strings.NewReader("x"))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("conditional upload support is preserved", func(t *testing.T) {
+		provider, err := NewObjectStorageProvider(&ProviderConfig{
+			Type:    ProviderTypeLocalFS,
+			LocalFS: &LocalFSConfig{BasePath: t.TempDir(), CreateDirs: true},
+		})
+		require.NoError(t, err)
+		limited := NewUploadLimitedProvider(provider, NewUploadLimiter(1))
+		conditional, ok := limited.(ConditionalUploader)
+		require.True(t, ok)
+		require.NoError(t, conditional.UploadIfNotExists(context.Background(), "a.txt", strings.NewReader("x")))
+		assert.ErrorIs(t, conditional.UploadIfNotExists(context.Background(), "a.txt", strings.NewReader("x")), ErrObjectExists)
+	})
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/pingcap/metering_sdk/storage/provider"
+)
+
+// ObjectVersion describes one version of an object in a versioned bucket
+type ObjectVersion = provider.ObjectVersion
+
+// ErrVersioningNotSupported is returned when a version pinned operation is used with a provider
+// that doesn't implement VersionedProvider
+var ErrVersioningNotSupported = errors.New("storage provider does not support object versions")
+
+// VersionedProvider is implemented by providers that can read previous versions of objects in
+// versioned buckets, e.g. to audit exactly what was read at invoice time after files were restated
+type VersionedProvider interface {
+	// DownloadVersion downloads the given version of the object at path
+	DownloadVersion(ctx context.Context, path string, versionID string) (io.ReadCloser, error)
+	// ListVersions lists all versions of the object at path, including delete markers, newest first
+	ListVersions(ctx context.Context, path string) ([]ObjectVersion, error)
+}
+
+// VersionAt returns the version that was current at time at, given versions sorted newest first.
+// It returns false if the object didn't exist at that time or had been deleted.
+func VersionAt(versions []ObjectVersion, at time.Time) (*ObjectVersion, bool) {
+	for i := range versions {
+		if versions[i].LastModified.After(at) {
+			continue
+		}
+		if versions[i].IsDeleteMarker {
+			return nil, false
+		}
+		return &versions[i], true
+	}
+	return nil, false
+}
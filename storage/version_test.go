@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionAt(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	versions := []ObjectVersion{
+		{VersionID: "v3", LastModified: base.Add(3 * time.Hour), IsLatest: true},
+		{VersionID: "v2", LastModified: base.Add(2 * time.Hour), IsDeleteMarker: true},
+		{VersionID: "v1", LastModified: base.Add(time.Hour)},
+	}
+
+	tests := []struct {
+		at        time.Time
+		versionID string
+	}{
+		{base, ""},
+		{base.Add(time.Hour), "v1"},
+		{base.Add(90 * time.Minute), "v1"},
+		{base.Add(2 * time.Hour), ""},
+		{base.Add(4 * time.Hour), "v3"},
+	}
+	for _, tt := range tests {
+		version, ok := VersionAt(versions, tt.at)
+		if tt.versionID == "" {
+			assert.False(t, ok, "at %s", tt.at)
+			continue
+		}
+		assert.True(t, ok, "at %s", tt.at)
+		assert.Equal(t, tt.versionID, version.VersionID)
+	}
+}
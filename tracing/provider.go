@@ -0,0 +1,183 @@
+package tracing
+
+import (
+	"context"
+	"io"
+
+	"github.com/pingcap/metering_sdk/storage"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedProvider creates a span for every storage operation
+type tracedProvider struct {
+	storage.ObjectStorageProvider
+	tp trace.TracerProvider
+}
+
+// conditionalTracedProvider additionally forwards conditional uploads
+type conditionalTracedProvider struct {
+	*tracedProvider
+	conditional storage.ConditionalUploader
+}
+
+// TraceProvider wraps provider so that every operation creates a span with tp.
+// If tp is nil, provider is returned unchanged. Conditional uploads, paginated listing, stats, copies and
+// object versions are preserved.
+func TraceProvider(provider storage.ObjectStorageProvider, tp trace.TracerProvider) storage.ObjectStorageProvider {
+	if tp == nil || provider == nil {
+		return provider
+	}
+	if _, ok := provider.(*tracedProvider); ok {
+		return provider
+	}
+	if _, ok := provider.(*conditionalTracedProvider); ok {
+		return provider
+	}
+	p := &tracedProvider{ObjectStorageProvider: provider, tp: tp}
+	if conditional, ok := provider.(storage.ConditionalUploader); ok {
+		return &conditionalTracedProvider{tracedProvider: p, conditional: conditional}
+	}
+	return p
+}
+
+// Upload implements ObjectStorageProvider interface
+func (p *tracedProvider) Upload(ctx context.Context, path string, data io.Reader) error {
+	ctx, span := Start(ctx, p.tp, "storage.Upload", AttributePath.String(path))
+	err := p.ObjectStorageProvider.Upload(ctx, path, data)
+	End(span, err)
+	return err
+}
+
+// Download implements ObjectStorageProvider interface
+func (p *tracedProvider) Download(ctx context.Context, path string) (io.ReadCloser, error) {
+	ctx, span := Start(ctx, p.tp, "storage.Download", AttributePath.String(path))
+	rc, err := p.ObjectStorageProvider.Download(ctx, path)
+	End(span, err)
+	return rc, err
+}
+
+// Delete implements ObjectStorageProvider interface
+func (p *tracedProvider) Delete(ctx context.Context, path string) error {
+	ctx, span := Start(ctx, p.tp, "storage.Delete", AttributePath.String(path))
+	err := p.ObjectStorageProvider.Delete(ctx, path)
+	End(span, err)
+	return err
+}
+
+// Exists implements ObjectStorageProvider interface
+func (p *tracedProvider) Exists(ctx context.Context, path string) (bool, error) {
+	ctx, span := Start(ctx, p.tp, "storage.Exists", AttributePath.String(path))
+	exists, err := p.ObjectStorageProvider.Exists(ctx, path)
+	span.SetAttributes(attribute.Bool("metering.exists", exists))
+	End(span, err)
+	return exists, err
+}
+
+// List implements ObjectStorageProvider interface
+func (p *tracedProvider) List(ctx context.Context, prefix string) ([]string, error) {
+	ctx, span := Start(ctx, p.tp, "storage.List", AttributePrefix.String(prefix))
+	objects, err := p.ObjectStorageProvider.List(ctx, prefix)
+	span.SetAttributes(attribute.Int("metering.objects", len(objects)))
+	End(span, err)
+	return objects, err
+}
+
+// Copy implements storage.Copier interface
+func (p *tracedProvider) Copy(ctx context.Context, src, dst string) error {
+	ctx, span := Start(ctx, p.tp, "storage.Copy", AttributePath.String(dst), AttributeSource.String(src))
+	err := storage.Copy(ctx, p.ObjectStorageProvider, src, dst)
+	End(span, err)
+	return err
+}
+
+// Move implements storage.Mover interface
+func (p *tracedProvider) Move(ctx context.Context, src, dst string) error {
+	ctx, span := Start(ctx, p.tp, "storage.Move", AttributePath.String(dst), AttributeSource.String(src))
+	err := storage.Move(ctx, p.ObjectStorageProvider, src, dst)
+	End(span, err)
+	return err
+}
+
+// ListPages implements storage.PageLister interface, with one span for the whole listing
+func (p *tracedProvider) ListPages(ctx context.Context, prefix string, fn func(page []string) error) error {
+	ctx, span := Start(ctx, p.tp, "storage.ListPages", AttributePrefix.String(prefix))
+	objects := 0
+	err := storage.ListPages(ctx, p.ObjectStorageProvider, prefix, func(page []string) error {
+		objects += len(page)
+		return fn(page)
+	})
+	span.SetAttributes(attribute.Int("metering.objects", objects))
+	End(span, err)
+	return err
+}
+
+// ListPage implements storage.PageTokenLister interface
+func (p *tracedProvider) ListPage(ctx context.Context, prefix, token string, limit int) ([]string, string, error) {
+	ctx, span := Start(ctx, p.tp, "storage.ListPage", AttributePrefix.String(prefix))
+	objects, next, err := storage.ListPage(ctx, p.ObjectStorageProvider, prefix, token, limit)
+	span.SetAttributes(attribute.Int("metering.objects", len(objects)))
+	End(span, err)
+	return objects, next, err
+}
+
+// ListCommonPrefixes implements storage.PrefixLister interface
+func (p *tracedProvider) ListCommonPrefixes(ctx context.Context, prefix, delimiter string) ([]string, error) {
+	ctx, span := Start(ctx, p.tp, "storage.ListCommonPrefixes", AttributePrefix.String(prefix))
+	prefixes, err := storage.ListCommonPrefixes(ctx, p.ObjectStorageProvider, prefix, delimiter)
+	span.SetAttributes(attribute.Int("metering.objects", len(prefixes)))
+	End(span, err)
+	return prefixes, err
+}
+
+// ListAttributes implements storage.AttributeLister interface
+func (p *tracedProvider) ListAttributes(ctx context.Context, prefix string) ([]storage.ObjectAttributes, error) {
+	ctx, span := Start(ctx, p.tp, "storage.ListAttributes", AttributePrefix.String(prefix))
+	objects, err := storage.ListAttributes(ctx, p.ObjectStorageProvider, prefix)
+	span.SetAttributes(attribute.Int("metering.objects", len(objects)))
+	End(span, err)
+	return objects, err
+}
+
+// Stat implements storage.ObjectStater interface
+func (p *tracedProvider) Stat(ctx context.Context, path string) (*storage.ObjectAttributes, error) {
+	ctx, span := Start(ctx, p.tp, "storage.Stat", AttributePath.String(path))
+	attrs, err := storage.Stat(ctx, p.ObjectStorageProvider, path)
+	End(span, err)
+	return attrs, err
+}
+
+// DownloadVersion implements storage.VersionedProvider interface, failing with
+// storage.ErrVersioningNotSupported if the wrapped provider doesn't support versions
+func (p *tracedProvider) DownloadVersion(ctx context.Context, path string, versionID string) (io.ReadCloser, error) {
+	versioned, ok := p.ObjectStorageProvider.(storage.VersionedProvider)
+	if !ok {
+		return nil, storage.ErrVersioningNotSupported
+	}
+	ctx, span := Start(ctx, p.tp, "storage.DownloadVersion", AttributePath.String(path))
+	rc, err := versioned.DownloadVersion(ctx, path, versionID)
+	End(span, err)
+	return rc, err
+}
+
+// ListVersions implements storage.VersionedProvider interface, failing with
+// storage.ErrVersioningNotSupported if the wrapped provider doesn't support versions
+func (p *tracedProvider) ListVersions(ctx context.Context, path string) ([]storage.ObjectVersion, error) {
+	versioned, ok := p.ObjectStorageProvider.(storage.VersionedProvider)
+	if !ok {
+		return nil, storage.ErrVersioningNotSupported
+	}
+	ctx, span := Start(ctx, p.tp, "storage.ListVersions", AttributePath.String(path))
+	versions, err := versioned.ListVersions(ctx, path)
+	span.SetAttributes(attribute.Int("metering.objects", len(versions)))
+	End(span, err)
+	return versions, err
+}
+
+// UploadIfNotExists implements storage.ConditionalUploader interface
+func (p *conditionalTracedProvider) UploadIfNotExists(ctx context.Context, path string, data io.Reader) error {
+	ctx, span := Start(ctx, p.tp, "storage.UploadIfNotExists", AttributePath.String(path))
+	err := p.conditional.UploadIfNotExists(ctx, path, data)
+	End(span, err)
+	return err
+}
@@ -0,0 +1,43 @@
+// Package tracing provides optional OpenTelemetry spans for writers, readers and storage providers.
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// InstrumentationName is the name of the tracer used for all SDK spans
+const InstrumentationName = "github.com/pingcap/metering_sdk"
+
+// Attribute keys used on SDK spans
+const (
+	AttributePath      = attribute.Key("metering.path")
+	AttributeSource    = attribute.Key("metering.source")
+	AttributePrefix    = attribute.Key("metering.prefix")
+	AttributeCategory  = attribute.Key("metering.category")
+	AttributeTimestamp = attribute.Key("metering.timestamp")
+	AttributeClusterID = attribute.Key("metering.cluster_id")
+	AttributeVersionID = attribute.Key("metering.version_id")
+)
+
+// Start starts a span named name as a child of the span in ctx.
+// If tp is nil, ctx is returned unchanged with a no-op span.
+func Start(ctx context.Context, tp trace.TracerProvider, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if tp == nil {
+		return ctx, noop.Span{}
+	}
+	return tp.Tracer(InstrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err, if any, on span and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,86 @@
+package tracing_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/pingcap/metering_sdk/config"
+	"github.com/pingcap/metering_sdk/storage"
+	"github.com/pingcap/metering_sdk/tracing"
+	meteringwriter "github.com/pingcap/metering_sdk/writer/metering"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTraceProvider(t *testing.T) {
+	provider := storage.NewMemoryProvider()
+	assert.Same(t, provider, tracing.TraceProvider(provider, nil))
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	traced := tracing.TraceProvider(provider, tp)
+	assert.Same(t, traced, tracing.TraceProvider(traced, tp), "wrapping twice should be a no-op")
+	_, ok := traced.(storage.ConditionalUploader)
+	assert.True(t, ok, "conditional upload support should be preserved")
+	stater, ok := traced.(storage.ObjectStater)
+	require.True(t, ok, "stats should be preserved")
+	prefixes, ok := traced.(storage.PrefixLister)
+	require.True(t, ok, "delimiter-based listing should be preserved")
+
+	ctx := context.Background()
+	_, err := traced.Exists(ctx, "a.txt")
+	require.NoError(t, err)
+	_, err = traced.Download(ctx, "missing.txt")
+	require.Error(t, err)
+	_, err = stater.Stat(ctx, "missing.txt")
+	require.Error(t, err)
+	_, err = prefixes.ListCommonPrefixes(ctx, "", "/")
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 4)
+	assert.Equal(t, "storage.Exists", spans[0].Name())
+	assert.Equal(t, "storage.Download", spans[1].Name())
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+	assert.Equal(t, "storage.Stat", spans[2].Name())
+	assert.Equal(t, "storage.ListCommonPrefixes", spans[3].Name())
+}
+
+func TestWriterSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	cfg := config.DefaultConfig().WithTracerProvider(tp)
+
+	w := meteringwriter.NewMeteringWriterWithSharedPool(storage.NewMemoryProvider(), cfg, "pool1")
+	defer w.Close()
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "caller")
+	err := w.Write(ctx, &common.MeteringData{
+		Timestamp: 1755849600,
+		Category:  "tidb",
+		SelfID:    "server1",
+		Data: []map[string]interface{}{
+			{"logical_cluster_id": "lc1", "ru": &common.MeteringValue{Value: 1, Unit: "RU"}},
+		},
+	})
+	require.NoError(t, err)
+	parent.End()
+
+	byName := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		byName[span.Name()] = span
+	}
+	write := byName["MeteringWriter.Write"]
+	require.NotNil(t, write)
+	assert.Equal(t, parent.SpanContext().SpanID(), write.Parent().SpanID(), "write span should be a child of the caller")
+
+	// Existing files are rejected by the conditional upload, without an Exists check
+	assert.NotContains(t, byName, "storage.Exists")
+	upload := byName["storage.UploadIfNotExists"]
+	require.NotNil(t, upload)
+	assert.Equal(t, write.SpanContext().SpanID(), upload.Parent().SpanID(), "upload span should be a child of the write span")
+}
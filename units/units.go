@@ -0,0 +1,250 @@
+// Package units defines the units of metering values and converts between units of the same dimension,
+// so e.g. "MB", "MiB" and "bytes" can be told apart and summed.
+package units
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/pingcap/metering_sdk/common"
+)
+
+// Dimension the kind of quantity a unit measures, values convert between units of the same dimension only
+type Dimension string
+
+const (
+	// DimensionBytes data sizes, canonical unit Bytes
+	DimensionBytes Dimension = "bytes"
+	// DimensionTime durations, canonical unit Seconds
+	DimensionTime Dimension = "time"
+	// DimensionRU request units, canonical unit RU
+	DimensionRU Dimension = "ru"
+	// DimensionCount plain counts, canonical unit Count
+	DimensionCount Dimension = "count"
+	// DimensionCPU CPU allocations, canonical unit Millicores
+	DimensionCPU Dimension = "cpu"
+)
+
+// Canonical unit names, metering values are normalized to them
+const (
+	Bytes      = "bytes"
+	Seconds    = "seconds"
+	RU         = "RU"
+	Count      = "count"
+	Millicores = "millicores"
+)
+
+// Unit a unit of measurement
+type Unit struct {
+	Name      string    // unit name, as set on common.MeteringValue.Unit
+	Dimension Dimension // quantity measured
+	Factor    float64   // value of one unit in the canonical unit of the dimension
+}
+
+// builtin units known to every registry
+var builtin = []Unit{
+	{Bytes, DimensionBytes, 1},
+	{"B", DimensionBytes, 1},
+	{"KB", DimensionBytes, 1e3},
+	{"MB", DimensionBytes, 1e6},
+	{"GB", DimensionBytes, 1e9},
+	{"TB", DimensionBytes, 1e12},
+	{"KiB", DimensionBytes, 1 << 10},
+	{"MiB", DimensionBytes, 1 << 20},
+	{"GiB", DimensionBytes, 1 << 30},
+	{"TiB", DimensionBytes, 1 << 40},
+
+	{Seconds, DimensionTime, 1},
+	{"s", DimensionTime, 1},
+	{"ms", DimensionTime, 1e-3},
+	{"milliseconds", DimensionTime, 1e-3},
+	{"minutes", DimensionTime, 60},
+	{"hours", DimensionTime, 3600},
+
+	{RU, DimensionRU, 1},
+
+	{Count, DimensionCount, 1},
+
+	{Millicores, DimensionCPU, 1},
+	{"cores", DimensionCPU, 1000},
+	{"vCPU", DimensionCPU, 1000},
+}
+
+// Registry holds known units, it is safe for concurrent use
+type Registry struct {
+	mu    sync.RWMutex
+	units map[string]Unit
+}
+
+// NewRegistry creates a registry of the built-in units
+func NewRegistry() *Registry {
+	r := &Registry{units: make(map[string]Unit, len(builtin))}
+	for _, u := range builtin {
+		r.units[u.Name] = u
+	}
+	return r
+}
+
+var defaultRegistry = NewRegistry()
+
+// Default returns the registry used by the package level functions
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// Register registers a unit, e.g. a service specific alias. Built-in and registered units can't be redefined
+func (r *Registry) Register(u Unit) error {
+	if u.Name == "" || u.Dimension == "" {
+		return fmt.Errorf("unit name and dimension are required")
+	}
+	if u.Factor <= 0 || math.IsInf(u.Factor, 0) || math.IsNaN(u.Factor) {
+		return fmt.Errorf("unit %s has invalid factor %v", u.Name, u.Factor)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.units[u.Name]; ok {
+		return fmt.Errorf("unit %s is already registered with dimension %s", u.Name, existing.Dimension)
+	}
+	r.units[u.Name] = u
+	return nil
+}
+
+// Lookup returns the unit called name
+func (r *Registry) Lookup(name string) (Unit, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	u, ok := r.units[name]
+	return u, ok
+}
+
+// Units returns the names of the known units of dimension, all units if it is empty, sorted
+func (r *Registry) Units(dimension Dimension) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var names []string
+	for name, u := range r.units {
+		if dimension == "" || u.Dimension == dimension {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Canonical returns the canonical unit of dimension
+func Canonical(dimension Dimension) (string, bool) {
+	switch dimension {
+	case DimensionBytes:
+		return Bytes, true
+	case DimensionTime:
+		return Seconds, true
+	case DimensionRU:
+		return RU, true
+	case DimensionCount:
+		return Count, true
+	case DimensionCPU:
+		return Millicores, true
+	}
+	return "", false
+}
+
+// Convert converts value from unit from to unit to, which must have the same dimension
+func (r *Registry) Convert(value float64, from, to string) (float64, error) {
+	fromUnit, toUnit, err := r.lookupPair(from, to)
+	if err != nil {
+		return 0, err
+	}
+	if from == to {
+		return value, nil
+	}
+	return value * fromUnit.Factor / toUnit.Factor, nil
+}
+
+// ConvertValue returns v converted to unit to. Integral values stay integral if the result is
+func (r *Registry) ConvertValue(v *common.MeteringValue, to string) (*common.MeteringValue, error) {
+	converted, err := r.Convert(v.Float64(), v.Unit, to)
+	if err != nil {
+		return nil, err
+	}
+	if !v.IsFloat() && converted >= 0 && converted < 0x1p64 && converted == math.Trunc(converted) {
+		return &common.MeteringValue{Value: uint64(converted), Unit: to}, nil
+	}
+	return common.NewFloatMeteringValue(converted, -1, to), nil
+}
+
+// Normalize returns v converted to the canonical unit of its dimension. For dimensions registered by
+// callers, the unit with factor 1 is canonical
+func (r *Registry) Normalize(v *common.MeteringValue) (*common.MeteringValue, error) {
+	u, ok := r.Lookup(v.Unit)
+	if !ok {
+		return nil, fmt.Errorf("unknown unit %q", v.Unit)
+	}
+	canonical, ok := Canonical(u.Dimension)
+	if !ok {
+		canonical, ok = r.base(u.Dimension)
+		if !ok {
+			return nil, fmt.Errorf("dimension %s of unit %s has no unit with factor 1", u.Dimension, u.Name)
+		}
+	}
+	return r.ConvertValue(v, canonical)
+}
+
+// Validate checks every metering value of the data entries has a known unit
+func (r *Registry) Validate(data []map[string]interface{}) error {
+	for i, entry := range data {
+		for field, raw := range entry {
+			value, ok := common.ParseMeteringValue(raw)
+			if !ok {
+				continue
+			}
+			if _, known := r.Lookup(value.Unit); !known {
+				return fmt.Errorf("data entry %d field %s has unknown unit %q", i, field, value.Unit)
+			}
+		}
+	}
+	return nil
+}
+
+// lookupPair looks up units from and to, which must have the same dimension
+func (r *Registry) lookupPair(from, to string) (Unit, Unit, error) {
+	fromUnit, ok := r.Lookup(from)
+	if !ok {
+		return Unit{}, Unit{}, fmt.Errorf("unknown unit %q", from)
+	}
+	toUnit, ok := r.Lookup(to)
+	if !ok {
+		return Unit{}, Unit{}, fmt.Errorf("unknown unit %q", to)
+	}
+	if fromUnit.Dimension != toUnit.Dimension {
+		return Unit{}, Unit{}, fmt.Errorf("cannot convert %s (%s) to %s (%s)", from, fromUnit.Dimension, to, toUnit.Dimension)
+	}
+	return fromUnit, toUnit, nil
+}
+
+// base returns the first unit of dimension with factor 1, by name
+func (r *Registry) base(dimension Dimension) (string, bool) {
+	for _, name := range r.Units(dimension) {
+		if u, _ := r.Lookup(name); u.Factor == 1 {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Register registers a unit with the default registry
+func Register(u Unit) error {
+	return defaultRegistry.Register(u)
+}
+
+// Convert converts value between units of the default registry
+func Convert(value float64, from, to string) (float64, error) {
+	return defaultRegistry.Convert(value, from, to)
+}
+
+// Normalize converts v to its canonical unit with the default registry
+func Normalize(v *common.MeteringValue) (*common.MeteringValue, error) {
+	return defaultRegistry.Normalize(v)
+}
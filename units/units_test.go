@@ -0,0 +1,75 @@
+package units
+
+import (
+	"testing"
+
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		value    float64
+		from, to string
+		expected float64
+	}{
+		{1, "MiB", "bytes", 1 << 20},
+		{1, "MB", "KB", 1000},
+		{2048, "MiB", "GiB", 2},
+		{1500, "ms", "seconds", 1.5},
+		{2, "hours", "minutes", 120},
+		{1.5, "vCPU", "millicores", 1500},
+		{7, "RU", "RU", 7},
+	}
+	for _, tt := range tests {
+		converted, err := Convert(tt.value, tt.from, tt.to)
+		require.NoError(t, err, "%s to %s", tt.from, tt.to)
+		assert.InDelta(t, tt.expected, converted, 1e-9, "%s to %s", tt.from, tt.to)
+	}
+
+	_, err := Convert(1, "MB", "seconds")
+	assert.ErrorContains(t, err, "cannot convert")
+	_, err = Convert(1, "mb", "bytes")
+	assert.ErrorContains(t, err, "unknown unit")
+}
+
+func TestNormalize(t *testing.T) {
+	normalized, err := Normalize(&common.MeteringValue{Value: 3, Unit: "GB"})
+	require.NoError(t, err)
+	assert.Equal(t, &common.MeteringValue{Value: 3e9, Unit: Bytes}, normalized)
+
+	normalized, err = Normalize(&common.MeteringValue{Value: 1, Unit: "ms"})
+	require.NoError(t, err)
+	assert.True(t, normalized.IsFloat(), "fractional results are float values")
+	assert.Equal(t, 0.001, normalized.Float64())
+
+	_, err = Normalize(&common.MeteringValue{Value: 1, Unit: "parsecs"})
+	assert.Error(t, err)
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	require.NoError(t, r.Register(Unit{Name: "vcpu_hours", Dimension: "cpu_time", Factor: 3600}))
+	require.NoError(t, r.Register(Unit{Name: "vcpu_seconds", Dimension: "cpu_time", Factor: 1}))
+	require.NoError(t, r.Register(Unit{Name: "KRU", Dimension: DimensionRU, Factor: 1000}))
+	assert.Error(t, r.Register(Unit{Name: "MB", Dimension: DimensionBytes, Factor: 1 << 20}), "units can't be redefined")
+	assert.Error(t, r.Register(Unit{Name: "zero", Dimension: DimensionCount}), "factor is required")
+	_, ok := Default().Lookup("KRU")
+	assert.False(t, ok, "registries are independent")
+
+	assert.Equal(t, []string{"vcpu_hours", "vcpu_seconds"}, r.Units("cpu_time"))
+	normalized, err := r.Normalize(&common.MeteringValue{Value: 2, Unit: "vcpu_hours"})
+	require.NoError(t, err)
+	assert.Equal(t, &common.MeteringValue{Value: 7200, Unit: "vcpu_seconds"}, normalized)
+	converted, err := r.ConvertValue(&common.MeteringValue{Value: 1500, Unit: RU}, "KRU")
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, converted.Float64())
+
+	assert.NoError(t, r.Validate([]map[string]interface{}{
+		{"logical_cluster_id": "lc-001", "ru": &common.MeteringValue{Value: 1, Unit: "KRU"}},
+	}))
+	assert.ErrorContains(t, r.Validate([]map[string]interface{}{
+		{"logical_cluster_id": "lc-001", "memory": &common.MeteringValue{Value: 1, Unit: "Mb"}},
+	}), `unknown unit "Mb"`)
+}
@@ -0,0 +1,193 @@
+package writer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pingcap/metering_sdk/storage"
+)
+
+// deadLetterInfoSuffix suffix of the file holding the failure details next to a dead-lettered page
+const deadLetterInfoSuffix = ".error.json"
+
+// DeadLetter a page whose upload failed terminally, kept so it can be replayed once storage recovers
+type DeadLetter struct {
+	Path  string     `json:"path"`        // target path of the page
+	Data  []byte     `json:"-"`           // gzip-compressed page, exactly as it would have been uploaded
+	Class ErrorClass `json:"error_class"` // error classification
+	Err   error      `json:"-"`           // the upload error
+	Time  time.Time  `json:"time"`        // time the upload failed
+}
+
+// MarshalJSON implements json.Marshaler, including the error message
+func (l *DeadLetter) MarshalJSON() ([]byte, error) {
+	type alias DeadLetter
+	var message string
+	if l.Err != nil {
+		message = l.Err.Error()
+	}
+	return json.Marshal(&struct {
+		*alias
+		Error string `json:"error"`
+	}{
+		alias: (*alias)(l),
+		Error: message,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler, restoring the error message as Err
+func (l *DeadLetter) UnmarshalJSON(data []byte) error {
+	type alias DeadLetter
+	decoded := struct {
+		*alias
+		Error string `json:"error"`
+	}{alias: (*alias)(l)}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	if decoded.Error != "" {
+		l.Err = errors.New(decoded.Error)
+	}
+	return nil
+}
+
+// DeadLetterQueue receives pages whose upload failed terminally, instead of dropping them.
+// Implementations must be safe for concurrent use.
+type DeadLetterQueue interface {
+	// PutDeadLetter stores letter, the write is reported as dead-lettered if it returns nil
+	PutDeadLetter(ctx context.Context, letter *DeadLetter) error
+}
+
+// DeadLetterFunc adapts a function to the DeadLetterQueue interface
+type DeadLetterFunc func(ctx context.Context, letter *DeadLetter) error
+
+// PutDeadLetter implements DeadLetterQueue
+func (f DeadLetterFunc) PutDeadLetter(ctx context.Context, letter *DeadLetter) error {
+	return f(ctx, letter)
+}
+
+// StorageDeadLetterQueue DeadLetterQueue storing dead letters in an object storage provider
+type StorageDeadLetterQueue struct {
+	provider storage.ObjectStorageProvider
+	prefix   string
+}
+
+// NewStorageDeadLetterQueue creates a dead letter queue storing every page at {prefix}/{path}, with the
+// failure details in {prefix}/{path}.error.json. provider can be the writer's own provider with an
+// alternate prefix, or a different one, e.g. a second bucket.
+func NewStorageDeadLetterQueue(provider storage.ObjectStorageProvider, prefix string) *StorageDeadLetterQueue {
+	return &StorageDeadLetterQueue{provider: provider, prefix: strings.Trim(prefix, "/")}
+}
+
+// NewLocalDeadLetterQueue creates a dead letter queue storing pages in the local directory dir,
+// which is created if needed
+func NewLocalDeadLetterQueue(dir string) (*StorageDeadLetterQueue, error) {
+	return NewLocalDeadLetterQueueWithQuota(dir, 0, "")
+}
+
+// NewLocalDeadLetterQueueWithQuota creates a dead letter queue storing at most maxBytes of pages in the
+// local directory dir, so an outage can't fill the disk. policy applies once the directory is full, see
+// storage.LocalFSConfig.QuotaPolicy
+func NewLocalDeadLetterQueueWithQuota(dir string, maxBytes int64, policy storage.QuotaPolicy) (*StorageDeadLetterQueue, error) {
+	provider, err := storage.NewObjectStorageProvider(&storage.ProviderConfig{
+		Type:    storage.ProviderTypeLocalFS,
+		LocalFS: &storage.LocalFSConfig{BasePath: dir, CreateDirs: true, MaxBytes: maxBytes, QuotaPolicy: policy},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dead letter directory provider: %w", err)
+	}
+	return NewStorageDeadLetterQueue(provider, ""), nil
+}
+
+// PutDeadLetter implements DeadLetterQueue
+func (q *StorageDeadLetterQueue) PutDeadLetter(ctx context.Context, letter *DeadLetter) error {
+	info, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter: %w", err)
+	}
+	// Store the write's failure even if its context was cancelled
+	ctx = context.WithoutCancel(ctx)
+	path := deadLetterPath(q.prefix, letter.Path)
+	if err := q.provider.Upload(ctx, path, bytes.NewReader(letter.Data)); err != nil {
+		return fmt.Errorf("failed to store dead letter %s: %w", path, err)
+	}
+	// The details are written last, so Replay never sees a letter without its page
+	if err := q.provider.Upload(ctx, path+deadLetterInfoSuffix, bytes.NewReader(info)); err != nil {
+		return fmt.Errorf("failed to store dead letter details %s: %w", path, err)
+	}
+	return nil
+}
+
+// Replay re-submits the stored dead letters with replay, e.g. MeteringWriter.ReplayDeadLetter.
+// Letters are deleted once replay succeeds; the others are kept and their errors returned joined.
+// It returns the number of letters replayed.
+func (q *StorageDeadLetterQueue) Replay(ctx context.Context, replay func(ctx context.Context, letter *DeadLetter) error) (int, error) {
+	files, err := q.provider.List(ctx, q.prefix)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+
+	replayed := 0
+	var errs []error
+	for _, infoPath := range files {
+		dataPath, ok := strings.CutSuffix(infoPath, deadLetterInfoSuffix)
+		if !ok {
+			continue
+		}
+		letter, err := readDeadLetter(ctx, q.provider, infoPath, dataPath)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := replay(ctx, letter); err != nil {
+			errs = append(errs, fmt.Errorf("failed to replay dead letter %s: %w", letter.Path, err))
+			continue
+		}
+		replayed++
+		if err := errors.Join(q.provider.Delete(ctx, dataPath), q.provider.Delete(ctx, infoPath)); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete replayed dead letter %s: %w", letter.Path, err))
+		}
+	}
+	return replayed, errors.Join(errs...)
+}
+
+// readDeadLetter reads the dead letter whose details are stored at infoPath and page at dataPath
+func readDeadLetter(ctx context.Context, provider storage.ObjectStorageProvider, infoPath, dataPath string) (*DeadLetter, error) {
+	info, err := download(ctx, provider, infoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead letter details %s: %w", infoPath, err)
+	}
+	letter := &DeadLetter{}
+	if err := json.Unmarshal(info, letter); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal dead letter details %s: %w", infoPath, err)
+	}
+	if letter.Data, err = download(ctx, provider, dataPath); err != nil {
+		return nil, fmt.Errorf("failed to read dead letter %s: %w", dataPath, err)
+	}
+	return letter, nil
+}
+
+// download reads the whole object at path
+func download(ctx context.Context, provider storage.ObjectStorageProvider, path string) ([]byte, error) {
+	body, err := provider.Download(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// deadLetterPath returns the path a page is dead-lettered at
+func deadLetterPath(prefix, path string) string {
+	path = strings.TrimPrefix(path, "/")
+	if prefix == "" {
+		return path
+	}
+	return prefix + "/" + path
+}
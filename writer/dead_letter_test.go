@@ -0,0 +1,55 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pingcap/metering_sdk/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageDeadLetterQueue(t *testing.T) {
+	ctx := context.Background()
+	provider := storage.NewMemoryProvider()
+	queue := NewStorageDeadLetterQueue(provider, "/dead-letter/")
+
+	letter := &DeadLetter{
+		Path:  "metering/ru/1640995200/storage/pool1/tikv001-0.json.gz",
+		Data:  []byte{0x1f, 0x8b, 0x08},
+		Class: ErrorClassStorage,
+		Err:   errors.New("connection reset"),
+		Time:  time.Unix(1640995260, 0).UTC(),
+	}
+	require.NoError(t, queue.PutDeadLetter(ctx, letter))
+	assert.Contains(t, provider.Snapshot(), "dead-letter/metering/ru/1640995200/storage/pool1/tikv001-0.json.gz.error.json")
+
+	// Failed replays keep the letter
+	var replayedLetters []*DeadLetter
+	replayed, err := queue.Replay(ctx, func(ctx context.Context, letter *DeadLetter) error {
+		replayedLetters = append(replayedLetters, letter)
+		if len(replayedLetters) == 1 {
+			return errors.New("still failing")
+		}
+		return nil
+	})
+	assert.ErrorContains(t, err, "still failing")
+	assert.Zero(t, replayed)
+
+	replayed, err = queue.Replay(ctx, func(ctx context.Context, letter *DeadLetter) error {
+		replayedLetters = append(replayedLetters, letter)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, replayed)
+	require.Len(t, replayedLetters, 2)
+	got := replayedLetters[1]
+	assert.Equal(t, letter.Path, got.Path)
+	assert.Equal(t, letter.Data, got.Data)
+	assert.Equal(t, letter.Class, got.Class)
+	assert.EqualError(t, got.Err, "connection reset")
+	assert.True(t, letter.Time.Equal(got.Time))
+	assert.Empty(t, provider.Snapshot())
+}
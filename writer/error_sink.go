@@ -0,0 +1,212 @@
+package writer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// webhookQueueSize number of failures a webhook error sink queues for delivery before dropping them
+const webhookQueueSize = 100
+
+// ErrorClass classifies terminal write failures
+type ErrorClass string
+
+const (
+	// ErrorClassValidation data failed validation before anything was written
+	ErrorClassValidation ErrorClass = "validation"
+	// ErrorClassConflict target file already exists and overwriting is disabled
+	ErrorClassConflict ErrorClass = "conflict"
+	// ErrorClassSerialization data could not be serialized or compressed
+	ErrorClassSerialization ErrorClass = "serialization"
+	// ErrorClassStorage storage provider operation failed
+	ErrorClassStorage ErrorClass = "storage"
+)
+
+// sentinel returns the error matching the class with errors.Is
+func (c ErrorClass) sentinel() error {
+	switch c {
+	case ErrorClassValidation:
+		return ErrValidation
+	case ErrorClassConflict:
+		return ErrFileExists
+	case ErrorClassSerialization:
+		return ErrSerialization
+	case ErrorClassStorage:
+		return ErrStorage
+	}
+	return nil
+}
+
+// WriteError is returned by writers when a write fails terminally. errors.Is matches the sentinel
+// of its class, e.g. ErrValidation, as well as the errors it wraps, e.g. storage.ErrThrottled.
+type WriteError struct {
+	Class ErrorClass // error classification
+	Path  string     // target path, empty if the failure happened before path construction
+	Err   error      // the underlying error
+}
+
+func (e *WriteError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *WriteError) Unwrap() []error {
+	if sentinel := e.Class.sentinel(); sentinel != nil {
+		return []error{sentinel, e.Err}
+	}
+	return []error{e.Err}
+}
+
+// WriteFailure describes a write that failed terminally
+type WriteFailure struct {
+	Path         string     `json:"path,omitempty"`          // target path, empty if the failure happened before path construction
+	Attempts     int        `json:"attempts"`                // number of attempts made
+	Class        ErrorClass `json:"error_class"`             // error classification
+	Err          error      `json:"-"`                       // the underlying error
+	Time         time.Time  `json:"time"`                    // time the failure was reported
+	DeadLettered bool       `json:"dead_lettered,omitempty"` // the data was kept by the dead letter queue
+}
+
+// MarshalJSON implements json.Marshaler, including the error message
+func (f *WriteFailure) MarshalJSON() ([]byte, error) {
+	type alias WriteFailure
+	var message string
+	if f.Err != nil {
+		message = f.Err.Error()
+	}
+	return json.Marshal(&struct {
+		*alias
+		Error string `json:"error"`
+	}{
+		alias: (*alias)(f),
+		Error: message,
+	})
+}
+
+// ErrorSink receives terminal write failures, e.g. to raise alerts on metering data loss.
+// Implementations must be safe for concurrent use and should return quickly.
+type ErrorSink interface {
+	// OnWriteFailure is called once for every write that failed terminally
+	OnWriteFailure(ctx context.Context, failure *WriteFailure)
+}
+
+// ErrorSinkFunc adapts a function to the ErrorSink interface
+type ErrorSinkFunc func(ctx context.Context, failure *WriteFailure)
+
+// OnWriteFailure implements ErrorSink
+func (f ErrorSinkFunc) OnWriteFailure(ctx context.Context, failure *WriteFailure) {
+	f(ctx, failure)
+}
+
+// channelErrorSink delivers failures to a channel
+type channelErrorSink struct {
+	ch chan<- *WriteFailure
+}
+
+// NewChannelErrorSink creates an error sink that sends failures to ch.
+// Sends never block: failures are dropped when the channel is full.
+func NewChannelErrorSink(ch chan<- *WriteFailure) ErrorSink {
+	return &channelErrorSink{ch: ch}
+}
+
+// OnWriteFailure implements ErrorSink
+func (s *channelErrorSink) OnWriteFailure(_ context.Context, failure *WriteFailure) {
+	select {
+	case s.ch <- failure:
+	default:
+	}
+}
+
+// webhookErrorSink posts failures as JSON to an HTTP endpoint
+type webhookErrorSink struct {
+	url     string
+	client  *http.Client
+	onError func(error)
+	queue   chan webhookDelivery
+	running atomic.Bool // a goroutine is delivering the queue
+}
+
+// webhookDelivery a queued failure with the context it was reported with
+type webhookDelivery struct {
+	ctx     context.Context
+	failure *WriteFailure
+}
+
+// NewWebhookErrorSink creates an error sink that POSTs every failure as JSON to url.
+// Failures are delivered in the background so writes don't wait for the webhook; up to 100 are queued
+// and later ones dropped. If client is nil, a client with a 10 second timeout is used. onError, if not
+// nil, is called when a failure cannot be delivered or is dropped.
+func NewWebhookErrorSink(url string, client *http.Client, onError func(error)) ErrorSink {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &webhookErrorSink{url: url, client: client, onError: onError, queue: make(chan webhookDelivery, webhookQueueSize)}
+}
+
+// OnWriteFailure implements ErrorSink
+func (s *webhookErrorSink) OnWriteFailure(ctx context.Context, failure *WriteFailure) {
+	select {
+	case s.queue <- webhookDelivery{ctx: ctx, failure: failure}:
+	default:
+		s.reportError(fmt.Errorf("webhook queue full, dropped failure of %q", failure.Path))
+		return
+	}
+	if s.running.CompareAndSwap(false, true) {
+		go s.deliver()
+	}
+}
+
+// deliver posts queued failures until the queue is empty
+func (s *webhookErrorSink) deliver() {
+	for {
+		select {
+		case d := <-s.queue:
+			if err := s.post(d.ctx, d.failure); err != nil {
+				s.reportError(err)
+			}
+		default:
+			s.running.Store(false)
+			// A failure queued after the queue was found empty but before running was cleared would
+			// otherwise wait for the next one
+			if len(s.queue) == 0 || !s.running.CompareAndSwap(false, true) {
+				return
+			}
+		}
+	}
+}
+
+// reportError passes err to onError, if set
+func (s *webhookErrorSink) reportError(err error) {
+	if s.onError != nil {
+		s.onError(err)
+	}
+}
+
+func (s *webhookErrorSink) post(ctx context.Context, failure *WriteFailure) error {
+	body, err := json.Marshal(failure)
+	if err != nil {
+		return fmt.Errorf("failed to marshal write failure: %w", err)
+	}
+
+	// Deliver even if the write context was cancelled, the failure is what we want to report
+	req, err := http.NewRequestWithContext(context.WithoutCancel(ctx), http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
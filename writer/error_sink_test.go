@@ -0,0 +1,105 @@
+package writer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelErrorSink(t *testing.T) {
+	ch := make(chan *WriteFailure, 1)
+	sink := NewChannelErrorSink(ch)
+
+	first := &WriteFailure{Path: "a", Class: ErrorClassStorage}
+	sink.OnWriteFailure(context.Background(), first)
+	// Channel is full, this one is dropped instead of blocking
+	sink.OnWriteFailure(context.Background(), &WriteFailure{Path: "b"})
+
+	assert.Same(t, first, <-ch)
+	assert.Empty(t, ch)
+}
+
+func TestWebhookErrorSink(t *testing.T) {
+	received := make(chan map[string]interface{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		received <- body
+	}))
+	defer server.Close()
+
+	sink := NewWebhookErrorSink(server.URL, nil, func(err error) {
+		t.Errorf("unexpected webhook error: %v", err)
+	})
+
+	// Cancelled write contexts must not prevent delivery
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sink.OnWriteFailure(ctx, &WriteFailure{
+		Path:     "metering/ru/1640995200/storage/pool1/tikv001-0.json.gz",
+		Attempts: 1,
+		Class:    ErrorClassConflict,
+		Err:      ErrFileExists,
+		Time:     time.Unix(1640995200, 0),
+	})
+
+	body := <-received
+	assert.Equal(t, "metering/ru/1640995200/storage/pool1/tikv001-0.json.gz", body["path"])
+	assert.Equal(t, "conflict", body["error_class"])
+	assert.Equal(t, ErrFileExists.Error(), body["error"])
+	assert.EqualValues(t, 1, body["attempts"])
+}
+
+func TestWebhookErrorSinkStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	webhookErrs := make(chan error, 1)
+	sink := NewWebhookErrorSink(server.URL, server.Client(), func(err error) { webhookErrs <- err })
+	sink.OnWriteFailure(context.Background(), &WriteFailure{Err: errors.New("boom")})
+
+	webhookErr := <-webhookErrs
+	require.Error(t, webhookErr)
+	assert.Contains(t, webhookErr.Error(), "status 500")
+}
+
+func TestWebhookErrorSinkQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	var delivered atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		delivered.Add(1)
+	}))
+	defer server.Close()
+
+	var dropped atomic.Int32
+	sink := NewWebhookErrorSink(server.URL, server.Client(), func(err error) {
+		assert.ErrorContains(t, err, "queue full")
+		dropped.Add(1)
+	})
+
+	// Reporting never waits for the webhook, failures beyond the queue are dropped
+	start := time.Now()
+	for range webhookQueueSize + 10 {
+		sink.OnWriteFailure(context.Background(), &WriteFailure{Err: errors.New("boom")})
+	}
+	assert.Less(t, time.Since(start), time.Second)
+	assert.GreaterOrEqual(t, dropped.Load(), int32(9))
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		return delivered.Load()+dropped.Load() == webhookQueueSize+10
+	}, 5*time.Second, 10*time.Millisecond)
+}
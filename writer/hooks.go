@@ -0,0 +1,56 @@
+package writer
+
+import (
+	"context"
+	"time"
+)
+
+// PageEvent describes a page file uploaded by a writer
+type PageEvent struct {
+	Path    string // path of the uploaded file
+	Part    int    // page number, 0 for unpaginated writes and metadata
+	Size    int    // size of the uploaded file in bytes
+	Records int    // number of data entries in the page, 0 if unknown, e.g. for raw pages
+}
+
+// FlushEvent describes a finished write call
+type FlushEvent struct {
+	Category  string        // category of the written data, may be empty for metadata
+	Timestamp int64         // timestamp of metering data, ModifyTS of metadata
+	Pages     int           // number of page files uploaded
+	Bytes     int64         // total size of the uploaded page files
+	Duration  time.Duration // duration of the call
+	Err       error         // error returned by the call, nil on success
+}
+
+// Hooks callbacks invoked by writers, so applications can emit their own metrics or alerts without
+// forking the writer. All callbacks are optional, must be safe for concurrent use and should return quickly.
+type Hooks struct {
+	// OnPageWritten is called after every page file is uploaded
+	OnPageWritten func(ctx context.Context, page *PageEvent)
+	// OnWriteError is called for every terminal write failure, like ErrorSink
+	OnWriteError func(ctx context.Context, failure *WriteFailure)
+	// OnFlush is called when a write call finishes, successfully or not
+	OnFlush func(ctx context.Context, flush *FlushEvent)
+}
+
+// PageWritten calls OnPageWritten, if set. Hooks may be nil
+func (h *Hooks) PageWritten(ctx context.Context, page *PageEvent) {
+	if h != nil && h.OnPageWritten != nil {
+		h.OnPageWritten(ctx, page)
+	}
+}
+
+// WriteError calls OnWriteError, if set. Hooks may be nil
+func (h *Hooks) WriteError(ctx context.Context, failure *WriteFailure) {
+	if h != nil && h.OnWriteError != nil {
+		h.OnWriteError(ctx, failure)
+	}
+}
+
+// Flush calls OnFlush, if set. Hooks may be nil
+func (h *Hooks) Flush(ctx context.Context, flush *FlushEvent) {
+	if h != nil && h.OnFlush != nil {
+		h.OnFlush(ctx, flush)
+	}
+}
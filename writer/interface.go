@@ -9,6 +9,20 @@ import (
 var (
 	// ErrFileExists error when file already exists
 	ErrFileExists = errors.New("file already exists")
+	// ErrStaleMetadata error when metadata is not newer than the stored metadata of the cluster
+	ErrStaleMetadata = errors.New("stale metadata")
+	// ErrValidation error when data failed validation before anything was written
+	ErrValidation = errors.New("validation failed")
+	// ErrSerialization error when data could not be serialized or compressed
+	ErrSerialization = errors.New("serialization failed")
+	// ErrStorage error when a storage provider operation failed, the provider error is wrapped as well
+	ErrStorage = errors.New("storage operation failed")
+	// ErrWriterClosed error when writing to a writer that has been shut down or closed
+	ErrWriterClosed = errors.New("writer closed")
+	// ErrLateRecord error when appending a record for a minute that has already been written
+	ErrLateRecord = errors.New("late record")
+	// ErrClockSkew error when a timestamp is out of the configured clock skew tolerance
+	ErrClockSkew = errors.New("timestamp out of clock skew tolerance")
 )
 
 // MetaWriter defines the meta writer interface
@@ -2,27 +2,40 @@ package metawriter
 
 import (
 	"bytes"
-	"compress/gzip"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"sync"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/pingcap/metering_sdk/common"
 	"github.com/pingcap/metering_sdk/config"
+	"github.com/pingcap/metering_sdk/internal/compress"
+	"github.com/pingcap/metering_sdk/logging"
 	"github.com/pingcap/metering_sdk/storage"
+	"github.com/pingcap/metering_sdk/tracing"
 	"github.com/pingcap/metering_sdk/writer"
 	"go.uber.org/zap"
 )
 
+// metricsLabel identifies this writer in metrics
+const metricsLabel = "meta"
+
+// metaFile metadata file structure, MetaData tagged with the format version it is written in
+type metaFile struct {
+	FormatVersion int `json:"format_version"`
+	*common.MetaData
+}
+
 // MetaWriter metadata writer
 type MetaWriter struct {
-	provider   storage.ObjectStorageProvider
-	config     *config.Config
-	logger     *zap.Logger
-	gzipWriter *gzip.Writer
-	buffer     *bytes.Buffer
-	mu         sync.Mutex // protects gzipWriter and buffer from concurrent access
+	provider storage.ObjectStorageProvider
+	config   *config.Config
+	logger   *zap.Logger
+	calls    *storage.CallCounter // storage requests made by this writer
+	closed   atomic.Bool          // writes fail with writer.ErrWriterClosed once set by Close
 }
 
 // NewMetaWriter creates a new metadata writer
@@ -31,133 +44,235 @@ func NewMetaWriter(provider storage.ObjectStorageProvider, cfg *config.Config) *
 		cfg = config.DefaultConfig()
 	}
 
-	buffer := &bytes.Buffer{}
-	gzipWriter := gzip.NewWriter(buffer)
+	// Uploads wait for a slot outside of tracing and metrics, which measure storage latency only
+	calls := &storage.CallCounter{}
+	instrumented := storage.NewUploadLimitedProvider(
+		cfg.WrapProvider(provider, calls),
+		storage.NewUploadLimiter(cfg.MaxConcurrentUploads),
+	)
 
 	return &MetaWriter{
-		provider:   provider,
-		config:     cfg,
-		logger:     cfg.GetLogger(),
-		gzipWriter: gzipWriter,
-		buffer:     buffer,
+		provider: instrumented,
+		config:   cfg,
+		logger:   cfg.ProviderLogger(provider),
+		calls:    calls,
 	}
 }
 
+// CallStats returns the storage requests this writer made, e.g. to estimate the object store API costs
+// of metering
+func (w *MetaWriter) CallStats() storage.CallStats {
+	return w.calls.Stats()
+}
+
 // Write implements Writer interface, writes metadata
 func (w *MetaWriter) Write(ctx context.Context, data interface{}) error {
+	return w.observedWrite(ctx, "MetaWriter.Write", data, false)
+}
+
+// WriteIfNewer writes metaData only if no metadata of the cluster with the same type and category has
+// a ModifyTS at or after metaData.ModifyTS, so out-of-order control-plane updates can't regress it.
+// Otherwise it fails with a conflict matching writer.ErrStaleMetadata. The check lists the stored
+// versions before uploading, concurrent writers of the same cluster are not serialized.
+func (w *MetaWriter) WriteIfNewer(ctx context.Context, metaData *common.MetaData) error {
+	return w.observedWrite(ctx, "MetaWriter.WriteIfNewer", metaData, true)
+}
+
+// observedWrite writes data with tracing, metrics and hooks, checking that it is newer if ifNewer is set
+func (w *MetaWriter) observedWrite(ctx context.Context, spanName string, data interface{}, ifNewer bool) error {
+	if w.closed.Load() {
+		return writer.ErrWriterClosed
+	}
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, w.config.TracerProvider, spanName)
+	ctx = w.config.UploadContext(ctx)
+	if metaData, ok := data.(*common.MetaData); ok {
+		span.SetAttributes(
+			tracing.AttributeClusterID.String(metaData.ClusterID),
+			tracing.AttributeCategory.String(metaData.Category),
+		)
+	}
+	page, err := w.write(ctx, data, ifNewer)
+	tracing.End(span, err)
+	w.config.Metrics.ObserveWrite(metricsLabel, start, err)
+	flush := &writer.FlushEvent{Duration: time.Since(start), Err: err}
+	if metaData, ok := data.(*common.MetaData); ok {
+		flush.Category = metaData.Category
+		flush.Timestamp = metaData.ModifyTS
+	}
+	if page != nil {
+		flush.Pages = 1
+		flush.Bytes = int64(page.Size)
+	}
+	w.config.Hooks.Flush(ctx, flush)
+	return err
+}
+
+// write validates and writes metadata, returning the uploaded file
+func (w *MetaWriter) write(ctx context.Context, data interface{}, ifNewer bool) (*writer.PageEvent, error) {
 	metaData, ok := data.(*common.MetaData)
 	if !ok {
-		return fmt.Errorf("invalid data type, expected *MetaData")
+		return nil, w.reportFailure(ctx, "", writer.ErrorClassValidation, fmt.Errorf("invalid data type, expected *MetaData"))
 	}
 
 	// Validate metadata type
 	if !common.ValidMetaTypes[metaData.Type] {
-		return fmt.Errorf("invalid metadata type: %s, must be one of: logic, sharedpool", metaData.Type)
+		return nil, w.reportFailure(ctx, "", writer.ErrorClassValidation, fmt.Errorf("invalid metadata type: %s, must be one of: logic, sharedpool", metaData.Type))
+	}
+	if err := w.config.ValidateMetaClusterID(metaData.ClusterID); err != nil {
+		return nil, w.reportFailure(ctx, "", writer.ErrorClassValidation, err)
 	}
 
 	// Build S3 path based on whether Category is set
-	var path string
+	var dir string
 	if metaData.Category != "" {
 		// Path with category: /metering/meta/{type}/{category}/{cluster_id}/{modify_ts}.json.gz
-		path = fmt.Sprintf("metering/meta/%s/%s/%s/%d.json.gz",
-			metaData.Type,
-			metaData.Category,
-			metaData.ClusterID,
-			metaData.ModifyTS,
-		)
+		dir = fmt.Sprintf("metering/meta/%s/%s/%s/", metaData.Type, metaData.Category, metaData.ClusterID)
 	} else {
 		// Path without category: /metering/meta/{type}/{cluster_id}/{modify_ts}.json.gz
-		path = fmt.Sprintf("metering/meta/%s/%s/%d.json.gz",
-			metaData.Type,
-			metaData.ClusterID,
-			metaData.ModifyTS,
-		)
+		dir = fmt.Sprintf("metering/meta/%s/%s/", metaData.Type, metaData.ClusterID)
 	}
+	path := fmt.Sprintf("%s%d.json.gz", dir, metaData.ModifyTS)
 
 	w.logger.Debug("Writing meta data",
-		zap.String("path", path),
+		logging.Path(path),
 		zap.String("cluster_id", metaData.ClusterID),
 		zap.String("type", string(metaData.Type)),
 		zap.String("category", metaData.Category),
 		zap.Int64("modify_ts", metaData.ModifyTS),
 	)
 
+	if ifNewer {
+		latest, err := w.latestModifyTS(ctx, dir)
+		if err != nil {
+			return nil, w.reportFailure(ctx, path, writer.ErrorClassStorage, fmt.Errorf("failed to list stored meta data: %w", err))
+		}
+		if latest >= metaData.ModifyTS {
+			w.logger.Warn("Stored meta data is newer, refusing to write",
+				logging.Path(path),
+				zap.Int64("stored_modify_ts", latest),
+			)
+			return nil, w.reportFailure(ctx, path, writer.ErrorClassConflict,
+				fmt.Errorf("%w: %s, stored modify_ts %d", writer.ErrStaleMetadata, path, latest))
+		}
+	}
+
 	// If overwrite is not allowed, check if file already exists
-	if !w.config.OverwriteExisting {
+	// With conditional put the provider rejects the upload itself, saving the Exists round-trip
+	conditional, useConditionalPut := w.provider.(storage.ConditionalUploader)
+	useConditionalPut = useConditionalPut && !w.config.DisableConditionalPut && !w.config.OverwriteExisting
+
+	if !w.config.OverwriteExisting && !useConditionalPut {
 		exists, err := w.provider.Exists(ctx, path)
 		if err != nil {
-			return fmt.Errorf("failed to check if file exists: %w", err)
+			return nil, w.reportFailure(ctx, path, writer.ErrorClassStorage, fmt.Errorf("failed to check if file exists: %w", err))
 		}
 		if exists {
 			w.logger.Warn("File already exists, refusing to overwrite",
-				zap.String("path", path),
+				logging.Path(path),
 			)
-			return fmt.Errorf("%w: %s", writer.ErrFileExists, path)
+			return nil, w.reportFailure(ctx, path, writer.ErrorClassConflict, fmt.Errorf("%w: %s", writer.ErrFileExists, path))
 		}
 	}
 
-	// Serialize data to JSON
-	jsonData, err := json.Marshal(metaData)
-	if err != nil {
-		return fmt.Errorf("failed to marshal meta data: %w", err)
-	}
-
-	// Compress data
-	compressedData, err := w.compressDataReuse(jsonData)
+	// Serialize and compress data
+	compressedData, err := compress.GzipJSON(&metaFile{FormatVersion: common.CurrentFormatVersion, MetaData: metaData})
 	if err != nil {
-		return fmt.Errorf("failed to compress data: %w", err)
+		return nil, w.reportFailure(ctx, path, writer.ErrorClassSerialization, fmt.Errorf("failed to serialize meta data: %w", err))
 	}
 
 	// Upload to storage
-	if err := w.provider.Upload(ctx, path, bytes.NewReader(compressedData)); err != nil {
-		return fmt.Errorf("failed to upload meta data: %w", err)
+	start := time.Now()
+	switch {
+	case w.config.DryRun:
+		w.logger.Info("Dry run, skipping upload",
+			logging.Path(path),
+			logging.Bytes(int64(len(compressedData))),
+		)
+	case useConditionalPut:
+		err = conditional.UploadIfNotExists(ctx, path, bytes.NewReader(compressedData))
+		if errors.Is(err, storage.ErrObjectExists) {
+			w.logger.Warn("File already exists, refusing to overwrite",
+				logging.Path(path),
+			)
+			return nil, w.reportFailure(ctx, path, writer.ErrorClassConflict, fmt.Errorf("%w: %s", writer.ErrFileExists, path))
+		}
+	default:
+		err = w.provider.Upload(ctx, path, bytes.NewReader(compressedData))
+	}
+	if err != nil {
+		return nil, w.reportFailure(ctx, path, writer.ErrorClassStorage, fmt.Errorf("failed to upload meta data: %w", err))
 	}
+	page := &writer.PageEvent{Path: path, Size: len(compressedData)}
+	w.config.Metrics.ObservePage(metricsLabel, page.Size)
+	w.config.Hooks.PageWritten(ctx, page)
 
 	w.logger.Info("Successfully wrote meta data",
-		zap.String("path", path),
-		zap.Int("size_bytes", len(compressedData)),
+		logging.Path(path),
+		logging.Bytes(int64(len(compressedData))),
+		logging.Since(start),
 	)
 
-	return nil
+	return page, nil
 }
 
-// Close implements Writer interface
-func (w *MetaWriter) Close() error {
-	w.mu.Lock()
-	defer w.mu.Unlock()
-
-	if w.gzipWriter != nil {
-		err := w.gzipWriter.Close()
-		w.gzipWriter = nil
-		return err
+// latestModifyTS returns the latest ModifyTS of the metadata files in dir, -1 if there is none
+func (w *MetaWriter) latestModifyTS(ctx context.Context, dir string) (int64, error) {
+	files, err := w.provider.List(ctx, dir)
+	if err != nil {
+		return 0, err
 	}
-	return nil
+	latest := int64(-1)
+	for _, file := range files {
+		// Listed keys carry the provider prefix, if any, in front of dir. Skip keys outside of dir and
+		// files of categories whose name is the cluster ID, which share the prefix
+		_, name, ok := strings.Cut(file, dir)
+		if !ok || strings.Contains(name, "/") {
+			continue
+		}
+		modifyTS, err := strconv.ParseInt(strings.TrimSuffix(name, ".json.gz"), 10, 64)
+		if err == nil && modifyTS > latest {
+			latest = modifyTS
+		}
+	}
+	return latest, nil
 }
 
-// compressDataReuse uses reusable gzip writer to compress data
-func (w *MetaWriter) compressDataReuse(data []byte) ([]byte, error) {
-	w.mu.Lock()
-	defer w.mu.Unlock()
-
-	// Reset buffer
-	w.buffer.Reset()
-
-	// Reset gzip writer to write to new buffer
-	w.gzipWriter.Reset(w.buffer)
+// Delete writes a tombstone for the metadata of the specified cluster and type, stamped with the
+// current time. Later reads return reader.ErrClusterDeleted instead of the previous metadata,
+// so readers can tell a deleted cluster from one without metadata yet. Writing new metadata
+// with a later ModifyTS revives the cluster.
+func (w *MetaWriter) Delete(ctx context.Context, clusterID string, metaType common.MetaType) error {
+	return w.Write(ctx, &common.MetaData{
+		ClusterID: clusterID,
+		Type:      metaType,
+		ModifyTS:  time.Now().Unix(),
+		Metadata:  map[string]interface{}{},
+		Deleted:   true,
+	})
+}
 
-	// Write data
-	if _, err := w.gzipWriter.Write(data); err != nil {
-		return nil, err
+// reportFailure notifies the configured error sink of a terminal write failure and returns it as a writer.WriteError
+func (w *MetaWriter) reportFailure(ctx context.Context, path string, class writer.ErrorClass, err error) error {
+	w.config.Metrics.ObserveWriteFailure(metricsLabel, string(class))
+	writeErr := &writer.WriteError{Class: class, Path: path, Err: err}
+	failure := &writer.WriteFailure{
+		Path:     path,
+		Attempts: 1,
+		Class:    class,
+		Err:      writeErr,
+		Time:     time.Now(),
 	}
-
-	// Close and flush data
-	if err := w.gzipWriter.Close(); err != nil {
-		return nil, err
+	if w.config.ErrorSink != nil {
+		w.config.ErrorSink.OnWriteFailure(ctx, failure)
 	}
+	w.config.Hooks.WriteError(ctx, failure)
+	return writeErr
+}
 
-	// Return copy of compressed data
-	result := make([]byte, w.buffer.Len())
-	copy(result, w.buffer.Bytes())
-
-	return result, nil
+// Close implements Writer interface. Later writes fail with writer.ErrWriterClosed, in-flight writes
+// are not waited for. Compression is pooled, there is nothing to release. Closing twice is a no-op.
+func (w *MetaWriter) Close() error {
+	w.closed.Store(true)
+	return nil
 }
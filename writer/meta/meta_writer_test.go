@@ -12,6 +12,7 @@ import (
 
 	"github.com/pingcap/metering_sdk/common"
 	"github.com/pingcap/metering_sdk/config"
+	"github.com/pingcap/metering_sdk/storage"
 	"github.com/pingcap/metering_sdk/writer"
 	"github.com/stretchr/testify/assert"
 )
@@ -62,6 +63,24 @@ func (m *MockStorageProvider) Exists(ctx context.Context, path string) (bool, er
 	return exists, nil
 }
 
+// ConditionalMockStorageProvider is a mock storage provider supporting conditional uploads
+type ConditionalMockStorageProvider struct {
+	*MockStorageProvider
+	existsCalls int
+}
+
+func (m *ConditionalMockStorageProvider) Exists(ctx context.Context, path string) (bool, error) {
+	m.existsCalls++
+	return m.MockStorageProvider.Exists(ctx, path)
+}
+
+func (m *ConditionalMockStorageProvider) UploadIfNotExists(ctx context.Context, path string, data io.Reader) error {
+	if _, exists := m.uploadedData[path]; exists {
+		return fmt.Errorf("%w: %s", storage.ErrObjectExists, path)
+	}
+	return m.Upload(ctx, path, data)
+}
+
 // decompressAndVerify decompresses data and verifies content
 func decompressAndVerify(t *testing.T, compressedData []byte, expectedJSON []byte) {
 	reader, err := gzip.NewReader(bytes.NewReader(compressedData))
@@ -119,12 +138,51 @@ func TestMetaWriterGzipReuse(t *testing.T) {
 			assert.True(t, exists, "Expected data not found at path: %s", expectedPath)
 
 			// Verify correctness of compressed data
-			originalJSON, _ := json.Marshal(data)
+			originalJSON, _ := json.Marshal(&metaFile{FormatVersion: common.CurrentFormatVersion, MetaData: data})
 			decompressAndVerify(t, uploadedData, originalJSON)
 		})
 	}
 }
 
+func TestMetaWriterClose(t *testing.T) {
+	metaWriter := NewMetaWriter(NewMockStorageProvider(), config.DefaultConfig())
+	assert.NoError(t, metaWriter.Close())
+	assert.NoError(t, metaWriter.Close(), "Closing twice should be a no-op")
+
+	err := metaWriter.Write(context.Background(), &common.MetaData{
+		ClusterID: "cluster-123",
+		Type:      common.MetaTypeLogic,
+		ModifyTS:  time.Now().Unix(),
+		Metadata:  map[string]interface{}{"env": "test"},
+	})
+	assert.ErrorIs(t, err, writer.ErrWriterClosed)
+	assert.ErrorIs(t, metaWriter.Delete(context.Background(), "cluster-123", common.MetaTypeLogic), writer.ErrWriterClosed)
+}
+
+func TestMetaWriterClusterIDValidation(t *testing.T) {
+	mockProvider := NewMockStorageProvider()
+	metaWriter := NewMetaWriter(mockProvider, config.DefaultConfig())
+	defer metaWriter.Close()
+
+	write := func(clusterID string) error {
+		return metaWriter.Write(context.Background(), &common.MetaData{
+			ClusterID: clusterID,
+			Type:      common.MetaTypeLogic,
+			ModifyTS:  time.Now().Unix(),
+			Metadata:  map[string]interface{}{"env": "test"},
+		})
+	}
+	assert.NoError(t, write("cluster:123"), "metadata cluster IDs are not restricted by default")
+	assert.Error(t, write(".."))
+	assert.Error(t, write("cluster/123"))
+
+	metaWriter = NewMetaWriter(mockProvider, config.DefaultConfig().WithClusterIDValidator(func(id string) error {
+		return fmt.Errorf("rejected")
+	}))
+	defer metaWriter.Close()
+	assert.Error(t, write("cluster123"), "the configured validator applies")
+}
+
 func TestMetaWriterConcurrency(t *testing.T) {
 	mockProvider := NewMockStorageProvider()
 	cfg := config.NewDebugConfig()
@@ -249,6 +307,48 @@ func TestMetaWriterFileExistsCheck(t *testing.T) {
 }
 
 // TestMetaTypeValidation tests metadata type validation
+func TestMetaWriterConditionalPut(t *testing.T) {
+	ctx := context.Background()
+	testData := &common.MetaData{
+		ClusterID: "cluster-123",
+		Type:      common.MetaTypeLogic,
+		ModifyTS:  time.Now().Unix(),
+		Metadata:  map[string]interface{}{"region": "us-west-2"},
+	}
+
+	t.Run("conditional put skips exists check by default", func(t *testing.T) {
+		mockProvider := &ConditionalMockStorageProvider{MockStorageProvider: NewMockStorageProvider()}
+		metaWriter := NewMetaWriter(mockProvider, config.DefaultConfig())
+		defer metaWriter.Close()
+
+		assert.NoError(t, metaWriter.Write(ctx, testData))
+		err := metaWriter.Write(ctx, testData)
+		assert.ErrorIs(t, err, writer.ErrFileExists)
+		assert.Equal(t, 0, mockProvider.existsCalls)
+	})
+
+	t.Run("exists check used when conditional put disabled", func(t *testing.T) {
+		mockProvider := &ConditionalMockStorageProvider{MockStorageProvider: NewMockStorageProvider()}
+		metaWriter := NewMetaWriter(mockProvider, config.DefaultConfig().WithConditionalPut(false))
+		defer metaWriter.Close()
+
+		assert.NoError(t, metaWriter.Write(ctx, testData))
+		err := metaWriter.Write(ctx, testData)
+		assert.ErrorIs(t, err, writer.ErrFileExists)
+		assert.Equal(t, 2, mockProvider.existsCalls)
+	})
+
+	t.Run("falls back to exists check without provider support", func(t *testing.T) {
+		mockProvider := NewMockStorageProvider()
+		metaWriter := NewMetaWriter(mockProvider, config.DefaultConfig())
+		defer metaWriter.Close()
+
+		assert.NoError(t, metaWriter.Write(ctx, testData))
+		err := metaWriter.Write(ctx, testData)
+		assert.ErrorIs(t, err, writer.ErrFileExists)
+	})
+}
+
 func TestMetaTypeValidation(t *testing.T) {
 	mockProvider := NewMockStorageProvider()
 	cfg := config.NewDebugConfig()
@@ -339,7 +439,7 @@ func TestMetaWriterWithCategory(t *testing.T) {
 		assert.True(t, exists, "Expected data not found at path: %s", expectedPath)
 
 		// Verify correctness of compressed data
-		originalJSON, _ := json.Marshal(testData)
+		originalJSON, _ := json.Marshal(&metaFile{FormatVersion: common.CurrentFormatVersion, MetaData: testData})
 		decompressAndVerify(t, uploadedData, originalJSON)
 	})
 
@@ -365,7 +465,7 @@ func TestMetaWriterWithCategory(t *testing.T) {
 		assert.True(t, exists, "Expected data not found at path: %s", expectedPath)
 
 		// Verify correctness of compressed data
-		originalJSON, _ := json.Marshal(testData)
+		originalJSON, _ := json.Marshal(&metaFile{FormatVersion: common.CurrentFormatVersion, MetaData: testData})
 		decompressAndVerify(t, uploadedData, originalJSON)
 	})
 
@@ -406,3 +506,109 @@ func TestMetaWriterWithCategory(t *testing.T) {
 		assert.Equal(t, 3, count, "Expected 3 files for different categories")
 	})
 }
+
+// TestMetaWriterDelete tests that Delete writes a tombstone
+func TestMetaWriterDelete(t *testing.T) {
+	mockProvider := NewMockStorageProvider()
+	metaWriter := NewMetaWriter(mockProvider, config.DefaultConfig())
+	defer metaWriter.Close()
+
+	ctx := context.Background()
+	assert.NoError(t, metaWriter.Delete(ctx, "cluster-deleted", common.MetaTypeLogic))
+	assert.Len(t, mockProvider.uploadedData, 1)
+
+	for path, compressed := range mockProvider.uploadedData {
+		assert.Contains(t, path, "metering/meta/logic/cluster-deleted/")
+		gzipReader, err := gzip.NewReader(bytes.NewReader(compressed))
+		assert.NoError(t, err)
+		var tombstone common.MetaData
+		assert.NoError(t, json.NewDecoder(gzipReader).Decode(&tombstone))
+		assert.True(t, tombstone.Deleted)
+		assert.Equal(t, "cluster-deleted", tombstone.ClusterID)
+	}
+
+	err := metaWriter.Delete(ctx, "cluster-deleted", common.MetaType("invalid"))
+	assert.ErrorIs(t, err, writer.ErrValidation)
+}
+
+func TestMetaWriterDryRun(t *testing.T) {
+	mockProvider := NewMockStorageProvider()
+	metaWriter := NewMetaWriter(mockProvider, config.DefaultConfig().WithDryRun(true))
+	defer metaWriter.Close()
+
+	ctx := context.Background()
+	assert.NoError(t, metaWriter.Write(ctx, &common.MetaData{
+		ClusterID: "cluster-dry-run",
+		Type:      common.MetaTypeLogic,
+		ModifyTS:  1640995200,
+		Metadata:  map[string]interface{}{"env": "staging"},
+	}))
+	assert.Empty(t, mockProvider.uploadedData)
+}
+
+func TestMetaWriterWriteIfNewer(t *testing.T) {
+	mockProvider := NewMockStorageProvider()
+	metaWriter := NewMetaWriter(mockProvider, config.DefaultConfig().WithOverwriteExisting(true))
+	defer metaWriter.Close()
+	ctx := context.Background()
+
+	meta := func(category string, modifyTS int64) *common.MetaData {
+		return &common.MetaData{
+			ClusterID: "cluster-123",
+			Type:      common.MetaTypeLogic,
+			Category:  category,
+			ModifyTS:  modifyTS,
+			Metadata:  map[string]interface{}{"region": "us-west-2"},
+		}
+	}
+
+	assert.NoError(t, metaWriter.WriteIfNewer(ctx, meta("", 1755849600)))
+	assert.NoError(t, metaWriter.WriteIfNewer(ctx, meta("", 1755849660)))
+
+	// Older and equal updates are refused, even with overwrites enabled
+	for _, modifyTS := range []int64{1755849600, 1755849630, 1755849660} {
+		err := metaWriter.WriteIfNewer(ctx, meta("", modifyTS))
+		assert.ErrorIs(t, err, writer.ErrStaleMetadata, "modify_ts %d", modifyTS)
+		var writeErr *writer.WriteError
+		assert.ErrorAs(t, err, &writeErr)
+		assert.Equal(t, writer.ErrorClassConflict, writeErr.Class)
+	}
+	assert.Len(t, mockProvider.uploadedData, 2)
+
+	// Categories are versioned separately, including one named like the cluster
+	assert.NoError(t, metaWriter.WriteIfNewer(ctx, meta("cluster-123", 1755849600)))
+	assert.NoError(t, metaWriter.WriteIfNewer(ctx, meta("tidb", 1755849600)))
+	assert.NoError(t, metaWriter.WriteIfNewer(ctx, meta("", 1755849720)))
+}
+
+// prefixedListProvider lists keys with a provider prefix, like S3 with a configured prefix, and a key
+// outside of the requested prefix
+type prefixedListProvider struct {
+	*MockStorageProvider
+}
+
+func (p *prefixedListProvider) List(ctx context.Context, prefix string) ([]string, error) {
+	paths, err := p.MockStorageProvider.List(ctx, prefix)
+	for i := range paths {
+		paths[i] = "tenant/" + paths[i]
+	}
+	return append(paths, "unrelated/9999999999.json.gz"), err
+}
+
+func TestMetaWriterWriteIfNewerPrefixedKeys(t *testing.T) {
+	metaWriter := NewMetaWriter(&prefixedListProvider{NewMockStorageProvider()}, config.DefaultConfig())
+	defer metaWriter.Close()
+	ctx := context.Background()
+
+	meta := func(modifyTS int64) *common.MetaData {
+		return &common.MetaData{
+			ClusterID: "cluster-123",
+			Type:      common.MetaTypeLogic,
+			ModifyTS:  modifyTS,
+			Metadata:  map[string]interface{}{"region": "us-west-2"},
+		}
+	}
+	assert.NoError(t, metaWriter.WriteIfNewer(ctx, meta(1755849660)))
+	assert.ErrorIs(t, metaWriter.WriteIfNewer(ctx, meta(1755849600)), writer.ErrStaleMetadata)
+	assert.NoError(t, metaWriter.WriteIfNewer(ctx, meta(1755849720)), "keys outside of the cluster are ignored")
+}
@@ -0,0 +1,226 @@
+package meteringwriter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/pingcap/metering_sdk/writer"
+	"go.uber.org/zap"
+)
+
+// maxRetriedMinutes number of failed minutes an appendKey keeps for retry, older ones are dropped
+const maxRetriedMinutes = 60
+
+// retryKey marks the context of a write retrying appended records, see retried
+type retryKey struct{}
+
+// withRetry returns ctx marking the write as a retry of appended records
+func withRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryKey{}, true)
+}
+
+// errPageWritten returned instead of a conflict by uploads of retried pages that already exist
+var errPageWritten = errors.New("page already written")
+
+// retried reports whether ctx belongs to a write retrying appended records. Pages of a retried minute
+// that already exist were uploaded by the failed attempt, they are skipped instead of failing the write.
+func retried(ctx context.Context) bool {
+	retry, _ := ctx.Value(retryKey{}).(bool)
+	return retry
+}
+
+// retryable reports whether a failed write of appended records may succeed when retried. Validation and
+// serialization failures never do, nor do conflicts alone: the minute was written by someone else.
+func retryable(err error) bool {
+	if errors.Is(err, writer.ErrValidation) || errors.Is(err, writer.ErrSerialization) {
+		return false
+	}
+	return errors.Is(err, writer.ErrStorage) || !errors.Is(err, writer.ErrFileExists)
+}
+
+// appendKey identifies the files records are appended to
+type appendKey struct {
+	category string
+	selfID   string
+}
+
+// appendBuffer the records appended for the open minute of an appendKey
+type appendBuffer struct {
+	timestamp int64                    // open minute
+	flushed   int64                    // last flushed minute, later records for it are rejected
+	records   []map[string]interface{} // records of the open minute
+	failed    []*common.MeteringData   // closed minutes that failed to be written, retried by the next write
+}
+
+// take closes the open minute, returning the earlier minutes that failed to be written and the records of
+// the open minute, if any, as metering data
+func (b *appendBuffer) take(key appendKey) (failed []*common.MeteringData, closed *common.MeteringData) {
+	b.flushed = b.timestamp
+	failed = b.failed
+	b.failed = nil
+	if len(b.records) == 0 {
+		return failed, nil
+	}
+	closed = &common.MeteringData{
+		Timestamp: b.timestamp,
+		Category:  key.category,
+		SelfID:    key.selfID,
+		Data:      b.records,
+	}
+	b.records = nil
+	return failed, closed
+}
+
+// backlog returns the number of records of failed minutes waiting to be retried
+func (b *appendBuffer) backlog() int {
+	n := 0
+	for _, data := range b.failed {
+		n += len(data.Data)
+	}
+	return n
+}
+
+// AppendRecord appends the record of one logical cluster to the metering data of category and selfID for
+// the minute timestamp. Records are buffered and written, paginated like Write, once a record for a later
+// minute is appended, or by Flush, Shutdown and Close. The error of writing the previous minute is returned
+// by the append that rolled it over; the appended record is buffered regardless and, if the failure is
+// retryable, the records of the previous minute are kept for the next write, see RetryBacklog. Records for a minute that has already been written are
+// rejected with writer.ErrLateRecord. The record must not be modified afterwards.
+func (w *MeteringWriter) AppendRecord(ctx context.Context, timestamp int64, category, selfID string, record map[string]interface{}) error {
+	timestamp = w.timestamp(timestamp)
+	// Validated up front, so the caller gets the error instead of the write of its minute
+	if err := w.validateData(timestamp, category, selfID, []map[string]interface{}{record}); err != nil {
+		return w.reportFailure(ctx, "", writer.ErrorClassValidation, err)
+	}
+
+	key := appendKey{category: category, selfID: selfID}
+	w.appendMu.Lock()
+	if w.appendClosed {
+		w.appendMu.Unlock()
+		return writer.ErrWriterClosed
+	}
+	buf, ok := w.appended[key]
+	if !ok {
+		buf = &appendBuffer{timestamp: timestamp}
+		w.appended[key] = buf
+	}
+	if timestamp <= buf.flushed || timestamp < buf.timestamp {
+		open := buf.timestamp
+		w.appendMu.Unlock()
+		return w.reportFailure(ctx, "", writer.ErrorClassValidation,
+			fmt.Errorf("%w: timestamp %d of %s/%s, the open minute is %d", writer.ErrLateRecord, timestamp, category, selfID, open))
+	}
+	var failed []*common.MeteringData
+	var closed *common.MeteringData
+	if timestamp > buf.timestamp {
+		failed, closed = buf.take(key)
+		buf.timestamp = timestamp
+	}
+	buf.records = append(buf.records, record)
+	w.appendMu.Unlock()
+
+	if closed != nil {
+		w.logger.Debug("Minute rolled over, writing appended records",
+			zap.Int64("timestamp", closed.Timestamp),
+			zap.String("category", category),
+			zap.Int("records", len(closed.Data)),
+		)
+	}
+	_, err := w.writeAppended(ctx, key, failed, closed, true)
+	return err
+}
+
+// writeAppended writes the failed minutes of key, as retries, and the closed minute, if not nil. With
+// keep, minutes that fail retryably are kept for the next write. It returns the number of records that
+// failed to be written.
+func (w *MeteringWriter) writeAppended(ctx context.Context, key appendKey, failed []*common.MeteringData, closed *common.MeteringData, keep bool) (int, error) {
+	dropped := 0
+	var errs []error
+	write := func(ctx context.Context, data *common.MeteringData) {
+		err := w.Write(ctx, data)
+		if err == nil {
+			return
+		}
+		dropped += len(data.Data)
+		errs = append(errs, fmt.Errorf("failed to write appended records of %s/%s at %d: %w", data.Category, data.SelfID, data.Timestamp, err))
+		if keep && retryable(err) {
+			w.restore(key, data)
+		}
+	}
+	for _, data := range failed {
+		write(withRetry(ctx), data)
+	}
+	if closed != nil {
+		write(ctx, closed)
+	}
+	return dropped, errors.Join(errs...)
+}
+
+// restore puts back appended records that failed to be written, so the next write retries them. Beyond
+// maxRetriedMinutes failed minutes, the oldest is dropped.
+func (w *MeteringWriter) restore(key appendKey, data *common.MeteringData) {
+	w.appendMu.Lock()
+	defer w.appendMu.Unlock()
+	buf := w.appended[key]
+	buf.failed = append(buf.failed, data)
+	if len(buf.failed) > maxRetriedMinutes {
+		w.logger.Error("Too many appended minutes failed to be written, dropping the oldest",
+			zap.Int64("timestamp", buf.failed[0].Timestamp),
+			zap.String("category", key.category),
+			zap.Int("records", len(buf.failed[0].Data)),
+		)
+		buf.failed = slices.Delete(buf.failed, 0, 1)
+	}
+}
+
+// RetryBacklog returns the number of appended records that failed to be written and are waiting to be
+// retried by the next roll-over or Flush
+func (w *MeteringWriter) RetryBacklog() int {
+	w.appendMu.Lock()
+	defer w.appendMu.Unlock()
+	n := 0
+	for _, buf := range w.appended {
+		n += buf.backlog()
+	}
+	return n
+}
+
+// Flush writes the records appended for every open minute, and retries those of minutes that failed to be
+// written before. The minutes are closed, later records for them are rejected. Records that fail to be
+// written retryably, i.e. not with a validation, serialization or conflict error, are kept for the next
+// write; errors are returned joined.
+func (w *MeteringWriter) Flush(ctx context.Context) error {
+	_, err := w.flushAppended(ctx, false)
+	return err
+}
+
+// flushAppended writes the records appended for every open minute, returning the number of records that
+// failed to be written. With closeAppends, later appends fail with writer.ErrWriterClosed and the failed
+// records are dropped, otherwise they are kept for the next write.
+func (w *MeteringWriter) flushAppended(ctx context.Context, closeAppends bool) (int, error) {
+	w.appendMu.Lock()
+	w.appendClosed = w.appendClosed || closeAppends
+	type pendingMinutes struct {
+		failed []*common.MeteringData
+		closed *common.MeteringData
+	}
+	pending := make(map[appendKey]pendingMinutes, len(w.appended))
+	for key, buf := range w.appended {
+		if failed, closed := buf.take(key); len(failed) > 0 || closed != nil {
+			pending[key] = pendingMinutes{failed: failed, closed: closed}
+		}
+	}
+	w.appendMu.Unlock()
+
+	dropped := 0
+	var errs []error
+	for key, minutes := range pending {
+		n, err := w.writeAppended(ctx, key, minutes.failed, minutes.closed, !closeAppends)
+		dropped += n
+		errs = append(errs, err)
+	}
+	return dropped, errors.Join(errs...)
+}
@@ -1,17 +1,27 @@
 package meteringwriter
 
 import (
+	"bufio"
 	"bytes"
-	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/pingcap/metering_sdk/codec"
 	"github.com/pingcap/metering_sdk/common"
 	"github.com/pingcap/metering_sdk/config"
+	"github.com/pingcap/metering_sdk/internal/compress"
 	"github.com/pingcap/metering_sdk/internal/utils"
+	"github.com/pingcap/metering_sdk/layout"
+	"github.com/pingcap/metering_sdk/logging"
+	meteringreader "github.com/pingcap/metering_sdk/reader/metering"
 	"github.com/pingcap/metering_sdk/storage"
+	"github.com/pingcap/metering_sdk/tracing"
 	"github.com/pingcap/metering_sdk/writer"
 	"go.uber.org/zap"
 )
@@ -19,16 +29,30 @@ import (
 const (
 	// DefaultSharedPoolID is the default shared pool ID used when none is specified
 	DefaultSharedPoolID = "default-shared-pool"
+	// metricsLabel identifies this writer in metrics
+	metricsLabel = "metering"
 )
 
 // pageMeteringData paginated metering data structure
 type pageMeteringData struct {
-	Timestamp    int64                    `json:"timestamp"`      // minute-level timestamp
-	Category     string                   `json:"category"`       // service category identifier
-	SelfID       string                   `json:"self_id"`        // component ID
-	SharedPoolID string                   `json:"shared_pool_id"` // shared pool cluster ID
-	Part         int                      `json:"part"`           // pagination number
-	Data         []map[string]interface{} `json:"data"`           // current page logical cluster metering data
+	FormatVersion int               `json:"format_version"` // common.CurrentFormatVersion, first so streaming readers check it before data
+	Timestamp     int64             `json:"timestamp"`      // minute-level timestamp
+	Category      string            `json:"category"`       // service category identifier
+	SelfID        string            `json:"self_id"`        // component ID
+	SharedPoolID  string            `json:"shared_pool_id"` // shared pool cluster ID
+	Part          int               `json:"part"`           // pagination number
+	Data          []json.RawMessage `json:"data"`           // current page logical cluster metering data, marshaled once
+
+	entries []map[string]interface{} // unmarshaled Data, encoded by codecs other than JSON
+}
+
+// marshalEntry marshals a logical cluster entry for pageMeteringData
+func marshalEntry(entry map[string]interface{}) (json.RawMessage, error) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal logical cluster data: %w", err)
+	}
+	return data, nil
 }
 
 // MeteringWriter metering data writer
@@ -36,10 +60,22 @@ type MeteringWriter struct {
 	provider     storage.ObjectStorageProvider
 	config       *config.Config
 	logger       *zap.Logger
-	gzipWriter   *gzip.Writer
-	buffer       *bytes.Buffer
-	mu           sync.Mutex // protects gzipWriter and buffer from concurrent access
-	sharedPoolID string     // shared pool cluster ID for path construction
+	sharedPoolID string      // shared pool cluster ID for path construction
+	codec        codec.Codec // serializes pages
+	stagedMu     sync.Mutex
+	staged       map[int64][]string // staged paths by timestamp, in staging mode
+	appendMu     sync.Mutex
+	appended     map[appendKey]*appendBuffer // records appended by AppendRecord
+	appendClosed bool                        // appends are refused once Shutdown has been called
+
+	lifecycleMu     sync.Mutex
+	shuttingDown    bool
+	inflight        sync.WaitGroup // in-flight calls, waited for by Shutdown
+	inflightRecords atomic.Int64   // data entries of in-flight writes
+	abort           context.Context
+	abortWrites     context.CancelFunc // cancels in-flight calls once the Shutdown deadline is exceeded
+
+	calls *storage.CallCounter // storage requests made by this writer
 }
 
 var _ writer.MeteringWriter = (*MeteringWriter)(nil)
@@ -55,19 +91,34 @@ func NewMeteringWriterWithSharedPool(provider storage.ObjectStorageProvider, cfg
 		cfg = config.DefaultConfig()
 	}
 
-	buffer := &bytes.Buffer{}
-	gzipWriter := gzip.NewWriter(buffer)
+	// Uploads wait for a slot outside of tracing and metrics, which measure storage latency only
+	calls := &storage.CallCounter{}
+	instrumented := storage.NewUploadLimitedProvider(
+		cfg.WrapProvider(provider, calls),
+		storage.NewUploadLimiter(cfg.MaxConcurrentUploads),
+	)
 
+	abort, abortWrites := context.WithCancel(context.Background())
 	return &MeteringWriter{
-		provider:     provider,
+		provider:     instrumented,
 		config:       cfg,
-		logger:       cfg.GetLogger(),
-		gzipWriter:   gzipWriter,
-		buffer:       buffer,
+		logger:       cfg.ProviderLogger(provider),
 		sharedPoolID: sharedPoolID,
+		codec:        cfg.GetCodec(),
+		staged:       make(map[int64][]string),
+		appended:     make(map[appendKey]*appendBuffer),
+		abort:        abort,
+		abortWrites:  abortWrites,
+		calls:        calls,
 	}
 }
 
+// CallStats returns the storage requests this writer made, including retries, e.g. to estimate the
+// object store API costs of metering
+func (w *MeteringWriter) CallStats() storage.CallStats {
+	return w.calls.Stats()
+}
+
 // NewMeteringWriterFromConfig creates a new metering data writer from MeteringConfig
 func NewMeteringWriterFromConfig(provider storage.ObjectStorageProvider, cfg *config.Config, meteringConfig *config.MeteringConfig) *MeteringWriter {
 	var sharedPoolID string
@@ -85,9 +136,95 @@ func NewMeteringWriterFromConfig(provider storage.ObjectStorageProvider, cfg *co
 
 // Write implements Writer interface, writes metering data
 func (w *MeteringWriter) Write(ctx context.Context, data interface{}) error {
+	records := 0
+	if meteringData, ok := data.(*common.MeteringData); ok {
+		records = len(meteringData.Data)
+		meteringData.Timestamp = w.timestamp(meteringData.Timestamp)
+	}
+	ctx, end, err := w.begin(ctx, records)
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, w.config.TracerProvider, "MeteringWriter.Write")
+	ctx = w.uploadContext(ctx, w.codec)
+	if meteringData, ok := data.(*common.MeteringData); ok {
+		span.SetAttributes(
+			tracing.AttributeCategory.String(meteringData.Category),
+			tracing.AttributeTimestamp.Int64(meteringData.Timestamp),
+		)
+	}
+	stats := &writeStats{}
+	err = w.write(ctx, data, stats)
+	tracing.End(span, err)
+	w.config.Metrics.ObserveWrite(metricsLabel, start, err)
+	flush := stats.flushEvent(start, err)
+	if meteringData, ok := data.(*common.MeteringData); ok {
+		flush.Category = meteringData.Category
+		flush.Timestamp = meteringData.Timestamp
+	}
+	w.config.Hooks.Flush(ctx, flush)
+	return err
+}
+
+// timestamp returns the timestamp data is written at, truncated to its minute with AutoTruncateTimestamp
+func (w *MeteringWriter) timestamp(timestamp int64) int64 {
+	if w.config.AutoTruncateTimestamp {
+		return utils.TruncateTimestamp(timestamp)
+	}
+	return timestamp
+}
+
+// checkClockSkew checks timestamp is within the configured clock skew tolerance of the current minute,
+// only logging timestamps out of it in WarnOnly mode
+func (w *MeteringWriter) checkClockSkew(timestamp int64) error {
+	skew := w.config.ClockSkew
+	if skew.MaxFuture <= 0 && skew.MaxPast <= 0 {
+		return nil
+	}
+	current := utils.GetCurrentMinuteTimestamp()
+	offset := time.Duration(timestamp-current) * time.Second
+	var err error
+	switch {
+	case skew.MaxFuture > 0 && offset > skew.MaxFuture:
+		err = fmt.Errorf("%w: timestamp %d is %s in the future, tolerance %s", writer.ErrClockSkew, timestamp, offset, skew.MaxFuture)
+	case skew.MaxPast > 0 && -offset > skew.MaxPast:
+		err = fmt.Errorf("%w: timestamp %d is %s in the past, tolerance %s", writer.ErrClockSkew, timestamp, -offset, skew.MaxPast)
+	}
+	if err != nil && skew.WarnOnly {
+		w.logger.Warn("Metering timestamp out of clock skew tolerance",
+			zap.Int64("timestamp", timestamp),
+			zap.Int64("current_minute", current),
+			zap.Duration("offset", offset),
+		)
+		return nil
+	}
+	return err
+}
+
+// writeStats counts the pages uploaded by a write call
+type writeStats struct {
+	pages atomic.Int32
+	bytes atomic.Int64
+}
+
+// flushEvent returns the writer.FlushEvent of a write call started at start
+func (s *writeStats) flushEvent(start time.Time, err error) *writer.FlushEvent {
+	return &writer.FlushEvent{
+		Pages:    int(s.pages.Load()),
+		Bytes:    s.bytes.Load(),
+		Duration: time.Since(start),
+		Err:      err,
+	}
+}
+
+// write validates and writes metering data, paginating if configured
+func (w *MeteringWriter) write(ctx context.Context, data interface{}, stats *writeStats) error {
 	meteringData, ok := data.(*common.MeteringData)
 	if !ok {
-		return fmt.Errorf("invalid data type, expected *MeteringData")
+		return w.reportFailure(ctx, "", writer.ErrorClassValidation, fmt.Errorf("invalid data type, expected *MeteringData"))
 	}
 
 	// Fill SharedPoolID from writer configuration if not set
@@ -97,16 +234,11 @@ func (w *MeteringWriter) Write(ctx context.Context, data interface{}) error {
 
 	// Validate that SharedPoolID is not empty
 	if meteringData.SharedPoolID == "" {
-		return fmt.Errorf("SharedPoolID is required and cannot be empty")
+		return w.reportFailure(ctx, "", writer.ErrorClassValidation, fmt.Errorf("SharedPoolID is required and cannot be empty"))
 	}
 
-	// Validate IDs do not contain hyphens
-	if err := utils.ValidateSelfID(meteringData.SelfID); err != nil {
-		return err
-	}
-	// Validate timestamp is minute-level
-	if err := utils.ValidateTimestamp(meteringData.Timestamp); err != nil {
-		return err
+	if err := w.validateData(meteringData.Timestamp, meteringData.Category, meteringData.SelfID, meteringData.Data); err != nil {
+		return w.reportFailure(ctx, "", writer.ErrorClassValidation, err)
 	}
 
 	w.logger.Debug("Writing metering data",
@@ -118,15 +250,47 @@ func (w *MeteringWriter) Write(ctx context.Context, data interface{}) error {
 
 	// Check if pagination is needed
 	if w.config.PageSizeBytes > 0 {
-		return w.writeWithPagination(ctx, meteringData)
+		return w.writeWithPagination(ctx, meteringData, stats)
 	} else {
 		// No pagination, write all data to a single file
-		return w.writeSinglePage(ctx, meteringData)
+		return w.writeSinglePage(ctx, meteringData, stats)
+	}
+}
+
+// validateData validates the self ID, timestamp and data entries of metering data
+func (w *MeteringWriter) validateData(timestamp int64, category, selfID string, data []map[string]interface{}) error {
+	// Validate IDs do not contain hyphens
+	if err := w.config.ValidateSelfID(selfID); err != nil {
+		return err
+	}
+	// Validate timestamp is minute-level
+	if err := utils.ValidateTimestamp(timestamp); err != nil {
+		return err
+	}
+	if err := w.checkClockSkew(timestamp); err != nil {
+		return err
+	}
+	// Validate metering values are serializable
+	if err := validateMeteringValues(data); err != nil {
+		return err
+	}
+	// Validate data entries against the category schema, if any
+	if w.config.Schemas != nil {
+		if err := w.config.Schemas.Validate(category, data); err != nil {
+			return err
+		}
+	}
+	if w.config.Units != nil {
+		if err := w.config.Units.Validate(data); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-// writeWithPagination writes paginated data
-func (w *MeteringWriter) writeWithPagination(ctx context.Context, meteringData *common.MeteringData) error {
+// writeWithPagination writes paginated data. With UploadConcurrency > 1 pages are uploaded in
+// parallel; part numbers still follow the order of the data, and every upload failure is returned.
+func (w *MeteringWriter) writeWithPagination(ctx context.Context, meteringData *common.MeteringData, stats *writeStats) error {
 	// Pre-allocate currentPage with an estimated capacity to reduce allocations
 	// Estimate based on total data length, but cap at a reasonable maximum
 	estimatedPageSize := len(meteringData.Data) / 10 // rough estimate
@@ -135,60 +299,66 @@ func (w *MeteringWriter) writeWithPagination(ctx context.Context, meteringData *
 	} else if estimatedPageSize > 100 {
 		estimatedPageSize = 100
 	}
-	currentPage := make([]map[string]interface{}, 0, estimatedPageSize)
+	currentPage := make([]json.RawMessage, 0, estimatedPageSize)
 	var currentSize int64
 	pageNum := 0
 
-	for _, logicalCluster := range meteringData.Data {
-		// Calculate current logical cluster data size
-		clusterJSON, err := json.Marshal(logicalCluster)
+	uploads := newPageUploads(w.config.UploadConcurrency)
+	pageStart := 0 // index of the first logical cluster of the current page
+	writePage := func(data []json.RawMessage, end int) error {
+		pageData := &pageMeteringData{
+			FormatVersion: common.CurrentFormatVersion,
+			Timestamp:     meteringData.Timestamp,
+			Category:      meteringData.Category,
+			SelfID:        meteringData.SelfID,
+			SharedPoolID:  meteringData.SharedPoolID,
+			Part:          pageNum,
+			Data:          data,
+			entries:       meteringData.Data[pageStart:end],
+		}
+		pageStart = end
+		return uploads.run(func() error { return w.writePageData(ctx, pageData, stats) })
+	}
+
+	for i, logicalCluster := range meteringData.Data {
+		// Marshal the logical cluster once, both to size and to write it
+		clusterJSON, err := marshalEntry(logicalCluster)
 		if err != nil {
-			return fmt.Errorf("failed to marshal logical cluster data: %w", err)
+			return errors.Join(uploads.wait(), w.reportFailure(ctx, "", writer.ErrorClassSerialization, err))
 		}
 		clusterSize := int64(len(clusterJSON))
 
 		// Check if a new page needs to be created
 		if len(currentPage) > 0 && currentSize+clusterSize > w.config.PageSizeBytes {
 			// Write current page
-			pageData := &pageMeteringData{
-				Timestamp:    meteringData.Timestamp,
-				Category:     meteringData.Category,
-				SelfID:       meteringData.SelfID,
-				SharedPoolID: meteringData.SharedPoolID,
-				Part:         pageNum,
-				Data:         currentPage,
-			}
-
-			if err := w.writePageData(ctx, pageData); err != nil {
+			if err := writePage(currentPage, i); err != nil {
 				return err
 			}
 
-			// Reset current page with pre-allocated capacity
-			currentPage = currentPage[:0] // reuse underlying array
+			// Reset current page, reusing the underlying array unless it may still be uploading
+			if uploads.sequential() {
+				currentPage = currentPage[:0]
+			} else {
+				currentPage = make([]json.RawMessage, 0, estimatedPageSize)
+			}
 			currentSize = 0
 			pageNum++
 		}
 
 		// Add logical cluster to current page
-		currentPage = append(currentPage, logicalCluster)
+		currentPage = append(currentPage, clusterJSON)
 		currentSize += clusterSize
 	}
 
 	// Write last page (if there is data)
 	if len(currentPage) > 0 {
-		pageData := &pageMeteringData{
-			Timestamp:    meteringData.Timestamp,
-			Category:     meteringData.Category,
-			SelfID:       meteringData.SelfID,
-			SharedPoolID: meteringData.SharedPoolID,
-			Part:         pageNum,
-			Data:         currentPage,
-		}
-
-		if err := w.writePageData(ctx, pageData); err != nil {
+		if err := writePage(currentPage, len(meteringData.Data)); err != nil {
 			return err
 		}
 	}
+	if err := uploads.wait(); err != nil {
+		return err
+	}
 
 	w.logger.Info("Successfully wrote metering data with pagination",
 		zap.Int("total_pages", pageNum+1),
@@ -198,118 +368,557 @@ func (w *MeteringWriter) writeWithPagination(ctx context.Context, meteringData *
 	return nil
 }
 
+// pageUploads runs the page uploads of a paginated write, in parallel if concurrency > 1
+type pageUploads struct {
+	slots chan struct{} // nil when uploading sequentially
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+	errs  []error
+}
+
+// newPageUploads creates a page upload runner allowing concurrency parallel uploads
+func newPageUploads(concurrency int) *pageUploads {
+	u := &pageUploads{}
+	if concurrency > 1 {
+		u.slots = make(chan struct{}, concurrency)
+	}
+	return u
+}
+
+// sequential reports whether uploads run one after the other
+func (u *pageUploads) sequential() bool {
+	return u.slots == nil
+}
+
+// run uploads a page. Sequential uploads return their error directly; parallel ones are started in
+// the background once a slot is free, and after a failure run waits for the started uploads and
+// returns every error instead of starting more.
+func (u *pageUploads) run(upload func() error) error {
+	if u.sequential() {
+		return upload()
+	}
+	if u.failed() {
+		return u.wait()
+	}
+
+	u.slots <- struct{}{}
+	u.wg.Add(1)
+	go func() {
+		defer u.wg.Done()
+		defer func() { <-u.slots }()
+		if err := upload(); err != nil {
+			u.mu.Lock()
+			u.errs = append(u.errs, err)
+			u.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+// failed reports whether an upload has failed
+func (u *pageUploads) failed() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return len(u.errs) > 0
+}
+
+// wait waits for the started uploads and returns their errors joined
+func (u *pageUploads) wait() error {
+	u.wg.Wait()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return errors.Join(u.errs...)
+}
+
 // writeSinglePage writes a single page of data (no pagination)
-func (w *MeteringWriter) writeSinglePage(ctx context.Context, meteringData *common.MeteringData) error {
+func (w *MeteringWriter) writeSinglePage(ctx context.Context, meteringData *common.MeteringData, stats *writeStats) error {
+	var data []json.RawMessage
+	if meteringData.Data != nil {
+		data = make([]json.RawMessage, len(meteringData.Data))
+	}
+	for i, logicalCluster := range meteringData.Data {
+		entry, err := marshalEntry(logicalCluster)
+		if err != nil {
+			return w.reportFailure(ctx, "", writer.ErrorClassSerialization, err)
+		}
+		data[i] = entry
+	}
 	pageData := &pageMeteringData{
-		Timestamp:    meteringData.Timestamp,
-		Category:     meteringData.Category,
-		SelfID:       meteringData.SelfID,
-		SharedPoolID: meteringData.SharedPoolID,
-		Part:         0,
-		Data:         meteringData.Data,
+		FormatVersion: common.CurrentFormatVersion,
+		Timestamp:     meteringData.Timestamp,
+		Category:      meteringData.Category,
+		SelfID:        meteringData.SelfID,
+		SharedPoolID:  meteringData.SharedPoolID,
+		Part:          0,
+		Data:          data,
+		entries:       meteringData.Data,
 	}
 
-	return w.writePageData(ctx, pageData)
+	return w.writePageData(ctx, pageData, stats)
 }
 
 // writePageData writes page data
-func (w *MeteringWriter) writePageData(ctx context.Context, pageData *pageMeteringData) error {
+func (w *MeteringWriter) writePageData(ctx context.Context, pageData *pageMeteringData, stats *writeStats) error {
 	// Validate that SharedPoolID is not empty
 	if pageData.SharedPoolID == "" {
-		return fmt.Errorf("SharedPoolID is required and cannot be empty")
+		return w.reportFailure(ctx, "", writer.ErrorClassValidation, fmt.Errorf("SharedPoolID is required and cannot be empty"))
 	}
 
-	// Build path: /metering/ru/{timestamp}/{category}/{shared_pool_id}/{self_id}-{part}.json.gz
-	path := fmt.Sprintf("metering/ru/%d/%s/%s/%s-%d.json.gz",
-		pageData.Timestamp,
-		pageData.Category,
-		pageData.SharedPoolID,
-		pageData.SelfID,
-		pageData.Part,
-	)
+	path := w.meteringPath(pageData.Timestamp, pageData.Category, pageData.SharedPoolID, pageData.SelfID, pageData.Part, w.codec)
 
 	w.logger.Debug("Writing page data",
-		zap.String("path", path),
+		logging.Path(path),
 		zap.Int("part", pageData.Part),
 		zap.Int("logical_clusters_in_page", len(pageData.Data)),
 	)
 
-	// If overwriting is not allowed, check if file already exists
-	if !w.config.OverwriteExisting {
-		exists, err := w.provider.Exists(ctx, path)
-		if err != nil {
-			return fmt.Errorf("failed to check if file exists: %w", err)
-		}
-		if exists {
-			w.logger.Warn("File already exists, refusing to overwrite",
-				zap.String("path", path),
-			)
-			return fmt.Errorf("%w: %s", writer.ErrFileExists, path)
-		}
+	path, conditional, err := w.target(ctx, path)
+	if errors.Is(err, errPageWritten) {
+		return w.skipWrittenPage(path)
 	}
-
-	// Serialize data to JSON
-	jsonData, err := json.Marshal(pageData)
 	if err != nil {
-		return fmt.Errorf("failed to marshal page data: %w", err)
+		return err
 	}
 
-	// Compress data
-	compressedData, err := w.compressDataReuse(jsonData)
+	// Only JSON is encoded as it is uploaded
+	if w.config.StreamingUpload && codec.IsJSON(w.codec) {
+		return w.streamPageData(ctx, path, pageData, conditional, stats)
+	}
+
+	// Serialize and compress data
+	start := time.Now()
+	compressedData, err := w.encodePage(pageData)
 	if err != nil {
-		return fmt.Errorf("failed to compress data: %w", err)
+		return w.reportFailure(ctx, path, writer.ErrorClassSerialization, fmt.Errorf("failed to serialize page data: %w", err))
 	}
 
 	// Upload to storage
-	if err := w.provider.Upload(ctx, path, bytes.NewReader(compressedData)); err != nil {
-		return fmt.Errorf("failed to upload page data: %w", err)
+	if class, err := w.upload(ctx, path, bytes.NewReader(compressedData), conditional); errors.Is(err, errPageWritten) {
+		return w.skipWrittenPage(path)
+	} else if err != nil {
+		return w.reportUploadFailure(ctx, path, class, err, func() ([]byte, error) { return compressedData, nil })
 	}
+	w.stage(pageData.Timestamp, path)
+	w.pageWritten(ctx, stats, &writer.PageEvent{Path: path, Part: pageData.Part, Size: len(compressedData), Records: len(pageData.Data)})
 
 	w.logger.Debug("Successfully wrote page data",
-		zap.String("path", path),
-		zap.Int("size_bytes", len(compressedData)),
+		logging.Path(path),
+		logging.Bytes(int64(len(compressedData))),
+		logging.Since(start),
 		zap.Int("logical_clusters", len(pageData.Data)),
 	)
 
 	return nil
 }
 
-func (w *MeteringWriter) Close() error {
-	w.mu.Lock()
-	defer w.mu.Unlock()
+// skipWrittenPage skips the page of a retried write found at path, it was uploaded by the failed attempt
+func (w *MeteringWriter) skipWrittenPage(path string) error {
+	w.logger.Debug("Page of retried write already exists, skipping",
+		logging.Path(path),
+	)
+	return nil
+}
+
+// encodePage serializes page data with the writer's codec and compresses it
+func (w *MeteringWriter) encodePage(pageData *pageMeteringData) ([]byte, error) {
+	if codec.IsJSON(w.codec) {
+		return compress.GzipJSON(pageData)
+	}
+	data, err := w.codec.Marshal(&common.MeteringData{
+		Timestamp:    pageData.Timestamp,
+		Category:     pageData.Category,
+		SelfID:       pageData.SelfID,
+		SharedPoolID: pageData.SharedPoolID,
+		Data:         pageData.entries,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode page data with codec %s: %w", w.codec.Name(), err)
+	}
+	return compress.Gzip(data)
+}
+
+// uploadContext returns ctx carrying the configured upload options. Files uploaded with gzip
+// Content-Encoding and no configured Content-Type are labeled with the content type of fileCodec
+func (w *MeteringWriter) uploadContext(ctx context.Context, fileCodec codec.Codec) context.Context {
+	opts := w.config.UploadOptions
+	if storage.UploadOptionsFromContext(ctx) == nil && opts.ContentType == "" && opts.ContentEncoding == "gzip" {
+		opts.ContentType = fileCodec.ContentType()
+		return storage.WithUploadOptions(ctx, &opts)
+	}
+	return w.config.UploadContext(ctx)
+}
+
+// streamPageData encodes, compresses and uploads page data through a pipe, so the compressed
+// page is never held in memory as a whole
+func (w *MeteringWriter) streamPageData(ctx context.Context, path string, pageData *pageMeteringData, conditional bool, stats *writeStats) error {
+	start := time.Now()
+	pr, pw := io.Pipe()
+	encodeErr := make(chan error, 1)
+	go func() {
+		gzipWriter := compress.NewWriter(pw)
+		err := json.NewEncoder(gzipWriter).Encode(pageData)
+		if closeErr := gzipWriter.Close(); err == nil {
+			err = closeErr
+		}
+		compress.PutWriter(gzipWriter)
+		pw.CloseWithError(err)
+		encodeErr <- err
+	}()
+
+	counter := &countingReader{r: pr}
+	class, uploadErr := w.upload(ctx, path, counter, conditional)
+	// Unblock the encoder if the upload stopped reading early
+	pr.CloseWithError(io.ErrClosedPipe)
+	if err := <-encodeErr; err != nil && !errors.Is(err, io.ErrClosedPipe) {
+		return w.reportFailure(ctx, path, writer.ErrorClassSerialization, fmt.Errorf("failed to encode page data: %w", err))
+	}
+	if errors.Is(uploadErr, errPageWritten) {
+		return w.skipWrittenPage(path)
+	}
+	if uploadErr != nil {
+		// The streamed page wasn't kept, encode it again for the dead letter queue
+		return w.reportUploadFailure(ctx, path, class, uploadErr, func() ([]byte, error) {
+			return compress.GzipJSON(pageData)
+		})
+	}
+	w.stage(pageData.Timestamp, path)
+	w.pageWritten(ctx, stats, &writer.PageEvent{Path: path, Part: pageData.Part, Size: counter.n, Records: len(pageData.Data)})
+
+	w.logger.Debug("Successfully streamed page data",
+		logging.Path(path),
+		logging.Bytes(int64(counter.n)),
+		logging.Since(start),
+		zap.Int("logical_clusters", len(pageData.Data)),
+	)
 
-	if w.gzipWriter != nil {
-		err := w.gzipWriter.Close()
-		w.gzipWriter = nil
+	return nil
+}
+
+// WriteRaw uploads an already serialized and gzip-compressed page file, e.g. one received by a relay
+// service from an agent, without re-encoding it. The target path is built from fileInfo; if
+// fileInfo.Path is set it must match. The payload is streamed to storage as-is.
+func (w *MeteringWriter) WriteRaw(ctx context.Context, fileInfo meteringreader.MeteringFileInfo, r io.Reader) (err error) {
+	ctx, end, err := w.begin(ctx, 0)
+	if err != nil {
 		return err
 	}
+	defer end()
+
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, w.config.TracerProvider, "MeteringWriter.WriteRaw",
+		tracing.AttributeCategory.String(fileInfo.Category),
+		tracing.AttributeTimestamp.Int64(fileInfo.Timestamp),
+	)
+	stats := &writeStats{}
+	defer func() {
+		tracing.End(span, err)
+		flush := stats.flushEvent(start, err)
+		flush.Category = fileInfo.Category
+		flush.Timestamp = fileInfo.Timestamp
+		w.config.Hooks.Flush(ctx, flush)
+	}()
+	// Raw files keep their codec, given by the suffix of their path if set
+	fileCodec := w.codec
+	if fileInfo.Path != "" {
+		fileCodec = codec.ForPath(fileInfo.Path)
+	}
+	ctx = w.uploadContext(ctx, fileCodec)
+
+	if err := w.validateFileInfo(&fileInfo); err != nil {
+		return w.reportFailure(ctx, fileInfo.Path, writer.ErrorClassValidation, err)
+	}
+	path := w.meteringPath(fileInfo.Timestamp, fileInfo.Category, fileInfo.SharedPoolID, fileInfo.SelfID, fileInfo.Part, fileCodec)
+	if fileInfo.Path != "" && fileInfo.Path != path {
+		return w.reportFailure(ctx, fileInfo.Path, writer.ErrorClassValidation,
+			fmt.Errorf("path %s does not match file info, expected %s", fileInfo.Path, path))
+	}
+
+	// Reject payloads that are obviously not gzip before touching storage
+	body := bufio.NewReader(r)
+	magic, err := body.Peek(2)
+	if err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		return w.reportFailure(ctx, path, writer.ErrorClassValidation, fmt.Errorf("payload for %s is not gzip-compressed", path))
+	}
+
+	w.logger.Debug("Writing raw page data",
+		logging.Path(path),
+		zap.Int("part", fileInfo.Part),
+	)
+
+	path, conditional, err := w.target(ctx, path)
+	if err != nil {
+		return err
+	}
+	counter := &countingReader{r: body}
+	if err := w.put(ctx, path, counter, conditional); err != nil {
+		return err
+	}
+	w.stage(fileInfo.Timestamp, path)
+	w.pageWritten(ctx, stats, &writer.PageEvent{Path: path, Part: fileInfo.Part, Size: counter.n})
+
+	w.logger.Debug("Successfully wrote raw page data",
+		logging.Path(path),
+		logging.Bytes(int64(counter.n)),
+		logging.Since(start),
+	)
+
+	return nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+// validateFileInfo validates the fields used to build a metering file path
+func (w *MeteringWriter) validateFileInfo(fileInfo *meteringreader.MeteringFileInfo) error {
+	if err := utils.ValidateTimestamp(fileInfo.Timestamp); err != nil {
+		return err
+	}
+	if err := utils.ValidateCategory(fileInfo.Category); err != nil {
+		return err
+	}
+	if fileInfo.SharedPoolID == "" {
+		return fmt.Errorf("SharedPoolID is required and cannot be empty")
+	}
+	if err := w.config.ValidateClusterID(fileInfo.SharedPoolID); err != nil {
+		return fmt.Errorf("invalid SharedPoolID: %w", err)
+	}
+	if err := w.config.ValidateSelfID(fileInfo.SelfID); err != nil {
+		return err
+	}
+	if fileInfo.Part < 0 {
+		return fmt.Errorf("part must be non-negative, got %d", fileInfo.Part)
+	}
+	return nil
+}
+
+// meteringPath builds the path of a metering file written with fileCodec with the configured layout, by default:
+// /metering/ru/{timestamp}/{category}/{shared_pool_id}/{self_id}-{part}.json.gz
+func (w *MeteringWriter) meteringPath(timestamp int64, category, sharedPoolID, selfID string, part int, fileCodec codec.Codec) string {
+	return codec.Path(w.config.GetPathLayout().Path(layout.Fields{
+		Timestamp:    timestamp,
+		Category:     category,
+		SharedPoolID: sharedPoolID,
+		SelfID:       selfID,
+		Part:         part,
+	}, w.config.PartNumberWidth), fileCodec)
+}
+
+// checkOverwrite enforces OverwriteExisting before an upload. It returns true when the provider
+// rejects existing files itself, so the upload must go through put with conditional set.
+func (w *MeteringWriter) checkOverwrite(ctx context.Context, path string) (bool, error) {
+	if w.config.OverwriteExisting {
+		return false, nil
+	}
+
+	// With conditional put the provider rejects the upload itself, saving the Exists round-trip
+	if _, ok := w.provider.(storage.ConditionalUploader); ok && !w.config.DisableConditionalPut {
+		return true, nil
+	}
+
+	exists, err := w.provider.Exists(ctx, path)
+	if err != nil {
+		return false, w.reportFailure(ctx, path, writer.ErrorClassStorage, fmt.Errorf("failed to check if file exists: %w", err))
+	}
+	if exists && retried(ctx) {
+		return false, errPageWritten
+	}
+	if exists {
+		w.logger.Warn("File already exists, refusing to overwrite",
+			logging.Path(path),
+		)
+		return false, w.reportFailure(ctx, path, writer.ErrorClassConflict, fmt.Errorf("%w: %s", writer.ErrFileExists, path))
+	}
+	return false, nil
+}
+
+// put uploads body to path, conditionally if requested by checkOverwrite, and reports failures
+func (w *MeteringWriter) put(ctx context.Context, path string, body io.Reader, conditional bool) error {
+	if class, err := w.upload(ctx, path, body, conditional); err != nil {
+		return w.reportFailure(ctx, path, class, err)
+	}
 	return nil
 }
 
-// compressDataReuse uses reusable gzip writer to compress data
-func (w *MeteringWriter) compressDataReuse(data []byte) ([]byte, error) {
-	w.mu.Lock()
-	defer w.mu.Unlock()
+// upload uploads body to path, returning the error class of a failure
+func (w *MeteringWriter) upload(ctx context.Context, path string, body io.Reader, conditional bool) (writer.ErrorClass, error) {
+	if w.config.DryRun {
+		// Drain body so streamed pages are encoded and counted in full
+		n, err := io.Copy(io.Discard, body)
+		if err != nil {
+			return writer.ErrorClassSerialization, fmt.Errorf("failed to read page data: %w", err)
+		}
+		w.logger.Info("Dry run, skipping upload",
+			logging.Path(path),
+			logging.Bytes(n),
+		)
+		return "", nil
+	}
+	var err error
+	if conditional {
+		err = w.provider.(storage.ConditionalUploader).UploadIfNotExists(ctx, path, body)
+		if errors.Is(err, storage.ErrObjectExists) && retried(ctx) {
+			return "", errPageWritten
+		}
+		if errors.Is(err, storage.ErrObjectExists) {
+			w.logger.Warn("File already exists, refusing to overwrite",
+				logging.Path(path),
+			)
+			return writer.ErrorClassConflict, fmt.Errorf("%w: %s", writer.ErrFileExists, path)
+		}
+	} else {
+		err = w.provider.Upload(ctx, path, body)
+	}
+	if err != nil {
+		return writer.ErrorClassStorage, fmt.Errorf("failed to upload page data: %w", err)
+	}
+	return "", nil
+}
 
-	// Reset buffer
-	w.buffer.Reset()
+// reportFailure notifies the configured error sink of a terminal write failure and returns it as a writer.WriteError
+func (w *MeteringWriter) reportFailure(ctx context.Context, path string, class writer.ErrorClass, err error) error {
+	return w.notifyFailure(ctx, path, class, err, false)
+}
+
+// reportUploadFailure hands the page of a failed upload to the configured dead letter queue, then
+// reports the failure. page returns the compressed page and is only called if the page is dead-lettered.
+func (w *MeteringWriter) reportUploadFailure(ctx context.Context, path string, class writer.ErrorClass, err error, page func() ([]byte, error)) error {
+	deadLettered := false
+	if w.config.DeadLetters != nil && class == writer.ErrorClassStorage {
+		deadLettered = w.deadLetter(ctx, path, class, err, page)
+	}
+	return w.notifyFailure(ctx, path, class, err, deadLettered)
+}
 
-	// Reset gzip writer to write to new buffer
-	w.gzipWriter.Reset(w.buffer)
+// deadLetter puts the page that failed to upload to path into the dead letter queue, returning whether it succeeded
+func (w *MeteringWriter) deadLetter(ctx context.Context, path string, class writer.ErrorClass, err error, page func() ([]byte, error)) bool {
+	data, pageErr := page()
+	if pageErr == nil {
+		pageErr = w.config.DeadLetters.PutDeadLetter(ctx, &writer.DeadLetter{
+			Path:  path,
+			Data:  data,
+			Class: class,
+			Err:   err,
+			Time:  time.Now(),
+		})
+	}
+	if pageErr != nil {
+		w.logger.Error("Failed to dead-letter page, metering data is lost",
+			logging.Path(path),
+			zap.Error(pageErr),
+		)
+		return false
+	}
+	w.logger.Warn("Upload failed, page was dead-lettered",
+		logging.Path(path),
+		zap.Error(err),
+	)
+	return true
+}
 
-	// Write data
-	if _, err := w.gzipWriter.Write(data); err != nil {
-		return nil, err
+// notifyFailure notifies the configured error sink of a terminal write failure and returns it as a writer.WriteError
+func (w *MeteringWriter) notifyFailure(ctx context.Context, path string, class writer.ErrorClass, err error, deadLettered bool) error {
+	w.config.Metrics.ObserveWriteFailure(metricsLabel, string(class))
+	writeErr := &writer.WriteError{Class: class, Path: path, Err: err}
+	failure := &writer.WriteFailure{
+		Path:         path,
+		Attempts:     1,
+		Class:        class,
+		Err:          writeErr,
+		Time:         time.Now(),
+		DeadLettered: deadLettered,
+	}
+	if w.config.ErrorSink != nil {
+		w.config.ErrorSink.OnWriteFailure(ctx, failure)
 	}
+	w.config.Hooks.WriteError(ctx, failure)
+	return writeErr
+}
 
-	// Close and flush data
-	if err := w.gzipWriter.Close(); err != nil {
-		return nil, err
+// pageWritten records an uploaded page in metrics, stats if not nil and the configured hooks
+func (w *MeteringWriter) pageWritten(ctx context.Context, stats *writeStats, page *writer.PageEvent) {
+	w.config.Metrics.ObservePage(metricsLabel, page.Size)
+	if stats != nil {
+		stats.pages.Add(1)
+		stats.bytes.Add(int64(page.Size))
 	}
+	w.config.Hooks.PageWritten(ctx, page)
+}
 
-	// Return copy of compressed data
-	result := make([]byte, w.buffer.Len())
-	copy(result, w.buffer.Bytes())
+// ReplayDeadLetter uploads a dead-lettered page to its original path, e.g. as the replay function of
+// writer.ReplayDeadLetters. Existing files are only overwritten if OverwriteExisting is set.
+func (w *MeteringWriter) ReplayDeadLetter(ctx context.Context, letter *writer.DeadLetter) error {
+	ctx, end, err := w.begin(ctx, 0)
+	if err != nil {
+		return err
+	}
+	defer end()
 
-	return result, nil
+	ctx = w.config.UploadContext(ctx)
+	staged, err := w.restage(ctx, letter)
+	if err != nil {
+		return err
+	}
+	if !staged {
+		conditional, err := w.checkOverwrite(ctx, letter.Path)
+		if err != nil {
+			return err
+		}
+		if err := w.put(ctx, letter.Path, bytes.NewReader(letter.Data), conditional); err != nil {
+			return err
+		}
+	}
+	w.pageWritten(ctx, nil, &writer.PageEvent{Path: letter.Path, Size: len(letter.Data)})
+
+	w.logger.Info("Replayed dead-lettered page",
+		logging.Path(letter.Path),
+	)
+	return nil
+}
+
+// Close writes the records buffered by AppendRecord and stops accepting writes, without waiting for
+// in-flight writes, see Shutdown. Appended records that fail to be written are dropped and their number
+// is returned in the error. Later calls fail with writer.ErrWriterClosed. Compression is pooled, there
+// is nothing else to release. Closing twice is a no-op.
+func (w *MeteringWriter) Close() error {
+	dropped, err := w.flushAppended(context.Background(), true)
+
+	w.lifecycleMu.Lock()
+	w.shuttingDown = true
+	w.lifecycleMu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("dropped %d appended records on close: %w", dropped, err)
+	}
+	return nil
+}
+
+// validateMeteringValues validates every MeteringValue field in the data entries
+func validateMeteringValues(data []map[string]interface{}) error {
+	for i, entry := range data {
+		for field, raw := range entry {
+			var value *common.MeteringValue
+			switch v := raw.(type) {
+			case *common.MeteringValue:
+				value = v
+			case common.MeteringValue:
+				value = &v
+			}
+			if value == nil {
+				continue
+			}
+			if err := value.Validate(); err != nil {
+				return fmt.Errorf("invalid value for field %s in data entry %d: %w", field, i, err)
+			}
+		}
+	}
+	return nil
 }
@@ -0,0 +1,74 @@
+package meteringwriter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/pingcap/metering_sdk/writer"
+	"go.uber.org/zap"
+)
+
+// begin registers a call writing records data entries, failing with writer.ErrWriterClosed once Shutdown
+// has been called. The returned context is cancelled if Shutdown gives up on the call; end must be
+// called when it returns.
+func (w *MeteringWriter) begin(ctx context.Context, records int) (context.Context, func(), error) {
+	w.lifecycleMu.Lock()
+	defer w.lifecycleMu.Unlock()
+	if w.shuttingDown {
+		return ctx, nil, writer.ErrWriterClosed
+	}
+	w.inflight.Add(1)
+	w.inflightRecords.Add(int64(records))
+
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(w.abort, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+		w.inflightRecords.Add(-int64(records))
+		w.inflight.Done()
+	}, nil
+}
+
+// Shutdown stops accepting writes, writes the records buffered by AppendRecord, waits for in-flight
+// calls to finish uploading and closes the writer. Other writes are uploaded synchronously, so nothing
+// else is buffered. Appended records that fail to be written are returned as dropped, with the error.
+// If ctx is done first, the remaining calls are cancelled and Shutdown also returns the number of data
+// entries they were writing as dropped, with ctx's error. Files staged but not finalized in staging mode are logged; call Finalize before
+// Shutdown to publish them. Later calls fail with writer.ErrWriterClosed.
+func (w *MeteringWriter) Shutdown(ctx context.Context) (dropped int, err error) {
+	// Appended records are written before writes are refused
+	dropped, flushErr := w.flushAppended(ctx, true)
+
+	w.lifecycleMu.Lock()
+	w.shuttingDown = true
+	w.lifecycleMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		w.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		cancelled := int(w.inflightRecords.Load())
+		w.abortWrites()
+		w.logger.Warn("Shutdown deadline exceeded, cancelled in-flight writes",
+			zap.Int("dropped_records", cancelled),
+		)
+		err := fmt.Errorf("in-flight writes did not finish before shutdown, dropped %d records: %w", cancelled, ctx.Err())
+		return dropped + cancelled, errors.Join(flushErr, err)
+	}
+
+	w.stagedMu.Lock()
+	for timestamp, paths := range w.staged {
+		w.logger.Warn("Shutting down with staged files that were not finalized",
+			zap.Int64("timestamp", timestamp),
+			zap.Int("files", len(paths)),
+		)
+	}
+	w.stagedMu.Unlock()
+	return dropped, errors.Join(flushErr, w.Close())
+}
@@ -0,0 +1,296 @@
+package meteringwriter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pingcap/metering_sdk/common"
+	"github.com/pingcap/metering_sdk/internal/utils"
+	"github.com/pingcap/metering_sdk/layout"
+	"github.com/pingcap/metering_sdk/logging"
+	"github.com/pingcap/metering_sdk/storage"
+	"github.com/pingcap/metering_sdk/tracing"
+	"github.com/pingcap/metering_sdk/writer"
+	"go.uber.org/zap"
+)
+
+// target returns the path to upload the file at path to, and whether to upload it conditionally.
+// In staging mode files are uploaded to the staging area, which they may overwrite after a crash;
+// OverwriteExisting is then enforced by Finalize.
+func (w *MeteringWriter) target(ctx context.Context, path string) (string, bool, error) {
+	if w.config.Staging {
+		return layout.StagingPath(path), false, nil
+	}
+	conditional, err := w.checkOverwrite(ctx, path)
+	return path, conditional, err
+}
+
+// stage records the file staged at path for timestamp, it is published by Finalize
+func (w *MeteringWriter) stage(timestamp int64, path string) {
+	// Nothing is uploaded in dry run mode, so there is nothing to publish
+	if !w.config.Staging || w.config.DryRun {
+		return
+	}
+	w.stagedMu.Lock()
+	defer w.stagedMu.Unlock()
+	if !slices.Contains(w.staged[timestamp], path) {
+		w.staged[timestamp] = append(w.staged[timestamp], path)
+	}
+}
+
+// stagedFile a file found in the staging area
+type stagedFile struct {
+	path   string // staged path
+	fields *layout.Fields
+}
+
+// listStaged lists the files of this writer's shared pool staged under the staging path of prefix, by
+// any writer. With selfIDs, only the files of those components are returned.
+func (w *MeteringWriter) listStaged(ctx context.Context, prefix string, selfIDs []string) ([]stagedFile, error) {
+	stagingPrefix := layout.StagingPath(prefix)
+	keys, err := w.provider.List(ctx, stagingPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list staged files: %w", err)
+	}
+	var files []stagedFile
+	for _, key := range keys {
+		// Providers with a configured prefix list keys with it
+		_, rest, ok := strings.Cut(key, stagingPrefix)
+		if !ok {
+			continue
+		}
+		path := prefix + rest
+		fields, err := w.config.GetPathLayout().Parse(path)
+		if err != nil || fields.SharedPoolID != w.sharedPoolID {
+			continue
+		}
+		if len(selfIDs) > 0 && !slices.Contains(selfIDs, fields.SelfID) {
+			continue
+		}
+		files = append(files, stagedFile{path: layout.StagingPath(path), fields: fields})
+	}
+	return files, nil
+}
+
+// StagedTimestamps returns the timestamps that have files staged in the shared pool of this writer, in
+// ascending order, e.g. to find the files a writer left staged when it crashed or was restarted. Files
+// staged by this writer since it was created are included.
+func (w *MeteringWriter) StagedTimestamps(ctx context.Context) ([]int64, error) {
+	files, err := w.listStaged(ctx, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	var timestamps []int64
+	for _, file := range files {
+		if !slices.Contains(timestamps, file.fields.Timestamp) {
+			timestamps = append(timestamps, file.fields.Timestamp)
+		}
+	}
+	slices.Sort(timestamps)
+	return timestamps, nil
+}
+
+// RecoverStaged adds the files staged for timestamp in the shared pool of this writer by selfIDs to the
+// files the next Finalize publishes, returning their number. Staged files are only tracked in memory, use
+// it after a crash or restart for minutes that were completely written before. Without selfIDs the files
+// of every component are recovered, only do so when no other writer of the shared pool is staging
+// files for timestamp, Finalize would publish their partial minute.
+func (w *MeteringWriter) RecoverStaged(ctx context.Context, timestamp int64, selfIDs ...string) (int, error) {
+	if !w.config.Staging {
+		return 0, fmt.Errorf("staging is not enabled")
+	}
+	files, err := w.listStaged(ctx, w.config.GetPathLayout().TimestampPrefix(timestamp), selfIDs)
+	if err != nil {
+		return 0, err
+	}
+	recovered := 0
+	for _, file := range files {
+		if file.fields.Timestamp == timestamp {
+			w.stage(timestamp, file.path)
+			recovered++
+		}
+	}
+	w.logger.Info("Recovered staged metering files",
+		zap.Int64("timestamp", timestamp),
+		zap.Int("files", recovered),
+	)
+	return recovered, nil
+}
+
+// DiscardStaged deletes the files staged for timestamp in the shared pool of this writer by selfIDs,
+// or by every component without selfIDs, returning their number. Use it after a crash or restart for
+// minutes that were only partly written, before writing them again. Errors are returned joined.
+func (w *MeteringWriter) DiscardStaged(ctx context.Context, timestamp int64, selfIDs ...string) (int, error) {
+	if !w.config.Staging {
+		return 0, fmt.Errorf("staging is not enabled")
+	}
+	files, err := w.listStaged(ctx, w.config.GetPathLayout().TimestampPrefix(timestamp), selfIDs)
+	if err != nil {
+		return 0, err
+	}
+	discarded := 0
+	var errs []error
+	for _, file := range files {
+		if file.fields.Timestamp != timestamp {
+			continue
+		}
+		if err := w.provider.Delete(ctx, file.path); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete staged file %s: %w", file.path, err))
+			continue
+		}
+		w.unstage(timestamp, file.path)
+		discarded++
+	}
+	return discarded, errors.Join(errs...)
+}
+
+// unstage forgets the file staged at path for timestamp
+func (w *MeteringWriter) unstage(timestamp int64, path string) {
+	w.stagedMu.Lock()
+	defer w.stagedMu.Unlock()
+	w.staged[timestamp] = slices.DeleteFunc(w.staged[timestamp], func(p string) bool { return p == path })
+	if len(w.staged[timestamp]) == 0 {
+		delete(w.staged, timestamp)
+	}
+}
+
+// restage uploads a page dead-lettered in staging mode to the staging area again, so the next Finalize
+// publishes it. It returns false if the page wasn't staged.
+func (w *MeteringWriter) restage(ctx context.Context, letter *writer.DeadLetter) (bool, error) {
+	path, ok := strings.CutPrefix(letter.Path, layout.StagingPrefix)
+	if !ok || !w.config.Staging {
+		return false, nil
+	}
+	fields, err := w.config.GetPathLayout().Parse(path)
+	if err != nil {
+		return false, fmt.Errorf("invalid staged path %s: %w", letter.Path, err)
+	}
+	if err := w.put(ctx, letter.Path, bytes.NewReader(letter.Data), false); err != nil {
+		return false, err
+	}
+	w.stage(fields.Timestamp, letter.Path)
+	return true, nil
+}
+
+// Finalize publishes the files this writer staged for timestamp in staging mode: every file is moved
+// from the staging area to its final path, then a finalize marker listing the published
+// files is written under layout.FinalizedMarkerPrefix. Readers with RequireFinalized only return files
+// listed in markers, so a timestamp is never consumed partially, even if Finalize crashes halfway.
+// Files that fail to publish stay staged, and files missing from a marker that failed to be written are
+// remembered, for the next Finalize call. Files staged before a crash or restart are not known to the
+// writer, see RecoverStaged and DiscardStaged. Errors are returned joined.
+func (w *MeteringWriter) Finalize(ctx context.Context, timestamp int64) (err error) {
+	ctx, end, err := w.begin(ctx, 0)
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	ctx, span := tracing.Start(ctx, w.config.TracerProvider, "MeteringWriter.Finalize",
+		tracing.AttributeTimestamp.Int64(timestamp),
+	)
+	defer func() { tracing.End(span, err) }()
+	if !w.config.Staging {
+		return fmt.Errorf("staging is not enabled")
+	}
+	if err := utils.ValidateTimestamp(timestamp); err != nil {
+		return err
+	}
+	ctx = w.config.UploadContext(ctx)
+
+	w.stagedMu.Lock()
+	staged := w.staged[timestamp]
+	delete(w.staged, timestamp)
+	w.stagedMu.Unlock()
+
+	var published []string
+	var errs []error
+	for _, stagedPath := range staged {
+		path, ok := strings.CutPrefix(stagedPath, layout.StagingPrefix)
+		if !ok {
+			// Published by a previous call that failed to write its marker
+			published = append(published, path)
+			continue
+		}
+		if err := w.publish(ctx, stagedPath, path); err != nil {
+			errs = append(errs, err)
+			w.stage(timestamp, stagedPath)
+			continue
+		}
+		published = append(published, path)
+	}
+
+	if len(published) > 0 {
+		if err := w.writeFinalizeMarker(ctx, timestamp, published); err != nil {
+			errs = append(errs, err)
+			for _, path := range published {
+				w.stage(timestamp, path)
+			}
+		}
+	}
+	w.logger.Info("Finalized staged metering files",
+		zap.Int64("timestamp", timestamp),
+		zap.Int("published", len(published)),
+		zap.Int("failed", len(staged)-len(published)),
+	)
+	return errors.Join(errs...)
+}
+
+// publish moves the file staged at stagedPath to path. Unless the move may replace path, i.e. without
+// conditional uploads, it is done server-side with storage.Move; otherwise the file is re-uploaded
+// conditionally and the staged copy deleted.
+func (w *MeteringWriter) publish(ctx context.Context, stagedPath, path string) error {
+	conditional, err := w.checkOverwrite(ctx, path)
+	if err != nil {
+		return err
+	}
+	if !conditional {
+		if err := storage.Move(ctx, w.provider, stagedPath, path); err != nil {
+			return w.reportFailure(ctx, path, writer.ErrorClassStorage, fmt.Errorf("failed to publish staged file %s: %w", stagedPath, err))
+		}
+		return nil
+	}
+
+	body, err := w.provider.Download(ctx, stagedPath)
+	if err != nil {
+		return w.reportFailure(ctx, path, writer.ErrorClassStorage, fmt.Errorf("failed to read staged file %s: %w", stagedPath, err))
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return w.reportFailure(ctx, path, writer.ErrorClassStorage, fmt.Errorf("failed to read staged file %s: %w", stagedPath, err))
+	}
+	if err := w.put(ctx, path, bytes.NewReader(data), true); err != nil {
+		return err
+	}
+	if err := w.provider.Delete(ctx, stagedPath); err != nil {
+		// The file is published, the staged copy is only left behind
+		w.logger.Warn("Failed to delete staged file",
+			logging.Path(stagedPath),
+			zap.Error(err),
+		)
+	}
+	return nil
+}
+
+// writeFinalizeMarker writes the marker listing the files published for timestamp
+func (w *MeteringWriter) writeFinalizeMarker(ctx context.Context, timestamp int64, files []string) error {
+	now := time.Now()
+	marker, err := json.Marshal(&common.FinalizeMarker{Timestamp: timestamp, Files: files, FinalizedAt: now})
+	if err != nil {
+		return fmt.Errorf("failed to marshal finalize marker: %w", err)
+	}
+	// Markers of every Finalize call are kept, a writer may finalize a timestamp more than once
+	path := fmt.Sprintf("%s%s-%d.json", layout.FinalizedMarkerPrefix(timestamp), w.sharedPoolID, now.UnixNano())
+	if err := w.provider.Upload(ctx, path, bytes.NewReader(marker)); err != nil {
+		return w.reportFailure(ctx, path, writer.ErrorClassStorage, fmt.Errorf("failed to write finalize marker: %w", err))
+	}
+	return nil
+}
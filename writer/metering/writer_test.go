@@ -7,13 +7,28 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/pingcap/metering_sdk/codec"
 	"github.com/pingcap/metering_sdk/common"
 	"github.com/pingcap/metering_sdk/config"
 	"github.com/pingcap/metering_sdk/internal/utils"
+	"github.com/pingcap/metering_sdk/layout"
+	meteringreader "github.com/pingcap/metering_sdk/reader/metering"
+	"github.com/pingcap/metering_sdk/schema"
+	"github.com/pingcap/metering_sdk/storage"
+	"github.com/pingcap/metering_sdk/units"
+	"github.com/pingcap/metering_sdk/writer"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMeteringDataValidation(t *testing.T) {
@@ -305,14 +320,15 @@ func TestMeteringWriterGzipReuse(t *testing.T) {
 
 			// Verify correctness of compressed data
 			// Note: data is now wrapped in pageMeteringData structure
-			expectedPageData := &pageMeteringData{
-				Timestamp:    data.Timestamp,
-				Category:     data.Category,
-				SelfID:       data.SelfID,
-				SharedPoolID: "pool-cluster-001",
-				Part:         0,
-				Data:         data.Data,
-			}
+			expectedPageData := struct {
+				FormatVersion int                      `json:"format_version"`
+				Timestamp     int64                    `json:"timestamp"`
+				Category      string                   `json:"category"`
+				SelfID        string                   `json:"self_id"`
+				SharedPoolID  string                   `json:"shared_pool_id"`
+				Part          int                      `json:"part"`
+				Data          []map[string]interface{} `json:"data"`
+			}{common.CurrentFormatVersion, data.Timestamp, data.Category, data.SelfID, "pool-cluster-001", 0, data.Data}
 			expectedJSON, _ := json.Marshal(expectedPageData)
 			decompressAndVerify(t, uploadedData, expectedJSON)
 		})
@@ -790,3 +806,1236 @@ func TestDefaultSharedPoolID(t *testing.T) {
 
 	t.Logf("✓ NewMeteringWriter correctly uses default SharedPoolID: %s", pageData.SharedPoolID)
 }
+
+// TestMeteringWriterErrorSink tests that terminal write failures are reported to the error sink
+func TestMeteringWriterErrorSink(t *testing.T) {
+	mockProvider := NewMockStorageProvider()
+	failures := make(chan *writer.WriteFailure, 10)
+	cfg := config.DefaultConfig().WithErrorSink(writer.NewChannelErrorSink(failures))
+	meteringWriter := NewMeteringWriterWithSharedPool(mockProvider, cfg, "pool1")
+	defer meteringWriter.Close()
+
+	ctx := context.Background()
+	testData := &common.MeteringData{
+		Timestamp: 1640995200,
+		Category:  "storage",
+		SelfID:    "tikv001",
+		Data: []map[string]interface{}{
+			{"logical_cluster_id": "lc-test", "disk_usage": &common.MeteringValue{Value: 100, Unit: "GB"}},
+		},
+	}
+
+	// Validation failure
+	err := meteringWriter.Write(ctx, &common.MeteringData{Timestamp: 1640995201, Category: "storage", SelfID: "tikv001"})
+	assert.Error(t, err)
+	failure := <-failures
+	assert.Equal(t, writer.ErrorClassValidation, failure.Class)
+	assert.Equal(t, err, failure.Err)
+	assert.Empty(t, failure.Path)
+	assert.ErrorIs(t, err, writer.ErrValidation)
+	var writeErr *writer.WriteError
+	assert.ErrorAs(t, err, &writeErr)
+	assert.Equal(t, writer.ErrorClassValidation, writeErr.Class)
+
+	// Successful write reports nothing
+	assert.NoError(t, meteringWriter.Write(ctx, testData))
+	assert.Empty(t, failures)
+
+	// Conflict on existing file
+	err = meteringWriter.Write(ctx, testData)
+	assert.ErrorIs(t, err, writer.ErrFileExists)
+	failure = <-failures
+	assert.Equal(t, writer.ErrorClassConflict, failure.Class)
+	assert.Equal(t, "metering/ru/1640995200/storage/pool1/tikv001-0.json.gz", failure.Path)
+	assert.Equal(t, 1, failure.Attempts)
+}
+
+// TestMeteringWriterFloatValues tests that float values are written and invalid ones rejected
+func TestMeteringWriterFloatValues(t *testing.T) {
+	mockProvider := NewMockStorageProvider()
+	meteringWriter := NewMeteringWriterWithSharedPool(mockProvider, config.DefaultConfig(), "pool1")
+	defer meteringWriter.Close()
+
+	ctx := context.Background()
+	testData := &common.MeteringData{
+		Timestamp: 1640995200,
+		Category:  "storage",
+		SelfID:    "tikv001",
+		Data: []map[string]interface{}{
+			{"logical_cluster_id": "lc-test", "cpu_usage": common.NewFloatMeteringValue(75.5, 1, "percent")},
+		},
+	}
+	assert.NoError(t, meteringWriter.Write(ctx, testData))
+	assert.Len(t, mockProvider.uploadedData, 1)
+
+	testData.Timestamp += 60
+	testData.Data[0]["cpu_usage"] = common.NewFloatMeteringValue(math.NaN(), -1, "percent")
+	err := meteringWriter.Write(ctx, testData)
+	assert.ErrorContains(t, err, "cpu_usage")
+	assert.Len(t, mockProvider.uploadedData, 1)
+}
+
+// TestMeteringWriterWriteRaw tests uploading pre-compressed payloads
+func TestMeteringWriterWriteRaw(t *testing.T) {
+	mockProvider := NewMockStorageProvider()
+	meteringWriter := NewMeteringWriterWithSharedPool(mockProvider, config.DefaultConfig(), "pool1")
+	defer meteringWriter.Close()
+
+	payload := []byte(`{"timestamp":1640995200,"category":"storage","self_id":"tikv001","shared_pool_id":"agent-pool","part":2,"data":[]}`)
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write(payload)
+	assert.NoError(t, err)
+	assert.NoError(t, gz.Close())
+
+	fileInfo := meteringreader.MeteringFileInfo{
+		Timestamp:    1640995200,
+		Category:     "storage",
+		SharedPoolID: "agent-pool",
+		SelfID:       "tikv001",
+		Part:         2,
+	}
+	expectedPath := "metering/ru/1640995200/storage/agent-pool/tikv001-2.json.gz"
+
+	ctx := context.Background()
+	err = meteringWriter.WriteRaw(ctx, fileInfo, bytes.NewReader(compressed.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, compressed.Bytes(), mockProvider.uploadedData[expectedPath], "payload should be stored as-is")
+
+	// Same file again is rejected unless overwrite is enabled
+	err = meteringWriter.WriteRaw(ctx, fileInfo, bytes.NewReader(compressed.Bytes()))
+	assert.ErrorIs(t, err, writer.ErrFileExists)
+
+	t.Run("invalid requests", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			modify func(info *meteringreader.MeteringFileInfo)
+			body   []byte
+		}{
+			{"path mismatch", func(info *meteringreader.MeteringFileInfo) { info.Path = "metering/ru/1/x/y/z-0.json.gz" }, compressed.Bytes()},
+			{"invalid timestamp", func(info *meteringreader.MeteringFileInfo) { info.Timestamp = 1640995201 }, compressed.Bytes()},
+			{"self ID with dash", func(info *meteringreader.MeteringFileInfo) { info.SelfID = "tikv-001" }, compressed.Bytes()},
+			{"empty shared pool", func(info *meteringreader.MeteringFileInfo) { info.SharedPoolID = "" }, compressed.Bytes()},
+			{"negative part", func(info *meteringreader.MeteringFileInfo) { info.Part = -1 }, compressed.Bytes()},
+			{"not gzip", func(info *meteringreader.MeteringFileInfo) { info.Part = 3 }, payload},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				info := fileInfo
+				tt.modify(&info)
+				err := meteringWriter.WriteRaw(ctx, info, bytes.NewReader(tt.body))
+				assert.Error(t, err)
+			})
+		}
+		assert.Len(t, mockProvider.uploadedData, 1)
+	})
+}
+
+// TestMeteringWriterSchemaValidation tests that data entries are validated against registered schemas
+func TestMeteringWriterSchemaValidation(t *testing.T) {
+	registry := schema.NewRegistry()
+	assert.NoError(t, registry.Register(&schema.Schema{
+		Category:     "storage",
+		AllowUnknown: true,
+		Fields: []schema.Field{
+			{Name: "logical_cluster_id", Type: schema.FieldTypeString, Required: true},
+			{Name: "disk_usage", Type: schema.FieldTypeMeteringValue, Required: true, Units: []string{"GB"}},
+		},
+	}))
+
+	mockProvider := NewMockStorageProvider()
+	meteringWriter := NewMeteringWriterWithSharedPool(mockProvider, config.DefaultConfig().WithSchemaRegistry(registry), "pool1")
+	defer meteringWriter.Close()
+
+	ctx := context.Background()
+	testData := &common.MeteringData{
+		Timestamp: 1640995200,
+		Category:  "storage",
+		SelfID:    "tikv001",
+		Data: []map[string]interface{}{
+			{"logical_cluster_id": "lc-test", "disk_usage": &common.MeteringValue{Value: 100, Unit: "MB"}},
+		},
+	}
+	err := meteringWriter.Write(ctx, testData)
+	assert.ErrorContains(t, err, "does not match schema")
+	assert.Empty(t, mockProvider.uploadedData)
+
+	testData.Data[0]["disk_usage"] = &common.MeteringValue{Value: 100, Unit: "GB"}
+	assert.NoError(t, meteringWriter.Write(ctx, testData))
+	assert.Len(t, mockProvider.uploadedData, 1)
+}
+
+// TestMeteringWriterMetrics tests that writes are recorded in Prometheus metrics
+func TestMeteringWriterMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	mockProvider := NewMockStorageProvider()
+	meteringWriter := NewMeteringWriterWithSharedPool(mockProvider, config.DefaultConfig().WithMetricsRegistry(reg), "pool1")
+	defer meteringWriter.Close()
+
+	ctx := context.Background()
+	testData := &common.MeteringData{
+		Timestamp: 1640995200,
+		Category:  "storage",
+		SelfID:    "tikv001",
+		Data: []map[string]interface{}{
+			{"logical_cluster_id": "lc-test", "disk_usage": &common.MeteringValue{Value: 100, Unit: "GB"}},
+		},
+	}
+	assert.NoError(t, meteringWriter.Write(ctx, testData))
+	assert.Error(t, meteringWriter.Write(ctx, testData))
+
+	expected := `
+# HELP metering_sdk_writes_total Number of Write calls by writer and result.
+# TYPE metering_sdk_writes_total counter
+metering_sdk_writes_total{result="failure",writer="metering"} 1
+metering_sdk_writes_total{result="success",writer="metering"} 1
+# HELP metering_sdk_write_failures_total Number of terminal write failures by writer and error class.
+# TYPE metering_sdk_write_failures_total counter
+metering_sdk_write_failures_total{class="conflict",writer="metering"} 1
+# HELP metering_sdk_pages_written_total Number of files uploaded by writer.
+# TYPE metering_sdk_pages_written_total counter
+metering_sdk_pages_written_total{writer="metering"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"metering_sdk_writes_total", "metering_sdk_write_failures_total", "metering_sdk_pages_written_total"))
+
+	count, err := testutil.GatherAndCount(reg, "metering_sdk_storage_operations_total")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count, "exists and upload series")
+}
+
+// failingUploadProvider reads part of the body and then fails the upload
+type failingUploadProvider struct {
+	*MockStorageProvider
+}
+
+func (p *failingUploadProvider) Upload(ctx context.Context, path string, data io.Reader) error {
+	if _, err := data.Read(make([]byte, 16)); err != nil {
+		return err
+	}
+	return fmt.Errorf("connection reset")
+}
+
+// TestMeteringWriterStreamingUpload tests that streamed pages match buffered ones
+func TestMeteringWriterStreamingUpload(t *testing.T) {
+	testData := &common.MeteringData{
+		Timestamp: 1640995200,
+		Category:  "storage",
+		SelfID:    "tikv001",
+		Data: []map[string]interface{}{
+			{"logical_cluster_id": "lc-001", "disk_usage": &common.MeteringValue{Value: 100, Unit: "GB"}},
+			{"logical_cluster_id": "lc-002", "disk_usage": &common.MeteringValue{Value: 200, Unit: "GB"}},
+		},
+	}
+	path := "metering/ru/1640995200/storage/pool1/tikv001-0.json.gz"
+	decode := func(compressed []byte) map[string]interface{} {
+		reader, err := gzip.NewReader(bytes.NewReader(compressed))
+		assert.NoError(t, err)
+		var page map[string]interface{}
+		assert.NoError(t, json.NewDecoder(reader).Decode(&page))
+		return page
+	}
+
+	ctx := context.Background()
+	bufferedProvider := NewMockStorageProvider()
+	bufferedWriter := NewMeteringWriterWithSharedPool(bufferedProvider, config.DefaultConfig(), "pool1")
+	defer bufferedWriter.Close()
+	assert.NoError(t, bufferedWriter.Write(ctx, testData))
+
+	streamingProvider := NewMockStorageProvider()
+	streamingWriter := NewMeteringWriterWithSharedPool(streamingProvider, config.DefaultConfig().WithStreamingUpload(true), "pool1")
+	defer streamingWriter.Close()
+	assert.NoError(t, streamingWriter.Write(ctx, testData))
+	assert.Equal(t, decode(bufferedProvider.uploadedData[path]), decode(streamingProvider.uploadedData[path]))
+
+	// Overwrite protection still applies
+	assert.ErrorIs(t, streamingWriter.Write(ctx, testData), writer.ErrFileExists)
+
+	t.Run("upload failure is reported once", func(t *testing.T) {
+		failures := make(chan *writer.WriteFailure, 10)
+		cfg := config.DefaultConfig().WithStreamingUpload(true).WithErrorSink(writer.NewChannelErrorSink(failures))
+		failingWriter := NewMeteringWriterWithSharedPool(&failingUploadProvider{NewMockStorageProvider()}, cfg, "pool1")
+		defer failingWriter.Close()
+
+		err := failingWriter.Write(ctx, testData)
+		assert.ErrorContains(t, err, "connection reset")
+		assert.Len(t, failures, 1)
+		assert.Equal(t, writer.ErrorClassStorage, (<-failures).Class)
+	})
+}
+
+// TestMeteringWriterPartNumberWidth tests zero-padded part numbers in file names
+func TestMeteringWriterPartNumberWidth(t *testing.T) {
+	mockProvider := NewMockStorageProvider()
+	cfg := config.DefaultConfig().WithPageSize(10).WithPartNumberWidth(4)
+	meteringWriter := NewMeteringWriterWithSharedPool(mockProvider, cfg, "pool1")
+	defer meteringWriter.Close()
+
+	testData := &common.MeteringData{
+		Timestamp: 1640995200,
+		Category:  "storage",
+		SelfID:    "tikv001",
+		Data: []map[string]interface{}{
+			{"logical_cluster_id": "lc-001", "disk_usage": &common.MeteringValue{Value: 100, Unit: "GB"}},
+			{"logical_cluster_id": "lc-002", "disk_usage": &common.MeteringValue{Value: 200, Unit: "GB"}},
+		},
+	}
+	assert.NoError(t, meteringWriter.Write(context.Background(), testData))
+	assert.Contains(t, mockProvider.uploadedData, "metering/ru/1640995200/storage/pool1/tikv001-0000.json.gz")
+	assert.Contains(t, mockProvider.uploadedData, "metering/ru/1640995200/storage/pool1/tikv001-0001.json.gz")
+}
+
+// TestMeteringWriterPathLayout tests writing and reading back with a custom path layout
+func TestMeteringWriterPathLayout(t *testing.T) {
+	mockProvider := NewMockStorageProvider()
+	cfg := config.DefaultConfig().WithPathLayout(layout.MustNew(layout.HiveTemplate))
+	meteringWriter := NewMeteringWriterWithSharedPool(mockProvider, cfg, "pool1")
+	defer meteringWriter.Close()
+
+	ctx := context.Background()
+	testData := &common.MeteringData{
+		Timestamp: 1755850380,
+		Category:  "storage",
+		SelfID:    "tikv001",
+		Data: []map[string]interface{}{
+			{"logical_cluster_id": "lc-001", "disk_usage": &common.MeteringValue{Value: 100, Unit: "GB"}},
+		},
+	}
+	assert.NoError(t, meteringWriter.Write(ctx, testData))
+	path := "metering/ru/year=2025/month=08/day=22/hour=08/minute=13/category=storage/shared_pool_id=pool1/tikv001-0.json.gz"
+	assert.Contains(t, mockProvider.uploadedData, path)
+
+	reader := meteringreader.NewMeteringReader(mockProvider, cfg)
+	files, err := reader.ListFilesByTimestamp(ctx, testData.Timestamp)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{"storage": {path}}, files.Files)
+
+	info, err := reader.GetFileInfo(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "pool1", info.SharedPoolID)
+	assert.Equal(t, testData.Timestamp, info.Timestamp)
+}
+
+// reversedJSON JSON with its bytes reversed, a codec JSON decoders can't read
+type reversedJSON struct{}
+
+func (reversedJSON) Name() string        { return "reversed-json" }
+func (reversedJSON) ContentType() string { return "application/x-reversed-json" }
+func (reversedJSON) Suffix() string      { return ".rjson" }
+func (reversedJSON) Marshal(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	slices.Reverse(data)
+	return data, err
+}
+func (reversedJSON) Unmarshal(data []byte, v any) error {
+	data = slices.Clone(data)
+	slices.Reverse(data)
+	return json.Unmarshal(data, v)
+}
+
+// TestMeteringWriterCodec tests writing pages with a codec other than JSON and reading them back
+func TestMeteringWriterCodec(t *testing.T) {
+	require.NoError(t, codec.Register(reversedJSON{}))
+	mockProvider := NewMockStorageProvider()
+	cfg := config.DefaultConfig().WithCodec(reversedJSON{})
+	cfg.PageSizeBytes = 100 // one logical cluster per page
+	meteringWriter := NewMeteringWriterWithSharedPool(mockProvider, cfg, "pool1")
+
+	ctx := context.Background()
+	testData := &common.MeteringData{
+		Timestamp: 1755850380,
+		Category:  "storage",
+		SelfID:    "tikv001",
+		Data: []map[string]interface{}{
+			{"logical_cluster_id": "lc-001", "disk_usage": &common.MeteringValue{Value: 100, Unit: "GB"}},
+			{"logical_cluster_id": "lc-002", "disk_usage": &common.MeteringValue{Value: 200, Unit: "GB"}},
+		},
+	}
+	require.NoError(t, meteringWriter.Write(ctx, testData))
+	paths := []string{
+		"metering/ru/1755850380/storage/pool1/tikv001-0.rjson.gz",
+		"metering/ru/1755850380/storage/pool1/tikv001-1.rjson.gz",
+	}
+	for _, path := range paths {
+		assert.Contains(t, mockProvider.uploadedData, path)
+	}
+
+	// Readers detect the codec from the suffix, whatever their own codec
+	reader := meteringreader.NewMeteringReader(mockProvider, config.DefaultConfig())
+	files, err := reader.ListFilesByTimestamp(ctx, testData.Timestamp)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"storage": paths}, files.Files)
+	for i, path := range paths {
+		page, err := reader.ReadFile(ctx, path)
+		require.NoError(t, err)
+		assert.Equal(t, "pool1", page.SharedPoolID)
+		require.Len(t, page.Data, 1)
+		assert.Equal(t, testData.Data[i]["logical_cluster_id"], page.Data[0]["logical_cluster_id"])
+	}
+	entries := 0
+	for entry, err := range reader.ReadFileStream(ctx, paths[1]) {
+		require.NoError(t, err)
+		assert.Equal(t, "lc-002", entry["logical_cluster_id"])
+		entries++
+	}
+	assert.Equal(t, 1, entries)
+}
+
+// uploadOptionsProvider records the upload options carried by each upload's context
+type uploadOptionsProvider struct {
+	*MockStorageProvider
+	options map[string]storage.UploadOptions
+}
+
+func (p *uploadOptionsProvider) Upload(ctx context.Context, path string, data io.Reader) error {
+	if opts := storage.UploadOptionsFromContext(ctx); opts != nil {
+		p.options[path] = *opts
+	}
+	return p.MockStorageProvider.Upload(ctx, path, data)
+}
+
+// TestMeteringWriterUploadOptions tests the HTTP metadata attached to uploads
+func TestMeteringWriterUploadOptions(t *testing.T) {
+	newData := func(selfID string) *common.MeteringData {
+		return &common.MeteringData{
+			Timestamp: 1640995200,
+			Category:  "storage",
+			SelfID:    selfID,
+			Data: []map[string]interface{}{
+				{"logical_cluster_id": "lc-001", "disk_usage": &common.MeteringValue{Value: 100, Unit: "GB"}},
+			},
+		}
+	}
+	ctx := context.Background()
+
+	t.Run("default content type", func(t *testing.T) {
+		provider := &uploadOptionsProvider{NewMockStorageProvider(), make(map[string]storage.UploadOptions)}
+		meteringWriter := NewMeteringWriterWithSharedPool(provider, config.DefaultConfig(), "pool1")
+		defer meteringWriter.Close()
+
+		assert.NoError(t, meteringWriter.Write(ctx, newData("tikv001")))
+		assert.Equal(t, storage.UploadOptions{ContentType: config.DefaultContentType},
+			provider.options["metering/ru/1640995200/storage/pool1/tikv001-0.json.gz"])
+	})
+
+	t.Run("configured and per-write options", func(t *testing.T) {
+		provider := &uploadOptionsProvider{NewMockStorageProvider(), make(map[string]storage.UploadOptions)}
+		cfg := config.DefaultConfig().
+			WithContentType("application/json").
+			WithContentEncoding("gzip").
+			WithCacheControl("max-age=3600").
+			WithStorageClass("INTELLIGENT_TIERING").
+			WithTags(map[string]string{"tenant": "acme"})
+		meteringWriter := NewMeteringWriterWithSharedPool(provider, cfg, "pool1")
+		defer meteringWriter.Close()
+
+		assert.NoError(t, meteringWriter.Write(ctx, newData("tikv001")))
+		assert.Equal(t, storage.UploadOptions{
+			ContentType:     "application/json",
+			ContentEncoding: "gzip",
+			CacheControl:    "max-age=3600",
+			StorageClass:    "INTELLIGENT_TIERING",
+			Tags:            map[string]string{"tenant": "acme"},
+		}, provider.options["metering/ru/1640995200/storage/pool1/tikv001-0.json.gz"])
+
+		override := storage.UploadOptions{ContentType: "application/octet-stream", CacheControl: "no-store"}
+		assert.NoError(t, meteringWriter.Write(storage.WithUploadOptions(ctx, &override), newData("tikv002")))
+		assert.Equal(t, override, provider.options["metering/ru/1640995200/storage/pool1/tikv002-0.json.gz"])
+	})
+}
+
+// slowUploadProvider delays uploads and records the peak number of concurrent uploads
+type slowUploadProvider struct {
+	*storage.MemoryProvider
+	active atomic.Int32
+	peak   atomic.Int32
+	fail   string // uploads to paths containing fail return an error
+}
+
+func (p *slowUploadProvider) Upload(ctx context.Context, path string, data io.Reader) error {
+	return p.upload(ctx, path, data, p.MemoryProvider.Upload)
+}
+
+func (p *slowUploadProvider) UploadIfNotExists(ctx context.Context, path string, data io.Reader) error {
+	return p.upload(ctx, path, data, p.MemoryProvider.UploadIfNotExists)
+}
+
+func (p *slowUploadProvider) upload(ctx context.Context, path string, data io.Reader, upload func(context.Context, string, io.Reader) error) error {
+	active := p.active.Add(1)
+	defer p.active.Add(-1)
+	for peak := p.peak.Load(); active > peak && !p.peak.CompareAndSwap(peak, active); peak = p.peak.Load() {
+	}
+	time.Sleep(20 * time.Millisecond)
+	if p.fail != "" && strings.Contains(path, p.fail) {
+		return fmt.Errorf("upload of %s failed", path)
+	}
+	return upload(ctx, path, data)
+}
+
+// TestMeteringWriterUploadConcurrency tests that pages are uploaded in parallel with ordered part numbers
+func TestMeteringWriterUploadConcurrency(t *testing.T) {
+	testData := &common.MeteringData{
+		Timestamp: 1640995200,
+		Category:  "storage",
+		SelfID:    "tikv001",
+	}
+	for i := 0; i < 8; i++ {
+		testData.Data = append(testData.Data, map[string]interface{}{"logical_cluster_id": fmt.Sprintf("lc-%d", i)})
+	}
+	ctx := context.Background()
+
+	provider := &slowUploadProvider{MemoryProvider: storage.NewMemoryProvider()}
+	cfg := config.DefaultConfig().WithPageSize(10).WithUploadConcurrency(4)
+	meteringWriter := NewMeteringWriterWithSharedPool(provider, cfg, "pool1")
+	defer meteringWriter.Close()
+	assert.NoError(t, meteringWriter.Write(ctx, testData))
+	assert.Equal(t, int32(4), provider.peak.Load())
+
+	reader := meteringreader.NewMeteringReader(provider, cfg)
+	merged, err := reader.ReadAllParts(ctx, 1640995200, "storage", "tikv001")
+	assert.NoError(t, err)
+	for i, entry := range merged.Data {
+		assert.Equal(t, fmt.Sprintf("lc-%d", i), entry["logical_cluster_id"])
+	}
+
+	t.Run("upload failures are aggregated", func(t *testing.T) {
+		failing := &slowUploadProvider{MemoryProvider: storage.NewMemoryProvider(), fail: "tikv001-"}
+		failingWriter := NewMeteringWriterWithSharedPool(failing, cfg, "pool1")
+		defer failingWriter.Close()
+
+		err := failingWriter.Write(ctx, testData)
+		assert.ErrorContains(t, err, "tikv001-0.json.gz failed")
+		assert.ErrorContains(t, err, "tikv001-3.json.gz failed")
+		assert.Less(t, failing.peak.Load(), int32(5))
+	})
+}
+
+// TestMeteringWriterStaging tests that staged pages are only visible to readers once finalized
+func TestMeteringWriterStaging(t *testing.T) {
+	ctx := context.Background()
+	provider := storage.NewMemoryProvider()
+	cfg := config.DefaultConfig().WithStaging(true).WithPageSize(10)
+	meteringWriter := NewMeteringWriterWithSharedPool(provider, cfg, "pool1")
+	defer meteringWriter.Close()
+
+	testData := &common.MeteringData{
+		Timestamp: 1640995200,
+		Category:  "storage",
+		SelfID:    "tikv001",
+		Data: []map[string]interface{}{
+			{"logical_cluster_id": "lc-1"},
+			{"logical_cluster_id": "lc-2"},
+		},
+	}
+	require.NoError(t, meteringWriter.Write(ctx, testData))
+	assert.Contains(t, provider.Snapshot(), ".tmp/metering/ru/1640995200/storage/pool1/tikv001-1.json.gz")
+
+	reader := meteringreader.NewMeteringReader(provider, config.DefaultConfig().WithRequireFinalized(true))
+	files, err := reader.ListFilesByTimestamp(ctx, 1640995200)
+	require.NoError(t, err)
+	assert.Empty(t, files.Files)
+
+	// A file published without marker, e.g. by a Finalize that crashed halfway, isn't read
+	require.NoError(t, provider.Upload(ctx, "metering/ru/1640995200/storage/pool1/tikv002-0.json.gz", strings.NewReader("partial")))
+
+	require.NoError(t, meteringWriter.Finalize(ctx, 1640995200))
+	files, err = reader.ListFilesByTimestamp(ctx, 1640995200)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"metering/ru/1640995200/storage/pool1/tikv001-0.json.gz",
+		"metering/ru/1640995200/storage/pool1/tikv001-1.json.gz",
+	}, files.Files["storage"])
+	for path := range provider.Snapshot() {
+		assert.NotContains(t, path, layout.StagingPrefix, "staged files should be deleted")
+	}
+	timestamps, err := reader.ListTimestamps(ctx, 1640995140, 1640995260)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1640995200}, timestamps)
+
+	// Nothing left to finalize
+	require.NoError(t, meteringWriter.Finalize(ctx, 1640995200))
+	assert.Error(t, NewMeteringWriter(provider, config.DefaultConfig()).Finalize(ctx, 1640995200), "staging is disabled")
+}
+
+func TestMeteringWriterStagingRecovery(t *testing.T) {
+	ctx := context.Background()
+	provider := storage.NewMemoryProvider()
+	cfg := config.DefaultConfig().WithStaging(true)
+	newData := func(timestamp int64, selfID string) *common.MeteringData {
+		return &common.MeteringData{
+			Timestamp: timestamp,
+			Category:  "storage",
+			SelfID:    selfID,
+			Data:      []map[string]interface{}{{"logical_cluster_id": "lc-1"}},
+		}
+	}
+
+	// A writer crashes after staging two minutes, another component of the pool is staging too
+	crashed := NewMeteringWriterWithSharedPool(provider, cfg, "pool1")
+	require.NoError(t, crashed.Write(ctx, newData(1640995200, "tikv001")))
+	require.NoError(t, crashed.Write(ctx, newData(1640995260, "tikv001")))
+	require.NoError(t, crashed.Write(ctx, newData(1640995200, "tikv002")))
+	require.NoError(t, NewMeteringWriterWithSharedPool(provider, cfg, "pool2").Write(ctx, newData(1640995320, "tikv001")))
+
+	restarted := NewMeteringWriterWithSharedPool(provider, cfg, "pool1")
+	defer restarted.Close()
+	timestamps, err := restarted.StagedTimestamps(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1640995200, 1640995260}, timestamps, "only the shared pool of the writer")
+
+	// Nothing is published before the staged files are recovered
+	require.NoError(t, restarted.Finalize(ctx, 1640995200))
+	assert.NotContains(t, provider.Snapshot(), "metering/ru/1640995200/storage/pool1/tikv001-0.json.gz")
+
+	recovered, err := restarted.RecoverStaged(ctx, 1640995200, "tikv001")
+	require.NoError(t, err)
+	assert.Equal(t, 1, recovered)
+	require.NoError(t, restarted.Finalize(ctx, 1640995200))
+	snapshot := provider.Snapshot()
+	assert.Contains(t, snapshot, "metering/ru/1640995200/storage/pool1/tikv001-0.json.gz")
+	assert.NotContains(t, snapshot, "metering/ru/1640995200/storage/pool1/tikv002-0.json.gz", "other components are left staged")
+	assert.Contains(t, snapshot, ".tmp/metering/ru/1640995200/storage/pool1/tikv002-0.json.gz")
+
+	// Partly written minutes are discarded
+	discarded, err := restarted.DiscardStaged(ctx, 1640995260)
+	require.NoError(t, err)
+	assert.Equal(t, 1, discarded)
+	assert.NotContains(t, provider.Snapshot(), ".tmp/metering/ru/1640995260/storage/pool1/tikv001-0.json.gz")
+	timestamps, err = restarted.StagedTimestamps(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1640995200}, timestamps)
+
+	_, err = NewMeteringWriter(provider, config.DefaultConfig()).RecoverStaged(ctx, 1640995200)
+	assert.Error(t, err, "staging is disabled")
+}
+
+// existsCountingProvider counts Exists calls of a memory provider, which supports conditional uploads
+type existsCountingProvider struct {
+	*storage.MemoryProvider
+	existsCalls atomic.Int32
+}
+
+func (p *existsCountingProvider) Exists(ctx context.Context, path string) (bool, error) {
+	p.existsCalls.Add(1)
+	return p.MemoryProvider.Exists(ctx, path)
+}
+
+// TestMeteringWriterConditionalPut tests that existing files are rejected by conditional uploads by default
+func TestMeteringWriterConditionalPut(t *testing.T) {
+	ctx := context.Background()
+	testData := &common.MeteringData{
+		Timestamp: 1640995200,
+		Category:  "storage",
+		SelfID:    "tikv001",
+		Data:      []map[string]interface{}{{"logical_cluster_id": "lc-1"}},
+	}
+
+	provider := &existsCountingProvider{MemoryProvider: storage.NewMemoryProvider()}
+	meteringWriter := NewMeteringWriterWithSharedPool(provider, config.DefaultConfig(), "pool1")
+	defer meteringWriter.Close()
+	assert.NoError(t, meteringWriter.Write(ctx, testData))
+	assert.ErrorIs(t, meteringWriter.Write(ctx, testData), writer.ErrFileExists)
+	assert.Equal(t, int32(0), provider.existsCalls.Load())
+
+	// Opting out falls back to the Exists pre-check
+	provider = &existsCountingProvider{MemoryProvider: storage.NewMemoryProvider()}
+	checkingWriter := NewMeteringWriterWithSharedPool(provider, config.DefaultConfig().WithConditionalPut(false), "pool1")
+	defer checkingWriter.Close()
+	assert.NoError(t, checkingWriter.Write(ctx, testData))
+	assert.ErrorIs(t, checkingWriter.Write(ctx, testData), writer.ErrFileExists)
+	assert.Equal(t, int32(2), provider.existsCalls.Load())
+}
+
+// TestMeteringWriterEncryption tests that files are encrypted at rest and decrypted by readers with the same keys
+func TestMeteringWriterEncryption(t *testing.T) {
+	keys, err := storage.NewStaticKeyProvider("k1", map[string][]byte{"k1": bytes.Repeat([]byte{7}, 32)})
+	assert.NoError(t, err)
+	provider := storage.NewMemoryProvider()
+	cfg := config.DefaultConfig().WithEncryption(keys)
+	meteringWriter := NewMeteringWriterWithSharedPool(provider, cfg, "pool1")
+	defer meteringWriter.Close()
+
+	ctx := context.Background()
+	testData := &common.MeteringData{
+		Timestamp: 1640995200,
+		Category:  "storage",
+		SelfID:    "tikv001",
+		Data:      []map[string]interface{}{{"logical_cluster_id": "lc-secret"}},
+	}
+	assert.NoError(t, meteringWriter.Write(ctx, testData))
+
+	for path, stored := range provider.Snapshot() {
+		_, err := gzip.NewReader(bytes.NewReader(stored))
+		assert.Error(t, err, "%s should not be stored as plain gzip", path)
+	}
+
+	read, err := meteringreader.NewMeteringReader(provider, cfg).ReadAllParts(ctx, 1640995200, "storage", "tikv001")
+	assert.NoError(t, err)
+	assert.Equal(t, "lc-secret", read.Data[0]["logical_cluster_id"])
+
+	_, err = meteringreader.NewMeteringReader(provider, config.DefaultConfig()).ReadAllParts(ctx, 1640995200, "storage", "tikv001")
+	assert.Error(t, err, "reading without the keys should fail")
+}
+
+// TestMeteringWriterDeadLetters tests that pages of failed uploads are dead-lettered and can be replayed
+func TestMeteringWriterDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	queue, err := writer.NewLocalDeadLetterQueue(t.TempDir())
+	require.NoError(t, err)
+	failures := make(chan *writer.WriteFailure, 10)
+	cfg := config.DefaultConfig().
+		WithPageSize(1).
+		WithUploadConcurrency(2).
+		WithErrorSink(writer.NewChannelErrorSink(failures)).
+		WithDeadLetterQueue(queue)
+	failing := &slowUploadProvider{MemoryProvider: storage.NewMemoryProvider(), fail: "tikv001"}
+	meteringWriter := NewMeteringWriterWithSharedPool(failing, cfg, "pool1")
+	defer meteringWriter.Close()
+
+	testData := &common.MeteringData{
+		Timestamp: 1640995200,
+		Category:  "storage",
+		SelfID:    "tikv001",
+		Data: []map[string]interface{}{
+			{"logical_cluster_id": "lc-001", "disk_usage": &common.MeteringValue{Value: 100, Unit: "GB"}},
+			{"logical_cluster_id": "lc-002", "disk_usage": &common.MeteringValue{Value: 200, Unit: "GB"}},
+		},
+	}
+	err = meteringWriter.Write(ctx, testData)
+	assert.ErrorIs(t, err, writer.ErrStorage)
+	for range 2 {
+		failure := <-failures
+		assert.Equal(t, writer.ErrorClassStorage, failure.Class)
+		assert.True(t, failure.DeadLettered)
+	}
+
+	// Replaying into storage that still fails keeps the letters
+	replayed, err := queue.Replay(ctx, meteringWriter.ReplayDeadLetter)
+	assert.Error(t, err)
+	assert.Zero(t, replayed)
+	for len(failures) > 0 {
+		<-failures
+	}
+
+	// Replay into healthy storage
+	healthy := storage.NewMemoryProvider()
+	replayWriter := NewMeteringWriterWithSharedPool(healthy, cfg, "pool1")
+	defer replayWriter.Close()
+	replayed, err = queue.Replay(ctx, replayWriter.ReplayDeadLetter)
+	require.NoError(t, err)
+	assert.Equal(t, 2, replayed)
+
+	reader := meteringreader.NewMeteringReader(healthy, config.DefaultConfig())
+	for part, clusterID := range []string{"lc-001", "lc-002"} {
+		page, err := reader.ReadFile(ctx, fmt.Sprintf("metering/ru/1640995200/storage/pool1/tikv001-%d.json.gz", part))
+		require.NoError(t, err)
+		assert.Equal(t, clusterID, page.Data[0]["logical_cluster_id"])
+	}
+
+	// Replayed letters are removed
+	replayed, err = queue.Replay(ctx, replayWriter.ReplayDeadLetter)
+	assert.NoError(t, err)
+	assert.Zero(t, replayed)
+	assert.Empty(t, failures)
+}
+
+// TestMeteringWriterHooks tests that hooks observe uploaded pages, failures and finished writes
+func TestMeteringWriterHooks(t *testing.T) {
+	ctx := context.Background()
+	// Pages are uploaded sequentially, so the hooks need no locking
+	var (
+		pages   []*writer.PageEvent
+		errs    []*writer.WriteFailure
+		flushes []*writer.FlushEvent
+	)
+	hooks := &writer.Hooks{
+		OnPageWritten: func(ctx context.Context, page *writer.PageEvent) {
+			pages = append(pages, page)
+		},
+		OnWriteError: func(ctx context.Context, failure *writer.WriteFailure) {
+			errs = append(errs, failure)
+		},
+		OnFlush: func(ctx context.Context, flush *writer.FlushEvent) {
+			flushes = append(flushes, flush)
+		},
+	}
+	meteringWriter := NewMeteringWriterWithSharedPool(storage.NewMemoryProvider(), config.DefaultConfig().WithPageSize(1).WithHooks(hooks), "pool1")
+	defer meteringWriter.Close()
+
+	testData := &common.MeteringData{
+		Timestamp: 1640995200,
+		Category:  "storage",
+		SelfID:    "tikv001",
+		Data: []map[string]interface{}{
+			{"logical_cluster_id": "lc-001", "disk_usage": &common.MeteringValue{Value: 100, Unit: "GB"}},
+			{"logical_cluster_id": "lc-002", "disk_usage": &common.MeteringValue{Value: 200, Unit: "GB"}},
+		},
+	}
+	require.NoError(t, meteringWriter.Write(ctx, testData))
+	require.Len(t, pages, 2)
+	assert.Equal(t, "metering/ru/1640995200/storage/pool1/tikv001-1.json.gz", pages[1].Path)
+	assert.Equal(t, 1, pages[1].Part)
+	assert.Equal(t, 1, pages[1].Records)
+	require.Len(t, flushes, 1)
+	assert.Equal(t, "storage", flushes[0].Category)
+	assert.Equal(t, int64(1640995200), flushes[0].Timestamp)
+	assert.Equal(t, 2, flushes[0].Pages)
+	assert.Equal(t, int64(pages[0].Size+pages[1].Size), flushes[0].Bytes)
+	assert.NoError(t, flushes[0].Err)
+
+	// Rejected writes are reported to both hooks
+	err := meteringWriter.Write(ctx, testData)
+	assert.ErrorIs(t, err, writer.ErrFileExists)
+	require.Len(t, errs, 1)
+	assert.Equal(t, writer.ErrorClassConflict, errs[0].Class)
+	require.Len(t, flushes, 2)
+	assert.Equal(t, err, flushes[1].Err)
+	assert.Zero(t, flushes[1].Pages)
+}
+
+func TestMeteringWriterAutoTruncateTimestamp(t *testing.T) {
+	ctx := context.Background()
+	newData := func() *common.MeteringData {
+		return &common.MeteringData{
+			Timestamp: 1640995245,
+			Category:  "storage",
+			SelfID:    "tikv001",
+			Data:      []map[string]interface{}{{"logical_cluster_id": "lc-001"}},
+		}
+	}
+
+	provider := storage.NewMemoryProvider()
+	assert.ErrorIs(t, NewMeteringWriter(provider, config.DefaultConfig()).Write(ctx, newData()), writer.ErrValidation)
+
+	meteringWriter := NewMeteringWriter(provider, config.DefaultConfig().WithAutoTruncateTimestamp(true))
+	data := newData()
+	require.NoError(t, meteringWriter.Write(ctx, data))
+	assert.Equal(t, int64(1640995200), data.Timestamp)
+	exists, err := provider.Exists(ctx, "metering/ru/1640995200/storage/default-shared-pool/tikv001-0.json.gz")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// Appended records are bucketed into their minute too
+	require.NoError(t, meteringWriter.AppendRecord(ctx, 1640995261, "storage", "tikv002", map[string]interface{}{"logical_cluster_id": "lc-001"}))
+	require.NoError(t, meteringWriter.AppendRecord(ctx, 1640995319, "storage", "tikv002", map[string]interface{}{"logical_cluster_id": "lc-002"}))
+	require.NoError(t, meteringWriter.Flush(ctx))
+	page, err := meteringreader.NewMeteringReader(provider, config.DefaultConfig()).ReadFile(ctx, "metering/ru/1640995260/storage/default-shared-pool/tikv002-0.json.gz")
+	require.NoError(t, err)
+	assert.Len(t, page.Data, 2)
+}
+
+func TestMeteringWriterDryRun(t *testing.T) {
+	ctx := context.Background()
+	data := make([]map[string]interface{}, 20)
+	for i := range data {
+		data[i] = map[string]interface{}{
+			"logical_cluster_id": fmt.Sprintf("lc-%03d", i),
+			"ru":                 &common.MeteringValue{Value: uint64(i), Unit: "RU"},
+		}
+	}
+
+	for _, streaming := range []bool{false, true} {
+		var pages []*writer.PageEvent
+		provider := storage.NewMemoryProvider()
+		cfg := config.DefaultConfig().WithDryRun(true).WithPageSize(200).WithStaging(true)
+		cfg.StreamingUpload = streaming
+		cfg.Hooks = &writer.Hooks{OnPageWritten: func(_ context.Context, page *writer.PageEvent) {
+			pages = append(pages, page)
+		}}
+		meteringWriter := NewMeteringWriter(provider, cfg)
+
+		require.NoError(t, meteringWriter.Write(ctx, &common.MeteringData{
+			Timestamp: 1640995200,
+			Category:  "storage",
+			SelfID:    "tikv001",
+			Data:      data,
+		}))
+		require.NoError(t, meteringWriter.Finalize(ctx, 1640995200))
+		assert.Empty(t, provider.Snapshot())
+		assert.Greater(t, len(pages), 1)
+		for _, page := range pages {
+			assert.Positive(t, page.Size)
+		}
+
+		// Validation still runs
+		err := meteringWriter.Write(ctx, &common.MeteringData{Timestamp: 1640995201, Category: "storage", SelfID: "tikv001"})
+		assert.ErrorIs(t, err, writer.ErrValidation)
+	}
+}
+
+func TestMeteringWriterCallStats(t *testing.T) {
+	ctx := context.Background()
+	data := make([]map[string]interface{}, 20)
+	for i := range data {
+		data[i] = map[string]interface{}{
+			"logical_cluster_id": fmt.Sprintf("lc-%03d", i),
+			"ru":                 &common.MeteringValue{Value: uint64(i), Unit: "RU"},
+		}
+	}
+
+	provider := storage.NewMemoryProvider()
+	meteringWriter := NewMeteringWriter(provider, config.DefaultConfig().WithPageSize(200))
+	require.NoError(t, meteringWriter.Write(ctx, &common.MeteringData{
+		Timestamp: 1640995200,
+		Category:  "storage",
+		SelfID:    "tikv001",
+		Data:      data,
+	}))
+
+	stats := meteringWriter.CallStats()
+	assert.Greater(t, stats.Puts, int64(1))
+	assert.Equal(t, int64(len(provider.Snapshot())), stats.Puts)
+	assert.Zero(t, stats.Gets)
+}
+
+func TestMeteringWriterClockSkew(t *testing.T) {
+	ctx := context.Background()
+	current := utils.GetCurrentMinuteTimestamp()
+	newData := func(timestamp int64) *common.MeteringData {
+		return &common.MeteringData{
+			Timestamp: timestamp,
+			Category:  "storage",
+			SelfID:    "tikv001",
+			Data:      []map[string]interface{}{{"logical_cluster_id": "lc-001"}},
+		}
+	}
+
+	cfg := config.DefaultConfig().WithClockSkewTolerance(5*time.Minute, time.Hour)
+	meteringWriter := NewMeteringWriter(storage.NewMemoryProvider(), cfg)
+	require.NoError(t, meteringWriter.Write(ctx, newData(current)))
+	require.NoError(t, meteringWriter.Write(ctx, newData(current+300)))
+	require.NoError(t, meteringWriter.Write(ctx, newData(current-3600)))
+	err := meteringWriter.Write(ctx, newData(current+360))
+	assert.ErrorIs(t, err, writer.ErrClockSkew)
+	assert.ErrorIs(t, err, writer.ErrValidation)
+	assert.ErrorIs(t, meteringWriter.Write(ctx, newData(current-3660)), writer.ErrClockSkew)
+	assert.ErrorIs(t, meteringWriter.AppendRecord(ctx, current+3600, "storage", "tikv002", map[string]interface{}{}), writer.ErrClockSkew)
+
+	// Only logged in WarnOnly mode
+	meteringWriter = NewMeteringWriter(storage.NewMemoryProvider(), cfg.WithClockSkewWarnOnly(true))
+	assert.NoError(t, meteringWriter.Write(ctx, newData(current+3600)))
+}
+
+func TestMeteringWriterSelfIDValidator(t *testing.T) {
+	ctx := context.Background()
+	data := &common.MeteringData{
+		Timestamp: 1640995200,
+		Category:  "storage",
+		SelfID:    "tikv-0",
+		Data:      []map[string]interface{}{{"logical_cluster_id": "lc-001"}},
+	}
+	provider := storage.NewMemoryProvider()
+	assert.ErrorIs(t, NewMeteringWriter(provider, config.DefaultConfig()).Write(ctx, data), writer.ErrValidation)
+
+	cfg := config.DefaultConfig().WithSelfIDValidator(config.PatternIDValidator(regexp.MustCompile(`[a-z0-9-]+`)))
+	require.NoError(t, NewMeteringWriter(provider, cfg).Write(ctx, data))
+	reader := meteringreader.NewMeteringReader(provider, config.DefaultConfig())
+	files, err := reader.ListFilesByTimestamp(ctx, data.Timestamp, meteringreader.WithSelfIDFilter("tikv-0"))
+	require.NoError(t, err)
+	path := "metering/ru/1640995200/storage/default-shared-pool/tikv--0-0.json.gz"
+	assert.Equal(t, map[string][]string{"storage": {path}}, files.Files)
+	page, err := reader.ReadFile(ctx, path)
+	require.NoError(t, err)
+	assert.Equal(t, "tikv-0", page.SelfID)
+}
+
+func TestMeteringWriterUnitValidation(t *testing.T) {
+	ctx := context.Background()
+	newData := func(unit string) *common.MeteringData {
+		return &common.MeteringData{
+			Timestamp: 1640995200,
+			Category:  "storage",
+			SelfID:    "tikv001",
+			Data:      []map[string]interface{}{{"logical_cluster_id": "lc-001", "disk": &common.MeteringValue{Value: 1, Unit: unit}}},
+		}
+	}
+	meteringWriter := NewMeteringWriter(storage.NewMemoryProvider(), config.DefaultConfig().WithUnitRegistry(units.Default()).WithOverwriteExisting(true))
+	require.NoError(t, meteringWriter.Write(ctx, newData("MiB")))
+	assert.ErrorIs(t, meteringWriter.Write(ctx, newData("Mib")), writer.ErrValidation)
+	assert.ErrorIs(t, meteringWriter.AppendRecord(ctx, 1640995260, "storage", "tikv001", newData("megabytes").Data[0]), writer.ErrValidation)
+}
+
+func TestMeteringWriter_AppendRecord(t *testing.T) {
+	ctx := context.Background()
+	provider := storage.NewMemoryProvider()
+	meteringWriter := NewMeteringWriter(provider, config.DefaultConfig())
+	record := func(id string) map[string]interface{} {
+		return map[string]interface{}{"logical_cluster_id": id, "disk_usage": &common.MeteringValue{Value: 100, Unit: "GB"}}
+	}
+	readFile := func(path string) *common.MeteringData {
+		data, err := meteringreader.NewMeteringReader(provider, config.DefaultConfig()).ReadFile(ctx, path)
+		require.NoError(t, err)
+		return data
+	}
+	first := "metering/ru/1640995200/storage/default-shared-pool/tikv001-0.json.gz"
+
+	require.NoError(t, meteringWriter.AppendRecord(ctx, 1640995200, "storage", "tikv001", record("lc-001")))
+	require.NoError(t, meteringWriter.AppendRecord(ctx, 1640995200, "storage", "tikv001", record("lc-002")))
+	require.NoError(t, meteringWriter.AppendRecord(ctx, 1640995200, "storage", "tikv002", record("lc-001")))
+	exists, err := provider.Exists(ctx, first)
+	require.NoError(t, err)
+	assert.False(t, exists, "records are buffered until the minute rolls over")
+
+	// A record for the next minute writes the previous one
+	require.NoError(t, meteringWriter.AppendRecord(ctx, 1640995260, "storage", "tikv001", record("lc-003")))
+	data := readFile(first)
+	require.Len(t, data.Data, 2)
+	assert.Equal(t, "lc-002", data.Data[1]["logical_cluster_id"])
+
+	// Records for written minutes are rejected, invalid records fail up front
+	err = meteringWriter.AppendRecord(ctx, 1640995200, "storage", "tikv001", record("lc-004"))
+	assert.ErrorIs(t, err, writer.ErrLateRecord)
+	assert.ErrorIs(t, err, writer.ErrValidation)
+	assert.Error(t, meteringWriter.AppendRecord(ctx, 1640995261, "storage", "tikv001", record("lc-004")))
+	assert.Error(t, meteringWriter.AppendRecord(ctx, 1640995260, "storage", "tikv-001", record("lc-004")))
+
+	// Flush writes every open minute
+	require.NoError(t, meteringWriter.Flush(ctx))
+	assert.Len(t, readFile("metering/ru/1640995200/storage/default-shared-pool/tikv002-0.json.gz").Data, 1)
+	assert.Len(t, readFile("metering/ru/1640995260/storage/default-shared-pool/tikv001-0.json.gz").Data, 1)
+	assert.ErrorIs(t, meteringWriter.AppendRecord(ctx, 1640995260, "storage", "tikv001", record("lc-004")), writer.ErrLateRecord)
+
+	// Shutdown writes the remaining records and refuses later ones
+	require.NoError(t, meteringWriter.AppendRecord(ctx, 1640995320, "storage", "tikv001", record("lc-005")))
+	dropped, err := meteringWriter.Shutdown(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, dropped)
+	assert.Len(t, readFile("metering/ru/1640995320/storage/default-shared-pool/tikv001-0.json.gz").Data, 1)
+	assert.ErrorIs(t, meteringWriter.AppendRecord(ctx, 1640995380, "storage", "tikv001", record("lc-006")), writer.ErrWriterClosed)
+}
+
+func TestMeteringWriter_AppendRecordFailure(t *testing.T) {
+	ctx := context.Background()
+	provider := storage.NewMemoryProvider()
+	meteringWriter := NewMeteringWriter(provider, config.DefaultConfig())
+	record := map[string]interface{}{"logical_cluster_id": "lc-001", "disk_usage": &common.MeteringValue{Value: 100, Unit: "GB"}}
+	exists := func(timestamp int64) bool {
+		exists, err := provider.Exists(ctx, fmt.Sprintf("metering/ru/%d/storage/default-shared-pool/tikv001-0.json.gz", timestamp))
+		require.NoError(t, err)
+		return exists
+	}
+
+	// Records of a minute that fails to roll over are kept for the next write
+	require.NoError(t, meteringWriter.AppendRecord(ctx, 1640995200, "storage", "tikv001", record))
+	provider.SetErrorRate(1)
+	assert.Error(t, meteringWriter.AppendRecord(ctx, 1640995260, "storage", "tikv001", record))
+	assert.Error(t, meteringWriter.Flush(ctx))
+	provider.SetErrorRate(0)
+	require.NoError(t, meteringWriter.AppendRecord(ctx, 1640995320, "storage", "tikv001", record))
+	assert.True(t, exists(1640995200))
+	assert.True(t, exists(1640995260))
+
+	// Close writes the open minute
+	require.NoError(t, meteringWriter.Close())
+	assert.True(t, exists(1640995320))
+
+	// Records Close fails to write are reported
+	meteringWriter = NewMeteringWriter(provider, config.DefaultConfig())
+	require.NoError(t, meteringWriter.AppendRecord(ctx, 1640995380, "storage", "tikv001", record))
+	provider.SetErrorRate(1)
+	err := meteringWriter.Close()
+	assert.ErrorContains(t, err, "dropped 1 appended records")
+	provider.SetErrorRate(0)
+	assert.NoError(t, meteringWriter.Close())
+	assert.False(t, exists(1640995380))
+}
+
+// partFailingProvider fails the first conditional upload of paths ending with suffix
+type partFailingProvider struct {
+	*storage.MemoryProvider
+	suffix string
+	failed atomic.Bool
+}
+
+func (p *partFailingProvider) UploadIfNotExists(ctx context.Context, path string, data io.Reader) error {
+	if strings.HasSuffix(path, p.suffix) && p.failed.CompareAndSwap(false, true) {
+		return fmt.Errorf("connection reset")
+	}
+	return p.MemoryProvider.UploadIfNotExists(ctx, path, data)
+}
+
+func TestMeteringWriter_AppendRecordPartialRetry(t *testing.T) {
+	ctx := context.Background()
+	provider := &partFailingProvider{MemoryProvider: storage.NewMemoryProvider(), suffix: "tikv001-1.json.gz"}
+	meteringWriter := NewMeteringWriter(provider, config.DefaultConfig().WithPageSize(1))
+	record := func(id string) map[string]interface{} {
+		return map[string]interface{}{"logical_cluster_id": id, "disk_usage": &common.MeteringValue{Value: 100, Unit: "GB"}}
+	}
+
+	// The first page is written, the second fails
+	require.NoError(t, meteringWriter.AppendRecord(ctx, 1640995200, "storage", "tikv001", record("lc-001")))
+	require.NoError(t, meteringWriter.AppendRecord(ctx, 1640995200, "storage", "tikv001", record("lc-002")))
+	err := meteringWriter.AppendRecord(ctx, 1640995260, "storage", "tikv001", record("lc-003"))
+	assert.ErrorIs(t, err, writer.ErrStorage)
+	assert.Equal(t, 2, meteringWriter.RetryBacklog())
+
+	// The retry skips the page that was written instead of failing on it
+	require.NoError(t, meteringWriter.Flush(ctx))
+	assert.Zero(t, meteringWriter.RetryBacklog())
+	for _, path := range []string{
+		"metering/ru/1640995200/storage/default-shared-pool/tikv001-0.json.gz",
+		"metering/ru/1640995200/storage/default-shared-pool/tikv001-1.json.gz",
+		"metering/ru/1640995260/storage/default-shared-pool/tikv001-0.json.gz",
+	} {
+		exists, err := provider.Exists(ctx, path)
+		require.NoError(t, err)
+		assert.True(t, exists, path)
+	}
+
+	// Conflicts of a first attempt are not retried, the minute was written by someone else
+	conflicting := NewMeteringWriter(provider, config.DefaultConfig())
+	require.NoError(t, conflicting.AppendRecord(ctx, 1640995260, "storage", "tikv001", record("lc-004")))
+	assert.ErrorIs(t, conflicting.Flush(ctx), writer.ErrFileExists)
+	assert.Zero(t, conflicting.RetryBacklog())
+}
+
+func TestMeteringWriter_AppendRecordPermanentFailure(t *testing.T) {
+	ctx := context.Background()
+	var failures atomic.Int32
+	cfg := config.DefaultConfig().WithErrorSink(writer.ErrorSinkFunc(func(context.Context, *writer.WriteFailure) {
+		failures.Add(1)
+	}))
+	// Without a shared pool ID every write fails validation
+	meteringWriter := NewMeteringWriterWithSharedPool(storage.NewMemoryProvider(), cfg, "")
+	record := map[string]interface{}{"logical_cluster_id": "lc-001", "disk_usage": &common.MeteringValue{Value: 100, Unit: "GB"}}
+
+	require.NoError(t, meteringWriter.AppendRecord(ctx, 1640995200, "storage", "tikv001", record))
+	err := meteringWriter.AppendRecord(ctx, 1640995260, "storage", "tikv001", record)
+	assert.ErrorIs(t, err, writer.ErrValidation)
+	assert.Zero(t, meteringWriter.RetryBacklog(), "validation failures are dropped")
+	assert.Equal(t, int32(1), failures.Load(), "and reported to the error sink")
+
+	// Later writes only fail with their own minute
+	err = meteringWriter.AppendRecord(ctx, 1640995320, "storage", "tikv001", record)
+	assert.ErrorContains(t, err, "at 1640995260")
+	assert.NotContains(t, err.Error(), "at 1640995200")
+	assert.Equal(t, int32(2), failures.Load())
+}
+
+func TestMeteringWriter_Shutdown(t *testing.T) {
+	ctx := context.Background()
+	newData := func(selfID string) *common.MeteringData {
+		return &common.MeteringData{
+			Timestamp: 1640995200,
+			Category:  "storage",
+			SelfID:    selfID,
+			Data: []map[string]interface{}{
+				{"logical_cluster_id": "lc-001", "disk_usage": &common.MeteringValue{Value: 100, Unit: "GB"}},
+				{"logical_cluster_id": "lc-002", "disk_usage": &common.MeteringValue{Value: 200, Unit: "GB"}},
+			},
+		}
+	}
+
+	// In-flight writes finish before Shutdown returns
+	provider := storage.NewMemoryProvider()
+	provider.SetLatency(50 * time.Millisecond)
+	meteringWriter := NewMeteringWriter(provider, config.DefaultConfig())
+	written := make(chan error, 1)
+	go func() { written <- meteringWriter.Write(ctx, newData("tikv001")) }()
+	require.Eventually(t, func() bool { return meteringWriter.inflightRecords.Load() == 2 }, time.Second, time.Millisecond)
+	dropped, err := meteringWriter.Shutdown(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, dropped)
+	assert.NoError(t, <-written)
+	exists, err := provider.Exists(ctx, "metering/ru/1640995200/storage/default-shared-pool/tikv001-0.json.gz")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.ErrorIs(t, meteringWriter.Write(ctx, newData("tikv002")), writer.ErrWriterClosed)
+
+	// Writes still running at the deadline are cancelled and reported as dropped
+	provider.SetLatency(time.Minute)
+	meteringWriter = NewMeteringWriter(provider, config.DefaultConfig())
+	go func() { written <- meteringWriter.Write(ctx, newData("tikv003")) }()
+	require.Eventually(t, func() bool { return meteringWriter.inflightRecords.Load() == 2 }, time.Second, time.Millisecond)
+	shutdownCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	dropped, err = meteringWriter.Shutdown(shutdownCtx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 2, dropped)
+	assert.ErrorIs(t, <-written, context.Canceled)
+}
+
+func TestMeteringWriter_Close(t *testing.T) {
+	ctx := context.Background()
+	meteringWriter := NewMeteringWriter(storage.NewMemoryProvider(), config.DefaultConfig())
+
+	// Concurrent and repeated closes are no-ops
+	done := make(chan error, 4)
+	for range 4 {
+		go func() { done <- meteringWriter.Close() }()
+	}
+	for range 4 {
+		assert.NoError(t, <-done)
+	}
+	assert.NoError(t, meteringWriter.Close())
+
+	data := &common.MeteringData{
+		Timestamp: 1640995200,
+		Category:  "storage",
+		SelfID:    "tikv001",
+		Data:      []map[string]interface{}{{"logical_cluster_id": "lc-001"}},
+	}
+	assert.ErrorIs(t, meteringWriter.Write(ctx, data), writer.ErrWriterClosed)
+	assert.ErrorIs(t, meteringWriter.AppendRecord(ctx, 1640995200, "storage", "tikv001", data.Data[0]), writer.ErrWriterClosed)
+	assert.ErrorIs(t, meteringWriter.Finalize(ctx, 1640995200), writer.ErrWriterClosed)
+}
+
+// BenchmarkMeteringWriter_Write measures per-write allocations of a producer writing 1k pages per second
+func BenchmarkMeteringWriter_Write(b *testing.B) {
+	ctx := context.Background()
+	data := make([]map[string]interface{}, 100)
+	for i := range data {
+		data[i] = map[string]interface{}{
+			"logical_cluster_id": fmt.Sprintf("lc%05d", i),
+			"compute_seconds":    &common.MeteringValue{Value: uint64(i * 60), Unit: "seconds"},
+		}
+	}
+	meteringWriter := NewMeteringWriter(storage.NewMemoryProvider(), config.DefaultConfig().WithOverwriteExisting(true))
+	defer meteringWriter.Close()
+
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		<-ticker.C
+		err := meteringWriter.Write(ctx, &common.MeteringData{
+			Timestamp: 1640995200,
+			Category:  "tidbserver",
+			SelfID:    "tidb001",
+			Data:      data,
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkMeteringWriter_WritePaginated measures a paginated write of 10k logical clusters
+func BenchmarkMeteringWriter_WritePaginated(b *testing.B) {
+	ctx := context.Background()
+	data := make([]map[string]interface{}, 10000)
+	for i := range data {
+		data[i] = map[string]interface{}{
+			"logical_cluster_id": fmt.Sprintf("lc%05d", i),
+			"compute_seconds":    &common.MeteringValue{Value: uint64(i * 60), Unit: "seconds"},
+			"disk_usage":         &common.MeteringValue{Value: uint64(i * 1024), Unit: "MB"},
+		}
+	}
+	meteringWriter := NewMeteringWriter(storage.NewMemoryProvider(), config.DefaultConfig().WithOverwriteExisting(true).WithPageSize(64*1024))
+	defer meteringWriter.Close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := meteringWriter.Write(ctx, &common.MeteringData{
+			Timestamp: 1640995200,
+			Category:  "tidbserver",
+			SelfID:    "tidb001",
+			Data:      data,
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}